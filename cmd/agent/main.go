@@ -0,0 +1,66 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/agent"
+)
+
+// main starts the node agent, the token can also be passed by the CHAOSBLADE_AGENT_TOKEN env
+func main() {
+	config := agent.Config{}
+	flag.StringVar(&config.Address, "address", agent.DefaultAddress, "the http listen address")
+	flag.StringVar(&config.Token, "token", os.Getenv("CHAOSBLADE_AGENT_TOKEN"), "the bearer token of the api")
+	flag.StringVar(&config.JournalFile, "journal", "", "the experiment journal file")
+	flag.BoolVar(&config.RevertOnShutdown, "revert-on-shutdown", false,
+		"destroy the running experiments on shutdown, otherwise they are kept in the journal for the next agent")
+	flag.DurationVar(&config.ShutdownTimeout, "shutdown-timeout", agent.DefaultShutdownTimeout,
+		"the max time waiting for the in-flight requests on shutdown, and then reverting the experiments")
+	flag.StringVar(&config.Runtime, "container-runtime", "", "the container runtime checked by the readiness endpoint")
+	flag.StringVar(&config.Endpoint, "cri-endpoint", "", "the container runtime endpoint")
+	flag.StringVar(&config.Namespace, "container-namespace", "", "the containerd namespace")
+	flag.IntVar(&config.MaxCleanupBacklog, "max-cleanup-backlog", agent.DefaultMaxCleanupBacklog,
+		"the max number of experiments failed to destroy before the agent is not ready")
+	flag.DurationVar(&config.MaxExecDuration, "max-exec-duration", agent.DefaultMaxExecDuration,
+		"the max time of one experiment execution before the agent is considered wedged")
+	flag.StringVar(&config.EventSink, "event-sink", "",
+		"send the experiment CloudEvents to http(s)://host/path or kafka://rest-proxy-host:port/topic")
+	flag.StringVar(&config.ReportFile, "report-file", "",
+		"refresh the experiment report after every experiment, junit xml if the extension is .xml, otherwise json")
+	flag.BoolVar(&config.ReadOnly, "read-only", false,
+		"serve the inspection apis only, the experiments are refused and the runtime clients are read-only")
+	flag.StringVar(&config.ProfilesFile, "profiles", "",
+		"the yaml of the named experiment profiles pre-setting the flags, the pinned targets and the ttl")
+	flag.Parse()
+
+	a, err := agent.New(config)
+	if err != nil {
+		log.Fatalf("create agent failed, %v", err)
+	}
+	go func() {
+		if err := a.Start(); err != nil {
+			log.Fatalf("agent exit, %v", err)
+		}
+	}()
+	util.Hold(a)
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/compat"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio"
+)
+
+// main checks the runtime capabilities on the node and prints the supported experiments
+func main() {
+	endpoint := flag.String("cri-endpoint", crio.DefaultStateUinxAddress, "the container runtime endpoint")
+	namespace := flag.String("container-namespace", "", "the containerd namespace")
+	var tlsOptions crio.TLSOptions
+	flag.StringVar(&tlsOptions.CAFile, "cri-tls-ca", "", "the ca file verifying the tls endpoint, such as tcp://10.0.0.1:10010")
+	flag.StringVar(&tlsOptions.CertFile, "cri-tls-cert", "", "the client certificate file of the tls endpoint")
+	flag.StringVar(&tlsOptions.KeyFile, "cri-tls-key", "", "the client key file of the tls endpoint")
+	flag.StringVar(&tlsOptions.ServerName, "cri-tls-server-name", "", "the server name verified, default is the host of the endpoint")
+	containerId := flag.String("container-id", "", "the running container to check, the first container is used if empty")
+	output := flag.String("output", "table", "the output format, table or json")
+	timeout := flag.Duration("timeout", time.Minute, "the timeout of the check")
+	nodeOnly := flag.Bool("node", false, "report the features of the node and the usable experiments only, the "+
+		"runtime is not checked, for the scheduling of the experiments")
+	flag.Parse()
+
+	if *nodeOnly {
+		client, err := crio.NewClientWithTLS(*endpoint, *namespace, tlsOptions)
+		if err != nil {
+			// the features of the node are reported without the runtime version
+			log.Printf("connect runtime failed, %v", err)
+		}
+		defer crio.CloseClient()
+		var runtime container.Container
+		if client != nil {
+			runtime = client
+		}
+		printReport(compat.CheckNode(context.Background(), runtime), *output)
+		return
+	}
+
+	client, err := crio.NewClientWithTLS(*endpoint, *namespace, tlsOptions)
+	if err != nil {
+		log.Fatalf("connect runtime failed, %v", err)
+	}
+	defer crio.CloseClient()
+	ctx, cancel := context.WithTimeout(client.Ctx, *timeout)
+	defer cancel()
+
+	report, err := compat.Check(ctx, client, *containerId)
+	if err != nil {
+		log.Fatalf("check runtime failed, %v", err)
+	}
+	printReport(report, *output)
+}
+
+// printReport writes the report as json or tables
+func printReport(report interface{ Print(io.Writer) }, output string) {
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+		return
+	}
+	report.Print(os.Stdout)
+}
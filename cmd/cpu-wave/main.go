@@ -0,0 +1,56 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/wave"
+)
+
+// main burns the cpus by the wave until the duration elapsed or it is terminated
+func main() {
+	var w wave.Wave
+	flag.StringVar(&w.Shape, "shape", wave.ShapeSquare, "the shape of the bursts, square, spike or ramp")
+	flag.IntVar(&w.High, "high", 90, "the percent of every cpu burnt during the burst")
+	flag.IntVar(&w.Low, "low", 0, "the percent of every cpu burnt out of the burst")
+	flag.IntVar(&w.Period, "period", 60, "the seconds of the period")
+	flag.IntVar(&w.Burst, "burst", 10, "the seconds of the burst at the start of every period")
+	cpus := flag.Int("cpu-count", 1, "the cpus burnt")
+	duration := flag.Duration("duration", 0, "the duration of the burn, 0 is until terminated")
+	// the uid is not used, it identifies the burner of the experiment by the command line
+	flag.String("uid", "", "the uid of the experiment")
+	flag.Parse()
+
+	if err := w.Validate(); err != nil {
+		log.Fatalf("illegal wave, %v", err)
+	}
+	if *cpus < 1 {
+		log.Fatalf("illegal cpu count %d", *cpus)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer cancel()
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+	wave.Burn(ctx, w, *cpus)
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/holder"
+)
+
+// main opens the file descriptors as the user of the application and holds them until it is terminated, the host
+// pid and the number opened are written to the ready file
+func main() {
+	count := flag.Int("count", 0, "the file descriptors opened")
+	uid := flag.Int("uid", -1, "the uid opening the files, the current user if negative")
+	gid := flag.Int("gid", -1, "the gid opening the files")
+	soft := flag.Uint64("nofile-soft", 0, "the soft open files limit, the current limit if 0")
+	hard := flag.Uint64("nofile-hard", 0, "the hard open files limit")
+	ready := flag.String("ready", "", "the file the host pid and the number of the opened files written to")
+	flag.Parse()
+
+	// the ready file is opened before the privileges are dropped
+	readyFile, err := os.OpenFile(*ready, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Fatalf("open ready file failed, %v", err)
+	}
+	if *uid >= 0 {
+		if err := holder.DropPrivileges(*uid, *gid, *soft, *hard); err != nil {
+			log.Fatalf("drop privileges failed, %v", err)
+		}
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	// the proc of the host resolves the self link to the host pid, the holder is in the pid namespace of the container
+	self, err := os.Readlink("/proc/self")
+	if err != nil {
+		log.Fatalf("read host pid failed, %v", err)
+	}
+	fds, err := holder.OpenFiles(*count)
+	if err != nil {
+		fmt.Fprintf(readyFile, "%s %d %v\n", self, len(fds), err)
+	} else {
+		fmt.Fprintf(readyFile, "%s %d\n", self, len(fds))
+	}
+	readyFile.Close()
+	<-signals
+}
@@ -0,0 +1,678 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/compat"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/event"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/killswitch"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/node"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/probe"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/profile"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/scenario"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/scheduler"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+const (
+	DefaultAddress         = ":9526"
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+// Config of the node agent
+type Config struct {
+	// Address is the http listen address
+	Address string
+	// Token is the bearer token required by every api request
+	Token string
+	// JournalFile is the experiment journal path
+	JournalFile string
+	// RevertOnShutdown destroys the running experiments on shutdown, otherwise they are kept in the journal
+	// and handed off to the next agent
+	RevertOnShutdown bool
+	// ShutdownTimeout is the max time waiting for the in-flight requests on shutdown, and then the max time reverting
+	// the running experiments if RevertOnShutdown
+	ShutdownTimeout time.Duration
+	// Runtime, Endpoint and Namespace are the container runtime checked by the readiness endpoint
+	Runtime   string
+	Endpoint  string
+	Namespace string
+	// MaxCleanupBacklog is the max number of experiments failed to destroy before the agent is not ready
+	MaxCleanupBacklog int
+	// MaxExecDuration is the max time of one experiment execution before the agent is considered wedged
+	MaxExecDuration time.Duration
+	// EventSink receives the CloudEvents of the experiment lifecycle, see event.NewSink for the format
+	EventSink string
+	// ReportFile is refreshed after every experiment is created or destroyed, it is junit xml if the extension
+	// is .xml, otherwise json
+	ReportFile string
+	// ReadOnly serves the inspection apis only, the experiments are refused and the runtime clients are read-only
+	ReadOnly bool
+	// ProfilesFile is the yaml of the named experiment profiles the create requests refer to, see the profile package
+	ProfilesFile string
+}
+
+// ExperimentRequest is the body of the create experiment api
+type ExperimentRequest struct {
+	Target string            `json:"target"`
+	Action string            `json:"action"`
+	Flags  map[string]string `json:"flags,omitempty"`
+	// Faults compose several faults into one scenario experiment instead of the target and the action, they are
+	// injected and destroyed together, see the scenario package
+	Faults []scenario.Fault `json:"faults,omitempty"`
+	// Webhooks receive the result when the experiment is created or destroyed
+	Webhooks []string `json:"webhooks,omitempty"`
+	// Probes are evaluated before the injection and after the revert, the experiment is not injected if they fail
+	Probes []probe.Probe `json:"probes,omitempty"`
+	// Flap reverts and injects the fault again by the duty cycle, the intermittent degradation instead of the constant
+	Flap *journal.Flap `json:"flap,omitempty"`
+	// Profile is the name of the profile pre-setting the experiment, its flags and its ttl
+	Profile string `json:"profile,omitempty"`
+}
+
+// Agent is the long-running node agent which keeps the runtime clients and the journal warm
+// and serves the experiments through http api
+type Agent struct {
+	config    Config
+	journal   *journal.Journal
+	executors map[string]spec.Executor
+	server    *http.Server
+	events    *event.Emitter
+	notifier  *event.Notifier
+	profiles  profile.Set
+
+	// the executors keep the runtime client in their fields, so the experiments are executed one by one
+	execMu sync.Mutex
+	// execStart is the start time of the current execution, zero if idle
+	execStart atomic.Value
+
+	stateMu  sync.RWMutex
+	stopping bool
+
+	schedulesMu sync.Mutex
+	schedules   map[string]*runningSchedule
+	schedulesWg sync.WaitGroup
+
+	flapsMu sync.Mutex
+	flaps   map[string]*runningFlap
+
+	// expiries destroy the experiments of the profiles when their ttl elapses
+	expiriesMu sync.Mutex
+	expiries   map[string]*time.Timer
+
+	// killFails are the experiments the engaged kill switch failed to revert, they are retried on every check
+	killMu    sync.Mutex
+	killFails []KillSwitchFailure
+
+	// stop is closed on shutdown, it stops the watch of the kill switch
+	stop chan struct{}
+}
+
+// New creates the agent, the token is required
+func New(config Config) (*Agent, error) {
+	if config.Token == "" {
+		return nil, errors.New("the agent token is required")
+	}
+	if config.Address == "" {
+		config.Address = DefaultAddress
+	}
+	if config.ShutdownTimeout == 0 {
+		config.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if config.MaxCleanupBacklog == 0 {
+		config.MaxCleanupBacklog = DefaultMaxCleanupBacklog
+	}
+	if config.MaxExecDuration == 0 {
+		config.MaxExecDuration = DefaultMaxExecDuration
+	}
+	if config.ReadOnly {
+		exec.ReadOnly = true
+	}
+	j, err := journal.Open(config.JournalFile)
+	if err != nil {
+		return nil, err
+	}
+	var sink event.Sink
+	if config.EventSink != "" {
+		if sink, err = event.NewSink(config.EventSink); err != nil {
+			return nil, err
+		}
+	}
+	profiles := profile.Set{}
+	if config.ProfilesFile != "" {
+		if profiles, err = profile.Load(config.ProfilesFile); err != nil {
+			return nil, err
+		}
+	}
+	// the ssh tunnels are kept across the experiments and closed on shutdown
+	exec.Resident = true
+	a := &Agent{
+		config:    config,
+		journal:   j,
+		executors: exec.GetAllExecutors(),
+		events:    event.NewEmitter(sink),
+		notifier:  event.NewNotifier(),
+		profiles:  profiles,
+		schedules: make(map[string]*runningSchedule),
+		flaps:     make(map[string]*runningFlap),
+		expiries:  make(map[string]*time.Timer),
+		stop:      make(chan struct{}),
+	}
+	// the node architecture is detected at the start, so the missing nsexec is reported before any experiment
+	if bin, err := nsexec.Bin(); err != nil {
+		log.Warnf(context.Background(), "%v, the experiments entering the container namespaces will fail", err)
+	} else {
+		log.Infof(context.Background(), "the node architecture is %s, nsexec: %s", nsexec.NodeArch(), bin)
+	}
+	if running := a.runningRecords(); len(running) > 0 {
+		log.Infof(context.Background(), "%d running experiments are taken over from the journal %s", len(running), j.File())
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/targets", a.handleTargets)
+	mux.HandleFunc("/v1/namespaces", a.handleNamespaces)
+	mux.HandleFunc("/v1/capabilities", a.handleCapabilities)
+	mux.HandleFunc("/v1/experiments", a.handleExperiments)
+	mux.HandleFunc("/v1/experiments/", a.handleExperiment)
+	mux.HandleFunc("/v1/schedules", a.handleSchedules)
+	mux.HandleFunc("/v1/schedules/", a.handleSchedule)
+	mux.HandleFunc("/v1/reports", a.handleReports)
+	mux.HandleFunc("/v1/bundles", a.handleBundles)
+	mux.HandleFunc("/v1/snapshots", a.handleSnapshots)
+	mux.HandleFunc("/v1/kill-switch", a.handleKillSwitch)
+	mux.HandleFunc("/v1/profiles", a.handleProfiles)
+	// the probes are served without the token for kubelet
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", a.handleHealthz)
+	root.HandleFunc("/readyz", a.handleReadyz)
+	root.Handle("/", a.authenticate(mux))
+	a.server = &http.Server{
+		Addr:    config.Address,
+		Handler: root,
+	}
+	return a, nil
+}
+
+// Journal returns the experiment journal of the agent
+func (a *Agent) Journal() *journal.Journal {
+	return a.journal
+}
+
+// Start serves the api until the server is closed
+func (a *Agent) Start() error {
+	log.Infof(context.Background(), "chaosblade cri agent listen on %s", a.config.Address)
+	go a.collectDeployments(context.Background())
+	go a.watchKillSwitch(context.Background())
+	a.resumeExpiries()
+	if err := a.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting new experiments, waits for the in-flight requests, stops the schedules, then reverts the running
+// experiments or keeps them in the journal for the next agent, and closes the runtime connections at last
+func (a *Agent) Shutdown() error {
+	a.stateMu.Lock()
+	if a.stopping {
+		a.stateMu.Unlock()
+		return nil
+	}
+	a.stopping = true
+	a.stateMu.Unlock()
+	close(a.stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+	log.Infof(ctx, "chaosblade cri agent is shutting down")
+	if err := a.server.Shutdown(ctx); err != nil {
+		log.Warnf(ctx, "wait for the in-flight requests failed, %v", err)
+	}
+	// the schedules and the flaps live in memory only, their experiments are destroyed rather than handed off
+	a.stopSchedules()
+	a.stopFlaps()
+	a.stopExpiries()
+
+	running := a.runningRecords()
+	if a.config.RevertOnShutdown {
+		// the revert has its own timeout, the experiments are reverted even if the draining used up the time above
+		revertCtx, revertCancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+		defer revertCancel()
+		for _, record := range running {
+			if response := a.Destroy(revertCtx, record); !response.Success {
+				log.Warnf(ctx, "revert experiment %s on shutdown failed, %s", record.Uid, response.Err)
+			}
+		}
+	} else if len(running) > 0 {
+		log.Infof(ctx, "%d running experiments are kept in the journal %s", len(running), a.journal.File())
+	}
+	a.events.Close()
+	a.notifier.Close()
+	return exec.CloseClients()
+}
+
+func (a *Agent) isStopping() bool {
+	a.stateMu.RLock()
+	defer a.stateMu.RUnlock()
+	return a.stopping
+}
+
+// runningRecords returns the experiments which have been or are being injected
+func (a *Agent) runningRecords() []journal.Record {
+	running := make([]journal.Record, 0)
+	for _, record := range a.journal.List() {
+		switch record.Status {
+		case journal.StatusCreated, journal.StatusSuccess, journal.StatusDestroyFailed:
+			running = append(running, record)
+		}
+	}
+	return running
+}
+
+func (a *Agent) authenticate(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + a.config.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeResponse(w, http.StatusUnauthorized, spec.ReturnFail(spec.Forbidden, "invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleTargets lists the containers of the runtime given by the query parameters, such as container-runtime
+func (a *Agent) handleTargets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	model := &spec.ExpModel{
+		ActionFlags: map[string]string{
+			exec.ContainerRuntime.Name:   query.Get(exec.ContainerRuntime.Name),
+			exec.EndpointFlag.Name:       query.Get(exec.EndpointFlag.Name),
+			exec.ContainerNamespace.Name: query.Get(exec.ContainerNamespace.Name),
+		},
+	}
+	client, err := exec.GetClientByRuntime(model)
+	if err != nil {
+		writeResponse(w, http.StatusServiceUnavailable, spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err))
+		return
+	}
+	containers, err, code := client.ListContainers(r.Context())
+	if err != nil {
+		writeResponse(w, http.StatusServiceUnavailable, spec.ResponseFail(code, err.Error(), nil))
+		return
+	}
+	writeResponse(w, http.StatusOK, spec.ReturnSuccess(containers))
+}
+
+// handleNamespaces lists the namespaces of the runtime, only containerd has them
+func (a *Agent) handleNamespaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	model := &spec.ExpModel{
+		ActionFlags: map[string]string{
+			exec.ContainerRuntime.Name: query.Get(exec.ContainerRuntime.Name),
+			exec.EndpointFlag.Name:     query.Get(exec.EndpointFlag.Name),
+		},
+	}
+	client, err := exec.GetClientByRuntime(model)
+	if err != nil {
+		writeResponse(w, http.StatusServiceUnavailable, spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err))
+		return
+	}
+	lister, ok := container.AsNamespaceLister(client)
+	if !ok {
+		writeResponse(w, http.StatusBadRequest, spec.ResponseFailWithFlags(spec.ParameterIllegal,
+			exec.ContainerRuntime.Name, query.Get(exec.ContainerRuntime.Name), "the runtime has no namespaces"))
+		return
+	}
+	namespaces, err := lister.Namespaces(r.Context())
+	if err != nil {
+		writeResponse(w, http.StatusServiceUnavailable, spec.ResponseFailWithFlags(spec.ContainerExecFailed, "Namespaces", err))
+		return
+	}
+	writeResponse(w, http.StatusOK, spec.ReturnSuccess(namespaces))
+}
+
+// handleCapabilities reports the features of the node and the usable experiments, the runtime is optional, the node
+// features are reported without its version if it is not reachable
+func (a *Agent) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	var client container.Container
+	if query.Get(exec.ContainerRuntime.Name) != "" {
+		model := &spec.ExpModel{
+			ActionFlags: map[string]string{
+				exec.ContainerRuntime.Name: query.Get(exec.ContainerRuntime.Name),
+				exec.EndpointFlag.Name:     query.Get(exec.EndpointFlag.Name),
+			},
+		}
+		var err error
+		if client, err = exec.GetClientByRuntime(model); err != nil {
+			log.Warnf(r.Context(), "connect the runtime for the capabilities failed, %v", err)
+			client = nil
+		}
+	}
+	writeResponse(w, http.StatusOK, spec.ReturnSuccess(compat.CheckNode(r.Context(), client)))
+}
+
+// handleExperiments creates an experiment or lists the journal
+func (a *Agent) handleExperiments(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeResponse(w, http.StatusOK, spec.ReturnSuccess(a.journal.List()))
+	case http.MethodPost:
+		if a.isStopping() {
+			writeResponse(w, http.StatusServiceUnavailable, spec.ReturnFail(spec.ChaosbladeServiceStoped, "the agent is shutting down"))
+			return
+		}
+		var request ExperimentRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeResponse(w, http.StatusBadRequest, spec.ResponseFailWithFlags(spec.ParameterRequestFailed))
+			return
+		}
+		uid, err := util.GenerateUid()
+		if err != nil {
+			writeResponse(w, http.StatusInternalServerError, spec.ResponseFailWithFlags(spec.GenerateUidFailed, err))
+			return
+		}
+		response := a.Create(r.Context(), uid, request)
+		writeResponse(w, http.StatusOK, response)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleExperiment queries or destroys the experiment by uid, or returns its transcript
+func (a *Agent) handleExperiment(w http.ResponseWriter, r *http.Request) {
+	uid := strings.TrimPrefix(r.URL.Path, "/v1/experiments/")
+	if strings.HasSuffix(uid, transcriptSuffix) {
+		a.handleTranscript(w, r, strings.TrimSuffix(uid, transcriptSuffix))
+		return
+	}
+	record, ok := a.journal.Get(uid)
+	if !ok {
+		writeResponse(w, http.StatusNotFound, spec.ResponseFailWithFlags(spec.DataNotFound, uid))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeResponse(w, http.StatusOK, spec.ReturnSuccess(record))
+	case http.MethodDelete:
+		// the flapping experiment is destroyed by its flap, the record is destroyed already unless that failed
+		if a.stopFlap(uid) {
+			record, _ = a.journal.Get(uid)
+		}
+		writeResponse(w, http.StatusOK, a.Destroy(r.Context(), record))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Create executes the experiment and records it in the journal
+func (a *Agent) Create(ctx context.Context, uid string, request ExperimentRequest) *spec.Response {
+	ttl, response := a.applyProfile(&request)
+	if response != nil {
+		return response
+	}
+	if response := a.prepareScenario(&request); response != nil {
+		return response
+	}
+	executor, ok := a.executorOf(request.Target, request.Action, request.Faults)
+	if !ok {
+		return spec.ResponseFailWithFlags(spec.ActionNotSupport, fmt.Sprintf("%s %s", request.Target, request.Action))
+	}
+	if err := event.ValidateWebhooks(request.Webhooks); err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "webhooks", request.Webhooks, err)
+	}
+	if err := probe.Validate(request.Probes); err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "probes", request.Probes, err)
+	}
+	var cycle scheduler.DutyCycle
+	if request.Flap != nil {
+		var err error
+		if cycle, err = parseFlap(request.Target, request.Flap); err != nil {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, "flap", request.Flap, err)
+		}
+	}
+	record := journal.Record{
+		Uid:      uid,
+		Target:   request.Target,
+		Action:   request.Action,
+		Flags:    request.Flags,
+		Webhooks: request.Webhooks,
+		Probes:   request.Probes,
+		Faults:   request.Faults,
+		Flap:     request.Flap,
+		Profile:  request.Profile,
+	}
+	if ttl > 0 {
+		// the ttl is counted from the create, the expire time is recorded with the experiment for the next agent
+		expireTime := time.Now().Add(ttl)
+		record.ExpireTime = &expireTime
+	}
+	response = a.create(ctx, executor, record)
+	if response.Success && request.Flap != nil {
+		a.startFlap(record, executor, cycle)
+	}
+	if response.Success && ttl > 0 {
+		a.expireAfter(uid, ttl)
+	}
+	return response
+}
+
+func (a *Agent) create(ctx context.Context, executor spec.Executor, record journal.Record) *spec.Response {
+	if a.config.ReadOnly {
+		// the experiments are refused here, those in the host namespaces of the container bypass the runtime client
+		return spec.ReturnFail(spec.Forbidden, "the agent is in the read-only mode")
+	}
+	if state := killswitch.Check(); state.Engaged {
+		return killswitch.Refusal(state)
+	}
+	uid := record.Uid
+	defer a.writeReport(ctx)
+	record.NodeInfo = a.nodeInfo(ctx, record.Flags)
+	if response := a.probeBefore(ctx, &record); response != nil {
+		if err := a.journal.Put(record); err != nil {
+			log.Warnf(ctx, "record experiment %s to journal failed, %v", uid, err)
+		}
+		a.emit(event.TypeFailed, record, response)
+		a.notify(event.PhaseCreate, record, response)
+		return response
+	}
+	record.Status = journal.StatusCreated
+	if err := a.journal.Put(record); err != nil {
+		log.Warnf(ctx, "record experiment %s to journal failed, %v", uid, err)
+	}
+	a.emit(event.TypeCreated, record, nil)
+	response := a.execute(ctx, uid, executor, record)
+	a.updateStatus(ctx, uid, response, journal.StatusSuccess)
+	a.emit(event.TypeInjected, record, response)
+	a.notify(event.PhaseCreate, record, response)
+	if response.Success {
+		response.Result = uid
+	}
+	return response
+}
+
+// Destroy reverts the experiment recorded in the journal
+func (a *Agent) Destroy(ctx context.Context, record journal.Record) *spec.Response {
+	a.cancelExpiry(record.Uid)
+	if record.Status == journal.StatusDestroyed {
+		return spec.ReturnSuccess(record.Uid)
+	}
+	executor, ok := a.executorOf(record.Target, record.Action, record.Faults)
+	if !ok {
+		return spec.ResponseFailWithFlags(spec.ActionNotSupport, fmt.Sprintf("%s %s", record.Target, record.Action))
+	}
+	defer a.writeReport(ctx)
+	response := a.execute(spec.SetDestroyFlag(ctx, record.Uid), record.Uid, executor, record)
+	if response.Success {
+		a.probeAfter(ctx, &record)
+	}
+	a.emit(event.TypeReverted, record, response)
+	a.notify(event.PhaseDestroy, record, response)
+	if !response.Success {
+		if err := a.journal.UpdateStatus(record.Uid, journal.StatusDestroyFailed, response.Err); err != nil {
+			log.Warnf(ctx, "update experiment %s in journal failed, %v", record.Uid, err)
+		}
+		return response
+	}
+	a.updateStatus(ctx, record.Uid, response, journal.StatusDestroyed)
+	return response
+}
+
+// prepareScenario validates the faults of the scenario experiment and records it as the scenario target, the probes
+// and the node info use the flags of the first fault unless the flags are given
+func (a *Agent) prepareScenario(request *ExperimentRequest) *spec.Response {
+	if len(request.Faults) == 0 {
+		return nil
+	}
+	if request.Target != "" || request.Action != "" {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "faults", request.Faults,
+			"the target and the action of a scenario are given by its faults")
+	}
+	if err := scenario.Validate(request.Faults, a.executors); err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "faults", request.Faults, err)
+	}
+	request.Target, request.Action = scenario.Target, scenario.Action
+	if len(request.Flags) == 0 {
+		request.Flags = request.Faults[0].Flags
+	}
+	return nil
+}
+
+// executorOf returns the executor of the target and the action, or the one of the scenario composing the faults
+func (a *Agent) executorOf(target, action string, faults []scenario.Fault) (spec.Executor, bool) {
+	if len(faults) > 0 {
+		return &scenario.Executor{Faults: faults, Executors: a.executors}, true
+	}
+	executor, ok := a.executors[exec.GetExecutorKey(target, action)]
+	return executor, ok
+}
+
+// nodeInfo collects the node context, the runtime is the one of the experiment, the host fields describe the
+// agent node even if the runtime is connected through the ssh tunnel
+func (a *Agent) nodeInfo(ctx context.Context, flags map[string]string) *node.Info {
+	ctx, cancel := context.WithTimeout(ctx, runtimeCheckTimeout)
+	defer cancel()
+	client, err := exec.GetClientByRuntime(&spec.ExpModel{ActionFlags: flags})
+	if err != nil {
+		log.Warnf(ctx, "get the runtime client for the node info failed, %v", err)
+		return node.Collect(ctx, nil)
+	}
+	return node.Collect(ctx, client)
+}
+
+func (a *Agent) execute(ctx context.Context, uid string, executor spec.Executor, record journal.Record) *spec.Response {
+	flags := make(map[string]string, len(record.Flags))
+	for k, v := range record.Flags {
+		flags[k] = v
+	}
+	model := &spec.ExpModel{
+		Target:      record.Target,
+		Scope:       "cri",
+		ActionName:  record.Action,
+		ActionFlags: flags,
+	}
+	a.execMu.Lock()
+	defer a.execMu.Unlock()
+	a.execStart.Store(time.Now())
+	defer a.execStart.Store(time.Time{})
+	ctx, collector := warning.WithCollector(ctx)
+	collected := len(collector.List())
+	response := executor.Exec(uid, ctx, model)
+	if warnings := collector.List()[collected:]; len(warnings) > 0 {
+		if err := a.journal.AddWarnings(uid, warnings); err != nil {
+			log.Warnf(ctx, "record the warnings of experiment %s to journal failed, %v", uid, err)
+		}
+	}
+	return response
+}
+
+func (a *Agent) updateStatus(ctx context.Context, uid string, response *spec.Response, successStatus string) {
+	status, errMsg := successStatus, ""
+	if !response.Success {
+		status, errMsg = journal.StatusError, response.Err
+	}
+	if err := a.journal.UpdateStatus(uid, status, errMsg); err != nil {
+		log.Warnf(ctx, "update experiment %s in journal failed, %v", uid, err)
+	}
+}
+
+// emit sends the lifecycle event, the failed event is sent instead if the response is failed
+func (a *Agent) emit(eventType string, record journal.Record, response *spec.Response) {
+	data := event.ExperimentEvent{
+		Uid:    record.Uid,
+		Target: record.Target,
+		Action: record.Action,
+		Flags:  record.Flags,
+	}
+	if response != nil && !response.Success {
+		eventType = event.TypeFailed
+		data.Error = response.Err
+	}
+	a.events.Emit(eventType, data)
+}
+
+// notify posts the result of the experiment phase to the webhooks of the experiment
+func (a *Agent) notify(phase string, record journal.Record, response *spec.Response) {
+	a.notifier.Notify(record.Webhooks, event.WebhookResult{
+		Uid:      record.Uid,
+		Target:   record.Target,
+		Action:   record.Action,
+		Phase:    phase,
+		Success:  response.Success,
+		Code:     response.Code,
+		Error:    response.Err,
+		Result:   response.Result,
+		Probes:   record.ProbeReport,
+		NodeInfo: record.NodeInfo,
+	})
+}
+
+func writeResponse(w http.ResponseWriter, statusCode int, response *spec.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
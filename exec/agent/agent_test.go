@@ -0,0 +1,399 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/killswitch"
+)
+
+const testToken = "secret"
+
+// fakeExecutor records the creates and the destroys, the destroys fail while failDestroy is set
+type fakeExecutor struct {
+	mu          sync.Mutex
+	created     []string
+	destroyed   []string
+	failDestroy bool
+}
+
+func (e *fakeExecutor) Name() string {
+	return "fake"
+}
+
+func (e *fakeExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *fakeExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := spec.IsDestroy(ctx); ok {
+		if e.failDestroy {
+			return spec.ReturnFail(spec.OsCmdExecFailed, "destroy failed")
+		}
+		e.destroyed = append(e.destroyed, uid)
+		return spec.ReturnSuccess(uid)
+	}
+	e.created = append(e.created, uid)
+	return spec.ReturnSuccess(uid)
+}
+
+func (e *fakeExecutor) calls() ([]string, []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string{}, e.created...), append([]string{}, e.destroyed...)
+}
+
+func (e *fakeExecutor) setFailDestroy(fail bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failDestroy = fail
+}
+
+// newTestAgent returns the agent executing cpu fullload by the fake executor, the runtime is the mock client and the
+// kill switch is a file of the test
+func newTestAgent(t *testing.T, config Config) (*Agent, *fakeExecutor, *mock.Container) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv(killswitch.Env, path.Join(dir, "kill-switch"))
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	exec.NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	t.Cleanup(func() { exec.NewClientFunc = nil })
+	config.Token, config.JournalFile = testToken, path.Join(dir, "journal.json")
+	a, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := &fakeExecutor{}
+	a.executors = map[string]spec.Executor{exec.GetExecutorKey("cpu", "fullload"): executor}
+	return a, executor, client
+}
+
+// call requests the api of the agent with the token and decodes the response
+func call(t *testing.T, server *httptest.Server, method, uri string, body interface{}) (int, spec.Response) {
+	t.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	request, err := http.NewRequest(method, server.URL+uri, &reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var response spec.Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("decode the response of %s %s failed, %v", method, uri, err)
+	}
+	return resp.StatusCode, response
+}
+
+// eventually polls the condition until it's true or the timeout elapses
+func eventually(t *testing.T, timeout time.Duration, condition func() bool) bool {
+	t.Helper()
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return condition()
+}
+
+func TestExperimentsApi(t *testing.T) {
+	a, executor, _ := newTestAgent(t, Config{})
+	server := httptest.NewServer(a.server.Handler)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/v1/experiments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the request without token refused, got %d", resp.StatusCode)
+	}
+
+	status, response := call(t, server, http.MethodPost, "/v1/experiments",
+		ExperimentRequest{Target: "cpu", Action: "fullload", Flags: map[string]string{"container-id": "c1"}})
+	uid, _ := response.Result.(string)
+	if status != http.StatusOK || !response.Success || uid == "" {
+		t.Fatalf("create failed, %d %+v", status, response)
+	}
+	if created, _ := executor.calls(); len(created) != 1 || created[0] != uid {
+		t.Errorf("expected experiment %s created, got %v", uid, created)
+	}
+	if _, response := call(t, server, http.MethodPost, "/v1/experiments",
+		ExperimentRequest{Target: "cpu", Action: "burn"}); response.Success || response.Code != spec.ActionNotSupport.Code {
+		t.Errorf("expected the unknown action refused, got %+v", response)
+	}
+
+	_, response = call(t, server, http.MethodGet, "/v1/experiments", nil)
+	if records, ok := response.Result.([]interface{}); !response.Success || !ok || len(records) != 1 {
+		t.Errorf("expected the experiment listed, got %+v", response)
+	}
+	if record, ok := a.journal.Get(uid); !ok || record.Status != journal.StatusSuccess ||
+		record.Flags["container-id"] != "c1" {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	if status, response := call(t, server, http.MethodDelete, "/v1/experiments/"+uid, nil); status != http.StatusOK ||
+		!response.Success {
+		t.Fatalf("destroy failed, %d %+v", status, response)
+	}
+	if _, destroyed := executor.calls(); len(destroyed) != 1 || destroyed[0] != uid {
+		t.Errorf("expected experiment %s destroyed, got %v", uid, destroyed)
+	}
+	if record, _ := a.journal.Get(uid); record.Status != journal.StatusDestroyed {
+		t.Errorf("expected the experiment destroyed, got %s", record.Status)
+	}
+	// the destroyed experiment is destroyed once
+	call(t, server, http.MethodDelete, "/v1/experiments/"+uid, nil)
+	if _, destroyed := executor.calls(); len(destroyed) != 1 {
+		t.Errorf("expected the experiment destroyed once, got %v", destroyed)
+	}
+	if status, _ := call(t, server, http.MethodGet, "/v1/experiments/unknown", nil); status != http.StatusNotFound {
+		t.Errorf("expected the unknown experiment not found, got %d", status)
+	}
+}
+
+func TestHealthzReadyz(t *testing.T) {
+	a, _, client := newTestAgent(t, Config{MaxCleanupBacklog: 1})
+	server := httptest.NewServer(a.server.Handler)
+	defer server.Close()
+	check := func(uri string) (int, HealthStatus) {
+		t.Helper()
+		// the probes are served without the token
+		resp, err := server.Client().Get(server.URL + uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var status HealthStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, status
+	}
+	if code, status := check("/healthz"); code != http.StatusOK || status.Status != checkOK {
+		t.Errorf("expected healthy, got %d %+v", code, status)
+	}
+	if code, status := check("/readyz"); code != http.StatusOK || status.Checks["runtime"] != checkOK ||
+		status.Checks["cleanupBacklog"] != "0" {
+		t.Errorf("expected ready, got %d %+v", code, status)
+	}
+
+	client.ListContainersFunc = func(ctx context.Context) ([]container.ContainerInfo, error, int32) {
+		return nil, errors.New("connection refused"), spec.ContainerExecFailed.Code
+	}
+	if code, status := check("/readyz"); code != http.StatusServiceUnavailable || status.Checks["runtime"] != "connection refused" {
+		t.Errorf("expected not ready by the runtime, got %d %+v", code, status)
+	}
+	// the agent is still alive without the runtime
+	if code, _ := check("/healthz"); code != http.StatusOK {
+		t.Errorf("expected healthy without the runtime, got %d", code)
+	}
+	client.ListContainersFunc = nil
+
+	for _, uid := range []string{"uid1", "uid2"} {
+		if err := a.journal.Put(journal.Record{Uid: uid, Target: "cpu", Action: "fullload",
+			Status: journal.StatusDestroyFailed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if code, status := check("/readyz"); code != http.StatusServiceUnavailable || status.Checks["cleanupBacklog"] == "0" {
+		t.Errorf("expected not ready by the cleanup backlog, got %d %+v", code, status)
+	}
+
+	a.execStart.Store(time.Now().Add(-2 * a.config.MaxExecDuration))
+	if code, status := check("/healthz"); code != http.StatusServiceUnavailable || status.Checks["execution"] == checkOK {
+		t.Errorf("expected unhealthy by the wedged execution, got %d %+v", code, status)
+	}
+}
+
+func TestShutdownRevert(t *testing.T) {
+	for _, revert := range []bool{true, false} {
+		a, executor, _ := newTestAgent(t, Config{RevertOnShutdown: revert, ShutdownTimeout: time.Second})
+		response := a.Create(context.Background(), "uid1", ExperimentRequest{Target: "cpu", Action: "fullload"})
+		if !response.Success {
+			t.Fatalf("create failed, %+v", response)
+		}
+		if err := a.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+		_, destroyed := executor.calls()
+		record, _ := a.journal.Get("uid1")
+		if revert && (len(destroyed) != 1 || record.Status != journal.StatusDestroyed) {
+			t.Errorf("expected the experiment reverted on shutdown, got %v, %s", destroyed, record.Status)
+		}
+		if !revert && (len(destroyed) != 0 || record.Status != journal.StatusSuccess) {
+			t.Errorf("expected the experiment kept for the next agent, got %v, %s", destroyed, record.Status)
+		}
+		// the experiments are refused while the agent is stopping
+		server := httptest.NewServer(a.server.Handler)
+		status, _ := call(t, server, http.MethodPost, "/v1/experiments", ExperimentRequest{Target: "cpu", Action: "fullload"})
+		server.Close()
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("expected the create refused on shutdown, got %d", status)
+		}
+	}
+}
+
+func TestKillSwitchRevert(t *testing.T) {
+	a, executor, _ := newTestAgent(t, Config{})
+	server := httptest.NewServer(a.server.Handler)
+	defer server.Close()
+	defer a.Shutdown()
+	if response := a.Create(context.Background(), "uid1", ExperimentRequest{Target: "cpu", Action: "fullload"}); !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	go a.watchKillSwitch(context.Background())
+
+	status, response := call(t, server, http.MethodPost, "/v1/kill-switch", KillSwitchRequest{Reason: "incident"})
+	if state, ok := response.Result.(map[string]interface{}); status != http.StatusOK || !ok || state["engaged"] != true {
+		t.Fatalf("engage failed, %d %+v", status, response)
+	}
+	if !eventually(t, 5*time.Second, func() bool {
+		record, _ := a.journal.Get("uid1")
+		return record.Status == journal.StatusDestroyed
+	}) {
+		_, destroyed := executor.calls()
+		t.Fatalf("expected the experiment reverted by the kill switch, got %v", destroyed)
+	}
+	// the experiments are refused until the switch is released
+	if response := a.Create(context.Background(), "uid2", ExperimentRequest{Target: "cpu", Action: "fullload"}); response.Success {
+		t.Errorf("expected the create refused by the kill switch, got %+v", response)
+	}
+	if status, response := call(t, server, http.MethodDelete, "/v1/kill-switch", nil); status != http.StatusOK || !response.Success {
+		t.Fatalf("release failed, %d %+v", status, response)
+	}
+	if _, err := os.Stat(killswitch.File()); !os.IsNotExist(err) {
+		t.Errorf("expected the switch file removed, got %v", err)
+	}
+	if response := a.Create(context.Background(), "uid2", ExperimentRequest{Target: "cpu", Action: "fullload"}); !response.Success {
+		t.Errorf("expected the create accepted after the release, got %+v", response)
+	}
+}
+
+// TestKillSwitchRetry fails the revert, it is retried on every check while the switch is engaged and the failure is
+// reported in the state until the revert succeeds
+func TestKillSwitchRetry(t *testing.T) {
+	a, executor, _ := newTestAgent(t, Config{})
+	server := httptest.NewServer(a.server.Handler)
+	defer server.Close()
+	defer a.Shutdown()
+	if response := a.Create(context.Background(), "uid1", ExperimentRequest{Target: "cpu", Action: "fullload"}); !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	executor.setFailDestroy(true)
+	go a.watchKillSwitch(context.Background())
+	if status, response := call(t, server, http.MethodPost, "/v1/kill-switch", nil); status != http.StatusOK || !response.Success {
+		t.Fatalf("engage failed, %d %+v", status, response)
+	}
+	failures := func() []interface{} {
+		_, response := call(t, server, http.MethodGet, "/v1/kill-switch", nil)
+		state, _ := response.Result.(map[string]interface{})
+		failures, _ := state["failures"].([]interface{})
+		return failures
+	}
+	if !eventually(t, 5*time.Second, func() bool {
+		failures := failures()
+		if len(failures) != 1 {
+			return false
+		}
+		failure := failures[0].(map[string]interface{})
+		return failure["uid"] == "uid1" && failure["error"] != "" && failure["attempts"].(float64) >= 2
+	}) {
+		t.Fatalf("expected the failed revert retried and reported, got %v", failures())
+	}
+	if record, _ := a.journal.Get("uid1"); record.Status != journal.StatusDestroyFailed {
+		t.Errorf("expected the experiment destroy failed, got %s", record.Status)
+	}
+	executor.setFailDestroy(false)
+	if !eventually(t, 5*time.Second, func() bool {
+		record, _ := a.journal.Get("uid1")
+		return record.Status == journal.StatusDestroyed
+	}) {
+		t.Fatalf("expected the experiment reverted by the retry")
+	}
+	if !eventually(t, 5*time.Second, func() bool { return len(failures()) == 0 }) {
+		t.Errorf("expected no failures after the retry, got %v", failures())
+	}
+}
+
+func TestProfileExpiry(t *testing.T) {
+	profiles := path.Join(t.TempDir(), "profiles.yaml")
+	if err := os.WriteFile(profiles, []byte(`profiles:
+- name: short-burn
+  target: cpu
+  action: fullload
+  flags: {cpu-percent: "50"}
+  ttl: 100ms
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, executor, _ := newTestAgent(t, Config{ProfilesFile: profiles})
+	defer a.Shutdown()
+	response := a.Create(context.Background(), "uid1", ExperimentRequest{Profile: "short-burn",
+		Flags: map[string]string{"container-id": "c1"}})
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	record, _ := a.journal.Get("uid1")
+	if record.ExpireTime == nil || record.Profile != "short-burn" || record.Flags["cpu-percent"] != "50" ||
+		record.Flags["container-id"] != "c1" {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	if !eventually(t, 5*time.Second, func() bool {
+		record, _ := a.journal.Get("uid1")
+		return record.Status == journal.StatusDestroyed
+	}) {
+		t.Fatal("expected the experiment destroyed by the ttl of the profile")
+	}
+	if _, destroyed := executor.calls(); len(destroyed) != 1 || destroyed[0] != "uid1" {
+		t.Errorf("expected the experiment destroyed once, got %v", destroyed)
+	}
+	if response := a.Create(context.Background(), "uid2", ExperimentRequest{Profile: "unknown"}); response.Success ||
+		response.Code != spec.ParameterIllegal.Code {
+		t.Errorf("expected the unknown profile refused, got %+v", response)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+)
+
+// collectDeployments removes the toolkit files copied into the containers for the experiments of the journal which
+// are not running anymore, such as failed to create or destroyed while the agent was down. The experiments unknown
+// to the journal are created by the blade commands and kept.
+func (a *Agent) collectDeployments(ctx context.Context) {
+	// the deployments are changed by the executors too
+	a.execMu.Lock()
+	defer a.execMu.Unlock()
+	deployments := exec.OpenDeployments()
+	list, err := deployments.List()
+	if err != nil {
+		log.Warnf(ctx, "list the deployments failed, %v", err)
+		return
+	}
+	running := make(map[string]bool)
+	for _, record := range a.runningRecords() {
+		running[record.Uid] = true
+	}
+	for _, deployment := range list {
+		var released journal.Deployment
+		var ok bool
+		for _, uid := range deployment.Uids {
+			if _, known := a.journal.Get(uid); !known || running[uid] {
+				continue
+			}
+			if released, ok, err = deployments.Release(deployment.ContainerId, uid); err != nil {
+				log.Warnf(ctx, "release experiment %s from the deployment failed, %v", uid, err)
+				break
+			}
+		}
+		if !ok || released.Retain {
+			continue
+		}
+		if err := exec.RemoveDeployment(ctx, released); err != nil {
+			log.Warnf(ctx, "remove the chaosblade tool of container %s failed, %v", released.ContainerId, err)
+		}
+	}
+}
+
+// handleBundles returns the statistics of the chaosblade releases staged on the node for troubleshooting
+func (a *Agent) handleBundles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	stats, err := exec.BundleCache().Stats()
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, spec.ReturnFail(spec.FileCantReadOrOpen, err.Error()))
+		return
+	}
+	writeResponse(w, http.StatusOK, spec.ReturnSuccess(stats))
+}
+
+// handleSnapshots returns the network snapshots of the experiments not verified yet, and the residue found after the
+// destroy, so the operators can tell the network state is restored
+func (a *Agent) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	snapshots, err := exec.OpenSnapshots().List()
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, spec.ReturnFail(spec.FileCantReadOrOpen, err.Error()))
+		return
+	}
+	writeResponse(w, http.StatusOK, spec.ReturnSuccess(snapshots))
+}
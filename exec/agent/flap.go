@@ -0,0 +1,157 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/event"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/scheduler"
+)
+
+// flapTargets are the network and resource targets whose faults can be reverted and injected again cheaply, the
+// faults such as the container removal or the file deletion can't be flapped
+var flapTargets = map[string]bool{
+	"network": true,
+	"cpu":     true,
+	"mem":     true,
+	"disk":    true,
+	"stress":  true,
+	"steal":   true,
+	"gpu":     true,
+	"device":  true,
+}
+
+type runningFlap struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// parseFlap validates the duty cycle of the flapping experiment
+func parseFlap(target string, flap *journal.Flap) (scheduler.DutyCycle, error) {
+	var cycle scheduler.DutyCycle
+	if !flapTargets[target] {
+		return cycle, fmt.Errorf("the %s experiments can't flap", target)
+	}
+	var err error
+	if cycle.On, err = time.ParseDuration(flap.On); err != nil {
+		return cycle, fmt.Errorf("illegal on `%s`, %v", flap.On, err)
+	}
+	if cycle.Off, err = time.ParseDuration(flap.Off); err != nil {
+		return cycle, fmt.Errorf("illegal off `%s`, %v", flap.Off, err)
+	}
+	if flap.Duration != "" {
+		if cycle.Duration, err = time.ParseDuration(flap.Duration); err != nil {
+			return cycle, fmt.Errorf("illegal duration `%s`, %v", flap.Duration, err)
+		}
+	}
+	return cycle, cycle.Validate()
+}
+
+// startFlap flaps the experiment injected in background until the duration elapses or it's stopped
+func (a *Agent) startFlap(record journal.Record, executor spec.Executor, cycle scheduler.DutyCycle) {
+	ctx, cancel := context.WithCancel(context.Background())
+	flap := &runningFlap{cancel: cancel, done: make(chan struct{})}
+	a.flapsMu.Lock()
+	a.flaps[record.Uid] = flap
+	a.flapsMu.Unlock()
+	go a.runFlap(ctx, flap, record, executor, cycle)
+}
+
+// stopFlap stops flapping the experiment and waits for it destroyed, false if it isn't flapping
+func (a *Agent) stopFlap(uid string) bool {
+	a.flapsMu.Lock()
+	flap, ok := a.flaps[uid]
+	a.flapsMu.Unlock()
+	if ok {
+		flap.cancel()
+		<-flap.done
+	}
+	return ok
+}
+
+// stopFlaps stops all the flapping experiments and waits for them destroyed
+func (a *Agent) stopFlaps() {
+	a.flapsMu.Lock()
+	flaps := make([]*runningFlap, 0, len(a.flaps))
+	for _, flap := range a.flaps {
+		flaps = append(flaps, flap)
+	}
+	a.flapsMu.Unlock()
+	for _, flap := range flaps {
+		flap.cancel()
+		<-flap.done
+	}
+}
+
+// runFlap reverts and injects the experiment again by the executor, the journal keeps it running in the off periods.
+// It's destroyed at the end whichever the period is, the experiment reverted by the last off period isn't executed
+// again but recorded as destroyed
+func (a *Agent) runFlap(ctx context.Context, flap *runningFlap, record journal.Record, executor spec.Executor,
+	cycle scheduler.DutyCycle) {
+	defer close(flap.done)
+	defer func() {
+		a.flapsMu.Lock()
+		delete(a.flaps, record.Uid)
+		a.flapsMu.Unlock()
+	}()
+	uid := record.Uid
+	toggle := func(ctx context.Context, destroy bool) error {
+		if destroy {
+			ctx = spec.SetDestroyFlag(ctx, uid)
+		}
+		if response := a.execute(ctx, uid, executor, record); !response.Success {
+			return errors.New(response.Err)
+		}
+		log.Debugf(ctx, "experiment %s flaps, destroy: %t", uid, destroy)
+		return nil
+	}
+	injected, err := scheduler.Toggle(ctx, cycle,
+		func(ctx context.Context) error { return toggle(ctx, false) },
+		func(ctx context.Context) error { return toggle(ctx, true) })
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Warnf(ctx, "flap experiment %s failed, %v", uid, err)
+	}
+	ctx = context.Background()
+	if current, ok := a.journal.Get(uid); ok {
+		record = current
+	}
+	if injected {
+		if response := a.Destroy(ctx, record); !response.Success {
+			log.Warnf(ctx, "destroy the flapping experiment %s failed, %s", uid, response.Err)
+		}
+		return
+	}
+	// the fault is reverted by the off period, or the injection failed and was rolled back
+	response := spec.ReturnSuccess(uid)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		response = spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("inject the flapping experiment again failed, %v", err))
+	} else {
+		a.probeAfter(ctx, &record)
+	}
+	defer a.writeReport(ctx)
+	a.emit(event.TypeReverted, record, response)
+	a.notify(event.PhaseDestroy, record, response)
+	a.updateStatus(ctx, uid, response, journal.StatusDestroyed)
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+)
+
+const (
+	DefaultMaxCleanupBacklog = 10
+	DefaultMaxExecDuration   = 10 * time.Minute
+
+	runtimeCheckTimeout = 3 * time.Second
+)
+
+const (
+	checkOK   = "ok"
+	checkFail = "failed"
+)
+
+// HealthStatus is the body of the health and readiness endpoints
+type HealthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (h *HealthStatus) fail(name, reason string) {
+	h.Status = checkFail
+	h.Checks[name] = reason
+}
+
+// Healthz checks the agent is not wedged, that is the journal is readable and no execution is stuck
+func (a *Agent) Healthz() HealthStatus {
+	status := HealthStatus{Status: checkOK, Checks: map[string]string{"journal": checkOK, "execution": checkOK}}
+	if err := a.journal.Verify(); err != nil {
+		status.fail("journal", err.Error())
+	}
+	if start, ok := a.execStart.Load().(time.Time); ok && !start.IsZero() {
+		if elapsed := time.Since(start); elapsed > a.config.MaxExecDuration {
+			status.fail("execution", fmt.Sprintf("an execution has been running for %s", elapsed.Truncate(time.Second)))
+		}
+	}
+	return status
+}
+
+// Readyz checks the agent can serve experiments, that is the runtime is connected and the experiments failed
+// to destroy are under the limit
+func (a *Agent) Readyz(ctx context.Context) HealthStatus {
+	status := a.Healthz()
+	if a.isStopping() {
+		status.fail("agent", "shutting down")
+	}
+
+	status.Checks["runtime"] = checkOK
+	if err := a.checkRuntime(ctx); err != nil {
+		status.fail("runtime", err.Error())
+	}
+
+	backlog := 0
+	for _, record := range a.journal.List() {
+		if record.Status == journal.StatusDestroyFailed {
+			backlog++
+		}
+	}
+	status.Checks["cleanupBacklog"] = fmt.Sprintf("%d", backlog)
+	if backlog > a.config.MaxCleanupBacklog {
+		status.fail("cleanupBacklog", fmt.Sprintf("%d experiments failed to destroy, more than %d", backlog, a.config.MaxCleanupBacklog))
+	}
+	return status
+}
+
+func (a *Agent) checkRuntime(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, runtimeCheckTimeout)
+	defer cancel()
+	client, err := exec.GetClientByRuntime(&spec.ExpModel{
+		ActionFlags: map[string]string{
+			exec.ContainerRuntime.Name:   a.config.Runtime,
+			exec.EndpointFlag.Name:       a.config.Endpoint,
+			exec.ContainerNamespace.Name: a.config.Namespace,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err, _ = client.ListContainers(ctx)
+	return err
+}
+
+func (a *Agent) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthStatus(w, a.Healthz())
+}
+
+func (a *Agent) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	writeHealthStatus(w, a.Readyz(r.Context()))
+}
+
+func writeHealthStatus(w http.ResponseWriter, status HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	if status.Status == checkOK {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
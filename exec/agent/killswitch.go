@@ -0,0 +1,161 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/killswitch"
+)
+
+// killSwitchInterval is how often the file of the kill switch is checked
+const killSwitchInterval = time.Second
+
+// KillSwitchRequest is the body engaging the kill switch
+type KillSwitchRequest struct {
+	Reason string `json:"reason"`
+}
+
+// KillSwitchState is the state of the kill switch with the experiments failed to revert while it is engaged
+type KillSwitchState struct {
+	killswitch.State
+	Failures []KillSwitchFailure `json:"failures,omitempty"`
+}
+
+// KillSwitchFailure is an experiment the kill switch failed to revert, it is retried on every check until reverted
+type KillSwitchFailure struct {
+	Uid         string    `json:"uid"`
+	Target      string    `json:"target"`
+	Action      string    `json:"action"`
+	Error       string    `json:"error"`
+	Attempts    int       `json:"attempts"`
+	LastAttempt time.Time `json:"lastAttempt"`
+}
+
+// watchKillSwitch reverts the running experiments as soon as the kill switch is engaged, by the file or the api, and
+// retries the reverts failed on every check while it is engaged, until the agent is shut down
+func (a *Agent) watchKillSwitch(ctx context.Context) {
+	ticker := time.NewTicker(killSwitchInterval)
+	defer ticker.Stop()
+	engaged := false
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+		state := killswitch.Check()
+		if !state.Engaged {
+			if engaged {
+				a.setKillFailures(nil)
+			}
+			engaged = false
+			continue
+		}
+		if !engaged {
+			log.Warnf(ctx, "the kill switch %s is engaged, %s, revert all the experiments", killswitch.File(), state.Reason)
+			a.stopSchedules()
+			a.stopFlaps()
+		}
+		engaged = true
+		a.revertAll(ctx)
+	}
+}
+
+// revertAll destroys the running experiments, including the ones whose destroy failed before, the failures are
+// kept for the state of the kill switch
+func (a *Agent) revertAll(ctx context.Context) {
+	previous := a.killFailures()
+	attempts := make(map[string]int, len(previous))
+	for _, failure := range previous {
+		attempts[failure.Uid] = failure.Attempts
+	}
+	failures := make([]KillSwitchFailure, 0)
+	for _, record := range a.runningRecords() {
+		response := a.Destroy(ctx, record)
+		if response.Success {
+			if attempts[record.Uid] > 0 {
+				log.Infof(ctx, "experiment %s is reverted by the kill switch after %d retries", record.Uid, attempts[record.Uid])
+			}
+			continue
+		}
+		if attempts[record.Uid] == 0 {
+			log.Warnf(ctx, "revert experiment %s by the kill switch failed, retry while engaged, %s", record.Uid, response.Err)
+		}
+		failures = append(failures, KillSwitchFailure{
+			Uid:         record.Uid,
+			Target:      record.Target,
+			Action:      record.Action,
+			Error:       response.Err,
+			Attempts:    attempts[record.Uid] + 1,
+			LastAttempt: time.Now(),
+		})
+	}
+	a.setKillFailures(failures)
+}
+
+func (a *Agent) killFailures() []KillSwitchFailure {
+	a.killMu.Lock()
+	defer a.killMu.Unlock()
+	return append([]KillSwitchFailure{}, a.killFails...)
+}
+
+func (a *Agent) setKillFailures(failures []KillSwitchFailure) {
+	a.killMu.Lock()
+	defer a.killMu.Unlock()
+	a.killFails = failures
+}
+
+// handleKillSwitch returns the state of the kill switch and the reverts failed on get, engages it on post and releases it on delete, the
+// running experiments are reverted by the watch of the switch
+func (a *Agent) handleKillSwitch(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		// the body is optional
+		var request KillSwitchRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+			writeResponse(w, http.StatusBadRequest, spec.ResponseFailWithFlags(spec.ParameterRequestFailed))
+			return
+		}
+		if err := killswitch.Engage(request.Reason); err != nil {
+			writeResponse(w, http.StatusInternalServerError, spec.ReturnFail(spec.FileCantReadOrOpen, err.Error()))
+			return
+		}
+	case http.MethodDelete:
+		if err := killswitch.Release(); err != nil {
+			writeResponse(w, http.StatusInternalServerError, spec.ReturnFail(spec.FileCantReadOrOpen, err.Error()))
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	state := KillSwitchState{State: killswitch.Check()}
+	if state.Engaged {
+		state.Failures = a.killFailures()
+	}
+	writeResponse(w, http.StatusOK, spec.ReturnSuccess(state))
+}
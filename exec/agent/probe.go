@@ -0,0 +1,89 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/probe"
+)
+
+// probeTarget resolves the experiment container for the command, the stats and the path http probes
+func (a *Agent) probeTarget(ctx context.Context, record journal.Record) probe.Target {
+	if !probe.NeedContainer(record.Probes) {
+		return probe.Target{}
+	}
+	client, err := exec.GetClientByRuntime(&spec.ExpModel{ActionFlags: record.Flags})
+	if err != nil {
+		log.Warnf(ctx, "get the runtime client for the probes of experiment %s failed, %v", record.Uid, err)
+		return probe.Target{}
+	}
+	containerInfo, response := exec.GetContainer(ctx, client, record.Uid,
+		record.Flags[exec.ContainerIdFlag.Name], record.Flags[exec.ContainerNameFlag.Name], nil)
+	if !response.Success {
+		log.Warnf(ctx, "get the container for the probes of experiment %s failed, %s", record.Uid, response.Err)
+		return probe.Target{Client: client}
+	}
+	return probe.Target{
+		Client:      client,
+		ContainerId: containerInfo.ContainerId,
+		IP:          containerInfo.PrimaryIP(),
+		Ports:       containerInfo.Ports,
+	}
+}
+
+// probeBefore evaluates the probes before the injection, it returns the failed response if the target is
+// already unhealthy, and the record is marked as error
+func (a *Agent) probeBefore(ctx context.Context, record *journal.Record) *spec.Response {
+	if len(record.Probes) == 0 {
+		return nil
+	}
+	results, healthy := probe.Evaluate(ctx, a.probeTarget(ctx, *record), record.Probes)
+	record.ProbeReport = &probe.Report{Before: results}
+	if healthy {
+		return nil
+	}
+	response := spec.ResponseFailWithFlags(spec.UnexpectedStatus, "healthy", "unhealthy")
+	record.Status, record.Error = journal.StatusError, response.Err
+	return response
+}
+
+// probeAfter waits for the probes healthy after the revert and records the recovery time
+func (a *Agent) probeAfter(ctx context.Context, record *journal.Record) {
+	if len(record.Probes) == 0 {
+		return
+	}
+	report := &probe.Report{}
+	if record.ProbeReport != nil {
+		*report = *record.ProbeReport
+	}
+	results, recovery, recovered := probe.WaitRecovery(ctx, a.probeTarget(ctx, *record), record.Probes, probe.DefaultRecoveryTimeout)
+	report.After, report.Recovered = results, recovered
+	if recovered {
+		report.RecoveryTime = recovery.Truncate(time.Millisecond).String()
+	}
+	record.ProbeReport = report
+	if err := a.journal.SetProbeReport(record.Uid, report); err != nil {
+		log.Warnf(ctx, "update the probe report of experiment %s in journal failed, %v", record.Uid, err)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+)
+
+// applyProfile resolves the experiment of the request by its profile and returns the ttl of the profile, the faults
+// of a scenario carry their own flags, so they can't use a profile
+func (a *Agent) applyProfile(request *ExperimentRequest) (time.Duration, *spec.Response) {
+	if request.Profile == "" {
+		return 0, nil
+	}
+	profile, ok := a.profiles[request.Profile]
+	if !ok {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, "profile", request.Profile, "the profile is not found")
+	}
+	if len(request.Faults) > 0 {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, "profile", request.Profile,
+			"the faults of a scenario can't use a profile")
+	}
+	target, action, flags, err := profile.Apply(request.Target, request.Action, request.Flags)
+	if err != nil {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, "profile", request.Profile, err)
+	}
+	request.Target, request.Action, request.Flags = target, action, flags
+	// the ttl is validated by the loading
+	ttl, _ := profile.Lifetime()
+	return ttl, nil
+}
+
+// expireAfter destroys the experiment when the ttl of its profile elapses
+func (a *Agent) expireAfter(uid string, ttl time.Duration) {
+	a.expiriesMu.Lock()
+	defer a.expiriesMu.Unlock()
+	if timer, ok := a.expiries[uid]; ok {
+		timer.Stop()
+	}
+	a.expiries[uid] = time.AfterFunc(ttl, func() { a.expire(uid) })
+}
+
+// cancelExpiry stops the expiry of the experiment destroyed before its ttl elapses
+func (a *Agent) cancelExpiry(uid string) {
+	a.expiriesMu.Lock()
+	defer a.expiriesMu.Unlock()
+	if timer, ok := a.expiries[uid]; ok {
+		timer.Stop()
+		delete(a.expiries, uid)
+	}
+}
+
+// stopExpiries stops all the expiries on shutdown, the expire time is kept in the journal for the next agent
+func (a *Agent) stopExpiries() {
+	a.expiriesMu.Lock()
+	defer a.expiriesMu.Unlock()
+	for uid, timer := range a.expiries {
+		timer.Stop()
+		delete(a.expiries, uid)
+	}
+}
+
+// resumeExpiries arms the expiries of the running experiments taken over from the journal, those expired while no
+// agent was running are destroyed at once
+func (a *Agent) resumeExpiries() {
+	for _, record := range a.runningRecords() {
+		if record.ExpireTime != nil && record.Status != journal.StatusDestroyFailed {
+			a.expireAfter(record.Uid, time.Until(*record.ExpireTime))
+		}
+	}
+}
+
+// expire destroys the experiment whose ttl elapsed, the flapping experiment is destroyed by its flap
+func (a *Agent) expire(uid string) {
+	a.expiriesMu.Lock()
+	delete(a.expiries, uid)
+	a.expiriesMu.Unlock()
+	if a.isStopping() {
+		return
+	}
+	ctx := context.Background()
+	a.stopFlap(uid)
+	record, ok := a.journal.Get(uid)
+	if !ok || record.Status == journal.StatusDestroyed || record.Status == journal.StatusError {
+		return
+	}
+	log.Infof(ctx, "experiment %s expires by the ttl of the profile %s", uid, record.Profile)
+	if response := a.Destroy(ctx, record); !response.Success {
+		log.Warnf(ctx, "destroy the expired experiment %s failed, %s", uid, response.Err)
+	}
+}
+
+// handleProfiles lists the profiles loaded from the profiles file
+func (a *Agent) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeResponse(w, http.StatusOK, spec.ReturnSuccess(a.profiles.List()))
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/report"
+)
+
+// handleReports exports the experiments as json or junit xml, the schedule query filters the runs of the schedule
+func (a *Agent) handleReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = report.FormatJSON
+	}
+	if err := report.ValidateFormat(format); err != nil {
+		writeResponse(w, http.StatusBadRequest, spec.ResponseFailWithFlags(spec.ParameterIllegal, "format", format, err))
+		return
+	}
+	records := a.journal.List()
+	if scheduleId := r.URL.Query().Get("schedule"); scheduleId != "" {
+		filtered := make([]journal.Record, 0)
+		for _, record := range records {
+			if record.ScheduleId == scheduleId {
+				filtered = append(filtered, record)
+			}
+		}
+		records = filtered
+	}
+	if format == report.FormatJUnit {
+		w.Header().Set("Content-Type", "application/xml")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err := report.New(records).Write(w, format); err != nil {
+		log.Warnf(r.Context(), "write report failed, %v", err)
+	}
+}
+
+// writeReport refreshes the report file if configured, the format is decided by the file extension
+func (a *Agent) writeReport(ctx context.Context) {
+	if a.config.ReportFile == "" {
+		return
+	}
+	file := a.config.ReportFile
+	if err := report.New(a.journal.List()).WriteFile(file, report.FormatOf(file)); err != nil {
+		log.Warnf(ctx, "write report %s failed, %v", file, err)
+	}
+}
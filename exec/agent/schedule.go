@@ -0,0 +1,289 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/event"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/probe"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/scheduler"
+)
+
+// The schedule status
+const (
+	ScheduleRunning  = "Running"
+	ScheduleFinished = "Finished"
+	ScheduleStopped  = "Stopped"
+)
+
+// ScheduleRequest is the body of the create schedule api, the experiment is re-run by the cron expression
+// or the interval, until the times or the end time is reached
+type ScheduleRequest struct {
+	Experiment ExperimentRequest `json:"experiment"`
+	Cron       string            `json:"cron,omitempty"`
+	Interval   string            `json:"interval,omitempty"`
+	Jitter     string            `json:"jitter,omitempty"`
+	// Duration is how long every run keeps the experiment injected before destroying it
+	Duration string    `json:"duration"`
+	Times    int       `json:"times,omitempty"`
+	EndTime  time.Time `json:"endTime,omitempty"`
+}
+
+// ScheduleStatus is the state of a schedule, the runs are the experiment uids in the journal
+type ScheduleStatus struct {
+	Id         string          `json:"id"`
+	Request    ScheduleRequest `json:"request"`
+	Status     string          `json:"status"`
+	Runs       []string        `json:"runs"`
+	CreateTime time.Time       `json:"createTime"`
+}
+
+type runningSchedule struct {
+	mu     sync.Mutex
+	status ScheduleStatus
+	cancel context.CancelFunc
+}
+
+func (s *runningSchedule) get() ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Runs = append([]string{}, s.status.Runs...)
+	return status
+}
+
+func (s *runningSchedule) addRun(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Runs = append(s.status.Runs, uid)
+}
+
+func (s *runningSchedule) setStatus(status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Status = status
+}
+
+func (request ScheduleRequest) parse() (scheduler.Schedule, time.Duration, error) {
+	schedule := scheduler.Schedule{
+		Cron:  request.Cron,
+		Times: request.Times,
+		End:   request.EndTime,
+	}
+	var err error
+	if request.Interval != "" {
+		if schedule.Interval, err = time.ParseDuration(request.Interval); err != nil {
+			return schedule, 0, fmt.Errorf("illegal interval `%s`, %v", request.Interval, err)
+		}
+	}
+	if request.Jitter != "" {
+		if schedule.Jitter, err = time.ParseDuration(request.Jitter); err != nil {
+			return schedule, 0, fmt.Errorf("illegal jitter `%s`, %v", request.Jitter, err)
+		}
+	}
+	duration, err := time.ParseDuration(request.Duration)
+	if err != nil || duration <= 0 {
+		return schedule, 0, fmt.Errorf("illegal duration `%s`, it must be a positive duration", request.Duration)
+	}
+	return schedule, duration, schedule.Validate()
+}
+
+// Schedule validates the request and starts re-running the experiment in background
+func (a *Agent) Schedule(request ScheduleRequest) *spec.Response {
+	// every run is kept injected by the duration of the schedule rather than the ttl of the profile
+	if _, response := a.applyProfile(&request.Experiment); response != nil {
+		return response
+	}
+	if response := a.prepareScenario(&request.Experiment); response != nil {
+		return response
+	}
+	experiment := request.Experiment
+	executor, ok := a.executorOf(experiment.Target, experiment.Action, experiment.Faults)
+	if !ok {
+		return spec.ResponseFailWithFlags(spec.ActionNotSupport, fmt.Sprintf("%s %s", experiment.Target, experiment.Action))
+	}
+	if err := event.ValidateWebhooks(experiment.Webhooks); err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "webhooks", experiment.Webhooks, err)
+	}
+	if err := probe.Validate(experiment.Probes); err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "probes", experiment.Probes, err)
+	}
+	if experiment.Flap != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "flap", experiment.Flap,
+			"the runs of a schedule don't flap, use the interval and the duration instead")
+	}
+	schedule, duration, err := request.parse()
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "schedule", request.Cron+request.Interval, err)
+	}
+	id, err := util.GenerateUid()
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.GenerateUidFailed, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &runningSchedule{
+		status: ScheduleStatus{
+			Id:         id,
+			Request:    request,
+			Status:     ScheduleRunning,
+			Runs:       make([]string, 0),
+			CreateTime: time.Now(),
+		},
+		cancel: cancel,
+	}
+	a.schedulesMu.Lock()
+	a.schedules[id] = s
+	a.schedulesMu.Unlock()
+	a.schedulesWg.Add(1)
+	go a.runSchedule(ctx, s, executor, schedule, duration)
+	return spec.ReturnSuccess(id)
+}
+
+// StopSchedule stops the schedule, the experiment of the current run is destroyed
+func (a *Agent) StopSchedule(id string) bool {
+	a.schedulesMu.Lock()
+	s, ok := a.schedules[id]
+	a.schedulesMu.Unlock()
+	if ok {
+		s.cancel()
+	}
+	return ok
+}
+
+// stopSchedules stops all schedules and waits for the current runs destroyed
+func (a *Agent) stopSchedules() {
+	a.schedulesMu.Lock()
+	for _, s := range a.schedules {
+		s.cancel()
+	}
+	a.schedulesMu.Unlock()
+	a.schedulesWg.Wait()
+}
+
+func (a *Agent) runSchedule(ctx context.Context, s *runningSchedule, executor spec.Executor,
+	schedule scheduler.Schedule, duration time.Duration) {
+	defer a.schedulesWg.Done()
+	defer s.cancel()
+	experiment := s.status.Request.Experiment
+	err := scheduler.Run(ctx, schedule, func(ctx context.Context, run int) {
+		uid, err := util.GenerateUid()
+		if err != nil {
+			log.Warnf(ctx, "generate uid for run %d of schedule %s failed, %v", run, s.status.Id, err)
+			return
+		}
+		s.addRun(uid)
+		response := a.create(ctx, executor, journal.Record{
+			Uid:        uid,
+			Target:     experiment.Target,
+			Action:     experiment.Action,
+			Flags:      experiment.Flags,
+			Webhooks:   experiment.Webhooks,
+			Probes:     experiment.Probes,
+			Faults:     experiment.Faults,
+			Profile:    experiment.Profile,
+			ScheduleId: s.status.Id,
+			Run:        run,
+		})
+		if !response.Success {
+			log.Warnf(ctx, "run %d of schedule %s failed, %s", run, s.status.Id, response.Err)
+			return
+		}
+		timer := time.NewTimer(duration)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		record, ok := a.journal.Get(uid)
+		if !ok {
+			return
+		}
+		// the run is destroyed even if the schedule is stopped
+		if response := a.Destroy(context.Background(), record); !response.Success {
+			log.Warnf(ctx, "destroy run %d of schedule %s failed, %s", run, s.status.Id, response.Err)
+		}
+	})
+	if err != nil {
+		s.setStatus(ScheduleStopped)
+		return
+	}
+	s.setStatus(ScheduleFinished)
+}
+
+// handleSchedules creates a schedule or lists the schedules
+func (a *Agent) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.schedulesMu.Lock()
+		schedules := make([]ScheduleStatus, 0, len(a.schedules))
+		for _, s := range a.schedules {
+			schedules = append(schedules, s.get())
+		}
+		a.schedulesMu.Unlock()
+		sort.Slice(schedules, func(i, k int) bool {
+			return schedules[i].CreateTime.Before(schedules[k].CreateTime)
+		})
+		writeResponse(w, http.StatusOK, spec.ReturnSuccess(schedules))
+	case http.MethodPost:
+		if a.isStopping() {
+			writeResponse(w, http.StatusServiceUnavailable, spec.ReturnFail(spec.ChaosbladeServiceStoped, "the agent is shutting down"))
+			return
+		}
+		var request ScheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeResponse(w, http.StatusBadRequest, spec.ResponseFailWithFlags(spec.ParameterRequestFailed))
+			return
+		}
+		writeResponse(w, http.StatusOK, a.Schedule(request))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSchedule queries or stops the schedule by id
+func (a *Agent) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/schedules/")
+	a.schedulesMu.Lock()
+	s, ok := a.schedules[id]
+	a.schedulesMu.Unlock()
+	if !ok {
+		writeResponse(w, http.StatusNotFound, spec.ResponseFailWithFlags(spec.DataNotFound, id))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeResponse(w, http.StatusOK, spec.ReturnSuccess(s.get()))
+	case http.MethodDelete:
+		a.StopSchedule(id)
+		writeResponse(w, http.StatusOK, spec.ReturnSuccess(id))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/transcript"
+)
+
+// transcriptSuffix is the suffix of the path of the experiment returning its transcript
+const transcriptSuffix = "/transcript"
+
+// handleTranscript returns the commands recorded by the experiment created with the transcript flag, the transcript
+// is kept after the destroy
+func (a *Agent) handleTranscript(w http.ResponseWriter, r *http.Request, uid string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	entries, err := transcript.Load(util.GetProgramPath(), uid)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeResponse(w, http.StatusNotFound, spec.ResponseFailWithFlags(spec.DataNotFound, uid+transcriptSuffix))
+			return
+		}
+		writeResponse(w, http.StatusInternalServerError, spec.ResponseFailWithFlags(spec.FileCantReadOrOpen,
+			uid+transcriptSuffix, err))
+		return
+	}
+	writeResponse(w, http.StatusOK, spec.ReturnSuccess(entries))
+}
@@ -0,0 +1,266 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package batch fans the experiment out to the targets listed by commas in the target flag, such as
+// --container-id c1,c2,c3, every target is injected as an experiment of its own uid and the response carries the
+// result of every target. The strict mode rolls back the targets injected if any target failed, the best-effort mode
+// keeps them and succeeds if any target is injected. The canary mode injects the first target and checks it before the
+// others, the batch is aborted and the canary rolled back if it is unhealthy, the others are injected as the strict
+// mode otherwise.
+package batch
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// The modes of the batch
+const (
+	ModeStrict     = "strict"
+	ModeBestEffort = "best-effort"
+	ModeCanary     = "canary"
+)
+
+// Canary is the check of the canary target
+type Canary struct {
+	Target  string      `json:"target"`
+	Healthy bool        `json:"healthy"`
+	Report  interface{} `json:"report,omitempty"`
+	Err     string      `json:"error,omitempty"`
+}
+
+// CanaryCheck checks the canary target injected, the report is carried by the result, the error aborts the batch
+type CanaryCheck func(ctx context.Context, uid string, model *spec.ExpModel) (interface{}, error)
+
+// Target is the result of the experiment of a target
+type Target struct {
+	Target  string      `json:"target"`
+	Uid     string      `json:"uid"`
+	Success bool        `json:"success"`
+	Code    int32       `json:"code,omitempty"`
+	Err     string      `json:"error,omitempty"`
+	Result  interface{} `json:"result,omitempty"`
+	// RolledBack is set if the target injected is destroyed since the others failed in the strict mode
+	RolledBack  bool   `json:"rolledBack,omitempty"`
+	RollbackErr string `json:"rollbackError,omitempty"`
+}
+
+// Result is the result of the batch, the targets are in the order of the flag
+type Result struct {
+	Mode      string   `json:"mode"`
+	Succeeded int      `json:"succeeded"`
+	Failed    int      `json:"failed"`
+	Targets   []Target `json:"targets"`
+	// Canary is set in the canary mode if the canary is injected
+	Canary *Canary `json:"canary,omitempty"`
+}
+
+// Uid returns the uid of the experiment of the target at the index, the destroy of the batch derives the same uids
+// from the same flags
+func Uid(uid string, index int) string {
+	return uid + "-" + strconv.Itoa(index)
+}
+
+// Split returns the targets of the value separated by commas, the blank and duplicated ones are skipped
+func Split(value string) []string {
+	targets := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, target := range strings.Split(value, ",") {
+		target = strings.TrimSpace(target)
+		if target == "" || seen[target] {
+			continue
+		}
+		seen[target] = true
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// Executor fans the experiment out to the targets of the first target flag set, the experiments of a single
+// target are passed to the wrapped executor as they are
+type Executor struct {
+	spec.Executor
+	ModeFlag    string
+	TargetFlags []string
+	// Canary checks the canary target in the canary mode, the canary is healthy if it is injected if nil
+	Canary CanaryCheck
+}
+
+// Wrap returns the executor fanning out the batches
+func Wrap(executor spec.Executor, modeFlag string, targetFlags ...string) *Executor {
+	if e, ok := executor.(*Executor); ok {
+		return e
+	}
+	return &Executor{Executor: executor, ModeFlag: modeFlag, TargetFlags: targetFlags}
+}
+
+func (e *Executor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flag, targets := e.targets(model)
+	if len(targets) < 2 {
+		return e.Executor.Exec(uid, ctx, model)
+	}
+	mode := model.ActionFlags[e.ModeFlag]
+	if mode == "" {
+		mode = ModeStrict
+	}
+	if mode != ModeStrict && mode != ModeBestEffort && mode != ModeCanary {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, e.ModeFlag, mode,
+			fmt.Sprintf("it must be %s, %s or %s", ModeStrict, ModeBestEffort, ModeCanary))
+	}
+	if suid, isDestroy := spec.IsDestroy(ctx); isDestroy {
+		if suid != "" {
+			uid = suid
+		}
+		return e.destroy(uid, ctx, model, flag, targets, mode)
+	}
+	result := Result{Mode: mode, Targets: make([]Target, 0, len(targets))}
+	start := 0
+	if mode == ModeCanary {
+		if response := e.canary(uid, ctx, model, flag, targets[0], &result); response != nil {
+			return response
+		}
+		start = 1
+	}
+	var failure *Target
+	for i := start; i < len(targets); i++ {
+		target, targetUid := targets[i], Uid(uid, i)
+		response := e.Executor.Exec(targetUid, ctx, targetModel(model, flag, target))
+		result.add(target, targetUid, response)
+		if !response.Success && failure == nil {
+			failure = &result.Targets[len(result.Targets)-1]
+		}
+	}
+	if failure == nil {
+		return spec.ReturnSuccess(result)
+	}
+	if mode == ModeBestEffort && result.Succeeded > 0 {
+		return spec.ReturnSuccess(result)
+	}
+	message := fmt.Sprintf("%d of %d targets failed, the first is %s, %s", result.Failed, len(targets),
+		failure.Target, failure.Err)
+	if mode != ModeBestEffort && result.Succeeded > 0 {
+		rolledBack := e.rollback(ctx, model, flag, &result)
+		message += fmt.Sprintf(", %d of the %d targets injected are rolled back", rolledBack, result.Succeeded)
+	}
+	return spec.ResponseFail(failure.Code, message, result)
+}
+
+// canary injects the canary target and checks it, the failed response is returned if the canary is not injected or
+// unhealthy, the canary injected is rolled back then
+func (e *Executor) canary(uid string, ctx context.Context, model *spec.ExpModel, flag, target string,
+	result *Result) *spec.Response {
+	targetUid, canaryModel := Uid(uid, 0), targetModel(model, flag, target)
+	response := e.Executor.Exec(targetUid, ctx, canaryModel)
+	result.add(target, targetUid, response)
+	if !response.Success {
+		return spec.ResponseFail(response.Code, fmt.Sprintf("the canary %s failed, %s, the others are not injected",
+			target, response.Err), *result)
+	}
+	canary := &Canary{Target: target, Healthy: true}
+	result.Canary = canary
+	if e.Canary == nil {
+		return nil
+	}
+	report, err := e.Canary(ctx, targetUid, canaryModel)
+	canary.Report = report
+	if err == nil {
+		return nil
+	}
+	canary.Healthy, canary.Err = false, err.Error()
+	message := fmt.Sprintf("the canary %s is unhealthy, %v, the others are not injected", target, err)
+	if e.rollback(ctx, model, flag, result) > 0 {
+		message += ", the canary is rolled back"
+	} else {
+		message += ", the rollback of the canary failed"
+	}
+	return spec.ResponseFail(spec.UnexpectedStatus.Code, message, *result)
+}
+
+// destroy destroys the experiments of all targets, the failures do not stop the others
+func (e *Executor) destroy(uid string, ctx context.Context, model *spec.ExpModel, flag string, targets []string,
+	mode string) *spec.Response {
+	result := Result{Mode: mode, Targets: make([]Target, 0, len(targets))}
+	var failure *Target
+	for i, target := range targets {
+		targetUid := Uid(uid, i)
+		response := e.Executor.Exec(targetUid, spec.SetDestroyFlag(ctx, targetUid), targetModel(model, flag, target))
+		result.add(target, targetUid, response)
+		if !response.Success && failure == nil {
+			failure = &result.Targets[len(result.Targets)-1]
+		}
+	}
+	if failure == nil {
+		return spec.ReturnSuccess(result)
+	}
+	return spec.ResponseFail(failure.Code, fmt.Sprintf("%d of %d targets failed to destroy, the first is %s, %s",
+		result.Failed, len(targets), failure.Target, failure.Err), result)
+}
+
+// rollback destroys the targets injected, it returns the count of the targets rolled back
+func (e *Executor) rollback(ctx context.Context, model *spec.ExpModel, flag string, result *Result) int {
+	rolledBack := 0
+	for i := range result.Targets {
+		target := &result.Targets[i]
+		if !target.Success {
+			continue
+		}
+		response := e.Executor.Exec(target.Uid, spec.SetDestroyFlag(ctx, target.Uid),
+			targetModel(model, flag, target.Target))
+		target.RolledBack = response.Success
+		if response.Success {
+			rolledBack++
+		} else {
+			target.RollbackErr = response.Err
+		}
+	}
+	return rolledBack
+}
+
+func (e *Executor) targets(model *spec.ExpModel) (string, []string) {
+	for _, flag := range e.TargetFlags {
+		if value := model.ActionFlags[flag]; value != "" {
+			return flag, Split(value)
+		}
+	}
+	return "", nil
+}
+
+func (r *Result) add(target, uid string, response *spec.Response) {
+	result := Target{Target: target, Uid: uid, Success: response.Success, Result: response.Result}
+	if response.Success {
+		r.Succeeded++
+	} else {
+		r.Failed++
+		result.Code = response.Code
+		result.Err = response.Err
+	}
+	r.Targets = append(r.Targets, result)
+}
+
+// targetModel returns the copy of the model with the single target
+func targetModel(model *spec.ExpModel, flag, target string) *spec.ExpModel {
+	copied := *model
+	copied.ActionFlags = make(map[string]string, len(model.ActionFlags))
+	for k, v := range model.ActionFlags {
+		copied.ActionFlags[k] = v
+	}
+	copied.ActionFlags[flag] = target
+	return &copied
+}
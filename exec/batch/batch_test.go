@@ -0,0 +1,170 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batch
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// recordExecutor fails the targets listed and records the calls as create|destroy:uid:target
+type recordExecutor struct {
+	spec.Executor
+	failed map[string]bool
+	calls  []string
+}
+
+func (e *recordExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	target := model.ActionFlags["container-id"]
+	if target == "" {
+		target = model.ActionFlags["container-name"]
+	}
+	phase := "create"
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		phase = "destroy"
+		uid = suid
+	}
+	e.calls = append(e.calls, phase+":"+uid+":"+target)
+	if e.failed[target] {
+		return spec.ReturnFail(spec.ContainerExecFailed, target+" failed")
+	}
+	return spec.ReturnSuccess(target)
+}
+
+func run(t *testing.T, ctx context.Context, failed []string, flags map[string]string) (*spec.Response, []string) {
+	t.Helper()
+	record := &recordExecutor{failed: map[string]bool{}}
+	for _, target := range failed {
+		record.failed[target] = true
+	}
+	executor := Wrap(record, "batch-mode", "container-id", "container-name")
+	response := executor.Exec("uid1", ctx, &spec.ExpModel{Target: "network", ActionName: "delay", ActionFlags: flags})
+	return response, record.calls
+}
+
+func TestSingleTarget(t *testing.T) {
+	response, calls := run(t, context.Background(), nil, map[string]string{"container-id": "c1"})
+	if !response.Success || response.Result != "c1" || strings.Join(calls, ",") != "create:uid1:c1" {
+		t.Errorf("expected the single target passed through, got %+v %v", response, calls)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	response, calls := run(t, context.Background(), []string{"c2"}, map[string]string{"container-id": "c1, c2,c3,c1"})
+	expected := "create:uid1-0:c1,create:uid1-1:c2,create:uid1-2:c3,destroy:uid1-0:c1,destroy:uid1-2:c3"
+	if strings.Join(calls, ",") != expected {
+		t.Errorf("expected %s, got %v", expected, calls)
+	}
+	if response.Success || response.Code != spec.ContainerExecFailed.Code ||
+		!strings.Contains(response.Err, "1 of 3 targets failed, the first is c2") {
+		t.Fatalf("expected the batch failed, got %+v", response)
+	}
+	result := response.Result.(Result)
+	if result.Succeeded != 2 || result.Failed != 1 || !result.Targets[0].RolledBack || result.Targets[1].RolledBack ||
+		result.Targets[1].Err != "c2 failed" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestBestEffort(t *testing.T) {
+	flags := map[string]string{"container-id": "c1,c2", "batch-mode": ModeBestEffort}
+	response, calls := run(t, context.Background(), []string{"c2"}, flags)
+	if !response.Success || len(calls) != 2 {
+		t.Fatalf("expected the injected target kept, got %+v %v", response, calls)
+	}
+	if result := response.Result.(Result); result.Succeeded != 1 || result.Failed != 1 || result.Targets[0].RolledBack {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if response, _ := run(t, context.Background(), []string{"c1", "c2"}, flags); response.Success {
+		t.Errorf("expected the batch failed if no target injected, got %+v", response)
+	}
+	flags["batch-mode"] = "all"
+	if response, _ := run(t, context.Background(), nil, flags); response.Code != spec.ParameterIllegal.Code {
+		t.Errorf("expected the illegal mode refused, got %+v", response)
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	response, calls := run(t, ctx, nil, map[string]string{"container-name": "n1,n2"})
+	if !response.Success || strings.Join(calls, ",") != "destroy:uid1-0:n1,destroy:uid1-1:n2" {
+		t.Errorf("expected all targets destroyed, got %+v %v", response, calls)
+	}
+	response, calls = run(t, ctx, []string{"c1"}, map[string]string{"container-id": "c1,c2"})
+	if len(calls) != 2 {
+		t.Errorf("expected the destroy continued after the failure, got %v", calls)
+	}
+	if response.Success || !strings.Contains(response.Err, "1 of 2 targets failed to destroy") {
+		t.Errorf("expected the destroy failed, got %+v", response)
+	}
+}
+
+func TestCanary(t *testing.T) {
+	flags := map[string]string{"container-id": "c1,c2,c3", "batch-mode": ModeCanary}
+	tests := []struct {
+		name    string
+		failed  []string
+		healthy bool
+		calls   string
+		success bool
+	}{
+		{"healthy", nil, true, "create:uid1-0:c1,create:uid1-1:c2,create:uid1-2:c3", true},
+		{"unhealthy", nil, false, "create:uid1-0:c1,destroy:uid1-0:c1", false},
+		{"canary failed", []string{"c1"}, true, "create:uid1-0:c1", false},
+		{"other failed", []string{"c3"}, true,
+			"create:uid1-0:c1,create:uid1-1:c2,create:uid1-2:c3,destroy:uid1-0:c1,destroy:uid1-1:c2", false},
+	}
+	for _, tt := range tests {
+		record := &recordExecutor{failed: map[string]bool{}}
+		for _, target := range tt.failed {
+			record.failed[target] = true
+		}
+		executor := Wrap(record, "batch-mode", "container-id")
+		var checked string
+		executor.Canary = func(ctx context.Context, uid string, model *spec.ExpModel) (interface{}, error) {
+			checked = uid + ":" + model.ActionFlags["container-id"]
+			if !tt.healthy {
+				return "probe failed", errors.New("unhealthy")
+			}
+			return "probe passed", nil
+		}
+		response := executor.Exec("uid1", context.Background(), &spec.ExpModel{ActionFlags: flags})
+		if response.Success != tt.success || strings.Join(record.calls, ",") != tt.calls {
+			t.Errorf("%s: expected success %t and calls %s, got %+v %v", tt.name, tt.success, tt.calls, response,
+				record.calls)
+		}
+		if len(tt.failed) == 0 && checked != "uid1-0:c1" {
+			t.Errorf("%s: expected the canary checked, got %s", tt.name, checked)
+		}
+	}
+	record := &recordExecutor{}
+	executor := Wrap(record, "batch-mode", "container-id")
+	executor.Canary = func(ctx context.Context, uid string, model *spec.ExpModel) (interface{}, error) {
+		return "probe failed", errors.New("unhealthy")
+	}
+	response := executor.Exec("uid1", context.Background(), &spec.ExpModel{ActionFlags: flags})
+	result := response.Result.(Result)
+	if response.Code != spec.UnexpectedStatus.Code || result.Canary == nil || result.Canary.Healthy ||
+		result.Canary.Report != "probe failed" || !result.Targets[0].RolledBack ||
+		!strings.Contains(response.Err, "the canary c1 is unhealthy, unhealthy") {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
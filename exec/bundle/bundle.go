@@ -0,0 +1,265 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	SchemeHTTP  = "http://"
+	SchemeHTTPS = "https://"
+	SchemeOCI   = "oci://"
+
+	// TitleAnnotation is the file name of the layer pushed by oras
+	TitleAnnotation = "org.opencontainers.image.title"
+	// DefaultName is the file name of the bundle if it's not found in the source
+	DefaultName = "chaosblade.tar.gz"
+)
+
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// IsRemote returns true if the source is a http(s) url or an oci artifact reference
+func IsRemote(source string) bool {
+	return strings.HasPrefix(source, SchemeHTTP) || strings.HasPrefix(source, SchemeHTTPS) ||
+		strings.HasPrefix(source, SchemeOCI)
+}
+
+// ValidateDigest checks the digest is in the sha256:<hex> form, the empty digest is valid
+func ValidateDigest(digest string) error {
+	if digest != "" && !digestRegexp.MatchString(digest) {
+		return fmt.Errorf("illegal digest %s, the format is sha256:<64 hex>", digest)
+	}
+	return nil
+}
+
+// Fetcher downloads the bundles to the cache directory on the node, the cached bundle is keyed by its digest
+type Fetcher struct {
+	CacheDir string
+	Client   *http.Client
+}
+
+// NewFetcher returns the fetcher caching the bundles in the dir
+func NewFetcher(cacheDir string) *Fetcher {
+	return &Fetcher{CacheDir: cacheDir, Client: http.DefaultClient}
+}
+
+// Fetch returns the local file of the bundle of the source, the digest pins the content of the bundle, the bundle
+// cached with the same digest is reused without downloading. The bundle of an url without the digest is downloaded
+// every time, the oci artifact is always pinned by the layer digest in its manifest
+func (f *Fetcher) Fetch(ctx context.Context, source, digest string) (string, error) {
+	if err := ValidateDigest(digest); err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(source, SchemeOCI) {
+		return f.fetchArtifact(ctx, source, digest)
+	}
+	if !IsRemote(source) {
+		return "", fmt.Errorf("unsupported bundle source %s", source)
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = DefaultName
+	}
+	if digest != "" {
+		if file, ok := f.cached(digest, name); ok {
+			return file, nil
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return "", err
+	}
+	return f.download(req, digest, name)
+}
+
+// Path returns the cached file of the bundle
+func (f *Fetcher) Path(digest, name string) string {
+	return path.Join(f.CacheDir, strings.Replace(digest, ":", "-", 1), name)
+}
+
+func (f *Fetcher) cached(digest, name string) (string, bool) {
+	file := f.Path(digest, name)
+	if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+		return file, true
+	}
+	return "", false
+}
+
+// download saves the response body to the cache, the body is verified against the digest if it's not empty
+func (f *Fetcher) download(req *http.Request, digest, name string) (string, error) {
+	resp, err := f.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := os.MkdirAll(f.CacheDir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(f.CacheDir, ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("download %s failed, %v", req.URL, err)
+	}
+	actual := "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	if digest != "" && actual != digest {
+		return "", fmt.Errorf("the digest of %s is %s, mismatches %s", req.URL, actual, digest)
+	}
+	file := f.Path(actual, name)
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", err
+	}
+	return file, os.Rename(tmp.Name(), file)
+}
+
+// do sends the request, the anonymous bearer token is requested for the registry challenging it
+func (f *Fetcher) do(req *http.Request) (*http.Response, error) {
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		token, err := f.token(req.Context(), challenge)
+		if err != nil {
+			return nil, fmt.Errorf("authorize %s failed, %v", req.URL, err)
+		}
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+token)
+		if resp, err = f.Client.Do(req); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("get %s failed, status %s", req.URL, resp.Status)
+	}
+	return resp, nil
+}
+
+// token requests the anonymous token of the bearer challenge
+func (f *Fetcher) token(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported challenge %q", challenge)
+	}
+	params := parseChallenge(strings.TrimPrefix(challenge, "Bearer "))
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("no realm in challenge %q", challenge)
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for _, key := range []string{"service", "scope"} {
+		if value := params[key]; value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get token failed, status %s", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("no token returned by %s", realm)
+}
+
+// parseChallenge parses the key="value" pairs of the challenge, the commas in the quoted values are kept
+func parseChallenge(challenge string) map[string]string {
+	params := make(map[string]string)
+	for challenge != "" {
+		eq := strings.IndexByte(challenge, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(challenge[:eq])
+		rest := challenge[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+1:]
+			if len(rest) > 0 {
+				rest = rest[1:]
+			}
+		} else if comma := strings.IndexByte(rest, ','); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		challenge = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+	return params
+}
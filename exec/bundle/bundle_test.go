@@ -0,0 +1,153 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func digestOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestFetchURL(t *testing.T) {
+	content := []byte("chaosblade bundle")
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write(content)
+	}))
+	defer server.Close()
+	fetcher := NewFetcher(t.TempDir())
+	ctx := context.Background()
+	source := server.URL + "/release/chaosblade-1.7.2-linux-amd64.tar.gz"
+
+	file, err := fetcher.Fetch(ctx, source, digestOf(content))
+	if err != nil || file != fetcher.Path(digestOf(content), "chaosblade-1.7.2-linux-amd64.tar.gz") {
+		t.Fatalf("unexpected file %s, %v", file, err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != string(content) {
+		t.Errorf("unexpected content %q", data)
+	}
+	// the pinned bundle is cached, the unpinned one is downloaded again
+	if _, err := fetcher.Fetch(ctx, source, digestOf(content)); err != nil || requests != 1 {
+		t.Errorf("expected the cached bundle reused, requests %d, %v", requests, err)
+	}
+	if again, err := fetcher.Fetch(ctx, source, ""); err != nil || again != file || requests != 2 {
+		t.Errorf("expected the bundle downloaded again, got %s, requests %d, %v", again, requests, err)
+	}
+	if _, err := fetcher.Fetch(ctx, server.URL+"/other.tar.gz", digestOf([]byte("other"))); err == nil ||
+		!strings.Contains(err.Error(), "mismatches") {
+		t.Errorf("expected the digest mismatched, got %v", err)
+	}
+	if _, err := fetcher.Fetch(ctx, source, "md5:1234"); err == nil {
+		t.Errorf("expected the illegal digest refused")
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	digest := digestOf([]byte("manifest"))
+	tests := []struct {
+		source   string
+		expected Reference
+	}{
+		{"oci://ghcr.io/chaosblade/bundle", Reference{"ghcr.io", "chaosblade/bundle", "latest"}},
+		{"oci://localhost:5000/bundle:1.7.2", Reference{"localhost:5000", "bundle", "1.7.2"}},
+		{"oci://ghcr.io/chaosblade/bundle@" + digest, Reference{"ghcr.io", "chaosblade/bundle", digest}},
+	}
+	for _, test := range tests {
+		if reference, err := ParseReference(test.source); err != nil || reference != test.expected {
+			t.Errorf("expected %+v of %s, got %+v, %v", test.expected, test.source, reference, err)
+		}
+	}
+	for _, illegal := range []string{"oci://ghcr.io", "oci://ghcr.io/", "oci://ghcr.io/bundle@latest", "oci://ghcr.io/bundle:"} {
+		if _, err := ParseReference(illegal); err == nil {
+			t.Errorf("expected error of %s", illegal)
+		}
+	}
+}
+
+func TestFetchArtifact(t *testing.T) {
+	layer := []byte("chaosblade layer")
+	body, _ := json.Marshal(manifest{
+		MediaType: manifestMediaTypes[0],
+		Layers: []descriptor{
+			{MediaType: "application/vnd.oci.image.config.v1+json", Digest: digestOf([]byte("{}"))},
+			{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: digestOf(layer),
+				Annotations: map[string]string{TitleAnnotation: "chaosblade-1.7.2.tar.gz"}},
+		},
+	})
+	var blobs int32
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:chaosblade/bundle:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"token":"t1"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t1" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="registry",scope="repository:chaosblade/bundle:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/chaosblade/bundle/manifests/1.7.2", "/v2/chaosblade/bundle/manifests/" + digestOf(body):
+			w.Write(body)
+		case "/v2/chaosblade/bundle/blobs/" + digestOf(layer):
+			atomic.AddInt32(&blobs, 1)
+			w.Write(layer)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	fetcher := &Fetcher{CacheDir: t.TempDir(), Client: server.Client()}
+	ctx := context.Background()
+	registry := strings.TrimPrefix(server.URL, "https://")
+
+	file, err := fetcher.Fetch(ctx, "oci://"+registry+"/chaosblade/bundle:1.7.2", "")
+	if err != nil || file != fetcher.Path(digestOf(layer), "chaosblade-1.7.2.tar.gz") {
+		t.Fatalf("unexpected file %s, %v", file, err)
+	}
+	if _, err := fetcher.Fetch(ctx, "oci://"+registry+"/chaosblade/bundle@"+digestOf(body), digestOf(layer)); err != nil || blobs != 1 {
+		t.Errorf("expected the cached layer reused, blobs %d, %v", blobs, err)
+	}
+	if _, err := fetcher.Fetch(ctx, "oci://"+registry+"/chaosblade/bundle@"+digestOf([]byte("other")), ""); err == nil {
+		t.Errorf("expected the manifest digest mismatched")
+	}
+	if _, err := fetcher.Fetch(ctx, "oci://"+registry+"/chaosblade/bundle:1.7.2", digestOf([]byte("other"))); err == nil {
+		t.Errorf("expected the layer digest mismatched")
+	}
+	if _, err := fetcher.Fetch(ctx, "oci://"+registry+"/chaosblade/bundle:1.6.0", ""); err == nil {
+		t.Errorf("expected the absent tag failed")
+	}
+}
@@ -0,0 +1,302 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const indexFileName = "index.json"
+
+// Entry is a bundle staged in the cache for a source, the copies to the containers read the staged file
+type Entry struct {
+	Source string `json:"source"`
+	Digest string `json:"digest"`
+	File   string `json:"file"`
+	// DirName is the top directory of the bundle, such as chaosblade-1.7.2
+	DirName string `json:"dirName"`
+	Size    int64  `json:"size"`
+	// SourceSize and SourceModTime identify the version of the local source, the entry is staged again if it's changed
+	SourceSize    int64     `json:"sourceSize,omitempty"`
+	SourceModTime time.Time `json:"sourceModTime,omitempty"`
+	StagedTime    time.Time `json:"stagedTime"`
+	LastUsed      time.Time `json:"lastUsed"`
+	// Hits is the times the entry is reused since staged
+	Hits int64 `json:"hits"`
+}
+
+// Stats are the statistics of the cache for troubleshooting
+type Stats struct {
+	Dir     string  `json:"dir"`
+	Entries []Entry `json:"entries"`
+	// Size is the total size of the staged files, the file shared by the sources is counted once
+	Size   int64 `json:"size"`
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+type index struct {
+	Entries map[string]*Entry `json:"entries"`
+	Hits    int64             `json:"hits"`
+	Misses  int64             `json:"misses"`
+}
+
+// Stage returns the bundle of the source staged in the cache, the source is a local file, an url or an oci artifact
+// reference. The entry staged before is reused if the local source is unchanged, or the remote source is pinned by the
+// same digest, otherwise the source is read once to the cache, so the deployments to the containers on the node don't
+// read the source repeatedly
+func (f *Fetcher) Stage(ctx context.Context, source, digest string) (Entry, error) {
+	if err := ValidateDigest(digest); err != nil {
+		return Entry{}, err
+	}
+	var info os.FileInfo
+	if !IsRemote(source) {
+		var err error
+		if info, err = os.Stat(source); err != nil {
+			return Entry{}, err
+		}
+	}
+	idx, err := f.loadIndex()
+	if err != nil {
+		return Entry{}, err
+	}
+	now := time.Now()
+	if entry, ok := idx.Entries[source]; ok && entry.fresh(info, digest) {
+		entry.Hits++
+		entry.LastUsed = now
+		idx.Hits++
+		return *entry, f.flushIndex(idx)
+	}
+
+	var file string
+	if info == nil {
+		file, err = f.Fetch(ctx, source, digest)
+	} else {
+		file, err = f.copy(source, digest)
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	dirName, err := TopDir(file)
+	if err != nil {
+		return Entry{}, err
+	}
+	stat, err := os.Stat(file)
+	if err != nil {
+		return Entry{}, err
+	}
+	// the staged file is in the directory of its digest, see Path
+	entry := &Entry{
+		Source:     source,
+		Digest:     strings.Replace(path.Base(path.Dir(file)), "-", ":", 1),
+		File:       file,
+		DirName:    dirName,
+		Size:       stat.Size(),
+		StagedTime: now,
+		LastUsed:   now,
+	}
+	if info != nil {
+		entry.SourceSize, entry.SourceModTime = info.Size(), info.ModTime()
+	}
+	if stale, ok := idx.Entries[source]; ok && stale.File != file {
+		idx.remove(stale.File, source)
+	}
+	idx.Entries[source] = entry
+	idx.Misses++
+	return *entry, f.flushIndex(idx)
+}
+
+// fresh returns true if the entry is the version of the source, the remote source without the digest is never fresh
+func (e *Entry) fresh(info os.FileInfo, digest string) bool {
+	if _, err := os.Stat(e.File); err != nil {
+		return false
+	}
+	if digest != "" && e.Digest != digest {
+		return false
+	}
+	if info == nil {
+		return digest != ""
+	}
+	return e.SourceSize == info.Size() && e.SourceModTime.Equal(info.ModTime())
+}
+
+// copy stages the local source to the cache, it's verified against the digest if it's not empty
+func (f *Fetcher) copy(source, digest string) (string, error) {
+	src, err := os.Open(source)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	if err := os.MkdirAll(f.CacheDir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(f.CacheDir, ".stage-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("stage %s failed, %v", source, err)
+	}
+	actual := "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	if digest != "" && actual != digest {
+		return "", fmt.Errorf("the digest of %s is %s, mismatches %s", source, actual, digest)
+	}
+	file := f.Path(actual, path.Base(source))
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", err
+	}
+	return file, os.Rename(tmp.Name(), file)
+}
+
+// Stats returns the statistics of the cache, the entries are sorted by the last used time, the latest first
+func (f *Fetcher) Stats() (Stats, error) {
+	idx, err := f.loadIndex()
+	if err != nil {
+		return Stats{}, err
+	}
+	stats := Stats{Dir: f.CacheDir, Entries: make([]Entry, 0, len(idx.Entries)), Hits: idx.Hits, Misses: idx.Misses}
+	files := make(map[string]bool)
+	for _, entry := range idx.Entries {
+		stats.Entries = append(stats.Entries, *entry)
+		if !files[entry.File] {
+			files[entry.File] = true
+			stats.Size += entry.Size
+		}
+	}
+	sort.Slice(stats.Entries, func(i, j int) bool {
+		return stats.Entries[i].LastUsed.After(stats.Entries[j].LastUsed)
+	})
+	return stats, nil
+}
+
+// Extract extracts the staged bundle once beside the staged file, and returns the top directory extracted, the bundle
+// is extracted to a temporary directory first, so the directory returned is always complete
+func (f *Fetcher) Extract(entry Entry) (string, error) {
+	dir := path.Join(path.Dir(entry.File), entry.DirName)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir, nil
+	}
+	tmp, err := os.MkdirTemp(path.Dir(entry.File), ".extract-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if output, err := exec.Command("tar", "-zxf", entry.File, "-C", tmp).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("extract %s failed, %v: %s", entry.File, err, strings.TrimSpace(string(output)))
+	}
+	if err := os.Rename(path.Join(tmp, entry.DirName), dir); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return dir, nil
+}
+
+// TopDir returns the top directory of the gzip tar bundle, it's the directory of the first file in the bundle
+func TopDir(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	reader, err := gzip.NewReader(f)
+	if err != nil {
+		return "", fmt.Errorf("illegal bundle %s, %v", file, err)
+	}
+	defer reader.Close()
+	header, err := tar.NewReader(reader).Next()
+	if err != nil {
+		return "", fmt.Errorf("illegal bundle %s, %v", file, err)
+	}
+	name := strings.Split(strings.TrimPrefix(header.Name, "./"), "/")[0]
+	if name == "" || name == "." || name == ".." {
+		return "", fmt.Errorf("illegal bundle %s, no top directory", file)
+	}
+	return name, nil
+}
+
+// remove deletes the staged file replaced for the source, unless it's staged for another source
+func (i *index) remove(file, source string) {
+	for key, entry := range i.Entries {
+		if key != source && entry.File == file {
+			return
+		}
+	}
+	os.RemoveAll(path.Dir(file))
+}
+
+func (f *Fetcher) loadIndex() (*index, error) {
+	idx := &index{Entries: make(map[string]*Entry)}
+	data, err := os.ReadFile(path.Join(f.CacheDir, indexFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return idx, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("illegal cache index, %v", err)
+	}
+	if idx.Entries == nil {
+		idx.Entries = make(map[string]*Entry)
+	}
+	return idx, nil
+}
+
+// flushIndex writes the index by renaming a temporary file, so the index read concurrently is never partial
+func (f *Fetcher) flushIndex(idx *index) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.CacheDir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.CacheDir, ".index-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path.Join(f.CacheDir, indexFileName))
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func gzipTar(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestStageLocal(t *testing.T) {
+	source := path.Join(t.TempDir(), "chaosblade-1.7.2.tar.gz")
+	content := gzipTar(t, "./chaosblade-1.7.2/", "./chaosblade-1.7.2/bin/")
+	if err := os.WriteFile(source, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fetcher := NewFetcher(t.TempDir())
+	ctx := context.Background()
+
+	entry, err := fetcher.Stage(ctx, source, "")
+	if err != nil || entry.File != fetcher.Path(digestOf(content), "chaosblade-1.7.2.tar.gz") ||
+		entry.DirName != "chaosblade-1.7.2" || entry.Digest != digestOf(content) {
+		t.Fatalf("unexpected entry %+v, %v", entry, err)
+	}
+	if again, err := fetcher.Stage(ctx, source, digestOf(content)); err != nil || again.Hits != 1 {
+		t.Errorf("expected the staged bundle reused, got %+v, %v", again, err)
+	}
+	// the changed source is staged again
+	changed := gzipTar(t, "chaosblade-1.7.3/", "chaosblade-1.7.3/bin/")
+	if err := os.WriteFile(source, changed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(source, time.Now(), time.Now().Add(time.Minute))
+	again, err := fetcher.Stage(ctx, source, "")
+	if err != nil || again.DirName != "chaosblade-1.7.3" || again.Hits != 0 {
+		t.Errorf("expected the changed bundle staged, got %+v, %v", again, err)
+	}
+	if _, err := fetcher.Stage(ctx, source, digestOf(content)); err == nil {
+		t.Errorf("expected the digest mismatched")
+	}
+	if _, err := os.Stat(entry.File); !os.IsNotExist(err) {
+		t.Errorf("expected the stale bundle removed, %v", err)
+	}
+
+	stats, err := fetcher.Stats()
+	if err != nil || stats.Hits != 1 || stats.Misses != 2 || len(stats.Entries) != 1 || stats.Size != int64(len(changed)) {
+		t.Errorf("unexpected stats %+v, %v", stats, err)
+	}
+	dir, err := fetcher.Extract(again)
+	if info, statErr := os.Stat(path.Join(dir, "bin")); err != nil || statErr != nil || !info.IsDir() ||
+		dir != path.Join(path.Dir(again.File), "chaosblade-1.7.3") {
+		t.Errorf("unexpected extracted %s, %v, %v", dir, err, statErr)
+	}
+	illegal := path.Join(t.TempDir(), "illegal.tar.gz")
+	os.WriteFile(illegal, []byte("illegal"), 0644)
+	if _, err := fetcher.Stage(ctx, illegal, ""); err == nil {
+		t.Errorf("expected the illegal bundle refused")
+	}
+}
+
+func TestStageRemote(t *testing.T) {
+	content := gzipTar(t, "chaosblade-1.7.2/")
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write(content)
+	}))
+	defer server.Close()
+	fetcher := NewFetcher(t.TempDir())
+	ctx := context.Background()
+	source := server.URL + "/chaosblade-1.7.2.tar.gz"
+
+	for i := 0; i < 3; i++ {
+		if entry, err := fetcher.Stage(ctx, source, digestOf(content)); err != nil || entry.DirName != "chaosblade-1.7.2" {
+			t.Fatalf("unexpected entry %+v, %v", entry, err)
+		}
+	}
+	if _, err := fetcher.Stage(ctx, source, ""); err != nil || requests != 2 {
+		t.Errorf("expected the unpinned bundle downloaded again, requests %d, %v", requests, err)
+	}
+	stats, err := fetcher.Stats()
+	if err != nil || stats.Hits != 2 || stats.Misses != 2 || stats.Size != int64(len(content)) {
+		t.Errorf("unexpected stats %+v, %v", stats, err)
+	}
+}
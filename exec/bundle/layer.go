@@ -0,0 +1,85 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// VersionFileName is the version marker in the blade directory, it has the top directory of the bundle
+const VersionFileName = ".version"
+
+// Layer writes the bundle as an image layer, a tar extracted to the root, the top directory of the bundle is renamed to
+// the blade directory, such as chaosblade-1.7.2/blade to opt/chaosblade/blade, and the version marker is added
+func Layer(w io.Writer, entry Entry, bladeDir string) error {
+	f, err := os.Open(entry.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("illegal bundle %s, %v", entry.File, err)
+	}
+	defer gz.Close()
+	root := strings.TrimPrefix(path.Clean(bladeDir), "/")
+	reader, writer := tar.NewReader(gz), tar.NewWriter(w)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("illegal bundle %s, %v", entry.File, err)
+		}
+		name := strings.TrimPrefix(header.Name, "./")
+		if name != entry.DirName && !strings.HasPrefix(name, entry.DirName+"/") {
+			return fmt.Errorf("illegal bundle %s, %s is out of %s", entry.File, header.Name, entry.DirName)
+		}
+		header.Name = root + strings.TrimPrefix(name, entry.DirName)
+		if header.Typeflag == tar.TypeLink {
+			header.Linkname = root + strings.TrimPrefix(strings.TrimPrefix(header.Linkname, "./"), entry.DirName)
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(writer, reader); err != nil {
+			return err
+		}
+	}
+	marker := []byte(entry.DirName + "\n")
+	if err := writer.WriteHeader(&tar.Header{
+		Name:     path.Join(root, VersionFileName),
+		Mode:     0644,
+		Size:     int64(len(marker)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	if _, err := writer.Write(marker); err != nil {
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestLayer(t *testing.T) {
+	file := path.Join(t.TempDir(), "chaosblade-1.7.2.tar.gz")
+	if err := os.WriteFile(file, gzipTar(t, "./chaosblade-1.7.2/", "./chaosblade-1.7.2/bin/"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Layer(&buf, Entry{File: file, DirName: "chaosblade-1.7.2"}, "/opt/chaosblade"); err != nil {
+		t.Fatalf("layer failed, %v", err)
+	}
+	names := make([]string, 0)
+	reader := tar.NewReader(&buf)
+	var marker []byte
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		if header.Name == "opt/chaosblade/.version" {
+			marker, _ = io.ReadAll(reader)
+		}
+	}
+	expected := []string{"opt/chaosblade/", "opt/chaosblade/bin/", "opt/chaosblade/.version"}
+	if !reflect.DeepEqual(names, expected) || string(marker) != "chaosblade-1.7.2\n" {
+		t.Errorf("expected %v, got %v, marker %q", expected, names, marker)
+	}
+	if err := Layer(io.Discard, Entry{File: file, DirName: "chaosblade-1.7.3"}, "/opt/chaosblade"); err == nil {
+		t.Errorf("expected the entry out of the top directory refused")
+	}
+}
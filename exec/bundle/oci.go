@@ -0,0 +1,147 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Reference is the oci artifact reference, such as oci://registry.example.com/chaosblade/bundle:1.7.2, or pinned by the
+// manifest digest, oci://registry.example.com/chaosblade/bundle@sha256:<hex>
+type Reference struct {
+	Registry   string
+	Repository string
+	// Reference is the tag or the manifest digest
+	Reference string
+}
+
+// ParseReference parses the oci:// reference, the default tag is latest
+func ParseReference(source string) (Reference, error) {
+	ref := strings.TrimPrefix(source, SchemeOCI)
+	slash := strings.IndexByte(ref, '/')
+	if slash <= 0 || slash == len(ref)-1 {
+		return Reference{}, fmt.Errorf("illegal oci reference %s, the format is oci://registry/repository[:tag|@digest]", source)
+	}
+	reference := Reference{Registry: ref[:slash], Repository: ref[slash+1:], Reference: "latest"}
+	if at := strings.IndexByte(reference.Repository, '@'); at >= 0 {
+		reference.Repository, reference.Reference = reference.Repository[:at], reference.Repository[at+1:]
+		if err := ValidateDigest(reference.Reference); err != nil || reference.Reference == "" {
+			return Reference{}, fmt.Errorf("illegal digest of oci reference %s", source)
+		}
+	} else if colon := strings.LastIndexByte(reference.Repository, ':'); colon > strings.LastIndexByte(reference.Repository, '/') {
+		reference.Repository, reference.Reference = reference.Repository[:colon], reference.Repository[colon+1:]
+	}
+	if reference.Repository == "" || reference.Reference == "" {
+		return Reference{}, fmt.Errorf("illegal oci reference %s, the format is oci://registry/repository[:tag|@digest]", source)
+	}
+	return reference, nil
+}
+
+func (r Reference) url(kind, ref string) string {
+	return fmt.Sprintf("https://%s/v2/%s/%s/%s", r.Registry, r.Repository, kind, ref)
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Layers    []descriptor `json:"layers"`
+	// Manifests is not empty if the reference is an index
+	Manifests []descriptor `json:"manifests"`
+}
+
+// fetchArtifact downloads the bundle layer of the artifact, the layer is cached by its digest in the manifest, so only
+// the manifest is requested if the layer is cached. The digest, if not empty, must be the digest of the layer
+func (f *Fetcher) fetchArtifact(ctx context.Context, source, digest string) (string, error) {
+	reference, err := ParseReference(source)
+	if err != nil {
+		return "", err
+	}
+	layer, err := f.resolve(ctx, reference)
+	if err != nil {
+		return "", err
+	}
+	if digest != "" && layer.Digest != digest {
+		return "", fmt.Errorf("the bundle layer of %s is %s, mismatches %s", source, layer.Digest, digest)
+	}
+	name := path.Base(layer.Annotations[TitleAnnotation])
+	if name == "" || name == "." || name == "/" {
+		name = DefaultName
+	}
+	if file, ok := f.cached(layer.Digest, name); ok {
+		return file, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reference.url("blobs", layer.Digest), nil)
+	if err != nil {
+		return "", err
+	}
+	return f.download(req, layer.Digest, name)
+}
+
+// resolve returns the bundle layer in the manifest of the reference, the manifest is verified if the reference is a
+// digest
+func (f *Fetcher) resolve(ctx context.Context, reference Reference) (descriptor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reference.url("manifests", reference.Reference), nil)
+	if err != nil {
+		return descriptor{}, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	resp, err := f.do(req)
+	if err != nil {
+		return descriptor{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return descriptor{}, err
+	}
+	if digestRegexp.MatchString(reference.Reference) {
+		sum := sha256.Sum256(body)
+		if actual := "sha256:" + hex.EncodeToString(sum[:]); actual != reference.Reference {
+			return descriptor{}, fmt.Errorf("the manifest digest of %s is %s, mismatches", reference.Repository, actual)
+		}
+	}
+	var m manifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return descriptor{}, fmt.Errorf("illegal manifest of %s, %v", reference.Repository, err)
+	}
+	if len(m.Manifests) > 0 {
+		return descriptor{}, fmt.Errorf("%s is an index, reference the manifest of the bundle", reference.Repository)
+	}
+	if len(m.Layers) == 1 {
+		return m.Layers[0], nil
+	}
+	for _, layer := range m.Layers {
+		if strings.Contains(layer.MediaType, "tar") && strings.Contains(layer.MediaType, "gzip") {
+			return layer, nil
+		}
+	}
+	return descriptor{}, fmt.Errorf("no gzip tar layer in the manifest of %s", reference.Repository)
+}
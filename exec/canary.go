@@ -0,0 +1,107 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/probe"
+)
+
+// DefaultCanaryWait is how long the fault of the canary takes effect before the canary probes
+const DefaultCanaryWait = 10 * time.Second
+
+// canarySleep waits before the canary probes, it is replaced in the tests
+var canarySleep = func(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// canaryCheck evaluates the canary probes against the canary container of the batch after the canary wait, the
+// canary is healthy without the probes if it is injected
+func canaryCheck(ctx context.Context, uid string, model *spec.ExpModel) (interface{}, error) {
+	flags := model.ActionFlags
+	var probes []probe.Probe
+	if value := flags[CanaryProbesFlag.Name]; value != "" {
+		if err := json.Unmarshal([]byte(value), &probes); err != nil {
+			return nil, fmt.Errorf("illegal %s, %v", CanaryProbesFlag.Name, err)
+		}
+		if err := probe.Validate(probes); err != nil {
+			return nil, err
+		}
+	}
+	wait := DefaultCanaryWait
+	if value := flags[CanaryWaitFlag.Name]; value != "" {
+		var err error
+		if wait, err = durations.Parse(value); err != nil {
+			return nil, fmt.Errorf("illegal %s, %v", CanaryWaitFlag.Name, err)
+		}
+	}
+	if len(probes) == 0 {
+		return nil, nil
+	}
+	if err := canarySleep(ctx, wait); err != nil {
+		return nil, err
+	}
+	target, err := canaryTarget(ctx, uid, model, probes)
+	if err != nil {
+		return nil, err
+	}
+	results, healthy := probe.Evaluate(ctx, target, probes)
+	if !healthy {
+		unhealthy := 0
+		for _, result := range results {
+			if !result.Healthy {
+				unhealthy++
+			}
+		}
+		return results, fmt.Errorf("%d of %d canary probes are unhealthy", unhealthy, len(probes))
+	}
+	return results, nil
+}
+
+// canaryTarget resolves the canary container for the command, the stats and the path http probes
+func canaryTarget(ctx context.Context, uid string, model *spec.ExpModel, probes []probe.Probe) (probe.Target, error) {
+	if !probe.NeedContainer(probes) {
+		return probe.Target{}, nil
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		return probe.Target{}, err
+	}
+	containerInfo, response := GetContainer(ctx, client, uid, model.ActionFlags[ContainerIdFlag.Name],
+		model.ActionFlags[ContainerNameFlag.Name], nil)
+	if !response.Success {
+		return probe.Target{}, fmt.Errorf("get the canary container failed, %s", response.Err)
+	}
+	return probe.Target{
+		Client:      client,
+		ContainerId: containerInfo.ContainerId,
+		IP:          containerInfo.PrimaryIP(),
+		Ports:       containerInfo.Ports,
+	}, nil
+}
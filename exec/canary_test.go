@@ -0,0 +1,68 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+func TestCanaryCheck(t *testing.T) {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1", ContainerName: "nginx"})
+	healthy := true
+	client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+		if !healthy {
+			return "", errors.New("exit status 22")
+		}
+		return "ok", nil
+	}
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	var waited time.Duration
+	sleep := canarySleep
+	canarySleep = func(ctx context.Context, d time.Duration) error {
+		waited = d
+		return nil
+	}
+	defer func() { canarySleep = sleep }()
+
+	model := &spec.ExpModel{ActionFlags: map[string]string{
+		ContainerIdFlag.Name:  "c1",
+		CanaryProbesFlag.Name: `[{"type":"command","command":"curl -sf localhost/healthz"}]`,
+		CanaryWaitFlag.Name:   "30s",
+	}}
+	if _, err := canaryCheck(context.Background(), "uid1-0", model); err != nil || waited != 30*time.Second {
+		t.Errorf("expected the canary healthy after 30s, got %v after %s", err, waited)
+	}
+	healthy = false
+	if _, err := canaryCheck(context.Background(), "uid1-0", model); err == nil {
+		t.Error("expected the canary unhealthy")
+	}
+	model.ActionFlags[CanaryProbesFlag.Name] = `[{"type":"ping"}]`
+	if _, err := canaryCheck(context.Background(), "uid1-0", model); err == nil {
+		t.Error("expected the illegal probe refused")
+	}
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package compat checks the capabilities of the connected runtime and derives which experiments are supported
+package compat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio"
+)
+
+// CapabilityCgroup is the cgroup version of the node, the resource experiments need it
+const CapabilityCgroup = "cgroup"
+
+var cgroupRoot = "/sys/fs/cgroup"
+
+// CapabilityUndeclared is reported missing by the experiments whose executor or target declares no requirements,
+// a new experiment must be added to the requirements below before it is reported supported
+const CapabilityUndeclared = "undeclared"
+
+// executorRequirements are the capabilities required by the executors, keyed by the executor name. The executors
+// entering the namespaces or the rootfs of the container need the pid from the verbose info
+var executorRequirements = map[string][]string{
+	"CommonExecutor":                {crio.CapabilityVerboseInfo},
+	"networkExecutor":               {crio.CapabilityVerboseInfo},
+	"runCmdInContainerExecutorByCP": {crio.CapabilityVerboseInfo},
+	"runAndExecSidecar":             {crio.CapabilityExecSync, crio.CapabilityExecSyncTimeout},
+	"noop":                          {crio.CapabilityVerboseInfo},
+	"remove":                        {},
+	"node":                          {},
+	"conntrack":                     {crio.CapabilityVerboseInfo},
+	"cpu":                           {crio.CapabilityVerboseInfo},
+	"device":                        {crio.CapabilityVerboseInfo},
+	"disk":                          {crio.CapabilityVerboseInfo},
+	"fd":                            {crio.CapabilityVerboseInfo},
+	"gpu":                           {crio.CapabilityVerboseInfo, crio.CapabilityExecSync},
+	"hosts":                         {crio.CapabilityVerboseInfo},
+	"interface":                     {crio.CapabilityVerboseInfo},
+	"log":                           {crio.CapabilityVerboseInfo},
+	"network":                       {crio.CapabilityVerboseInfo},
+	"pid":                           {crio.CapabilityVerboseInfo},
+	"secret":                        {crio.CapabilityVerboseInfo},
+	"socket":                        {crio.CapabilityVerboseInfo},
+	"steal":                         {crio.CapabilityVerboseInfo},
+	"stress":                        {crio.CapabilityVerboseInfo},
+	"sysctl":                        {crio.CapabilityVerboseInfo},
+}
+
+// targetRequirements are the capabilities required by the targets besides the executors
+var targetRequirements = map[string][]string{
+	"conntrack": {},
+	"container": {},
+	"cpu":       {CapabilityCgroup},
+	"device":    {},
+	"disk":      {},
+	"fd":        {},
+	"file":      {},
+	"gpu":       {},
+	"hosts":     {},
+	"http2":     {},
+	"interface": {},
+	"log":       {},
+	"mem":       {CapabilityCgroup},
+	"network":   {},
+	"node":      {},
+	"pid":       {CapabilityCgroup},
+	"process":   {},
+	"script":    {},
+	"secret":    {},
+	"socket":    {},
+	"steal":     {CapabilityCgroup},
+	"stress":    {},
+	"sysctl":    {},
+}
+
+// Experiment is the support of one target-action
+type Experiment struct {
+	Name      string   `json:"name"`
+	Executor  string   `json:"executor"`
+	Supported bool     `json:"supported"`
+	Missing   []string `json:"missing,omitempty"`
+	// Limits are the flag values unusable for the missing node features, the experiment is supported without them
+	Limits []string `json:"limits,omitempty"`
+}
+
+// Report is the compatibility matrix of a runtime
+type Report struct {
+	Runtime        string            `json:"runtime"`
+	RuntimeVersion string            `json:"runtimeVersion"`
+	ContainerId    string            `json:"containerId"`
+	Capabilities   []crio.Capability `json:"capabilities"`
+	Experiments    []Experiment      `json:"experiments"`
+}
+
+// Check exercises the capabilities on the container, the first container of the runtime is used if the id is empty.
+// It runs on the node because the cgroup version is read from the host.
+func Check(ctx context.Context, client *crio.CRIClient, containerId string) (*Report, error) {
+	report := &Report{}
+	var err error
+	report.Runtime, report.RuntimeVersion, err = client.RuntimeVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if containerId == "" {
+		containers, err, _ := client.ListContainers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(containers) == 0 {
+			return nil, fmt.Errorf("no containers to check, please start one or specify the container id")
+		}
+		containerId = containers[0].ContainerId
+	}
+	report.ContainerId = containerId
+	report.Capabilities = append(client.CheckCapabilities(ctx, containerId), checkCgroup())
+	report.Experiments = matrix(report.Capabilities)
+	return report, nil
+}
+
+func checkCgroup() crio.Capability {
+	capability := crio.Capability{Name: CapabilityCgroup}
+	if _, err := os.Stat(path.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		capability.Supported, capability.Detail = true, "v2"
+		return capability
+	}
+	if _, err := os.Stat(path.Join(cgroupRoot, "memory")); err == nil {
+		capability.Supported, capability.Detail = true, "v1"
+		return capability
+	}
+	capability.Detail = fmt.Sprintf("no cgroup found under %s", cgroupRoot)
+	return capability
+}
+
+// matrix derives the experiment support from the capabilities
+func matrix(capabilities []crio.Capability) []Experiment {
+	supported := make(map[string]bool, len(capabilities))
+	for _, capability := range capabilities {
+		supported[capability.Name] = capability.Supported
+	}
+	experiments := make([]Experiment, 0)
+	for key, executor := range exec.GetAllExecutors() {
+		target := strings.SplitN(key, "-", 2)[0]
+		experiment := Experiment{Name: key, Executor: executor.Name()}
+		executorRequired, executorDeclared := executorRequirements[executor.Name()]
+		targetRequired, targetDeclared := targetRequirements[target]
+		if !executorDeclared || !targetDeclared {
+			experiment.Missing = []string{CapabilityUndeclared}
+			experiments = append(experiments, experiment)
+			continue
+		}
+		for _, requirement := range append(append([]string{}, executorRequired...), targetRequired...) {
+			if !supported[requirement] {
+				experiment.Missing = append(experiment.Missing, requirement)
+			}
+		}
+		experiment.Supported = len(experiment.Missing) == 0
+		experiments = append(experiments, experiment)
+	}
+	sort.Slice(experiments, func(i, j int) bool {
+		return experiments[i].Name < experiments[j].Name
+	})
+	return experiments
+}
+
+// Print writes the capabilities and the experiment matrix as tables
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Runtime: %s %s, container: %s\n\n", r.Runtime, r.RuntimeVersion, r.ContainerId)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CAPABILITY\tSUPPORTED\tDETAIL")
+	for _, capability := range r.Capabilities {
+		fmt.Fprintf(tw, "%s\t%t\t%s\n", capability.Name, capability.Supported, capability.Detail)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	printExperiments(w, r.Experiments)
+}
+
+func printExperiments(w io.Writer, experiments []Experiment) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "EXPERIMENT\tSUPPORTED\tMISSING\tLIMITS")
+	for _, experiment := range experiments {
+		fmt.Fprintf(tw, "%s\t%t\t%s\t%s\n", experiment.Name, experiment.Supported, strings.Join(experiment.Missing, ","),
+			strings.Join(experiment.Limits, "; "))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compat
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio/fake"
+)
+
+func TestCheck(t *testing.T) {
+	server := fake.NewServer(fake.Container{
+		Id:    "c1",
+		Name:  "nginx",
+		State: v1.ContainerState_CONTAINER_RUNNING,
+		Pid:   1234,
+	})
+	server.SetExecFunc(func(containerId string, cmd []string) ([]byte, []byte, int32) {
+		if cmd[0] == "sh" {
+			return make([]byte, 1<<20), nil, 0
+		}
+		return nil, nil, 0
+	})
+	endpoint, err := server.Start()
+	if err != nil {
+		t.Fatalf("start fake cri server failed, %v", err)
+	}
+	defer server.Stop()
+	crio.CloseClient()
+	client, err := crio.NewClient(endpoint, "")
+	if err != nil {
+		t.Fatalf("connect fake cri server failed, %v", err)
+	}
+	defer crio.CloseClient()
+
+	cgroupRoot = t.TempDir()
+	if err := os.WriteFile(path.Join(cgroupRoot, "cgroup.controllers"), []byte("cpu memory pids"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Check(context.Background(), client, "")
+	if err != nil {
+		t.Fatalf("check failed, %v", err)
+	}
+	if report.ContainerId != "c1" || report.Runtime != fake.RuntimeName {
+		t.Errorf("unexpected report %+v", report)
+	}
+	expected := map[string]bool{
+		crio.CapabilityVerboseInfo:     true,
+		crio.CapabilityExecSync:        true,
+		crio.CapabilityExecSyncTimeout: true,
+		crio.CapabilityStreamingExec:   false,
+		crio.CapabilityAttach:          true,
+		crio.CapabilityCheckpoint:      false,
+		CapabilityCgroup:               true,
+	}
+	for _, capability := range report.Capabilities {
+		if supported, ok := expected[capability.Name]; !ok || supported != capability.Supported {
+			t.Errorf("unexpected capability %+v", capability)
+		}
+	}
+	if len(report.Capabilities) != len(expected) {
+		t.Errorf("expected %d capabilities, got %d", len(expected), len(report.Capabilities))
+	}
+	for _, experiment := range report.Experiments {
+		if !experiment.Supported {
+			t.Errorf("experiment %s is not supported, missing %v", experiment.Name, experiment.Missing)
+		}
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	if !strings.Contains(out.String(), "container-remove") {
+		t.Errorf("the matrix misses container-remove, %s", out.String())
+	}
+}
+
+func TestMatrixMissing(t *testing.T) {
+	experiments := matrix([]crio.Capability{{Name: crio.CapabilityVerboseInfo, Supported: true}})
+	for _, experiment := range experiments {
+		switch {
+		case strings.HasPrefix(experiment.Name, "cpu-") || strings.HasPrefix(experiment.Name, "mem-"):
+			if experiment.Supported || experiment.Missing[0] != CapabilityCgroup {
+				t.Errorf("experiment %s requires the cgroup, got %+v", experiment.Name, experiment)
+			}
+		case experiment.Name == "container-remove":
+			if !experiment.Supported {
+				t.Errorf("experiment %s requires nothing, got %+v", experiment.Name, experiment)
+			}
+		}
+	}
+}
+
+// TestRequirementsDeclared fails for the experiment whose executor or target is not in the requirements, so every
+// new experiment declares what it requires of the runtime
+func TestRequirementsDeclared(t *testing.T) {
+	for key, executor := range exec.GetAllExecutors() {
+		if !declared(executorRequirements, executor.Name()) {
+			t.Errorf("executor %s of experiment %s declares no requirements in executorRequirements", executor.Name(), key)
+		}
+		if target := strings.SplitN(key, "-", 2)[0]; !declared(targetRequirements, target) {
+			t.Errorf("target %s of experiment %s declares no requirements in targetRequirements", target, key)
+		}
+	}
+}
+
+func declared(requirements map[string][]string, name string) bool {
+	_, ok := requirements[name]
+	return ok
+}
+
+func TestMatrixUndeclared(t *testing.T) {
+	defer func(requirements []string) { executorRequirements["CommonExecutor"] = requirements }(executorRequirements["CommonExecutor"])
+	delete(executorRequirements, "CommonExecutor")
+	for _, experiment := range matrix([]crio.Capability{{Name: crio.CapabilityVerboseInfo, Supported: true}}) {
+		if experiment.Executor != "CommonExecutor" {
+			continue
+		}
+		if experiment.Supported || len(experiment.Missing) != 1 || experiment.Missing[0] != CapabilityUndeclared {
+			t.Errorf("experiment %s of the undeclared executor is reported %+v", experiment.Name, experiment)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/node"
+)
+
+// featureRequirements are the node features required by the targets or the target-actions, the alternatives of a
+// requirement are separated by |. Every target is declared, the experiments of a target declared by neither the
+// featureRequirements nor the unprivilegedExperiments are reported undeclared
+var featureRequirements = map[string][]string{
+	"conntrack":         {node.FeatureConntrack},
+	"cpu":               {node.FeatureCgroupWritable},
+	"device":            {node.FeatureDmsetup},
+	"disk":              {},
+	"fd":                {node.FeatureCgroupWritable},
+	"file":              {},
+	"gpu":               {node.FeatureNvidiaGPU, node.FeatureCgroupWritable},
+	"hosts":             {},
+	"http2":             {},
+	"interface":         {},
+	"interface-mirror":  {node.FeatureTc},
+	"log":               {},
+	"mem":               {node.FeatureCgroupWritable},
+	"network":           {},
+	"network-delay":     {node.FeatureTc},
+	"network-loss":      {node.FeatureTc},
+	"network-corrupt":   {node.FeatureTc},
+	"network-duplicate": {node.FeatureTc},
+	"network-reorder":   {node.FeatureTc},
+	"network-drop":      {node.FeatureIptables + "|" + node.FeatureNft},
+	"network-dns_down":  {node.FeatureIptables + "|" + node.FeatureNft},
+	"network-dns_delay": {node.FeatureTc, node.FeatureU32},
+	"node":              {},
+	"pid":               {node.FeatureCgroupWritable},
+	"process":           {},
+	"script":            {},
+	"secret":            {},
+	"socket":            {},
+	"steal":             {node.FeatureCgroupWritable},
+	"stress":            {node.FeatureCgroupWritable},
+	"sysctl":            {},
+}
+
+// optionRequirements are the node features required by the flag values of the target-actions, the experiment
+// missing them is still supported without the flag values, which are reported in its limits
+var optionRequirements = map[string][]optionRequirement{
+	"network-delay":     {ingressRequirement},
+	"network-loss":      {ingressRequirement},
+	"network-corrupt":   {ingressRequirement},
+	"network-duplicate": {ingressRequirement},
+	"network-reorder":   {ingressRequirement},
+}
+
+type optionRequirement struct {
+	Option  string
+	Feature string
+}
+
+// ingressRequirement is of the tc actions redirecting the ingress packets to an ifb device
+var ingressRequirement = optionRequirement{Option: "--direction ingress|both", Feature: node.FeatureIfb}
+
+// unprivilegedExperiments are the targets or the target-actions executed by the runtime api only, they don't enter
+// the namespaces or write the host. The other node actions write the runtime configs and the image filesystem
+var unprivilegedExperiments = map[string]bool{
+	"container":  true,
+	"node-drain": true,
+}
+
+// NodeReport is the capabilities of the node, the operator schedules the experiments onto the nodes supporting them
+type NodeReport struct {
+	Node        *node.Info     `json:"node"`
+	Features    []node.Feature `json:"features"`
+	Experiments []Experiment   `json:"experiments"`
+}
+
+// CheckNode detects the features of the node and derives the usable experiments, nothing is executed in the
+// containers, so it's cheap enough for the operator to poll
+func CheckNode(ctx context.Context, client container.Container) *NodeReport {
+	report := &NodeReport{Node: node.Collect(ctx, client), Features: node.Features()}
+	report.Experiments = nodeMatrix(report.Features)
+	return report
+}
+
+// nodeMatrix derives the experiment support from the node features
+func nodeMatrix(features []node.Feature) []Experiment {
+	available := make(map[string]bool, len(features))
+	for _, feature := range features {
+		available[feature.Name] = feature.Available
+	}
+	experiments := make([]Experiment, 0)
+	for key, executor := range exec.GetAllExecutors() {
+		target := strings.SplitN(key, "-", 2)[0]
+		experiment := Experiment{Name: key, Executor: executor.Name()}
+		if !classified(target, key) {
+			experiment.Missing = []string{CapabilityUndeclared}
+			experiments = append(experiments, experiment)
+			continue
+		}
+		requirements := append(append([]string{}, featureRequirements[target]...), featureRequirements[key]...)
+		if !unprivilegedExperiments[target] && !unprivilegedExperiments[key] {
+			requirements = append([]string{node.FeaturePrivileged}, requirements...)
+		}
+		for _, requirement := range requirements {
+			satisfied := false
+			for _, alternative := range strings.Split(requirement, "|") {
+				satisfied = satisfied || available[alternative]
+			}
+			if !satisfied {
+				experiment.Missing = append(experiment.Missing, requirement)
+			}
+		}
+		for _, option := range optionRequirements[key] {
+			if !available[option.Feature] {
+				experiment.Limits = append(experiment.Limits, fmt.Sprintf("%s requires %s", option.Option, option.Feature))
+			}
+		}
+		experiment.Supported = len(experiment.Missing) == 0
+		experiments = append(experiments, experiment)
+	}
+	sort.Slice(experiments, func(i, j int) bool {
+		return experiments[i].Name < experiments[j].Name
+	})
+	return experiments
+}
+
+// classified returns true if the target or the target-action declares its node requirements
+func classified(target, key string) bool {
+	for _, name := range []string{target, key} {
+		if _, ok := featureRequirements[name]; ok || unprivilegedExperiments[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the node features and the experiment matrix as tables
+func (r *NodeReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Node: %s, kernel: %s, runtime: %s %s, cni: %s\n\n", r.Node.Hostname, r.Node.KernelVersion,
+		r.Node.Runtime, r.Node.RuntimeVersion, r.Node.CNIProvider)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FEATURE\tAVAILABLE\tDETAIL")
+	for _, feature := range r.Features {
+		fmt.Fprintf(tw, "%s\t%t\t%s\n", feature.Name, feature.Available, feature.Detail)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	printExperiments(w, r.Experiments)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/node"
+)
+
+func TestNodeMatrix(t *testing.T) {
+	experiments := nodeMatrix([]node.Feature{
+		{Name: node.FeaturePrivileged, Available: true},
+		{Name: node.FeatureNft, Available: true},
+		{Name: node.FeatureCgroupWritable, Available: true},
+	})
+	expected := map[string]string{
+		"container-remove":  "[]",
+		"cpu-fullload":      "[]",
+		"steal-cpu":         "[]",
+		"network-drop":      "[]",
+		"network-delay":     "[tc]",
+		"network-dns_delay": "[tc u32]",
+		"interface-mtu":     "[]",
+		"interface-mirror":  "[tc]",
+		"conntrack-flush":   "[conntrack]",
+		"gpu-load":          "[nvidia-gpu]",
+		"device-flakey":     "[dmsetup]",
+	}
+	for _, experiment := range experiments {
+		missing, ok := expected[experiment.Name]
+		if !ok {
+			continue
+		}
+		if fmt.Sprint(experiment.Missing) != missing || experiment.Supported != (missing == "[]") {
+			t.Errorf("expected %s missing %s, got %+v", experiment.Name, missing, experiment)
+		}
+	}
+}
+
+func TestNodeMatrixLimits(t *testing.T) {
+	for _, experiment := range nodeMatrix([]node.Feature{
+		{Name: node.FeaturePrivileged, Available: true},
+		{Name: node.FeatureTc, Available: true},
+		{Name: node.FeatureCgroupWritable, Available: true},
+	}) {
+		switch experiment.Name {
+		case "network-delay", "network-loss":
+			if !experiment.Supported || fmt.Sprint(experiment.Limits) != "[--direction ingress|both requires ifb]" {
+				t.Errorf("expected %s supported without the ingress, got %+v", experiment.Name, experiment)
+			}
+		case "cpu-fullload", "network-drop":
+			if len(experiment.Limits) != 0 {
+				t.Errorf("expected %s not limited, got %+v", experiment.Name, experiment)
+			}
+		}
+	}
+}
+
+// TestNodeMatrixClassified fails for the experiment whose target or target-action declares no node requirements, so
+// every new experiment declares what it requires of the node
+func TestNodeMatrixClassified(t *testing.T) {
+	for key := range exec.GetAllExecutors() {
+		if !classified(strings.SplitN(key, "-", 2)[0], key) {
+			t.Errorf("experiment %s is not classified by featureRequirements or unprivilegedExperiments", key)
+		}
+	}
+	defer func(requirements []string) { featureRequirements["sysctl"] = requirements }(featureRequirements["sysctl"])
+	delete(featureRequirements, "sysctl")
+	for _, experiment := range nodeMatrix(nil) {
+		if experiment.Name == "sysctl-set" && (experiment.Supported || fmt.Sprint(experiment.Missing) != "[undeclared]") {
+			t.Errorf("expected the unclassified %s undeclared, got %+v", experiment.Name, experiment)
+		}
+	}
+}
+
+func TestNodeMatrixUnprivileged(t *testing.T) {
+	for _, experiment := range nodeMatrix(nil) {
+		switch {
+		case strings.HasPrefix(experiment.Name, "container-"), experiment.Name == "node-drain":
+			if !experiment.Supported {
+				t.Errorf("experiment %s requires nothing, got %+v", experiment.Name, experiment)
+			}
+		case experiment.Name == "network-drop":
+			if fmt.Sprint(experiment.Missing) != "[privileged iptables|nft]" {
+				t.Errorf("unexpected missing of %s, %v", experiment.Name, experiment.Missing)
+			}
+		case experiment.Supported || experiment.Missing[0] != node.FeaturePrivileged:
+			t.Errorf("experiment %s requires the privileges, got %+v", experiment.Name, experiment)
+		}
+	}
+}
+
+func TestCheckNode(t *testing.T) {
+	report := CheckNode(context.Background(), nil)
+	if report.Node == nil || len(report.Features) == 0 || len(report.Experiments) == 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	var out bytes.Buffer
+	report.Print(&out)
+	if !strings.Contains(out.String(), node.FeaturePrivileged) || !strings.Contains(out.String(), "network-delay") {
+		t.Errorf("unexpected output %s", out.String())
+	}
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+)
+
+// The flags of the conntrack experiment
+const (
+	ConntrackScopeFlag         = "scope"
+	ConntrackProtocolFlag      = "protocol"
+	ConntrackPortFlag          = "port"
+	ConntrackDestinationIpFlag = "destination-ip"
+)
+
+// The scopes of the conntrack entries
+const (
+	// ConntrackScopePod is the table of the network namespace of the pod
+	ConntrackScopePod = "pod"
+	// ConntrackScopeNode is the table of the host network namespace, such as the nat entries of the services
+	ConntrackScopeNode = "node"
+)
+
+// conntrackDeleted matches the summary of conntrack -D, e.g. 3 flow entries have been deleted
+var conntrackDeleted = regexp.MustCompile(`(\d+) flow entr(y has|ies have) been deleted`)
+
+type ConntrackCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewConntrackCommandSpec() spec.ExpModelCommandSpec {
+	return &ConntrackCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&ConntrackFlushActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:    ConntrackScopeFlag,
+								Desc:    "pod deletes the entries in the network namespace of the pod, node deletes the entries of the pod ips in the host network namespace, default is pod",
+								Default: ConntrackScopePod,
+							},
+							&spec.ExpFlag{
+								Name: ConntrackProtocolFlag,
+								Desc: "protocol of the entries, such as tcp and udp, default is all protocols",
+							},
+							&spec.ExpFlag{
+								Name: ConntrackPortFlag,
+								Desc: "original destination port of the entries, the protocol is required",
+							},
+							&spec.ExpFlag{
+								Name: ConntrackDestinationIpFlag,
+								Desc: "original destination ip of the entries",
+							},
+						},
+						ActionExecutor: &conntrackFlushExecutor{},
+						ActionLongDesc: "The conntrack entries of the pod are deleted, like the nat table lost after the " +
+							"networking of the node restarted, so the established connections are reset or re-established. " +
+							"The entries are deleted once by conntrack of the host, the destroy does nothing.",
+						ActionExample: `# Delete all conntrack entries in the network namespace of the pod
+blade create cri conntrack flush --container-id ee54f1e61c08
+
+# Delete the entries of the pod connecting to the port 5432 in the host network namespace, such as the service nat
+blade create cri conntrack flush --scope node --protocol tcp --port 5432 --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*ConntrackCommandModelSpec) Name() string {
+	return "conntrack"
+}
+
+func (*ConntrackCommandModelSpec) ShortDesc() string {
+	return "Conntrack experiment"
+}
+
+func (*ConntrackCommandModelSpec) LongDesc() string {
+	return "Conntrack experiment, the connection tracking entries of the pod flows are deleted by conntrack of the host."
+}
+
+type ConntrackFlushActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*ConntrackFlushActionCommand) Name() string {
+	return "flush"
+}
+
+func (*ConntrackFlushActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*ConntrackFlushActionCommand) ShortDesc() string {
+	return "conntrack entries flush"
+}
+
+func (c *ConntrackFlushActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+// ConntrackResult is the result of the conntrack experiment
+type ConntrackResult struct {
+	ContainerId string `json:"containerId"`
+	Scope       string `json:"scope"`
+	Deleted     int    `json:"deleted"`
+}
+
+type conntrackFlushExecutor struct {
+}
+
+func (e *conntrackFlushExecutor) Name() string {
+	return "conntrack"
+}
+
+func (e *conntrackFlushExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *conntrackFlushExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	// the deleted entries cannot be restored
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return spec.ReturnSuccess(uid)
+	}
+	flags := model.ActionFlags
+	scope := flags[ConntrackScopeFlag]
+	if scope == "" {
+		scope = ConntrackScopePod
+	}
+	if scope != ConntrackScopePod && scope != ConntrackScopeNode {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, ConntrackScopeFlag, scope,
+			fmt.Sprintf("it must be %s or %s", ConntrackScopePod, ConntrackScopeNode))
+	}
+	if flags[ConntrackPortFlag] != "" {
+		if port, err := strconv.Atoi(flags[ConntrackPortFlag]); err != nil || port < 1 || port > 65535 {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, ConntrackPortFlag, flags[ConntrackPortFlag],
+				"it must be a port from 1 to 65535")
+		}
+		if flags[ConntrackProtocolFlag] == "" {
+			return spec.ResponseFailWithFlags(spec.ParameterLess, ConntrackProtocolFlag)
+		}
+	}
+	if ip := flags[ConntrackDestinationIpFlag]; ip != "" && net.ParseIP(ip) == nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, ConntrackDestinationIpFlag, ip, "it is not an ip")
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	containerInfo, response := GetContainer(ctx, client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name],
+		parseContainerLabelSelector(flags[ContainerLabelSelectorFlag.Name]))
+	if !response.Success {
+		return response
+	}
+	// the host network namespace is entered by the pid 1
+	var pid int32 = 1
+	var podIPs []string
+	if scope == ConntrackScopePod {
+		var code int32
+		if pid, err, code = client.GetPidById(ctx, containerInfo.ContainerId); err != nil {
+			return spec.ResponseFail(code, err.Error(), nil)
+		}
+	} else {
+		if len(containerInfo.IPs) == 0 {
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, ConntrackScopeFlag, scope,
+				"the ips of the pod are unknown")
+		}
+		podIPs = containerInfo.IPs
+	}
+	output, err := runInNetns(ctx, pid, conntrackScript(flags, podIPs))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "conntrack", err)
+	}
+	deleted := 0
+	for _, match := range conntrackDeleted.FindAllStringSubmatch(output, -1) {
+		count, _ := strconv.Atoi(match[1])
+		deleted += count
+	}
+	log.Infof(ctx, "%d conntrack entries of container %s are deleted in the %s scope", deleted,
+		containerInfo.ContainerId, scope)
+	return spec.ReturnSuccess(ConntrackResult{ContainerId: containerInfo.ContainerId, Scope: scope, Deleted: deleted})
+}
+
+// conntrackScript deletes the entries of both families matched by the flags, the entries of the pod are the entries
+// whose original source or reply source is a pod ip if the pod ips are given. The exit status of conntrack is ignored
+// since nothing deleted is not a failure, only its absence fails the script
+func conntrackScript(flags map[string]string, podIPs []string) string {
+	filters := make([]string, 0)
+	if protocol := flags[ConntrackProtocolFlag]; protocol != "" {
+		filters = append(filters, "-p", nsexec.Quote(protocol))
+		if port := flags[ConntrackPortFlag]; port != "" {
+			filters = append(filters, "--dport", nsexec.Quote(port))
+		}
+	}
+	destination := net.ParseIP(flags[ConntrackDestinationIpFlag])
+	commands := []string{"command -v conntrack >/dev/null || { echo conntrack not found; exit 127; }"}
+	for _, family := range []string{"ipv4", "ipv6"} {
+		familyFilters := append([]string{"-f", family}, filters...)
+		if destination != nil {
+			if (destination.To4() != nil) != (family == "ipv4") {
+				continue
+			}
+			familyFilters = append(familyFilters, "--orig-dst", destination.String())
+		}
+		if podIPs == nil {
+			commands = append(commands, fmt.Sprintf("conntrack -D %s 2>&1", strings.Join(familyFilters, " ")))
+			continue
+		}
+		for _, ip := range podIPs {
+			parsed := net.ParseIP(ip)
+			if parsed == nil || (parsed.To4() != nil) != (family == "ipv4") {
+				continue
+			}
+			for _, option := range []string{"--orig-src", "--reply-src"} {
+				commands = append(commands, fmt.Sprintf("conntrack -D %s %s %s 2>&1", strings.Join(familyFilters, " "),
+					option, parsed.String()))
+			}
+		}
+	}
+	return strings.Join(commands, "\n") + "\ntrue"
+}
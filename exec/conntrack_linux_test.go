@@ -0,0 +1,102 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+func TestConntrackScript(t *testing.T) {
+	script := conntrackScript(map[string]string{}, nil)
+	expected := "command -v conntrack >/dev/null || { echo conntrack not found; exit 127; }\n" +
+		"conntrack -D -f ipv4 2>&1\nconntrack -D -f ipv6 2>&1\ntrue"
+	if script != expected {
+		t.Errorf("expected %s, got %s", expected, script)
+	}
+	flags := map[string]string{ConntrackProtocolFlag: "tcp", ConntrackPortFlag: "5432", ConntrackDestinationIpFlag: "10.96.0.10"}
+	script = conntrackScript(flags, []string{"10.244.1.5", "fd00::5"})
+	lines := strings.Split(script, "\n")
+	expectedLines := []string{
+		"conntrack -D -f ipv4 -p tcp --dport 5432 --orig-dst 10.96.0.10 --orig-src 10.244.1.5 2>&1",
+		"conntrack -D -f ipv4 -p tcp --dport 5432 --orig-dst 10.96.0.10 --reply-src 10.244.1.5 2>&1",
+	}
+	if len(lines) != 4 || lines[1] != expectedLines[0] || lines[2] != expectedLines[1] {
+		t.Errorf("expected %q, got %q", expectedLines, lines)
+	}
+}
+
+func runConntrack(t *testing.T, client *mock.Container, flags map[string]string) (*spec.Response, []int32) {
+	t.Helper()
+	pids := make([]int32, 0)
+	originRun := runInNetns
+	runInNetns = func(ctx context.Context, pid int32, script string) (string, error) {
+		pids = append(pids, pid)
+		return "conntrack v1.4.6 (conntrack-tools): 3 flow entries have been deleted.\n" +
+			"conntrack v1.4.6 (conntrack-tools): 1 flow entry has been deleted.\n", nil
+	}
+	defer func() { runInNetns = originRun }()
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	flags[ContainerIdFlag.Name] = "c1"
+	model := &spec.ExpModel{Target: "conntrack", ActionName: "flush", ActionFlags: flags}
+	return (&conntrackFlushExecutor{}).Exec("uid1", context.Background(), model), pids
+}
+
+func TestConntrackFlush(t *testing.T) {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1", IPs: []string{"10.244.1.5"}})
+	client.Pids["c1"] = 1234
+	response, pids := runConntrack(t, client, map[string]string{})
+	if !response.Success || len(pids) != 1 || pids[0] != 1234 {
+		t.Fatalf("expected the pod scope flushed, got %+v, %v", response, pids)
+	}
+	if result := response.Result.(ConntrackResult); result.Deleted != 4 || result.Scope != ConntrackScopePod {
+		t.Errorf("expected 4 entries deleted in the pod scope, got %+v", result)
+	}
+	response, pids = runConntrack(t, client, map[string]string{ConntrackScopeFlag: ConntrackScopeNode})
+	if !response.Success || len(pids) != 1 || pids[0] != 1 {
+		t.Errorf("expected the node scope flushed in the host network namespace, got %+v, %v", response, pids)
+	}
+}
+
+func TestConntrackFlushIllegal(t *testing.T) {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	tests := []struct {
+		flags map[string]string
+		code  int32
+	}{
+		{map[string]string{ConntrackScopeFlag: "cluster"}, spec.ParameterIllegal.Code},
+		{map[string]string{ConntrackPortFlag: "80"}, spec.ParameterLess.Code},
+		{map[string]string{ConntrackProtocolFlag: "tcp", ConntrackPortFlag: "0"}, spec.ParameterIllegal.Code},
+		{map[string]string{ConntrackDestinationIpFlag: "db"}, spec.ParameterIllegal.Code},
+		{map[string]string{ConntrackScopeFlag: ConntrackScopeNode}, spec.ParameterInvalid.Code},
+	}
+	for _, test := range tests {
+		response, pids := runConntrack(t, client, test.flags)
+		if response.Success || response.Code != test.code || len(pids) != 0 {
+			t.Errorf("expected the code %d of %v, got %+v, %v", test.code, test.flags, response, pids)
+		}
+	}
+}
@@ -21,10 +21,13 @@ import (
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
 )
 
 const (
-	ForceFlag = "force"
+	ForceFlag       = "force"
+	StopSignalsFlag = "stop-signals"
 )
 
 type ContainerCommandModelSpec struct {
@@ -36,6 +39,7 @@ func NewContainerCommandSpec() spec.ExpModelCommandSpec {
 		spec.BaseExpModelCommandSpec{
 			ExpActions: []spec.ExpActionCommandSpec{
 				NewRemoveActionCommand(),
+				NewNoopActionCommand(),
 			},
 			ExpFlags: []spec.ExpFlagSpec{},
 		},
@@ -68,10 +72,19 @@ func NewRemoveActionCommand() spec.ExpActionCommandSpec {
 					Desc:   "force remove",
 					NoArgs: true,
 				},
+				&spec.ExpFlag{
+					Name: StopSignalsFlag,
+					Desc: "The signals stopping the container before it is removed, each with the grace period to exit, " +
+						"such as SIGTERM:10s,SIGKILL, the grace period is 10s if absent. The signals are sent to the " +
+						"init process of the container, the runtime stops the container if it survives them all",
+				},
 			},
 			ActionExecutor: &removeActionExecutor{},
 			ActionExample: `# Delete the container id that is a76d53933d3f",
-blade create cri container remove --container-id a76d53933d3f. If container-runtime is contained, the container-id shoud be full id`,
+blade create cri container remove --container-id a76d53933d3f. If container-runtime is contained, the container-id shoud be full id
+
+# Send SIGTERM, then SIGKILL if the container does not exit in 30 seconds, and remove it
+blade create cri container remove --stop-signals SIGTERM:30s,SIGKILL --container-id a76d53933d3f`,
 			ActionCategories: []string{CategorySystemContainer},
 		},
 	}
@@ -111,6 +124,13 @@ func (e *removeActionExecutor) Exec(uid string, ctx context.Context, model *spec
 		return spec.ReturnSuccess(uid)
 	}
 	flags := model.ActionFlags
+	var ladder container.StopLadder
+	if value := flags[StopSignalsFlag]; value != "" {
+		var err error
+		if ladder, err = container.ParseStopLadder(value); err != nil {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, StopSignalsFlag, value, err)
+		}
+	}
 	client, err := GetClientByRuntime(model)
 	if err != nil {
 		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
@@ -119,17 +139,33 @@ func (e *removeActionExecutor) Exec(uid string, ctx context.Context, model *spec
 	containerId := flags[ContainerIdFlag.Name]
 	containerName := flags[ContainerNameFlag.Name]
 	containerLabelSelector := parseContainerLabelSelector(flags[ContainerNameFlag.Name])
-	container, response := GetContainer(ctx, client, uid, containerId, containerName, containerLabelSelector)
+	containerInfo, response := GetContainer(ctx, client, uid, containerId, containerName, containerLabelSelector)
 	if !response.Success {
 		return response
 	}
 	forceFlag := flags[ForceFlag]
 
-	err = client.RemoveContainer(ctx, container.ContainerId, judgeForce(forceFlag))
+	var stopped *container.StopResult
+	if ladder != nil {
+		pid, err, code := client.GetPidById(ctx, containerInfo.ContainerId)
+		if err != nil {
+			return spec.ResponseFail(code, err.Error(), nil)
+		}
+		result, err := container.StopByLadder(ctx, pid, ladder)
+		if err != nil {
+			return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "StopByLadder", err)
+		}
+		log.Infof(ctx, "container %s stopped by %s, %+v", containerInfo.ContainerId, ladder, result)
+		stopped = &result
+	}
+	err = client.RemoveContainer(ctx, containerInfo.ContainerId, judgeForce(forceFlag))
 	if err != nil {
 		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("ContainerRemove", err))
 		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "ContainerRemove", err)
 	}
+	if stopped != nil {
+		return spec.ReturnSuccess(stopped)
+	}
 	return spec.ReturnSuccess(uid)
 }
 
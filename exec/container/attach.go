@@ -0,0 +1,53 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"io"
+)
+
+// AttachOptions are the standard streams of the attach, the nil ones are not attached. The stderr is merged into the
+// stdout by the tty
+type AttachOptions struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Tty    bool
+}
+
+// Attacher is implemented by the cri runtimes, it attaches to the standard streams of the main process of a running
+// container, such as feeding the stdin of a process reading its commands, which the exec of a new process cannot
+type Attacher interface {
+	// Attach streams until the process exits, the stdout closes or the context is done. The error is *ExitError if the
+	// process exited with the non-zero code
+	Attach(ctx context.Context, containerId string, options AttachOptions) error
+}
+
+// AsAttacher returns the attacher of the client, the wrappers of the client, such as the breaker, are skipped
+func AsAttacher(c Container) (Attacher, bool) {
+	for {
+		if attacher, ok := c.(Attacher); ok {
+			return attacher, true
+		}
+		wrapper, ok := c.(interface{ Unwrap() Container })
+		if !ok {
+			return nil, false
+		}
+		c = wrapper.Unwrap()
+	}
+}
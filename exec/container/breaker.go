@@ -0,0 +1,320 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	containertype "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// DefaultBreakerThreshold is the number of consecutive runtime failures which opens the breaker
+	DefaultBreakerThreshold = 3
+	// DefaultBreakerProbeInterval is the interval between recovery probes while the breaker is open
+	DefaultBreakerProbeInterval = 10 * time.Second
+)
+
+// breakerNow is the clock of the breakers, replaced in the tests to drive the probe interval
+var breakerNow = time.Now
+
+// runtimeFailureMessages are the error fragments returned by the runtime clients when the endpoint
+// itself is broken, rather than the requested container or command, they are the dial and transport errors only,
+// the deadline of the call is the caller's own
+var runtimeFailureMessages = []string{
+	"connection refused",
+	"connection reset",
+	"transport is closing",
+	"error reading from server",
+	"Cannot connect to the Docker daemon",
+	"Error while dialing",
+}
+
+// RuntimeUnhealthyError is returned without calling the runtime while the breaker is open
+type RuntimeUnhealthyError struct {
+	Endpoint string
+	Since    time.Time
+	Cause    error
+}
+
+func (e *RuntimeUnhealthyError) Error() string {
+	return fmt.Sprintf("runtime %s unhealthy since %s, last error: %v",
+		e.Endpoint, e.Since.Format(time.RFC3339), e.Cause)
+}
+
+func (e *RuntimeUnhealthyError) Unwrap() error {
+	return e.Cause
+}
+
+// Breaker is a circuit breaker for one runtime endpoint. After Threshold consecutive runtime failures it
+// opens and fails fast, letting a single probe call through every ProbeInterval until the runtime recovers.
+type Breaker struct {
+	Endpoint      string
+	Threshold     int
+	ProbeInterval time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openSince time.Time
+	lastProbe time.Time
+	probing   bool
+	lastErr   error
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*Breaker)
+)
+
+// GetBreaker returns the shared breaker of the runtime endpoint
+func GetBreaker(runtime, endpoint string) *Breaker {
+	key := fmt.Sprintf("%s:%s", runtime, endpoint)
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	b, ok := breakers[key]
+	if !ok {
+		b = &Breaker{
+			Endpoint:      key,
+			Threshold:     DefaultBreakerThreshold,
+			ProbeInterval: DefaultBreakerProbeInterval,
+		}
+		breakers[key] = b
+	}
+	return b
+}
+
+// Allow returns a RuntimeUnhealthyError if the call must not reach the runtime.
+// Every allowed call must be followed by Done.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openSince.IsZero() {
+		return nil
+	}
+	if !b.probing && breakerNow().Sub(b.lastProbe) >= b.ProbeInterval {
+		b.probing = true
+		b.lastProbe = breakerNow()
+		return nil
+	}
+	return &RuntimeUnhealthyError{Endpoint: b.Endpoint, Since: b.openSince, Cause: b.lastErr}
+}
+
+// Done records the result of an allowed call
+func (b *Breaker) Done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !IsRuntimeFailure(err) {
+		b.failures = 0
+		b.openSince = time.Time{}
+		b.lastErr = nil
+		return
+	}
+	b.failures++
+	b.lastErr = err
+	if b.failures >= b.Threshold && b.openSince.IsZero() {
+		b.openSince = breakerNow()
+		b.lastProbe = b.openSince
+	}
+}
+
+// Do runs fn under the breaker
+func (b *Breaker) Do(fn func() error) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.Done(err)
+	return err
+}
+
+// Healthy returns false and the time the breaker opened if the runtime is considered unhealthy
+func (b *Breaker) Healthy() (bool, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.openSince.IsZero(), b.openSince
+}
+
+// IsRuntimeFailure returns true if the error means the runtime endpoint is unreachable, the Unavailable of grpc or
+// the dial and transport errors. The deadline and the cancel of the context are the caller's, such as a slow command
+// reaching the timeout of its exec, they never count as runtime failures
+func IsRuntimeFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable:
+			return true
+		case codes.DeadlineExceeded, codes.Canceled:
+			return false
+		}
+	}
+	msg := err.Error()
+	for _, m := range runtimeFailureMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithBreaker wraps the client so that every runtime call passes through the breaker
+func WithBreaker(c Container, b *Breaker) Container {
+	return &breakerContainer{Container: c, breaker: b}
+}
+
+type breakerContainer struct {
+	Container
+	breaker *Breaker
+}
+
+func (c *breakerContainer) Unwrap() Container {
+	return c.Container
+}
+
+func (c *breakerContainer) RuntimeVersion(ctx context.Context) (name, version string, err error) {
+	err = c.breaker.Do(func() error {
+		name, version, err = c.Container.RuntimeVersion(ctx)
+		return err
+	})
+	return name, version, err
+}
+
+func (c *breakerContainer) GetPidById(ctx context.Context, containerId string) (int32, error, int32) {
+	if err := c.breaker.Allow(); err != nil {
+		return -1, err, spec.ContainerExecFailed.Code
+	}
+	pid, err, code := c.Container.GetPidById(ctx, containerId)
+	c.breaker.Done(err)
+	return pid, err, code
+}
+
+func (c *breakerContainer) GetContainerById(ctx context.Context, containerId string) (ContainerInfo, error, int32) {
+	if err := c.breaker.Allow(); err != nil {
+		return ContainerInfo{}, err, spec.ContainerExecFailed.Code
+	}
+	info, err, code := c.Container.GetContainerById(ctx, containerId)
+	c.breaker.Done(err)
+	return info, err, code
+}
+
+func (c *breakerContainer) GetContainerByName(ctx context.Context, containerName string) (ContainerInfo, error, int32) {
+	if err := c.breaker.Allow(); err != nil {
+		return ContainerInfo{}, err, spec.ContainerExecFailed.Code
+	}
+	info, err, code := c.Container.GetContainerByName(ctx, containerName)
+	c.breaker.Done(err)
+	return info, err, code
+}
+
+func (c *breakerContainer) GetContainerByLabelSelector(containerLabelSelector map[string]string) (ContainerInfo, error, int32) {
+	if err := c.breaker.Allow(); err != nil {
+		return ContainerInfo{}, err, spec.ContainerExecFailed.Code
+	}
+	info, err, code := c.Container.GetContainerByLabelSelector(containerLabelSelector)
+	c.breaker.Done(err)
+	return info, err, code
+}
+
+func (c *breakerContainer) ListContainers(ctx context.Context) ([]ContainerInfo, error, int32) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err, spec.ContainerExecFailed.Code
+	}
+	infos, err, code := c.Container.ListContainers(ctx)
+	c.breaker.Done(err)
+	return infos, err, code
+}
+
+func (c *breakerContainer) GetRootfsPath(ctx context.Context, containerId string) (string, error, int32) {
+	if err := c.breaker.Allow(); err != nil {
+		return "", err, spec.ContainerExecFailed.Code
+	}
+	rootfs, err, code := c.Container.GetRootfsPath(ctx, containerId)
+	c.breaker.Done(err)
+	return rootfs, err, code
+}
+
+func (c *breakerContainer) GetCgroupPath(ctx context.Context, containerId string) (CgroupPath, error, int32) {
+	if err := c.breaker.Allow(); err != nil {
+		return CgroupPath{}, err, spec.ContainerExecFailed.Code
+	}
+	cgroupPath, err, code := c.Container.GetCgroupPath(ctx, containerId)
+	c.breaker.Done(err)
+	return cgroupPath, err, code
+}
+
+// ListProcesses is not guarded itself, the cgroup and the exec calls of it are guarded by the breaker
+func (c *breakerContainer) ListProcesses(ctx context.Context, containerId string) ([]Process, error, int32) {
+	return ListProcesses(ctx, c, containerId)
+}
+
+// Watch is guarded on the subscription only, the failure of the event stream closes the channel
+func (c *breakerContainer) Watch(ctx context.Context, filter EventFilter) (<-chan Event, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	events, err := c.Container.Watch(ctx, filter)
+	c.breaker.Done(err)
+	return events, err
+}
+
+func (c *breakerContainer) RemoveContainer(ctx context.Context, containerId string, force bool) error {
+	return c.breaker.Do(func() error {
+		return c.Container.RemoveContainer(ctx, containerId, force)
+	})
+}
+
+func (c *breakerContainer) CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string,
+	override bool) (extractPath string, err error) {
+	err = c.breaker.Do(func() error {
+		extractPath, err = c.Container.CopyToContainer(ctx, containerId, srcFile, dstPath, extractDirName, override)
+		return err
+	})
+	return extractPath, err
+}
+
+func (c *breakerContainer) ExecContainer(ctx context.Context, containerId, command string) (output string, err error) {
+	err = c.breaker.Do(func() error {
+		output, err = c.Container.ExecContainer(ctx, containerId, command)
+		return err
+	})
+	return output, err
+}
+
+func (c *breakerContainer) ExecuteAndRemove(ctx context.Context, config *containertype.Config, hostConfig *containertype.HostConfig,
+	networkConfig *network.NetworkingConfig, containerName string, removed bool, timeout time.Duration,
+	command string, containerInfo ContainerInfo) (containerId string, output string, err error, code int32) {
+	if err := c.breaker.Allow(); err != nil {
+		return "", "", err, spec.ContainerExecFailed.Code
+	}
+	containerId, output, err, code = c.Container.ExecuteAndRemove(ctx, config, hostConfig, networkConfig,
+		containerName, removed, timeout, command, containerInfo)
+	c.breaker.Done(err)
+	return containerId, output, err, code
+}
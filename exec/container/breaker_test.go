@@ -0,0 +1,142 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsRuntimeFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		failure bool
+	}{
+		{"nil", nil, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"wrapped deadline", fmt.Errorf("exec in container c1, %w", context.DeadlineExceeded), false},
+		{"canceled", fmt.Errorf("exec in container c1, %w", context.Canceled), false},
+		{"grpc unavailable", status.Error(codes.Unavailable, "connection error"), true},
+		{"grpc deadline", status.Error(codes.DeadlineExceeded, "context deadline exceeded"), false},
+		{"exec timeout", errors.New("exec in container c1 timed out: context deadline exceeded"), false},
+		{"grpc not found", status.Error(codes.NotFound, "container c1 not found"), false},
+		{"connection refused", errors.New("dial unix /run/containerd/containerd.sock: connect: connection refused"), true},
+		{"docker daemon", errors.New("Cannot connect to the Docker daemon at unix:///var/run/docker.sock"), true},
+		{"dialing", errors.New(`rpc error: code = Unknown desc = Error while dialing dial unix: no such file`), true},
+		{"not found", errors.New("No such container: c1"), false},
+		{"command", CommandError(errors.New("exit status 1"), "tc: command not found"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if failure := IsRuntimeFailure(tt.err); failure != tt.failure {
+				t.Errorf("expect runtime failure %t, but got %t", tt.failure, failure)
+			}
+		})
+	}
+}
+
+// TestBreakerTransitions drives the breaker with a fake clock, it opens after the threshold, lets one probe through
+// after the interval, stays open if the probe fails and closes after the probe succeeds
+func TestBreakerTransitions(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer func(clock func() time.Time) { breakerNow = clock }(breakerNow)
+	breakerNow = func() time.Time { return now }
+	refused := errors.New("connect: connection refused")
+	b := &Breaker{Endpoint: "containerd:/run/containerd/containerd.sock", Threshold: 3, ProbeInterval: 10 * time.Second}
+
+	// the failures not of the runtime and the success reset the count
+	b.Do(func() error { return refused })
+	b.Do(func() error { return refused })
+	b.Do(func() error { return errors.New("No such container: c1") })
+	b.Do(func() error { return refused })
+	b.Do(func() error { return refused })
+	if healthy, _ := b.Healthy(); !healthy {
+		t.Fatalf("expect closed before the consecutive threshold")
+	}
+
+	// open
+	b.Do(func() error { return refused })
+	healthy, since := b.Healthy()
+	if healthy || !since.Equal(now) {
+		t.Fatalf("expect open since %s, but got %t, %s", now, healthy, since)
+	}
+	called := false
+	err := b.Do(func() error { called = true; return nil })
+	var unhealthy *RuntimeUnhealthyError
+	if called || !errors.As(err, &unhealthy) || !errors.Is(err, refused) || !unhealthy.Since.Equal(now) {
+		t.Fatalf("expect the call failed fast by the open breaker, but got called %t, %v", called, err)
+	}
+
+	// half open, one probe is let through after the interval and the concurrent calls still fail fast
+	now = now.Add(10 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expect the probe allowed after the interval, but got %v", err)
+	}
+	if err := b.Allow(); err == nil {
+		t.Fatalf("expect only one probe allowed")
+	}
+	b.Done(refused)
+	if healthy, _ := b.Healthy(); healthy {
+		t.Fatalf("expect still open after the failed probe")
+	}
+	now = now.Add(5 * time.Second)
+	if err := b.Allow(); err == nil {
+		t.Fatalf("expect the next probe waits for the interval since the last probe")
+	}
+
+	// closed after the probe succeeds
+	now = now.Add(5 * time.Second)
+	if err := b.Do(func() error { return nil }); err != nil {
+		t.Fatalf("expect the probe allowed, but got %v", err)
+	}
+	if healthy, since := b.Healthy(); !healthy || !since.IsZero() {
+		t.Fatalf("expect closed after the probe succeeds, but got %t since %s", healthy, since)
+	}
+	b.Do(func() error { return refused })
+	if healthy, _ := b.Healthy(); !healthy {
+		t.Errorf("expect the failure count reset after closed")
+	}
+}
+
+func TestWithBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer func(clock func() time.Time) { breakerNow = clock }(breakerNow)
+	breakerNow = func() time.Time { return now }
+	refused := errors.New("connect: connection refused")
+	flaky := &flakyContainer{errs: []error{refused, refused}}
+	client := WithBreaker(flaky, &Breaker{Endpoint: "docker", Threshold: 2, ProbeInterval: time.Second})
+	for i := 0; i < 4; i++ {
+		client.ExecContainer(context.Background(), "c1", "true")
+	}
+	if flaky.calls != 2 {
+		t.Errorf("expect the runtime called twice before the breaker opens, but got %d", flaky.calls)
+	}
+	now = now.Add(time.Second)
+	if output, err := client.ExecContainer(context.Background(), "c1", "true"); err != nil || output != "ok" || flaky.calls != 3 {
+		t.Errorf("expect the probe reaches the runtime, but got %q, %v after %d calls", output, err, flaky.calls)
+	}
+	if unwrapped := client.(*breakerContainer).Unwrap(); unwrapped != flaky {
+		t.Errorf("expect unwrapped to the runtime client")
+	}
+}
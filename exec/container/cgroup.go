@@ -0,0 +1,282 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// The cgroup versions
+const (
+	CgroupV1 = 1
+	CgroupV2 = 2
+)
+
+// cgroupRoot is the mount of the cgroup hierarchies on the host
+var cgroupRoot = "/sys/fs/cgroup"
+
+// CgroupPath is the cgroup of the container, the paths are relative to the hierarchies
+type CgroupPath struct {
+	Version int `json:"version"`
+	// Unified is the path in the v2 unified hierarchy
+	Unified string `json:"unified,omitempty"`
+	// Controllers are the paths in the v1 hierarchies keyed by the controller, such as memory and cpuacct
+	Controllers map[string]string `json:"controllers,omitempty"`
+}
+
+// Relative returns the path of the v1 controller, the unified path is returned if the controller is empty
+// or not mounted in v1
+func (c CgroupPath) Relative(controller string) (string, bool) {
+	if controller != "" {
+		if p, ok := c.Controllers[controller]; ok {
+			return p, true
+		}
+		if p, ok := c.Controllers["name="+controller]; ok {
+			return p, true
+		}
+	}
+	if c.Unified != "" {
+		return c.Unified, true
+	}
+	return "", false
+}
+
+// Absolute returns the host path of the controller, the v1 controllers mounted together such as cpu,cpuacct
+// are found by the mounts under the cgroup root
+func (c CgroupPath) Absolute(controller string) (string, error) {
+	if controller != "" {
+		if p, ok := c.Controllers[controller]; ok {
+			if mount, ok := v1Mount(controller); ok {
+				return path.Join(cgroupRoot, mount, p), nil
+			}
+			return path.Join(cgroupRoot, controller, p), nil
+		}
+	}
+	if c.Unified != "" {
+		return path.Join(cgroupRoot, c.Unified), nil
+	}
+	return "", fmt.Errorf("cgroup of the %s controller not found", controller)
+}
+
+// CgroupVersion returns the cgroup version of the host, the hybrid mode is v1
+func CgroupVersion() int {
+	if _, err := os.Stat(path.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		return CgroupV2
+	}
+	return CgroupV1
+}
+
+// ResolveCgroupPath returns the cgroup of the process, the cgroupsPath of the oci spec is used if the process
+// cgroup cannot be read, e.g. the agent is not in the host pid namespace
+func ResolveCgroupPath(pid int32, cgroupsPath string) (CgroupPath, error) {
+	if pid > 0 {
+		cgroupPath, err := ParseCgroupFile(path.Join(procRoot, strconv.Itoa(int(pid)), "cgroup"))
+		if err == nil && (cgroupPath.Unified != "" || len(cgroupPath.Controllers) > 0) {
+			return cgroupPath, nil
+		}
+	}
+	if cgroupsPath == "" {
+		return CgroupPath{}, fmt.Errorf("cgroup of process %d not found", pid)
+	}
+	p := ExpandCgroupsPath(cgroupsPath)
+	if CgroupVersion() == CgroupV2 {
+		return CgroupPath{Version: CgroupV2, Unified: p}, nil
+	}
+	cgroupPath := CgroupPath{Version: CgroupV1, Controllers: make(map[string]string)}
+	for _, controller := range v1Controllers() {
+		cgroupPath.Controllers[controller] = p
+	}
+	return cgroupPath, nil
+}
+
+// ParseCgroupFile parses the /proc/<pid>/cgroup file
+func ParseCgroupFile(file string) (CgroupPath, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return CgroupPath{}, err
+	}
+	defer f.Close()
+	cgroupPath := CgroupPath{Version: CgroupV2, Controllers: make(map[string]string)}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 4:memory:/kubepods/pod1234/c0ffee or 0::/kubepods.slice/cri-containerd-c0ffee.scope
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[1] == "" {
+			cgroupPath.Unified = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			cgroupPath.Controllers[controller] = fields[2]
+			if !strings.HasPrefix(controller, "name=") {
+				cgroupPath.Version = CgroupV1
+			}
+		}
+	}
+	return cgroupPath, scanner.Err()
+}
+
+// ExpandCgroupsPath converts the cgroupsPath of the oci spec to the path in the hierarchy, the systemd format
+// slice:prefix:name is expanded, e.g. kubepods-pod1.slice:crio:c0ffee is
+// /kubepods.slice/kubepods-pod1.slice/crio-c0ffee.scope
+func ExpandCgroupsPath(cgroupsPath string) string {
+	if strings.HasPrefix(cgroupsPath, "/") {
+		return cgroupsPath
+	}
+	parts := strings.Split(cgroupsPath, ":")
+	if len(parts) != 3 {
+		return "/" + cgroupsPath
+	}
+	slice, prefix, name := parts[0], parts[1], parts[2]
+	unit := name
+	if !strings.HasSuffix(name, ".slice") {
+		unit = fmt.Sprintf("%s-%s.scope", prefix, name)
+	}
+	return path.Join(expandSlice(slice), unit)
+}
+
+// expandSlice returns the path of the systemd slice, the parents are the dash separated prefixes
+func expandSlice(slice string) string {
+	if slice == "" || slice == "-.slice" {
+		return "/"
+	}
+	name := strings.TrimSuffix(slice, ".slice")
+	p := "/"
+	prefix := ""
+	for _, component := range strings.Split(name, "-") {
+		if component == "" {
+			continue
+		}
+		prefix += component
+		p = path.Join(p, prefix+".slice")
+		prefix += "-"
+	}
+	return p
+}
+
+// v1Mount returns the mount directory of the v1 controller under the cgroup root
+func v1Mount(controller string) (string, bool) {
+	entries, err := os.ReadDir(cgroupRoot)
+	if err != nil {
+		return "", false
+	}
+	name := strings.TrimPrefix(controller, "name=")
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		for _, mounted := range strings.Split(entry.Name(), ",") {
+			if mounted == name {
+				return entry.Name(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// v1Controllers returns the v1 controllers mounted under the cgroup root
+func v1Controllers() []string {
+	entries, err := os.ReadDir(cgroupRoot)
+	if err != nil {
+		return nil
+	}
+	controllers := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == "unified" {
+			continue
+		}
+		for _, controller := range strings.Split(entry.Name(), ",") {
+			if controller == "systemd" {
+				controller = "name=systemd"
+			}
+			controllers = append(controllers, controller)
+		}
+	}
+	return controllers
+}
+
+// PidsLimit is the pids limit of a cgroup
+type PidsLimit struct {
+	// Dir is the host path of the cgroup
+	Dir     string `json:"dir"`
+	Max     int    `json:"max"`
+	Current int    `json:"current"`
+}
+
+// ReadPidsLimit returns the limit with the least pids left among the cgroup of the controller and its ancestors,
+// such as the pod cgroup limited by the kubelet, it fails if none of them is limited
+func ReadPidsLimit(cgroupPath CgroupPath) (PidsLimit, error) {
+	dir, err := cgroupPath.Absolute("pids")
+	if err != nil {
+		return PidsLimit{}, err
+	}
+	var found PidsLimit
+	for ; strings.HasPrefix(dir, cgroupRoot+"/"); dir = path.Dir(dir) {
+		content, err := os.ReadFile(path.Join(dir, "pids.max"))
+		if err != nil {
+			continue
+		}
+		max, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			// the max is unlimited
+			continue
+		}
+		content, err = os.ReadFile(path.Join(dir, "pids.current"))
+		if err != nil {
+			return PidsLimit{}, err
+		}
+		current, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			return PidsLimit{}, fmt.Errorf("illegal pids.current of %s, %v", dir, err)
+		}
+		if found.Dir == "" || max-current < found.Max-found.Current {
+			found = PidsLimit{Dir: dir, Max: max, Current: current}
+		}
+	}
+	if found.Dir == "" {
+		return PidsLimit{}, fmt.Errorf("the pids of the cgroup are not limited")
+	}
+	return found, nil
+}
+
+// ReadCgroupCounter returns the integer of the first file of the controller readable, such as memory.current of v2
+// and memory.usage_in_bytes of v1
+func ReadCgroupCounter(cgroupPath CgroupPath, controller string, files ...string) (int64, error) {
+	dir, err := cgroupPath.Absolute(controller)
+	if err != nil {
+		return 0, err
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(path.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		value, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("illegal %s of %s, %v", file, dir, err)
+		}
+		return value, nil
+	}
+	return 0, fmt.Errorf("none of %s found in %s", strings.Join(files, ", "), dir)
+}
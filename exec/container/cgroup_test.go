@@ -0,0 +1,160 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func setupCgroupRoot(t *testing.T, dirs ...string) {
+	t.Helper()
+	cgroupRoot = t.TempDir()
+	t.Cleanup(func() { cgroupRoot = "/sys/fs/cgroup" })
+	for _, dir := range dirs {
+		mkdir(t, path.Join(cgroupRoot, dir))
+	}
+}
+
+func writeProcCgroup(t *testing.T, pid, content string) {
+	t.Helper()
+	dir := mkdir(t, path.Join(procRoot, pid))
+	if err := os.WriteFile(path.Join(dir, "cgroup"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpandCgroupsPath(t *testing.T) {
+	tests := []struct {
+		cgroupsPath string
+		expected    string
+	}{
+		{"/kubepods/besteffort/pod1234/c0ffee", "/kubepods/besteffort/pod1234/c0ffee"},
+		{"kubepods-besteffort-pod1234.slice:crio:c0ffee",
+			"/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1234.slice/crio-c0ffee.scope"},
+		{"system.slice:docker:abc", "/system.slice/docker-abc.scope"},
+		{":cri-containerd:abc", "/cri-containerd-abc.scope"},
+		{"user.slice:foo:bar.slice", "/user.slice/bar.slice"},
+		{"kubepods/pod1", "/kubepods/pod1"},
+	}
+	for _, tt := range tests {
+		if p := ExpandCgroupsPath(tt.cgroupsPath); p != tt.expected {
+			t.Errorf("expand %s, expected %s, got %s", tt.cgroupsPath, tt.expected, p)
+		}
+	}
+}
+
+func TestResolveCgroupPathFromProc(t *testing.T) {
+	setupProcRoot(t, "")
+	setupCgroupRoot(t, "cpu,cpuacct", "memory", "systemd")
+	writeProcCgroup(t, "42", "12:memory:/kubepods/pod1/c1\n"+
+		"4:cpu,cpuacct:/kubepods/pod1/c1\n"+
+		"1:name=systemd:/kubepods/pod1/c1\n"+
+		"0::/\n")
+	cgroupPath, err := ResolveCgroupPath(42, "")
+	if err != nil {
+		t.Fatalf("ResolveCgroupPath failed, %v", err)
+	}
+	if cgroupPath.Version != CgroupV1 {
+		t.Errorf("expected v1, got %d", cgroupPath.Version)
+	}
+	if p, _ := cgroupPath.Relative("systemd"); p != "/kubepods/pod1/c1" {
+		t.Errorf("unexpected systemd path %s", p)
+	}
+	if p, err := cgroupPath.Absolute("cpuacct"); err != nil || p != path.Join(cgroupRoot, "cpu,cpuacct", "kubepods/pod1/c1") {
+		t.Errorf("unexpected cpuacct path %s, %v", p, err)
+	}
+	if p, err := cgroupPath.Absolute("memory"); err != nil || p != path.Join(cgroupRoot, "memory", "kubepods/pod1/c1") {
+		t.Errorf("unexpected memory path %s, %v", p, err)
+	}
+
+	writeProcCgroup(t, "43", "0::/kubepods.slice/cri-containerd-c2.scope\n")
+	cgroupPath, err = ResolveCgroupPath(43, "")
+	if err != nil {
+		t.Fatalf("ResolveCgroupPath failed, %v", err)
+	}
+	if cgroupPath.Version != CgroupV2 {
+		t.Errorf("expected v2, got %d", cgroupPath.Version)
+	}
+	if p, err := cgroupPath.Absolute("memory"); err != nil || p != path.Join(cgroupRoot, "kubepods.slice/cri-containerd-c2.scope") {
+		t.Errorf("unexpected unified path %s, %v", p, err)
+	}
+}
+
+func TestResolveCgroupPathFromSpec(t *testing.T) {
+	setupProcRoot(t, "")
+	setupCgroupRoot(t)
+	if err := os.WriteFile(path.Join(cgroupRoot, "cgroup.controllers"), []byte("cpu memory"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cgroupPath, err := ResolveCgroupPath(42, "kubepods-pod1.slice:crio:c1")
+	if err != nil {
+		t.Fatalf("ResolveCgroupPath failed, %v", err)
+	}
+	if cgroupPath.Version != CgroupV2 || cgroupPath.Unified != "/kubepods.slice/kubepods-pod1.slice/crio-c1.scope" {
+		t.Errorf("unexpected cgroup %+v", cgroupPath)
+	}
+
+	setupCgroupRoot(t, "memory", "cpu,cpuacct")
+	cgroupPath, err = ResolveCgroupPath(0, "/kubepods/pod1/c1")
+	if err != nil {
+		t.Fatalf("ResolveCgroupPath failed, %v", err)
+	}
+	if cgroupPath.Version != CgroupV1 || cgroupPath.Controllers["cpuacct"] != "/kubepods/pod1/c1" ||
+		cgroupPath.Controllers["memory"] != "/kubepods/pod1/c1" {
+		t.Errorf("unexpected cgroup %+v", cgroupPath)
+	}
+
+	if _, err := ResolveCgroupPath(42, ""); err == nil {
+		t.Error("expected error without the process cgroup and the cgroups path")
+	}
+}
+
+func TestReadPidsLimit(t *testing.T) {
+	setupCgroupRoot(t, "kubepods.slice/kubepods-pod1.slice/cri-containerd-c1.scope")
+	pod := path.Join(cgroupRoot, "kubepods.slice/kubepods-pod1.slice")
+	scope := path.Join(pod, "cri-containerd-c1.scope")
+	files := map[string]string{
+		path.Join(scope, "pids.max"):                            "max\n",
+		path.Join(scope, "pids.current"):                        "12\n",
+		path.Join(pod, "pids.max"):                              "1024\n",
+		path.Join(pod, "pids.current"):                          "30\n",
+		path.Join(cgroupRoot, "kubepods.slice", "pids.max"):     "4096\n",
+		path.Join(cgroupRoot, "kubepods.slice", "pids.current"): "3000\n",
+	}
+	for file, content := range files {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cgroupPath := CgroupPath{Version: CgroupV2, Unified: "/kubepods.slice/kubepods-pod1.slice/cri-containerd-c1.scope"}
+	limit, err := ReadPidsLimit(cgroupPath)
+	if err != nil || limit != (PidsLimit{Dir: pod, Max: 1024, Current: 30}) {
+		t.Errorf("expected the pod limit, got %+v, %v", limit, err)
+	}
+	// the kubepods slice has less pids left
+	if err := os.WriteFile(path.Join(cgroupRoot, "kubepods.slice", "pids.current"), []byte("3900\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if limit, err := ReadPidsLimit(cgroupPath); err != nil || limit.Max != 4096 {
+		t.Errorf("expected the kubepods limit, got %+v, %v", limit, err)
+	}
+	if _, err := ReadPidsLimit(CgroupPath{Version: CgroupV2, Unified: "/system.slice"}); err == nil {
+		t.Error("expected error of the unlimited cgroup")
+	}
+}
@@ -17,12 +17,17 @@ package container
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"path"
 	"time"
 
 	containertype "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/gogo/protobuf/types"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
 )
 
 const (
@@ -37,12 +42,27 @@ const (
 )
 
 type Container interface {
+	// RuntimeVersion returns the name and the version of the connected runtime
+	RuntimeVersion(ctx context.Context) (string, string, error)
 	GetPidById(ctx context.Context, containerId string) (int32, error, int32)
 	GetContainerById(ctx context.Context, containerId string) (ContainerInfo, error, int32)
 	GetContainerByName(ctx context.Context, containerName string) (ContainerInfo, error, int32)
 	GetContainerByLabelSelector(containerLabelSelector map[string]string) (ContainerInfo, error, int32)
+	ListContainers(ctx context.Context) ([]ContainerInfo, error, int32)
+	// GetRootfsPath returns the host path of the container rootfs, see ResolveRootfsPath
+	GetRootfsPath(ctx context.Context, containerId string) (string, error, int32)
+	// GetCgroupPath returns the cgroup of the container, see ResolveCgroupPath
+	GetCgroupPath(ctx context.Context, containerId string) (CgroupPath, error, int32)
+	// ListProcesses returns the processes of the container, see the ListProcesses function
+	ListProcesses(ctx context.Context, containerId string) ([]Process, error, int32)
+	// Watch returns the lifecycle events of the containers matched by the filter, the channel is closed when the
+	// context is done or the event stream of the runtime fails, the caller watches again in that case
+	Watch(ctx context.Context, filter EventFilter) (<-chan Event, error)
 	RemoveContainer(ctx context.Context, containerId string, force bool) error
-	CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) error
+	// CopyToContainer copies the tar.gz archive into dstPath of the container and extracts it there, the archive is
+	// copied by the name of ArchiveTempName and removed after the extraction. It returns the extraction path
+	CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) (
+		extractPath string, err error)
 
 	ExecContainer(ctx context.Context, containerId, command string) (output string, err error)
 	ExecuteAndRemove(ctx context.Context, config *containertype.Config, hostConfig *containertype.HostConfig,
@@ -56,6 +76,70 @@ type ContainerInfo struct {
 	ContainerName string
 	Labels        map[string]string
 	Spec          *types.Any
+	// CreatedAt, StartedAt and FinishedAt are zero if not reported by the runtime
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Mounts are the volumes mounted into the container, see NewMount
+	Mounts []Mount
+	// Attempt is the restart count of the container in the pod, the kubelet increases it on every restart
+	Attempt uint32
+	// IPs are the pod ips of the container, the first is the primary one, empty if not reported by the runtime
+	IPs []string
+	// Ports are the ports declared by the container, see ParsePortsAnnotation
+	Ports []Port
+	// LastTermination is the termination of the previous attempt, nil if not restarted, the previous container is
+	// already removed or the runtime does not keep it, e.g. docker resets the exit code on restart
+	LastTermination *Termination
+	// Annotations are the annotations of the container and of its pod, the container ones take precedence, such as
+	// OpenShiftSCCAnnotation. Only the cri runtimes report them
+	Annotations map[string]string
+	// SELinuxLabel is the process label of the container and MountLabel the label of its files, see FileLabel, they
+	// are empty if selinux is disabled or not reported by the runtime
+	SELinuxLabel string
+	MountLabel   string
+	// Runtime is the runtime discovered by the auto container-runtime, such as cri-dockerd, empty if it is given
+	Runtime string
+}
+
+// SCC returns the security context constraint admitting the pod on openshift, empty elsewhere
+func (c ContainerInfo) SCC() string {
+	return c.Annotations[OpenShiftSCCAnnotation]
+}
+
+// Termination is how a container attempt exited
+type Termination struct {
+	ContainerId string
+	Attempt     uint32
+	ExitCode    int32
+	// Reason is the brief reason reported by the runtime, such as OOMKilled, Error and Completed
+	Reason     string
+	Message    string
+	FinishedAt time.Time
+}
+
+// Uptime returns how long the container has been running, the age since created is used if the start time is not
+// reported, it is zero if the container is finished
+func (c ContainerInfo) Uptime() time.Duration {
+	if !c.FinishedAt.IsZero() && !c.FinishedAt.Before(c.StartedAt) {
+		return 0
+	}
+	start := c.StartedAt
+	if start.IsZero() {
+		start = c.CreatedAt
+	}
+	if start.IsZero() {
+		return 0
+	}
+	return time.Since(start)
+}
+
+// UnixNanoTime converts the unix nanoseconds of the runtime to time, zero if not set
+func UnixNanoTime(nanoseconds int64) time.Time {
+	if nanoseconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanoseconds)
 }
 
 func GetChaosBladeImageRef(repo, version string) string {
@@ -67,3 +151,24 @@ func GetChaosBladeImageRef(repo, version string) string {
 	}
 	return fmt.Sprintf("%s:%s", repo, version)
 }
+
+// ArchiveTempName returns the name the archive is copied by into the container, it is unique to the copy, so the
+// experiments copying the same archive into the container concurrently do not overwrite each other. The uid and the
+// time of the experiment stamped in the context are in the name, so a residue is traced back to the experiment
+func ArchiveTempName(ctx context.Context, srcFile string) string {
+	name := "." + path.Base(srcFile)
+	if stamp, ok := trace.FromContext(ctx); ok {
+		name += "." + stamp.Suffix()
+	}
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s.%d", name, time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%s.%s", name, hex.EncodeToString(suffix))
+}
+
+// ExtractPath returns the path the archive is extracted to in dstPath, the top directory of the archive is the
+// extract dir name
+func ExtractPath(dstPath, extractDirName string) string {
+	return path.Join(dstPath, extractDirName)
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+)
+
+func TestArchiveTempName(t *testing.T) {
+	ctx := context.Background()
+	first, second := ArchiveTempName(ctx, "/root/chaosblade-1.7.2.tar.gz"), ArchiveTempName(ctx, "/root/chaosblade-1.7.2.tar.gz")
+	if first == second {
+		t.Errorf("expected the temp names unique, got %s twice", first)
+	}
+	if !strings.HasPrefix(first, ".chaosblade-1.7.2.tar.gz.") {
+		t.Errorf("unexpected temp name %s", first)
+	}
+	stamped := ArchiveTempName(trace.WithStamp(ctx, "uid1"), "/root/chaosblade-1.7.2.tar.gz")
+	if !strings.HasPrefix(stamped, ".chaosblade-1.7.2.tar.gz.uid1-") {
+		t.Errorf("expected the uid in the temp name, got %s", stamped)
+	}
+	if path := ExtractPath("/opt", "chaosblade-1.7.2"); path != "/opt/chaosblade-1.7.2" {
+		t.Errorf("unexpected extract path %s", path)
+	}
+}
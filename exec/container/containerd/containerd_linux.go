@@ -21,23 +21,30 @@ import (
 	"errors"
 	"fmt"
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"path"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/credential"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
 	"github.com/containerd/containerd"
+	apievents "github.com/containerd/containerd/api/events"
 	"github.com/containerd/containerd/cio"
 	"github.com/containerd/containerd/containers"
 	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/events"
 	"github.com/containerd/containerd/namespaces"
 	"github.com/containerd/containerd/oci"
 	ctrdutil "github.com/containerd/containerd/pkg/cri/util"
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
 	"github.com/containerd/containerd/runtime/v2/runc/options"
 	"github.com/containerd/containerd/snapshots"
+	"github.com/containerd/typeurl"
 	containertype "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -57,14 +64,22 @@ const (
 	DefaultSnapshotter = "overlayfs"
 
 	DefaultContainerdNS = "k8s.io"
+	// NamespaceAuto selects the namespace containing the target container, see SelectNamespace
+	NamespaceAuto = "auto"
 
 	NetworkNsType = "network"
 )
 
-var cli *Client
+// clients are the cached clients by the namespace, the default namespace of the client is the one of the calls
+// without the namespace in the context
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*Client)
+)
 
 type Client struct {
-	cclient *containerd.Client
+	cclient   *containerd.Client
+	namespace string
 
 	Ctx    context.Context
 	Cancel context.CancelFunc
@@ -72,7 +87,12 @@ type Client struct {
 }
 
 func NewClient(endpoint, namespace string) (*Client, error) {
-	if cli != nil {
+	if namespace == "" || namespace == NamespaceAuto {
+		namespace = DefaultContainerdNS
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if cli, ok := clients[namespace]; ok {
 		if ok, _ := cli.cclient.IsServing(cli.Ctx); ok {
 			return cli, nil
 		}
@@ -81,9 +101,6 @@ func NewClient(endpoint, namespace string) (*Client, error) {
 	if endpoint == "" {
 		endpoint = DefaultUinxAddress
 	}
-	if namespace == "" {
-		namespace = DefaultContainerdNS
-	}
 	cclient, err := containerd.New(endpoint, containerd.WithDefaultNamespace(namespace))
 	if err != nil {
 		return nil, err
@@ -94,15 +111,46 @@ func NewClient(endpoint, namespace string) (*Client, error) {
 	)
 	ctx = namespaces.WithNamespace(ctx, namespace)
 	ctx, cancel = context.WithCancel(ctx)
-	cli = &Client{
-		cclient: cclient,
-		connMu:  sync.Mutex{},
-		Ctx:     ctx,
-		Cancel:  cancel,
-	}
+	cli := &Client{
+		cclient:   cclient,
+		namespace: namespace,
+		connMu:    sync.Mutex{},
+		Ctx:       ctx,
+		Cancel:    cancel,
+	}
+	clients[namespace] = cli
 	return cli, nil
 }
 
+// CloseClient closes the cached containerd clients
+func CloseClient() error {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	var errs []string
+	for namespace, cli := range clients {
+		cli.Cancel()
+		if err := cli.cclient.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		delete(clients, namespace)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (c *Client) RuntimeVersion(ctx context.Context) (string, string, error) {
+	if c.cclient == nil {
+		return "", "", errors.New("containerd client is not available")
+	}
+	version, err := c.cclient.Version(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return container.ContainerdRuntime, version.Version, nil
+}
+
 func (c *Client) GetPidById(ctx context.Context, containerId string) (int32, error, int32) {
 
 	container, err := c.cclient.LoadContainer(ctx, containerId)
@@ -117,6 +165,140 @@ func (c *Client) GetPidById(ctx context.Context, containerId string) (int32, err
 	return int32(task.Pid()), nil, spec.OK.Code
 }
 
+// GetRootfsPath returns the rootfs mounted in the task bundle, the snapshotter decides how it is resolved
+func (c *Client) GetRootfsPath(ctx context.Context, containerId string) (string, error, int32) {
+	if c.cclient == nil {
+		return "", errors.New("containerd client is not available"), spec.ContainerExecFailed.Code
+	}
+	containerDetail, err := c.cclient.ContainerService().Get(c.Ctx, containerId)
+	if err != nil {
+		return "", err, spec.ContainerExecFailed.Code
+	}
+	namespace, err := namespaces.NamespaceRequired(c.Ctx)
+	if err != nil {
+		namespace = DefaultContainerdNS
+	}
+	hint := container.RootfsHint{
+		Driver: containerDetail.Snapshotter,
+		Path:   path.Join(DefaultStateDir, "io.containerd.runtime.v2.task", namespace, containerId, "rootfs"),
+	}
+	// the pid is only used for the fallback, the rootfs of a stopped container can still be resolved
+	if pid, err, _ := c.GetPidById(ctx, containerId); err == nil {
+		hint.Pid = pid
+	}
+	rootfs, err := container.ResolveRootfsPath(hint)
+	if err != nil {
+		return "", fmt.Errorf("%v for container %s", err, containerId), spec.ContainerExecFailed.Code
+	}
+	return rootfs, nil, spec.OK.Code
+}
+
+// GetCgroupPath returns the cgroup of the task, the cgroupsPath of the spec is used if the task cgroup cannot be read
+func (c *Client) GetCgroupPath(ctx context.Context, containerId string) (container.CgroupPath, error, int32) {
+	if c.cclient == nil {
+		return container.CgroupPath{}, errors.New("containerd client is not available"), spec.ContainerExecFailed.Code
+	}
+	containerDetail, err := c.cclient.ContainerService().Get(c.Ctx, containerId)
+	if err != nil {
+		return container.CgroupPath{}, err, spec.ContainerExecFailed.Code
+	}
+	var cgroupsPath string
+	if containerDetail.Spec != nil {
+		var s specs.Spec
+		if err := json.Unmarshal(containerDetail.Spec.Value, &s); err == nil && s.Linux != nil {
+			cgroupsPath = s.Linux.CgroupsPath
+		}
+	}
+	var pid int32
+	if p, err, _ := c.GetPidById(ctx, containerId); err == nil {
+		pid = p
+	}
+	cgroupPath, err := container.ResolveCgroupPath(pid, cgroupsPath)
+	if err != nil {
+		return container.CgroupPath{}, fmt.Errorf("%v for container %s", err, containerId), spec.ContainerExecFailed.Code
+	}
+	return cgroupPath, nil, spec.OK.Code
+}
+
+// Watch subscribes the container and the task events of the namespace, the exits of the exec processes are skipped
+func (c *Client) Watch(ctx context.Context, filter container.EventFilter) (<-chan container.Event, error) {
+	namespace, err := namespaces.NamespaceRequired(c.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	envelopes, errs := c.cclient.Subscribe(ctx,
+		fmt.Sprintf(`namespace==%s,topic=="/containers/create"`, namespace),
+		fmt.Sprintf(`namespace==%s,topic=="/containers/delete"`, namespace),
+		fmt.Sprintf(`namespace==%s,topic=="/tasks/start"`, namespace),
+		fmt.Sprintf(`namespace==%s,topic=="/tasks/exit"`, namespace),
+	)
+	sender, events := container.NewEventSender(ctx, filter)
+	go func() {
+		defer sender.Close()
+		// the labels are cached for the delete events, the deleted containers cannot be loaded
+		labels := make(map[string]map[string]string)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errs:
+				if err != nil && ctx.Err() == nil {
+					log.Warnf(ctx, "the containerd event stream failed, %v", err)
+				}
+				return
+			case envelope := <-envelopes:
+				event, ok := c.convertEvent(envelope, labels)
+				if ok && !sender.Send(event) {
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (c *Client) convertEvent(envelope *events.Envelope, labels map[string]map[string]string) (container.Event, bool) {
+	if envelope == nil || envelope.Event == nil {
+		return container.Event{}, false
+	}
+	decoded, err := typeurl.UnmarshalAny(envelope.Event)
+	if err != nil {
+		return container.Event{}, false
+	}
+	event := container.Event{Time: envelope.Timestamp}
+	switch e := decoded.(type) {
+	case *apievents.ContainerCreate:
+		event.Type, event.ContainerId = container.EventCreated, e.ID
+	case *apievents.ContainerDelete:
+		event.Type, event.ContainerId = container.EventDeleted, e.ID
+	case *apievents.TaskStart:
+		event.Type, event.ContainerId = container.EventStarted, e.ContainerID
+	case *apievents.TaskExit:
+		if e.ID != e.ContainerID {
+			return container.Event{}, false
+		}
+		event.Type, event.ContainerId, event.Time = container.EventStopped, e.ContainerID, e.ExitedAt
+	default:
+		return container.Event{}, false
+	}
+	if _, ok := labels[event.ContainerId]; !ok && event.Type != container.EventDeleted {
+		if containerDetail, err := c.cclient.ContainerService().Get(c.Ctx, event.ContainerId); err == nil {
+			labels[event.ContainerId] = containerDetail.Labels
+		}
+	}
+	event.Labels = labels[event.ContainerId]
+	event.ContainerName = containerName(c.namespace, event.Labels)
+	if event.Type == container.EventDeleted {
+		delete(labels, event.ContainerId)
+	}
+	return event, true
+}
+
+// ListProcesses returns the processes of the container
+func (c *Client) ListProcesses(ctx context.Context, containerId string) ([]container.Process, error, int32) {
+	return container.ListProcesses(ctx, c, containerId)
+}
+
 func (c *Client) GetContainerById(ctx context.Context, containerId string) (container.ContainerInfo, error, int32) {
 	if c.cclient == nil {
 		return container.ContainerInfo{}, errors.New("containerd client is not available"), spec.ContainerExecFailed.Code
@@ -127,18 +309,21 @@ func (c *Client) GetContainerById(ctx context.Context, containerId string) (cont
 		return container.ContainerInfo{}, err, spec.ContainerExecFailed.Code
 	}
 
-	return convertContainerInfo(containerDetail), nil, spec.OK.Code
+	return c.convertContainerInfo(containerDetail), nil, spec.OK.Code
 }
 
+// GetContainerByName returns the container by the name label of the namespace, see NameLabels
 func (c *Client) GetContainerByName(ctx context.Context, containerName string) (container.ContainerInfo, error, int32) {
-	// containerd have not name. so maybe it is not usefull
-	filters := []string{fmt.Sprintf("runtime,name==%s", containerName)}
-	containerDetails, err := c.cclient.ContainerService().List(c.Ctx, filters...)
+	containerDetails, err := c.cclient.ContainerService().List(c.Ctx, nameFilters(c.namespace, containerName)...)
 	if err != nil {
 		return container.ContainerInfo{}, err, spec.ContainerExecFailed.Code
 	}
+	if len(containerDetails) == 0 {
+		return container.ContainerInfo{}, fmt.Errorf("container named %s not found in the containerd namespace %s",
+			containerName, c.namespace), spec.ContainerExecFailed.Code
+	}
 
-	return convertContainerInfo(containerDetails[0]), nil, spec.OK.Code
+	return c.convertContainerInfo(containerDetails[0]), nil, spec.OK.Code
 }
 
 func (c *Client) GetContainerByLabelSelector(labels map[string]string) (container.ContainerInfo, error, int32) {
@@ -153,18 +338,60 @@ func (c *Client) GetContainerByLabelSelector(labels map[string]string) (containe
 		return container.ContainerInfo{}, err, spec.ContainerExecFailed.Code
 	}
 
-	return convertContainerInfo(containerDetails[0]), nil, spec.OK.Code
+	return c.convertContainerInfo(containerDetails[0]), nil, spec.OK.Code
+}
+
+func (c *Client) ListContainers(ctx context.Context) ([]container.ContainerInfo, error, int32) {
+	containerDetails, err := c.cclient.ContainerService().List(c.Ctx)
+	if err != nil {
+		return nil, err, spec.ContainerExecFailed.Code
+	}
+	containerInfos := make([]container.ContainerInfo, 0, len(containerDetails))
+	for _, containerDetail := range containerDetails {
+		containerInfos = append(containerInfos, c.convertContainerInfo(containerDetail))
+	}
+	return containerInfos, nil, spec.OK.Code
 }
 
-func convertContainerInfo(containerDetail containers.Container) container.ContainerInfo {
+func (c *Client) convertContainerInfo(containerDetail containers.Container) container.ContainerInfo {
 	return container.ContainerInfo{
 		ContainerId:   containerDetail.ID,
-		ContainerName: containerDetail.Labels["io.kubernetes.container.name"],
+		ContainerName: containerName(c.namespace, containerDetail.Labels),
 		//Env:             spec.Process.Env,
-		Labels: containerDetail.Labels,
-		Spec:   containerDetail.Spec,
+		Labels:    containerDetail.Labels,
+		Spec:      containerDetail.Spec,
+		CreatedAt: containerDetail.CreatedAt,
+		Mounts:    convertMounts(containerDetail),
+		// the pod ips and the declared ports are only known by the cri plugin, use the cri client for them
 	}
 }
+
+// convertMounts returns the bind mounts of the spec, the pseudo filesystems such as proc are skipped
+func convertMounts(containerDetail containers.Container) []container.Mount {
+	mounts := make([]container.Mount, 0)
+	if containerDetail.Spec == nil {
+		return mounts
+	}
+	var s specs.Spec
+	if err := json.Unmarshal(containerDetail.Spec.Value, &s); err != nil {
+		return mounts
+	}
+	for _, mount := range s.Mounts {
+		bind, readOnly := mount.Type == "bind", false
+		for _, option := range mount.Options {
+			switch option {
+			case "bind", "rbind":
+				bind = true
+			case "ro":
+				readOnly = true
+			}
+		}
+		if bind {
+			mounts = append(mounts, container.NewMount(mount.Destination, mount.Source, readOnly))
+		}
+	}
+	return mounts
+}
 func (c *Client) RemoveContainer(ctx context.Context, containerId string, force bool) error {
 	err := c.cclient.ContainerService().Delete(c.Ctx, containerId)
 	if err == nil {
@@ -178,16 +405,16 @@ func (c *Client) RemoveContainer(ctx context.Context, containerId string, force
 	return err
 }
 
-func (c *Client) CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) error {
+func (c *Client) CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) (string, error) {
 
 	containerDetail, err := c.cclient.LoadContainer(c.Ctx, containerId)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	task, err := containerDetail.Task(c.Ctx, nil)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	processId := task.Pid()
@@ -203,6 +430,22 @@ func (c *Client) ExecContainer(ctx context.Context, containerId, command string)
 	return container.ExecContainer(ctx, id, command)
 }
 
+// pullResolver returns the resolver of the image with its credential, see credential.Lookup, the registry of the
+// image is the only host of the pull. The identity token is sent as the secret without the username
+func pullResolver(ctx context.Context, image string) remotes.Resolver {
+	auth := credential.Lookup(ctx, image)
+	return docker.NewResolver(docker.ResolverOptions{
+		Hosts: docker.ConfigureDefaultRegistries(docker.WithAuthorizer(docker.NewDockerAuthorizer(
+			docker.WithAuthCreds(func(host string) (string, string, error) {
+				if auth.Username == "" {
+					return "", auth.IdentityToken, nil
+				}
+				return auth.Username, auth.Password, nil
+			}),
+		))),
+	})
+}
+
 // ExecuteAndRemove: create and start a container for executing a command, and remove the container
 func (c *Client) ExecuteAndRemove(ctx context.Context, config *containertype.Config, hostConfig *containertype.HostConfig,
 	networkConfig *network.NetworkingConfig, containerName string, removed bool, timeout time.Duration,
@@ -224,7 +467,8 @@ func (c *Client) ExecuteAndRemove(ctx context.Context, config *containertype.Con
 		return "", "", fmt.Errorf(spec.CreateContainerFailed.Sprintf("target container network namespace path is nil")), spec.CreateContainerFailed.Code
 	}
 
-	if _, err := c.cclient.Pull(c.Ctx, config.Image, containerd.WithPullUnpack, containerd.WithPullSnapshotter(snapshotter)); err != nil {
+	if _, err := c.cclient.Pull(c.Ctx, config.Image, containerd.WithPullUnpack, containerd.WithPullSnapshotter(snapshotter),
+		containerd.WithResolver(pullResolver(ctx, config.Image))); err != nil {
 		return "", "", fmt.Errorf(spec.ImagePullFailed.Sprintf(config.Image, err.Error())), spec.ImagePullFailed.Code
 	}
 
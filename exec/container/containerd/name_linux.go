@@ -0,0 +1,58 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package containerd
+
+import "fmt"
+
+// The labels of the container names, containerd has no names
+const (
+	// KubernetesNameLabel is the name of the container in the pod, set by the cri plugin
+	KubernetesNameLabel = "io.kubernetes.container.name"
+	// NerdctlNameLabel is the name given by nerdctl run --name, or generated by nerdctl
+	NerdctlNameLabel = "nerdctl/name"
+)
+
+// NameLabels returns the labels of the container names in the namespace by precedence, the containers in the
+// namespace of the cri plugin are named by kubernetes, the ones in the other namespaces, such as default of the
+// standalone containerd hosts, by nerdctl first
+func NameLabels(namespace string) []string {
+	if namespace == DefaultContainerdNS {
+		return []string{KubernetesNameLabel, NerdctlNameLabel}
+	}
+	return []string{NerdctlNameLabel, KubernetesNameLabel}
+}
+
+// containerName returns the name of the container of the labels, empty if it is not named
+func containerName(namespace string, labels map[string]string) string {
+	for _, label := range NameLabels(namespace) {
+		if name := labels[label]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// nameFilters returns the filters of the container service listing the containers of the name, any of the filters
+// matches
+func nameFilters(namespace, name string) []string {
+	labels := NameLabels(namespace)
+	filters := make([]string, 0, len(labels))
+	for _, label := range labels {
+		filters = append(filters, fmt.Sprintf(`labels."%s"==%s`, label, name))
+	}
+	return filters
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/containerd/containerd/namespaces"
+)
+
+// KnownNamespaces are the namespaces of the well-known clients of containerd, the cri plugin of kubernetes, dockerd
+// and ctr or nerdctl, they are searched first
+var KnownNamespaces = []string{DefaultContainerdNS, "moby", "default"}
+
+// Target is the container the namespace is selected by, the id is preferred to the name and the name to the labels,
+// the name is the one of the name labels of every namespace
+type Target struct {
+	Id     string
+	Name   string
+	Labels map[string]string
+}
+
+// IsEmpty returns true if the target selects no container
+func (t Target) IsEmpty() bool {
+	return t.Id == "" && t.Name == "" && len(t.Labels) == 0
+}
+
+func (t Target) String() string {
+	switch {
+	case t.Id != "":
+		return "container " + t.Id
+	case t.Name != "":
+		return "container named " + t.Name
+	default:
+		return fmt.Sprintf("container labeled %v", t.Labels)
+	}
+}
+
+// filters returns the filters of the container service listing the target in the namespace, any of the filters
+// matches
+func (t Target) filters(namespace string) []string {
+	switch {
+	case t.Id != "":
+		return []string{fmt.Sprintf("id==%s", t.Id)}
+	case t.Name != "":
+		return nameFilters(namespace, t.Name)
+	default:
+		filters := make([]string, 0, len(t.Labels))
+		for k, v := range t.Labels {
+			filters = append(filters, fmt.Sprintf(`labels."%s"==%s`, k, v))
+		}
+		sort.Strings(filters)
+		return []string{strings.Join(filters, ",")}
+	}
+}
+
+// Namespaces returns the namespaces of containerd, the known ones first and the others sorted
+func (c *Client) Namespaces(ctx context.Context) ([]string, error) {
+	listed, err := c.cclient.NamespaceService().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list the containerd namespaces failed, %v", err)
+	}
+	return orderNamespaces(listed), nil
+}
+
+// FindNamespaces returns the namespaces containing the target and the namespaces searched
+func (c *Client) FindNamespaces(ctx context.Context, target Target) ([]string, []string, error) {
+	searched, err := c.Namespaces(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	found := make([]string, 0, 1)
+	for _, namespace := range searched {
+		containers, err := c.cclient.ContainerService().List(namespaces.WithNamespace(ctx, namespace),
+			target.filters(namespace)...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("list the containers of the namespace %s failed, %v", namespace, err)
+		}
+		if len(containers) > 0 {
+			found = append(found, namespace)
+		}
+	}
+	return found, searched, nil
+}
+
+// SelectNamespace returns the only namespace containing the target, DefaultContainerdNS if there is no target. It
+// fails if the target is in none or several of the namespaces, the namespace must be selected by the flag then
+func SelectNamespace(ctx context.Context, endpoint string, target Target) (string, error) {
+	if target.IsEmpty() {
+		return DefaultContainerdNS, nil
+	}
+	client, err := NewClient(endpoint, DefaultContainerdNS)
+	if err != nil {
+		return "", err
+	}
+	found, searched, err := client.FindNamespaces(ctx, target)
+	if err != nil {
+		return "", err
+	}
+	namespace, err := pickNamespace(target, found, searched)
+	if err != nil {
+		return "", err
+	}
+	log.Infof(ctx, "the %s is found in the containerd namespace %s", target, namespace)
+	return namespace, nil
+}
+
+// pickNamespace returns the only namespace found
+func pickNamespace(target Target, found, searched []string) (string, error) {
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("%s not found in the containerd namespaces %v", target, searched)
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("%s found in several containerd namespaces %v, select one by container-namespace",
+			target, found)
+	}
+}
+
+// orderNamespaces returns the known namespaces listed first in their order, then the others sorted
+func orderNamespaces(listed []string) []string {
+	others := make(map[string]bool, len(listed))
+	for _, namespace := range listed {
+		others[namespace] = true
+	}
+	ordered := make([]string, 0, len(listed))
+	for _, namespace := range KnownNamespaces {
+		if others[namespace] {
+			ordered = append(ordered, namespace)
+			delete(others, namespace)
+		}
+	}
+	rest := make([]string, 0, len(others))
+	for namespace := range others {
+		rest = append(rest, namespace)
+	}
+	sort.Strings(rest)
+	return append(ordered, rest...)
+}
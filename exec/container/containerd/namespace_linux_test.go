@@ -0,0 +1,93 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package containerd
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOrderNamespaces(t *testing.T) {
+	ordered := orderNamespaces([]string{"zeta", "default", "buildkit", "k8s.io"})
+	expected := []string{"k8s.io", "default", "buildkit", "zeta"}
+	if !reflect.DeepEqual(ordered, expected) {
+		t.Errorf("expected %v, got %v", expected, ordered)
+	}
+}
+
+func TestPickNamespace(t *testing.T) {
+	target := Target{Id: "ee54f1e61c08"}
+	searched := []string{"k8s.io", "moby", "default"}
+	if namespace, err := pickNamespace(target, []string{"default"}, searched); err != nil || namespace != "default" {
+		t.Errorf("expected default, got %s, %v", namespace, err)
+	}
+	if _, err := pickNamespace(target, nil, searched); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if _, err := pickNamespace(target, []string{"k8s.io", "default"}, searched); err == nil ||
+		!strings.Contains(err.Error(), "container-namespace") {
+		t.Errorf("expected ambiguous, got %v", err)
+	}
+}
+
+func TestTargetFilters(t *testing.T) {
+	tests := []struct {
+		target   Target
+		expected string
+	}{
+		{Target{Id: "abc", Name: "nginx"}, "id==abc"},
+		{Target{Labels: map[string]string{"b": "2", "a": "1"}}, `labels."a"==1,labels."b"==2`},
+	}
+	for _, tt := range tests {
+		if filters := tt.target.filters("default"); len(filters) != 1 || filters[0] != tt.expected {
+			t.Errorf("expected %s, got %v", tt.expected, filters)
+		}
+	}
+}
+
+func TestSelectNamespaceWithoutTarget(t *testing.T) {
+	// the runtime is not connected without the target
+	namespace, err := SelectNamespace(context.Background(), "/nonexistent/containerd.sock", Target{})
+	if err != nil || namespace != DefaultContainerdNS {
+		t.Errorf("expected %s, got %s, %v", DefaultContainerdNS, namespace, err)
+	}
+}
+
+func TestContainerName(t *testing.T) {
+	labels := map[string]string{KubernetesNameLabel: "app", NerdctlNameLabel: "nerdctl-app"}
+	if name := containerName(DefaultContainerdNS, labels); name != "app" {
+		t.Errorf("expected the kubernetes name in %s, got %s", DefaultContainerdNS, name)
+	}
+	if name := containerName("default", labels); name != "nerdctl-app" {
+		t.Errorf("expected the nerdctl name in default, got %s", name)
+	}
+	if name := containerName("default", map[string]string{KubernetesNameLabel: "app"}); name != "app" {
+		t.Errorf("expected the kubernetes name without the nerdctl one, got %s", name)
+	}
+	if name := containerName("moby", nil); name != "" {
+		t.Errorf("expected no name, got %s", name)
+	}
+}
+
+func TestNameFilters(t *testing.T) {
+	expected := []string{`labels."nerdctl/name"==web`, `labels."io.kubernetes.container.name"==web`}
+	if filters := (Target{Name: "web"}).filters("default"); !reflect.DeepEqual(filters, expected) {
+		t.Errorf("expected %v, got %v", expected, filters)
+	}
+}
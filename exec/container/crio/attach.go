@@ -0,0 +1,224 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+
+	"golang.org/x/net/websocket"
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// The websocket protocols of the streaming server of the runtimes, v5 closes the stdin, v4 of the runtimes before
+// kubernetes 1.30 cannot
+const (
+	streamProtocolV5 = "v5.channel.k8s.io"
+	streamProtocolV4 = "v4.channel.k8s.io"
+)
+
+// The channels of the streaming protocols, every message is prefixed by its channel
+const (
+	stdinChannel  = 0
+	stdoutChannel = 1
+	stderrChannel = 2
+	errorChannel  = 3
+	closeChannel  = 255
+)
+
+// stdinChunk is the max size of the stdin messages
+const stdinChunk = 32 << 10
+
+// streamStatus is the status written to the error channel when the process exits, the exit code is a cause of the
+// failure
+type streamStatus struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Details *struct {
+		Causes []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"causes"`
+	} `json:"details"`
+}
+
+// Attach 调用 Attach 获取流式服务的 url, 以 websocket 连接流式服务, 与 kubectl attach 相同, 直到进程退出, 连接关闭或 ctx 结束
+func (c *CRIClient) Attach(ctx context.Context, containerId string, options container.AttachOptions) error {
+	if options.Stdin == nil && options.Stdout == nil && options.Stderr == nil {
+		return errors.New("one of the stdin, the stdout and the stderr must be attached")
+	}
+	response, err := c.runtimeService.Attach(ctx, &v1.AttachRequest{
+		ContainerId: containerId,
+		Stdin:       options.Stdin != nil,
+		Tty:         options.Tty,
+		Stdout:      options.Stdout != nil,
+		// tty 的 stderr 合并到 stdout
+		Stderr: options.Stderr != nil && !options.Tty,
+	})
+	if err != nil {
+		return fmt.Errorf("attach container %s failed, %v", containerId, err)
+	}
+	return stream(ctx, response.Url, options)
+}
+
+// stream 连接流式服务的 url, 转发标准流, 返回错误通道的状态
+func stream(ctx context.Context, rawURL string, options container.AttachOptions) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || !target.IsAbs() {
+		return fmt.Errorf("illegal streaming url %s", rawURL)
+	}
+	location := *target
+	switch target.Scheme {
+	case "http":
+		location.Scheme = "ws"
+	case "https":
+		location.Scheme = "wss"
+	default:
+		return fmt.Errorf("unsupported scheme of the streaming url %s", rawURL)
+	}
+	config, err := websocket.NewConfig(location.String(), rawURL)
+	if err != nil {
+		return err
+	}
+	config.Protocol = []string{streamProtocolV5, streamProtocolV4}
+	conn, err := dialStream(ctx, target)
+	if err != nil {
+		return fmt.Errorf("connect the streaming server %s failed, %v", target.Host, err)
+	}
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connect the streaming server %s failed, %v", target.Host, err)
+	}
+	defer ws.Close()
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// ctx 结束时关闭连接以中断读取
+	go func() {
+		<-streamCtx.Done()
+		ws.Close()
+	}()
+	if options.Stdin != nil {
+		go sendStdin(ws, options.Stdin, ws.Config().Protocol[0] == streamProtocolV5)
+	}
+	status := make([]byte, 0)
+	for {
+		var message []byte
+		if err := websocket.Message.Receive(ws, &message); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("receive from the streaming server failed, %v", err)
+		}
+		// 流式服务打开通道时发送只有通道号的消息
+		if len(message) < 2 {
+			continue
+		}
+		switch message[0] {
+		case stdoutChannel:
+			if options.Stdout != nil {
+				options.Stdout.Write(message[1:])
+			}
+		case stderrChannel:
+			if options.Stderr != nil {
+				options.Stderr.Write(message[1:])
+			}
+		case errorChannel:
+			status = append(status, message[1:]...)
+		}
+	}
+	return statusError(status)
+}
+
+// dialStream 在 ctx 内连接流式服务, https 的服务以系统 CA 校验
+func dialStream(ctx context.Context, target *url.URL) (net.Conn, error) {
+	address := target.Host
+	if target.Port() == "" {
+		port := "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(target.Hostname(), port)
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil || target.Scheme != "https" {
+		return conn, err
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: target.Hostname(), MinVersion: tls.VersionTLS12})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// sendStdin 将 stdin 分块发送到 stdin 通道, v5 在 stdin 结束时关闭其通道, v4 无法通知进程
+func sendStdin(ws *websocket.Conn, stdin io.Reader, closable bool) {
+	buffer := make([]byte, stdinChunk+1)
+	buffer[0] = stdinChannel
+	for {
+		n, err := stdin.Read(buffer[1:])
+		if n > 0 {
+			if err := websocket.Message.Send(ws, buffer[:n+1]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if closable {
+		websocket.Message.Send(ws, []byte{closeChannel, stdinChannel})
+	}
+}
+
+// statusError 解析错误通道的状态, 非零的退出码返回 *container.ExitError
+func statusError(content []byte) error {
+	if len(content) == 0 {
+		return nil
+	}
+	status := streamStatus{}
+	if err := json.Unmarshal(content, &status); err != nil {
+		return fmt.Errorf("illegal status of the streaming server %s", content)
+	}
+	if status.Status == "Success" {
+		return nil
+	}
+	if status.Details != nil {
+		for _, cause := range status.Details.Causes {
+			if cause.Reason != "ExitCode" {
+				continue
+			}
+			if code, err := strconv.Atoi(cause.Message); err == nil {
+				return &container.ExitError{Code: code}
+			}
+		}
+	}
+	return errors.New(status.Message)
+}
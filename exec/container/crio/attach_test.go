@@ -0,0 +1,117 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio/fake"
+)
+
+func TestAttach(t *testing.T) {
+	client, server := newTestClient(t, testContainers...)
+	server.SetAttachFunc(func(containerId string, stdin io.Reader, stdout, stderr io.Writer) int32 {
+		input, _ := io.ReadAll(stdin)
+		stdout.Write([]byte(containerId + ": " + strings.ToUpper(string(input))))
+		stderr.Write([]byte("done"))
+		return 0
+	})
+	var stdout, stderr strings.Builder
+	err := client.Attach(context.Background(), "c1", container.AttachOptions{Stdin: strings.NewReader("reload\n"),
+		Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("attach failed, %v", err)
+	}
+	// the stdin is closed by v5, so the process reading it to the end exits
+	if stdout.String() != "c1: RELOAD\n" || stderr.String() != "done" {
+		t.Errorf("unexpected output %q, %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestAttachV4(t *testing.T) {
+	client, server := newTestClient(t, testContainers...)
+	server.SetStreamProtocols(fake.StreamProtocolV4)
+	server.SetAttachFunc(func(containerId string, stdin io.Reader, stdout, stderr io.Writer) int32 {
+		line, _ := bufio.NewReader(stdin).ReadString('\n')
+		stdout.Write([]byte(line))
+		stderr.Write([]byte("dropped"))
+		return 3
+	})
+	var stdout strings.Builder
+	err := client.Attach(context.Background(), "c2", container.AttachOptions{Stdin: strings.NewReader("quit\n"),
+		Stdout: &stdout, Stderr: io.Discard, Tty: true})
+	var exitErr *container.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("expected the exit code 3, got %v", err)
+	}
+	// the stderr is merged into the stdout by the tty
+	if stdout.String() != "quit\n" {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+}
+
+func TestAttachCancel(t *testing.T) {
+	client, server := newTestClient(t, testContainers...)
+	server.SetStreamProtocols(fake.StreamProtocolV4)
+	server.SetAttachFunc(func(containerId string, stdin io.Reader, stdout, stderr io.Writer) int32 {
+		// the stdin of v4 is never closed, it blocks until the connection is closed
+		io.ReadAll(stdin)
+		return 0
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := client.Attach(ctx, "c1", container.AttachOptions{Stdin: strings.NewReader("x"),
+		Stdout: io.Discard}); err != context.DeadlineExceeded {
+		t.Errorf("expected the attach cancelled, got %v", err)
+	}
+}
+
+func TestAttachRefused(t *testing.T) {
+	client, _ := newTestClient(t, testContainers...)
+	if err := client.Attach(context.Background(), "missing", container.AttachOptions{Stdout: io.Discard}); err == nil {
+		t.Error("expected the missing container refused")
+	}
+	if err := client.Attach(context.Background(), "c1", container.AttachOptions{}); err == nil {
+		t.Error("expected the attach without streams refused")
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	if err := statusError(nil); err != nil {
+		t.Errorf("expected no status succeeded, got %v", err)
+	}
+	if err := statusError([]byte(`{"metadata":{},"status":"Success"}`)); err != nil {
+		t.Errorf("expected success, got %v", err)
+	}
+	err := statusError([]byte(`{"status":"Failure","message":"command terminated with non-zero exit code: 137",` +
+		`"reason":"NonZeroExitCode","details":{"causes":[{"reason":"ExitCode","message":"137"}]}}`))
+	var exitErr *container.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 137 {
+		t.Errorf("expected the exit code 137, got %v", err)
+	}
+	if err := statusError([]byte(`{"status":"Failure","message":"container not running"}`)); err == nil ||
+		err.Error() != "container not running" {
+		t.Errorf("expected the failure message, got %v", err)
+	}
+}
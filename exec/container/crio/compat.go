@@ -0,0 +1,196 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// The runtime capabilities checked by CheckCapabilities
+const (
+	// CapabilityVerboseInfo 容器详细信息中包含进程 pid
+	CapabilityVerboseInfo = "verbose-info"
+	// CapabilityExecSync 支持 ExecSync 并返回完整输出
+	CapabilityExecSync = "exec-sync"
+	// CapabilityExecSyncTimeout ExecSync 遵守超时时间
+	CapabilityExecSyncTimeout = "exec-sync-timeout"
+	// CapabilityStreamingExec 支持流式 Exec
+	CapabilityStreamingExec = "streaming-exec"
+	// CapabilityAttach 支持 Attach 容器的标准流
+	CapabilityAttach = "attach"
+	// CapabilityCheckpoint 支持容器 checkpoint
+	CapabilityCheckpoint = "checkpoint"
+)
+
+const (
+	compatExecOutputSize  = 1 << 20
+	compatExecTimeout     = time.Second
+	compatExecSyncTimeout = 10 * time.Second
+	compatCheckpointId    = "chaosblade-compat-check"
+	checkpointMethod      = "/runtime.v1.RuntimeService/CheckpointContainer"
+)
+
+// Capability 运行时对某项能力的支持情况
+type Capability struct {
+	Name      string `json:"name"`
+	Supported bool   `json:"supported"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// RuntimeVersion 返回运行时的名称和版本
+func (c *CRIClient) RuntimeVersion(ctx context.Context) (string, string, error) {
+	response, err := c.runtimeService.Version(ctx, &v1.VersionRequest{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get runtime version: %v", err)
+	}
+	return response.RuntimeName, response.RuntimeVersion, nil
+}
+
+// CheckCapabilities 在指定的运行中容器上逐项检测运行时能力, 检测不会修改容器
+func (c *CRIClient) CheckCapabilities(ctx context.Context, containerId string) []Capability {
+	return []Capability{
+		c.checkVerboseInfo(ctx, containerId),
+		c.checkExecSync(ctx, containerId),
+		c.checkExecSyncTimeout(ctx, containerId),
+		c.checkStreamingExec(ctx, containerId),
+		c.checkAttach(ctx, containerId),
+		c.checkCheckpoint(ctx),
+	}
+}
+
+func (c *CRIClient) checkVerboseInfo(ctx context.Context, containerId string) Capability {
+	capability := Capability{Name: CapabilityVerboseInfo}
+	response, err := c.runtimeService.ContainerStatus(ctx, &v1.ContainerStatusRequest{ContainerId: containerId, Verbose: true})
+	if err != nil {
+		capability.Detail = err.Error()
+		return capability
+	}
+	info, ok := response.Info["info"]
+	if !ok {
+		capability.Detail = "no info in the verbose status"
+		return capability
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(info), &fields); err != nil {
+		capability.Detail = fmt.Sprintf("info is not json, %v", err)
+		return capability
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pid, _ := fields["pid"].(float64)
+	capability.Supported = pid > 0
+	capability.Detail = fmt.Sprintf("fields: %s", strings.Join(keys, ","))
+	return capability
+}
+
+func (c *CRIClient) checkExecSync(ctx context.Context, containerId string) Capability {
+	capability := Capability{Name: CapabilityExecSync}
+	response, err := c.runtimeService.ExecSync(ctx, &v1.ExecSyncRequest{
+		ContainerId: containerId,
+		Cmd:         []string{"sh", "-c", fmt.Sprintf("head -c %d /dev/zero", compatExecOutputSize)},
+		Timeout:     int64(compatExecSyncTimeout.Seconds()),
+	})
+	if err != nil {
+		capability.Detail = err.Error()
+		return capability
+	}
+	if response.ExitCode != 0 {
+		capability.Detail = fmt.Sprintf("exit code %d, %s", response.ExitCode, response.Stderr)
+		return capability
+	}
+	// 部分运行时会截断 ExecSync 的输出
+	capability.Supported = len(response.Stdout) == compatExecOutputSize
+	capability.Detail = fmt.Sprintf("returned %d of %d bytes", len(response.Stdout), compatExecOutputSize)
+	return capability
+}
+
+func (c *CRIClient) checkExecSyncTimeout(ctx context.Context, containerId string) Capability {
+	capability := Capability{Name: CapabilityExecSyncTimeout}
+	start := time.Now()
+	_, err := c.runtimeService.ExecSync(ctx, &v1.ExecSyncRequest{
+		ContainerId: containerId,
+		Cmd:         []string{"sleep", "5"},
+		Timeout:     int64(compatExecTimeout.Seconds()),
+	})
+	elapsed := time.Since(start)
+	capability.Supported = elapsed < 3*compatExecTimeout
+	capability.Detail = fmt.Sprintf("returned after %s with timeout %s", elapsed.Round(time.Millisecond), compatExecTimeout)
+	if err != nil {
+		capability.Detail += fmt.Sprintf(", %v", err)
+	}
+	return capability
+}
+
+func (c *CRIClient) checkStreamingExec(ctx context.Context, containerId string) Capability {
+	capability := Capability{Name: CapabilityStreamingExec}
+	response, err := c.runtimeService.Exec(ctx, &v1.ExecRequest{
+		ContainerId: containerId,
+		Cmd:         []string{"true"},
+		Stdout:      true,
+	})
+	if err != nil {
+		capability.Detail = err.Error()
+		return capability
+	}
+	capability.Supported = response.Url != ""
+	capability.Detail = response.Url
+	return capability
+}
+
+// checkAttach 只获取 Attach 的 url 而不连接, 未使用的 url 由流式服务过期清理
+func (c *CRIClient) checkAttach(ctx context.Context, containerId string) Capability {
+	capability := Capability{Name: CapabilityAttach}
+	response, err := c.runtimeService.Attach(ctx, &v1.AttachRequest{
+		ContainerId: containerId,
+		Stdout:      true,
+	})
+	if err != nil {
+		capability.Detail = err.Error()
+		return capability
+	}
+	capability.Supported = response.Url != ""
+	capability.Detail = response.Url
+	return capability
+}
+
+// checkCheckpoint 调用 CheckpointContainer 检查是否实现, cri-api v0.20 没有该接口的消息定义,
+// RemoveContainerRequest 与 CheckpointContainerRequest 的 container_id 同为字段 1, 编码兼容,
+// 使用不存在的容器 id, 返回 Unimplemented 以外的错误即表示支持
+func (c *CRIClient) checkCheckpoint(ctx context.Context) Capability {
+	capability := Capability{Name: CapabilityCheckpoint}
+	err := c.conn.Invoke(ctx, checkpointMethod, &v1.RemoveContainerRequest{ContainerId: compatCheckpointId}, &v1.RemoveContainerResponse{})
+	if err == nil {
+		capability.Supported = true
+		return capability
+	}
+	s, _ := status.FromError(err)
+	capability.Supported = s.Code() != codes.Unimplemented
+	capability.Detail = s.Message()
+	return capability
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"gopkg.in/yaml.v2"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+)
+
+const (
+	// DefaultCrictlConfig 是 crictl 的默认配置文件
+	DefaultCrictlConfig = "/etc/crictl.yaml"
+	// CrictlConfigEnv 指定 crictl 配置文件, 与 crictl 使用相同的环境变量
+	CrictlConfigEnv = "CRI_CONFIG_FILE"
+)
+
+// CrictlConfig 是 crictl 配置文件中客户端使用的部分, 未配置的字段为零值
+type CrictlConfig struct {
+	RuntimeEndpoint string
+	// ImageEndpoint 为空时与 RuntimeEndpoint 相同
+	ImageEndpoint string
+	// Timeout 是连接超时
+	Timeout time.Duration
+}
+
+// ParseCrictlConfig 解析 crictl 配置, timeout 是秒数或 Go duration, 例如 2 或 500ms
+func ParseCrictlConfig(content []byte) (CrictlConfig, error) {
+	var raw struct {
+		RuntimeEndpoint string `yaml:"runtime-endpoint"`
+		ImageEndpoint   string `yaml:"image-endpoint"`
+		Timeout         string `yaml:"timeout"`
+	}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return CrictlConfig{}, fmt.Errorf("illegal crictl config, %v", err)
+	}
+	config := CrictlConfig{RuntimeEndpoint: raw.RuntimeEndpoint, ImageEndpoint: raw.ImageEndpoint}
+	if raw.Timeout != "" {
+		timeout, err := durations.Parse(raw.Timeout)
+		if err != nil {
+			return CrictlConfig{}, fmt.Errorf("illegal timeout of the crictl config, %v", err)
+		}
+		config.Timeout = timeout
+	}
+	return config, nil
+}
+
+// LoadCrictlConfig 读取 CrictlConfigEnv 或 DefaultCrictlConfig 指定的 crictl 配置, 文件不存在或非法时返回零值,
+// 非法时记录警告, 客户端使用默认配置
+func LoadCrictlConfig(ctx context.Context) CrictlConfig {
+	file := os.Getenv(CrictlConfigEnv)
+	if file == "" {
+		file = DefaultCrictlConfig
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf(ctx, "read the crictl config %s failed, %v", file, err)
+		}
+		return CrictlConfig{}
+	}
+	config, err := ParseCrictlConfig(content)
+	if err != nil {
+		log.Warnf(ctx, "%v, %s is ignored", err, file)
+		return CrictlConfig{}
+	}
+	return config
+}
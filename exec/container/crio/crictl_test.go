@@ -0,0 +1,106 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio/fake"
+)
+
+func TestParseCrictlConfig(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected CrictlConfig
+	}{
+		{"runtime-endpoint: unix:///run/containerd/containerd.sock\nimage-endpoint: unix:///run/containerd/containerd.sock\n" +
+			"timeout: 10\ndebug: false\npull-image-on-create: false\n",
+			CrictlConfig{RuntimeEndpoint: "unix:///run/containerd/containerd.sock",
+				ImageEndpoint: "unix:///run/containerd/containerd.sock", Timeout: 10 * time.Second}},
+		{"runtime-endpoint: unix:///var/run/crio/crio.sock\ntimeout: 500ms\n",
+			CrictlConfig{RuntimeEndpoint: "unix:///var/run/crio/crio.sock", Timeout: 500 * time.Millisecond}},
+		{"", CrictlConfig{}},
+	}
+	for _, tt := range tests {
+		config, err := ParseCrictlConfig([]byte(tt.content))
+		if err != nil || config != tt.expected {
+			t.Errorf("expected %+v of %q, got %+v, %v", tt.expected, tt.content, config, err)
+		}
+	}
+	for _, illegal := range []string{"timeout: soon\n", "runtime-endpoint: [unix\n"} {
+		if _, err := ParseCrictlConfig([]byte(illegal)); err == nil {
+			t.Errorf("expected %q illegal", illegal)
+		}
+	}
+}
+
+func TestLoadCrictlConfig(t *testing.T) {
+	file := path.Join(t.TempDir(), "crictl.yaml")
+	t.Setenv(CrictlConfigEnv, file)
+	if config := LoadCrictlConfig(context.Background()); config != (CrictlConfig{}) {
+		t.Errorf("expected no config without the file, got %+v", config)
+	}
+	os.WriteFile(file, []byte("timeout: never\n"), 0644)
+	if config := LoadCrictlConfig(context.Background()); config != (CrictlConfig{}) {
+		t.Errorf("expected the illegal config ignored, got %+v", config)
+	}
+}
+
+func TestNewClientByCrictlConfig(t *testing.T) {
+	runtimeServer, imageServer := fake.NewServer(fake.Container{Id: "c1", Name: "app",
+		State: v1.ContainerState_CONTAINER_RUNNING}), fake.NewServer()
+	runtimeEndpoint, err := runtimeServer.Start()
+	if err != nil {
+		t.Fatalf("start fake cri server failed, %v", err)
+	}
+	defer runtimeServer.Stop()
+	imageEndpoint, err := imageServer.Start()
+	if err != nil {
+		t.Fatalf("start fake cri server failed, %v", err)
+	}
+	defer imageServer.Stop()
+	file := path.Join(t.TempDir(), "crictl.yaml")
+	os.WriteFile(file, []byte(fmt.Sprintf("runtime-endpoint: %s\nimage-endpoint: %s\ntimeout: 5\n", runtimeEndpoint,
+		imageEndpoint)), 0644)
+	t.Setenv(CrictlConfigEnv, file)
+
+	CloseClient()
+	defer CloseClient()
+	client, err := NewClient("", "")
+	if err != nil {
+		t.Fatalf("connect by the crictl config failed, %v", err)
+	}
+	if containers, err, _ := client.ListContainers(context.Background()); err != nil || len(containers) != 1 {
+		t.Errorf("expected the containers of the runtime endpoint, got %v, %v", containers, err)
+	}
+	client.imageService.ImageStatus(context.Background(), &v1.ImageStatusRequest{Image: &v1.ImageSpec{Image: "busybox"}})
+	if calls := imageServer.Calls(); len(calls) != 1 || calls[0] != "ImageStatus" {
+		t.Errorf("expected the image calls served by the image endpoint, got %v", calls)
+	}
+	for _, call := range runtimeServer.Calls() {
+		if call == "ImageStatus" {
+			t.Errorf("expected no image calls served by the runtime endpoint")
+		}
+	}
+}
@@ -11,38 +11,119 @@ import (
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/strslice"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio/fixture"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/credential"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
 )
 
 const (
 	DefaultStateUinxAddress    = "unix:///var/run/crio/crio.sock"
 	DefaultContainerdNameSpace = "k8s.io"
+
+	connectionTimeout = 2 * time.Second
+
+	// containerdBundleDir 是 containerd 的 task bundle 目录, containerd 的 runtimeSpec.root.path 是相对于 bundle 的
+	containerdBundleDir = "/run/containerd/io.containerd.runtime.v2.task"
+
+	// RecordFixtureEnv is the fixture file path which the cri interactions are recorded to if set
+	RecordFixtureEnv = "CHAOSBLADE_CRI_RECORD"
 )
 
-var cli *CRIClient
+// clientKey 区分缓存的客户端, 不同的 endpoint, 命名空间或 tls 配置使用各自的连接
+type clientKey struct {
+	endpoint      string
+	imageEndpoint string
+	namespace     string
+	tls           TLSOptions
+}
+
+var (
+	// clients 是已建立连接的客户端, agent 的请求并发获取客户端, 由 clientsMu 保护
+	clients   = make(map[clientKey]*CRIClient)
+	clientsMu sync.Mutex
+)
+
+var (
+	// RemoveStopTimeout 删除容器前停止容器的超时时间, 超时后容器被强制停止
+	RemoveStopTimeout = 15 * time.Second
+	// HelperStopTimeout ExecuteAndRemove 停止辅助容器的超时时间
+	HelperStopTimeout = 10 * time.Second
+)
+
+// stopTimeout 返回 StopContainerRequest 的秒数, 不足一秒的向上取整
+func stopTimeout(timeout time.Duration) int64 {
+	return int64(durations.Seconds(timeout))
+}
 
-// NewClient 创建与 crio 的客户端连接
+// CRIClient 是 cri 运行时的客户端
 type CRIClient struct {
 	runtimeService v1.RuntimeServiceClient
 	conn           *grpc.ClientConn
 	imageService   v1.ImageServiceClient
 	Ctx            context.Context
 	Cancel         context.CancelFunc
+	// imageConn 是 crictl 配置了不同的 image-endpoint 时镜像服务的连接
+	imageConn *grpc.ClientConn
+	// runtimeAPI 是运行时连接协商的 api 版本, 镜像服务的连接沿用, 使用已建立的连接创建的客户端为 nil, 即 v1
+	runtimeAPI *apiNegotiator
 }
 
+// NewClient 创建与 crio 的客户端连接, endpoint 为空时使用 crictl 配置的 runtime-endpoint 和 image-endpoint, 都未配置时
+// 使用发现的提供 cri 服务的已知 socket, 见 container.DiscoverySockets. crictl 配置的 timeout 是连接超时
 func NewClient(endpoint string, namespace string) (*CRIClient, error) {
-	dialOptions := []grpc.DialOption{
-		grpc.WithInsecure(), // 可以考虑使用安全连接
-		grpc.WithBlock(),
-	}
+	return NewClientWithTLS(endpoint, namespace, TLSOptions{})
+}
 
+// NewClientWithTLS 与 NewClient 相同, 使用 tls 连接 tcp 暴露的 endpoint, 例如 tcp://10.0.0.1:10010, runtime-endpoint 和
+// image-endpoint 使用相同的 tls 配置
+func NewClientWithTLS(endpoint string, namespace string, tlsOptions TLSOptions) (*CRIClient, error) {
+	config := LoadCrictlConfig(context.Background())
+	imageEndpoint := ""
 	if endpoint == "" {
-		endpoint = DefaultStateUinxAddress
+		endpoint, imageEndpoint = config.RuntimeEndpoint, config.ImageEndpoint
 	}
 	if namespace == "" {
 		namespace = DefaultContainerdNameSpace
 	}
+	// 发现的 endpoint 由空的 endpoint 缓存, 复用时不再探测已知 socket
+	key := clientKey{endpoint: endpoint, imageEndpoint: imageEndpoint, namespace: namespace, tls: tlsOptions}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	// 复用已建立的连接, 已断开的连接被关闭后重新建立
+	if cached, ok := clients[key]; ok {
+		if state := cached.conn.GetState(); state != connectivity.Shutdown && state != connectivity.TransientFailure {
+			return cached, nil
+		}
+		cached.Cancel()
+		cached.Close()
+		delete(clients, key)
+	}
+	if endpoint == "" {
+		endpoint = discoverEndpoint(context.Background())
+	}
+	transport, err := tlsOptions.transportOption()
+	if err != nil {
+		return nil, err
+	}
+	dialOptions := []grpc.DialOption{
+		transport,
+		grpc.WithBlock(),
+	}
+	if file := os.Getenv(RecordFixtureEnv); file != "" {
+		dialOptions = append(dialOptions, fixture.NewFileRecorder(endpoint, file).DialOption())
+	}
+
 	var (
 		ctx    = context.Background()
 		cancel context.CancelFunc
@@ -50,29 +131,144 @@ func NewClient(endpoint string, namespace string) (*CRIClient, error) {
 	ctx = namespaces.WithNamespace(ctx, namespace)
 	ctx, cancel = context.WithCancel(ctx)
 
-	conn, err := grpc.DialContext(ctx, endpoint, dialOptions...)
+	timeout := connectionTimeout
+	if config.Timeout > 0 {
+		timeout = config.Timeout
+	}
+	runtimeAPI := &apiNegotiator{}
+	conn, err := dial(ctx, endpoint, timeout, append(dialOptions[:len(dialOptions):len(dialOptions)],
+		runtimeAPI.dialOptions()...))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	client := newClientFromConn(ctx, cancel, conn)
+	client.runtimeAPI = runtimeAPI
+	negotiateAPI(ctx, runtimeAPI, timeout, func(ctx context.Context) error {
+		_, err := client.runtimeService.Version(ctx, &v1.VersionRequest{})
+		return err
+	})
+	if imageEndpoint != "" && imageEndpoint != endpoint {
+		// 镜像服务没有 Version, 其 endpoint 通常由同一运行时提供, 沿用运行时服务协商的版本
+		imageConn, err := dial(ctx, imageEndpoint, timeout, append(dialOptions[:len(dialOptions):len(dialOptions)],
+			runtimeAPI.dialOptions()...))
+		if err != nil {
+			client.Cancel()
+			conn.Close()
+			return nil, err
+		}
+		client.imageConn, client.imageService = imageConn, v1.NewImageServiceClient(imageConn)
+	}
+	clients[key] = client
+	return client, nil
+}
+
+// negotiateAPI 在连接超时内协商 api 版本
+func negotiateAPI(ctx context.Context, negotiator *apiNegotiator, timeout time.Duration,
+	probe func(ctx context.Context) error) {
+	negotiateCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	negotiator.negotiate(negotiateCtx, probe)
+}
+
+// APIVersion 返回与运行时协商的 cri api 版本, v1 或 v1alpha2
+func (c *CRIClient) APIVersion() string {
+	return c.runtimeAPI.Version()
+}
+
+// dial 连接 endpoint, 不会因无响应的 endpoint 永久阻塞
+func dial(ctx context.Context, endpoint string, timeout time.Duration, dialOptions []grpc.DialOption) (*grpc.ClientConn, error) {
+	dialCtx, dialCancel := context.WithTimeout(ctx, timeout)
+	defer dialCancel()
+	conn, err := grpc.DialContext(dialCtx, dialTarget(endpoint), dialOptions...)
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return nil, fmt.Errorf("failed to connect to crio endpoint %s: %w", endpoint, ctx.Err())
+		if dialCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("failed to connect to crio endpoint %s: %w", endpoint, dialCtx.Err())
 		}
 		return nil, fmt.Errorf("failed to connect to crio endpoint %s: %v", endpoint, err.Error())
 	}
+	return conn, nil
+}
+
+// Probe 调用 endpoint 的 Version 检查其是否提供 cri 服务, 例如未启用 cri 插件的 dockerd 的 containerd 不提供, 只提供
+// v1alpha2 的运行时同样可用, 连接不被缓存
+func Probe(ctx context.Context, endpoint string) error {
+	negotiator := &apiNegotiator{}
+	conn, err := dial(ctx, endpoint, connectionTimeout, append([]grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock()},
+		negotiator.dialOptions()...))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
 	runtimeService := v1.NewRuntimeServiceClient(conn)
-	imageService := v1.NewImageServiceClient(conn)
+	version := func(ctx context.Context) error {
+		_, err := runtimeService.Version(ctx, &v1.VersionRequest{})
+		return err
+	}
+	negotiateAPI(ctx, negotiator, connectionTimeout, version)
+	versionCtx, cancel := context.WithTimeout(ctx, connectionTimeout)
+	defer cancel()
+	if err := version(versionCtx); err != nil {
+		return fmt.Errorf("failed to get the cri version of %s: %v", endpoint, err)
+	}
+	return nil
+}
+
+// discoverEndpoint 返回第一个提供 cri 服务的已知 socket, 例如 containerd 和 cri-dockerd, 都不可用时返回 crio 的默认
+// socket, 连接失败的错误由其报告
+func discoverEndpoint(ctx context.Context) string {
+	socket, err := container.DiscoverRuntime(ctx, container.CRISockets(),
+		func(ctx context.Context, socket container.RuntimeSocket) error {
+			return Probe(ctx, "unix://"+socket.Socket)
+		})
+	if err != nil {
+		return DefaultStateUinxAddress
+	}
+	return "unix://" + socket.Socket
+}
+
+// NewClientFromConn 使用已建立的连接创建客户端, 例如回放 fixture 的连接, 该客户端不会被缓存
+func NewClientFromConn(conn *grpc.ClientConn, namespace string) *CRIClient {
+	if namespace == "" {
+		namespace = DefaultContainerdNameSpace
+	}
+	ctx, cancel := context.WithCancel(namespaces.WithNamespace(context.Background(), namespace))
+	return newClientFromConn(ctx, cancel, conn)
+}
+
+func newClientFromConn(ctx context.Context, cancel context.CancelFunc, conn *grpc.ClientConn) *CRIClient {
 	return &CRIClient{
-		runtimeService: runtimeService,
+		runtimeService: v1.NewRuntimeServiceClient(conn),
 		conn:           conn,
-		imageService:   imageService,
+		imageService:   v1.NewImageServiceClient(conn),
 		Ctx:            ctx,
 		Cancel:         cancel,
-	}, nil
+	}
 }
 
 // Close 关闭客户端连接
 func (c *CRIClient) Close() error {
+	if c.imageConn != nil {
+		c.imageConn.Close()
+	}
 	return c.conn.Close()
 }
 
+// CloseClient 关闭缓存的所有客户端连接
+func CloseClient() error {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	var err error
+	for key, client := range clients {
+		client.Cancel()
+		if closeErr := client.Close(); closeErr != nil {
+			err = closeErr
+		}
+		delete(clients, key)
+	}
+	return err
+}
+
 // GetContainerById 根据容器ID获取容器的详细信息
 func (c *CRIClient) GetContainerById(ctx context.Context, containerId string) (container.ContainerInfo, error, int32) {
 	// 构造 GetContainerStatusRequest 请求
@@ -90,19 +286,143 @@ func (c *CRIClient) GetContainerById(ctx context.Context, containerId string) (c
 	if response == nil || response.Status == nil {
 		return containerInfo, fmt.Errorf("no response status found for container %s", containerId), spec.ContainerExecFailed.Code
 	}
-	return convertContainerInfo(response.Status), nil, spec.OK.Code
+	containerInfo = convertContainerInfo(response.Status)
+	c.fillSandbox(ctx, &containerInfo, parseSandboxFromInfo(response.Info))
+	containerInfo.SELinuxLabel, containerInfo.MountLabel = parseSELinuxFromInfo(response.Info)
+	containerInfo.LastTermination = c.lastTermination(ctx, containerInfo)
+	return containerInfo, nil, spec.OK.Code
+}
+
+// parseSandboxFromInfo 从 verbose 信息中解析 sandboxID, 解析失败时返回空
+func parseSandboxFromInfo(info map[string]string) string {
+	var dataMap struct {
+		SandboxID string `json:"sandboxID"`
+	}
+	if err := json.Unmarshal([]byte(info["info"]), &dataMap); err != nil {
+		return ""
+	}
+	return dataMap.SandboxID
+}
+
+// parseSELinuxFromInfo 从 verbose 信息的 runtimeSpec 中解析容器进程和文件的 selinux 标签, 未启用 selinux 时为空
+func parseSELinuxFromInfo(info map[string]string) (string, string) {
+	var dataMap struct {
+		RuntimeSpec struct {
+			Process struct {
+				SelinuxLabel string `json:"selinuxLabel"`
+			} `json:"process"`
+			Linux struct {
+				MountLabel string `json:"mountLabel"`
+			} `json:"linux"`
+		} `json:"runtimeSpec"`
+	}
+	if err := json.Unmarshal([]byte(info["info"]), &dataMap); err != nil {
+		return "", ""
+	}
+	return dataMap.RuntimeSpec.Process.SelinuxLabel, dataMap.RuntimeSpec.Linux.MountLabel
+}
+
+// fillSandbox 查询 pod sandbox 的 ip 和注解, 第一个 ip 为主 ip, 容器注解优先于 pod 注解, 查询失败或 hostNetwork 未上报时不填充
+func (c *CRIClient) fillSandbox(ctx context.Context, info *container.ContainerInfo, sandboxId string) {
+	if sandboxId == "" {
+		return
+	}
+	response, err := c.runtimeService.PodSandboxStatus(ctx, &v1.PodSandboxStatusRequest{PodSandboxId: sandboxId})
+	if err != nil {
+		return
+	}
+	if podAnnotations := response.GetStatus().GetAnnotations(); len(podAnnotations) > 0 {
+		annotations := make(map[string]string, len(podAnnotations)+len(info.Annotations))
+		for k, v := range podAnnotations {
+			annotations[k] = v
+		}
+		for k, v := range info.Annotations {
+			annotations[k] = v
+		}
+		info.Annotations = annotations
+	}
+	network := response.GetStatus().GetNetwork()
+	if network.GetIp() == "" {
+		return
+	}
+	ips := []string{network.GetIp()}
+	for _, ip := range network.GetAdditionalIps() {
+		if ip.GetIp() != "" {
+			ips = append(ips, ip.GetIp())
+		}
+	}
+	info.IPs = ips
+}
+
+// lastTermination 查找同一 pod 中同名容器的上一次运行, 未重启或已被 kubelet 回收时返回 nil
+func (c *CRIClient) lastTermination(ctx context.Context, info container.ContainerInfo) *container.Termination {
+	podUid, name := info.Labels["io.kubernetes.pod.uid"], info.Labels["io.kubernetes.container.name"]
+	if info.Attempt == 0 || podUid == "" || name == "" {
+		return nil
+	}
+	response, err := c.runtimeService.ListContainers(ctx, &v1.ListContainersRequest{
+		Filter: &v1.ContainerFilter{LabelSelector: map[string]string{
+			"io.kubernetes.pod.uid":        podUid,
+			"io.kubernetes.container.name": name,
+		}},
+	})
+	if err != nil {
+		return nil
+	}
+	var previous *v1.Container
+	for _, item := range response.GetContainers() {
+		attempt := item.GetMetadata().GetAttempt()
+		if item.GetId() == info.ContainerId || attempt >= info.Attempt {
+			continue
+		}
+		if previous == nil || attempt > previous.GetMetadata().GetAttempt() {
+			previous = item
+		}
+	}
+	if previous == nil {
+		return nil
+	}
+	statusResponse, err := c.runtimeService.ContainerStatus(ctx, &v1.ContainerStatusRequest{ContainerId: previous.GetId()})
+	if err != nil || statusResponse.GetStatus() == nil {
+		return nil
+	}
+	status := statusResponse.GetStatus()
+	return &container.Termination{
+		ContainerId: previous.GetId(),
+		Attempt:     previous.GetMetadata().GetAttempt(),
+		ExitCode:    status.GetExitCode(),
+		Reason:      status.GetReason(),
+		Message:     status.GetMessage(),
+		FinishedAt:  container.UnixNanoTime(status.GetFinishedAt()),
+	}
 }
 
 func convertContainerInfo(containerDetail *v1.ContainerStatus) container.ContainerInfo {
 	return container.ContainerInfo{
-		ContainerId:   containerDetail.Id,
-		ContainerName: containerDetail.Metadata.Name,
+		ContainerId:   containerDetail.GetId(),
+		ContainerName: containerDetail.GetMetadata().GetName(),
 		//Env:             spec.Process.Env,
-		Labels: containerDetail.Labels,
-		Spec:   nil,
+		Labels:      containerDetail.Labels,
+		Spec:        nil,
+		CreatedAt:   container.UnixNanoTime(containerDetail.GetCreatedAt()),
+		StartedAt:   container.UnixNanoTime(containerDetail.GetStartedAt()),
+		FinishedAt:  container.UnixNanoTime(containerDetail.GetFinishedAt()),
+		Mounts:      convertMounts(containerDetail.GetMounts()),
+		Attempt:     containerDetail.GetMetadata().GetAttempt(),
+		Ports:       container.ParsePortsAnnotation(containerDetail.GetAnnotations()),
+		Annotations: containerDetail.GetAnnotations(),
 	}
 }
 
+// convertMounts 转换容器状态中的挂载, 根据 kubelet 的卷路径识别卷类型
+func convertMounts(mounts []*v1.Mount) []container.Mount {
+	result := make([]container.Mount, 0, len(mounts))
+	for _, mount := range mounts {
+		result = append(result, container.NewMount(mount.GetContainerPath(), mount.GetHostPath(), mount.GetReadonly()))
+	}
+	return result
+}
+
 func (c *CRIClient) GetPidById(ctx context.Context, containerId string) (int32, error, int32) {
 	request := &v1.ContainerStatusRequest{
 		ContainerId: containerId,
@@ -115,18 +435,143 @@ func (c *CRIClient) GetPidById(ctx context.Context, containerId string) (int32,
 	if response == nil || response.Info == nil {
 		return -1, fmt.Errorf("container info is nil for container %s", containerId), spec.ContainerExecFailed.Code
 	}
-	// 获取 Info 字段中的详细信息
-	info := response.Info
+	pid, err := parsePidFromInfo(response.Info)
+	if err != nil {
+		return -1, fmt.Errorf("%v for container %s", err, containerId), spec.ContainerExecFailed.Code
+	}
+	return pid, nil, spec.OK.Code
+}
+
+// parsePidFromInfo 从 verbose 信息中解析 pid, info["info"] 是运行时返回的 json, 例如 {"pid": 1234}
+func parsePidFromInfo(info map[string]string) (int32, error) {
+	raw, ok := info["info"]
+	if !ok {
+		return -1, fmt.Errorf("container info is nil")
+	}
 	var dataMap map[string]interface{}
-	// 通常 PID 存储在 Info 字段中，例如 "pid": "1234"
-	//pidStr, ok := info["info"]
-	//fmt.Println("pidStr:", pidStr)
-	// 使用 json.Unmarshal() 解析 JSON 字符串到 map
-	err = json.Unmarshal([]byte(info["info"]), &dataMap)
+	if err := json.Unmarshal([]byte(raw), &dataMap); err != nil {
+		return -1, fmt.Errorf("json.Unmarshal container info error, %v", err)
+	}
+	var pid float64
+	switch value := dataMap["pid"].(type) {
+	case float64:
+		pid = value
+	case string:
+		// 部分运行时以字符串返回 pid
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return -1, fmt.Errorf("pid %q in container info is illegal", value)
+		}
+		pid = float64(parsed)
+	default:
+		return -1, fmt.Errorf("pid not found in container info")
+	}
+	if pid <= 0 || pid > math.MaxInt32 || pid != math.Trunc(pid) {
+		return -1, fmt.Errorf("pid %v in container info is illegal", pid)
+	}
+	return int32(pid), nil
+}
+
+// GetRootfsPath 从 verbose 信息中解析容器 rootfs 在宿主机上的路径
+func (c *CRIClient) GetRootfsPath(ctx context.Context, containerId string) (string, error, int32) {
+	response, err := c.runtimeService.ContainerStatus(ctx, &v1.ContainerStatusRequest{
+		ContainerId: containerId,
+		Verbose:     true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get container status and info for container %s: %v", containerId, err), spec.ContainerExecFailed.Code
+	}
+	namespace, _ := namespaces.Namespace(c.Ctx)
+	hint, err := parseRootfsFromInfo(containerId, namespace, response.GetInfo())
+	if err != nil {
+		return "", fmt.Errorf("%v for container %s", err, containerId), spec.ContainerExecFailed.Code
+	}
+	rootfs, err := container.ResolveRootfsPath(hint)
+	if err != nil {
+		return "", fmt.Errorf("%v for container %s", err, containerId), spec.ContainerExecFailed.Code
+	}
+	return rootfs, nil, spec.OK.Code
+}
+
+// parseRootfsFromInfo 从 verbose 信息中解析 rootfs, crio 返回存储驱动下的绝对路径, 例如 overlay 的 merged 目录,
+// containerd 返回相对于 bundle 的路径和 snapshotter
+func parseRootfsFromInfo(containerId, namespace string, info map[string]string) (container.RootfsHint, error) {
+	hint := container.RootfsHint{}
+	raw, ok := info["info"]
+	if !ok {
+		return hint, fmt.Errorf("container info is nil")
+	}
+	var dataMap struct {
+		Snapshotter string `json:"snapshotter"`
+		RuntimeSpec struct {
+			Root struct {
+				Path string `json:"path"`
+			} `json:"root"`
+		} `json:"runtimeSpec"`
+	}
+	if err := json.Unmarshal([]byte(raw), &dataMap); err != nil {
+		return hint, fmt.Errorf("json.Unmarshal container info error, %v", err)
+	}
+	// pid 仅用于回退到 /proc/<pid>/root
+	hint.Pid, _ = parsePidFromInfo(info)
+	hint.Driver = dataMap.Snapshotter
+	hint.Path = dataMap.RuntimeSpec.Root.Path
+	if hint.Path != "" && !path.IsAbs(hint.Path) {
+		hint.Path = path.Join(containerdBundleDir, namespace, containerId, hint.Path)
+	}
+	// crio 的路径为 /var/lib/containers/storage/<driver>/..., 从路径中推断存储驱动
+	if hint.Driver == "" {
+		if _, rest, found := strings.Cut(hint.Path, "/containers/storage/"); found {
+			hint.Driver, _, _ = strings.Cut(rest, "/")
+		}
+	}
+	return hint, nil
+}
+
+// GetCgroupPath 解析容器的 cgroup, 优先读取 /proc/<pid>/cgroup, 读取失败时使用 runtimeSpec 中的 cgroupsPath
+func (c *CRIClient) GetCgroupPath(ctx context.Context, containerId string) (container.CgroupPath, error, int32) {
+	response, err := c.runtimeService.ContainerStatus(ctx, &v1.ContainerStatusRequest{
+		ContainerId: containerId,
+		Verbose:     true,
+	})
+	if err != nil {
+		return container.CgroupPath{}, fmt.Errorf("failed to get container status and info for container %s: %v", containerId, err), spec.ContainerExecFailed.Code
+	}
+	pid, cgroupsPath, err := parseCgroupFromInfo(response.GetInfo())
+	if err != nil {
+		return container.CgroupPath{}, fmt.Errorf("%v for container %s", err, containerId), spec.ContainerExecFailed.Code
+	}
+	cgroupPath, err := container.ResolveCgroupPath(pid, cgroupsPath)
 	if err != nil {
-		return -1, fmt.Errorf("json.Unmarshal container info error for container %s,%v", containerId, err), spec.ContainerExecFailed.Code
+		return container.CgroupPath{}, fmt.Errorf("%v for container %s", err, containerId), spec.ContainerExecFailed.Code
 	}
-	return int32(dataMap["pid"].(float64)), nil, spec.OK.Code
+	return cgroupPath, nil, spec.OK.Code
+}
+
+// parseCgroupFromInfo 从 verbose 信息中解析 pid 和 runtimeSpec.linux.cgroupsPath, 例如
+// kubepods-besteffort-pod1234.slice:crio:c0ffee
+func parseCgroupFromInfo(info map[string]string) (int32, string, error) {
+	raw, ok := info["info"]
+	if !ok {
+		return -1, "", fmt.Errorf("container info is nil")
+	}
+	var dataMap struct {
+		RuntimeSpec struct {
+			Linux struct {
+				CgroupsPath string `json:"cgroupsPath"`
+			} `json:"linux"`
+		} `json:"runtimeSpec"`
+	}
+	if err := json.Unmarshal([]byte(raw), &dataMap); err != nil {
+		return -1, "", fmt.Errorf("json.Unmarshal container info error, %v", err)
+	}
+	pid, _ := parsePidFromInfo(info)
+	return pid, dataMap.RuntimeSpec.Linux.CgroupsPath, nil
+}
+
+// ListProcesses 列出容器内的进程, 优先遍历宿主机上容器 cgroup 的 cgroup.procs, 失败时在容器内执行 ps
+func (c *CRIClient) ListProcesses(ctx context.Context, containerId string) ([]container.Process, error, int32) {
+	return container.ListProcesses(ctx, c, containerId)
 }
 
 func (c *CRIClient) GetContainerByName(ctx context.Context, containerName string) (container.ContainerInfo, error, int32) {
@@ -140,10 +585,10 @@ func (c *CRIClient) GetContainerByName(ctx context.Context, containerName string
 		return containerInfo, fmt.Errorf("failed to list containers: %s", err.Error()), spec.ContainerExecFailed.Code
 	}
 	// 遍历容器列表，找到匹配的容器
-	var containerID string
+	var containerID, sandboxID string
 	for _, container := range listResponse.Containers {
-		if container.Labels["io.kubernetes.container.name"] == containerName {
-			containerID = container.Id
+		if container.GetLabels()["io.kubernetes.container.name"] == containerName {
+			containerID, sandboxID = container.Id, container.PodSandboxId
 			break
 		}
 	}
@@ -153,6 +598,7 @@ func (c *CRIClient) GetContainerByName(ctx context.Context, containerName string
 	// 使用找到的容器ID获取容器的详细状态信息
 	statusRequest := &v1.ContainerStatusRequest{
 		ContainerId: containerID,
+		Verbose:     true,
 	}
 	statusResponse, err := c.runtimeService.ContainerStatus(ctx, statusRequest)
 	if err != nil {
@@ -162,7 +608,11 @@ func (c *CRIClient) GetContainerByName(ctx context.Context, containerName string
 	if statusResponse == nil || statusResponse.Status == nil {
 		return containerInfo, fmt.Errorf("no statusResponse found for container %s", containerName), spec.ContainerExecFailed.Code
 	}
-	return convertContainerInfo(statusResponse.Status), nil, spec.OK.Code
+	containerInfo = convertContainerInfo(statusResponse.Status)
+	c.fillSandbox(ctx, &containerInfo, sandboxID)
+	containerInfo.SELinuxLabel, containerInfo.MountLabel = parseSELinuxFromInfo(statusResponse.Info)
+	containerInfo.LastTermination = c.lastTermination(ctx, containerInfo)
+	return containerInfo, nil, spec.OK.Code
 }
 
 // 标签选择器从容器运行时中筛选容器
@@ -188,18 +638,35 @@ func (c *CRIClient) GetContainerByLabelSelector(labels map[string]string) (conta
 	return ContainerInfo, nil, spec.OK.Code
 }
 
+// ListContainers 列出所有容器
+func (c *CRIClient) ListContainers(ctx context.Context) ([]container.ContainerInfo, error, int32) {
+	listResponse, err := c.runtimeService.ListContainers(ctx, &v1.ListContainersRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err), spec.ContainerExecFailed.Code
+	}
+	containerInfos := make([]container.ContainerInfo, 0, len(listResponse.Containers))
+	for _, containerDetail := range listResponse.Containers {
+		containerInfos = append(containerInfos, convertContainerInfo2(containerDetail))
+	}
+	return containerInfos, nil, spec.OK.Code
+}
+
+// convertContainerInfo2 列表接口只返回创建时间
 func convertContainerInfo2(containerDetail *v1.Container) container.ContainerInfo {
 	return container.ContainerInfo{
-		ContainerId:   containerDetail.Id,
-		ContainerName: containerDetail.Metadata.Name,
+		ContainerId:   containerDetail.GetId(),
+		ContainerName: containerDetail.GetMetadata().GetName(),
 		//Env:             spec.Process.Env,
-		Labels: containerDetail.Labels,
-		Spec:   nil,
+		Labels:    containerDetail.Labels,
+		Spec:      nil,
+		CreatedAt: container.UnixNanoTime(containerDetail.GetCreatedAt()),
+		Attempt:   containerDetail.GetMetadata().GetAttempt(),
+		Ports:     container.ParsePortsAnnotation(containerDetail.GetAnnotations()),
 	}
 }
 func matchLabels(container *v1.Container, labelSelector map[string]string) bool {
 	// 获取容器的标签
-	labels := container.Labels
+	labels := container.GetLabels()
 	if labels == nil {
 		return false
 	}
@@ -212,11 +679,57 @@ func matchLabels(container *v1.Container, labelSelector map[string]string) bool
 	return true
 }
 
+// ListSandboxes 列出节点上的所有 pod sandbox
+func (c *CRIClient) ListSandboxes(ctx context.Context) ([]container.Sandbox, error) {
+	response, err := c.runtimeService.ListPodSandbox(ctx, &v1.ListPodSandboxRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod sandboxes: %v", err)
+	}
+	sandboxes := make([]container.Sandbox, 0, len(response.Items))
+	for _, item := range response.Items {
+		sandboxes = append(sandboxes, container.Sandbox{
+			Id:        item.GetId(),
+			Name:      item.GetMetadata().GetName(),
+			Namespace: item.GetMetadata().GetNamespace(),
+			Uid:       item.GetMetadata().GetUid(),
+			Labels:    item.GetLabels(),
+			Ready:     item.GetState() == v1.PodSandboxState_SANDBOX_READY,
+			CreatedAt: container.UnixNanoTime(item.GetCreatedAt()),
+		})
+	}
+	return sandboxes, nil
+}
+
+// StopSandbox 停止 pod sandbox, 运行时停止其所有容器并回收网络, kubelet 随后重建 pod
+func (c *CRIClient) StopSandbox(ctx context.Context, sandboxId string) error {
+	if _, err := c.runtimeService.StopPodSandbox(ctx, &v1.StopPodSandboxRequest{PodSandboxId: sandboxId}); err != nil {
+		return fmt.Errorf("failed to stop pod sandbox %s: %v", sandboxId, err)
+	}
+	return nil
+}
+
+// ImageFilesystems 返回运行时 ImageFsInfo 报告的镜像文件系统, 即 kubelet 的 imagefs
+func (c *CRIClient) ImageFilesystems(ctx context.Context) ([]container.ImageFilesystem, error) {
+	response, err := c.imageService.ImageFsInfo(ctx, &v1.ImageFsInfoRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image fs info: %v", err)
+	}
+	filesystems := make([]container.ImageFilesystem, 0, len(response.ImageFilesystems))
+	for _, usage := range response.ImageFilesystems {
+		filesystems = append(filesystems, container.ImageFilesystem{
+			Mountpoint: usage.GetFsId().GetMountpoint(),
+			UsedBytes:  usage.GetUsedBytes().GetValue(),
+			InodesUsed: usage.GetInodesUsed().GetValue(),
+		})
+	}
+	return filesystems, nil
+}
+
 func (c *CRIClient) RemoveContainer(ctx context.Context, containerId string, force bool) error {
 	// 先尝试停止容器
 	stopRequest := &v1.StopContainerRequest{
 		ContainerId: containerId,
-		Timeout:     15, // 你可以设置一个合理的超时时间
+		Timeout:     stopTimeout(RemoveStopTimeout),
 	}
 	_, err := c.runtimeService.StopContainer(ctx, stopRequest)
 	if err != nil {
@@ -233,13 +746,32 @@ func (c *CRIClient) RemoveContainer(ctx context.Context, containerId string, for
 	return nil
 }
 
-// CopyToContainer 将 tar 文件复制到容器中并解压缩
-func (c *CRIClient) CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) error {
-	processId, err, _ := c.GetPidById(ctx, containerId)
+// CopyToContainer 将 tar 文件复制到容器中并解压缩, 解压的文件打上容器的 selinux 文件标签, 否则 openshift 等
+// selinux 隔离的容器无法读取宿主机标签的文件
+func (c *CRIClient) CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) (string, error) {
+	response, err := c.runtimeService.ContainerStatus(ctx, &v1.ContainerStatusRequest{
+		ContainerId: containerId,
+		Verbose:     true,
+	})
 	if err != nil {
-		return err
+		return "", fmt.Errorf("failed to get container status and info for container %s: %v", containerId, err)
+	}
+	processId, err := parsePidFromInfo(response.GetInfo())
+	if err != nil {
+		return "", fmt.Errorf("%v for container %s", err, containerId)
+	}
+	extractPath, err := container.CopyToContainer(ctx, uint32(processId), srcFile, dstPath, extractDirName, override)
+	if err != nil {
+		return "", err
 	}
-	return crioCopyToContainer(ctx, uint32(processId), srcFile, dstPath, extractDirName, override)
+	processLabel, mountLabel := parseSELinuxFromInfo(response.GetInfo())
+	if processLabel == "" {
+		processLabel = container.ProcessLabel(processId)
+	}
+	if err := container.RelabelFiles(processId, extractPath, container.FileLabel(processLabel, mountLabel)); err != nil {
+		return "", err
+	}
+	return extractPath, nil
 }
 
 func (c *CRIClient) ExecContainer(ctx context.Context, containerId, command string) (output string, err error) {
@@ -247,7 +779,7 @@ func (c *CRIClient) ExecContainer(ctx context.Context, containerId, command stri
 	if err != nil {
 		return "", err
 	}
-	return crioExecContainer(ctx, processId, command)
+	return container.ExecContainer(ctx, processId, command)
 }
 
 // ExecuteAndRemove: create and start a container for executing a command, and remove the container
@@ -273,25 +805,24 @@ func (c *CRIClient) ExecuteAndRemove(ctx context.Context, config *containertype.
 	if config.Cmd == nil {
 		config.Cmd = cmdslice
 	}
-	// 在容器中执行命令
-	execRequest := &v1.ExecSyncRequest{
-		ContainerId: containerId,
-		Cmd:         config.Cmd,
-		Timeout:     int64(timeout.Seconds()), // 以秒为单位
-	}
-	execResponse, err := c.runtimeService.ExecSync(ctx, execRequest)
+	// 在容器中执行命令, 输出被运行时截断时以文件捕获的方式取回
+	execResponse, err := c.ExecSync(ctx, containerId, config.Cmd, timeout)
 	if err != nil {
-		return containerId, "", fmt.Errorf("failed to execute command in container %s: %v", err), spec.CreateContainerFailed.Code
+		return containerId, "", fmt.Errorf("failed to execute command in container %s: %v", containerId, err), spec.CreateContainerFailed.Code
 	}
 
 	if execResponse.ExitCode != 0 {
-		return containerId, "", fmt.Errorf("command in container failed : %v", err), spec.ContainerExecFailed.Code
+		return containerId, "", fmt.Errorf("command in container failed : exit code %d, %s", execResponse.ExitCode, execResponse.Stderr), spec.ContainerExecFailed.Code
 
 	}
+	if execResponse.Truncated {
+		warning.Add(ctx, "ExecSync", "the output of the helper container %s is truncated, %d bytes of stdout are returned",
+			containerId, len(execResponse.Stdout))
+	}
 	// 停止容器
 	stopRequest := &v1.StopContainerRequest{
 		ContainerId: containerId,
-		Timeout:     10, // 停止容器的超时时间，可以根据需要调整
+		Timeout:     stopTimeout(HelperStopTimeout),
 	}
 	_, err = c.runtimeService.StopContainer(ctx, stopRequest)
 	if err != nil {
@@ -305,7 +836,7 @@ func (c *CRIClient) ExecuteAndRemove(ctx context.Context, config *containertype.
 	if err != nil {
 		return containerId, "", fmt.Errorf("failed to remove container : %v", err), spec.ContainerExecFailed.Code
 	}
-	return containerId, execResponse.String(), nil, spec.OK.Code
+	return containerId, string(execResponse.Stdout), nil, spec.OK.Code
 }
 
 // CreateContainer 创建一个新容器，带有配置选项
@@ -315,9 +846,21 @@ func (c *CRIClient) CreateContainer(ctx context.Context, containerName string, c
 	imageSpec := &v1.ImageSpec{Image: config.Image}
 	pullRequest := &v1.PullImageRequest{Image: imageSpec}
 	statusRequest := &v1.ImageStatusRequest{Image: imageSpec}
-	_, err := c.imageService.ImageStatus(ctx, statusRequest)
+	if _, err := c.imageService.ImageStatus(ctx, statusRequest); err != nil {
+		// 镜像状态检查失败不影响拉取镜像
+		warning.Add(ctx, "CreateContainer", "check the status of the image %s failed, pull it anyway, %v", config.Image, err)
+	}
 
-	_, err = c.imageService.PullImage(ctx, pullRequest)
+	// 镜像凭证来自节点的凭证插件，取不到时匿名拉取
+	if auth := credential.Lookup(ctx, config.Image); !auth.IsEmpty() {
+		pullRequest.Auth = &v1.AuthConfig{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			IdentityToken: auth.IdentityToken,
+			ServerAddress: credential.Registry(config.Image),
+		}
+	}
+	_, err := c.imageService.PullImage(ctx, pullRequest)
 	if err != nil {
 		return "", fmt.Errorf("failed to pull image %s: %v", config.Image, err)
 	}
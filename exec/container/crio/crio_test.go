@@ -0,0 +1,502 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"context"
+	"net"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	containertype "github.com/docker/docker/api/types/container"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio/fake"
+)
+
+var testContainers = []fake.Container{
+	{
+		Id:     "c1",
+		Name:   "nginx",
+		Image:  "nginx:latest",
+		Labels: map[string]string{"io.kubernetes.container.name": "nginx", "app": "web"},
+		State:  v1.ContainerState_CONTAINER_RUNNING,
+		Pid:    1234,
+	},
+	{
+		Id:     "c2",
+		Name:   "redis",
+		Image:  "redis:7",
+		Labels: map[string]string{"io.kubernetes.container.name": "redis", "app": "cache"},
+		State:  v1.ContainerState_CONTAINER_RUNNING,
+		Pid:    5678,
+	},
+}
+
+func newTestClient(t *testing.T, containers ...fake.Container) (*CRIClient, *fake.Server) {
+	t.Helper()
+	server := fake.NewServer(containers...)
+	endpoint, err := server.Start()
+	if err != nil {
+		t.Fatalf("start fake cri server failed, %v", err)
+	}
+	CloseClient()
+	client, err := NewClient(endpoint, "")
+	if err != nil {
+		server.Stop()
+		t.Fatalf("connect fake cri server failed, %v", err)
+	}
+	t.Cleanup(func() {
+		CloseClient()
+		server.Stop()
+	})
+	return client, server
+}
+
+// TestNewClientCache gets the clients of two endpoints concurrently like the handlers of the agent, every endpoint
+// and namespace has its own connection
+func TestNewClientCache(t *testing.T) {
+	endpoints := make([]string, 0, 2)
+	for _, c := range testContainers {
+		server := fake.NewServer(c)
+		endpoint, err := server.Start()
+		if err != nil {
+			t.Fatalf("start fake cri server failed, %v", err)
+		}
+		t.Cleanup(server.Stop)
+		endpoints = append(endpoints, endpoint)
+	}
+	CloseClient()
+	t.Cleanup(func() { CloseClient() })
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for index, endpoint := range endpoints {
+			wg.Add(1)
+			go func(endpoint string, expected fake.Container) {
+				defer wg.Done()
+				client, err := NewClient(endpoint, "")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				containers, err, _ := client.ListContainers(context.Background())
+				if err != nil || len(containers) != 1 || containers[0].ContainerId != expected.Id {
+					t.Errorf("expected the container %s of %s, got %+v, %v", expected.Id, endpoint, containers, err)
+				}
+			}(endpoint, testContainers[index])
+		}
+	}
+	wg.Wait()
+	first, _ := NewClient(endpoints[0], "")
+	if again, _ := NewClient(endpoints[0], ""); again != first {
+		t.Error("expected the connection of the endpoint reused")
+	}
+	if other, _ := NewClient(endpoints[0], "default"); other == first {
+		t.Error("expected the connection of another namespace")
+	}
+}
+
+func TestGetContainerById(t *testing.T) {
+	client, _ := newTestClient(t, testContainers...)
+	info, err, _ := client.GetContainerById(context.Background(), "c1")
+	if err != nil {
+		t.Fatalf("GetContainerById failed, %v", err)
+	}
+	if info.ContainerId != "c1" || info.ContainerName != "nginx" || info.Labels["app"] != "web" {
+		t.Errorf("unexpected container %+v", info)
+	}
+	if _, err, _ := client.GetContainerById(context.Background(), "missing"); err == nil {
+		t.Error("expected error for missing container")
+	}
+}
+
+func TestGetPidById(t *testing.T) {
+	client, server := newTestClient(t, testContainers...)
+	pid, err, _ := client.GetPidById(context.Background(), "c2")
+	if err != nil {
+		t.Fatalf("GetPidById failed, %v", err)
+	}
+	if pid != 5678 {
+		t.Errorf("expected pid 5678, got %d", pid)
+	}
+
+	// the info schema without pid must not panic
+	server.AddContainer(fake.Container{Id: "c3", Name: "nopid", Info: map[string]string{"info": `{"sandboxID":"s1"}`}})
+	if _, err, _ := client.GetPidById(context.Background(), "c3"); err == nil {
+		t.Error("expected error for info without pid")
+	}
+	server.AddContainer(fake.Container{Id: "c4", Name: "noinfo", Info: map[string]string{}})
+	if _, err, _ := client.GetPidById(context.Background(), "c4"); err == nil {
+		t.Error("expected error for empty info")
+	}
+}
+
+func TestGetContainerByName(t *testing.T) {
+	client, _ := newTestClient(t, testContainers...)
+	info, err, _ := client.GetContainerByName(context.Background(), "redis")
+	if err != nil {
+		t.Fatalf("GetContainerByName failed, %v", err)
+	}
+	if info.ContainerId != "c2" {
+		t.Errorf("expected c2, got %s", info.ContainerId)
+	}
+	if _, err, _ := client.GetContainerByName(context.Background(), "missing"); err == nil {
+		t.Error("expected error for missing container")
+	}
+}
+
+func TestGetContainerByLabelSelector(t *testing.T) {
+	client, _ := newTestClient(t, testContainers...)
+	info, err, _ := client.GetContainerByLabelSelector(map[string]string{"app": "cache"})
+	if err != nil {
+		t.Fatalf("GetContainerByLabelSelector failed, %v", err)
+	}
+	if info.ContainerId != "c2" {
+		t.Errorf("expected c2, got %s", info.ContainerId)
+	}
+	if _, err, _ := client.GetContainerByLabelSelector(map[string]string{"app": "db"}); err == nil {
+		t.Error("expected error for unmatched selector")
+	}
+}
+
+func TestListContainers(t *testing.T) {
+	client, _ := newTestClient(t, testContainers...)
+	containers, err, _ := client.ListContainers(context.Background())
+	if err != nil {
+		t.Fatalf("ListContainers failed, %v", err)
+	}
+	ids := make(map[string]bool)
+	for _, c := range containers {
+		ids[c.ContainerId] = true
+	}
+	if !reflect.DeepEqual(ids, map[string]bool{"c1": true, "c2": true}) {
+		t.Errorf("unexpected containers %v", ids)
+	}
+}
+
+func TestRemoveContainer(t *testing.T) {
+	client, server := newTestClient(t, testContainers...)
+	if err := client.RemoveContainer(context.Background(), "c1", true); err != nil {
+		t.Fatalf("RemoveContainer failed, %v", err)
+	}
+	if _, ok := server.GetContainer("c1"); ok {
+		t.Error("container c1 is not removed")
+	}
+	calls := server.Calls()
+	if len(calls) < 2 || calls[len(calls)-2] != "StopContainer" || calls[len(calls)-1] != "RemoveContainer" {
+		t.Errorf("expected stop then remove, got %v", calls)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	server := fake.NewServer()
+	endpoint, err := server.Start()
+	if err != nil {
+		t.Fatalf("start fake cri server failed, %v", err)
+	}
+	defer server.Stop()
+	if err := Probe(context.Background(), endpoint); err != nil {
+		t.Errorf("expected the cri endpoint probed, got %v", err)
+	}
+	// the containerd of dockerd serves no cri
+	socket := path.Join(t.TempDir(), "containerd.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := grpc.NewServer()
+	go plain.Serve(listener)
+	defer plain.Stop()
+	if err := Probe(context.Background(), "unix://"+socket); err == nil || !strings.Contains(err.Error(), "Unimplemented") {
+		t.Errorf("expected the endpoint without cri refused, got %v", err)
+	}
+}
+
+func TestImageFilesystems(t *testing.T) {
+	client, server := newTestClient(t, testContainers...)
+	if filesystems, err := client.ImageFilesystems(context.Background()); err != nil || len(filesystems) != 0 {
+		t.Fatalf("expected no image filesystem, got %+v, %v", filesystems, err)
+	}
+	server.SetImageFs("/var/lib/containers/storage/overlay-images")
+	filesystems, err := client.ImageFilesystems(context.Background())
+	if err != nil || len(filesystems) != 1 || filesystems[0].Mountpoint != "/var/lib/containers/storage/overlay-images" {
+		t.Errorf("unexpected image filesystems %+v, %v", filesystems, err)
+	}
+}
+
+func TestExecuteAndRemove(t *testing.T) {
+	client, server := newTestClient(t)
+	server.SetExecFunc(truncatingExec(DefaultExecOutputLimit))
+	config := &containertype.Config{Image: "chaosblade-tool:latest", Cmd: []string{"echo", "echo ok"}}
+	id, output, err, _ := client.ExecuteAndRemove(context.Background(), config, &containertype.HostConfig{}, nil,
+		"chaosblade-tool", true, time.Second, "", container.ContainerInfo{})
+	if err != nil {
+		t.Fatalf("ExecuteAndRemove failed, %v", err)
+	}
+	if !strings.Contains(output, "echo ok") {
+		t.Errorf("unexpected output %s", output)
+	}
+	if _, ok := server.GetContainer(id); ok {
+		t.Errorf("container %s is not removed", id)
+	}
+}
+
+func TestFault(t *testing.T) {
+	client, server := newTestClient(t, testContainers...)
+	server.SetFault("ListContainers", fake.Fault{Code: codes.Unavailable, Times: 1})
+	if _, err, _ := client.ListContainers(context.Background()); err == nil {
+		t.Fatal("expected the fault error")
+	}
+	if _, err, _ := client.ListContainers(context.Background()); err != nil {
+		t.Fatalf("expected the fault cleared after one call, %v", err)
+	}
+
+	server.SetFault("ContainerStatus", fake.Fault{Delay: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err, _ := client.GetContainerById(ctx, "c1"); err == nil {
+		t.Fatal("expected the deadline error")
+	}
+}
+
+func TestContainerTimes(t *testing.T) {
+	created := time.Now().Add(-time.Minute).Truncate(time.Second)
+	client, _ := newTestClient(t,
+		fake.Container{Id: "c1", Name: "nginx", State: v1.ContainerState_CONTAINER_RUNNING, CreatedAt: created},
+		fake.Container{Id: "c2", Name: "job", State: v1.ContainerState_CONTAINER_EXITED, CreatedAt: created,
+			StartedAt: created.Add(time.Second), FinishedAt: created.Add(10 * time.Second)},
+	)
+	info, err, _ := client.GetContainerById(context.Background(), "c1")
+	if err != nil {
+		t.Fatalf("GetContainerById failed, %v", err)
+	}
+	if !info.CreatedAt.Equal(created) || !info.StartedAt.Equal(created) || !info.FinishedAt.IsZero() {
+		t.Errorf("unexpected times %v %v %v", info.CreatedAt, info.StartedAt, info.FinishedAt)
+	}
+	if uptime := info.Uptime(); uptime < time.Minute || uptime > 2*time.Minute {
+		t.Errorf("unexpected uptime %s", uptime)
+	}
+
+	info, err, _ = client.GetContainerById(context.Background(), "c2")
+	if err != nil {
+		t.Fatalf("GetContainerById failed, %v", err)
+	}
+	if !info.FinishedAt.Equal(created.Add(10*time.Second)) || info.Uptime() != 0 {
+		t.Errorf("expected the finished container without uptime, got %v %s", info.FinishedAt, info.Uptime())
+	}
+
+	infos, err, _ := client.ListContainers(context.Background())
+	if err != nil {
+		t.Fatalf("ListContainers failed, %v", err)
+	}
+	for _, info := range infos {
+		if !info.CreatedAt.Equal(created) {
+			t.Errorf("unexpected created time of %s, %v", info.ContainerId, info.CreatedAt)
+		}
+	}
+}
+
+func TestContainerMounts(t *testing.T) {
+	client, _ := newTestClient(t, fake.Container{Id: "c1", Name: "nginx", State: v1.ContainerState_CONTAINER_RUNNING,
+		Mounts: []*v1.Mount{
+			{ContainerPath: "/cache", HostPath: "/var/lib/kubelet/pods/p1/volumes/kubernetes.io~empty-dir/cache"},
+			{ContainerPath: "/etc/config", HostPath: "/var/lib/kubelet/pods/p1/volumes/kubernetes.io~configmap/config", Readonly: true},
+		}})
+	info, err, _ := client.GetContainerById(context.Background(), "c1")
+	if err != nil {
+		t.Fatalf("GetContainerById failed, %v", err)
+	}
+	if len(info.Mounts) != 2 {
+		t.Fatalf("expected 2 mounts, got %+v", info.Mounts)
+	}
+	if mount := info.Mounts[0]; mount.VolumeType != container.VolumeEmptyDir || mount.VolumeName != "cache" {
+		t.Errorf("unexpected mount %+v", mount)
+	}
+	if mount := info.Mounts[1]; mount.VolumeType != container.VolumeConfigMap || !mount.ReadOnly {
+		t.Errorf("unexpected mount %+v", mount)
+	}
+}
+
+func TestLastTermination(t *testing.T) {
+	labels := map[string]string{"io.kubernetes.pod.uid": "p1", "io.kubernetes.container.name": "app"}
+	finished := time.Now().Add(-time.Minute).Truncate(time.Second)
+	client, _ := newTestClient(t,
+		fake.Container{Id: "a0", Name: "app", Labels: labels, State: v1.ContainerState_CONTAINER_EXITED, Attempt: 0,
+			ExitCode: 1, Reason: "Error"},
+		fake.Container{Id: "a1", Name: "app", Labels: labels, State: v1.ContainerState_CONTAINER_EXITED, Attempt: 1,
+			ExitCode: 137, Reason: "OOMKilled", FinishedAt: finished},
+		fake.Container{Id: "a2", Name: "app", Labels: labels, State: v1.ContainerState_CONTAINER_RUNNING, Attempt: 2},
+		fake.Container{Id: "b0", Name: "app", Labels: map[string]string{"io.kubernetes.pod.uid": "p2",
+			"io.kubernetes.container.name": "app"}, State: v1.ContainerState_CONTAINER_RUNNING, Attempt: 3},
+	)
+	info, err, _ := client.GetContainerById(context.Background(), "a2")
+	if err != nil {
+		t.Fatalf("GetContainerById failed, %v", err)
+	}
+	if info.Attempt != 2 || info.LastTermination == nil {
+		t.Fatalf("expected the last termination of the attempt 2, got %+v", info)
+	}
+	termination := info.LastTermination
+	if termination.ContainerId != "a1" || termination.ExitCode != 137 || termination.Reason != "OOMKilled" ||
+		!termination.FinishedAt.Equal(finished) {
+		t.Errorf("unexpected termination %+v", termination)
+	}
+
+	// the previous attempts of the other pod are garbage collected
+	info, err, _ = client.GetContainerById(context.Background(), "b0")
+	if err != nil {
+		t.Fatalf("GetContainerById failed, %v", err)
+	}
+	if info.Attempt != 3 || info.LastTermination != nil {
+		t.Errorf("expected no termination, got %+v", info.LastTermination)
+	}
+}
+
+func TestContainerNetwork(t *testing.T) {
+	annotations := map[string]string{
+		container.PortsAnnotation: `[{"name":"http","containerPort":8080,"protocol":"TCP"},{"containerPort":53,"protocol":"UDP"}]`,
+	}
+	client, server := newTestClient(t,
+		fake.Container{Id: "c1", Name: "app", PodSandboxId: "s1", Annotations: annotations,
+			Labels: map[string]string{"io.kubernetes.container.name": "app"}, State: v1.ContainerState_CONTAINER_RUNNING},
+		fake.Container{Id: "c2", Name: "sidecar", PodSandboxId: "s2", State: v1.ContainerState_CONTAINER_RUNNING},
+	)
+	server.AddSandbox("s1", "10.244.1.5", "fd00::5")
+	expectedPorts := []container.Port{
+		{Name: "http", ContainerPort: 8080, Protocol: "TCP"},
+		{ContainerPort: 53, Protocol: "UDP"},
+	}
+	for _, lookup := range []func() (container.ContainerInfo, error, int32){
+		func() (container.ContainerInfo, error, int32) {
+			return client.GetContainerById(context.Background(), "c1")
+		},
+		func() (container.ContainerInfo, error, int32) {
+			return client.GetContainerByName(context.Background(), "app")
+		},
+	} {
+		info, err, _ := lookup()
+		if err != nil {
+			t.Fatalf("get container failed, %v", err)
+		}
+		if !reflect.DeepEqual(info.IPs, []string{"10.244.1.5", "fd00::5"}) || info.PrimaryIP() != "10.244.1.5" {
+			t.Errorf("unexpected ips %v", info.IPs)
+		}
+		if !reflect.DeepEqual(info.Ports, expectedPorts) {
+			t.Errorf("unexpected ports %+v", info.Ports)
+		}
+	}
+
+	// the sandbox is not found, e.g. removed concurrently
+	info, err, _ := client.GetContainerById(context.Background(), "c2")
+	if err != nil {
+		t.Fatalf("GetContainerById failed, %v", err)
+	}
+	if info.IPs != nil || info.Ports != nil || info.PrimaryIP() != "" {
+		t.Errorf("expected no ips and ports, got %v %v", info.IPs, info.Ports)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	watchInterval = 10 * time.Millisecond
+	defer func() { watchInterval = 2 * time.Second }()
+	client, server := newTestClient(t,
+		fake.Container{Id: "s1", Name: "sidecar", State: v1.ContainerState_CONTAINER_RUNNING},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := client.Watch(ctx, container.EventFilter{ContainerName: "app"})
+	if err != nil {
+		t.Fatalf("Watch failed, %v", err)
+	}
+	next := func() container.Event {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for the event")
+			return container.Event{}
+		}
+	}
+	expect := func(eventType string) {
+		t.Helper()
+		if event := next(); event.Type != eventType || event.ContainerId != "a1" || event.ContainerName != "app" {
+			t.Fatalf("expected the %s event of a1, got %+v", eventType, event)
+		}
+	}
+
+	app := fake.Container{Id: "a1", Name: "app", Labels: map[string]string{"app": "web"},
+		State: v1.ContainerState_CONTAINER_RUNNING}
+	server.AddContainer(app)
+	server.AddContainer(fake.Container{Id: "s2", Name: "sidecar", State: v1.ContainerState_CONTAINER_RUNNING})
+	expect(container.EventCreated)
+	expect(container.EventStarted)
+
+	app.State = v1.ContainerState_CONTAINER_EXITED
+	server.AddContainer(app)
+	expect(container.EventStopped)
+
+	if _, err := server.RemoveContainer(context.Background(), &v1.RemoveContainerRequest{ContainerId: "a1"}); err != nil {
+		t.Fatal(err)
+	}
+	expect(container.EventDeleted)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestOpenShiftMetadata(t *testing.T) {
+	info := `{"sandboxID":"s1","pid":4242,"runtimeSpec":{"process":{"selinuxLabel":` +
+		`"system_u:system_r:container_t:s0:c123,c456"},"linux":{"mountLabel":"system_u:object_r:container_file_t:s0:c123,c456"}}}`
+	client, server := newTestClient(t,
+		fake.Container{Id: "c1", Name: "app", PodSandboxId: "s1", State: v1.ContainerState_CONTAINER_RUNNING,
+			Labels:      map[string]string{"io.kubernetes.container.name": "app"},
+			Annotations: map[string]string{"io.kubernetes.container.hash": "c0ffee", "owner": "container"},
+			Info:        map[string]string{"info": info}},
+	)
+	server.SetSandboxAnnotations("s1", map[string]string{container.OpenShiftSCCAnnotation: "restricted-v2",
+		"owner": "pod"})
+	for _, lookup := range []func() (container.ContainerInfo, error, int32){
+		func() (container.ContainerInfo, error, int32) {
+			return client.GetContainerById(context.Background(), "c1")
+		},
+		func() (container.ContainerInfo, error, int32) {
+			return client.GetContainerByName(context.Background(), "app")
+		},
+	} {
+		info, err, _ := lookup()
+		if err != nil {
+			t.Fatalf("get container failed, %v", err)
+		}
+		if info.SCC() != "restricted-v2" || info.Annotations["owner"] != "container" ||
+			info.Annotations["io.kubernetes.container.hash"] != "c0ffee" {
+			t.Errorf("unexpected annotations %v", info.Annotations)
+		}
+		if info.SELinuxLabel != "system_u:system_r:container_t:s0:c123,c456" ||
+			info.MountLabel != "system_u:object_r:container_file_t:s0:c123,c456" {
+			t.Errorf("unexpected selinux labels %s, %s", info.SELinuxLabel, info.MountLabel)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+const (
+	// ExecOutputLimitEnv overrides the ExecOutputLimit in bytes
+	ExecOutputLimitEnv = "CHAOSBLADE_CRI_EXEC_OUTPUT_LIMIT"
+	// ExecCaptureEnv overrides the ExecCapture
+	ExecCaptureEnv = "CHAOSBLADE_CRI_EXEC_CAPTURE"
+	// DefaultExecOutputLimit 是运行时 ExecSync 返回的最大输出, cri-o 和 containerd 都在 16MiB 处截断
+	DefaultExecOutputLimit = 16 << 20
+)
+
+// The capture modes of the ExecSync output
+const (
+	// CaptureFile 总是将输出写入容器中的临时文件再分块读回, 命令只执行一次
+	CaptureFile = "file"
+	// CaptureNone 直接执行, 达到上限的输出被标记为截断
+	CaptureNone = "none"
+)
+
+var (
+	// ExecOutputLimit 是运行时 ExecSync 返回的最大输出, 达到上限的输出被认为已截断
+	ExecOutputLimit = envInt(ExecOutputLimitEnv, DefaultExecOutputLimit)
+	// ExecCapture 是取得输出的方式, 除 CaptureNone 外都以文件捕获
+	ExecCapture = envString(ExecCaptureEnv, CaptureFile)
+)
+
+// captureCleanupTimeout 是删除捕获文件的超时, 命令可能已经用完了调用者的超时
+var captureCleanupTimeout = 10 * time.Second
+
+// CaptureDeployments 返回记录捕获文件的部署, 文件记录在容器的部署中, 清理失败留下的文件由销毁删除
+var CaptureDeployments = func() *journal.Deployments {
+	return journal.OpenDeployments("")
+}
+
+// captureUnavailable 是文件捕获的包装命令未能执行命令时的退出码, 125 为临时文件无法创建, 126 和 127 为容器中没有可
+// 执行的 sh
+var captureUnavailable = map[int32]bool{125: true, 126: true, 127: true}
+
+// execTimeout 将超时向上取整为 ExecSyncRequest 的秒数, 不足一秒的超时至少为 1 秒, 而不是被截断为表示不超时的 0
+func execTimeout(timeout time.Duration) int64 {
+	if timeout <= 0 {
+		return 0
+	}
+	seconds := int64((timeout + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+func envInt(name string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func envString(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// ExecResult 是 ExecSync 的结果
+type ExecResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int32
+	// Truncated 为 true 表示输出仍然被截断
+	Truncated bool
+	// Capture 是取得输出的方式, exec-sync 或 file
+	Capture string
+}
+
+// ExecSync 在容器中执行命令并返回输出. 运行时会截断 ExecSync 的输出, 所以默认以文件捕获的方式执行, 命令的输出写入
+// 容器的 /tmp 并分块读回, 仍不完整的输出被标记为截断. 命令不会被重新执行, 只有包装命令未能执行命令时, 如容器中没有 sh
+// 或 /tmp 不可写, 才直接执行命令, 达到 ExecOutputLimit 的输出被标记为截断
+func (c *CRIClient) ExecSync(ctx context.Context, containerId string, cmd []string, timeout time.Duration) (ExecResult, error) {
+	if ExecCapture != CaptureNone {
+		result, captured, err := c.execByFile(ctx, containerId, cmd, timeout)
+		if captured || err != nil {
+			return result, err
+		}
+		warning.Add(ctx, "ExecSync", "the file capture of `%s` is unavailable, the output may be truncated",
+			strings.Join(cmd, " "))
+	}
+	response, err := c.runtimeService.ExecSync(ctx, &v1.ExecSyncRequest{
+		ContainerId: containerId,
+		Cmd:         cmd,
+		Timeout:     execTimeout(timeout),
+	})
+	if err != nil {
+		return ExecResult{}, err
+	}
+	result := ExecResult{Stdout: response.Stdout, Stderr: response.Stderr, ExitCode: response.ExitCode,
+		Capture: "exec-sync"}
+	result.Truncated = len(result.Stdout) >= ExecOutputLimit || len(result.Stderr) >= ExecOutputLimit
+	return result, nil
+}
+
+// execByFile 执行命令并将 stdout 和 stderr 写入容器的临时文件, ExecSync 只返回退出码, 再分块读回两个文件. 包装命令
+// 未能执行命令时返回 false, 此时命令没有执行过
+func (c *CRIClient) execByFile(ctx context.Context, containerId string, cmd []string, timeout time.Duration) (ExecResult, bool, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return ExecResult{}, false, err
+	}
+	prefix := "/tmp/.chaosblade-exec-" + hex.EncodeToString(buf)
+	// $0 is the prefix, the arguments are passed unquoted to the command, which runs only if the files are created
+	wrapper := append([]string{"sh", "-c",
+		`{ : >"$0.out" && : >"$0.err"; } 2>/dev/null || exit 125; "$@" >"$0.out" 2>"$0.err"; echo $?`, prefix}, cmd...)
+	files := []string{prefix + ".out", prefix + ".err"}
+	deployments := CaptureDeployments()
+	recorded, err := deployments.AddPaths(containerId, files...)
+	if err != nil {
+		warning.Add(ctx, "ExecSync", "record the capture files %s in the deployment failed, %v", prefix, err)
+	}
+	defer func() {
+		if err := c.removeCapture(containerId, files); err != nil {
+			warning.Add(ctx, "ExecSync", "remove the captured output %s failed, %v", prefix, err)
+			return
+		}
+		if recorded {
+			if err := deployments.RemovePaths(containerId, files...); err != nil {
+				warning.Add(ctx, "ExecSync", "remove the capture files %s from the deployment failed, %v", prefix, err)
+			}
+		}
+	}()
+	response, err := c.runtimeService.ExecSync(ctx, &v1.ExecSyncRequest{
+		ContainerId: containerId,
+		Cmd:         wrapper,
+		Timeout:     execTimeout(timeout),
+	})
+	if err != nil && strings.Contains(err.Error(), "executable file not found") {
+		return ExecResult{}, false, nil
+	}
+	if err != nil {
+		return ExecResult{}, false, err
+	}
+	if captureUnavailable[response.ExitCode] && len(bytes.TrimSpace(response.Stdout)) == 0 {
+		return ExecResult{}, false, nil
+	}
+	if response.ExitCode != 0 {
+		return ExecResult{}, false, fmt.Errorf("capture the output in %s failed, exit code %d, %s", prefix,
+			response.ExitCode, strings.TrimSpace(string(response.Stderr)))
+	}
+	exitCode, err := strconv.Atoi(strings.TrimSpace(string(response.Stdout)))
+	if err != nil {
+		return ExecResult{}, false, fmt.Errorf("illegal exit code %q of the captured command", response.Stdout)
+	}
+	result := ExecResult{ExitCode: int32(exitCode), Capture: "file"}
+	var stdoutTruncated, stderrTruncated bool
+	if result.Stdout, stdoutTruncated, err = c.readFile(ctx, containerId, prefix+".out", timeout); err != nil {
+		return ExecResult{}, false, err
+	}
+	if result.Stderr, stderrTruncated, err = c.readFile(ctx, containerId, prefix+".err", timeout); err != nil {
+		return ExecResult{}, false, err
+	}
+	result.Truncated = stdoutTruncated || stderrTruncated
+	return result, true, nil
+}
+
+// removeCapture 删除捕获输出的临时文件, 使用自己的超时, 命令可能已经用完了调用者的超时
+func (c *CRIClient) removeCapture(containerId string, files []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), captureCleanupTimeout)
+	defer cancel()
+	response, err := c.runtimeService.ExecSync(ctx, &v1.ExecSyncRequest{ContainerId: containerId,
+		Cmd: append([]string{"rm", "-f"}, files...), Timeout: execTimeout(captureCleanupTimeout)})
+	if err == nil && response.ExitCode != 0 {
+		err = fmt.Errorf("exit code %d, %s", response.ExitCode, strings.TrimSpace(string(response.Stderr)))
+	}
+	return err
+}
+
+// readFile 按不超过 ExecOutputLimit 一半的块读取容器中的文件, 块不完整时返回已读取的部分并标记为截断
+func (c *CRIClient) readFile(ctx context.Context, containerId, file string, timeout time.Duration) ([]byte, bool, error) {
+	exec := func(script string) (*v1.ExecSyncResponse, error) {
+		response, err := c.runtimeService.ExecSync(ctx, &v1.ExecSyncRequest{ContainerId: containerId,
+			Cmd: []string{"sh", "-c", script, file}, Timeout: execTimeout(timeout)})
+		if err == nil && response.ExitCode != 0 {
+			err = fmt.Errorf("read %s failed, exit code %d, %s", file, response.ExitCode,
+				strings.TrimSpace(string(response.Stderr)))
+		}
+		return response, err
+	}
+	response, err := exec(`wc -c <"$0"`)
+	if err != nil {
+		return nil, false, err
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(string(response.Stdout)))
+	if err != nil {
+		return nil, false, fmt.Errorf("illegal size %q of %s", response.Stdout, file)
+	}
+	chunk := ExecOutputLimit / 2
+	if chunk < 1 {
+		chunk = 1
+	}
+	var content bytes.Buffer
+	for offset := 0; offset < size; offset += chunk {
+		expected := chunk
+		if size-offset < expected {
+			expected = size - offset
+		}
+		response, err := exec(fmt.Sprintf(`tail -c +%d "$0" | head -c %d`, offset+1, expected))
+		if err != nil {
+			return nil, false, err
+		}
+		content.Write(response.Stdout)
+		if len(response.Stdout) != expected {
+			return content.Bytes(), true, nil
+		}
+	}
+	return content.Bytes(), false, nil
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+)
+
+// truncatingExec runs the commands on the host and truncates the output at the limit like the runtimes
+func truncatingExec(limit int) func(containerId string, cmd []string) ([]byte, []byte, int32) {
+	return func(containerId string, cmd []string) ([]byte, []byte, int32) {
+		var stdout, stderr bytes.Buffer
+		command := exec.Command(cmd[0], cmd[1:]...)
+		command.Stdout, command.Stderr = &stdout, &stderr
+		exitCode := int32(0)
+		if err := command.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return nil, []byte(err.Error()), 127
+			}
+			exitCode = int32(exitErr.ExitCode())
+		}
+		out, errOut := stdout.Bytes(), stderr.Bytes()
+		if len(out) > limit {
+			out = out[:limit]
+		}
+		if len(errOut) > limit {
+			errOut = errOut[:limit]
+		}
+		return out, errOut, exitCode
+	}
+}
+
+func setExecOutput(t *testing.T, limit int, capture string) {
+	t.Helper()
+	originLimit, originCapture := ExecOutputLimit, ExecCapture
+	ExecOutputLimit, ExecCapture = limit, capture
+	t.Cleanup(func() { ExecOutputLimit, ExecCapture = originLimit, originCapture })
+}
+
+func TestExecSync(t *testing.T) {
+	client, server := newTestClient(t, testContainers...)
+	server.SetExecFunc(truncatingExec(64))
+	setExecOutput(t, 64, CaptureFile)
+	before, _ := filepath.Glob("/tmp/.chaosblade-exec-*")
+	ctx := context.Background()
+	counter := filepath.Join(t.TempDir(), "counter")
+	large := []string{"sh", "-c", "echo run >>" + counter +
+		"; head -c 200 /dev/zero | tr '\\0' a; echo failed >&2; exit 3"}
+
+	result, err := client.ExecSync(ctx, "c1", []string{"echo", "ok"}, time.Second)
+	if err != nil || string(result.Stdout) != "ok\n" || result.Capture != "file" || result.Truncated {
+		t.Fatalf("unexpected result %+v, %v", result, err)
+	}
+	result, err = client.ExecSync(ctx, "c1", large, time.Second)
+	if err != nil || len(result.Stdout) != 200 || strings.Trim(string(result.Stdout), "a") != "" ||
+		string(result.Stderr) != "failed\n" || result.ExitCode != 3 || result.Capture != "file" || result.Truncated {
+		t.Fatalf("expected the whole output captured by the file, got %d bytes, %+v, %v", len(result.Stdout), result, err)
+	}
+	if after, _ := filepath.Glob("/tmp/.chaosblade-exec-*"); len(after) != len(before) {
+		t.Errorf("expected the captured files removed, got %v", after)
+	}
+
+	setExecOutput(t, 64, CaptureNone)
+	result, err = client.ExecSync(ctx, "c1", large, time.Second)
+	if err != nil || len(result.Stdout) != 64 || !result.Truncated || result.Capture != "exec-sync" {
+		t.Errorf("expected the output marked truncated, got %d bytes, %v", len(result.Stdout), err)
+	}
+	if runs, _ := os.ReadFile(counter); string(runs) != "run\nrun\n" {
+		t.Errorf("expected the command executed once by every ExecSync, got %q", runs)
+	}
+}
+
+func TestExecSyncCaptureUnavailable(t *testing.T) {
+	client, server := newTestClient(t, testContainers...)
+	run := truncatingExec(64)
+	server.SetExecFunc(func(containerId string, cmd []string) ([]byte, []byte, int32) {
+		if cmd[0] == "sh" {
+			// the image has no shell
+			return nil, []byte("sh: not found"), 127
+		}
+		return run(containerId, cmd)
+	})
+	setExecOutput(t, 64, CaptureFile)
+	result, err := client.ExecSync(context.Background(), "c1", []string{"head", "-c", "100", "/dev/zero"}, time.Second)
+	if err != nil || len(result.Stdout) != 64 || !result.Truncated || result.Capture != "exec-sync" {
+		t.Errorf("expected the truncated output kept, got %+v, %v", result, err)
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	for timeout, expected := range map[time.Duration]int64{0: 0, time.Millisecond: 1, 500 * time.Millisecond: 1,
+		time.Second: 1, 1500 * time.Millisecond: 2, time.Minute: 60} {
+		if seconds := execTimeout(timeout); seconds != expected {
+			t.Errorf("expected the timeout %s rounded up to %d seconds, got %d", timeout, expected, seconds)
+		}
+	}
+}
+
+func TestExecSyncCaptureCleanup(t *testing.T) {
+	client, server := newTestClient(t, testContainers...)
+	run := truncatingExec(64)
+	failRemove := true
+	server.SetExecFunc(func(containerId string, cmd []string) ([]byte, []byte, int32) {
+		if cmd[0] == "rm" && failRemove {
+			return nil, []byte("read-only file system"), 1
+		}
+		return run(containerId, cmd)
+	})
+	setExecOutput(t, 64, CaptureFile)
+	deployments := journal.OpenDeployments(filepath.Join(t.TempDir(), journal.DefaultDeploymentsFileName))
+	origin := CaptureDeployments
+	CaptureDeployments = func() *journal.Deployments { return deployments }
+	t.Cleanup(func() { CaptureDeployments = origin })
+	if err := deployments.Add(journal.Deployment{ContainerId: "c1", Paths: []string{"/opt/chaosblade"},
+		Uids: []string{"uid1"}}); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := filepath.Glob("/tmp/.chaosblade-exec-*")
+	t.Cleanup(func() {
+		after, _ := filepath.Glob("/tmp/.chaosblade-exec-*")
+		for _, file := range after[len(before):] {
+			os.Remove(file)
+		}
+	})
+
+	// the files left are recorded with the deployment, the destroy removes them
+	if _, err := client.ExecSync(context.Background(), "c1", []string{"echo", "ok"}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	deployment, _, _ := deployments.Get("c1")
+	if len(deployment.Paths) != 3 || !strings.HasPrefix(deployment.Paths[1], "/tmp/.chaosblade-exec-") {
+		t.Errorf("expected the capture files recorded, got %v", deployment.Paths)
+	}
+	failRemove = false
+	if err := deployments.RemovePaths("c1", deployment.Paths[1:]...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ExecSync(context.Background(), "c1", []string{"echo", "ok"}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if deployment, _, _ := deployments.Get("c1"); len(deployment.Paths) != 1 {
+		t.Errorf("expected the capture files removed from the deployment, got %v", deployment.Paths)
+	}
+}
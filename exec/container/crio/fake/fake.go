@@ -0,0 +1,666 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fake is an in-memory CRI v1 RuntimeService and ImageService server for unit tests
+package fake
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const (
+	RuntimeName       = "fake"
+	RuntimeVersion    = "0.0.1"
+	RuntimeApiVersion = "v1"
+)
+
+// Container is a container served by the fake runtime
+type Container struct {
+	Id           string
+	Name         string
+	PodSandboxId string
+	Image        string
+	Labels       map[string]string
+	Annotations  map[string]string
+	State        v1.ContainerState
+	Pid          int
+	CreatedAt    time.Time
+	// StartedAt is the CreatedAt if the container is running and it is not set
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Mounts     []*v1.Mount
+	// Attempt is the restart count, ExitCode, Reason and Message are the termination of the exited container
+	Attempt  uint32
+	ExitCode int32
+	Reason   string
+	Message  string
+	// Info is the verbose info payload of the container status, it is generated from the Pid if nil,
+	// set it to replay the info schema of a specific runtime version
+	Info map[string]string
+}
+
+// Fault makes the calls of a method fail
+type Fault struct {
+	Code    codes.Code
+	Message string
+	// Delay is applied before the call is served or failed
+	Delay time.Duration
+	// Times is how many calls fail, 0 means all calls
+	Times int
+}
+
+// ExecFunc serves the ExecSync calls
+type ExecFunc func(containerId string, cmd []string) (stdout, stderr []byte, exitCode int32)
+
+// Server is the fake runtime, the containers, images and faults can be changed while it is serving
+type Server struct {
+	v1.UnimplementedRuntimeServiceServer
+	v1.UnimplementedImageServiceServer
+
+	mu         sync.Mutex
+	containers map[string]*Container
+	sandboxes  map[string][]string
+	// podAnnotations are the annotations of the sandboxes
+	podAnnotations map[string]map[string]string
+	// podMetadata and podLabels are the metadata and the labels of the sandboxes, podStopped the stopped ones
+	podMetadata map[string]*v1.PodSandboxMetadata
+	podLabels   map[string]map[string]string
+	podStopped  map[string]bool
+	images      map[string]*v1.Image
+	// imageFs is the mountpoint of the image filesystem, none is reported if empty
+	imageFs  string
+	faults   map[string]*Fault
+	calls    []string
+	execFunc ExecFunc
+	seq      int
+	// attachFunc serves the attaches by the streaming server, streamProtocols are the websocket protocols it accepts
+	attachFunc      AttachFunc
+	streamProtocols []string
+	streaming       *http.Server
+	streamAddr      string
+	attaches        map[string]*v1.AttachRequest
+
+	server *grpc.Server
+	dir    string
+	// legacy serves the v1alpha2 services by forwarding them to the server through legacyConn
+	legacy     *grpc.Server
+	legacyConn *grpc.ClientConn
+}
+
+// NewServer creates the fake runtime with the containers
+func NewServer(containers ...Container) *Server {
+	s := &Server{
+		containers:     make(map[string]*Container),
+		sandboxes:      make(map[string][]string),
+		podAnnotations: make(map[string]map[string]string),
+		podMetadata:    make(map[string]*v1.PodSandboxMetadata),
+		podLabels:      make(map[string]map[string]string),
+		podStopped:     make(map[string]bool),
+		images:         make(map[string]*v1.Image),
+		faults:         make(map[string]*Fault),
+		attaches:       make(map[string]*v1.AttachRequest),
+	}
+	for _, c := range containers {
+		s.AddContainer(c)
+	}
+	return s
+}
+
+// Start serves on a unix socket under a temporary directory and returns the endpoint
+func (s *Server) Start() (string, error) {
+	dir, err := os.MkdirTemp("", "fakecri-")
+	if err != nil {
+		return "", err
+	}
+	socket := path.Join(dir, "cri.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	s.dir = dir
+	s.serve(listener)
+	return "unix://" + socket, nil
+}
+
+// StartTLS serves on a tcp port of the loopback with the tls config, the client certificates are verified if the
+// config requires them, it returns the tcp endpoint
+func (s *Server) StartTLS(config *tls.Config) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	s.serve(listener, grpc.Creds(credentials.NewTLS(config)))
+	return "tcp://" + listener.Addr().String(), nil
+}
+
+// StartV1alpha2 serves as the older runtimes exposing the v1alpha2 services only, the v1 calls fail with Unimplemented.
+// The v1alpha2 calls are forwarded to the v1 services unchanged, their messages are encoded alike
+func (s *Server) StartV1alpha2() (string, error) {
+	endpoint, err := s.Start()
+	if err != nil {
+		return "", err
+	}
+	conn, err := grpc.Dial(endpoint, grpc.WithInsecure())
+	if err != nil {
+		return "", err
+	}
+	socket := path.Join(s.dir, "v1alpha2.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	s.legacyConn = conn
+	s.legacy = grpc.NewServer(grpc.ForceServerCodec(rawCodec{}), grpc.UnknownServiceHandler(s.forward))
+	go s.legacy.Serve(listener)
+	return "unix://" + socket, nil
+}
+
+// forward forwards the v1alpha2 call to the v1 service
+func (s *Server) forward(srv interface{}, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	if !strings.HasPrefix(method, "/runtime.v1alpha2.") {
+		return status.Errorf(codes.Unimplemented, "unknown service %s", strings.Split(method, "/")[1])
+	}
+	var request, response []byte
+	if err := stream.RecvMsg(&request); err != nil {
+		return err
+	}
+	if err := s.legacyConn.Invoke(stream.Context(), strings.Replace(method, "v1alpha2", "v1", 1), &request, &response,
+		grpc.ForceCodec(rawCodec{})); err != nil {
+		return err
+	}
+	return stream.SendMsg(&response)
+}
+
+// rawCodec passes the encoded messages through
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+func (s *Server) serve(listener net.Listener, options ...grpc.ServerOption) {
+	s.server = grpc.NewServer(append(options, grpc.UnaryInterceptor(s.intercept))...)
+	v1.RegisterRuntimeServiceServer(s.server, s)
+	v1.RegisterImageServiceServer(s.server, s)
+	go s.server.Serve(listener)
+}
+
+// Stop stops serving and removes the socket
+func (s *Server) Stop() {
+	if s.legacy != nil {
+		s.legacy.Stop()
+		s.legacyConn.Close()
+	}
+	if s.server != nil {
+		s.server.Stop()
+	}
+	if s.streaming != nil {
+		s.streaming.Close()
+	}
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+}
+
+// AddContainer adds or replaces the container
+func (s *Server) AddContainer(c Container) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+	if c.StartedAt.IsZero() && c.State == v1.ContainerState_CONTAINER_RUNNING {
+		c.StartedAt = c.CreatedAt
+	}
+	s.containers[c.Id] = &c
+}
+
+// AddSandbox adds or replaces the pod sandbox with the pod ips, the first is the primary one
+func (s *Server) AddSandbox(id string, ips ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sandboxes[id] = ips
+}
+
+// SetSandboxAnnotations sets the pod annotations of the sandbox, the sandbox is added without ips if absent
+func (s *Server) SetSandboxAnnotations(id string, annotations map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sandboxes[id]; !ok {
+		s.sandboxes[id] = nil
+	}
+	s.podAnnotations[id] = annotations
+}
+
+// SetSandboxMetadata sets the pod metadata and labels of the sandbox, the sandbox is added without ips if absent
+func (s *Server) SetSandboxMetadata(id, name, namespace string, labels map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sandboxes[id]; !ok {
+		s.sandboxes[id] = nil
+	}
+	s.podMetadata[id] = &v1.PodSandboxMetadata{Name: name, Namespace: namespace, Uid: "uid-" + id}
+	s.podLabels[id] = labels
+}
+
+// SetImageFs sets the mountpoint of the image filesystem reported by ImageFsInfo
+func (s *Server) SetImageFs(mountpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.imageFs = mountpoint
+}
+
+// GetContainer returns a copy of the container
+func (s *Server) GetContainer(id string) (Container, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.containers[id]
+	if !ok {
+		return Container{}, false
+	}
+	return *c, true
+}
+
+// AddImage adds the image by the reference
+func (s *Server) AddImage(ref string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.images[ref] = &v1.Image{Id: ref, RepoTags: []string{ref}, Spec: &v1.ImageSpec{Image: ref}}
+}
+
+// SetFault makes the calls of the method fail, the method is the short name such as ListContainers
+func (s *Server) SetFault(method string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[method] = &fault
+}
+
+// ClearFaults removes all faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = make(map[string]*Fault)
+}
+
+// SetExecFunc sets the ExecSync handler, the default handler returns empty output with exit code 0
+func (s *Server) SetExecFunc(fn ExecFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.execFunc = fn
+}
+
+// Calls returns the short method names called in order
+func (s *Server) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.calls...)
+}
+
+// intercept records the call and applies the fault of the method
+func (s *Server) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	method := path.Base(info.FullMethod)
+	s.mu.Lock()
+	s.calls = append(s.calls, method)
+	var fault Fault
+	f, faulted := s.faults[method]
+	if faulted {
+		fault = *f
+		if f.Times > 0 {
+			if f.Times--; f.Times == 0 {
+				delete(s.faults, method)
+			}
+		}
+	}
+	s.mu.Unlock()
+	if fault.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-time.After(fault.Delay):
+		}
+	}
+	if faulted && fault.Code != codes.OK {
+		message := fault.Message
+		if message == "" {
+			message = fmt.Sprintf("fake fault of %s", method)
+		}
+		return nil, status.Error(fault.Code, message)
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) Version(ctx context.Context, req *v1.VersionRequest) (*v1.VersionResponse, error) {
+	return &v1.VersionResponse{
+		Version:           "0.1.0",
+		RuntimeName:       RuntimeName,
+		RuntimeVersion:    RuntimeVersion,
+		RuntimeApiVersion: RuntimeApiVersion,
+	}, nil
+}
+
+func (s *Server) ListContainers(ctx context.Context, req *v1.ListContainersRequest) (*v1.ListContainersResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	containers := make([]*v1.Container, 0, len(s.containers))
+	for _, c := range s.containers {
+		if !matchFilter(c, req.Filter) {
+			continue
+		}
+		containers = append(containers, &v1.Container{
+			Id:           c.Id,
+			PodSandboxId: c.PodSandboxId,
+			Metadata:     &v1.ContainerMetadata{Name: c.Name, Attempt: c.Attempt},
+			Image:        &v1.ImageSpec{Image: c.Image},
+			ImageRef:     c.Image,
+			State:        c.State,
+			CreatedAt:    c.CreatedAt.UnixNano(),
+			Labels:       c.Labels,
+			Annotations:  c.Annotations,
+		})
+	}
+	return &v1.ListContainersResponse{Containers: containers}, nil
+}
+
+func matchFilter(c *Container, filter *v1.ContainerFilter) bool {
+	if filter == nil {
+		return true
+	}
+	if filter.Id != "" && filter.Id != c.Id {
+		return false
+	}
+	if filter.State != nil && filter.State.State != c.State {
+		return false
+	}
+	if filter.PodSandboxId != "" && filter.PodSandboxId != c.PodSandboxId {
+		return false
+	}
+	for k, v := range filter.LabelSelector {
+		if c.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) ContainerStatus(ctx context.Context, req *v1.ContainerStatusRequest) (*v1.ContainerStatusResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.containers[req.ContainerId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "could not find container %q", req.ContainerId)
+	}
+	response := &v1.ContainerStatusResponse{
+		Status: &v1.ContainerStatus{
+			Id:          c.Id,
+			Metadata:    &v1.ContainerMetadata{Name: c.Name, Attempt: c.Attempt},
+			State:       c.State,
+			CreatedAt:   c.CreatedAt.UnixNano(),
+			StartedAt:   unixNano(c.StartedAt),
+			FinishedAt:  unixNano(c.FinishedAt),
+			Mounts:      c.Mounts,
+			ExitCode:    c.ExitCode,
+			Reason:      c.Reason,
+			Message:     c.Message,
+			Image:       &v1.ImageSpec{Image: c.Image},
+			ImageRef:    c.Image,
+			Labels:      c.Labels,
+			Annotations: c.Annotations,
+		},
+	}
+	if req.Verbose {
+		response.Info = c.Info
+		if response.Info == nil {
+			info, _ := json.Marshal(map[string]interface{}{"pid": c.Pid, "sandboxID": c.PodSandboxId})
+			response.Info = map[string]string{"info": string(info)}
+		}
+	}
+	return response, nil
+}
+
+func (s *Server) PodSandboxStatus(ctx context.Context, req *v1.PodSandboxStatusRequest) (*v1.PodSandboxStatusResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ips, ok := s.sandboxes[req.PodSandboxId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "could not find pod %q", req.PodSandboxId)
+	}
+	network := &v1.PodSandboxNetworkStatus{}
+	for i, ip := range ips {
+		if i == 0 {
+			network.Ip = ip
+			continue
+		}
+		network.AdditionalIps = append(network.AdditionalIps, &v1.PodIP{Ip: ip})
+	}
+	return &v1.PodSandboxStatusResponse{Status: &v1.PodSandboxStatus{
+		Id:          req.PodSandboxId,
+		State:       s.sandboxState(req.PodSandboxId),
+		Network:     network,
+		Annotations: s.podAnnotations[req.PodSandboxId],
+	}}, nil
+}
+
+func (s *Server) sandboxState(id string) v1.PodSandboxState {
+	if s.podStopped[id] {
+		return v1.PodSandboxState_SANDBOX_NOTREADY
+	}
+	return v1.PodSandboxState_SANDBOX_READY
+}
+
+func (s *Server) ListPodSandbox(ctx context.Context, req *v1.ListPodSandboxRequest) (*v1.ListPodSandboxResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.sandboxes))
+	for id := range s.sandboxes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	response := &v1.ListPodSandboxResponse{}
+	for i, id := range ids {
+		response.Items = append(response.Items, &v1.PodSandbox{
+			Id:       id,
+			Metadata: s.podMetadata[id],
+			State:    s.sandboxState(id),
+			// the sandboxes are created in the order of their ids
+			CreatedAt: int64(i + 1),
+			Labels:    s.podLabels[id],
+		})
+	}
+	return response, nil
+}
+
+// StopPodSandbox stops the sandbox and its containers
+func (s *Server) StopPodSandbox(ctx context.Context, req *v1.StopPodSandboxRequest) (*v1.StopPodSandboxResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sandboxes[req.PodSandboxId]; !ok {
+		return nil, status.Errorf(codes.NotFound, "could not find pod %q", req.PodSandboxId)
+	}
+	s.podStopped[req.PodSandboxId] = true
+	for _, c := range s.containers {
+		if c.PodSandboxId == req.PodSandboxId && c.State == v1.ContainerState_CONTAINER_RUNNING {
+			c.State, c.FinishedAt = v1.ContainerState_CONTAINER_EXITED, time.Now()
+		}
+	}
+	return &v1.StopPodSandboxResponse{}, nil
+}
+
+func (s *Server) CreateContainer(ctx context.Context, req *v1.CreateContainerRequest) (*v1.CreateContainerResponse, error) {
+	if req.Config == nil || req.Config.Metadata == nil {
+		return nil, status.Error(codes.InvalidArgument, "container config metadata is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	c := &Container{
+		Id:           fmt.Sprintf("fake-%d", s.seq),
+		Name:         req.Config.Metadata.Name,
+		PodSandboxId: req.PodSandboxId,
+		Labels:       req.Config.Labels,
+		Annotations:  req.Config.Annotations,
+		State:        v1.ContainerState_CONTAINER_CREATED,
+		CreatedAt:    time.Now(),
+	}
+	if req.Config.Image != nil {
+		c.Image = req.Config.Image.Image
+	}
+	s.containers[c.Id] = c
+	return &v1.CreateContainerResponse{ContainerId: c.Id}, nil
+}
+
+func (s *Server) StartContainer(ctx context.Context, req *v1.StartContainerRequest) (*v1.StartContainerResponse, error) {
+	if err := s.setState(req.ContainerId, v1.ContainerState_CONTAINER_RUNNING); err != nil {
+		return nil, err
+	}
+	return &v1.StartContainerResponse{}, nil
+}
+
+func (s *Server) StopContainer(ctx context.Context, req *v1.StopContainerRequest) (*v1.StopContainerResponse, error) {
+	if err := s.setState(req.ContainerId, v1.ContainerState_CONTAINER_EXITED); err != nil {
+		return nil, err
+	}
+	return &v1.StopContainerResponse{}, nil
+}
+
+func (s *Server) setState(id string, state v1.ContainerState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.containers[id]
+	if !ok {
+		return status.Errorf(codes.NotFound, "could not find container %q", id)
+	}
+	c.State = state
+	return nil
+}
+
+// RemoveContainer is idempotent as the CRI requires
+func (s *Server) RemoveContainer(ctx context.Context, req *v1.RemoveContainerRequest) (*v1.RemoveContainerResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.containers, req.ContainerId)
+	return &v1.RemoveContainerResponse{}, nil
+}
+
+func (s *Server) ExecSync(ctx context.Context, req *v1.ExecSyncRequest) (*v1.ExecSyncResponse, error) {
+	s.mu.Lock()
+	c, ok := s.containers[req.ContainerId]
+	fn := s.execFunc
+	s.mu.Unlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "could not find container %q", req.ContainerId)
+	}
+	if c.State != v1.ContainerState_CONTAINER_RUNNING {
+		return nil, status.Errorf(codes.FailedPrecondition, "container %q is not running", req.ContainerId)
+	}
+	if fn == nil {
+		return &v1.ExecSyncResponse{}, nil
+	}
+	stdout, stderr, exitCode := fn(req.ContainerId, req.Cmd)
+	return &v1.ExecSyncResponse{Stdout: stdout, Stderr: stderr, ExitCode: exitCode}, nil
+}
+
+func (s *Server) ListImages(ctx context.Context, req *v1.ListImagesRequest) (*v1.ListImagesResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	images := make([]*v1.Image, 0, len(s.images))
+	for ref, image := range s.images {
+		if req.Filter != nil && req.Filter.Image != nil && req.Filter.Image.Image != "" && req.Filter.Image.Image != ref {
+			continue
+		}
+		images = append(images, image)
+	}
+	return &v1.ListImagesResponse{Images: images}, nil
+}
+
+// ImageStatus returns nil image if not found as the CRI requires
+func (s *Server) ImageStatus(ctx context.Context, req *v1.ImageStatusRequest) (*v1.ImageStatusResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Image == nil {
+		return nil, status.Error(codes.InvalidArgument, "image spec is required")
+	}
+	return &v1.ImageStatusResponse{Image: s.images[req.Image.Image]}, nil
+}
+
+func (s *Server) PullImage(ctx context.Context, req *v1.PullImageRequest) (*v1.PullImageResponse, error) {
+	if req.Image == nil || req.Image.Image == "" {
+		return nil, status.Error(codes.InvalidArgument, "image spec is required")
+	}
+	s.AddImage(req.Image.Image)
+	return &v1.PullImageResponse{ImageRef: req.Image.Image}, nil
+}
+
+func (s *Server) RemoveImage(ctx context.Context, req *v1.RemoveImageRequest) (*v1.RemoveImageResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Image != nil {
+		delete(s.images, req.Image.Image)
+	}
+	return &v1.RemoveImageResponse{}, nil
+}
+
+// ImageFsInfo reports the image filesystem set by SetImageFs
+func (s *Server) ImageFsInfo(ctx context.Context, req *v1.ImageFsInfoRequest) (*v1.ImageFsInfoResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.imageFs == "" {
+		return &v1.ImageFsInfoResponse{}, nil
+	}
+	usage := &v1.FilesystemUsage{
+		Timestamp:  time.Now().UnixNano(),
+		FsId:       &v1.FilesystemIdentifier{Mountpoint: s.imageFs},
+		UsedBytes:  &v1.UInt64Value{Value: uint64(len(s.images))},
+		InodesUsed: &v1.UInt64Value{Value: uint64(len(s.images))},
+	}
+	return &v1.ImageFsInfoResponse{ImageFilesystems: []*v1.FilesystemUsage{usage}}, nil
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// The websocket protocols of the streaming server, v5 closes the stdin
+const (
+	StreamProtocolV5 = "v5.channel.k8s.io"
+	StreamProtocolV4 = "v4.channel.k8s.io"
+)
+
+// AttachFunc serves the attach of the container as its main process, the streams not attached are empty or discarded,
+// it returns the exit code of the process
+type AttachFunc func(containerId string, stdin io.Reader, stdout, stderr io.Writer) int32
+
+// SetAttachFunc sets the attach handler, the default handler exits with code 0 immediately
+func (s *Server) SetAttachFunc(fn AttachFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attachFunc = fn
+}
+
+// SetStreamProtocols sets the websocket protocols of the streaming server, the default is v5 and v4, set v4 only to
+// serve as the runtimes before kubernetes 1.30
+func (s *Server) SetStreamProtocols(protocols ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streamProtocols = protocols
+}
+
+func (s *Server) Attach(ctx context.Context, req *v1.AttachRequest) (*v1.AttachResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.containers[req.ContainerId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "could not find container %q", req.ContainerId)
+	}
+	if c.State != v1.ContainerState_CONTAINER_RUNNING {
+		return nil, status.Errorf(codes.FailedPrecondition, "container %q is not running", req.ContainerId)
+	}
+	if !req.Stdin && !req.Stdout && !req.Stderr {
+		return nil, status.Error(codes.InvalidArgument, "one of stdin, stdout, or stderr must be set")
+	}
+	if s.streaming == nil {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		s.streamAddr = listener.Addr().String()
+		s.streaming = &http.Server{Handler: websocket.Server{Handshake: s.handshake, Handler: s.serveAttach}}
+		go s.streaming.Serve(listener)
+	}
+	s.seq++
+	token := strconv.Itoa(s.seq)
+	s.attaches[token] = req
+	return &v1.AttachResponse{Url: fmt.Sprintf("http://%s/attach/%s", s.streamAddr, token)}, nil
+}
+
+// handshake accepts the first protocol offered and supported
+func (s *Server) handshake(config *websocket.Config, req *http.Request) error {
+	s.mu.Lock()
+	supported := s.streamProtocols
+	s.mu.Unlock()
+	if len(supported) == 0 {
+		supported = []string{StreamProtocolV5, StreamProtocolV4}
+	}
+	for _, offered := range config.Protocol {
+		for _, protocol := range supported {
+			if offered == protocol {
+				config.Protocol = []string{protocol}
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unsupported protocols %v", config.Protocol)
+}
+
+// serveAttach serves the attach of the token once, the status is written to the error channel after the handler
+func (s *Server) serveAttach(ws *websocket.Conn) {
+	defer ws.Close()
+	token := strings.TrimPrefix(ws.Request().URL.Path, "/attach/")
+	s.mu.Lock()
+	req, ok := s.attaches[token]
+	delete(s.attaches, token)
+	fn := s.attachFunc
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	stdinReader, stdinWriter := io.Pipe()
+	go func() {
+		defer stdinWriter.Close()
+		for {
+			var message []byte
+			if err := websocket.Message.Receive(ws, &message); err != nil || len(message) == 0 {
+				return
+			}
+			switch message[0] {
+			case 0:
+				stdinWriter.Write(message[1:])
+			case 255:
+				return
+			}
+		}
+	}()
+	var stdin io.Reader = strings.NewReader("")
+	var stdout, stderr io.Writer = io.Discard, io.Discard
+	if req.Stdin {
+		stdin = stdinReader
+	}
+	if req.Stdout {
+		stdout = channelWriter{ws, 1}
+	}
+	if req.Stderr {
+		stderr = channelWriter{ws, 2}
+	}
+	var exitCode int32
+	if fn != nil {
+		exitCode = fn(req.ContainerId, stdin, stdout, stderr)
+	}
+	result := map[string]interface{}{"metadata": map[string]string{}, "status": "Success"}
+	if exitCode != 0 {
+		result = map[string]interface{}{"metadata": map[string]string{}, "status": "Failure",
+			"message": fmt.Sprintf("command terminated with non-zero exit code: %d", exitCode),
+			"reason":  "NonZeroExitCode",
+			"details": map[string]interface{}{"causes": []map[string]string{
+				{"reason": "ExitCode", "message": strconv.Itoa(int(exitCode))}}}}
+	}
+	content, _ := json.Marshal(result)
+	websocket.Message.Send(ws, append([]byte{3}, content...))
+}
+
+// channelWriter writes the messages of the channel
+type channelWriter struct {
+	ws      *websocket.Conn
+	channel byte
+}
+
+func (w channelWriter) Write(p []byte) (int, error) {
+	if err := websocket.Message.Send(w.ws, append([]byte{w.channel}, p...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
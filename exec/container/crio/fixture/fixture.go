@@ -0,0 +1,277 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fixture records the CRI request and response pairs to json fixtures and replays them,
+// so the behaviors against a specific runtime version can be reproduced without the runtime
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const redacted = "<redacted>"
+
+// sensitiveKey matches the keys whose values are redacted
+var sensitiveKey = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|auth|apikey|api_key|private)`)
+
+// Fixture is the recorded interactions with a runtime
+type Fixture struct {
+	// Runtime describes the runtime and version the fixture is recorded against, such as cri-o 1.28.1
+	Runtime      string        `json:"runtime,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one unary call, the request and response are the json of the cri messages
+type Interaction struct {
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Code     codes.Code      `json:"code,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Load reads the fixture file
+func Load(file string) (*Fixture, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("fixture %s is illegal, %v", file, err)
+	}
+	return &fixture, nil
+}
+
+// Save writes the fixture file
+func (f *Fixture) Save(file string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Recorder captures the sanitized interactions through the client interceptor
+type Recorder struct {
+	mu      sync.Mutex
+	fixture Fixture
+	file    string
+}
+
+// NewRecorder creates the recorder for the runtime description
+func NewRecorder(runtime string) *Recorder {
+	return &Recorder{fixture: Fixture{Runtime: runtime, Interactions: make([]Interaction, 0)}}
+}
+
+// NewFileRecorder creates the recorder which saves the fixture file after every call, it is used by the
+// short-lived cli processes which never close the client
+func NewFileRecorder(runtime, file string) *Recorder {
+	r := NewRecorder(runtime)
+	r.file = file
+	return r
+}
+
+// DialOption returns the dial option installing the recorder
+func (r *Recorder) DialOption() grpc.DialOption {
+	return grpc.WithUnaryInterceptor(r.intercept)
+}
+
+func (r *Recorder) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	interaction := Interaction{Method: method}
+	interaction.Request, _ = sanitize(req)
+	if err != nil {
+		s, _ := status.FromError(err)
+		interaction.Code, interaction.Error = s.Code(), s.Message()
+	} else {
+		interaction.Response, _ = sanitize(reply)
+	}
+	r.mu.Lock()
+	r.fixture.Interactions = append(r.fixture.Interactions, interaction)
+	r.mu.Unlock()
+	if r.file != "" {
+		if saveErr := r.Save(r.file); saveErr != nil {
+			log.Warnf(ctx, "save cri fixture %s failed, %v", r.file, saveErr)
+		}
+	}
+	return err
+}
+
+// Fixture returns a copy of the recorded fixture
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Fixture{
+		Runtime:      r.fixture.Runtime,
+		Interactions: append([]Interaction{}, r.fixture.Interactions...),
+	}
+}
+
+// Save writes the recorded fixture file
+func (r *Recorder) Save(file string) error {
+	return r.Fixture().Save(file)
+}
+
+// Replayer serves the calls from the fixture, a call is matched by the method and the request,
+// the unused interactions are preferred so the repeated calls replay the recorded order
+type Replayer struct {
+	mu      sync.Mutex
+	fixture *Fixture
+	used    []bool
+}
+
+// NewReplayer creates the replayer of the fixture
+func NewReplayer(fixture *Fixture) *Replayer {
+	return &Replayer{fixture: fixture, used: make([]bool, len(fixture.Interactions))}
+}
+
+// Dial returns a connection whose calls never leave the process
+func (r *Replayer) Dial() (*grpc.ClientConn, error) {
+	return grpc.Dial("passthrough:///fixture", grpc.WithInsecure(), grpc.WithUnaryInterceptor(r.intercept),
+		grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			return nil, fmt.Errorf("the fixture connection is replayed only")
+		}))
+}
+
+func (r *Replayer) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	request, err := sanitize(req)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	interaction, ok := r.match(method, request)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "no recorded interaction of %s with request %s", method, request)
+	}
+	if interaction.Code != codes.OK {
+		return status.Error(interaction.Code, interaction.Error)
+	}
+	if err := json.Unmarshal(interaction.Response, reply); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+func (r *Replayer) match(method string, request json.RawMessage) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := -1
+	for i, interaction := range r.fixture.Interactions {
+		if interaction.Method != method || !jsonEqual(interaction.Request, request) {
+			continue
+		}
+		if !r.used[i] {
+			found = i
+			break
+		}
+		if found < 0 {
+			found = i
+		}
+	}
+	if found < 0 {
+		return Interaction{}, false
+	}
+	r.used[found] = true
+	return r.fixture.Interactions[found], true
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// sanitize marshals the message and redacts the sensitive values, including the environment variables
+// and the values of the sensitive keys in the nested json strings such as the verbose info
+func sanitize(message interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redact("", value))
+}
+
+func redact(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = redact(k, item)
+		}
+		return v
+	case []interface{}:
+		isEnv := strings.EqualFold(key, "env") || strings.EqualFold(key, "envs")
+		for i, item := range v {
+			if isEnv {
+				// the runtime spec envs are KEY=VALUE, the cri envs are {"key":...,"value":...}
+				if s, ok := item.(string); ok {
+					if idx := strings.Index(s, "="); idx > 0 {
+						v[i] = s[:idx+1] + redacted
+					}
+					continue
+				}
+				if kv, ok := item.(map[string]interface{}); ok {
+					if _, ok := kv["value"]; ok {
+						kv["value"] = redacted
+					}
+					continue
+				}
+			}
+			v[i] = redact(key, item)
+		}
+		return v
+	case string:
+		if sensitiveKey.MatchString(key) {
+			return redacted
+		}
+		// the verbose info values are json strings
+		if strings.HasPrefix(strings.TrimSpace(v), "{") {
+			var nested interface{}
+			if json.Unmarshal([]byte(v), &nested) == nil {
+				if data, err := json.Marshal(redact(key, nested)); err == nil {
+					return string(data)
+				}
+			}
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio/fake"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio/fixture"
+)
+
+func newReplayClient(t *testing.T, f *fixture.Fixture) *CRIClient {
+	t.Helper()
+	conn, err := fixture.NewReplayer(f).Dial()
+	if err != nil {
+		t.Fatalf("dial replayer failed, %v", err)
+	}
+	client := NewClientFromConn(conn, "")
+	t.Cleanup(func() {
+		client.Cancel()
+		client.Close()
+	})
+	return client
+}
+
+func TestReplayRuntimeInfo(t *testing.T) {
+	tests := []struct {
+		file string
+		pid  int32
+	}{
+		{"testdata/crio-1.28.json", 4242},
+		{"testdata/containerd-1.7.json", 3131},
+	}
+	for _, tt := range tests {
+		t.Run(path.Base(tt.file), func(t *testing.T) {
+			f, err := fixture.Load(tt.file)
+			if err != nil {
+				t.Fatalf("load fixture failed, %v", err)
+			}
+			client := newReplayClient(t, f)
+			pid, err, _ := client.GetPidById(context.Background(), "c0ffee")
+			if err != nil {
+				t.Fatalf("GetPidById failed, %v", err)
+			}
+			if pid != tt.pid {
+				t.Errorf("expected pid %d, got %d", tt.pid, pid)
+			}
+			info, err, _ := client.GetContainerById(context.Background(), "c0ffee")
+			if err != nil {
+				t.Fatalf("GetContainerById failed, %v", err)
+			}
+			if info.ContainerName != "nginx" {
+				t.Errorf("expected container nginx, got %s", info.ContainerName)
+			}
+		})
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := fake.NewServer(fake.Container{
+		Id:          "c1",
+		Name:        "nginx",
+		State:       v1.ContainerState_CONTAINER_RUNNING,
+		Pid:         1234,
+		Annotations: map[string]string{"api-token": "s3cr3t"},
+	})
+	endpoint, err := server.Start()
+	if err != nil {
+		t.Fatalf("start fake cri server failed, %v", err)
+	}
+	defer server.Stop()
+
+	recorder := fixture.NewRecorder("fake")
+	conn, err := grpc.Dial(endpoint, grpc.WithInsecure(), recorder.DialOption())
+	if err != nil {
+		t.Fatalf("dial fake cri server failed, %v", err)
+	}
+	recording := NewClientFromConn(conn, "")
+	defer recording.Close()
+	if _, err, _ := recording.GetPidById(context.Background(), "c1"); err != nil {
+		t.Fatalf("GetPidById failed, %v", err)
+	}
+	if _, err, _ := recording.GetContainerById(context.Background(), "missing"); err == nil {
+		t.Fatal("expected error for missing container")
+	}
+
+	file := path.Join(t.TempDir(), "fixture.json")
+	if err := recorder.Save(file); err != nil {
+		t.Fatalf("save fixture failed, %v", err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read fixture failed, %v", err)
+	}
+	if strings.Contains(string(data), "s3cr3t") {
+		t.Error("the token is not redacted")
+	}
+
+	f, err := fixture.Load(file)
+	if err != nil {
+		t.Fatalf("load fixture failed, %v", err)
+	}
+	client := newReplayClient(t, f)
+	pid, err, _ := client.GetPidById(context.Background(), "c1")
+	if err != nil || pid != 1234 {
+		t.Errorf("expected pid 1234, got %d, %v", pid, err)
+	}
+	if _, err, _ := client.GetContainerById(context.Background(), "missing"); err == nil {
+		t.Error("expected the recorded error")
+	}
+	if _, err, _ := client.ListContainers(context.Background()); err == nil {
+		t.Error("expected error for the call not recorded")
+	}
+}
+
+func TestReplayRootfsInfo(t *testing.T) {
+	tests := []struct {
+		file   string
+		driver string
+		path   string
+	}{
+		{"testdata/crio-1.28.json", "overlay", "/var/lib/containers/storage/overlay/9f1e/merged"},
+		{"testdata/containerd-1.7.json", "overlayfs", "/run/containerd/io.containerd.runtime.v2.task/k8s.io/c0ffee/rootfs"},
+	}
+	for _, tt := range tests {
+		t.Run(path.Base(tt.file), func(t *testing.T) {
+			f, err := fixture.Load(tt.file)
+			if err != nil {
+				t.Fatalf("load fixture failed, %v", err)
+			}
+			var response v1.ContainerStatusResponse
+			if err := json.Unmarshal(f.Interactions[0].Response, &response); err != nil {
+				t.Fatalf("unmarshal response failed, %v", err)
+			}
+			hint, err := parseRootfsFromInfo("c0ffee", DefaultContainerdNameSpace, response.Info)
+			if err != nil {
+				t.Fatalf("parseRootfsFromInfo failed, %v", err)
+			}
+			if hint.Driver != tt.driver || hint.Path != tt.path || hint.Pid <= 0 {
+				t.Errorf("unexpected rootfs hint %+v", hint)
+			}
+		})
+	}
+}
+
+func TestReplayCgroupInfo(t *testing.T) {
+	tests := []struct {
+		file        string
+		cgroupsPath string
+	}{
+		{"testdata/crio-1.28.json", "kubepods-besteffort-pod1234.slice:crio:c0ffee"},
+		{"testdata/containerd-1.7.json", "kubepods-besteffort-pod1234.slice:cri-containerd:c0ffee"},
+	}
+	for _, tt := range tests {
+		t.Run(path.Base(tt.file), func(t *testing.T) {
+			f, err := fixture.Load(tt.file)
+			if err != nil {
+				t.Fatalf("load fixture failed, %v", err)
+			}
+			var response v1.ContainerStatusResponse
+			if err := json.Unmarshal(f.Interactions[0].Response, &response); err != nil {
+				t.Fatalf("unmarshal response failed, %v", err)
+			}
+			pid, cgroupsPath, err := parseCgroupFromInfo(response.Info)
+			if err != nil {
+				t.Fatalf("parseCgroupFromInfo failed, %v", err)
+			}
+			if pid <= 0 || cgroupsPath != tt.cgroupsPath {
+				t.Errorf("unexpected pid %d and cgroups path %s", pid, cgroupsPath)
+			}
+		})
+	}
+}
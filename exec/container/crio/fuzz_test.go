@@ -0,0 +1,75 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"testing"
+
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+func FuzzParsePidFromInfo(f *testing.F) {
+	for _, seed := range []string{
+		`{"pid":1234}`,
+		`{"pid":"1234"}`,
+		`{"pid":-1}`,
+		`{"pid":1.5}`,
+		`{"pid":1e300}`,
+		`{"pid":null}`,
+		`{"sandboxID":"abc","pid":42,"runtimeSpec":{"process":{"env":["A=B"]}}}`,
+		`[]`,
+		`null`,
+		`{`,
+		``,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, info string) {
+		pid, err := parsePidFromInfo(map[string]string{"info": info})
+		if err == nil && pid <= 0 {
+			t.Errorf("illegal pid %d parsed from %q", pid, info)
+		}
+		if err != nil && pid != -1 {
+			t.Errorf("expected pid -1 on error, got %d", pid)
+		}
+	})
+}
+
+func FuzzConvertContainer(f *testing.F) {
+	seed, _ := (&v1.Container{
+		Id:       "c1",
+		Metadata: &v1.ContainerMetadata{Name: "nginx"},
+		Labels:   map[string]string{"app": "web"},
+	}).Marshal()
+	f.Add(seed, "app", "web")
+	f.Add([]byte{}, "", "")
+	f.Fuzz(func(t *testing.T, data []byte, key, value string) {
+		c := &v1.Container{}
+		if err := c.Unmarshal(data); err != nil {
+			return
+		}
+		info := convertContainerInfo2(c)
+		if info.ContainerId != c.Id {
+			t.Errorf("expected id %q, got %q", c.Id, info.ContainerId)
+		}
+		if matchLabels(c, map[string]string{key: value}) && c.Labels[key] != value {
+			t.Errorf("container %v matched the selector %s=%s", c.Labels, key, value)
+		}
+		status := &v1.ContainerStatus{Id: c.Id, Metadata: c.Metadata, Labels: c.Labels}
+		convertContainerInfo(status)
+	})
+}
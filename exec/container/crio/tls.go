@@ -0,0 +1,88 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// TLSOptions 是 tcp 暴露的 cri endpoint 的 tls 配置, CertFile 和 KeyFile 是 mtls 的客户端证书, 都为空时使用非安全连接
+type TLSOptions struct {
+	// CAFile 是校验服务端证书的 ca 证书, 为空时使用系统的 ca
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// ServerName 校验服务端证书的名称, 为空时使用 endpoint 的主机名
+	ServerName string
+}
+
+// Enabled 返回是否使用 tls 连接
+func (o TLSOptions) Enabled() bool {
+	return o != TLSOptions{}
+}
+
+// Config 返回 tls 配置, 证书文件不存在或非法时返回错误
+func (o TLSOptions) Config() (*tls.Config, error) {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, errors.New("the client certificate and key must be given together")
+	}
+	config := &tls.Config{ServerName: o.ServerName, MinVersion: tls.VersionTLS12}
+	if o.CAFile != "" {
+		content, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file %s: %v", o.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no certificate found in ca file %s", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if o.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s: %v", o.CertFile, err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// transportOption 返回连接的传输凭证, 未配置 tls 时使用非安全连接
+func (o TLSOptions) transportOption() (grpc.DialOption, error) {
+	if !o.Enabled() {
+		return grpc.WithInsecure(), nil
+	}
+	config, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(config)), nil
+}
+
+// dialTarget 返回 grpc 的连接目标, crictl 格式的 tcp://host:port 转换为 host:port, unix 等 grpc 支持的 scheme 不变
+func dialTarget(endpoint string) string {
+	return strings.TrimPrefix(endpoint, "tcp://")
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio/fake"
+)
+
+// testPKI is a ca with the certificates of the server and the client, the pem files are written under dir
+type testPKI struct {
+	dir    string
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	pool   *x509.CertPool
+	serial int64
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	p := &testPKI{dir: t.TempDir(), pool: x509.NewCertPool()}
+	p.ca, p.caKey = p.issue(t, "ca", &x509.Certificate{IsCA: true, KeyUsage: x509.KeyUsageCertSign,
+		BasicConstraintsValid: true})
+	p.pool.AddCert(p.ca)
+	return p
+}
+
+// issue signs the template by the ca, or by itself if the ca is absent, and writes name.pem and name-key.pem
+func (p *testPKI) issue(t *testing.T, name string, template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.serial++
+	template.SerialNumber = big.NewInt(p.serial)
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore, template.NotAfter = time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	parent, signer := template, key
+	if p.ca != nil {
+		parent, signer = p.ca, p.caKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path.Join(p.dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(path.Join(p.dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate, key
+}
+
+func (p *testPKI) file(name string) string {
+	return path.Join(p.dir, name)
+}
+
+// startTLSServer starts the fake runtime requiring the client certificates of the ca
+func startTLSServer(t *testing.T, p *testPKI) string {
+	t.Helper()
+	p.issue(t, "server", &x509.Certificate{DNSNames: []string{"cri.local"}, IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}})
+	certificate, err := tls.LoadX509KeyPair(p.file("server.pem"), p.file("server-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := fake.NewServer(testContainers...)
+	endpoint, err := server.StartTLS(&tls.Config{Certificates: []tls.Certificate{certificate}, ClientCAs: p.pool,
+		ClientAuth: tls.RequireAndVerifyClientCert})
+	if err != nil {
+		t.Fatalf("start fake cri server failed, %v", err)
+	}
+	CloseClient()
+	t.Cleanup(func() {
+		CloseClient()
+		server.Stop()
+	})
+	return endpoint
+}
+
+func TestNewClientWithTLS(t *testing.T) {
+	p := newTestPKI(t)
+	endpoint := startTLSServer(t, p)
+	p.issue(t, "client", &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	options := TLSOptions{CAFile: p.file("ca.pem"), CertFile: p.file("client.pem"), KeyFile: p.file("client-key.pem"),
+		ServerName: "cri.local"}
+	client, err := NewClientWithTLS(endpoint, "", options)
+	if err != nil {
+		t.Fatalf("connect the tls endpoint failed, %v", err)
+	}
+	info, err, _ := client.GetContainerById(context.Background(), "c1")
+	if err != nil || info.ContainerName != "nginx" {
+		t.Errorf("unexpected container %+v, %v", info, err)
+	}
+}
+
+// TestNewClientWithTLSCache dials the same endpoint with the tls and without, the cached tls connection is never
+// returned to the insecure client
+func TestNewClientWithTLSCache(t *testing.T) {
+	p := newTestPKI(t)
+	endpoint := startTLSServer(t, p)
+	p.issue(t, "client", &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	options := TLSOptions{CAFile: p.file("ca.pem"), CertFile: p.file("client.pem"), KeyFile: p.file("client-key.pem"),
+		ServerName: "cri.local"}
+	secure, err := NewClientWithTLS(endpoint, "", options)
+	if err != nil {
+		t.Fatalf("connect the tls endpoint failed, %v", err)
+	}
+	if again, err := NewClientWithTLS(endpoint, "", options); err != nil || again != secure {
+		t.Errorf("expected the tls connection reused, %v", err)
+	}
+	if insecure, err := NewClient(endpoint, ""); err == nil {
+		if insecure == secure {
+			t.Fatal("expected the insecure client not reusing the tls connection")
+		}
+		if _, err, _ := insecure.ListContainers(context.Background()); err == nil {
+			t.Error("expected the insecure client refused by the tls endpoint")
+		}
+	}
+}
+
+func TestNewClientWithTLSUntrusted(t *testing.T) {
+	p := newTestPKI(t)
+	endpoint := startTLSServer(t, p)
+	// the server is verified by the system cas without the ca file
+	other := newTestPKI(t)
+	other.issue(t, "client", &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	options := TLSOptions{CertFile: other.file("client.pem"), KeyFile: other.file("client-key.pem")}
+	if client, err := NewClientWithTLS(endpoint, "", options); err == nil {
+		if _, err, _ := client.ListContainers(context.Background()); err == nil {
+			t.Error("expected the untrusted server refused")
+		}
+	}
+}
+
+func TestTLSOptionsConfig(t *testing.T) {
+	p := newTestPKI(t)
+	p.issue(t, "client", &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	if (TLSOptions{}).Enabled() || !(TLSOptions{ServerName: "cri.local"}).Enabled() {
+		t.Error("expected the tls enabled by any option")
+	}
+	config, err := TLSOptions{CAFile: p.file("ca.pem"), CertFile: p.file("client.pem"),
+		KeyFile: p.file("client-key.pem"), ServerName: "cri.local"}.Config()
+	if err != nil || config.RootCAs == nil || len(config.Certificates) != 1 || config.ServerName != "cri.local" ||
+		config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("unexpected config %+v, %v", config, err)
+	}
+	for _, options := range []TLSOptions{
+		{CertFile: p.file("client.pem")},
+		{KeyFile: p.file("client-key.pem")},
+		{CAFile: p.file("missing.pem")},
+		{CAFile: p.file("client-key.pem")},
+		{CertFile: p.file("client.pem"), KeyFile: p.file("ca-key.pem")},
+	} {
+		if _, err := options.Config(); err == nil {
+			t.Errorf("expected error of the options %+v", options)
+		}
+	}
+}
+
+func TestDialTarget(t *testing.T) {
+	for endpoint, expected := range map[string]string{
+		"tcp://10.0.0.1:10010":           "10.0.0.1:10010",
+		"unix:///var/run/crio/crio.sock": "unix:///var/run/crio/crio.sock",
+		"10.0.0.1:10010":                 "10.0.0.1:10010",
+	} {
+		if target := dialTarget(endpoint); target != expected {
+			t.Errorf("expected the target %s of %s, got %s", expected, endpoint, target)
+		}
+	}
+}
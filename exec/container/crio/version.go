@@ -0,0 +1,85 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The cri api versions negotiated with the runtime
+const (
+	APIVersionV1       = "v1"
+	APIVersionV1alpha2 = "v1alpha2"
+)
+
+const (
+	v1MethodPrefix       = "/runtime.v1."
+	v1alpha2MethodPrefix = "/runtime.v1alpha2."
+)
+
+// apiNegotiator 协商连接的 cri api 版本, 只提供 v1alpha2 的运行时, 例如 containerd 1.5 之前和 crio 1.20 之前, 的调用
+// 将 v1 的方法名改写为 v1alpha2. cri-api v0.20 的 v1 由 v1alpha2 复制而来, 两者消息的字段编号相同, 编码兼容
+type apiNegotiator struct {
+	v1alpha2 int32
+}
+
+// Version 返回协商的 api 版本, 未协商时为 v1
+func (n *apiNegotiator) Version() string {
+	if n != nil && atomic.LoadInt32(&n.v1alpha2) == 1 {
+		return APIVersionV1alpha2
+	}
+	return APIVersionV1
+}
+
+func (n *apiNegotiator) method(method string) string {
+	if n.Version() == APIVersionV1alpha2 && strings.HasPrefix(method, v1MethodPrefix) {
+		return v1alpha2MethodPrefix + strings.TrimPrefix(method, v1MethodPrefix)
+	}
+	return method
+}
+
+// dialOptions 返回改写方法名的拦截器, 在 fixture 的录制之后执行, 录制的方法名仍为 v1
+func (n *apiNegotiator) dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{},
+			cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(ctx, n.method(method), req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+			method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(ctx, desc, cc, n.method(method), opts...)
+		}),
+	}
+}
+
+// negotiate 调用 probe 协商 api 版本, v1 返回 Unimplemented 且 v1alpha2 成功时使用 v1alpha2, 其他错误保持 v1, 由之后的调用
+// 报告
+func (n *apiNegotiator) negotiate(ctx context.Context, probe func(ctx context.Context) error) {
+	if err := probe(ctx); status.Code(err) != codes.Unimplemented {
+		return
+	}
+	atomic.StoreInt32(&n.v1alpha2, 1)
+	if err := probe(ctx); err != nil {
+		atomic.StoreInt32(&n.v1alpha2, 0)
+	}
+}
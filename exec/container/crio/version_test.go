@@ -0,0 +1,80 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio/fake"
+)
+
+func TestNegotiatorMethod(t *testing.T) {
+	negotiator := &apiNegotiator{}
+	if method := negotiator.method("/runtime.v1.RuntimeService/Version"); method != "/runtime.v1.RuntimeService/Version" {
+		t.Errorf("expected the v1 method kept before the negotiation, got %s", method)
+	}
+	negotiator.v1alpha2 = 1
+	if method := negotiator.method("/runtime.v1.ImageService/ImageFsInfo"); method != "/runtime.v1alpha2.ImageService/ImageFsInfo" {
+		t.Errorf("expected the v1alpha2 method, got %s", method)
+	}
+	if method := negotiator.method("/grpc.health.v1.Health/Check"); method != "/grpc.health.v1.Health/Check" {
+		t.Errorf("expected the other services kept, got %s", method)
+	}
+	if version := (*apiNegotiator)(nil).Version(); version != APIVersionV1 {
+		t.Errorf("expected v1 of the client without negotiation, got %s", version)
+	}
+}
+
+func TestNegotiateV1(t *testing.T) {
+	client, _ := newTestClient(t, testContainers...)
+	if version := client.APIVersion(); version != APIVersionV1 {
+		t.Errorf("expected v1, got %s", version)
+	}
+}
+
+func TestNegotiateV1alpha2(t *testing.T) {
+	server := fake.NewServer(testContainers...)
+	endpoint, err := server.StartV1alpha2()
+	if err != nil {
+		t.Fatalf("start fake v1alpha2 cri server failed, %v", err)
+	}
+	CloseClient()
+	client, err := NewClient(endpoint, "")
+	if err != nil {
+		server.Stop()
+		t.Fatalf("connect fake v1alpha2 cri server failed, %v", err)
+	}
+	defer func() {
+		CloseClient()
+		server.Stop()
+	}()
+	if version := client.APIVersion(); version != APIVersionV1alpha2 {
+		t.Fatalf("expected v1alpha2, got %s", version)
+	}
+	containers, err, _ := client.ListContainers(context.Background())
+	if err != nil || len(containers) != len(testContainers) {
+		t.Fatalf("expected the containers listed by v1alpha2, got %+v, %v", containers, err)
+	}
+	info, err, _ := client.GetContainerById(context.Background(), "c2")
+	if err != nil || info.ContainerName != "redis" || info.Labels["app"] != "cache" {
+		t.Errorf("expected the container of v1alpha2, got %+v, %v", info, err)
+	}
+	if err := Probe(context.Background(), endpoint); err != nil {
+		t.Errorf("expected the v1alpha2 endpoint probed, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crio
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// watchInterval 是轮询容器列表的间隔, CRI 没有事件接口
+var watchInterval = 2 * time.Second
+
+// Watch 轮询容器列表, 比较前后两次的容器状态生成生命周期事件
+func (c *CRIClient) Watch(ctx context.Context, filter container.EventFilter) (<-chan container.Event, error) {
+	previous, err := c.listStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sender, events := container.NewEventSender(ctx, filter)
+	go func() {
+		defer sender.Close()
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current, err := c.listStates(ctx)
+			if err != nil {
+				// 临时的错误, 下次轮询重试
+				log.Warnf(ctx, "list containers for the watch failed, %v", err)
+				continue
+			}
+			for _, event := range diffStates(previous, current, time.Now()) {
+				if !sender.Send(event) {
+					return
+				}
+			}
+			previous = current
+		}
+	}()
+	return events, nil
+}
+
+// listStates 返回容器列表, key 为容器 id
+func (c *CRIClient) listStates(ctx context.Context) (map[string]*v1.Container, error) {
+	response, err := c.runtimeService.ListContainers(ctx, &v1.ListContainersRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+	states := make(map[string]*v1.Container, len(response.GetContainers()))
+	for _, item := range response.GetContainers() {
+		states[item.GetId()] = item
+	}
+	return states, nil
+}
+
+// diffStates 比较两次轮询的容器状态, 两次轮询之间的中间状态会丢失, 例如快速退出的容器没有 started 事件
+func diffStates(previous, current map[string]*v1.Container, now time.Time) []container.Event {
+	events := make([]container.Event, 0)
+	newEvent := func(eventType string, item *v1.Container) container.Event {
+		return container.Event{
+			Type:          eventType,
+			ContainerId:   item.GetId(),
+			ContainerName: item.GetMetadata().GetName(),
+			Labels:        item.GetLabels(),
+			Time:          now,
+		}
+	}
+	for _, id := range sortedIds(current) {
+		item := current[id]
+		before, existed := previous[id]
+		if !existed {
+			events = append(events, newEvent(container.EventCreated, item))
+		}
+		state := item.GetState()
+		if state == v1.ContainerState_CONTAINER_RUNNING && (!existed || before.GetState() != state) {
+			events = append(events, newEvent(container.EventStarted, item))
+		}
+		if state == v1.ContainerState_CONTAINER_EXITED && (!existed || before.GetState() != state) {
+			events = append(events, newEvent(container.EventStopped, item))
+		}
+	}
+	for _, id := range sortedIds(previous) {
+		if _, ok := current[id]; !ok {
+			events = append(events, newEvent(container.EventDeleted, previous[id]))
+		}
+	}
+	return events
+}
+
+func sortedIds(states map[string]*v1.Container) []string {
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
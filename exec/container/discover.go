@@ -0,0 +1,151 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RuntimeAuto is the container-runtime discovering the live runtime of the node by the well-known sockets
+const RuntimeAuto = "auto"
+
+// RuntimeSocket is a well-known socket of a runtime, Runtime is the client of the socket, so the cri runtimes
+// without their own client, such as cri-dockerd, are served by the cri client
+type RuntimeSocket struct {
+	Runtime string `json:"runtime"`
+	Name    string `json:"name"`
+	Socket  string `json:"socket"`
+}
+
+// DiscoverySockets are the sockets probed by precedence, the cri runtimes of the kubelet first, since dockerd runs
+// beside them on some nodes
+var DiscoverySockets = []RuntimeSocket{
+	{Runtime: ContainerdRuntime, Name: "containerd", Socket: "/run/containerd/containerd.sock"},
+	{Runtime: CRIORuntime, Name: "cri-o", Socket: "/var/run/crio/crio.sock"},
+	{Runtime: CRIORuntime, Name: "cri-dockerd", Socket: "/var/run/cri-dockerd.sock"},
+	{Runtime: DockerRuntime, Name: "docker", Socket: "/var/run/docker.sock"},
+}
+
+// CRISockets returns the sockets of the runtimes serving the cri api
+func CRISockets() []RuntimeSocket {
+	sockets := make([]RuntimeSocket, 0, len(DiscoverySockets))
+	for _, socket := range DiscoverySockets {
+		if socket.Runtime != DockerRuntime {
+			sockets = append(sockets, socket)
+		}
+	}
+	return sockets
+}
+
+// Endpoint returns the endpoint of the socket for its client, containerd dials the socket path, the others need the
+// unix scheme
+func (s RuntimeSocket) Endpoint() string {
+	if s.Runtime == ContainerdRuntime {
+		return s.Socket
+	}
+	return "unix://" + s.Socket
+}
+
+func (s RuntimeSocket) String() string {
+	return fmt.Sprintf("%s at %s", s.Name, s.Socket)
+}
+
+// RuntimeProber checks the runtime serving the socket, such as the cri Version of the cri runtimes, so the containerd
+// of dockerd without the cri plugin is not selected
+type RuntimeProber func(ctx context.Context, socket RuntimeSocket) error
+
+// DiscoveryError is returned if no socket is live, it tells why each socket is skipped
+type DiscoveryError struct {
+	Skipped []string
+}
+
+func (e *DiscoveryError) Error() string {
+	return fmt.Sprintf("no live container runtime is discovered, %s", strings.Join(e.Skipped, "; "))
+}
+
+// DiscoverRuntime returns the first socket found and passing the probe
+func DiscoverRuntime(ctx context.Context, sockets []RuntimeSocket, probe RuntimeProber) (RuntimeSocket, error) {
+	discoveryError := &DiscoveryError{}
+	for _, socket := range sockets {
+		info, err := os.Stat(socket.Socket)
+		if err != nil {
+			discoveryError.Skipped = append(discoveryError.Skipped, fmt.Sprintf("%s not found", socket))
+			continue
+		}
+		if info.Mode()&os.ModeSocket == 0 {
+			discoveryError.Skipped = append(discoveryError.Skipped, fmt.Sprintf("%s is not a socket", socket))
+			continue
+		}
+		if err := probe(ctx, socket); err != nil {
+			discoveryError.Skipped = append(discoveryError.Skipped, fmt.Sprintf("%s is not serving, %v", socket, err))
+			continue
+		}
+		return socket, nil
+	}
+	return RuntimeSocket{}, discoveryError
+}
+
+// WithDiscoveredRuntime wraps the client of the discovered runtime, the containers looked up report the runtime and
+// the lookup errors tell it, so the experiments against the wrong runtime are obvious
+func WithDiscoveredRuntime(c Container, socket RuntimeSocket) Container {
+	return &discoveredContainer{Container: c, socket: socket}
+}
+
+type discoveredContainer struct {
+	Container
+	socket RuntimeSocket
+}
+
+func (c *discoveredContainer) Unwrap() Container {
+	return c.Container
+}
+
+func (c *discoveredContainer) discovered(info ContainerInfo, err error, code int32) (ContainerInfo, error, int32) {
+	if err != nil {
+		return info, fmt.Errorf("%w, the runtime discovered is %s", err, c.socket), code
+	}
+	info.Runtime = c.socket.Name
+	return info, nil, code
+}
+
+func (c *discoveredContainer) GetContainerById(ctx context.Context, containerId string) (ContainerInfo, error, int32) {
+	return c.discovered(c.Container.GetContainerById(ctx, containerId))
+}
+
+func (c *discoveredContainer) GetContainerByName(ctx context.Context, containerName string) (ContainerInfo, error,
+	int32) {
+	return c.discovered(c.Container.GetContainerByName(ctx, containerName))
+}
+
+func (c *discoveredContainer) GetContainerByLabelSelector(containerLabelSelector map[string]string) (ContainerInfo,
+	error, int32) {
+	return c.discovered(c.Container.GetContainerByLabelSelector(containerLabelSelector))
+}
+
+func (c *discoveredContainer) ListContainers(ctx context.Context) ([]ContainerInfo, error, int32) {
+	containers, err, code := c.Container.ListContainers(ctx)
+	if err != nil {
+		return containers, fmt.Errorf("%w, the runtime discovered is %s", err, c.socket), code
+	}
+	for i := range containers {
+		containers[i].Runtime = c.socket.Name
+	}
+	return containers, nil, code
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// listenSocket listens on the unix socket under the dir until the test ends
+func listenSocket(t *testing.T, dir, name string) string {
+	t.Helper()
+	socket := path.Join(dir, name)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return socket
+}
+
+func TestDiscoverRuntime(t *testing.T) {
+	dir := t.TempDir()
+	file := path.Join(dir, "crio.sock")
+	os.WriteFile(file, nil, 0600)
+	sockets := []RuntimeSocket{
+		{Runtime: ContainerdRuntime, Name: "containerd", Socket: listenSocket(t, dir, "containerd.sock")},
+		{Runtime: CRIORuntime, Name: "cri-o", Socket: file},
+		{Runtime: CRIORuntime, Name: "cri-dockerd", Socket: path.Join(dir, "cri-dockerd.sock")},
+		{Runtime: DockerRuntime, Name: "docker", Socket: listenSocket(t, dir, "docker.sock")},
+	}
+	probed := make([]string, 0)
+	probe := func(ctx context.Context, socket RuntimeSocket) error {
+		probed = append(probed, socket.Name)
+		if socket.Runtime == ContainerdRuntime {
+			return errors.New("unknown service runtime.v1.RuntimeService")
+		}
+		return nil
+	}
+	socket, err := DiscoverRuntime(context.Background(), sockets, probe)
+	if err != nil || socket != sockets[3] {
+		t.Fatalf("expected docker discovered, got %+v, %v", socket, err)
+	}
+	if strings.Join(probed, ",") != "containerd,docker" {
+		t.Errorf("expected the live sockets probed only, got %v", probed)
+	}
+	if socket.Endpoint() != "unix://"+sockets[3].Socket || sockets[0].Endpoint() != sockets[0].Socket {
+		t.Errorf("unexpected endpoints %s, %s", socket.Endpoint(), sockets[0].Endpoint())
+	}
+
+	_, err = DiscoverRuntime(context.Background(), sockets[:3], probe)
+	var discoveryError *DiscoveryError
+	if !errors.As(err, &discoveryError) || len(discoveryError.Skipped) != 3 {
+		t.Fatalf("expected the discovery error of 3 sockets, got %v", err)
+	}
+	for i, reason := range []string{"is not serving, unknown service", "is not a socket", "not found"} {
+		if !strings.Contains(discoveryError.Skipped[i], reason) {
+			t.Errorf("expected %q of %s, got %s", reason, sockets[i].Name, discoveryError.Skipped[i])
+		}
+	}
+}
+
+func TestCRISockets(t *testing.T) {
+	for _, socket := range CRISockets() {
+		if socket.Runtime == DockerRuntime {
+			t.Errorf("unexpected socket %s", socket)
+		}
+	}
+	if len(CRISockets()) != len(DiscoverySockets)-1 {
+		t.Errorf("expected all the sockets but docker, got %v", CRISockets())
+	}
+}
+
+type lookupContainer struct {
+	listContainer
+}
+
+func (c *lookupContainer) GetContainerById(ctx context.Context, containerId string) (ContainerInfo, error, int32) {
+	for _, info := range c.containers {
+		if info.ContainerId == containerId {
+			return info, nil, 0
+		}
+	}
+	return ContainerInfo{}, errors.New("container not found"), 404
+}
+
+func TestWithDiscoveredRuntime(t *testing.T) {
+	socket := RuntimeSocket{Runtime: CRIORuntime, Name: "cri-dockerd", Socket: "/var/run/cri-dockerd.sock"}
+	client := WithDiscoveredRuntime(&lookupContainer{listContainer{containers: []ContainerInfo{{ContainerId: "c1"}}}},
+		socket)
+	if info, err, _ := client.GetContainerById(context.Background(), "c1"); err != nil || info.Runtime != "cri-dockerd" {
+		t.Errorf("expected the runtime of the container, got %+v, %v", info, err)
+	}
+	_, err, code := client.GetContainerById(context.Background(), "c2")
+	if err == nil || code != 404 || err.Error() != "container not found, the runtime discovered is cri-dockerd at "+
+		"/var/run/cri-dockerd.sock" {
+		t.Errorf("expected the runtime in the error, got %v, %d", err, code)
+	}
+	if containers, err, _ := client.ListContainers(context.Background()); err != nil || containers[0].Runtime != "cri-dockerd" {
+		t.Errorf("expected the runtime of the containers, got %+v, %v", containers, err)
+	}
+}
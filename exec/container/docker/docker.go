@@ -17,21 +17,27 @@ package docker
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/docker/docker/api/types"
 	containertype "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/versions"
 	"github.com/docker/docker/client"
 
 	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/credential"
 )
 
 var cli *Client
@@ -58,10 +64,24 @@ func NewClient(endpoint string) (*Client, error) {
 	return cli, nil
 }
 
+// CloseClient closes the cached docker client
+func CloseClient() error {
+	if cli == nil {
+		return nil
+	}
+	err := cli.client.Close()
+	cli = nil
+	return err
+}
+
 // checkAndCreateClient
 func checkAndCreateClient(endpoint string, cli *client.Client) (*client.Client, error) {
 	if cli == nil {
 		var err error
+		if endpoint == "" {
+			// the rootless and the dev environment daemons are found if the rootful one is absent
+			endpoint = DiscoverEndpoint()
+		}
 		if endpoint == "" {
 			cli, err = client.NewClientWithOpts(client.FromEnv, client.WithVersion("1.24"))
 		} else {
@@ -100,6 +120,65 @@ func ping(cli *client.Client) (*client.Client, error) {
 	return nil, err
 }
 
+func (c *Client) RuntimeVersion(ctx context.Context) (string, string, error) {
+	version, err := c.client.ServerVersion(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return container.DockerRuntime, version.Version, nil
+}
+
+// dockerActions are the event types of the docker container actions
+var dockerActions = map[string]string{
+	"create":  container.EventCreated,
+	"start":   container.EventStarted,
+	"die":     container.EventStopped,
+	"destroy": container.EventDeleted,
+}
+
+// Watch subscribes the container events of docker, the labels of the events are the actor attributes which
+// contain the container labels
+func (c *Client) Watch(ctx context.Context, filter container.EventFilter) (<-chan container.Event, error) {
+	args := []filters.KeyValuePair{filters.Arg("type", events.ContainerEventType)}
+	for action := range dockerActions {
+		args = append(args, filters.Arg("event", action))
+	}
+	messages, errs := c.client.Events(ctx, types.EventsOptions{Filters: filters.NewArgs(args...)})
+	sender, watched := container.NewEventSender(ctx, filter)
+	go func() {
+		defer sender.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errs:
+				if err != nil && ctx.Err() == nil {
+					log.Warnf(ctx, "the docker event stream failed, %v", err)
+				}
+				return
+			case message := <-messages:
+				eventType, ok := dockerActions[message.Action]
+				if !ok {
+					continue
+				}
+				event := container.Event{
+					Type:        eventType,
+					ContainerId: message.Actor.ID,
+					Labels:      message.Actor.Attributes,
+					Time:        time.Unix(0, message.TimeNano),
+				}
+				if name := message.Actor.Attributes["name"]; name != "" {
+					event.ContainerName = "/" + name
+				}
+				if !sender.Send(event) {
+					return
+				}
+			}
+		}
+	}()
+	return watched, nil
+}
+
 func (c *Client) GetPidById(ctx context.Context, containerId string) (int32, error, int32) {
 	inspect, err := c.client.ContainerInspect(context.Background(), containerId)
 
@@ -110,6 +189,45 @@ func (c *Client) GetPidById(ctx context.Context, containerId string) (int32, err
 	return int32(inspect.State.Pid), nil, spec.OK.Code
 }
 
+// GetRootfsPath returns the rootfs of the graph driver, e.g. the merged dir of overlay2
+func (c *Client) GetRootfsPath(ctx context.Context, containerId string) (string, error, int32) {
+	inspect, err := c.client.ContainerInspect(ctx, containerId)
+	if err != nil {
+		return "", fmt.Errorf(spec.ContainerExecFailed.Sprintf("ContainerInspect", err.Error())), spec.ContainerExecFailed.Code
+	}
+	hint := container.RootfsHint{
+		Driver: inspect.GraphDriver.Name,
+		Path:   inspect.GraphDriver.Data["MergedDir"],
+		Data:   inspect.GraphDriver.Data,
+	}
+	if inspect.State != nil {
+		hint.Pid = int32(inspect.State.Pid)
+	}
+	rootfs, err := container.ResolveRootfsPath(hint)
+	if err != nil {
+		return "", fmt.Errorf("%v for container %s", err, containerId), spec.ContainerExecFailed.Code
+	}
+	return rootfs, nil, spec.OK.Code
+}
+
+// GetCgroupPath returns the cgroup of the container process
+func (c *Client) GetCgroupPath(ctx context.Context, containerId string) (container.CgroupPath, error, int32) {
+	pid, err, code := c.GetPidById(ctx, containerId)
+	if err != nil {
+		return container.CgroupPath{}, err, code
+	}
+	cgroupPath, err := container.ResolveCgroupPath(pid, "")
+	if err != nil {
+		return container.CgroupPath{}, fmt.Errorf("%v for container %s", err, containerId), spec.ContainerExecFailed.Code
+	}
+	return cgroupPath, nil, spec.OK.Code
+}
+
+// ListProcesses returns the processes of the container
+func (c *Client) ListProcesses(ctx context.Context, containerId string) ([]container.Process, error, int32) {
+	return container.ListProcesses(ctx, c, containerId)
+}
+
 func (c *Client) GetContainerById(ctx context.Context, containerId string) (container.ContainerInfo, error, int32) {
 	option := types.ContainerListOptions{
 		Filters: filters.NewArgs(
@@ -154,15 +272,99 @@ func (c *Client) GetContainerFromDocker(option types.ContainerListOptions) (cont
 		return container.ContainerInfo{}, fmt.Errorf(spec.ParameterInvalidDockContainerId.Sprintf("container-id")), spec.ParameterInvalidDockContainerId.Code
 	}
 	containerInfo := convertContainerInfo(containers[0])
+	// the list api only returns the created time, the state times are inspected
+	if inspect, err := c.client.ContainerInspect(context.Background(), containerInfo.ContainerId); err == nil && inspect.State != nil {
+		containerInfo.StartedAt = parseStateTime(inspect.State.StartedAt)
+		containerInfo.FinishedAt = parseStateTime(inspect.State.FinishedAt)
+		containerInfo.Attempt = uint32(inspect.RestartCount)
+	}
 	return containerInfo, nil, spec.OK.Code
 }
 
+// parseStateTime parses the rfc3339 time of the container state, the unset time 0001-01-01T00:00:00Z is zero
+func parseStateTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ListContainers returns the running containers
+func (c *Client) ListContainers(ctx context.Context) ([]container.ContainerInfo, error, int32) {
+	containers, err := c.client.ContainerList(context.Background(), types.ContainerListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf(spec.ContainerExecFailed.Sprintf("GetContainerList", err.Error())), spec.ContainerExecFailed.Code
+	}
+	containerInfos := make([]container.ContainerInfo, 0, len(containers))
+	for _, item := range containers {
+		containerInfos = append(containerInfos, convertContainerInfo(item))
+	}
+	return containerInfos, nil, spec.OK.Code
+}
+
 func convertContainerInfo(container2 types.Container) container.ContainerInfo {
 	return container.ContainerInfo{
 		ContainerId:   container2.ID,
 		ContainerName: container2.Names[0],
 		Labels:        container2.Labels,
+		CreatedAt:     time.Unix(container2.Created, 0),
+		Mounts:        convertMounts(container2.Mounts),
+		IPs:           convertIPs(container2.NetworkSettings),
+		Ports:         convertPorts(container2.Ports),
+	}
+}
+
+// convertIPs returns the addresses of the networks sorted by the network name, empty for the host network
+func convertIPs(settings *types.SummaryNetworkSettings) []string {
+	if settings == nil {
+		return nil
 	}
+	names := make([]string, 0, len(settings.Networks))
+	for name := range settings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ips := make([]string, 0)
+	for _, name := range names {
+		endpoint := settings.Networks[name]
+		if endpoint == nil {
+			continue
+		}
+		for _, ip := range []string{endpoint.IPAddress, endpoint.GlobalIPv6Address} {
+			if ip != "" {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// convertPorts returns the exposed ports, the port published on both the ipv4 and the ipv6 host address is listed once
+func convertPorts(ports []types.Port) []container.Port {
+	result := make([]container.Port, 0, len(ports))
+	seen := make(map[container.Port]bool)
+	for _, port := range ports {
+		converted := container.Port{
+			ContainerPort: int32(port.PrivatePort),
+			HostPort:      int32(port.PublicPort),
+			Protocol:      strings.ToUpper(port.Type),
+		}
+		if seen[converted] {
+			continue
+		}
+		seen[converted] = true
+		result = append(result, converted)
+	}
+	return result
+}
+
+func convertMounts(mounts []types.MountPoint) []container.Mount {
+	result := make([]container.Mount, 0, len(mounts))
+	for _, mount := range mounts {
+		result = append(result, container.NewMount(mount.Destination, mount.Source, !mount.RW))
+	}
+	return result
 }
 
 // RemoveContainer
@@ -188,7 +390,7 @@ func (c *Client) ExecuteAndRemove(ctx context.Context, config *containertype.Con
 	_, err = c.getImageByRef(ctx, config.Image)
 	if err != nil {
 		// pull image if not exists
-		_, err := c.pullImage(config.Image)
+		_, err := c.pullImage(ctx, config.Image)
 		if err != nil {
 			return "", "", fmt.Errorf(spec.ImagePullFailed.Sprintf(config.Image, err)), spec.ImagePullFailed.Code
 		}
@@ -231,9 +433,23 @@ func (c *Client) getImageByRef(ctx context.Context, ref string) (types.ImageSumm
 	return list[0], nil
 }
 
-// PullImage
-func (c *Client) pullImage(ref string) (string, error) {
-	reader, err := c.client.ImagePull(context.Background(), ref, types.ImagePullOptions{})
+// PullImage with the credential of the image, see credential.Lookup
+func (c *Client) pullImage(ctx context.Context, ref string) (string, error) {
+	auth := credential.Lookup(ctx, ref)
+	options := types.ImagePullOptions{}
+	if !auth.IsEmpty() {
+		encoded, err := json.Marshal(types.AuthConfig{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			IdentityToken: auth.IdentityToken,
+			ServerAddress: credential.Registry(ref),
+		})
+		if err != nil {
+			return "", err
+		}
+		options.RegistryAuth = base64.URLEncoding.EncodeToString(encoded)
+	}
+	reader, err := c.client.ImagePull(context.Background(), ref, options)
 	if err != nil {
 		return "", err
 	}
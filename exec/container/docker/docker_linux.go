@@ -31,10 +31,10 @@ func (c *Client) ExecContainer(ctx context.Context, containerId, command string)
 
 // CopyToContainer copies a tar file to the dstPath.
 // If the same file exits in the dstPath, it will be override if the override arg is true, otherwise not
-func (c *Client) CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) error {
+func (c *Client) CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) (string, error) {
 	id, err, _ := c.GetPidById(ctx, containerId)
 	if err != nil {
-		return err
+		return "", err
 	}
 	return container.CopyToContainer(ctx, uint32(id), srcFile, dstPath, extractDirName, override)
 }
@@ -0,0 +1,71 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+)
+
+const (
+	// DefaultSocket is the socket of the rootful docker daemon
+	DefaultSocket = "/var/run/docker.sock"
+	// HostEnv is the endpoint of the docker client, the sockets are not discovered if it is set
+	HostEnv = "DOCKER_HOST"
+)
+
+// candidateSockets returns the sockets of the docker daemons by precedence: the rootful daemon, the rootless daemon of
+// the user, then the daemons of the local dev environments, docker desktop, colima and rancher desktop
+func candidateSockets(getenv func(string) string, home string, uid int) []string {
+	sockets := []string{DefaultSocket}
+	// the runtime dir is unset in the sessions without login, such as sudo or ssh commands
+	rootless := path.Join("/run/user", strconv.Itoa(uid), "docker.sock")
+	if runtimeDir := getenv("XDG_RUNTIME_DIR"); runtimeDir != "" && path.Join(runtimeDir, "docker.sock") != rootless {
+		sockets = append(sockets, path.Join(runtimeDir, "docker.sock"))
+	}
+	sockets = append(sockets, rootless)
+	if home != "" {
+		sockets = append(sockets,
+			path.Join(home, ".docker", "run", "docker.sock"),
+			path.Join(home, ".colima", "default", "docker.sock"),
+			path.Join(home, ".colima", "docker.sock"),
+			path.Join(home, ".rd", "docker.sock"),
+		)
+	}
+	return sockets
+}
+
+// DiscoverEndpoint returns the endpoint of the first socket found, empty if DOCKER_HOST is set, the client uses it
+// then, or no socket is found
+func DiscoverEndpoint() string {
+	if os.Getenv(HostEnv) != "" {
+		return ""
+	}
+	home, _ := os.UserHomeDir()
+	return discoverEndpoint(candidateSockets(os.Getenv, home, os.Getuid()))
+}
+
+func discoverEndpoint(sockets []string) string {
+	for _, socket := range sockets {
+		if info, err := os.Stat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return fmt.Sprintf("unix://%s", socket)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestCandidateSockets(t *testing.T) {
+	getenv := func(key string) string {
+		if key == "XDG_RUNTIME_DIR" {
+			return "/run/user/1000"
+		}
+		return ""
+	}
+	sockets := candidateSockets(getenv, "/home/dev", 1000)
+	expected := []string{
+		DefaultSocket,
+		"/run/user/1000/docker.sock",
+		"/home/dev/.docker/run/docker.sock",
+		"/home/dev/.colima/default/docker.sock",
+		"/home/dev/.colima/docker.sock",
+		"/home/dev/.rd/docker.sock",
+	}
+	if !reflect.DeepEqual(sockets, expected) {
+		t.Errorf("expected %v, got %v", expected, sockets)
+	}
+	sockets = candidateSockets(func(string) string { return "/tmp/runtime-dev" }, "", 1000)
+	expected = []string{DefaultSocket, "/tmp/runtime-dev/docker.sock", "/run/user/1000/docker.sock"}
+	if !reflect.DeepEqual(sockets, expected) {
+		t.Errorf("expected %v, got %v", expected, sockets)
+	}
+}
+
+func TestDiscoverEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	regular := path.Join(dir, "regular.sock")
+	os.WriteFile(regular, nil, 0600)
+	socket := path.Join(dir, "colima.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix socket unavailable, %v", err)
+	}
+	defer listener.Close()
+	if endpoint := discoverEndpoint([]string{path.Join(dir, "missing.sock"), regular, socket}); endpoint != "unix://"+socket {
+		t.Errorf("expected the socket %s, got %s", socket, endpoint)
+	}
+	if endpoint := discoverEndpoint([]string{regular}); endpoint != "" {
+		t.Errorf("expected no endpoint, got %s", endpoint)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package docker
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// execAPI is the exec api of the docker client, it is faked in the tests
+type execAPI interface {
+	ContainerExecCreate(ctx context.Context, container string, config types.ExecConfig) (types.IDResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, config types.ExecStartCheck) (types.HijackedResponse, error)
+	ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error)
+}
+
+// execTransport runs the commands by the exec api of the daemon, it is used on darwin, the containers of the docker
+// desktop vm are not reachable by nsexec
+type execTransport struct {
+	api         execAPI
+	containerId string
+}
+
+func (t execTransport) Run(ctx context.Context, argv []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	log.Infof(ctx, "execute command: %s", strings.Join(argv, " "))
+	id, err := t.api.ContainerExecCreate(ctx, t.containerId, types.ExecConfig{
+		AttachStdin:  stdin != nil,
+		AttachStderr: true,
+		AttachStdout: true,
+		Cmd:          argv,
+		Privileged:   true,
+		User:         "root",
+	})
+	if err != nil {
+		log.Warnf(ctx, "Create exec for container: %s, err: %s", t.containerId, err.Error())
+		return err
+	}
+	resp, err := t.api.ContainerExecAttach(ctx, id.ID, types.ExecStartCheck{})
+	if err != nil {
+		log.Warnf(ctx, "Attach exec for container: %s, err: %s", t.containerId, err.Error())
+		return err
+	}
+	defer resp.Close()
+	if stdin != nil {
+		go func() {
+			io.Copy(resp.Conn, stdin)
+			resp.CloseWrite()
+		}()
+	}
+	if _, err := stdcopy.StdCopy(stdout, stderr, resp.Reader); err != nil {
+		log.Warnf(ctx, "Attach exec for container: %s, err: %s", t.containerId, err.Error())
+		return err
+	}
+	inspect, err := t.api.ContainerExecInspect(ctx, id.ID)
+	if err != nil {
+		return err
+	}
+	if inspect.ExitCode != 0 {
+		return &container.ExitError{Code: inspect.ExitCode}
+	}
+	return nil
+}
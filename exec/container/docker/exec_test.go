@@ -0,0 +1,155 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/transporttest"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+// fakeExecAPI runs the execs on the host, the attach streams the multiplexed stdout and stderr over a tcp connection
+// like the hijacked connection of the daemon, so the stdin is half closed by the transport
+type fakeExecAPI struct {
+	t         *testing.T
+	mu        sync.Mutex
+	configs   map[string]types.ExecConfig
+	exitCodes map[string]int
+}
+
+func newFakeExecAPI(t *testing.T) *fakeExecAPI {
+	return &fakeExecAPI{t: t, configs: make(map[string]types.ExecConfig), exitCodes: make(map[string]int)}
+}
+
+func (f *fakeExecAPI) ContainerExecCreate(ctx context.Context, containerId string, config types.ExecConfig) (types.IDResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if containerId != "c1" {
+		return types.IDResponse{}, fmt.Errorf("No such container: %s", containerId)
+	}
+	id := fmt.Sprintf("exec%d", len(f.configs))
+	f.configs[id] = config
+	return types.IDResponse{ID: id}, nil
+}
+
+func (f *fakeExecAPI) ContainerExecAttach(ctx context.Context, execID string, config types.ExecStartCheck) (types.HijackedResponse, error) {
+	f.mu.Lock()
+	execConfig := f.configs[execID]
+	f.mu.Unlock()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return types.HijackedResponse{}, err
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		accepted <- conn
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return types.HijackedResponse{}, err
+	}
+	server := <-accepted
+	cmd := exec.Command(execConfig.Cmd[0], execConfig.Cmd[1:]...)
+	if execConfig.AttachStdin {
+		cmd.Stdin = server
+	}
+	cmd.Stdout = stdcopy.NewStdWriter(server, stdcopy.Stdout)
+	cmd.Stderr = stdcopy.NewStdWriter(server, stdcopy.Stderr)
+	go func() {
+		defer server.Close()
+		exitCode := 0
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				f.t.Errorf("run %v failed, %v", execConfig.Cmd, err)
+			}
+			exitCode = exitErr.ExitCode()
+		}
+		f.mu.Lock()
+		f.exitCodes[execID] = exitCode
+		f.mu.Unlock()
+	}()
+	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(conn)}, nil
+}
+
+func (f *fakeExecAPI) ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return types.ContainerExecInspect{ExecID: execID, ExitCode: f.exitCodes[execID]}, nil
+}
+
+// TestExecTransport runs the conformance cases of the transports against the exec api
+func TestExecTransport(t *testing.T) {
+	api := newFakeExecAPI(t)
+	transport := execTransport{api: api, containerId: "c1"}
+	for _, tt := range transporttest.ExecCases {
+		ctx, collector := warning.WithCollector(context.Background())
+		output, err := container.Exec(ctx, transport, tt.Command)
+		var exitErr *container.ExitError
+		if tt.ExitCode != 0 {
+			if !errors.As(err, &exitErr) || exitErr.Code != tt.ExitCode || !strings.Contains(err.Error(), "failed") {
+				t.Errorf("expected exit code %d of %q with the stderr, got %v", tt.ExitCode, tt.Command, err)
+			}
+			continue
+		}
+		if err != nil || output != tt.Output {
+			t.Errorf("expected %q of %q, got %q, %v", tt.Output, tt.Command, output, err)
+		}
+		if warned := len(collector.List()) > 0; warned != tt.Warned {
+			t.Errorf("expected warned %v of %q", tt.Warned, tt.Command)
+		}
+	}
+	for _, config := range api.configs {
+		if !config.Privileged || config.User != "root" || !config.AttachStdout || !config.AttachStderr {
+			t.Errorf("unexpected exec config %+v", config)
+		}
+	}
+	if _, err := container.Exec(context.Background(), execTransport{api: api, containerId: "c2"}, "echo ok"); err == nil ||
+		!strings.Contains(err.Error(), "No such container") {
+		t.Errorf("expected the exec of the missing container failed, got %v", err)
+	}
+}
+
+func TestCopyTransport(t *testing.T) {
+	archive := transporttest.WriteArchive(t, "chaosblade", "blade", "#!/bin/sh\n")
+	transport := execTransport{api: newFakeExecAPI(t), containerId: "c1"}
+	dstPath := path.Join(t.TempDir(), "opt", "tools")
+	extractPath, err := container.Copy(context.Background(), transport, archive, dstPath, "chaosblade")
+	if err != nil || extractPath != path.Join(dstPath, "chaosblade") {
+		t.Fatalf("copy failed, %s, %v", extractPath, err)
+	}
+	if content, err := os.ReadFile(path.Join(extractPath, "blade")); err != nil || string(content) != "#!/bin/sh\n" {
+		t.Errorf("expected the archive extracted, got %q, %v", content, err)
+	}
+}
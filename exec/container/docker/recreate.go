@@ -0,0 +1,163 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/docker/docker/api/types"
+	containertype "github.com/docker/docker/api/types/container"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// kubernetesPodLabel is set by the kubelet on the containers of the pods
+const kubernetesPodLabel = "io.kubernetes.pod.name"
+
+var recreateStopTimeout = 10 * time.Second
+
+var _ container.Recreator = &Client{}
+
+// Recreate commits the image of the container with the layer by a temporary container, so the layer is added even if
+// the filesystem of the container is read only, then recreates the container from the image, the additional networks
+// are connected again. The origin container is restored if the recreation fails. The running state of the origin
+// container is returned, the restore starts it only if it was running
+func (c *Client) Recreate(ctx context.Context, containerId string, layer io.Reader, image string) (string, bool, error) {
+	info, err := c.client.ContainerInspect(ctx, containerId)
+	if err != nil {
+		return "", false, err
+	}
+	if _, ok := info.Config.Labels[kubernetesPodLabel]; ok {
+		return "", false, fmt.Errorf("container %s is managed by kubernetes, it can't be recreated", containerId)
+	}
+	running := info.State != nil && info.State.Running
+	if err := c.commitLayer(ctx, info, layer, image); err != nil {
+		return "", false, err
+	}
+	name := strings.TrimPrefix(info.Name, "/")
+	if err := c.client.ContainerStop(ctx, info.ID, &recreateStopTimeout); err != nil {
+		c.removeImage(ctx, image)
+		return "", false, err
+	}
+	if err := c.client.ContainerRename(ctx, info.ID, name+container.OriginSuffix); err != nil {
+		if running {
+			c.startContainer(ctx, info.ID)
+		}
+		c.removeImage(ctx, image)
+		return "", false, err
+	}
+	config := *info.Config
+	config.Image = image
+	body, err := c.client.ContainerCreate(ctx, &config, info.HostConfig, nil, name)
+	if err == nil {
+		err = c.connectNetworks(ctx, body.ID, info)
+		if err == nil {
+			err = c.startContainer(ctx, body.ID)
+		}
+	}
+	if err != nil {
+		log.Warnf(ctx, "recreate container %s failed, restore it, %v", containerId, err)
+		if restoreErr := c.RestoreRecreated(ctx, body.ID, info.ID, image, running); restoreErr != nil {
+			log.Warnf(ctx, "restore container %s failed, %v", containerId, restoreErr)
+		}
+		return "", false, err
+	}
+	log.Infof(ctx, "container %s is recreated as %s from image %s", containerId, body.ID, image)
+	return body.ID, running, nil
+}
+
+// RestoreRecreated removes the recreated container if it exists, renames the origin container back and starts it if it
+// was running when recreated, the image is removed at last, the failure of it is logged only
+func (c *Client) RestoreRecreated(ctx context.Context, containerId, originId, image string, running bool) error {
+	if containerId != "" {
+		err := c.client.ContainerRemove(ctx, containerId, types.ContainerRemoveOptions{Force: true})
+		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "no such container") {
+			return err
+		}
+	}
+	origin, err := c.client.ContainerInspect(ctx, originId)
+	if err != nil {
+		return err
+	}
+	if name := strings.TrimPrefix(origin.Name, "/"); strings.HasSuffix(name, container.OriginSuffix) {
+		if err := c.client.ContainerRename(ctx, originId, strings.TrimSuffix(name, container.OriginSuffix)); err != nil {
+			return err
+		}
+	}
+	if running && (origin.State == nil || !origin.State.Running) {
+		if err := c.startContainer(ctx, originId); err != nil {
+			return err
+		}
+	}
+	c.removeImage(ctx, image)
+	return nil
+}
+
+// commitLayer extracts the layer to a temporary container created from the image of the container, and commits it
+// with the config of the container
+func (c *Client) commitLayer(ctx context.Context, info types.ContainerJSON, layer io.Reader, image string) error {
+	// the temporary container is never started, the command is required by the images without it only
+	temp, err := c.client.ContainerCreate(ctx, &containertype.Config{Image: info.Image, Cmd: []string{"true"}}, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer c.client.ContainerRemove(ctx, temp.ID, types.ContainerRemoveOptions{Force: true})
+	if err := c.client.CopyToContainer(ctx, temp.ID, "/", layer, types.CopyToContainerOptions{}); err != nil {
+		return err
+	}
+	_, err = c.client.ContainerCommit(ctx, temp.ID, types.ContainerCommitOptions{
+		Reference: image,
+		Comment:   "the chaosblade tool layered for container " + info.ID,
+		Config:    info.Config,
+	})
+	return err
+}
+
+// connectNetworks connects the recreated container to the networks of the origin one except the network mode
+func (c *Client) connectNetworks(ctx context.Context, containerId string, info types.ContainerJSON) error {
+	if info.NetworkSettings == nil || info.HostConfig == nil {
+		return nil
+	}
+	var errs []string
+	for name, endpoint := range info.NetworkSettings.Networks {
+		if name == string(info.HostConfig.NetworkMode) || endpoint == nil {
+			continue
+		}
+		settings := *endpoint
+		// the address of the origin container is released only when it's removed
+		settings.EndpointID, settings.IPAddress, settings.GlobalIPv6Address = "", "", ""
+		if err := c.client.NetworkConnect(ctx, name, containerId, &settings); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New("connect networks failed, " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (c *Client) removeImage(ctx context.Context, image string) {
+	if _, err := c.client.ImageRemove(ctx, image, types.ImageRemoveOptions{PruneChildren: true}); err != nil {
+		log.Warnf(ctx, "remove image %s failed, %v", image, err)
+	}
+}
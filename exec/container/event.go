@@ -0,0 +1,107 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"time"
+)
+
+// The container lifecycle event types
+const (
+	EventCreated = "created"
+	EventStarted = "started"
+	EventStopped = "stopped"
+	EventDeleted = "deleted"
+)
+
+// eventBuffer is the buffer of the watch channels, the events are dropped only when the context is done
+const eventBuffer = 64
+
+// Event is a container lifecycle change
+type Event struct {
+	Type          string
+	ContainerId   string
+	ContainerName string
+	// Labels are the container labels, they may be empty for the deleted events if the runtime does not report them
+	Labels map[string]string
+	Time   time.Time
+}
+
+// EventFilter selects the events, the empty fields match all
+type EventFilter struct {
+	ContainerId   string
+	ContainerName string
+	Labels        map[string]string
+	// Types are the event types, see the Event constants
+	Types []string
+}
+
+// Match returns true if the event is selected by the filter
+func (f EventFilter) Match(event Event) bool {
+	if f.ContainerId != "" && f.ContainerId != event.ContainerId {
+		return false
+	}
+	if f.ContainerName != "" && f.ContainerName != event.ContainerName {
+		return false
+	}
+	for key, value := range f.Labels {
+		if event.Labels[key] != value {
+			return false
+		}
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// EventSender sends the matched events to the watch channel
+type EventSender struct {
+	ctx    context.Context
+	filter EventFilter
+	events chan Event
+}
+
+// NewEventSender returns the sender and the watch channel, the runtime goroutine calls Close when it returns
+func NewEventSender(ctx context.Context, filter EventFilter) (*EventSender, <-chan Event) {
+	events := make(chan Event, eventBuffer)
+	return &EventSender{ctx: ctx, filter: filter, events: events}, events
+}
+
+// Send sends the event if matched, it returns false if the context is done
+func (s *EventSender) Send(event Event) bool {
+	if !s.filter.Match(event) {
+		return s.ctx.Err() == nil
+	}
+	select {
+	case s.events <- event:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// Close closes the watch channel
+func (s *EventSender) Close() {
+	close(s.events)
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEventFilterMatch(t *testing.T) {
+	event := Event{Type: EventStarted, ContainerId: "c1", ContainerName: "app", Labels: map[string]string{"app": "web"}}
+	tests := []struct {
+		filter   EventFilter
+		expected bool
+	}{
+		{EventFilter{}, true},
+		{EventFilter{ContainerId: "c1", ContainerName: "app"}, true},
+		{EventFilter{ContainerId: "c2"}, false},
+		{EventFilter{ContainerName: "sidecar"}, false},
+		{EventFilter{Labels: map[string]string{"app": "web"}}, true},
+		{EventFilter{Labels: map[string]string{"app": "db"}}, false},
+		{EventFilter{Types: []string{EventStopped, EventStarted}}, true},
+		{EventFilter{Types: []string{EventDeleted}}, false},
+	}
+	for _, tt := range tests {
+		if matched := tt.filter.Match(event); matched != tt.expected {
+			t.Errorf("match %+v, expected %v, got %v", tt.filter, tt.expected, matched)
+		}
+	}
+}
+
+func TestEventSender(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sender, events := NewEventSender(ctx, EventFilter{Types: []string{EventStarted}})
+	if !sender.Send(Event{Type: EventCreated, ContainerId: "c1"}) || !sender.Send(Event{Type: EventStarted, ContainerId: "c1"}) {
+		t.Fatal("expected the events sent before the context is done")
+	}
+	if event := <-events; event.Type != EventStarted {
+		t.Errorf("expected the started event only, got %+v", event)
+	}
+	for i := 0; i < eventBuffer; i++ {
+		sender.Send(Event{Type: EventStarted})
+	}
+	cancel()
+	if sender.Send(Event{Type: EventStarted}) {
+		t.Error("expected the send to fail after the context is done")
+	}
+	sender.Close()
+}
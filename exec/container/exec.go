@@ -0,0 +1,175 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+// Transport runs the commands in the container, it is the runtime specific part of ExecContainer and
+// CopyToContainer, such as nsexec entering the namespaces of the container or the exec api of the docker daemon. The
+// semantics of the exit codes, the stderr and the shell are the same whatever the transport, see Exec and Copy
+type Transport interface {
+	// Run runs the argv in the container, the stdin is nil if the command reads nothing. The error is *ExitError if
+	// the command exited with the non-zero code, any other error is the failure of the transport
+	Run(ctx context.Context, argv []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// ExitError is the command exited with the non-zero code
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the exit code of the command
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Exec runs the command by /bin/sh in the container. The command failed with the exit code carries the stderr in the
+// error. The stderr of the command succeeded is returned as the output with a warning, the stdout is dropped then, it
+// is kept for the compatibility with the experiments parsing it
+func Exec(ctx context.Context, transport Transport, command string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := transport.Run(ctx, []string{"/bin/sh", "-c", command}, nil, &stdout, &stderr)
+	log.Debugf(ctx, "Command Result, output: %s, errMsg: %s, err: %v", stdout.String(), stderr.String(), err)
+	if err != nil {
+		return "", CommandError(err, stderr.String())
+	}
+	if stderr.Len() > 0 {
+		warning.Add(ctx, "ExecContainer", "the stderr of `%s` is returned as the output, %d bytes of the stdout are dropped",
+			command, stdout.Len())
+		return stderr.String(), nil
+	}
+	return stdout.String(), nil
+}
+
+// Copy streams the tar.gz archive into the dstPath of the container by the name of ArchiveTempName, extracts it and
+// removes it. The dstPath is created if missing and the files extracted overwrite the existing ones. Any stderr fails
+// the copy, since a partial archive extracts silently. It returns the extraction path
+func Copy(ctx context.Context, transport Transport, srcFile, dstPath, extractDirName string) (string, error) {
+	file, err := os.Open(srcFile)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	dstFile := path.Join(dstPath, ArchiveTempName(ctx, srcFile))
+	defer func() {
+		if err := transport.Run(ctx, []string{"rm", "-f", dstFile}, nil, io.Discard, io.Discard); err != nil {
+			warning.Add(ctx, "CopyToContainer", "remove the archive %s failed, %v", dstFile, err)
+		}
+	}()
+	copyScript := fmt.Sprintf("mkdir -p %s && cat > %s", nsexec.Quote(dstPath), nsexec.Quote(dstFile))
+	if err := runChecked(ctx, transport, []string{"/bin/sh", "-c", copyScript}, file); err != nil {
+		return "", err
+	}
+	if err := runChecked(ctx, transport, []string{"tar", "-zxf", dstFile, "-C", dstPath}, nil); err != nil {
+		return "", err
+	}
+	return ExtractPath(dstPath, extractDirName), nil
+}
+
+// runChecked runs the argv and fails with the stderr even if the exit code is 0
+func runChecked(ctx context.Context, transport Transport, argv []string, stdin io.Reader) error {
+	var stdout, stderr bytes.Buffer
+	err := transport.Run(ctx, argv, stdin, &stdout, &stderr)
+	log.Debugf(ctx, "Command Result, output: %s, errMsg: %s, err: %v", stdout.String(), stderr.String(), err)
+	if err != nil {
+		return CommandError(err, stderr.String())
+	}
+	if stderr.Len() != 0 {
+		return errors.New(stderr.String())
+	}
+	return nil
+}
+
+// NsexecTransport runs the commands by nsexec in the namespaces of the container process, the bin is resolved by
+// nsexec.Bin if empty. The user namespace of the container is entered too if it is not the one of the current process,
+// such as rootless docker, so the files are created by the users of the container as in the rootful runtimes
+type NsexecTransport struct {
+	Bin        string
+	Pid        int32
+	Namespaces []nsexec.Namespace
+}
+
+func (t NsexecTransport) Run(ctx context.Context, argv []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	bin := t.Bin
+	if bin == "" {
+		var err error
+		if bin, err = nsexec.Bin(); err != nil {
+			return err
+		}
+	}
+	builder := nsexec.New(bin, t.Pid).Namespaces(t.Namespaces...)
+	if InOtherUserNamespace(t.Pid) {
+		builder.Namespaces(nsexec.User)
+	}
+	command, err := builder.Argv(argv...).Build()
+	if err != nil {
+		return err
+	}
+	log.Infof(ctx, "run command: %s", command)
+	cmd := exec.Command(command.Path, command.Args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	// the command killed by the signal has no exit code, the error tells the signal
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return &ExitError{Code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+// InOtherUserNamespace returns true if the process is in a user namespace other than the one of the current process,
+// the files created in it without entering it are owned by the overflow uid in the container
+func InOtherUserNamespace(pid int32) bool {
+	self, err := os.Readlink(path.Join(procRoot, "self", "ns", "user"))
+	if err != nil {
+		return false
+	}
+	target, err := os.Readlink(path.Join(procRoot, strconv.Itoa(int(pid)), "ns", "user"))
+	return err == nil && target != self
+}
+
+// ExecContainer runs the command by /bin/sh in the pid, mount and network namespaces of the container process
+func ExecContainer(ctx context.Context, pid int32, command string) (output string, err error) {
+	return Exec(ctx, NsexecTransport{Pid: pid, Namespaces: []nsexec.Namespace{nsexec.Pid, nsexec.Mount, nsexec.Net}},
+		command)
+}
+
+// CopyToContainer copies the archive into the pid and mount namespaces of the container process, the override is
+// kept for the callers, the files extracted always overwrite the existing ones
+func CopyToContainer(ctx context.Context, pid uint32, srcFile, dstPath, extractDirName string, override bool) (string, error) {
+	return Copy(ctx, NsexecTransport{Pid: int32(pid), Namespaces: []nsexec.Namespace{nsexec.Pid, nsexec.Mount}},
+		srcFile, dstPath, extractDirName)
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/transporttest"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+// hostTransport runs the commands on the host, it is the reference the transports of the runtimes conform to
+type hostTransport struct{}
+
+func (hostTransport) Run(ctx context.Context, argv []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+// fakeNsexec writes the nsexec skipping its options, the commands run on the host
+func fakeNsexec(t *testing.T) string {
+	t.Helper()
+	bin := path.Join(t.TempDir(), "nsexec")
+	script := "#!/bin/sh\nwhile [ \"$1\" != -- ]; do shift; done\nshift\nexec \"$@\"\n"
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func transports(t *testing.T) map[string]Transport {
+	return map[string]Transport{
+		"host":   hostTransport{},
+		"nsexec": NsexecTransport{Bin: fakeNsexec(t), Pid: int32(os.Getpid())},
+	}
+}
+
+func TestExecConformance(t *testing.T) {
+	for name, transport := range transports(t) {
+		for _, tt := range transporttest.ExecCases {
+			ctx, collector := warning.WithCollector(context.Background())
+			output, err := Exec(ctx, transport, tt.Command)
+			var exitErr *ExitError
+			if tt.ExitCode != 0 {
+				if !errors.As(err, &exitErr) || exitErr.Code != tt.ExitCode || !strings.Contains(err.Error(), "failed") {
+					t.Errorf("%s: expected exit code %d of %q with the stderr, got %v", name, tt.ExitCode, tt.Command, err)
+				}
+				continue
+			}
+			if err != nil || output != tt.Output {
+				t.Errorf("%s: expected %q of %q, got %q, %v", name, tt.Output, tt.Command, output, err)
+			}
+			if warned := len(collector.List()) > 0; warned != tt.Warned {
+				t.Errorf("%s: expected warned %v of %q", name, tt.Warned, tt.Command)
+			}
+		}
+	}
+}
+
+func TestCopyConformance(t *testing.T) {
+	archive := transporttest.WriteArchive(t, "chaosblade", "blade", "#!/bin/sh\n")
+	for name, transport := range transports(t) {
+		dstPath := path.Join(t.TempDir(), "opt", "tools")
+		extractPath, err := Copy(context.Background(), transport, archive, dstPath, "chaosblade")
+		if err != nil || extractPath != path.Join(dstPath, "chaosblade") {
+			t.Fatalf("%s: copy failed, %s, %v", name, extractPath, err)
+		}
+		if content, err := os.ReadFile(path.Join(extractPath, "blade")); err != nil || string(content) != "#!/bin/sh\n" {
+			t.Errorf("%s: expected the archive extracted, got %q, %v", name, content, err)
+		}
+		if entries, _ := os.ReadDir(dstPath); len(entries) != 1 {
+			t.Errorf("%s: expected the archive removed, got %d entries", name, len(entries))
+		}
+		broken := path.Join(t.TempDir(), "broken.tar.gz")
+		os.WriteFile(broken, []byte("not a tar"), 0644)
+		if _, err := Copy(context.Background(), transport, broken, dstPath, "chaosblade"); err == nil {
+			t.Errorf("%s: expected the broken archive failed", name)
+		}
+	}
+}
+
+func TestInOtherUserNamespace(t *testing.T) {
+	procRoot = t.TempDir()
+	t.Cleanup(func() { procRoot = "/proc" })
+	for pid, userns := range map[string]string{"self": "user:[4026531837]", "10": "user:[4026531837]",
+		"20": "user:[4026532481]"} {
+		os.MkdirAll(path.Join(procRoot, pid, "ns"), 0755)
+		if err := os.Symlink(userns, path.Join(procRoot, pid, "ns", "user")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if InOtherUserNamespace(10) {
+		t.Errorf("expected the user namespace of the current process")
+	}
+	if !InOtherUserNamespace(20) {
+		t.Errorf("expected the other user namespace")
+	}
+	if InOtherUserNamespace(30) {
+		t.Errorf("expected the process gone not in the other user namespace")
+	}
+}
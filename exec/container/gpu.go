@@ -0,0 +1,58 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// nvidiaDevice matches the gpu device nodes, the control nodes such as nvidiactl and nvidia-uvm are skipped
+var nvidiaDevice = regexp.MustCompile(`^nvidia([0-9]+)$`)
+
+// GPU is a nvidia gpu assigned to the container
+type GPU struct {
+	// Minor is the minor number of the device, it is the index of nvidia-smi
+	Minor  int    `json:"minor"`
+	Device string `json:"device"`
+}
+
+// DetectGPUs returns the gpus assigned to the container sorted by the minor, they are the device nodes in the /dev
+// of the container, the nvidia container runtime and the device plugin only create the nodes of the assigned gpus
+func DetectGPUs(pid int32) ([]GPU, error) {
+	entries, err := os.ReadDir(path.Join(procRoot, strconv.Itoa(int(pid)), "root", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	gpus := make([]GPU, 0)
+	for _, entry := range entries {
+		match := nvidiaDevice.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		minor, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		gpus = append(gpus, GPU{Minor: minor, Device: path.Join("/dev", entry.Name())})
+	}
+	sort.Slice(gpus, func(i, j int) bool { return gpus[i].Minor < gpus[j].Minor })
+	return gpus, nil
+}
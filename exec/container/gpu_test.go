@@ -0,0 +1,50 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestDetectGPUs(t *testing.T) {
+	setupProcRoot(t, "")
+	dev := mkdir(t, path.Join(procRoot, "42", "root", "dev"))
+	for _, name := range []string{"nvidia3", "nvidia1", "nvidiactl", "nvidia-uvm", "null"} {
+		if err := os.WriteFile(path.Join(dev, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gpus, err := DetectGPUs(42)
+	if err != nil {
+		t.Fatalf("DetectGPUs failed, %v", err)
+	}
+	expected := []GPU{{Minor: 1, Device: "/dev/nvidia1"}, {Minor: 3, Device: "/dev/nvidia3"}}
+	if !reflect.DeepEqual(gpus, expected) {
+		t.Errorf("expected %+v, got %+v", expected, gpus)
+	}
+
+	mkdir(t, path.Join(procRoot, "43", "root", "dev"))
+	if gpus, err := DetectGPUs(43); err != nil || len(gpus) != 0 {
+		t.Errorf("expected no gpus, got %+v, %v", gpus, err)
+	}
+	if _, err := DetectGPUs(44); err == nil {
+		t.Error("expected error of the exited process")
+	}
+}
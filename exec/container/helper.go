@@ -0,0 +1,202 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	containertype "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+const (
+	NetworkToolsHelper = "network-tools"
+	StressNgHelper     = "stress-ng"
+	DnsFakerHelper     = "dns-faker"
+)
+
+const (
+	// HelperLabel is set on the helper containers, the value is the reference of the template
+	HelperLabel = "chaosblade-helper"
+	// helperTimeout is how long the helper container waits to be started
+	helperTimeout = time.Second
+)
+
+// HelperTemplate is a named and versioned helper container started beside the target container, the image is pinned
+// and the security context is the least the helper needs
+type HelperTemplate struct {
+	Name    string
+	Version string
+	Image   string
+	// CapAdd are the capabilities kept, all the others are dropped
+	CapAdd []string
+	// ShareNetwork joins the network namespace of the target container
+	ShareNetwork bool
+	// SharePid joins the pid namespace of the target container
+	SharePid       bool
+	ReadonlyRootfs bool
+	// User runs the helper, the user of the image if empty
+	User string
+}
+
+// Ref returns the reference of the template, name@version
+func (t HelperTemplate) Ref() string {
+	return fmt.Sprintf("%s@%s", t.Name, t.Version)
+}
+
+// Configs returns the configs creating the helper container beside the target container, the image overrides the
+// pinned one if not empty
+func (t HelperTemplate) Configs(target ContainerInfo, image string) (*containertype.Config,
+	*containertype.HostConfig, *network.NetworkingConfig) {
+	if image == "" {
+		image = t.Image
+	}
+	config := &containertype.Config{
+		// detach
+		AttachStdout: false,
+		AttachStderr: false,
+		Tty:          true,
+		Cmd:          []string{"/bin/sh"},
+		Image:        image,
+		User:         t.User,
+		Labels: map[string]string{
+			"chaosblade": "chaosblade-sidecar",
+			HelperLabel:  t.Ref(),
+		},
+	}
+	hostConfig := &containertype.HostConfig{
+		CapAdd:         append([]string{}, t.CapAdd...),
+		CapDrop:        []string{"ALL"},
+		SecurityOpt:    []string{"no-new-privileges"},
+		ReadonlyRootfs: t.ReadonlyRootfs,
+	}
+	if t.ShareNetwork {
+		hostConfig.NetworkMode = containertype.NetworkMode(fmt.Sprintf("container:%s", target.ContainerId))
+	}
+	if t.SharePid {
+		hostConfig.PidMode = containertype.PidMode(fmt.Sprintf("container:%s", target.ContainerId))
+	}
+	return config, hostConfig, &network.NetworkingConfig{}
+}
+
+var (
+	helperTemplatesLock sync.RWMutex
+	// helperTemplates are the versions of the templates by name, the last is the latest
+	helperTemplates = map[string][]HelperTemplate{}
+)
+
+func init() {
+	RegisterHelperTemplate(HelperTemplate{
+		Name:         NetworkToolsHelper,
+		Version:      "1.0",
+		Image:        GetChaosBladeImageRef(DefaultImageRepo, "1.7.2"),
+		CapAdd:       []string{"NET_ADMIN", "NET_RAW"},
+		ShareNetwork: true,
+	})
+	RegisterHelperTemplate(HelperTemplate{
+		Name:     StressNgHelper,
+		Version:  "1.0",
+		Image:    "docker.io/colinianking/stress-ng:V0.17.08",
+		SharePid: true,
+		User:     "65534",
+	})
+	RegisterHelperTemplate(HelperTemplate{
+		Name:         DnsFakerHelper,
+		Version:      "1.0",
+		Image:        "docker.io/jpillora/dnsmasq:1.1",
+		CapAdd:       []string{"NET_ADMIN", "NET_BIND_SERVICE"},
+		ShareNetwork: true,
+	})
+}
+
+// RegisterHelperTemplate adds the version of the template, the version registered replaces the same one
+func RegisterHelperTemplate(template HelperTemplate) {
+	helperTemplatesLock.Lock()
+	defer helperTemplatesLock.Unlock()
+	versions := helperTemplates[template.Name]
+	for i, version := range versions {
+		if version.Version == template.Version {
+			versions = append(versions[:i], versions[i+1:]...)
+			break
+		}
+	}
+	helperTemplates[template.Name] = append(versions, template)
+}
+
+// GetHelperTemplate returns the template of the reference, name or name@version, the latest version of the name is
+// returned without the version
+func GetHelperTemplate(ref string) (HelperTemplate, error) {
+	name, version, _ := strings.Cut(ref, "@")
+	helperTemplatesLock.RLock()
+	defer helperTemplatesLock.RUnlock()
+	versions := helperTemplates[name]
+	if len(versions) == 0 {
+		return HelperTemplate{}, fmt.Errorf("helper template %s not found", name)
+	}
+	if version == "" {
+		return versions[len(versions)-1], nil
+	}
+	for _, template := range versions {
+		if template.Version == version {
+			return template, nil
+		}
+	}
+	return HelperTemplate{}, fmt.Errorf("helper template %s not found, versions of %s: %s", ref, name,
+		strings.Join(helperVersions(versions), ","))
+}
+
+// HelperTemplates returns all the versions of the templates ordered by name
+func HelperTemplates() []HelperTemplate {
+	helperTemplatesLock.RLock()
+	defer helperTemplatesLock.RUnlock()
+	names := make([]string, 0, len(helperTemplates))
+	for name := range helperTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	templates := make([]HelperTemplate, 0)
+	for _, name := range names {
+		templates = append(templates, helperTemplates[name]...)
+	}
+	return templates
+}
+
+func helperVersions(templates []HelperTemplate) []string {
+	versions := make([]string, 0, len(templates))
+	for _, template := range templates {
+		versions = append(versions, template.Version)
+	}
+	return versions
+}
+
+// ExecuteHelper runs the command in the helper container of the template beside the target container by
+// ExecuteAndRemove, the helper container is removed after. The image overrides the pinned one if not empty
+func ExecuteHelper(ctx context.Context, c Container, ref, image, containerName, command string,
+	target ContainerInfo) (containerId string, output string, err error, code int32) {
+	template, err := GetHelperTemplate(ref)
+	if err != nil {
+		return "", "", err, spec.ParameterIllegal.Code
+	}
+	config, hostConfig, networkConfig := template.Configs(target, image)
+	return c.ExecuteAndRemove(ctx, config, hostConfig, networkConfig, containerName, true, helperTimeout, command, target)
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	containertype "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestGetHelperTemplate(t *testing.T) {
+	RegisterHelperTemplate(HelperTemplate{Name: "test-helper", Version: "1.0", Image: "test:1.0"})
+	RegisterHelperTemplate(HelperTemplate{Name: "test-helper", Version: "2.0", Image: "test:2.0"})
+	RegisterHelperTemplate(HelperTemplate{Name: "test-helper", Version: "1.0", Image: "test:1.1"})
+	defer func() {
+		helperTemplatesLock.Lock()
+		delete(helperTemplates, "test-helper")
+		helperTemplatesLock.Unlock()
+	}()
+	tests := []struct {
+		ref, image string
+		failed     bool
+	}{
+		{"test-helper", "test:1.1", false},
+		{"test-helper@2.0", "test:2.0", false},
+		{"test-helper@1.0", "test:1.1", false},
+		{"test-helper@3.0", "", true},
+		{"unknown", "", true},
+	}
+	for _, tt := range tests {
+		template, err := GetHelperTemplate(tt.ref)
+		if (err != nil) != tt.failed || template.Image != tt.image {
+			t.Errorf("get %s, expected %s, got %+v, err %v", tt.ref, tt.image, template, err)
+		}
+	}
+	for _, name := range []string{NetworkToolsHelper, StressNgHelper, DnsFakerHelper} {
+		if template, err := GetHelperTemplate(name); err != nil || template.Image == "" {
+			t.Errorf("expected the builtin template %s, got %+v, err %v", name, template, err)
+		}
+	}
+}
+
+type helperContainer struct {
+	Container
+	config     *containertype.Config
+	hostConfig *containertype.HostConfig
+	removed    bool
+}
+
+func (c *helperContainer) ExecuteAndRemove(ctx context.Context, config *containertype.Config,
+	hostConfig *containertype.HostConfig, networkConfig *network.NetworkingConfig, containerName string, removed bool,
+	timeout time.Duration, command string, containerInfo ContainerInfo) (string, string, error, int32) {
+	c.config, c.hostConfig, c.removed = config, hostConfig, removed
+	return "helper", "ok", nil, 0
+}
+
+func TestExecuteHelper(t *testing.T) {
+	client := &helperContainer{}
+	target := ContainerInfo{ContainerId: "c1"}
+	if _, output, err, _ := ExecuteHelper(context.Background(), client, NetworkToolsHelper, "", "c1-network",
+		"blade create network loss", target); err != nil || output != "ok" {
+		t.Fatalf("execute helper failed, output %s, err %v", output, err)
+	}
+	if client.config.Image != GetChaosBladeImageRef(DefaultImageRepo, "1.7.2") ||
+		client.config.Labels[HelperLabel] != "network-tools@1.0" || !client.removed {
+		t.Errorf("unexpected config %+v", client.config)
+	}
+	expected := &containertype.HostConfig{
+		NetworkMode: "container:c1",
+		CapAdd:      []string{"NET_ADMIN", "NET_RAW"},
+		CapDrop:     []string{"ALL"},
+		SecurityOpt: []string{"no-new-privileges"},
+	}
+	if !reflect.DeepEqual(client.hostConfig, expected) {
+		t.Errorf("expected host config %+v, got %+v", expected, client.hostConfig)
+	}
+
+	ExecuteHelper(context.Background(), client, StressNgHelper, "stress:test", "c1-stress", "stress-ng", target)
+	if client.config.Image != "stress:test" || client.hostConfig.PidMode != "container:c1" ||
+		client.hostConfig.NetworkMode != "" {
+		t.Errorf("unexpected configs %+v, %+v", client.config, client.hostConfig)
+	}
+	if _, _, err, _ := ExecuteHelper(context.Background(), client, "unknown", "", "c1-unknown", "true",
+		target); err == nil {
+		t.Errorf("expected the unknown template refused")
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+)
+
+// ImageFilesystem is a filesystem the runtime stores the images on, the imagefs of the kubelet
+type ImageFilesystem struct {
+	// Mountpoint is the host directory of the images, such as /var/lib/containers/storage/overlay-images
+	Mountpoint string `json:"mountpoint"`
+	UsedBytes  uint64 `json:"usedBytes"`
+	InodesUsed uint64 `json:"inodesUsed"`
+}
+
+// ImageFsReader is implemented by the cri runtimes, the kubelet garbage collects the images and evicts the pods by
+// the usage of the filesystems
+type ImageFsReader interface {
+	// ImageFilesystems returns the image filesystems reported by ImageFsInfo
+	ImageFilesystems(ctx context.Context) ([]ImageFilesystem, error)
+}
+
+// AsImageFsReader returns the image filesystem reader of the client, the wrappers of the client, such as the breaker,
+// are skipped
+func AsImageFsReader(c Container) (ImageFsReader, bool) {
+	for {
+		if reader, ok := c.(ImageFsReader); ok {
+			return reader, true
+		}
+		wrapper, ok := c.(interface{ Unwrap() Container })
+		if !ok {
+			return nil, false
+		}
+		c = wrapper.Unwrap()
+	}
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	containertype "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// The standard labels of the containers created by ExecuteAndRemove, the values are valid kubernetes label values,
+// so the containers are visible by the selectors too
+const (
+	ManagedLabel    = "chaosblade/managed"
+	ExperimentLabel = "chaosblade/experiment"
+	// ExpiresLabel is the unix seconds the container is expected to be removed by, the ones left after are leaked
+	ExpiresLabel = "chaosblade/expires"
+)
+
+// ManagedTTL is how long the managed container is expected to live
+var ManagedTTL = time.Hour
+
+type experimentKey struct{}
+
+// WithExperiment returns the context carrying the uid of the experiment, the containers created by ExecuteAndRemove
+// with the context are labeled by it
+func WithExperiment(ctx context.Context, uid string) context.Context {
+	return context.WithValue(ctx, experimentKey{}, uid)
+}
+
+// ExperimentFromContext returns the uid of the experiment of the context, empty if not set
+func ExperimentFromContext(ctx context.Context) string {
+	uid, _ := ctx.Value(experimentKey{}).(string)
+	return uid
+}
+
+// ManagedLabels returns the standard labels of the container created for the experiment, the uid is not labeled if
+// empty
+func ManagedLabels(uid string, expires time.Time) map[string]string {
+	labels := map[string]string{
+		ManagedLabel: "true",
+		ExpiresLabel: strconv.FormatInt(expires.Unix(), 10),
+	}
+	if uid != "" {
+		labels[ExperimentLabel] = uid
+	}
+	return labels
+}
+
+// ManagedExpiry returns the expiry of the managed container, false if the container is not managed or the expiry
+// is not labeled
+func ManagedExpiry(info ContainerInfo) (time.Time, bool) {
+	if info.Labels[ManagedLabel] != "true" {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(info.Labels[ExpiresLabel], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// ManagedFilter selects the managed containers
+type ManagedFilter struct {
+	// Uid selects the containers of the experiment, all if empty
+	Uid string
+	// ExpiredAt selects the containers expired at the time, the expiry is ignored if zero
+	ExpiredAt time.Time
+}
+
+// Match returns true if the container is managed and selected by the filter
+func (f ManagedFilter) Match(info ContainerInfo) bool {
+	if info.Labels[ManagedLabel] != "true" {
+		return false
+	}
+	if f.Uid != "" && info.Labels[ExperimentLabel] != f.Uid {
+		return false
+	}
+	if f.ExpiredAt.IsZero() {
+		return true
+	}
+	expires, ok := ManagedExpiry(info)
+	return ok && !expires.After(f.ExpiredAt)
+}
+
+// ListManaged returns the managed containers selected by the filter, for the auditing and the garbage collection
+func ListManaged(ctx context.Context, c Container, filter ManagedFilter) ([]ContainerInfo, error) {
+	containers, err, _ := c.ListContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	managed := make([]ContainerInfo, 0)
+	for _, info := range containers {
+		if filter.Match(info) {
+			managed = append(managed, info)
+		}
+	}
+	return managed, nil
+}
+
+// RemoveManaged force removes the managed containers selected by the filter, the ids removed are returned with the
+// errors of the others
+func RemoveManaged(ctx context.Context, c Container, filter ManagedFilter) ([]string, error) {
+	managed, err := ListManaged(ctx, c, filter)
+	if err != nil {
+		return nil, err
+	}
+	removed := make([]string, 0, len(managed))
+	var errs []error
+	for _, info := range managed {
+		if err := c.RemoveContainer(ctx, info.ContainerId, true); err != nil {
+			errs = append(errs, fmt.Errorf("remove the managed container %s failed, %v", info.ContainerId, err))
+			continue
+		}
+		removed = append(removed, info.ContainerId)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// WithManagedLabels wraps the client so that the containers created by ExecuteAndRemove are labeled by
+// ManagedLabels, the experiment is the one of the context, see WithExperiment
+func WithManagedLabels(c Container) Container {
+	return &managedContainer{Container: c}
+}
+
+type managedContainer struct {
+	Container
+}
+
+func (c *managedContainer) Unwrap() Container {
+	return c.Container
+}
+
+func (c *managedContainer) ExecuteAndRemove(ctx context.Context, config *containertype.Config,
+	hostConfig *containertype.HostConfig, networkConfig *network.NetworkingConfig, containerName string, removed bool,
+	timeout time.Duration, command string, containerInfo ContainerInfo) (string, string, error, int32) {
+	// the config of the caller is not mutated
+	labeled := *config
+	labeled.Labels = make(map[string]string, len(config.Labels)+3)
+	for k, v := range config.Labels {
+		labeled.Labels[k] = v
+	}
+	for k, v := range ManagedLabels(ExperimentFromContext(ctx), time.Now().Add(ManagedTTL)) {
+		labeled.Labels[k] = v
+	}
+	return c.Container.ExecuteAndRemove(ctx, &labeled, hostConfig, networkConfig, containerName, removed, timeout,
+		command, containerInfo)
+}
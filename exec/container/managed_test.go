@@ -0,0 +1,79 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type listContainer struct {
+	helperContainer
+	containers []ContainerInfo
+	removed    []string
+}
+
+func (c *listContainer) ListContainers(ctx context.Context) ([]ContainerInfo, error, int32) {
+	return c.containers, nil, 0
+}
+
+func (c *listContainer) RemoveContainer(ctx context.Context, containerId string, force bool) error {
+	if containerId == "busy" {
+		return fmt.Errorf("container %s is busy", containerId)
+	}
+	c.removed = append(c.removed, containerId)
+	return nil
+}
+
+func TestManagedLabels(t *testing.T) {
+	client := &helperContainer{}
+	ctx := WithExperiment(context.Background(), "uid1")
+	if _, _, err, _ := ExecuteHelper(ctx, WithManagedLabels(client), NetworkToolsHelper, "", "c1-network",
+		"blade create network loss", ContainerInfo{ContainerId: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	labels := client.config.Labels
+	if labels[ManagedLabel] != "true" || labels[ExperimentLabel] != "uid1" || labels[HelperLabel] != "network-tools@1.0" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	expires, ok := ManagedExpiry(ContainerInfo{Labels: labels})
+	if !ok || expires.Before(time.Now().Add(ManagedTTL-time.Minute)) {
+		t.Errorf("unexpected expiry %s", expires)
+	}
+}
+
+func TestRemoveManaged(t *testing.T) {
+	now := time.Now()
+	client := &listContainer{containers: []ContainerInfo{
+		{ContainerId: "expired", Labels: ManagedLabels("uid1", now.Add(-time.Minute))},
+		{ContainerId: "alive", Labels: ManagedLabels("uid1", now.Add(time.Minute))},
+		{ContainerId: "other", Labels: ManagedLabels("uid2", now.Add(-time.Minute))},
+		{ContainerId: "busy", Labels: ManagedLabels("uid2", now.Add(-time.Minute))},
+		{ContainerId: "nginx", Labels: map[string]string{"app": "nginx"}},
+	}}
+	managed, err := ListManaged(context.Background(), client, ManagedFilter{Uid: "uid1"})
+	if err != nil || len(managed) != 2 {
+		t.Errorf("expected the containers of uid1, got %v, %v", managed, err)
+	}
+	removed, err := RemoveManaged(context.Background(), client, ManagedFilter{ExpiredAt: now})
+	if !reflect.DeepEqual(removed, []string{"expired", "other"}) || err == nil {
+		t.Errorf("expected the expired containers removed and the busy one failed, got %v, %v", removed, err)
+	}
+}
@@ -0,0 +1,346 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mock is a scriptable container.Container for testing the chaos flows without a runtime
+package mock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	containertype "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// Call is a recorded method call, the args are in the order of the method parameters without the context
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Container records every call and returns the response of the method func if set, otherwise the default
+// response served from the Containers and Pids
+type Container struct {
+	mu    sync.Mutex
+	calls []Call
+
+	// Containers are served by the default lookups, the pid of a container is looked up from the Pids
+	Containers []container.ContainerInfo
+	Pids       map[string]int32
+
+	RuntimeVersionFunc              func(ctx context.Context) (string, string, error)
+	GetPidByIdFunc                  func(ctx context.Context, containerId string) (int32, error, int32)
+	GetContainerByIdFunc            func(ctx context.Context, containerId string) (container.ContainerInfo, error, int32)
+	GetContainerByNameFunc          func(ctx context.Context, containerName string) (container.ContainerInfo, error, int32)
+	GetContainerByLabelSelectorFunc func(containerLabelSelector map[string]string) (container.ContainerInfo, error, int32)
+	ListContainersFunc              func(ctx context.Context) ([]container.ContainerInfo, error, int32)
+	GetRootfsPathFunc               func(ctx context.Context, containerId string) (string, error, int32)
+	GetCgroupPathFunc               func(ctx context.Context, containerId string) (container.CgroupPath, error, int32)
+	ListProcessesFunc               func(ctx context.Context, containerId string) ([]container.Process, error, int32)
+	WatchFunc                       func(ctx context.Context, filter container.EventFilter) (<-chan container.Event, error)
+	RemoveContainerFunc             func(ctx context.Context, containerId string, force bool) error
+	CopyToContainerFunc             func(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) (string, error)
+	ExecContainerFunc               func(ctx context.Context, containerId, command string) (string, error)
+	ExecuteAndRemoveFunc            func(ctx context.Context, config *containertype.Config, hostConfig *containertype.HostConfig,
+		networkConfig *network.NetworkingConfig, containerName string, removed bool, timeout time.Duration,
+		command string, containerInfo container.ContainerInfo) (string, string, error, int32)
+	RecreateFunc         func(ctx context.Context, containerId string, layer io.Reader, image string) (string, bool, error)
+	RestoreRecreatedFunc func(ctx context.Context, containerId, originId, image string, running bool) error
+}
+
+var _ container.Container = &Container{}
+var _ container.Recreator = &Container{}
+
+// NewContainer returns the mock serving the containers
+func NewContainer(containers ...container.ContainerInfo) *Container {
+	return &Container{
+		Containers: containers,
+		Pids:       make(map[string]int32),
+	}
+}
+
+// Calls returns the recorded calls in order
+func (m *Container) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call{}, m.calls...)
+}
+
+// CallsOf returns the recorded calls of the method
+func (m *Container) CallsOf(method string) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make([]Call, 0)
+	for _, call := range m.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset clears the recorded calls
+func (m *Container) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+func (m *Container) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+}
+
+func (m *Container) find(match func(info container.ContainerInfo) bool) (container.ContainerInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, info := range m.Containers {
+		if match(info) {
+			return info, true
+		}
+	}
+	return container.ContainerInfo{}, false
+}
+
+func (m *Container) RuntimeVersion(ctx context.Context) (string, string, error) {
+	m.record("RuntimeVersion")
+	if m.RuntimeVersionFunc != nil {
+		return m.RuntimeVersionFunc(ctx)
+	}
+	return "mock", "0.0.0", nil
+}
+
+func (m *Container) GetPidById(ctx context.Context, containerId string) (int32, error, int32) {
+	m.record("GetPidById", containerId)
+	if m.GetPidByIdFunc != nil {
+		return m.GetPidByIdFunc(ctx, containerId)
+	}
+	m.mu.Lock()
+	pid, ok := m.Pids[containerId]
+	m.mu.Unlock()
+	if !ok {
+		return -1, fmt.Errorf("pid of container %s not found", containerId), spec.ContainerExecFailed.Code
+	}
+	return pid, nil, spec.OK.Code
+}
+
+func (m *Container) GetContainerById(ctx context.Context, containerId string) (container.ContainerInfo, error, int32) {
+	m.record("GetContainerById", containerId)
+	if m.GetContainerByIdFunc != nil {
+		return m.GetContainerByIdFunc(ctx, containerId)
+	}
+	info, ok := m.find(func(info container.ContainerInfo) bool {
+		return info.ContainerId == containerId
+	})
+	if !ok {
+		return info, fmt.Errorf("container %s not found", containerId), spec.ParameterInvalidDockContainerId.Code
+	}
+	return info, nil, spec.OK.Code
+}
+
+func (m *Container) GetContainerByName(ctx context.Context, containerName string) (container.ContainerInfo, error, int32) {
+	m.record("GetContainerByName", containerName)
+	if m.GetContainerByNameFunc != nil {
+		return m.GetContainerByNameFunc(ctx, containerName)
+	}
+	info, ok := m.find(func(info container.ContainerInfo) bool {
+		return info.ContainerName == containerName
+	})
+	if !ok {
+		return info, fmt.Errorf("container %s not found", containerName), spec.ParameterInvalidDockContainerName.Code
+	}
+	return info, nil, spec.OK.Code
+}
+
+func (m *Container) GetContainerByLabelSelector(containerLabelSelector map[string]string) (container.ContainerInfo, error, int32) {
+	m.record("GetContainerByLabelSelector", containerLabelSelector)
+	if m.GetContainerByLabelSelectorFunc != nil {
+		return m.GetContainerByLabelSelectorFunc(containerLabelSelector)
+	}
+	info, ok := m.find(func(info container.ContainerInfo) bool {
+		for k, v := range containerLabelSelector {
+			if info.Labels[k] != v {
+				return false
+			}
+		}
+		return true
+	})
+	if !ok {
+		return info, fmt.Errorf("no containers found by labels %v", containerLabelSelector), spec.ContainerExecFailed.Code
+	}
+	return info, nil, spec.OK.Code
+}
+
+func (m *Container) ListContainers(ctx context.Context) ([]container.ContainerInfo, error, int32) {
+	m.record("ListContainers")
+	if m.ListContainersFunc != nil {
+		return m.ListContainersFunc(ctx)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]container.ContainerInfo{}, m.Containers...), nil, spec.OK.Code
+}
+
+// GetRootfsPath returns the /proc/<pid>/root of the container by default
+func (m *Container) GetRootfsPath(ctx context.Context, containerId string) (string, error, int32) {
+	m.record("GetRootfsPath", containerId)
+	if m.GetRootfsPathFunc != nil {
+		return m.GetRootfsPathFunc(ctx, containerId)
+	}
+	m.mu.Lock()
+	pid, ok := m.Pids[containerId]
+	m.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("pid of container %s not found", containerId), spec.ContainerExecFailed.Code
+	}
+	return fmt.Sprintf("/proc/%d/root", pid), nil, spec.OK.Code
+}
+
+// GetCgroupPath returns the v2 cgroup of the container id by default
+func (m *Container) GetCgroupPath(ctx context.Context, containerId string) (container.CgroupPath, error, int32) {
+	m.record("GetCgroupPath", containerId)
+	if m.GetCgroupPathFunc != nil {
+		return m.GetCgroupPathFunc(ctx, containerId)
+	}
+	if _, ok := m.find(func(info container.ContainerInfo) bool {
+		return info.ContainerId == containerId
+	}); !ok {
+		return container.CgroupPath{}, fmt.Errorf("container %s not found", containerId), spec.ParameterInvalidDockContainerId.Code
+	}
+	return container.CgroupPath{Version: container.CgroupV2, Unified: "/" + containerId}, nil, spec.OK.Code
+}
+
+// ListProcesses returns the pid of the container as the only process by default
+func (m *Container) ListProcesses(ctx context.Context, containerId string) ([]container.Process, error, int32) {
+	m.record("ListProcesses", containerId)
+	if m.ListProcessesFunc != nil {
+		return m.ListProcessesFunc(ctx, containerId)
+	}
+	m.mu.Lock()
+	pid, ok := m.Pids[containerId]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("pid of container %s not found", containerId), spec.ContainerExecFailed.Code
+	}
+	return []container.Process{{Pid: pid, Comm: "mock", Cmdline: "mock"}}, nil, spec.OK.Code
+}
+
+// Watch returns a channel without events by default, it is closed when the context is done
+func (m *Container) Watch(ctx context.Context, filter container.EventFilter) (<-chan container.Event, error) {
+	m.record("Watch", filter)
+	if m.WatchFunc != nil {
+		return m.WatchFunc(ctx, filter)
+	}
+	events := make(chan container.Event)
+	go func() {
+		<-ctx.Done()
+		close(events)
+	}()
+	return events, nil
+}
+
+func (m *Container) RemoveContainer(ctx context.Context, containerId string, force bool) error {
+	m.record("RemoveContainer", containerId, force)
+	if m.RemoveContainerFunc != nil {
+		return m.RemoveContainerFunc(ctx, containerId, force)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, info := range m.Containers {
+		if info.ContainerId == containerId {
+			m.Containers = append(m.Containers[:i:i], m.Containers[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("container %s not found", containerId)
+}
+
+// CopyToContainer returns the extraction path in dstPath by default
+func (m *Container) CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) (string, error) {
+	m.record("CopyToContainer", containerId, srcFile, dstPath, extractDirName, override)
+	if m.CopyToContainerFunc != nil {
+		return m.CopyToContainerFunc(ctx, containerId, srcFile, dstPath, extractDirName, override)
+	}
+	return container.ExtractPath(dstPath, extractDirName), nil
+}
+
+// ExecContainer returns a success response of the chaosblade cli by default
+func (m *Container) ExecContainer(ctx context.Context, containerId, command string) (string, error) {
+	m.record("ExecContainer", containerId, command)
+	if m.ExecContainerFunc != nil {
+		return m.ExecContainerFunc(ctx, containerId, command)
+	}
+	return spec.ReturnSuccess("").Print(), nil
+}
+
+func (m *Container) ExecuteAndRemove(ctx context.Context, config *containertype.Config, hostConfig *containertype.HostConfig,
+	networkConfig *network.NetworkingConfig, containerName string, removed bool, timeout time.Duration,
+	command string, containerInfo container.ContainerInfo) (string, string, error, int32) {
+	m.record("ExecuteAndRemove", config, hostConfig, networkConfig, containerName, removed, timeout, command, containerInfo)
+	if m.ExecuteAndRemoveFunc != nil {
+		return m.ExecuteAndRemoveFunc(ctx, config, hostConfig, networkConfig, containerName, removed, timeout, command, containerInfo)
+	}
+	return containerName, spec.ReturnSuccess("").Print(), nil, spec.OK.Code
+}
+
+// Recreate drains the layer and serves the container with the id suffixed by -recreated by default, the origin
+// container is reported running
+func (m *Container) Recreate(ctx context.Context, containerId string, layer io.Reader, image string) (string, bool, error) {
+	m.record("Recreate", containerId, image)
+	if m.RecreateFunc != nil {
+		return m.RecreateFunc(ctx, containerId, layer, image)
+	}
+	if _, err := io.Copy(io.Discard, layer); err != nil {
+		return "", false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, info := range m.Containers {
+		if info.ContainerId == containerId {
+			info.ContainerId = containerId + "-recreated"
+			m.Containers = append(m.Containers, info)
+			if pid, ok := m.Pids[containerId]; ok {
+				m.Pids[info.ContainerId] = pid
+			}
+			return info.ContainerId, true, nil
+		}
+	}
+	return "", false, fmt.Errorf("container %s not found", containerId)
+}
+
+// RestoreRecreated removes the recreated container by default
+func (m *Container) RestoreRecreated(ctx context.Context, containerId, originId, image string, running bool) error {
+	m.record("RestoreRecreated", containerId, originId, image, running)
+	if m.RestoreRecreatedFunc != nil {
+		return m.RestoreRecreatedFunc(ctx, containerId, originId, image, running)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, info := range m.Containers {
+		if info.ContainerId == containerId {
+			m.Containers = append(m.Containers[:i:i], m.Containers[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
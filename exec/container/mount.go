@@ -0,0 +1,190 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// The volume types of the mounts, derived from the kubelet volume paths
+const (
+	VolumeEmptyDir   = "emptyDir"
+	VolumePersistent = "persistentVolume"
+	VolumeConfigMap  = "configMap"
+	VolumeSecret     = "secret"
+	VolumeProjected  = "projected"
+	VolumeSubPath    = "subPath"
+	// VolumeKubelet is the file managed by the kubelet, such as /etc/hosts and the termination log
+	VolumeKubelet  = "kubelet"
+	VolumeHostPath = "hostPath"
+)
+
+// kubeletPodsDir is the pod directory of the kubelet, the volumes are mounted from it
+const kubeletPodsDir = "/var/lib/kubelet/pods/"
+
+// volumePlugins are the volume types of the kubelet plugin directories, the others are persistent volumes
+var volumePlugins = map[string]string{
+	"kubernetes.io~empty-dir":    VolumeEmptyDir,
+	"kubernetes.io~configmap":    VolumeConfigMap,
+	"kubernetes.io~secret":       VolumeSecret,
+	"kubernetes.io~projected":    VolumeProjected,
+	"kubernetes.io~downward-api": VolumeProjected,
+}
+
+// sharedVolumePlugins are the plugins of the network filesystems shared with the other nodes
+var sharedVolumePlugins = map[string]bool{
+	"kubernetes.io~nfs":        true,
+	"kubernetes.io~cephfs":     true,
+	"kubernetes.io~glusterfs":  true,
+	"kubernetes.io~azure-file": true,
+	"kubernetes.io~quobyte":    true,
+}
+
+// sharedFsTypes are the network filesystem types
+var sharedFsTypes = map[string]bool{
+	"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "ceph": true, "glusterfs": true, "lustre": true, "9p": true,
+	"fuse.glusterfs": true, "fuse.ceph-fuse": true, "fuse.sshfs": true, "fuse.s3fs": true, "fuse.gcsfuse": true,
+}
+
+// Mount is a volume mounted into the container
+type Mount struct {
+	ContainerPath string
+	HostPath      string
+	ReadOnly      bool
+	// VolumeType is one of the Volume types, empty if the host path is not a volume
+	VolumeType string
+	// VolumeName is the pod volume name, or the persistent volume name of the csi volumes
+	VolumeName string
+	// plugin is the kubelet plugin directory of the volume
+	plugin string
+}
+
+// NewMount returns the mount classified by the kubelet volume path of the host path
+func NewMount(containerPath, hostPath string, readOnly bool) Mount {
+	mount := Mount{ContainerPath: containerPath, HostPath: hostPath, ReadOnly: readOnly}
+	if !strings.HasPrefix(hostPath, kubeletPodsDir) {
+		if hostPath != "" {
+			mount.VolumeType = VolumeHostPath
+		}
+		return mount
+	}
+	// /var/lib/kubelet/pods/<uid>/volumes/kubernetes.io~empty-dir/<name>
+	parts := strings.Split(strings.TrimPrefix(hostPath, kubeletPodsDir), "/")
+	if len(parts) < 2 {
+		return mount
+	}
+	switch parts[1] {
+	case "volumes":
+		if len(parts) < 4 {
+			return mount
+		}
+		mount.plugin, mount.VolumeName = parts[2], parts[3]
+		if volumeType, ok := volumePlugins[mount.plugin]; ok {
+			mount.VolumeType = volumeType
+		} else {
+			mount.VolumeType = VolumePersistent
+		}
+	case "volume-subpaths":
+		mount.VolumeType = VolumeSubPath
+		if len(parts) > 2 {
+			mount.VolumeName = parts[2]
+		}
+	default:
+		mount.VolumeType = VolumeKubelet
+	}
+	return mount
+}
+
+// IsShared returns whether the volume is a network filesystem shared with the other nodes, the filesystem of the
+// host path is checked for the csi and the host path volumes
+func (m Mount) IsShared() bool {
+	if sharedVolumePlugins[m.plugin] {
+		return true
+	}
+	if m.VolumeType != VolumePersistent && m.VolumeType != VolumeHostPath && m.VolumeType != VolumeSubPath {
+		return false
+	}
+	mount, ok := hostMountOf(m.HostPath)
+	if !ok {
+		return false
+	}
+	return sharedFsTypes[mount.FsType]
+}
+
+// BackingDevice returns the block device backing the host path of the mount, the device is refused if it contains
+// the kubelet pod directories, e.g. the root filesystem, or it is mounted anywhere else than the pod directories and
+// the host mount of the volume
+func (m Mount) BackingDevice() (string, error) {
+	volume, ok := hostMountOf(m.HostPath)
+	if !ok || !strings.HasPrefix(volume.Source, "/dev/") {
+		return "", fmt.Errorf("the host path %s is not on a block device", m.HostPath)
+	}
+	// the device containing the kubelet pod directories backs the volumes of every pod
+	if strings.HasPrefix(kubeletPodsDir, strings.TrimSuffix(volume.MountPoint, "/")+"/") {
+		return "", fmt.Errorf("the device %s of %s backs the kubelet pod directories", volume.Source, m.HostPath)
+	}
+	for _, mount := range readHostMounts() {
+		if mount.Source != volume.Source || mount.MountPoint == volume.MountPoint ||
+			strings.HasPrefix(mount.MountPoint, kubeletPodsDir) {
+			continue
+		}
+		return "", fmt.Errorf("the device %s of %s is shared with the host mount %s", volume.Source, m.HostPath,
+			mount.MountPoint)
+	}
+	return volume.Source, nil
+}
+
+// HostPathOf returns the host path of the path in the container under the mount
+func (m Mount) HostPathOf(file string) string {
+	return path.Join(m.HostPath, strings.TrimPrefix(path.Clean(file), path.Clean(m.ContainerPath)))
+}
+
+// RootOf returns the root on the host the path in the container under the mount is resolved in, and the path in the
+// root, the source of the mount, or its directory if the source is a file mounted by itself, such as /etc/hosts
+func (m Mount) RootOf(file string) (string, string) {
+	name := strings.TrimPrefix(strings.TrimPrefix(path.Clean(file), path.Clean(m.ContainerPath)), "/")
+	if info, err := os.Stat(m.HostPath); name == "" && err == nil && !info.IsDir() {
+		return path.Dir(m.HostPath), path.Base(m.HostPath)
+	}
+	return m.HostPath, name
+}
+
+// IsSynced returns whether the kubelet rewrites the files of the volume from the api objects, the changes of the
+// files are reverted by the next sync of the pod
+func (m Mount) IsSynced() bool {
+	return m.VolumeType == VolumeSecret || m.VolumeType == VolumeConfigMap || m.VolumeType == VolumeProjected
+}
+
+// MountOf returns the mount containing the path in the container, the longest container path wins
+func (c ContainerInfo) MountOf(file string) (Mount, bool) {
+	file = path.Clean(file)
+	var found Mount
+	ok := false
+	for _, mount := range c.Mounts {
+		containerPath := path.Clean(mount.ContainerPath)
+		if file != containerPath && !strings.HasPrefix(file, strings.TrimSuffix(containerPath, "/")+"/") {
+			continue
+		}
+		if !ok || len(containerPath) > len(path.Clean(found.ContainerPath)) {
+			found, ok = mount, true
+		}
+	}
+	return found, ok
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+const testPodDir = "/var/lib/kubelet/pods/8d2c6a6e-1f7b-4c3a-9a51-2f5b1c0d9e7f"
+
+func TestNewMount(t *testing.T) {
+	tests := []struct {
+		hostPath   string
+		volumeType string
+		volumeName string
+	}{
+		{testPodDir + "/volumes/kubernetes.io~empty-dir/cache", VolumeEmptyDir, "cache"},
+		{testPodDir + "/volumes/kubernetes.io~csi/pvc-1234/mount", VolumePersistent, "pvc-1234"},
+		{testPodDir + "/volumes/kubernetes.io~nfs/shared", VolumePersistent, "shared"},
+		{testPodDir + "/volumes/kubernetes.io~configmap/config", VolumeConfigMap, "config"},
+		{testPodDir + "/volumes/kubernetes.io~projected/kube-api-access-x1", VolumeProjected, "kube-api-access-x1"},
+		{testPodDir + "/volume-subpaths/data/app/0", VolumeSubPath, "data"},
+		{testPodDir + "/etc-hosts", VolumeKubelet, ""},
+		{"/data/logs", VolumeHostPath, ""},
+	}
+	for _, tt := range tests {
+		mount := NewMount("/mnt", tt.hostPath, false)
+		if mount.VolumeType != tt.volumeType || mount.VolumeName != tt.volumeName {
+			t.Errorf("classify %s, expected %s %s, got %s %s", tt.hostPath, tt.volumeType, tt.volumeName,
+				mount.VolumeType, mount.VolumeName)
+		}
+	}
+}
+
+func TestMountShared(t *testing.T) {
+	csiPath := testPodDir + "/volumes/kubernetes.io~csi/pvc-1234/mount"
+	setupProcRoot(t, "1 0 8:1 / / rw - ext4 /dev/sda1 rw\n"+
+		"2 1 0:50 / "+csiPath+" rw - nfs4 10.0.0.1:/exports/pvc-1234 rw\n"+
+		"3 1 8:2 / /data rw - xfs /dev/sdb rw\n")
+	tests := []struct {
+		mount  Mount
+		shared bool
+	}{
+		{NewMount("/shared", testPodDir+"/volumes/kubernetes.io~nfs/shared", false), true},
+		{NewMount("/data", csiPath, false), true},
+		{NewMount("/logs", "/data/logs", false), false},
+		{NewMount("/cache", testPodDir+"/volumes/kubernetes.io~empty-dir/cache", false), false},
+	}
+	for _, tt := range tests {
+		if shared := tt.mount.IsShared(); shared != tt.shared {
+			t.Errorf("expected the mount of %s shared %t, got %t", tt.mount.HostPath, tt.shared, shared)
+		}
+	}
+}
+
+func TestMountBackingDevice(t *testing.T) {
+	csiPath := testPodDir + "/volumes/kubernetes.io~csi/pvc-1234/mount"
+	setupProcRoot(t, "1 0 8:1 / / rw - ext4 /dev/sda1 rw\n"+
+		"2 1 253:3 / "+csiPath+" rw - xfs /dev/mapper/vg-pvc--1234 rw\n"+
+		"3 1 253:4 / /mnt/disks/ssd0 rw - ext4 /dev/mapper/vg-ssd0 rw\n"+
+		"4 1 253:4 / /mnt/backup rw - ext4 /dev/mapper/vg-ssd0 rw\n"+
+		"5 1 0:50 / /shared rw - nfs4 10.0.0.1:/exports rw\n")
+	tests := []struct {
+		mount  Mount
+		device string
+	}{
+		{NewMount("/data", csiPath, false), "/dev/mapper/vg-pvc--1234"},
+		{NewMount("/cache", testPodDir+"/volumes/kubernetes.io~empty-dir/cache", false), ""},
+		{NewMount("/ssd", "/mnt/disks/ssd0", false), ""},
+		{NewMount("/shared", "/shared/app", false), ""},
+	}
+	for _, tt := range tests {
+		device, err := tt.mount.BackingDevice()
+		if device != tt.device || (tt.device == "") != (err != nil) {
+			t.Errorf("expected the device %q of %s, got %q, %v", tt.device, tt.mount.HostPath, device, err)
+		}
+	}
+}
+
+func TestMountOf(t *testing.T) {
+	info := ContainerInfo{Mounts: []Mount{
+		NewMount("/data", "/host/data", false),
+		NewMount("/data/cache", "/host/cache", false),
+		NewMount("/etc/hosts", testPodDir+"/etc-hosts", false),
+	}}
+	if mount, ok := info.MountOf("/data/cache/file"); !ok || mount.HostPath != "/host/cache" {
+		t.Errorf("expected the longest mount, got %+v", mount)
+	}
+	if mount, ok := info.MountOf("/data/../data/db"); !ok || mount.HostPath != "/host/data" {
+		t.Errorf("expected the data mount, got %+v", mount)
+	}
+	if _, ok := info.MountOf("/database"); ok {
+		t.Error("expected no mount of the path with the same prefix")
+	}
+}
+
+func TestMountHostPathOf(t *testing.T) {
+	mount := NewMount("/etc/tls/", testPodDir+"/volumes/kubernetes.io~secret/tls", true)
+	if hostPath := mount.HostPathOf("/etc/tls/tls.crt"); hostPath != testPodDir+"/volumes/kubernetes.io~secret/tls/tls.crt" {
+		t.Errorf("unexpected host path %s", hostPath)
+	}
+	if !mount.IsSynced() || NewMount("/data", "/data", false).IsSynced() {
+		t.Error("expected the secret volume synced only")
+	}
+}
+
+func TestMountRootOf(t *testing.T) {
+	dir := t.TempDir()
+	hosts := path.Join(dir, "etc-hosts")
+	if err := os.WriteFile(hosts, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if root, name := NewMount("/etc/hosts", hosts, false).RootOf("/etc/hosts"); root != dir || name != "etc-hosts" {
+		t.Errorf("expected the file mounted resolved in its directory, got %s %s", root, name)
+	}
+	if root, name := NewMount("/etc/tls/", dir, true).RootOf("/etc/tls/tls.crt"); root != dir || name != "tls.crt" {
+		t.Errorf("expected the file resolved in the source, got %s %s", root, name)
+	}
+	if root, name := NewMount("/data", dir, false).RootOf("/data"); root != dir || name != "" {
+		t.Errorf("expected the directory mounted resolved as the root, got %s %s", root, name)
+	}
+}
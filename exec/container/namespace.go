@@ -0,0 +1,40 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import "context"
+
+// NamespaceLister is implemented by the runtimes partitioning the containers by the namespaces, such as containerd
+type NamespaceLister interface {
+	// Namespaces returns the namespaces of the runtime
+	Namespaces(ctx context.Context) ([]string, error)
+}
+
+// AsNamespaceLister returns the namespace lister of the client, the wrappers of the client, such as the breaker, are
+// skipped
+func AsNamespaceLister(c Container) (NamespaceLister, bool) {
+	for {
+		if lister, ok := c.(NamespaceLister); ok {
+			return lister, true
+		}
+		wrapper, ok := c.(interface{ Unwrap() Container })
+		if !ok {
+			return nil, false
+		}
+		c = wrapper.Unwrap()
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	containertype "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// ExecPolicy restricts the commands ExecContainer runs in the target containers and ExecuteAndRemove runs in the
+// helper containers, for the organizations permitting the fault injection but not the arbitrary commands in the
+// production workloads. The commands are run by the shell, so a command is split into the simple commands by the
+// control operators such as && and ; first, and the command and process substitutions and the subshells are denied.
+// A command is denied if it or any of its simple commands matches a deny pattern, and if allow patterns are set,
+// every simple command must match one of them. The commands deploying the blade, such as mkdir, mv and rm, pass
+// through the policy as well.
+type ExecPolicy struct {
+	Allow []*regexp.Regexp
+	Deny  []*regexp.Regexp
+}
+
+// execPolicyFile is the json file of the policy, the patterns are regular expressions matched against the command
+type execPolicyFile struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// ExecDeniedError is returned without calling the runtime if the command is denied by the policy
+type ExecDeniedError struct {
+	ContainerId string
+	Command     string
+	Reason      string
+}
+
+func (e *ExecDeniedError) Error() string {
+	return fmt.Sprintf("the command `%s` in container %s is denied by the exec policy, %s", e.Command,
+		e.ContainerId, e.Reason)
+}
+
+// NewExecPolicy compiles the allow and deny patterns
+func NewExecPolicy(allow, deny []string) (*ExecPolicy, error) {
+	policy := &ExecPolicy{}
+	var err error
+	if policy.Allow, err = compilePatterns(allow); err != nil {
+		return nil, err
+	}
+	if policy.Deny, err = compilePatterns(deny); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// LoadExecPolicy reads the policy from the json file, such as {"allow": ["^/opt/chaosblade/blade "], "deny": ["rm -rf /$"]}
+func LoadExecPolicy(file string) (*ExecPolicy, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var patterns execPolicyFile
+	if err := json.Unmarshal(content, &patterns); err != nil {
+		return nil, fmt.Errorf("parse the exec policy %s failed, %v", file, err)
+	}
+	return NewExecPolicy(patterns.Allow, patterns.Deny)
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("illegal pattern %s of the exec policy, %v", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Check returns the reason if the command is denied, empty if allowed
+func (p *ExecPolicy) Check(command string) string {
+	if len(p.Allow) == 0 && len(p.Deny) == 0 {
+		return ""
+	}
+	simpleCommands, err := SplitCommand(command)
+	if err != nil {
+		return err.Error()
+	}
+	for _, simple := range append([]string{command}, simpleCommands...) {
+		for _, re := range p.Deny {
+			if re.MatchString(simple) {
+				return fmt.Sprintf("`%s` matches the denied pattern %s", simple, re)
+			}
+		}
+	}
+	if len(p.Allow) == 0 {
+		return ""
+	}
+	for _, simple := range simpleCommands {
+		if !matchAny(p.Allow, simple) {
+			return fmt.Sprintf("`%s` matches no allowed pattern", simple)
+		}
+	}
+	return ""
+}
+
+func matchAny(patterns []*regexp.Regexp, command string) bool {
+	for _, re := range patterns {
+		if re.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitCommand splits the shell command into the simple commands by the control operators out of the quotes, such
+// as `[ -e x ] && cat y 2>/dev/null` into `[ -e x ]` and `cat y 2>/dev/null`, the redirections are kept in the
+// simple commands. The command substitutions, the process substitutions and the subshells run the commands the
+// patterns can't see, they are refused.
+func SplitCommand(command string) ([]string, error) {
+	commands := make([]string, 0)
+	var current strings.Builder
+	cut := func() {
+		if simple := strings.TrimSpace(current.String()); simple != "" {
+			commands = append(commands, simple)
+		}
+		current.Reset()
+	}
+	runes := []rune(command)
+	single, double := false, false
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case single:
+			single = c != '\''
+		case c == '\\':
+			current.WriteRune(c)
+			if next != 0 {
+				current.WriteRune(next)
+				i++
+			}
+			continue
+		case c == '`' || (c == '$' && next == '('):
+			return nil, fmt.Errorf("the command substitution of `%s` is denied", command)
+		case double:
+			double = c != '"'
+		case c == '\'':
+			single = true
+		case c == '"':
+			double = true
+		case (c == '<' || c == '>') && next == '(':
+			return nil, fmt.Errorf("the process substitution of `%s` is denied", command)
+		case c == '(' || c == ')':
+			return nil, fmt.Errorf("the subshell of `%s` is denied", command)
+		case c == '&' && (next == '>' || strings.HasSuffix(current.String(), ">") ||
+			strings.HasSuffix(current.String(), "<")):
+			// the redirections such as 2>&1 and &>/dev/null
+		case c == ';' || c == '\n' || c == '|' || c == '&':
+			cut()
+			if (c == '|' || c == '&') && next == c {
+				i++
+			}
+			continue
+		}
+		current.WriteRune(c)
+	}
+	if single || double {
+		return nil, fmt.Errorf("the quote of `%s` is not closed", command)
+	}
+	cut()
+	return commands, nil
+}
+
+// WithExecPolicy wraps the client so that the commands executed in the containers are checked by the policy
+func WithExecPolicy(c Container, policy *ExecPolicy) Container {
+	return &policyContainer{Container: c, policy: policy}
+}
+
+type policyContainer struct {
+	Container
+	policy *ExecPolicy
+}
+
+func (c *policyContainer) Unwrap() Container {
+	return c.Container
+}
+
+func (c *policyContainer) ExecuteAndRemove(ctx context.Context, config *containertype.Config,
+	hostConfig *containertype.HostConfig, networkConfig *network.NetworkingConfig, containerName string, removed bool,
+	timeout time.Duration, command string, containerInfo ContainerInfo) (string, string, error, int32) {
+	if reason := c.policy.Check(command); reason != "" {
+		return "", "", &ExecDeniedError{ContainerId: containerInfo.ContainerId, Command: command, Reason: reason},
+			spec.ParameterIllegal.Code
+	}
+	return c.Container.ExecuteAndRemove(ctx, config, hostConfig, networkConfig, containerName, removed, timeout,
+		command, containerInfo)
+}
+
+func (c *policyContainer) ExecContainer(ctx context.Context, containerId, command string) (string, error) {
+	if reason := c.policy.Check(command); reason != "" {
+		return "", &ExecDeniedError{ContainerId: containerId, Command: command, Reason: reason}
+	}
+	return c.Container.ExecContainer(ctx, containerId, command)
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	containertype "github.com/docker/docker/api/types/container"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/transcript"
+)
+
+type execRecorder struct {
+	Container
+	commands []string
+}
+
+func (r *execRecorder) ExecContainer(ctx context.Context, containerId, command string) (string, error) {
+	r.commands = append(r.commands, command)
+	return "ok", nil
+}
+
+func (r *execRecorder) Recreate(ctx context.Context, containerId string, layer io.Reader, image string) (string, bool, error) {
+	return "recreated", true, nil
+}
+
+func (r *execRecorder) RestoreRecreated(ctx context.Context, containerId, originId, image string, running bool) error {
+	return nil
+}
+
+func TestExecPolicy(t *testing.T) {
+	file := path.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(file, []byte(`{"allow":["^/opt/chaosblade/blade ","^(mkdir|mv|rm) "],
+		"deny":["rm -rf /$"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadExecPolicy(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &execRecorder{}
+	client := WithExecPolicy(recorder, policy)
+	tests := []struct {
+		command string
+		denied  bool
+	}{
+		{"/opt/chaosblade/blade create cpu fullload --uid 1", false},
+		{"mv /opt/chaosblade-1.7.2 /opt/chaosblade", false},
+		{"rm -rf /", true},
+		{"cat /etc/shadow", true},
+		// the simple commands chained to the allowed one are checked too
+		{"/opt/chaosblade/blade x; rm -rf /", true},
+		{"/opt/chaosblade/blade x && cat /etc/shadow", true},
+		{"/opt/chaosblade/blade x | sh", true},
+		{"/opt/chaosblade/blade $(cat /etc/shadow)", true},
+		{"/opt/chaosblade/blade `id`", true},
+		{"mkdir -p /opt && mv /tmp/a '/opt/b;c' 2>&1", false},
+	}
+	for _, tt := range tests {
+		_, err := client.ExecContainer(context.Background(), "c1", tt.command)
+		var denied *ExecDeniedError
+		if errors.As(err, &denied) != tt.denied {
+			t.Errorf("expected %q denied %t, got %v", tt.command, tt.denied, err)
+		}
+	}
+	if len(recorder.commands) != 3 {
+		t.Errorf("expected the denied commands not executed, got %q", recorder.commands)
+	}
+	if _, ok := AsRecreator(WithExecPolicy(WithBreaker(recorder, &Breaker{}), policy)); !ok {
+		t.Error("expected the recreator of the wrapped client")
+	}
+	if _, err := NewExecPolicy(nil, []string{"("}); err == nil {
+		t.Error("expected the illegal pattern refused")
+	}
+	if policy, _ := NewExecPolicy(nil, nil); policy.Check("anything") != "" {
+		t.Error("expected the empty policy allowing all commands")
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		commands []string
+	}{
+		{"[ -e '/opt/chaosblade/blade' ] && cat '/opt/chaosblade/.version' 2>/dev/null",
+			[]string{"[ -e '/opt/chaosblade/blade' ]", "cat '/opt/chaosblade/.version' 2>/dev/null"}},
+		{"ps -eo pid 2>/dev/null || ps -o pid", []string{"ps -eo pid 2>/dev/null", "ps -o pid"}},
+		{"echo 'a;b|c' > \"/tmp/x y\"; true &", []string{"echo 'a;b|c' > \"/tmp/x y\"", "true"}},
+		{"blade x 2>&1 &>/dev/null | tee log", []string{"blade x 2>&1 &>/dev/null", "tee log"}},
+		{"echo a\\;b\nrm x", []string{"echo a\\;b", "rm x"}},
+	}
+	for _, tt := range tests {
+		commands, err := SplitCommand(tt.command)
+		if err != nil || strings.Join(commands, "\n") != strings.Join(tt.commands, "\n") {
+			t.Errorf("expected %q of %q, got %q, %v", tt.commands, tt.command, commands, err)
+		}
+	}
+	for _, command := range []string{"echo $(id)", "echo \"`id`\"", "(rm -rf /)", "diff <(ls)", "echo 'a",
+		"echo \"$(id)\""} {
+		if _, err := SplitCommand(command); err == nil {
+			t.Errorf("expected %q refused", command)
+		}
+	}
+	// the substitution in the single quotes is a literal
+	if commands, err := SplitCommand("echo '$(id)'"); err != nil || len(commands) != 1 {
+		t.Errorf("unexpected %q, %v", commands, err)
+	}
+}
+
+func TestExecPolicyExecuteAndRemove(t *testing.T) {
+	policy, err := NewExecPolicy([]string{"^/opt/chaosblade/blade "}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := WithExecPolicy(&execRecorder{}, policy)
+	_, _, err, _ = client.ExecuteAndRemove(context.Background(), &containertype.Config{}, nil, nil, "helper", true, 0,
+		"/opt/chaosblade/blade x; rm -rf /", ContainerInfo{ContainerId: "c1"})
+	var denied *ExecDeniedError
+	if !errors.As(err, &denied) || denied.ContainerId != "c1" {
+		t.Errorf("expected the helper command denied, got %v", err)
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	stateDir := t.TempDir()
+	ctx := transcript.WithTranscript(context.Background(), stateDir, "uid1", transcript.PhaseCreate)
+	policy, err := NewExecPolicy(nil, []string{"^rm "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := WithTranscript(WithExecPolicy(&execRecorder{}, policy))
+	client.ExecContainer(ctx, "c1", "echo ok")
+	client.ExecContainer(ctx, "c1", "rm -f /tmp/x")
+	entries, err := transcript.Load(stateDir, "uid1")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v, %v", entries, err)
+	}
+	if entries[0].Output != "ok" || entries[0].ExitCode != 0 || entries[1].ExitCode != -1 ||
+		!strings.Contains(entries[1].Error, "denied by the exec policy") {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+}
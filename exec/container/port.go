@@ -0,0 +1,63 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// PortsAnnotation is the container annotation the kubelet records the declared ports in
+const PortsAnnotation = "io.kubernetes.container.ports"
+
+// Port is a port declared by the container
+type Port struct {
+	Name          string `json:"name,omitempty"`
+	ContainerPort int32  `json:"containerPort"`
+	// HostPort is the port mapped on the host, zero if not mapped
+	HostPort int32 `json:"hostPort,omitempty"`
+	// Protocol is TCP, UDP or SCTP
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// ParsePortsAnnotation returns the ports of the kubelet annotation, e.g.
+// [{"name":"http","containerPort":8080,"protocol":"TCP"}], nil if not annotated
+func ParsePortsAnnotation(annotations map[string]string) []Port {
+	raw := annotations[PortsAnnotation]
+	if raw == "" {
+		return nil
+	}
+	var ports []Port
+	if err := json.Unmarshal([]byte(raw), &ports); err != nil {
+		return nil
+	}
+	for i := range ports {
+		if ports[i].Protocol == "" {
+			ports[i].Protocol = "TCP"
+		}
+		ports[i].Protocol = strings.ToUpper(ports[i].Protocol)
+	}
+	return ports
+}
+
+// PrimaryIP returns the first pod ip, empty if not reported
+func (c ContainerInfo) PrimaryIP() string {
+	if len(c.IPs) == 0 {
+		return ""
+	}
+	return c.IPs[0]
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortsAnnotation(t *testing.T) {
+	tests := []struct {
+		annotation string
+		expected   []Port
+	}{
+		{`[{"name":"http","containerPort":8080,"protocol":"TCP"}]`, []Port{{Name: "http", ContainerPort: 8080, Protocol: "TCP"}}},
+		{`[{"containerPort":53,"hostPort":5353,"protocol":"udp"},{"containerPort":9090}]`,
+			[]Port{{ContainerPort: 53, HostPort: 5353, Protocol: "UDP"}, {ContainerPort: 9090, Protocol: "TCP"}}},
+		{"", nil},
+		{"not json", nil},
+	}
+	for _, tt := range tests {
+		ports := ParsePortsAnnotation(map[string]string{PortsAnnotation: tt.annotation})
+		if !reflect.DeepEqual(ports, tt.expected) {
+			t.Errorf("parse %s, expected %+v, got %+v", tt.annotation, tt.expected, ports)
+		}
+	}
+}
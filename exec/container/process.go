@@ -0,0 +1,293 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// psColumns are the columns listed by ps, busybox ps only lists all processes without -e
+const psColumns = "pid,comm,args"
+
+// psCommand lists the processes in the container if the cgroup cannot be read on the host
+var psCommand = fmt.Sprintf("ps -eo %s 2>/dev/null || ps -o %s", psColumns, psColumns)
+
+// Process is a process of the container
+type Process struct {
+	// Pid is the host pid, or the pid in the container pid namespace if Namespaced
+	Pid        int32  `json:"pid"`
+	Comm       string `json:"comm"`
+	Cmdline    string `json:"cmdline"`
+	Namespaced bool   `json:"namespaced,omitempty"`
+}
+
+// ListProcesses returns the processes of the container sorted by the pid. The cgroup of the container is walked
+// on the host and the processes are read from /proc, ps is executed in the container if the cgroup cannot be read,
+// e.g. the agent is not in the host pid namespace
+func ListProcesses(ctx context.Context, client Container, containerId string) ([]Process, error, int32) {
+	cgroupPath, err, _ := client.GetCgroupPath(ctx, containerId)
+	if err == nil {
+		if processes, err := CgroupProcesses(cgroupPath); err == nil && len(processes) > 0 {
+			return processes, nil, spec.OK.Code
+		}
+	}
+	output, err := client.ExecContainer(ctx, containerId, psCommand)
+	if err != nil {
+		return nil, fmt.Errorf("list processes of container %s failed, %v", containerId, err), spec.ContainerExecFailed.Code
+	}
+	processes := ParsePsOutput(output)
+	if len(processes) == 0 {
+		return nil, fmt.Errorf("list processes of container %s failed, unexpected ps output: %s", containerId,
+			strings.TrimSpace(output)), spec.ContainerExecFailed.Code
+	}
+	return processes, nil, spec.OK.Code
+}
+
+// CgroupProcesses returns the processes in the cgroup and its descendants, the processes exited while reading are
+// skipped
+func CgroupProcesses(cgroupPath CgroupPath) ([]Process, error) {
+	var root string
+	var err error
+	for _, controller := range []string{"pids", "memory", "cpuacct", ""} {
+		if root, err = cgroupPath.Absolute(controller); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	pids := make(map[int32]bool)
+	err = filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// the child cgroup is removed while walking
+			if file != root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || entry.Name() != "cgroup.procs" {
+			return nil
+		}
+		return readCgroupProcs(file, pids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	processes := make([]Process, 0, len(pids))
+	for pid := range pids {
+		process, err := readProcess(pid)
+		if err != nil {
+			continue
+		}
+		processes = append(processes, process)
+	}
+	sort.Slice(processes, func(i, j int) bool { return processes[i].Pid < processes[j].Pid })
+	return processes, nil
+}
+
+func readCgroupProcs(file string, pids map[int32]bool) error {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		pid, err := strconv.ParseInt(strings.TrimSpace(scanner.Text()), 10, 32)
+		if err == nil && pid > 0 {
+			pids[int32(pid)] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// readProcess reads the comm and the cmdline of the process, the cmdline arguments are joined by spaces and it is
+// empty for the kernel threads and the zombies
+func readProcess(pid int32) (Process, error) {
+	dir := path.Join(procRoot, strconv.Itoa(int(pid)))
+	comm, err := os.ReadFile(path.Join(dir, "comm"))
+	if err != nil {
+		return Process{}, err
+	}
+	cmdline, err := os.ReadFile(path.Join(dir, "cmdline"))
+	if err != nil {
+		return Process{}, err
+	}
+	return Process{
+		Pid:     pid,
+		Comm:    strings.TrimSpace(string(comm)),
+		Cmdline: strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " ")),
+	}, nil
+}
+
+// ParsePsOutput parses the output of the ps command, the header and the ps process itself are skipped
+func ParsePsOutput(output string) []Process {
+	processes := make([]Process, 0)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.ParseInt(fields[0], 10, 32)
+		if err != nil {
+			continue
+		}
+		cmdline := strings.Join(fields[2:], " ")
+		if strings.Contains(cmdline, psColumns) {
+			continue
+		}
+		processes = append(processes, Process{Pid: int32(pid), Comm: fields[1], Cmdline: cmdline, Namespaced: true})
+	}
+	sort.Slice(processes, func(i, j int) bool { return processes[i].Pid < processes[j].Pid })
+	return processes
+}
+
+// AllowedCpus returns the list of the cpus the process may run on, such as 0-3,6, it is the effective cpuset of
+// the container if the process is its init process
+func AllowedCpus(pid int32) (string, error) {
+	f, err := os.Open(path.Join(procRoot, strconv.Itoa(int(pid)), "status"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), "Cpus_allowed_list:"); value != scanner.Text() {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("cpus allowed list of process %d not found", pid)
+}
+
+// Children returns the pids of the children of the process, the zombies included
+func Children(pid int32) ([]int32, error) {
+	content, err := os.ReadFile(path.Join(procRoot, strconv.Itoa(int(pid)), "task", strconv.Itoa(int(pid)), "children"))
+	if err != nil {
+		return nil, err
+	}
+	children := make([]int32, 0)
+	for _, field := range strings.Fields(string(content)) {
+		if child, err := strconv.ParseInt(field, 10, 32); err == nil {
+			children = append(children, int32(child))
+		}
+	}
+	return children, nil
+}
+
+// IsZombie returns whether the process is exited but not reaped by its parent
+func IsZombie(pid int32) (bool, error) {
+	content, err := os.ReadFile(path.Join(procRoot, strconv.Itoa(int(pid)), "stat"))
+	if err != nil {
+		return false, err
+	}
+	// the comm may contain spaces and parentheses, the state follows the last parenthesis
+	stat := string(content)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) == 0 {
+		return false, fmt.Errorf("illegal stat of process %d", pid)
+	}
+	return fields[0] == "Z", nil
+}
+
+// Credentials returns the effective uid and gid of the process
+func Credentials(pid int32) (int, int, error) {
+	f, err := os.Open(path.Join(procRoot, strconv.Itoa(int(pid)), "status"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	ids := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Uid:	1000	1000	1000	1000, the real, effective, saved and filesystem ids
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || (fields[0] != "Uid:" && fields[0] != "Gid:") {
+			continue
+		}
+		id, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, 0, fmt.Errorf("illegal %s of process %d", fields[0], pid)
+		}
+		ids[fields[0]] = id
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	uid, uidOk := ids["Uid:"]
+	gid, gidOk := ids["Gid:"]
+	if !uidOk || !gidOk {
+		return 0, 0, fmt.Errorf("credentials of process %d not found", pid)
+	}
+	return uid, gid, nil
+}
+
+// NofileLimit returns the soft and hard limits of the open files of the process
+func NofileLimit(pid int32) (uint64, uint64, error) {
+	f, err := os.Open(path.Join(procRoot, strconv.Itoa(int(pid)), "limits"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Max open files            1024                 1048576              files
+		value := strings.TrimPrefix(scanner.Text(), "Max open files")
+		if value == scanner.Text() {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) < 2 {
+			break
+		}
+		soft, softErr := strconv.ParseUint(fields[0], 10, 64)
+		hard, hardErr := strconv.ParseUint(fields[1], 10, 64)
+		if softErr != nil || hardErr != nil {
+			return 0, 0, fmt.Errorf("illegal open files limit %q of process %d", value, pid)
+		}
+		return soft, hard, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, fmt.Errorf("open files limit of process %d not found", pid)
+}
+
+// OpenFiles returns the number of the file descriptors opened by the process
+func OpenFiles(pid int32) (int, error) {
+	entries, err := os.ReadDir(path.Join(procRoot, strconv.Itoa(int(pid)), "fd"))
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func writeProcess(t *testing.T, pid, comm, cmdline string) {
+	t.Helper()
+	dir := mkdir(t, path.Join(procRoot, pid))
+	if err := os.WriteFile(path.Join(dir, "comm"), []byte(comm+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(dir, "cmdline"), []byte(cmdline), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCgroupProcesses(t *testing.T) {
+	setupProcRoot(t, "")
+	setupCgroupRoot(t, "kubepods.slice/crio-c1.scope/init")
+	scope := path.Join(cgroupRoot, "kubepods.slice/crio-c1.scope")
+	if err := os.WriteFile(path.Join(scope, "cgroup.procs"), []byte("42\n57\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the nested cgroup created by the init system of the container, 99 is exited
+	if err := os.WriteFile(path.Join(scope, "init", "cgroup.procs"), []byte("43\n99\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeProcess(t, "42", "java", "java\x00-Xmx1g\x00-jar\x00app.jar\x00")
+	writeProcess(t, "43", "sleep", "sleep\x00100\x00")
+	writeProcess(t, "57", "kworker", "")
+
+	processes, err := CgroupProcesses(CgroupPath{Version: CgroupV2, Unified: "/kubepods.slice/crio-c1.scope"})
+	if err != nil {
+		t.Fatalf("CgroupProcesses failed, %v", err)
+	}
+	expected := []Process{
+		{Pid: 42, Comm: "java", Cmdline: "java -Xmx1g -jar app.jar"},
+		{Pid: 43, Comm: "sleep", Cmdline: "sleep 100"},
+		{Pid: 57, Comm: "kworker"},
+	}
+	if !reflect.DeepEqual(processes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, processes)
+	}
+
+	if _, err := CgroupProcesses(CgroupPath{Version: CgroupV2, Unified: "/kubepods.slice/crio-gone.scope"}); err == nil {
+		t.Error("expected error of the removed cgroup")
+	}
+}
+
+func TestParsePsOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{"procps", "    PID COMMAND         COMMAND\n" +
+			"      1 nginx           nginx: master process nginx -g daemon off;\n" +
+			"     31 sh              sh -c ps -eo pid,comm,args 2>/dev/null || ps -o pid,comm,args\n" +
+			"     32 ps              ps -eo pid,comm,args\n" +
+			"      7 nginx           nginx: worker process\n"},
+		{"busybox", "PID   COMMAND          COMMAND\n" +
+			"    1 nginx            nginx: master process nginx -g daemon off;\n" +
+			"    7 nginx            nginx: worker process\n" +
+			"   32 ps               ps -o pid,comm,args\n"},
+	}
+	expected := []Process{
+		{Pid: 1, Comm: "nginx", Cmdline: "nginx: master process nginx -g daemon off;", Namespaced: true},
+		{Pid: 7, Comm: "nginx", Cmdline: "nginx: worker process", Namespaced: true},
+	}
+	for _, tt := range tests {
+		if processes := ParsePsOutput(tt.output); !reflect.DeepEqual(processes, expected) {
+			t.Errorf("parse %s output, expected %+v, got %+v", tt.name, expected, processes)
+		}
+	}
+}
+
+func TestAllowedCpus(t *testing.T) {
+	setupProcRoot(t, "")
+	dir := mkdir(t, path.Join(procRoot, "42"))
+	status := "Name:\tjava\nCpus_allowed:\t4f\nCpus_allowed_list:\t0-3,6\nMems_allowed_list:\t0\n"
+	if err := os.WriteFile(path.Join(dir, "status"), []byte(status), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cpus, err := AllowedCpus(42); err != nil || cpus != "0-3,6" {
+		t.Errorf("expected 0-3,6, got %q, %v", cpus, err)
+	}
+	if _, err := AllowedCpus(43); err == nil {
+		t.Error("expected error of the missing process")
+	}
+}
+
+func TestChildrenAndZombies(t *testing.T) {
+	setupProcRoot(t, "")
+	task := mkdir(t, path.Join(procRoot, "42", "task", "42"))
+	if err := os.WriteFile(path.Join(task, "children"), []byte("43 44 "), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if children, err := Children(42); err != nil || !reflect.DeepEqual(children, []int32{43, 44}) {
+		t.Errorf("expected [43 44], got %v, %v", children, err)
+	}
+	stats := map[string]string{"43": "43 (sleep) Z 42 1 1 0", "44": "44 (my (app)) S 42 1 1 0"}
+	for pid, stat := range stats {
+		dir := mkdir(t, path.Join(procRoot, pid))
+		if err := os.WriteFile(path.Join(dir, "stat"), []byte(stat), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if zombie, err := IsZombie(43); err != nil || !zombie {
+		t.Errorf("expected 43 zombie, got %t, %v", zombie, err)
+	}
+	if zombie, err := IsZombie(44); err != nil || zombie {
+		t.Errorf("expected 44 not zombie, got %t, %v", zombie, err)
+	}
+	if _, err := IsZombie(45); err == nil {
+		t.Error("expected error of the missing process")
+	}
+}
+
+func TestCredentialsAndLimits(t *testing.T) {
+	setupProcRoot(t, "")
+	dir := mkdir(t, path.Join(procRoot, "42"))
+	status := "Name:\tjava\nUid:\t1000\t1001\t1001\t1001\nGid:\t2000\t2001\t2001\t2001\nGroups:\t2001\n"
+	if err := os.WriteFile(path.Join(dir, "status"), []byte(status), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if uid, gid, err := Credentials(42); err != nil || uid != 1001 || gid != 2001 {
+		t.Errorf("expected the effective ids 1001 and 2001, got %d, %d, %v", uid, gid, err)
+	}
+	limits := "Limit                     Soft Limit           Hard Limit           Units\n" +
+		"Max processes             63459                63459                processes\n" +
+		"Max open files            1024                 1048576              files\n"
+	if err := os.WriteFile(path.Join(dir, "limits"), []byte(limits), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if soft, hard, err := NofileLimit(42); err != nil || soft != 1024 || hard != 1048576 {
+		t.Errorf("expected 1024 and 1048576, got %d, %d, %v", soft, hard, err)
+	}
+	for _, fd := range []string{"0", "1", "2"} {
+		if err := os.Symlink("/dev/null", path.Join(mkdir(t, path.Join(dir, "fd")), fd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count, err := OpenFiles(42); err != nil || count != 3 {
+		t.Errorf("expected 3 files, got %d, %v", count, err)
+	}
+	if _, _, err := Credentials(43); err == nil {
+		t.Error("expected error of the missing process")
+	}
+}
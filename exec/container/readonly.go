@@ -0,0 +1,114 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	containertype "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// ErrReadOnly is wrapped by the errors of the mutating methods of the read-only client
+var ErrReadOnly = errors.New("the client is in the read-only mode")
+
+// WithReadOnly wraps the client so that the mutating methods, exec, copy, remove and recreate, fail with ErrReadOnly
+// without calling the runtime, for the monitoring and reporting deployments of the agent which must not alter the
+// workloads. The inspecting methods are passed through
+func WithReadOnly(c Container) Container {
+	return &readOnlyContainer{Container: c}
+}
+
+// IsReadOnly returns true if the error is returned by the read-only client
+func IsReadOnly(err error) bool {
+	return errors.Is(err, ErrReadOnly)
+}
+
+type readOnlyContainer struct {
+	Container
+}
+
+func readOnlyError(method, containerId string) error {
+	return fmt.Errorf("%s %s: %w", method, containerId, ErrReadOnly)
+}
+
+func (c *readOnlyContainer) RemoveContainer(ctx context.Context, containerId string, force bool) error {
+	return readOnlyError("RemoveContainer", containerId)
+}
+
+func (c *readOnlyContainer) CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string,
+	override bool) (string, error) {
+	return "", readOnlyError("CopyToContainer", containerId)
+}
+
+func (c *readOnlyContainer) ExecContainer(ctx context.Context, containerId, command string) (string, error) {
+	return "", readOnlyError("ExecContainer", containerId)
+}
+
+func (c *readOnlyContainer) ExecuteAndRemove(ctx context.Context, config *containertype.Config,
+	hostConfig *containertype.HostConfig, networkConfig *network.NetworkingConfig, containerName string, removed bool,
+	timeout time.Duration, command string, containerInfo ContainerInfo) (string, string, error, int32) {
+	return "", "", readOnlyError("ExecuteAndRemove", containerName), spec.Forbidden.Code
+}
+
+// Recreate and RestoreRecreated are denied even if the runtime is not a recreator, AsRecreator stops at the client
+func (c *readOnlyContainer) Recreate(ctx context.Context, containerId string, layer io.Reader, image string) (string, bool, error) {
+	return "", false, readOnlyError("Recreate", containerId)
+}
+
+func (c *readOnlyContainer) RestoreRecreated(ctx context.Context, containerId, originId, image string, running bool) error {
+	return readOnlyError("RestoreRecreated", containerId)
+}
+
+// ListSandboxes is passed through to the runtime and StopSandbox is denied, AsSandboxStopper stops at the client
+func (c *readOnlyContainer) ListSandboxes(ctx context.Context) ([]Sandbox, error) {
+	stopper, ok := AsSandboxStopper(c.Container)
+	if !ok {
+		return nil, errors.New("the runtime has no pod sandboxes")
+	}
+	return stopper.ListSandboxes(ctx)
+}
+
+func (c *readOnlyContainer) StopSandbox(ctx context.Context, sandboxId string) error {
+	return readOnlyError("StopSandbox", sandboxId)
+}
+
+// ImageFilesystems is passed through to the runtime, it is an inspection
+func (c *readOnlyContainer) ImageFilesystems(ctx context.Context) ([]ImageFilesystem, error) {
+	reader, ok := AsImageFsReader(c.Container)
+	if !ok {
+		return nil, errors.New("the runtime reports no image filesystems")
+	}
+	return reader.ImageFilesystems(ctx)
+}
+
+// Attach is passed through to the runtime without the stdin, the output is an inspection and the stdin is denied
+func (c *readOnlyContainer) Attach(ctx context.Context, containerId string, options AttachOptions) error {
+	if options.Stdin != nil {
+		return readOnlyError("Attach", containerId)
+	}
+	attacher, ok := AsAttacher(c.Container)
+	if !ok {
+		return errors.New("the runtime has no attach")
+	}
+	return attacher.Attach(ctx, containerId, options)
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	recorder := &execRecorder{}
+	client := WithReadOnly(recorder)
+	ctx := context.Background()
+	if _, err := client.ExecContainer(ctx, "c1", "true"); !IsReadOnly(err) {
+		t.Errorf("expected the exec denied, got %v", err)
+	}
+	if _, err := client.CopyToContainer(ctx, "c1", "/tmp/a.tar.gz", "/opt", "a", false); !IsReadOnly(err) {
+		t.Errorf("expected the copy denied, got %v", err)
+	}
+	if err := client.RemoveContainer(ctx, "c1", true); !IsReadOnly(err) || err.Error() !=
+		"RemoveContainer c1: the client is in the read-only mode" {
+		t.Errorf("expected the remove denied, got %v", err)
+	}
+	if _, _, err, _ := client.ExecuteAndRemove(ctx, nil, nil, nil, "helper", true, 0, "true", ContainerInfo{}); !IsReadOnly(err) {
+		t.Errorf("expected the helper denied, got %v", err)
+	}
+	recreator, ok := AsRecreator(WithReadOnly(WithBreaker(recorder, &Breaker{})))
+	if !ok {
+		t.Fatal("expected the recreator of the read-only client")
+	}
+	if _, _, err := recreator.Recreate(ctx, "c1", nil, "image"); !IsReadOnly(err) {
+		t.Errorf("expected the recreate denied, got %v", err)
+	}
+	stopper, ok := AsSandboxStopper(WithReadOnly(recorder))
+	if !ok {
+		t.Fatal("expected the sandbox stopper of the read-only client")
+	}
+	if err := stopper.StopSandbox(ctx, "s1"); !IsReadOnly(err) {
+		t.Errorf("expected the sandbox stop denied, got %v", err)
+	}
+	if _, err := stopper.ListSandboxes(ctx); err == nil || IsReadOnly(err) {
+		t.Errorf("expected the runtime without sandboxes reported, got %v", err)
+	}
+	reader, ok := AsImageFsReader(WithReadOnly(recorder))
+	if !ok {
+		t.Fatal("expected the image filesystem reader of the read-only client")
+	}
+	if _, err := reader.ImageFilesystems(ctx); err == nil || IsReadOnly(err) {
+		t.Errorf("expected the runtime without image filesystems reported, got %v", err)
+	}
+	attacher, ok := AsAttacher(WithReadOnly(recorder))
+	if !ok {
+		t.Fatal("expected the attacher of the read-only client")
+	}
+	if err := attacher.Attach(ctx, "c1", AttachOptions{Stdin: strings.NewReader("quit"), Stdout: io.Discard}); !IsReadOnly(err) {
+		t.Errorf("expected the attach of the stdin denied, got %v", err)
+	}
+	if err := attacher.Attach(ctx, "c1", AttachOptions{Stdout: io.Discard}); err == nil || IsReadOnly(err) {
+		t.Errorf("expected the runtime without attach reported, got %v", err)
+	}
+	if len(recorder.commands) != 0 {
+		t.Errorf("expected no command executed, got %q", recorder.commands)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"io"
+)
+
+// OriginSuffix is appended to the name of the origin container kept stopped while the container is recreated
+const OriginSuffix = "-chaosblade-origin"
+
+// Recreator is implemented by the runtimes able to recreate a container from a derived image, for the containers whose
+// filesystem is not writable. The containers managed by kubernetes are never recreated, the kubelet owns them
+type Recreator interface {
+	// Recreate commits the image of the container with the layer, a tar extracted to the root, as the image, then
+	// stops the container, renames it with OriginSuffix and recreates the container from the image with the same config.
+	// It returns the id of the recreated container, and whether the origin container was running, the recreated one is
+	// started anyway
+	Recreate(ctx context.Context, containerId string, layer io.Reader, image string) (string, bool, error)
+	// RestoreRecreated removes the recreated container and the image, and restores the origin container, it's started
+	// only if it was running when recreated
+	RestoreRecreated(ctx context.Context, containerId, originId, image string, running bool) error
+}
+
+// AsRecreator returns the recreator of the client, the wrappers of the client, such as the breaker, are skipped
+func AsRecreator(c Container) (Recreator, bool) {
+	for {
+		if recreator, ok := c.(Recreator); ok {
+			return recreator, true
+		}
+		wrapper, ok := c.(interface{ Unwrap() Container })
+		if !ok {
+			return nil, false
+		}
+		c = wrapper.Unwrap()
+	}
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// selinuxXattr is the extended attribute of the selinux label of the files
+const selinuxXattr = "security.selinux"
+
+// RelabelFiles labels the files under the path in the container of the process recursively, through the root of the
+// process on the host, so the container confined by the MCS categories can read them. The files copied by the host
+// keep the label of the host otherwise, such as the unreadable ones on openshift. It does nothing if the filesystem
+// has no selinux labels
+func RelabelFiles(pid int32, containerPath, label string) error {
+	if label == "" {
+		return nil
+	}
+	root := path.Join(procRoot, strconv.Itoa(int(pid)), "root", containerPath)
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return unix.Lsetxattr(file, selinuxXattr, []byte(label), 0)
+	})
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("relabel %s of process %d to %s failed, %v", containerPath, pid, label, err)
+	}
+	return nil
+}
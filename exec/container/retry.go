@@ -0,0 +1,201 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+// ErrorClass is the class of the failure of a command in the container, the retry policy decides by the class
+type ErrorClass string
+
+const (
+	// ClassNoSpace is the ENOSPC races, such as the archive extracted while the logs are rotated
+	ClassNoSpace ErrorClass = "no-space"
+	// ClassBusy is the device or file busy, such as tc changing the qdisc being replaced
+	ClassBusy ErrorClass = "busy"
+	// ClassTemporary is the EAGAIN and EINTR of the commands
+	ClassTemporary ErrorClass = "temporary"
+	// ClassPermanent is any other failure, it is never retried
+	ClassPermanent ErrorClass = "permanent"
+)
+
+// errorClassMessages are the error fragments of the commands by class
+var errorClassMessages = map[ErrorClass][]string{
+	ClassNoSpace:   {"No space left on device"},
+	ClassBusy:      {"Device or resource busy", "Text file busy"},
+	ClassTemporary: {"Resource temporarily unavailable", "Interrupted system call"},
+}
+
+// ClassifyError returns the class of the failure of the command, nil is permanent too
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ClassPermanent
+	}
+	msg := err.Error()
+	for _, class := range []ErrorClass{ClassNoSpace, ClassBusy, ClassTemporary} {
+		for _, m := range errorClassMessages[class] {
+			if strings.Contains(msg, m) {
+				return class
+			}
+		}
+	}
+	return ClassPermanent
+}
+
+// CommandError returns the error of the failed command carrying the stderr, the exit status alone can not be classified
+func CommandError(err error, stderr string) error {
+	if stderr = strings.TrimSpace(stderr); stderr == "" {
+		return err
+	}
+	return fmt.Errorf("%w, %s", err, stderr)
+}
+
+// RetryPolicy retries the transient failures of the commands executed and the archives copied into the containers,
+// so that the experiments do not reimplement the retry loops in the shell
+type RetryPolicy struct {
+	// Attempts are the max attempts by the class, including the first, the class not set is not retried
+	Attempts map[ErrorClass]int
+	// Delay is the delay before the first retry, doubled on every retry up to MaxDelay
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by the runtime clients if the policy is not configured by RetryPolicyEnv
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts: map[ErrorClass]int{
+		ClassNoSpace:   3,
+		ClassBusy:      5,
+		ClassTemporary: 3,
+	},
+	Delay:    200 * time.Millisecond,
+	MaxDelay: 2 * time.Second,
+}
+
+// retryPolicyFile is the json file of the policy, such as {"attempts": {"busy": 5}, "delay": "200ms", "maxDelay": "2s"}
+type retryPolicyFile struct {
+	Attempts map[ErrorClass]int `json:"attempts"`
+	Delay    string             `json:"delay"`
+	MaxDelay string             `json:"maxDelay"`
+}
+
+// LoadRetryPolicy reads the policy from the json file, the delays not set are the ones of DefaultRetryPolicy
+func LoadRetryPolicy(file string) (RetryPolicy, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return RetryPolicy{}, err
+	}
+	var config retryPolicyFile
+	if err := json.Unmarshal(content, &config); err != nil {
+		return RetryPolicy{}, fmt.Errorf("parse the retry policy %s failed, %v", file, err)
+	}
+	policy := RetryPolicy{Attempts: config.Attempts, Delay: DefaultRetryPolicy.Delay, MaxDelay: DefaultRetryPolicy.MaxDelay}
+	for class, attempts := range policy.Attempts {
+		if _, ok := errorClassMessages[class]; !ok {
+			return RetryPolicy{}, fmt.Errorf("illegal error class %s of the retry policy", class)
+		}
+		if attempts < 1 {
+			return RetryPolicy{}, fmt.Errorf("illegal attempts %d of %s of the retry policy, must be positive", attempts, class)
+		}
+	}
+	if config.Delay != "" {
+		if policy.Delay, err = durations.Parse(config.Delay); err != nil {
+			return RetryPolicy{}, fmt.Errorf("illegal delay of the retry policy, %v", err)
+		}
+	}
+	if config.MaxDelay != "" {
+		if policy.MaxDelay, err = durations.Parse(config.MaxDelay); err != nil {
+			return RetryPolicy{}, fmt.Errorf("illegal max delay of the retry policy, %v", err)
+		}
+	}
+	return policy, nil
+}
+
+// retrySleep waits between the attempts, replaced in the tests
+var retrySleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Do runs fn until it succeeds, fails permanently or the attempts of the class are used up, the last error is
+// returned. Every retry is reported as a warning of the source
+func (p RetryPolicy) Do(ctx context.Context, source string, fn func() error) error {
+	delay := p.Delay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		class := ClassifyError(err)
+		if attempt >= p.Attempts[class] {
+			return err
+		}
+		warning.Add(ctx, source, "attempt %d failed by the %s error, retry after %s, %v", attempt, class, delay, err)
+		if sleepErr := retrySleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+		if delay *= 2; p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
+
+// WithRetry wraps the client so that the commands executed and the archives copied into the containers are retried
+// by the policy
+func WithRetry(c Container, policy RetryPolicy) Container {
+	return &retryContainer{Container: c, policy: policy}
+}
+
+type retryContainer struct {
+	Container
+	policy RetryPolicy
+}
+
+func (c *retryContainer) Unwrap() Container {
+	return c.Container
+}
+
+func (c *retryContainer) CopyToContainer(ctx context.Context, containerId, srcFile, dstPath, extractDirName string,
+	override bool) (extractPath string, err error) {
+	err = c.policy.Do(ctx, "CopyToContainer", func() error {
+		extractPath, err = c.Container.CopyToContainer(ctx, containerId, srcFile, dstPath, extractDirName, override)
+		return err
+	})
+	return extractPath, err
+}
+
+func (c *retryContainer) ExecContainer(ctx context.Context, containerId, command string) (output string, err error) {
+	err = c.policy.Do(ctx, "ExecContainer", func() error {
+		output, err = c.Container.ExecContainer(ctx, containerId, command)
+		return err
+	})
+	return output, err
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+type flakyContainer struct {
+	Container
+	errs  []error
+	calls int
+}
+
+func (c *flakyContainer) ExecContainer(ctx context.Context, containerId, command string) (string, error) {
+	c.calls++
+	if c.calls <= len(c.errs) {
+		return "", c.errs[c.calls-1]
+	}
+	return "ok", nil
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var delays []time.Duration
+	sleep := retrySleep
+	defer func() {
+		retrySleep = sleep
+	}()
+	retrySleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	busy := CommandError(errors.New("exit status 2"), "RTNETLINK answers: Device or resource busy\n")
+	noSpace := errors.New("tar: write error: No space left on device")
+	tests := []struct {
+		name   string
+		errs   []error
+		output string
+		calls  int
+	}{
+		{"busy recovered", []error{busy, busy}, "ok", 3},
+		{"no space exhausted", []error{noSpace, noSpace, noSpace, noSpace}, "", 3},
+		{"permanent", []error{errors.New("exit status 1")}, "", 1},
+	}
+	for _, tt := range tests {
+		delays = nil
+		flaky := &flakyContainer{errs: tt.errs}
+		ctx, collector := warning.WithCollector(context.Background())
+		output, _ := WithRetry(flaky, DefaultRetryPolicy).ExecContainer(ctx, "c1", "tc qdisc replace")
+		if output != tt.output || flaky.calls != tt.calls {
+			t.Errorf("%s: expected %q after %d calls, got %q after %d", tt.name, tt.output, tt.calls, output, flaky.calls)
+		}
+		if len(collector.List()) != tt.calls-1 {
+			t.Errorf("%s: expected a warning per retry, got %v", tt.name, collector.List())
+		}
+	}
+	delays = nil
+	policy := RetryPolicy{Attempts: map[ErrorClass]int{ClassBusy: 4}, Delay: time.Second, MaxDelay: 3 * time.Second}
+	_, _ = WithRetry(&flakyContainer{errs: []error{busy, busy, busy}}, policy).ExecContainer(context.Background(), "c1", "")
+	if len(delays) != 3 || delays[0] != time.Second || delays[1] != 2*time.Second || delays[2] != 3*time.Second {
+		t.Errorf("expected the delays doubled up to the max, got %v", delays)
+	}
+}
+
+func TestLoadRetryPolicy(t *testing.T) {
+	file := path.Join(t.TempDir(), "retry.json")
+	if err := os.WriteFile(file, []byte(`{"attempts":{"busy":2},"delay":"1s"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadRetryPolicy(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Attempts[ClassBusy] != 2 || policy.Attempts[ClassNoSpace] != 0 || policy.Delay != time.Second ||
+		policy.MaxDelay != DefaultRetryPolicy.MaxDelay {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+	if err := os.WriteFile(file, []byte(`{"attempts":{"unknown":2}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRetryPolicy(file); err == nil {
+		t.Error("expected the unknown class rejected")
+	}
+}
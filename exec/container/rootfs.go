@@ -0,0 +1,198 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// The storage drivers of the container rootfs
+const (
+	StorageDriverOverlay   = "overlay"
+	StorageDriverZFS       = "zfs"
+	StorageDriverDevmapper = "devmapper"
+)
+
+// procRoot is the proc filesystem of the host, the mounts of the host are read from the pid 1
+var procRoot = "/proc"
+
+// RootfsHint is the rootfs reported by the runtime, the clients fill it from the container metadata
+type RootfsHint struct {
+	// Driver is the storage driver or the snapshotter, e.g. overlay2, overlayfs, zfs, devicemapper
+	Driver string
+	// Path is the rootfs path reported by the runtime, e.g. the root path of the oci spec
+	Path string
+	// Data is the driver specific data, e.g. MergedDir of overlay, Dataset of zfs, DeviceName of devmapper
+	Data map[string]string
+	// Pid is the container process, /proc/<pid>/root is used if the rootfs is not found on the host
+	Pid int32
+}
+
+// NormalizeStorageDriver returns the storage driver of the docker graph drivers and the containerd snapshotters
+func NormalizeStorageDriver(driver string) string {
+	switch strings.ToLower(driver) {
+	case "overlay", "overlay2", "overlayfs", "fuse-overlayfs":
+		return StorageDriverOverlay
+	case "zfs":
+		return StorageDriverZFS
+	case "devmapper", "devicemapper":
+		return StorageDriverDevmapper
+	default:
+		return strings.ToLower(driver)
+	}
+}
+
+// ResolveRootfsPath returns the host path of the container rootfs, the path is checked to exist so the
+// experiments can operate on it from the host without exec
+func ResolveRootfsPath(hint RootfsHint) (string, error) {
+	candidates := make([]string, 0)
+	switch NormalizeStorageDriver(hint.Driver) {
+	case StorageDriverOverlay:
+		candidates = append(candidates, hint.Data["MergedDir"], hint.Path)
+	case StorageDriverZFS:
+		candidates = append(candidates, hint.Data["Mountpoint"], hint.Path)
+		if dataset := hint.Data["Dataset"]; dataset != "" {
+			if mountPoint, ok := findHostMount(func(fsType, source string) bool {
+				return fsType == "zfs" && source == dataset
+			}); ok {
+				candidates = append(candidates, mountPoint)
+			}
+		}
+	case StorageDriverDevmapper:
+		candidates = append(candidates, hint.Path)
+		if device := hint.Data["DeviceName"]; device != "" {
+			if mountPoint, ok := findHostMount(func(fsType, source string) bool {
+				return source == path.Join("/dev/mapper", device)
+			}); ok {
+				// docker mounts the device with the rootfs directory inside
+				candidates = append(candidates, path.Join(mountPoint, "rootfs"), mountPoint)
+			}
+		}
+	default:
+		candidates = append(candidates, hint.Path)
+	}
+	for _, candidate := range candidates {
+		if candidate != "" && path.IsAbs(candidate) && isDir(candidate) {
+			return candidate, nil
+		}
+	}
+	if hint.Pid > 0 {
+		root := path.Join(procRoot, strconv.Itoa(int(hint.Pid)), "root")
+		if isDir(root) {
+			return root, nil
+		}
+	}
+	return "", fmt.Errorf("rootfs of the %s storage driver not found on the host, reported path: %s",
+		hint.Driver, hint.Path)
+}
+
+func isDir(file string) bool {
+	stat, err := os.Stat(file)
+	return err == nil && stat.IsDir()
+}
+
+// hostMount is a mount of the host mount namespace
+type hostMount struct {
+	MountPoint string
+	FsType     string
+	Source     string
+}
+
+// HostMountInfo returns the mountinfo file of the host mount namespace, it is replaced by the tests of the experiments
+var HostMountInfo = func() string {
+	return path.Join(procRoot, "1", "mountinfo")
+}
+
+// readHostMounts returns the mounts of the host mount namespace
+func readHostMounts() []hostMount {
+	file, err := os.Open(HostMountInfo())
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	mounts := make([]hostMount, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(scanner.Text())
+		separator := -1
+		for i, field := range fields {
+			if field == "-" {
+				separator = i
+				break
+			}
+		}
+		if separator < 5 || separator+2 >= len(fields) {
+			continue
+		}
+		mounts = append(mounts, hostMount{
+			MountPoint: unescapeMountPath(fields[4]),
+			FsType:     fields[separator+1],
+			Source:     fields[separator+2],
+		})
+	}
+	return mounts
+}
+
+// findHostMount returns the mount point of the first mount matched in the host mount namespace
+func findHostMount(match func(fsType, source string) bool) (string, bool) {
+	for _, mount := range readHostMounts() {
+		if match(mount.FsType, mount.Source) {
+			return mount.MountPoint, true
+		}
+	}
+	return "", false
+}
+
+// hostMountOf returns the host mount containing the path, the longest mount point wins
+func hostMountOf(file string) (hostMount, bool) {
+	var found hostMount
+	ok := false
+	for _, mount := range readHostMounts() {
+		if file != mount.MountPoint && !strings.HasPrefix(file, strings.TrimSuffix(mount.MountPoint, "/")+"/") {
+			continue
+		}
+		if !ok || len(mount.MountPoint) >= len(found.MountPoint) {
+			found, ok = mount, true
+		}
+	}
+	return found, ok
+}
+
+// unescapeMountPath decodes the octal escapes of the mountinfo, e.g. \040 is the space
+func unescapeMountPath(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) {
+			if c, err := strconv.ParseUint(value[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
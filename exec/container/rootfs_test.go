@@ -0,0 +1,99 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+)
+
+func setupProcRoot(t *testing.T, mountinfo string) string {
+	t.Helper()
+	dir := t.TempDir()
+	procRoot = path.Join(dir, "proc")
+	t.Cleanup(func() { procRoot = "/proc" })
+	if err := os.MkdirAll(path.Join(procRoot, "1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(procRoot, "1", "mountinfo"), []byte(mountinfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func mkdir(t *testing.T, dir string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestResolveRootfsPath(t *testing.T) {
+	dir := setupProcRoot(t, "")
+	merged := mkdir(t, path.Join(dir, "overlay2", "abc", "merged"))
+	zfsMount := mkdir(t, path.Join(dir, "zfs", "graph", "abc"))
+	devmapperMount := mkdir(t, path.Join(dir, "devicemapper", "mnt", "abc"))
+	mkdir(t, path.Join(devmapperMount, "rootfs"))
+	procPidRoot := mkdir(t, path.Join(procRoot, "42", "root"))
+	mountinfo := fmt.Sprintf("1 0 8:1 / / rw - ext4 /dev/sda1 rw\n"+
+		"2 1 0:50 / %s rw - zfs tank/docker/abc rw\n"+
+		"3 1 253:3 / %s rw - xfs /dev/mapper/docker-253:0-1-abc rw\n", zfsMount, devmapperMount)
+	if err := os.WriteFile(path.Join(procRoot, "1", "mountinfo"), []byte(mountinfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hint     RootfsHint
+		expected string
+	}{
+		{"overlay2 merged dir", RootfsHint{Driver: "overlay2", Data: map[string]string{"MergedDir": merged}}, merged},
+		{"overlayfs spec root", RootfsHint{Driver: "overlayfs", Path: merged}, merged},
+		{"zfs dataset", RootfsHint{Driver: "zfs", Data: map[string]string{"Dataset": "tank/docker/abc"}}, zfsMount},
+		{"devicemapper device", RootfsHint{Driver: "devicemapper", Data: map[string]string{"DeviceName": "docker-253:0-1-abc"}},
+			path.Join(devmapperMount, "rootfs")},
+		{"missing path falls back to pid", RootfsHint{Driver: "overlay2", Path: "/not/exist", Pid: 42}, procPidRoot},
+		{"relative path is ignored", RootfsHint{Path: "rootfs", Pid: 42}, procPidRoot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootfs, err := ResolveRootfsPath(tt.hint)
+			if err != nil {
+				t.Fatalf("ResolveRootfsPath failed, %v", err)
+			}
+			if rootfs != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, rootfs)
+			}
+		})
+	}
+
+	if _, err := ResolveRootfsPath(RootfsHint{Driver: "zfs", Data: map[string]string{"Dataset": "tank/missing"}}); err == nil {
+		t.Error("expected error for the unresolved rootfs")
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	if value := unescapeMountPath(`/var/lib/my\040dir`); value != "/var/lib/my dir" {
+		t.Errorf("unexpected unescaped path %q", value)
+	}
+	if value := unescapeMountPath(`/var/lib\`); value != `/var/lib\` {
+		t.Errorf("unexpected unescaped path %q", value)
+	}
+}
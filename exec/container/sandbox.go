@@ -0,0 +1,58 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"time"
+)
+
+// Sandbox is a pod sandbox of the cri runtimes
+type Sandbox struct {
+	Id string `json:"id"`
+	// Name, Namespace and Uid are the metadata of the pod
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Uid       string            `json:"uid,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Ready     bool              `json:"ready"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// SandboxStopper is implemented by the cri runtimes, the pod sandboxes stopped with their containers are recreated by
+// the kubelet as after a node failure
+type SandboxStopper interface {
+	// ListSandboxes returns the pod sandboxes of the node
+	ListSandboxes(ctx context.Context) ([]Sandbox, error)
+	// StopSandbox stops the pod sandbox and all its containers, the network of the pod is torn down
+	StopSandbox(ctx context.Context, sandboxId string) error
+}
+
+// AsSandboxStopper returns the sandbox stopper of the client, the wrappers of the client, such as the breaker, are
+// skipped
+func AsSandboxStopper(c Container) (SandboxStopper, bool) {
+	for {
+		if stopper, ok := c.(SandboxStopper); ok {
+			return stopper, true
+		}
+		wrapper, ok := c.(interface{ Unwrap() Container })
+		if !ok {
+			return nil, false
+		}
+		c = wrapper.Unwrap()
+	}
+}
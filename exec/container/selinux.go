@@ -0,0 +1,82 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// OpenShiftSCCAnnotation is the pod annotation of the security context constraint admitting the pod on openshift
+const OpenShiftSCCAnnotation = "openshift.io/scc"
+
+// containerFileType is the selinux type of the files of the containers
+const containerFileType = "container_file_t"
+
+// SELinuxLabel is the selinux context, such as system_u:system_r:container_t:s0:c123,c456, the level carries the MCS
+// categories isolating the containers from each other
+type SELinuxLabel struct {
+	User  string
+	Role  string
+	Type  string
+	Level string
+}
+
+// ParseSELinuxLabel parses the context, the level may contain the colons
+func ParseSELinuxLabel(label string) (SELinuxLabel, error) {
+	fields := strings.SplitN(strings.TrimSpace(label), ":", 4)
+	if len(fields) != 4 || fields[0] == "" || fields[1] == "" || fields[2] == "" || fields[3] == "" {
+		return SELinuxLabel{}, fmt.Errorf("illegal selinux label %q", label)
+	}
+	return SELinuxLabel{User: fields[0], Role: fields[1], Type: fields[2], Level: fields[3]}, nil
+}
+
+func (l SELinuxLabel) String() string {
+	return strings.Join([]string{l.User, l.Role, l.Type, l.Level}, ":")
+}
+
+// FileLabel returns the label of the files written into the container, the mount label of the container if reported,
+// otherwise the container file type of the MCS level of the process label. It is empty if the container is not
+// confined by selinux, the files need no label then
+func FileLabel(processLabel, mountLabel string) string {
+	if mountLabel != "" {
+		return mountLabel
+	}
+	label, err := ParseSELinuxLabel(processLabel)
+	if err != nil {
+		return ""
+	}
+	return SELinuxLabel{User: label.User, Role: "object_r", Type: containerFileType, Level: label.Level}.String()
+}
+
+// ProcessLabel returns the selinux label of the process read from the proc filesystem, empty if selinux is disabled
+// or the process is unconfined
+func ProcessLabel(pid int32) string {
+	content, err := os.ReadFile(path.Join(procRoot, strconv.Itoa(int(pid)), "attr", "current"))
+	if err != nil {
+		return ""
+	}
+	label := strings.TrimRight(string(content), "\x00\n")
+	if _, err := ParseSELinuxLabel(label); err != nil {
+		// such as unconfined or kernel of the hosts without selinux policies
+		return ""
+	}
+	return label
+}
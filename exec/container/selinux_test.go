@@ -0,0 +1,72 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParseSELinuxLabel(t *testing.T) {
+	label, err := ParseSELinuxLabel("system_u:system_r:container_t:s0:c123,c456")
+	if err != nil || label.Type != "container_t" || label.Level != "s0:c123,c456" {
+		t.Errorf("unexpected label %+v, %v", label, err)
+	}
+	for _, illegal := range []string{"", "unconfined", "system_u:system_r:container_t", "system_u::container_t:s0"} {
+		if _, err := ParseSELinuxLabel(illegal); err == nil {
+			t.Errorf("expected %q illegal", illegal)
+		}
+	}
+}
+
+func TestFileLabel(t *testing.T) {
+	tests := []struct {
+		process, mount, expected string
+	}{
+		{"system_u:system_r:container_t:s0:c1,c2", "system_u:object_r:container_file_t:s0:c3,c4",
+			"system_u:object_r:container_file_t:s0:c3,c4"},
+		{"system_u:system_r:container_t:s0:c1,c2", "", "system_u:object_r:container_file_t:s0:c1,c2"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if label := FileLabel(tt.process, tt.mount); label != tt.expected {
+			t.Errorf("expected %q of %q and %q, got %q", tt.expected, tt.process, tt.mount, label)
+		}
+	}
+}
+
+func TestProcessLabel(t *testing.T) {
+	procRoot = t.TempDir()
+	t.Cleanup(func() { procRoot = "/proc" })
+	for pid, label := range map[string]string{"10": "system_u:system_r:container_t:s0:c5,c9\x00", "20": "unconfined\n"} {
+		os.MkdirAll(path.Join(procRoot, pid, "attr"), 0755)
+		os.WriteFile(path.Join(procRoot, pid, "attr", "current"), []byte(label), 0644)
+	}
+	if label := ProcessLabel(10); label != "system_u:system_r:container_t:s0:c5,c9" {
+		t.Errorf("unexpected label %q", label)
+	}
+	if label := ProcessLabel(20); label != "" {
+		t.Errorf("expected the unconfined process without label, got %q", label)
+	}
+	if label := ProcessLabel(30); label != "" {
+		t.Errorf("expected the process gone without label, got %q", label)
+	}
+	if err := RelabelFiles(10, "/opt", ""); err != nil {
+		t.Errorf("expected nothing relabeled without the label, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+)
+
+// DefaultStopGrace is the grace period of the stop step without one
+const DefaultStopGrace = 10 * time.Second
+
+// stopSignals are the signals of the stop steps, by the names with or without the SIG prefix
+var stopSignals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+// StopStep sends the signal to the init process of the container and waits for the grace period for it to exit
+type StopStep struct {
+	Name   string
+	Signal syscall.Signal
+	Grace  time.Duration
+}
+
+// StopLadder is the sequence of the stop steps, the next step is taken if the container survives the grace period
+type StopLadder []StopStep
+
+// ParseStopLadder parses the steps separated by commas, a step is a signal and the optional grace period after a
+// colon, such as SIGTERM:10s,SIGKILL, the grace period of the step without one is DefaultStopGrace
+func ParseStopLadder(value string) (StopLadder, error) {
+	ladder := make(StopLadder, 0)
+	for _, step := range strings.Split(value, ",") {
+		step = strings.TrimSpace(step)
+		if step == "" {
+			continue
+		}
+		name, grace, hasGrace := strings.Cut(step, ":")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if !strings.HasPrefix(name, "SIG") {
+			name = "SIG" + name
+		}
+		signal, ok := stopSignals[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported signal %s", name)
+		}
+		s := StopStep{Name: name, Signal: signal, Grace: DefaultStopGrace}
+		if hasGrace {
+			d, err := durations.Parse(grace)
+			if err != nil {
+				return nil, fmt.Errorf("illegal grace period of %s, %v", name, err)
+			}
+			s.Grace = d
+		}
+		ladder = append(ladder, s)
+	}
+	if len(ladder) == 0 {
+		return nil, fmt.Errorf("no stop step in %q", value)
+	}
+	return ladder, nil
+}
+
+// String returns the ladder in the format of ParseStopLadder
+func (l StopLadder) String() string {
+	steps := make([]string, 0, len(l))
+	for _, step := range l {
+		steps = append(steps, fmt.Sprintf("%s:%s", step.Name, step.Grace))
+	}
+	return strings.Join(steps, ",")
+}
+
+// StopResult is how the container was stopped by the ladder
+type StopResult struct {
+	// Signal is the signal of the step the container exited in, empty if it survived the whole ladder or exited
+	// before the first signal
+	Signal string `json:"signal,omitempty"`
+	// Elapsed is the time from the first signal to the exit, or to the end of the ladder
+	Elapsed string `json:"elapsed"`
+	Stopped bool   `json:"stopped"`
+}
+
+// signalProcess and processExited are replaced by the tests
+var (
+	signalProcess = func(pid int32, signal syscall.Signal) error {
+		return syscall.Kill(int(pid), signal)
+	}
+	// processExited returns true if the process is gone or a zombie
+	processExited = func(pid int32) bool {
+		zombie, err := IsZombie(pid)
+		return err != nil || zombie
+	}
+)
+
+// stopPollInterval is how often the process is checked during the grace period
+const stopPollInterval = 100 * time.Millisecond
+
+// StopByLadder takes the steps of the ladder on the init process of the container, the host pid, until it exits. The
+// runtime is not involved, so the signals reach the application as the kubelet or an orchestrator would send them
+func StopByLadder(ctx context.Context, pid int32, ladder StopLadder) (StopResult, error) {
+	start := time.Now()
+	var sent string
+	for _, step := range ladder {
+		if processExited(pid) {
+			break
+		}
+		log.Infof(ctx, "send %s to process %d, grace period %s", step.Name, pid, step.Grace)
+		if err := signalProcess(pid, step.Signal); err != nil {
+			if err == syscall.ESRCH {
+				break
+			}
+			return StopResult{}, fmt.Errorf("send %s to process %d failed, %v", step.Name, pid, err)
+		}
+		sent = step.Name
+		deadline := time.Now().Add(step.Grace)
+		for !processExited(pid) && time.Now().Before(deadline) {
+			select {
+			case <-ctx.Done():
+				return StopResult{}, ctx.Err()
+			case <-time.After(stopPollInterval):
+			}
+		}
+	}
+	result := StopResult{Elapsed: time.Since(start).String(), Stopped: processExited(pid)}
+	if result.Stopped {
+		// the process exited by the last signal sent, or by itself before the first one
+		result.Signal = sent
+	}
+	return result, nil
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseStopLadder(t *testing.T) {
+	ladder, err := ParseStopLadder("SIGTERM:500ms, quit:2 ,SIGKILL")
+	expected := StopLadder{
+		{Name: "SIGTERM", Signal: syscall.SIGTERM, Grace: 500 * time.Millisecond},
+		{Name: "SIGQUIT", Signal: syscall.SIGQUIT, Grace: 2 * time.Second},
+		{Name: "SIGKILL", Signal: syscall.SIGKILL, Grace: DefaultStopGrace},
+	}
+	if err != nil || !reflect.DeepEqual(ladder, expected) {
+		t.Errorf("expected %v, got %v, %v", expected, ladder, err)
+	}
+	for _, illegal := range []string{"", ",", "SIGSTOP", "SIGTERM:soon"} {
+		if _, err := ParseStopLadder(illegal); err == nil {
+			t.Errorf("expected %q refused", illegal)
+		}
+	}
+}
+
+func TestStopByLadder(t *testing.T) {
+	signal, exited := signalProcess, processExited
+	defer func() {
+		signalProcess, processExited = signal, exited
+	}()
+	signals := make([]syscall.Signal, 0)
+	exitOn := syscall.SIGKILL
+	signalProcess = func(pid int32, signal syscall.Signal) error {
+		signals = append(signals, signal)
+		return nil
+	}
+	processExited = func(pid int32) bool {
+		return len(signals) > 0 && signals[len(signals)-1] == exitOn
+	}
+	ladder, _ := ParseStopLadder("SIGTERM:50ms,SIGINT:50ms,SIGKILL:50ms")
+	result, err := StopByLadder(context.Background(), 1, ladder)
+	if err != nil || !result.Stopped || result.Signal != "SIGKILL" || len(signals) != 3 {
+		t.Errorf("expected the container killed at last, got %+v, %v, signals %v", result, err, signals)
+	}
+
+	signals, exitOn = signals[:0], syscall.SIGTERM
+	result, err = StopByLadder(context.Background(), 1, ladder)
+	if err != nil || !result.Stopped || result.Signal != "SIGTERM" || len(signals) != 1 {
+		t.Errorf("expected the container exited on SIGTERM, got %+v, %v, signals %v", result, err, signals)
+	}
+
+	signals, exitOn = signals[:0], syscall.SIGUSR1
+	result, err = StopByLadder(context.Background(), 1, ladder[:2])
+	if err != nil || result.Stopped || result.Signal != "" || len(signals) != 2 {
+		t.Errorf("expected the container survived, got %+v, %v, signals %v", result, err, signals)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"context"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/transcript"
+)
+
+// WithTranscript wraps the client so that the commands executed in the containers are recorded to the transcript of
+// the context, the commands denied by the exec policy are recorded too if it is the outermost wrapper
+func WithTranscript(c Container) Container {
+	return &transcriptContainer{Container: c}
+}
+
+type transcriptContainer struct {
+	Container
+}
+
+func (c *transcriptContainer) Unwrap() Container {
+	return c.Container
+}
+
+func (c *transcriptContainer) ExecContainer(ctx context.Context, containerId, command string) (string, error) {
+	start := time.Now()
+	output, err := c.Container.ExecContainer(ctx, containerId, command)
+	transcript.Record(ctx, containerId, command, start, output, err)
+	return output, err
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package transporttest is the conformance cases of the transports executing the commands and copying the archives
+// into the containers, the transports of the runtimes run the same cases as the reference transport on the host
+package transporttest
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path"
+	"testing"
+)
+
+// ExecCase is a shell command and what the Exec of the container package returns for it
+type ExecCase struct {
+	Command  string
+	Output   string
+	ExitCode int
+	// Warned is whether the stderr of the succeeded command is reported as a warning
+	Warned bool
+}
+
+// ExecCases are run by every transport, the output is the stdout, or the stderr if the stdout is empty
+var ExecCases = []ExecCase{
+	{Command: "echo ok", Output: "ok\n"},
+	{Command: "printf '%s' \"it's \\$HOME\"", Output: "it's $HOME"},
+	{Command: "echo out; echo err >&2", Output: "err\n", Warned: true},
+	{Command: "echo failed >&2; exit 3", ExitCode: 3},
+	{Command: "cat", Output: ""},
+}
+
+// WriteArchive writes the tar.gz of the dir with the file, it is the archive copied by the Copy of the transports
+func WriteArchive(t *testing.T, dir, file, content string) string {
+	t.Helper()
+	archive := path.Join(t.TempDir(), "chaosblade-1.7.4.tar.gz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: dir + "/" + file, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})
+	tw.Write([]byte(content))
+	tw.Close()
+	gz.Close()
+	return archive
+}
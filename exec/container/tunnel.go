@@ -0,0 +1,287 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	DefaultSSHPort       = "22"
+	sshConnectionTimeout = 5 * time.Second
+	// sshTunnelDirPrefix is the prefix of the temp dirs of the local sockets
+	sshTunnelDirPrefix = "chaosblade-ssh-"
+	// sshStaleTunnelAge is the age of the tunnel dir without the listening socket to be removed, it is left by the
+	// blade command killed before closing its tunnels
+	sshStaleTunnelAge = time.Minute
+)
+
+// sshKeepaliveInterval is the interval of the keepalive requests, the tunnel not answering in sshConnectionTimeout
+// is evicted, so the next experiment opens a new one instead of dialing a dead connection
+var sshKeepaliveInterval = 15 * time.Second
+
+// The default runtime sockets on the remote node
+var DefaultRuntimeSockets = map[string]string{
+	DockerRuntime:     "/var/run/docker.sock",
+	ContainerdRuntime: "/run/containerd/containerd.sock",
+	CRIORuntime:       "/var/run/crio/crio.sock",
+}
+
+// SSHTunnelConfig is the ssh local forwarding to a remote runtime socket
+type SSHTunnelConfig struct {
+	// Address is user@host[:port]
+	Address string
+	// KeyFile is the private key, default is ~/.ssh/id_rsa
+	KeyFile string
+	// KnownHostsFile verifies the remote host key, default is ~/.ssh/known_hosts
+	KnownHostsFile string
+	// RemoteSocket is the runtime socket path on the remote node
+	RemoteSocket string
+}
+
+type sshTunnel struct {
+	key      string
+	client   *ssh.Client
+	listener net.Listener
+	socket   string
+	dir      string
+	done     chan struct{}
+	once     sync.Once
+}
+
+var (
+	tunnelsMu sync.Mutex
+	tunnels   = make(map[string]*sshTunnel)
+)
+
+// OpenSSHTunnel forwards a local unix socket to the remote runtime socket and returns the local socket path.
+// The tunnel is shared by the same address and remote socket until CloseSSHTunnels, or until its keepalive fails.
+func OpenSSHTunnel(config SSHTunnelConfig) (string, error) {
+	key := fmt.Sprintf("%s%s", config.Address, config.RemoteSocket)
+	tunnelsMu.Lock()
+	defer tunnelsMu.Unlock()
+	if t, ok := tunnels[key]; ok {
+		return t.socket, nil
+	}
+
+	user, host, err := parseSSHAddress(config.Address)
+	if err != nil {
+		return "", err
+	}
+	keyFile := config.KeyFile
+	if keyFile == "" {
+		keyFile = path.Join(util.GetUserHome(), ".ssh", "id_rsa")
+	}
+	knownHostsFile := config.KnownHostsFile
+	if knownHostsFile == "" {
+		knownHostsFile = path.Join(util.GetUserHome(), ".ssh", "known_hosts")
+	}
+	keyBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", fmt.Errorf("read ssh key %s failed, %v", keyFile, err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+	if err != nil {
+		return "", fmt.Errorf("parse ssh key %s failed, %v", keyFile, err)
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return "", fmt.Errorf("load ssh known hosts %s failed, %v", knownHostsFile, err)
+	}
+	client, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshConnectionTimeout,
+	})
+	if err != nil {
+		return "", fmt.Errorf("ssh to %s failed, %v", config.Address, err)
+	}
+
+	removeStaleTunnels()
+	dir, err := os.MkdirTemp("", sshTunnelDirPrefix)
+	if err != nil {
+		client.Close()
+		return "", err
+	}
+	socket := path.Join(dir, "runtime.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		client.Close()
+		os.RemoveAll(dir)
+		return "", err
+	}
+	t := &sshTunnel{
+		key:      key,
+		client:   client,
+		listener: listener,
+		socket:   socket,
+		dir:      dir,
+		done:     make(chan struct{}),
+	}
+	tunnels[key] = t
+	go t.serve(config.RemoteSocket)
+	go t.keepalive(sshKeepaliveInterval)
+	log.Infof(context.Background(), "ssh tunnel %s -> %s:%s is opened", socket, config.Address, config.RemoteSocket)
+	return socket, nil
+}
+
+// CloseSSHTunnels closes all the opened tunnels
+func CloseSSHTunnels() error {
+	tunnelsMu.Lock()
+	defer tunnelsMu.Unlock()
+	var errs []string
+	for key, t := range tunnels {
+		if err := t.close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		delete(tunnels, key)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("close ssh tunnels failed, %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// removeStaleTunnels removes the tunnel dirs left by the other processes, whose sockets are no longer listening
+func removeStaleTunnels() {
+	dirs, err := filepath.Glob(path.Join(os.TempDir(), sshTunnelDirPrefix+"*"))
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() || time.Since(info.ModTime()) < sshStaleTunnelAge {
+			continue
+		}
+		if conn, err := net.DialTimeout("unix", path.Join(dir, "runtime.sock"), time.Second); err == nil {
+			conn.Close()
+			continue
+		}
+		if err := os.RemoveAll(dir); err == nil {
+			log.Infof(context.Background(), "the stale ssh tunnel dir %s is removed", dir)
+		}
+	}
+}
+
+// close stops the tunnel and removes its socket, it is called once by the eviction or CloseSSHTunnels
+func (t *sshTunnel) close() error {
+	var err error
+	t.once.Do(func() {
+		close(t.done)
+		t.listener.Close()
+		err = t.client.Close()
+		os.RemoveAll(t.dir)
+	})
+	return err
+}
+
+// keepalive sends the keepalive requests until the tunnel is closed, the tunnel not answering is evicted
+func (t *sshTunnel) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		}
+		replied := make(chan error, 1)
+		go func() {
+			_, _, err := t.client.SendRequest("keepalive@openssh.com", true, nil)
+			replied <- err
+		}()
+		var err error
+		select {
+		case <-t.done:
+			return
+		case err = <-replied:
+		case <-time.After(sshConnectionTimeout):
+			err = fmt.Errorf("no reply in %s", sshConnectionTimeout)
+		}
+		if err != nil {
+			log.Warnf(context.Background(), "keepalive of ssh tunnel %s failed, evict it, %v", t.socket, err)
+			t.evict()
+			return
+		}
+	}
+}
+
+// evict removes the tunnel from the cache if it is still cached and closes it
+func (t *sshTunnel) evict() {
+	tunnelsMu.Lock()
+	if tunnels[t.key] == t {
+		delete(tunnels, t.key)
+	}
+	tunnelsMu.Unlock()
+	t.close()
+}
+
+func (t *sshTunnel) serve(remoteSocket string) {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go t.forward(local, remoteSocket)
+	}
+}
+
+func (t *sshTunnel) forward(local net.Conn, remoteSocket string) {
+	defer local.Close()
+	remote, err := t.client.Dial("unix", remoteSocket)
+	if err != nil {
+		log.Warnf(context.Background(), "dial remote socket %s failed, %v", remoteSocket, err)
+		return
+	}
+	defer remote.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+func parseSSHAddress(address string) (user, host string, err error) {
+	idx := strings.LastIndex(address, "@")
+	if idx <= 0 || idx == len(address)-1 {
+		return "", "", fmt.Errorf("illegal ssh address `%s`, the format is user@host[:port]", address)
+	}
+	user, host = address[:idx], address[idx+1:]
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, DefaultSSHPort)
+	}
+	return user, host, nil
+}
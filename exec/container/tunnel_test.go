@@ -0,0 +1,272 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package container
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshServer forwards the streamlocal channels to the unix sockets on the host and answers the keepalives
+type sshServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	conns    []*ssh.ServerConn
+}
+
+// startSSHServer starts the server accepting the key, and writes the key and the known hosts for the tunnel config
+func startSSHServer(t *testing.T) (*sshServer, SSHTunnelConfig) {
+	t.Helper()
+	dir := t.TempDir()
+	hostKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	hostSigner, _ := ssh.NewSignerFromKey(hostKey)
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	clientPublic, _ := ssh.NewPublicKey(&clientKey.PublicKey)
+	der, _ := x509.MarshalECPrivateKey(clientKey)
+	keyFile := path.Join(dir, "id_ecdsa")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientPublic.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	knownHostsFile := path.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{listener.Addr().String()}, hostSigner.PublicKey())
+	if err := os.WriteFile(knownHostsFile, []byte(line+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := &sshServer{listener: listener}
+	t.Cleanup(func() {
+		listener.Close()
+		server.dropConns()
+	})
+	go server.serve(config)
+	return server, SSHTunnelConfig{
+		Address:        "root@" + listener.Addr().String(),
+		KeyFile:        keyFile,
+		KnownHostsFile: knownHostsFile,
+	}
+}
+
+func (s *sshServer) serve(config *ssh.ServerConfig) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		serverConn, channels, requests, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			conn.Close()
+			continue
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, serverConn)
+		s.mu.Unlock()
+		go func() {
+			for request := range requests {
+				request.Reply(request.Type == "keepalive@openssh.com", nil)
+			}
+		}()
+		go func() {
+			for newChannel := range channels {
+				var payload struct {
+					SocketPath string
+					Reserved0  string
+					Reserved1  uint32
+				}
+				if newChannel.ChannelType() != "direct-streamlocal@openssh.com" ||
+					ssh.Unmarshal(newChannel.ExtraData(), &payload) != nil {
+					newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+					continue
+				}
+				remote, err := net.Dial("unix", payload.SocketPath)
+				if err != nil {
+					newChannel.Reject(ssh.ConnectionFailed, err.Error())
+					continue
+				}
+				channel, channelRequests, _ := newChannel.Accept()
+				go ssh.DiscardRequests(channelRequests)
+				go func() {
+					defer channel.Close()
+					defer remote.Close()
+					go io.Copy(remote, channel)
+					io.Copy(channel, remote)
+				}()
+			}
+		}()
+	}
+}
+
+// dropConns closes the connections like the node rebooted, the clients get no reply to the keepalives
+func (s *sshServer) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// startEchoSocket listens on the runtime socket of the remote node
+func startEchoSocket(t *testing.T) string {
+	t.Helper()
+	socket := path.Join(t.TempDir(), "runtime.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return socket
+}
+
+func echo(t *testing.T, socket string) {
+	t.Helper()
+	conn, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		t.Fatalf("dial tunnel %s failed, %v", socket, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte("ping"))
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "ping" {
+		t.Fatalf("expected the echo through the tunnel, got %q, %v", reply, err)
+	}
+}
+
+func cachedTunnel(config SSHTunnelConfig) *sshTunnel {
+	tunnelsMu.Lock()
+	defer tunnelsMu.Unlock()
+	return tunnels[config.Address+config.RemoteSocket]
+}
+
+func TestSSHTunnel(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	_, config := startSSHServer(t)
+	config.RemoteSocket = startEchoSocket(t)
+	defer CloseSSHTunnels()
+	socket, err := OpenSSHTunnel(config)
+	if err != nil {
+		t.Fatalf("open tunnel failed, %v", err)
+	}
+	echo(t, socket)
+	if cached, err := OpenSSHTunnel(config); err != nil || cached != socket {
+		t.Errorf("expected the tunnel %s cached, got %s, %v", socket, cached, err)
+	}
+	if err := CloseSSHTunnels(); err != nil {
+		t.Errorf("close tunnels failed, %v", err)
+	}
+	if _, err := os.Stat(path.Dir(socket)); !os.IsNotExist(err) {
+		t.Errorf("expected the tunnel dir removed, got %v", err)
+	}
+	if cachedTunnel(config) != nil {
+		t.Errorf("expected no tunnel cached after the close")
+	}
+}
+
+// TestSSHTunnelKeepalive drops the ssh connection, the tunnel is evicted by the keepalive and reopened by the next open
+func TestSSHTunnelKeepalive(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	defer func(interval time.Duration) { sshKeepaliveInterval = interval }(sshKeepaliveInterval)
+	sshKeepaliveInterval = 20 * time.Millisecond
+	server, config := startSSHServer(t)
+	config.RemoteSocket = startEchoSocket(t)
+	defer CloseSSHTunnels()
+	socket, err := OpenSSHTunnel(config)
+	if err != nil {
+		t.Fatalf("open tunnel failed, %v", err)
+	}
+	time.Sleep(5 * sshKeepaliveInterval)
+	if cachedTunnel(config) == nil {
+		t.Fatalf("expected the tunnel answering the keepalives kept")
+	}
+	server.dropConns()
+	deadline := time.Now().Add(5 * time.Second)
+	for cachedTunnel(config) != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cachedTunnel(config) != nil {
+		t.Fatalf("expected the tunnel evicted after the keepalive failed")
+	}
+	if _, err := os.Stat(path.Dir(socket)); !os.IsNotExist(err) {
+		t.Errorf("expected the evicted tunnel dir removed, got %v", err)
+	}
+	reopened, err := OpenSSHTunnel(config)
+	if err != nil || reopened == socket {
+		t.Fatalf("expected a new tunnel, got %s, %v", reopened, err)
+	}
+	echo(t, reopened)
+}
+
+func TestRemoveStaleTunnels(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	stale, _ := os.MkdirTemp("", sshTunnelDirPrefix)
+	fresh, _ := os.MkdirTemp("", sshTunnelDirPrefix)
+	listening, _ := os.MkdirTemp("", sshTunnelDirPrefix)
+	listener, err := net.Listen("unix", path.Join(listening, "runtime.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	old := time.Now().Add(-2 * sshStaleTunnelAge)
+	os.Chtimes(stale, old, old)
+	os.Chtimes(listening, old, old)
+	removeStaleTunnels()
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected the stale tunnel dir removed, got %v", err)
+	}
+	for _, dir := range []string{fresh, listening} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("expected the tunnel dir %s kept, got %v", dir, err)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// NoopDstDir is the directory the self-test file is copied to
+const NoopDstDir = "/tmp"
+
+// The steps of the noop experiment
+const (
+	NoopStepSelectTarget = "select-target"
+	NoopStepResolvePid   = "resolve-pid"
+	NoopStepCopyFile     = "copy-file"
+	NoopStepExec         = "exec"
+	NoopStepRevert       = "revert"
+)
+
+type NoopActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewNoopActionCommand() spec.ExpActionCommandSpec {
+	return &NoopActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags:    []spec.ExpFlagSpec{},
+			ActionExecutor: &noopActionExecutor{},
+			ActionExample: `# Validate the node readiness by the container a76d53933d3f without any fault
+blade create cri container noop --container-id a76d53933d3f --container-runtime containerd`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+func (*NoopActionCommand) Name() string {
+	return "noop"
+}
+
+func (*NoopActionCommand) Aliases() []string {
+	return []string{"selftest"}
+}
+
+func (*NoopActionCommand) ShortDesc() string {
+	return "self-test the experiment pipeline without any fault"
+}
+
+func (n *NoopActionCommand) LongDesc() string {
+	if n.ActionLongDesc != "" {
+		return n.ActionLongDesc
+	}
+	return "Selects the container, resolves the pid, copies a tiny file into the container, executes true and " +
+		"removes the file, the result reports the cost and the result of every step. It injects nothing."
+}
+
+// NoopStep is the result of one step of the noop experiment
+type NoopStep struct {
+	Name     string `json:"name"`
+	Success  bool   `json:"success"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NoopReport is the result of the noop experiment
+type NoopReport struct {
+	ContainerId string `json:"containerId,omitempty"`
+	Pid         int32  `json:"pid,omitempty"`
+	// ExtractPath is the path the file is extracted to in the container
+	ExtractPath string     `json:"extractPath,omitempty"`
+	Steps       []NoopStep `json:"steps"`
+	Duration    string     `json:"duration"`
+}
+
+type noopActionExecutor struct {
+}
+
+func (*noopActionExecutor) Name() string {
+	return "noop"
+}
+
+func (e *noopActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+// Exec runs the steps in order and stops at the first failed one, the copied file is always removed
+func (e *noopActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return spec.ReturnSuccess(uid)
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	start := time.Now()
+	report := &NoopReport{Steps: make([]NoopStep, 0)}
+	var failed *spec.Response
+	step := func(name string, fn func() *spec.Response) bool {
+		stepStart := time.Now()
+		response := fn()
+		noopStep := NoopStep{Name: name, Success: response.Success, Duration: time.Since(stepStart).String()}
+		if !response.Success {
+			noopStep.Error = response.Err
+			if failed == nil {
+				failed = response
+			}
+			log.Warnf(ctx, "noop experiment %s step %s failed, %s", uid, name, response.Err)
+		}
+		report.Steps = append(report.Steps, noopStep)
+		return response.Success
+	}
+
+	flags := model.ActionFlags
+	var containerInfo container.ContainerInfo
+	fileName := fmt.Sprintf("chaosblade-noop-%s", uid)
+	ok := step(NoopStepSelectTarget, func() *spec.Response {
+		var response *spec.Response
+		containerInfo, response = GetContainer(ctx, client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name],
+			parseContainerLabelSelector(flags[ContainerLabelSelectorFlag.Name]))
+		report.ContainerId = containerInfo.ContainerId
+		return response
+	}) && step(NoopStepResolvePid, func() *spec.Response {
+		pid, err, code := client.GetPidById(ctx, containerInfo.ContainerId)
+		if err != nil {
+			return spec.ResponseFail(code, err.Error(), nil)
+		}
+		report.Pid = pid
+		return spec.ReturnSuccess(pid)
+	})
+	if ok {
+		copied := step(NoopStepCopyFile, func() *spec.Response {
+			var response *spec.Response
+			report.ExtractPath, response = copyNoopFile(ctx, client, containerInfo.ContainerId, fileName, uid)
+			return response
+		})
+		if copied {
+			step(NoopStepExec, func() *spec.Response {
+				if _, err := client.ExecContainer(ctx, containerInfo.ContainerId, "true"); err != nil {
+					return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "ExecContainer", err)
+				}
+				return spec.ReturnSuccess(nil)
+			})
+		}
+		// the file may be extracted even if the copy failed, the archive is removed by the client
+		step(NoopStepRevert, func() *spec.Response {
+			command := fmt.Sprintf("rm -f %s", path.Join(NoopDstDir, fileName))
+			if _, err := client.ExecContainer(ctx, containerInfo.ContainerId, command); err != nil {
+				return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "ExecContainer", err)
+			}
+			return spec.ReturnSuccess(nil)
+		})
+	}
+	report.Duration = time.Since(start).String()
+	if failed != nil {
+		return spec.ResponseFail(failed.Code, failed.Err, report)
+	}
+	return spec.ReturnSuccess(report)
+}
+
+// copyNoopFile copies the archive of a tiny file to the container and returns the extraction path, the clients
+// extract the archive after copied
+func copyNoopFile(ctx context.Context, client container.Container, containerId, fileName, content string) (string,
+	*spec.Response) {
+	dir, err := os.MkdirTemp("", "chaosblade-noop-")
+	if err != nil {
+		return "", spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "MkdirTemp", err)
+	}
+	defer os.RemoveAll(dir)
+	archive := path.Join(dir, fileName+".tar.gz")
+	if err := writeNoopArchive(archive, fileName, content); err != nil {
+		return "", spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "WriteArchive", err)
+	}
+	extractPath, err := client.CopyToContainer(ctx, containerId, archive, NoopDstDir, fileName, true)
+	if err != nil {
+		return "", spec.ResponseFailWithFlags(spec.ContainerExecFailed, "CopyToContainer", err)
+	}
+	return extractPath, spec.ReturnSuccess(nil)
+}
+
+func writeNoopArchive(archive, fileName, content string) error {
+	file, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gw := gzip.NewWriter(file)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: fileName, Mode: 0644, Size: int64(len(content)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+func runNoop(t *testing.T, client *mock.Container) *spec.Response {
+	t.Helper()
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	t.Cleanup(func() { NewClientFunc = nil })
+	model := &spec.ExpModel{
+		Target:      "container",
+		ActionName:  "noop",
+		ActionFlags: map[string]string{ContainerIdFlag.Name: "c1"},
+	}
+	return (&noopActionExecutor{}).Exec("uid1", context.Background(), model)
+}
+
+func noopSteps(response *spec.Response) []string {
+	steps := make([]string, 0)
+	for _, step := range response.Result.(*NoopReport).Steps {
+		steps = append(steps, fmt.Sprintf("%s:%t", step.Name, step.Success))
+	}
+	return steps
+}
+
+func TestNoop(t *testing.T) {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1", ContainerName: "nginx"})
+	client.Pids["c1"] = 1234
+	response := runNoop(t, client)
+	if !response.Success {
+		t.Fatalf("noop failed, %s", response.Err)
+	}
+	expected := fmt.Sprint([]string{"select-target:true", "resolve-pid:true", "copy-file:true", "exec:true", "revert:true"})
+	if steps := fmt.Sprint(noopSteps(response)); steps != expected {
+		t.Errorf("expected steps %s, got %s", expected, steps)
+	}
+	copies := client.CallsOf("CopyToContainer")
+	if len(copies) != 1 || copies[0].Args[2] != NoopDstDir {
+		t.Errorf("unexpected copy calls %v", copies)
+	}
+	if extractPath := response.Result.(*NoopReport).ExtractPath; extractPath != "/tmp/chaosblade-noop-uid1" {
+		t.Errorf("unexpected extract path %s", extractPath)
+	}
+	execs := client.CallsOf("ExecContainer")
+	if len(execs) != 2 || execs[1].Args[1] != "rm -f /tmp/chaosblade-noop-uid1" {
+		t.Errorf("unexpected exec calls %v", execs)
+	}
+}
+
+func TestNoopFailed(t *testing.T) {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1", ContainerName: "nginx"})
+	client.Pids["c1"] = 1234
+	client.CopyToContainerFunc = func(ctx context.Context, containerId, srcFile, dstPath, extractDirName string, override bool) (string, error) {
+		return "", fmt.Errorf("no space left on device")
+	}
+	response := runNoop(t, client)
+	if response.Success {
+		t.Fatal("expected the noop failed")
+	}
+	expected := fmt.Sprint([]string{"select-target:true", "resolve-pid:true", "copy-file:false", "revert:true"})
+	if steps := fmt.Sprint(noopSteps(response)); steps != expected {
+		t.Errorf("expected steps %s, got %s", expected, steps)
+	}
+
+	response = runNoop(t, mock.NewContainer())
+	if response.Success {
+		t.Fatal("expected the noop failed without the container")
+	}
+	if steps := fmt.Sprint(noopSteps(response)); steps != fmt.Sprint([]string{"select-target:false"}) {
+		t.Errorf("unexpected steps %s", steps)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/ctrstate"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+// StateResult is the result of the destroy verified by the container snapshots
+type StateResult struct {
+	Result interface{}    `json:"result,omitempty"`
+	State  *ctrstate.Diff `json:"state"`
+}
+
+// snapshotContainer snapshots the container of the experiment, it's replaced by the tests
+var snapshotContainer = func(ctx context.Context, uid string, model *spec.ExpModel) (ctrstate.Snapshot, error) {
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		return ctrstate.Snapshot{}, err
+	}
+	containerInfo, response := GetContainer(ctx, client, uid, model.ActionFlags[ContainerIdFlag.Name],
+		model.ActionFlags[ContainerNameFlag.Name], nil)
+	if !response.Success {
+		return ctrstate.Snapshot{}, errors.New(response.Err)
+	}
+	return ctrstate.Take(ctx, client, containerInfo.ContainerId, verifyFiles(model))
+}
+
+func verifyFiles(model *spec.ExpModel) []string {
+	files := make([]string, 0)
+	for _, file := range strings.Split(model.ActionFlags[VerifyFilesFlag.Name], ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// stateExecutor snapshots the container before the experiment of a single container is injected, and compares it
+// after the experiment is reverted if the verify-state flag is set. The experiment is never failed by the snapshots
+type stateExecutor struct {
+	spec.Executor
+}
+
+func wrapStateVerify(executor spec.Executor) spec.Executor {
+	if _, ok := executor.(*stateExecutor); ok {
+		return executor
+	}
+	return &stateExecutor{Executor: executor}
+}
+
+func (e *stateExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if verify, _ := strconv.ParseBool(model.ActionFlags[VerifyStateFlag.Name]); !verify {
+		return e.Executor.Exec(uid, ctx, model)
+	}
+	if suid, isDestroy := spec.IsDestroy(ctx); isDestroy {
+		if suid != "" {
+			uid = suid
+		}
+		return e.destroy(uid, ctx, model)
+	}
+	before, err := snapshotContainer(ctx, uid, model)
+	if err != nil {
+		warning.Add(ctx, "VerifyState", "snapshot the container before the experiment %s failed, %v", uid, err)
+	}
+	response := e.Executor.Exec(uid, ctx, model)
+	if err != nil || !response.Success {
+		return response
+	}
+	if err := ctrstate.Save(util.GetProgramPath(), uid, before); err != nil {
+		warning.Add(ctx, "VerifyState", "save the container snapshot of the experiment %s failed, %v", uid, err)
+	}
+	return response
+}
+
+func (e *stateExecutor) destroy(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	response := e.Executor.Exec(uid, ctx, model)
+	if !response.Success {
+		return response
+	}
+	stateDir := util.GetProgramPath()
+	before, err := ctrstate.Load(stateDir, uid)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			warning.Add(ctx, "VerifyState", "load the container snapshot of the experiment %s failed, %v", uid, err)
+		}
+		return response
+	}
+	after, err := snapshotContainer(ctx, uid, model)
+	if err != nil {
+		warning.Add(ctx, "VerifyState", "snapshot the container after the experiment %s failed, %v", uid, err)
+		return response
+	}
+	if err := ctrstate.Remove(stateDir, uid); err != nil {
+		log.Warnf(ctx, "remove the container snapshot of the experiment %s failed, %v", uid, err)
+	}
+	diff := ctrstate.Compare(before, after)
+	if !diff.Restored {
+		log.Warnf(ctx, "container %s is not restored after the experiment %s, residue %v", before.ContainerId, uid,
+			diff.Residue)
+	}
+	response.Result = StateResult{Result: response.Result, State: &diff}
+	return response
+}
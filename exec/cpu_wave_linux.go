@@ -0,0 +1,252 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/cpu"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/holder"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rollback"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/wave"
+)
+
+// The flags of the cpu wave experiment
+const (
+	CpuWaveShapeFlag    = "shape"
+	CpuWaveHighFlag     = "high-percent"
+	CpuWaveLowFlag      = "low-percent"
+	CpuWavePeriodFlag   = "period"
+	CpuWaveBurstFlag    = "burst"
+	CpuWaveCountFlag    = "cpu-count"
+	CpuWaveDurationFlag = "duration"
+)
+
+// CpuWaveBin is the binary burning the cpu by the wave
+const CpuWaveBin = "chaos_cpuwave"
+
+// startCpuWave starts the burner in the pid namespace and the cgroups of the process, so the load is charged to the
+// container, it returns the host pid of nsexec
+var startCpuWave = func(ctx context.Context, pid int32, cgroupRoot string, argv []string) (int, error) {
+	return startInCgroup(ctx, pid, cgroupRoot, []nsexec.Namespace{nsexec.Pid}, argv)
+}
+
+// withCpuWaveAction adds the wave action to the cpu model of chaos_os, its executor is set after the executor of the
+// common models
+func withCpuWaveAction(commandSpec spec.ExpModelCommandSpec) spec.ExpModelCommandSpec {
+	if cpuSpec, ok := commandSpec.(*cpu.CpuCommandModelSpec); ok {
+		cpuSpec.ExpActions = append(cpuSpec.ExpActions, NewCpuWaveActionCommand())
+	}
+	return commandSpec
+}
+
+func NewCpuWaveActionCommand() spec.ExpActionCommandSpec {
+	return &CpuWaveActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: CpuWaveShapeFlag,
+					Desc: fmt.Sprintf("shape of the bursts, %s keeps the high percent, %s decays from it, %s rises to "+
+						"it, default is %s", wave.ShapeSquare, wave.ShapeSpike, wave.ShapeRamp, wave.ShapeSquare),
+					Default: wave.ShapeSquare,
+				},
+				&spec.ExpFlag{
+					Name:    CpuWaveHighFlag,
+					Desc:    "percent of every cpu burnt at the top of the burst, 1 to 100, default is 90",
+					Default: "90",
+				},
+				&spec.ExpFlag{
+					Name:    CpuWaveLowFlag,
+					Desc:    "percent of every cpu burnt out of the bursts, 0 to 99, default is 0",
+					Default: "0",
+				},
+				&spec.ExpFlag{
+					Name:    CpuWavePeriodFlag,
+					Desc:    "seconds of the period, a burst starts every period, default is 60",
+					Default: "60",
+				},
+				&spec.ExpFlag{
+					Name:    CpuWaveBurstFlag,
+					Desc:    "seconds of the burst, at most the period, default is 10",
+					Default: "10",
+				},
+				&spec.ExpFlag{
+					Name:    CpuWaveCountFlag,
+					Desc:    "cpus burnt, default is 1",
+					Default: "1",
+				},
+				&spec.ExpFlag{
+					Name: CpuWaveDurationFlag,
+					Desc: "duration of the waves, such as 90s or 1h, the integer is seconds, default or forever is until the experiment is destroyed",
+				},
+				&spec.ExpFlag{
+					Name: "cgroup-root",
+					Desc: "cgroup root path, default value /sys/fs/cgroup",
+				},
+			},
+			ActionExecutor: &cpuWaveExecutor{},
+			ActionLongDesc: "The cpu of the container is burnt by a waveform instead of a constant load, a burst " +
+				"starts every period and the load is the low percent out of the bursts. The burner is started in the " +
+				"pid namespace and the cgroups of the container, so the load is charged to the container and " +
+				"throttled by its cpu limit. The destroy kills the burner.",
+			ActionExample: `# Burn 2 cpus at 90% for 10 seconds every minute
+blade create cri cpu wave --high-percent 90 --burst 10 --period 60 --cpu-count 2 --container-id ee54f1e61c08
+
+# Ramp the load from 20% to 100% in 5 minutes, for an hour
+blade create cri cpu wave --shape ramp --low-percent 20 --high-percent 100 --burst 300 --period 300 --duration 3600 --container-id ee54f1e61c08`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+type CpuWaveActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*CpuWaveActionCommand) Name() string {
+	return "wave"
+}
+
+func (*CpuWaveActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*CpuWaveActionCommand) ShortDesc() string {
+	return "cpu load by waveform"
+}
+
+func (c *CpuWaveActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+// CpuWaveResult is the result of the cpu wave experiment
+type CpuWaveResult struct {
+	holder.State
+	Wave wave.Wave `json:"wave"`
+}
+
+type cpuWaveExecutor struct {
+}
+
+func (e *cpuWaveExecutor) Name() string {
+	return "cpu"
+}
+
+func (e *cpuWaveExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *cpuWaveExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return stopCpuWave(ctx, suid)
+	}
+	flags := model.ActionFlags
+	w := wave.Wave{Shape: flags[CpuWaveShapeFlag]}
+	if w.Shape == "" {
+		w.Shape = wave.ShapeSquare
+	}
+	var response *spec.Response
+	if w.High, response = rangeFlag(flags, CpuWaveHighFlag, 90, 1, 100); response != nil {
+		return response
+	}
+	if w.Low, response = rangeFlag(flags, CpuWaveLowFlag, 0, 0, 99); response != nil {
+		return response
+	}
+	if w.Period, response = intervalFlag(flags, CpuWavePeriodFlag, 60, 1); response != nil {
+		return response
+	}
+	if w.Burst, response = intervalFlag(flags, CpuWaveBurstFlag, 10, 1); response != nil {
+		return response
+	}
+	if err := w.Validate(); err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, CpuWaveShapeFlag, w.Shape, err)
+	}
+	cpus, response := intervalFlag(flags, CpuWaveCountFlag, 1, 1)
+	if response != nil {
+		return response
+	}
+	duration, response := lifetimeFlag(flags, CpuWaveDurationFlag)
+	if response != nil {
+		return response
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	argv := []string{
+		path.Join(util.GetProgramPath(), spec.BinPath, CpuWaveBin),
+		"-shape", w.Shape,
+		"-high", strconv.Itoa(w.High), "-low", strconv.Itoa(w.Low),
+		"-period", strconv.Itoa(w.Period), "-burst", strconv.Itoa(w.Burst),
+		"-cpu-count", strconv.Itoa(cpus), "-duration", duration.String(),
+		"-uid", uid,
+	}
+	starter, err := startCpuWave(ctx, pid, getCgroupRoot(model), argv)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, CpuWaveBin, err)
+	}
+	state := holder.State{Uid: uid, Kind: "cpu-wave", Starter: starter, Cmdline: strings.Join(argv, " "), Count: cpus}
+	steps := rollback.New(ctx, uid)
+	// the state is read when released, so the burner found later is released too
+	steps.Done("start burner", func() error { return holder.Release(state) })
+	for i := 0; i < 50; i++ {
+		if state.Holder, err = holder.Find(starter, state.Cmdline); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, CpuWaveBin, err))
+	}
+	if err := holder.SaveState(util.GetProgramPath(), state); err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err))
+	}
+	log.Infof(ctx, "%d cpus of process %d are burnt by the %s wave of %d%%-%d%%, %d of every %d seconds, burner %d",
+		cpus, pid, w.Shape, w.Low, w.High, w.Burst, w.Period, state.Holder)
+	return spec.ReturnSuccess(CpuWaveResult{State: state, Wave: w})
+}
+
+// stopCpuWave kills the burner of the experiment, it succeeds if the burner exited by the duration
+func stopCpuWave(ctx context.Context, uid string) *spec.Response {
+	stateDir := util.GetProgramPath()
+	state, err := holder.LoadState(stateDir, uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, stateDir, err)
+	}
+	if err := holder.Release(state); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "kill", err)
+	}
+	if err := holder.RemoveState(stateDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove state", err)
+	}
+	log.Infof(ctx, "the burner %d of experiment %s is stopped", state.Holder, uid)
+	return spec.ReturnSuccess(uid)
+}
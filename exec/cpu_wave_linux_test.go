@@ -0,0 +1,113 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/holder"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/wave"
+)
+
+// fakeCpuWave builds the burner and starts it on the host with the command line of the container, the argv started
+// are recorded
+func fakeCpuWave(t *testing.T) *[][]string {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skipf("no go to build the burner, %v", err)
+	}
+	bin := path.Join(t.TempDir(), CpuWaveBin)
+	if output, err := exec.Command(goBin, "build", "-o", bin, "../cmd/cpu-wave").CombinedOutput(); err != nil {
+		t.Fatalf("build the burner failed, %v: %s", err, output)
+	}
+	argvs := make([][]string, 0)
+	origin := startCpuWave
+	startCpuWave = func(ctx context.Context, pid int32, cgroupRoot string, argv []string) (int, error) {
+		argvs = append(argvs, argv)
+		cmd := &exec.Cmd{Path: bin, Args: argv}
+		if err := cmd.Start(); err != nil {
+			return 0, err
+		}
+		go cmd.Wait()
+		t.Cleanup(func() { cmd.Process.Kill() })
+		return cmd.Process.Pid, nil
+	}
+	t.Cleanup(func() { startCpuWave = origin })
+	return &argvs
+}
+
+func TestCpuWave(t *testing.T) {
+	fakeNetns(t, "")
+	argvs := fakeCpuWave(t)
+	flags := map[string]string{ContainerIdFlag.Name: "c1", CpuWaveShapeFlag: "spike", CpuWaveHighFlag: "5",
+		CpuWavePeriodFlag: "10", CpuWaveBurstFlag: "2"}
+	model := &spec.ExpModel{Target: "cpu", ActionName: "wave", ActionFlags: flags}
+	response := (&cpuWaveExecutor{}).Exec("uid1", context.Background(), model)
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	defer holder.RemoveState(util.GetProgramPath(), "uid1")
+	result := response.Result.(CpuWaveResult)
+	expected := wave.Wave{Shape: wave.ShapeSpike, High: 5, Low: 0, Period: 10, Burst: 2}
+	if result.Wave != expected || result.Count != 1 || result.Holder != result.Starter {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(*argvs) != 1 || path.Base((*argvs)[0][0]) != CpuWaveBin {
+		t.Fatalf("expected the burner started, got %q", *argvs)
+	}
+	if cmdline, err := holder.Cmdline(result.Holder); err != nil || cmdline != result.Cmdline {
+		t.Fatalf("expected the burner running, got %q, %v", cmdline, err)
+	}
+
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := (&cpuWaveExecutor{}).Exec("uid1", ctx, model); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if cmdline, _ := holder.Cmdline(result.Holder); cmdline == result.Cmdline {
+		t.Error("expected the burner killed")
+	}
+	if _, err := holder.LoadState(util.GetProgramPath(), "uid1"); !os.IsNotExist(err) {
+		t.Errorf("expected the state removed, got %v", err)
+	}
+}
+
+func TestCpuWaveIllegal(t *testing.T) {
+	fakeNetns(t, "")
+	for _, flags := range []map[string]string{
+		{CpuWaveShapeFlag: "sine"},
+		{CpuWaveHighFlag: "101"},
+		{CpuWaveHighFlag: "50", CpuWaveLowFlag: "60"},
+		{CpuWaveBurstFlag: "120"},
+		{CpuWaveCountFlag: "0"},
+		{CpuWaveDurationFlag: "x"},
+	} {
+		flags[ContainerIdFlag.Name] = "c1"
+		model := &spec.ExpModel{Target: "cpu", ActionName: "wave", ActionFlags: flags}
+		response := (&cpuWaveExecutor{}).Exec("uid1", context.Background(), model)
+		if response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the illegal flags %v, got %+v", flags, response)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package credential looks up the credentials of the images pulled by the runtimes, such as the helper images, from
+// the plugins of the node instead of the static passwords: the image credential provider plugins of the kubelet, the
+// docker credential helpers of the docker config and the helpers of the cloud registries, ECR, GCR and ACR, found in
+// the PATH. The plugins exchange the identity of the node for the short-lived tokens of the registries.
+package credential
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+// DockerHub is the registry of the images without the registry host
+const DockerHub = "docker.io"
+
+// Credential is the credential of a registry, the identity token is used instead of the password if set
+type Credential struct {
+	Username      string
+	Password      string
+	IdentityToken string
+}
+
+// IsEmpty returns true if there is no credential, the image is pulled anonymously
+func (c Credential) IsEmpty() bool {
+	return c.Username == "" && c.Password == "" && c.IdentityToken == ""
+}
+
+// Provider provides the credentials of the images
+type Provider interface {
+	// Name is the name of the provider in the logs
+	Name() string
+	// Get returns the credential of the image, false if the provider does not serve the image
+	Get(ctx context.Context, image string) (Credential, bool, error)
+}
+
+// Chain is the providers by precedence, the first one serving the image provides its credential
+type Chain []Provider
+
+// Get returns the credential of the first provider serving the image, the failed providers are skipped with the
+// errors returned, the credential is empty if no provider serves the image
+func (c Chain) Get(ctx context.Context, image string) (Credential, error) {
+	var errs []string
+	for _, provider := range c {
+		credential, ok, err := provider.Get(ctx, image)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", provider.Name(), err))
+			continue
+		}
+		if ok {
+			return credential, nil
+		}
+	}
+	if len(errs) > 0 {
+		return Credential{}, fmt.Errorf("get the credential of %s failed, %s", image, strings.Join(errs, "; "))
+	}
+	return Credential{}, nil
+}
+
+// Lookup returns the credential of the image by the providers of the node, see Default. The image is pulled
+// anonymously with a warning if the providers failed
+func Lookup(ctx context.Context, image string) Credential {
+	credential, err := Default(ctx).Get(ctx, image)
+	if err != nil {
+		warning.Add(ctx, "PullImage", "%v, pull it anonymously", err)
+	}
+	return credential
+}
+
+// Registry returns the registry host of the image, DockerHub if the image has no host
+func Registry(image string) string {
+	slash := strings.IndexByte(image, '/')
+	if slash < 0 {
+		return DockerHub
+	}
+	host := image[:slash]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host
+	}
+	return DockerHub
+}
+
+// MatchImage returns true if the image matches the pattern of the kubelet credential providers, such as
+// *.dkr.ecr.*.amazonaws.com or gcr.io/project. The labels of the host are matched by the globs one by one, the port
+// must be the same if the pattern has one, and the path of the pattern is a prefix of the path of the image
+func MatchImage(pattern, image string) bool {
+	patternURL, err := url.Parse("https://" + pattern)
+	if err != nil {
+		return false
+	}
+	imageURL, err := parseImageURL(image)
+	if err != nil {
+		return false
+	}
+	if patternURL.Port() != "" && patternURL.Port() != imageURL.Port() {
+		return false
+	}
+	patternLabels := strings.Split(patternURL.Hostname(), ".")
+	imageLabels := strings.Split(imageURL.Hostname(), ".")
+	if len(patternLabels) != len(imageLabels) {
+		return false
+	}
+	for i := range patternLabels {
+		if ok, err := path.Match(patternLabels[i], imageLabels[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return strings.HasPrefix(imageURL.Path, patternURL.Path)
+}
+
+// parseImageURL parses the image as the url of the registry, the tag and the digest are dropped
+func parseImageURL(image string) (*url.URL, error) {
+	if i := strings.IndexByte(image, '@'); i >= 0 {
+		image = image[:i]
+	}
+	if slash, colon := strings.LastIndexByte(image, '/'), strings.LastIndexByte(image, ':'); colon > slash {
+		image = image[:colon]
+	}
+	if Registry(image) == DockerHub && !strings.HasPrefix(image, DockerHub+"/") {
+		image = DockerHub + "/" + image
+	}
+	return url.Parse("https://" + image)
+}
@@ -0,0 +1,219 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package credential
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	tests := map[string]string{
+		"busybox":                       DockerHub,
+		"library/busybox:latest":        DockerHub,
+		"localhost/busybox":             "localhost",
+		"registry.local:5000/tools/app": "registry.local:5000",
+		"gcr.io/project/app@sha256:abc": "gcr.io",
+	}
+	for image, expected := range tests {
+		if registry := Registry(image); registry != expected {
+			t.Errorf("expected %s of %s, got %s", expected, image, registry)
+		}
+	}
+}
+
+func TestMatchImage(t *testing.T) {
+	tests := []struct {
+		pattern, image string
+		expected       bool
+	}{
+		{"*.dkr.ecr.*.amazonaws.com", "123.dkr.ecr.us-east-1.amazonaws.com/tools:v1", true},
+		{"*.dkr.ecr.*.amazonaws.com", "dkr.ecr.us-east-1.amazonaws.com/tools", false},
+		{"gcr.io", "gcr.io/project/app:v1", true},
+		{"gcr.io/project", "gcr.io/project/app", true},
+		{"gcr.io/project", "gcr.io/other/app", false},
+		{"*.gcr.io", "gcr.io/project/app", false},
+		{"registry.local:5000", "registry.local:5000/app", true},
+		{"registry.local:5000", "registry.local/app", false},
+		{"docker.io", "busybox:latest", true},
+		{"docker.io/library", "library/busybox", true},
+	}
+	for _, tt := range tests {
+		if matched := MatchImage(tt.pattern, tt.image); matched != tt.expected {
+			t.Errorf("expected %v of %s by %s, got %v", tt.expected, tt.image, tt.pattern, matched)
+		}
+	}
+}
+
+type fakeProvider struct {
+	name       string
+	credential Credential
+	ok         bool
+	err        error
+}
+
+func (f fakeProvider) Name() string { return f.name }
+
+func (f fakeProvider) Get(context.Context, string) (Credential, bool, error) {
+	return f.credential, f.ok, f.err
+}
+
+func TestChain(t *testing.T) {
+	served := Credential{Username: "user", Password: "secret"}
+	chain := Chain{
+		fakeProvider{name: "failed", err: errors.New("expired")},
+		fakeProvider{name: "skipped"},
+		fakeProvider{name: "served", credential: served, ok: true},
+	}
+	if credential, err := chain.Get(context.Background(), "busybox"); err != nil || credential != served {
+		t.Errorf("expected %v, got %v, %v", served, credential, err)
+	}
+	credential, err := chain[:2].Get(context.Background(), "busybox")
+	if err == nil || !strings.Contains(err.Error(), "failed: expired") || !credential.IsEmpty() {
+		t.Errorf("expected the error of the failed provider, got %v, %v", credential, err)
+	}
+}
+
+// writeScript writes the executable shell script into the dir
+func writeScript(t *testing.T, dir, name, script string) {
+	if err := os.WriteFile(path.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHelper(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, HelperPrefix+"fake", `read server
+case "$server" in
+https://index.docker.io/v1/) echo '{"ServerURL":"'$server'","Username":"hub","Secret":"pass"}' ;;
+gcr.io) echo '{"ServerURL":"gcr.io","Username":"<token>","Secret":"token"}' ;;
+*) echo "credentials not found in native keychain"; exit 1 ;;
+esac
+`)
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+	helper := Helper{Helper: "fake"}
+	tests := []struct {
+		image    string
+		expected Credential
+		ok       bool
+	}{
+		{"busybox", Credential{Username: "hub", Password: "pass"}, true},
+		{"gcr.io/project/app", Credential{IdentityToken: "token"}, true},
+		{"quay.io/app", Credential{}, false},
+	}
+	for _, tt := range tests {
+		credential, ok, err := helper.Get(context.Background(), tt.image)
+		if err != nil || ok != tt.ok || credential != tt.expected {
+			t.Errorf("expected %v, %v of %s, got %v, %v, %v", tt.expected, tt.ok, tt.image, credential, ok, err)
+		}
+	}
+	if _, ok, err := (Helper{Helper: "fake", Registries: []string{"quay.io"}}).Get(context.Background(), "busybox"); ok || err != nil {
+		t.Errorf("expected the unmatched registry skipped, got %v, %v", ok, err)
+	}
+}
+
+func TestDockerConfigHelpers(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(DockerConfigEnv, dir)
+	config := `{"credsStore":"desktop","credHelpers":{"gcr.io":"gcr","https://index.docker.io/v1/":"hub"}}`
+	if err := os.WriteFile(path.Join(dir, "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	providers, err := DockerConfigHelpers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Helper{
+		{Helper: "gcr", Registries: []string{"gcr.io"}},
+		{Helper: "hub", Registries: []string{DockerHub}},
+		{Helper: "desktop"},
+	}
+	if len(providers) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, providers)
+	}
+	for i := range expected {
+		helper := providers[i].(Helper)
+		if helper.Helper != expected[i].Helper || strings.Join(helper.Registries, ",") != strings.Join(expected[i].Registries, ",") {
+			t.Errorf("expected %v, got %v", expected[i], helper)
+		}
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	dir := t.TempDir()
+	calls := path.Join(dir, "calls")
+	writeScript(t, dir, "fake-credential-provider", `cat > /dev/null
+echo called >> `+calls+`
+echo '{"kind":"CredentialProviderResponse","cacheKeyType":"Registry","cacheDuration":"1h",
+"auth":{"*.dkr.ecr.*.amazonaws.com":{"username":"AWS","password":"'$TOKEN'"},
+"*.dkr.ecr.*.amazonaws.com/private":{"username":"AWS","password":"private"}}}'
+`)
+	config := path.Join(dir, "config.yaml")
+	if err := os.WriteFile(config, []byte(`apiVersion: kubelet.config.k8s.io/v1
+kind: CredentialProviderConfig
+providers:
+- name: fake-credential-provider
+  matchImages:
+  - "*.dkr.ecr.*.amazonaws.com"
+  defaultCacheDuration: 10m
+  apiVersion: credentialprovider.kubelet.k8s.io/v1
+  env:
+  - name: TOKEN
+    value: token
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPluginConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin := Plugin{Provider: loaded.Providers[0], BinDir: dir}
+	tests := []struct {
+		image    string
+		expected Credential
+	}{
+		{"123.dkr.ecr.us-east-1.amazonaws.com/tools:v1", Credential{Username: "AWS", Password: "token"}},
+		{"123.dkr.ecr.us-east-1.amazonaws.com/private/app", Credential{Username: "AWS", Password: "private"}},
+	}
+	for _, tt := range tests {
+		credential, ok, err := plugin.Get(context.Background(), tt.image)
+		if err != nil || !ok || credential != tt.expected {
+			t.Errorf("expected %v of %s, got %v, %v, %v", tt.expected, tt.image, credential, ok, err)
+		}
+	}
+	if _, ok, err := plugin.Get(context.Background(), "gcr.io/project/app"); ok || err != nil {
+		t.Errorf("expected the unmatched image skipped, got %v, %v", ok, err)
+	}
+	// the response is cached by the registry
+	if content, _ := os.ReadFile(calls); strings.Count(string(content), "called") != 1 {
+		t.Errorf("expected the plugin called once, got %q", content)
+	}
+}
+
+func TestLoadPluginConfigWithoutMatchImages(t *testing.T) {
+	config := path.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(config, []byte("providers:\n- name: fake\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPluginConfig(config); err == nil || !strings.Contains(err.Error(), "matchImages") {
+		t.Errorf("expected the illegal config, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package credential
+
+import (
+	"context"
+	"os"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+)
+
+const (
+	// PluginConfigEnv is the CredentialProviderConfig of the kubelet plugins, usually the file of the
+	// --image-credential-provider-config flag of the kubelet
+	PluginConfigEnv = "CHAOSBLADE_CRI_CREDENTIAL_PROVIDER_CONFIG"
+	// PluginBinDirEnv is the directory of the plugin binaries, the --image-credential-provider-bin-dir flag of the
+	// kubelet
+	PluginBinDirEnv = "CHAOSBLADE_CRI_CREDENTIAL_PROVIDER_BIN_DIR"
+)
+
+// Default returns the providers of the node by precedence: the kubelet plugins of PluginConfigEnv, the helpers of
+// the docker config, then the helpers of the cloud registries found in the PATH. The illegal configs are skipped with
+// the warning logs
+func Default(ctx context.Context) Chain {
+	chain := make(Chain, 0)
+	if file := os.Getenv(PluginConfigEnv); file != "" {
+		if config, err := LoadPluginConfig(file); err != nil {
+			log.Warnf(ctx, "skip the kubelet credential providers, %v", err)
+		} else {
+			for _, provider := range config.Providers {
+				chain = append(chain, Plugin{Provider: provider, BinDir: os.Getenv(PluginBinDirEnv)})
+			}
+		}
+	}
+	helpers, err := DockerConfigHelpers()
+	if err != nil {
+		log.Warnf(ctx, "skip the docker credential helpers, %v", err)
+	}
+	chain = append(chain, helpers...)
+	return append(chain, InstalledCloudHelpers()...)
+}
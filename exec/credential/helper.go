@@ -0,0 +1,160 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package credential
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// HelperPrefix is the prefix of the binaries of the docker credential helpers
+	HelperPrefix = "docker-credential-"
+	// DockerConfigEnv is the directory of the docker config
+	DockerConfigEnv = "DOCKER_CONFIG"
+	// dockerHubServer is the server url of docker hub in the helpers
+	dockerHubServer = "https://index.docker.io/v1/"
+	// tokenUsername is the username of the helpers returning the identity token as the secret
+	tokenUsername = "<token>"
+)
+
+// CloudHelpers are the helpers of the cloud registries by the patterns of the registries, they are used if found in
+// the PATH and the docker config has no helper of the registry
+var CloudHelpers = []struct {
+	Pattern string
+	Helper  string
+}{
+	{"*.dkr.ecr.*.amazonaws.com", "ecr-login"},
+	{"*.dkr.ecr.*.amazonaws.com.cn", "ecr-login"},
+	{"gcr.io", "gcr"},
+	{"*.gcr.io", "gcr"},
+	{"*.pkg.dev", "gcr"},
+	{"*.azurecr.io", "acr-env"},
+}
+
+// Helper gets the credentials by the docker credential helper, the protocol of docker-credential-helpers: the server
+// url is written to the stdin of `docker-credential-<name> get`
+type Helper struct {
+	// Helper is the name of the helper, such as ecr-login
+	Helper string
+	// Registries are the patterns of the registries the helper serves, see MatchImage, all if empty
+	Registries []string
+}
+
+func (h Helper) Name() string {
+	return HelperPrefix + h.Helper
+}
+
+func (h Helper) Get(ctx context.Context, image string) (Credential, bool, error) {
+	if len(h.Registries) > 0 {
+		matched := false
+		for _, pattern := range h.Registries {
+			matched = matched || MatchImage(pattern, image)
+		}
+		if !matched {
+			return Credential{}, false, nil
+		}
+	}
+	server := Registry(image)
+	if server == DockerHub {
+		server = dockerHubServer
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.Name(), "get")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = strings.NewReader(server), &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stdout.String() + stderr.String())
+		// the helpers print the message of the missing credentials and exit 1
+		if strings.Contains(output, "credentials not found") {
+			return Credential{}, false, nil
+		}
+		return Credential{}, false, fmt.Errorf("%v, %s", err, output)
+	}
+	var response struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return Credential{}, false, fmt.Errorf("illegal output of %s, %v", h.Name(), err)
+	}
+	if response.Username == tokenUsername {
+		return Credential{IdentityToken: response.Secret}, true, nil
+	}
+	return Credential{Username: response.Username, Password: response.Secret}, true, nil
+}
+
+// DockerConfigHelpers returns the helpers of the docker config, the credHelpers of the registries before the
+// credsStore of all registries. The static auths of the config are not used
+func DockerConfigHelpers() ([]Provider, error) {
+	dir := os.Getenv(DockerConfigEnv)
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		dir = path.Join(home, ".docker")
+	}
+	content, err := os.ReadFile(path.Join(dir, "config.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var config struct {
+		CredsStore  string            `json:"credsStore"`
+		CredHelpers map[string]string `json:"credHelpers"`
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("illegal docker config %s, %v", dir, err)
+	}
+	registries := make([]string, 0, len(config.CredHelpers))
+	for registry := range config.CredHelpers {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	providers := make([]Provider, 0, len(registries)+1)
+	for _, registry := range registries {
+		pattern := registry
+		if pattern == dockerHubServer {
+			pattern = DockerHub
+		}
+		providers = append(providers, Helper{Helper: config.CredHelpers[registry], Registries: []string{pattern}})
+	}
+	if config.CredsStore != "" {
+		providers = append(providers, Helper{Helper: config.CredsStore})
+	}
+	return providers, nil
+}
+
+// InstalledCloudHelpers returns the helpers of CloudHelpers found in the PATH
+func InstalledCloudHelpers() []Provider {
+	providers := make([]Provider, 0)
+	for _, cloud := range CloudHelpers {
+		if _, err := exec.LookPath(HelperPrefix + cloud.Helper); err == nil {
+			providers = append(providers, Helper{Helper: cloud.Helper, Registries: []string{cloud.Pattern}})
+		}
+	}
+	return providers
+}
@@ -0,0 +1,219 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package credential
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The cache keys of the responses of the kubelet credential provider plugins
+const (
+	cacheKeyImage    = "Image"
+	cacheKeyRegistry = "Registry"
+	cacheKeyGlobal   = "Global"
+)
+
+// defaultPluginAPIVersion is the api version of the plugins without one in the config
+const defaultPluginAPIVersion = "credentialprovider.kubelet.k8s.io/v1"
+
+// PluginConfig is the CredentialProviderConfig of the kubelet, the same file configures the plugins for the kubelet
+// and for the helper images
+type PluginConfig struct {
+	Providers []PluginProvider `yaml:"providers"`
+}
+
+// PluginProvider is a plugin of the config
+type PluginProvider struct {
+	Name                 string   `yaml:"name"`
+	MatchImages          []string `yaml:"matchImages"`
+	DefaultCacheDuration string   `yaml:"defaultCacheDuration"`
+	APIVersion           string   `yaml:"apiVersion"`
+	Args                 []string `yaml:"args"`
+	Env                  []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+}
+
+// LoadPluginConfig reads the config of the plugins, yaml or json
+func LoadPluginConfig(file string) (PluginConfig, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return PluginConfig{}, err
+	}
+	var config PluginConfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return PluginConfig{}, fmt.Errorf("illegal credential provider config %s, %v", file, err)
+	}
+	for _, provider := range config.Providers {
+		if provider.Name == "" || len(provider.MatchImages) == 0 {
+			return PluginConfig{}, fmt.Errorf("illegal credential provider config %s, the name and the matchImages "+
+				"of the providers are required", file)
+		}
+	}
+	return config, nil
+}
+
+// Plugin gets the credentials by the kubelet credential provider plugin, such as ecr-credential-provider, the
+// CredentialProviderRequest of the image is written to its stdin and the CredentialProviderResponse read from its
+// stdout. The responses are cached by their cache key type and duration
+type Plugin struct {
+	Provider PluginProvider
+	// BinDir is the directory of the plugin binaries
+	BinDir string
+}
+
+func (p Plugin) Name() string {
+	return p.Provider.Name
+}
+
+// pluginCache is the cache of the responses of the plugins by the plugin and the cache key
+var pluginCache = struct {
+	sync.Mutex
+	entries map[string]pluginCacheEntry
+}{entries: make(map[string]pluginCacheEntry)}
+
+type pluginCacheEntry struct {
+	auth    map[string]Credential
+	expires time.Time
+}
+
+func (p Plugin) Get(ctx context.Context, image string) (Credential, bool, error) {
+	matched := false
+	for _, pattern := range p.Provider.MatchImages {
+		matched = matched || MatchImage(pattern, image)
+	}
+	if !matched {
+		return Credential{}, false, nil
+	}
+	for _, key := range []string{image, Registry(image), ""} {
+		if auth, ok := p.cached(key); ok {
+			credential, ok := pickAuth(auth, image)
+			return credential, ok, nil
+		}
+	}
+	auth, cacheKeyType, cacheDuration, err := p.exec(ctx, image)
+	if err != nil {
+		return Credential{}, false, err
+	}
+	if cacheDuration > 0 {
+		key := image
+		switch cacheKeyType {
+		case cacheKeyRegistry:
+			key = Registry(image)
+		case cacheKeyGlobal:
+			key = ""
+		}
+		pluginCache.Lock()
+		pluginCache.entries[p.Name()+"\x00"+key] = pluginCacheEntry{auth: auth, expires: time.Now().Add(cacheDuration)}
+		pluginCache.Unlock()
+	}
+	credential, ok := pickAuth(auth, image)
+	return credential, ok, nil
+}
+
+func (p Plugin) cached(key string) (map[string]Credential, bool) {
+	pluginCache.Lock()
+	defer pluginCache.Unlock()
+	entry, ok := pluginCache.entries[p.Name()+"\x00"+key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.auth, true
+}
+
+// exec runs the plugin and returns the credentials by the patterns, the cache key type and the cache duration
+func (p Plugin) exec(ctx context.Context, image string) (map[string]Credential, string, time.Duration, error) {
+	apiVersion := p.Provider.APIVersion
+	if apiVersion == "" {
+		apiVersion = defaultPluginAPIVersion
+	}
+	request, _ := json.Marshal(map[string]string{
+		"apiVersion": apiVersion,
+		"kind":       "CredentialProviderRequest",
+		"image":      image,
+	})
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path.Join(p.BinDir, p.Provider.Name), p.Provider.Args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(request), &stdout, &stderr
+	cmd.Env = os.Environ()
+	for _, env := range p.Provider.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	if err := cmd.Run(); err != nil {
+		return nil, "", 0, fmt.Errorf("%v, %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var response struct {
+		CacheKeyType  string `json:"cacheKeyType"`
+		CacheDuration string `json:"cacheDuration"`
+		Auth          map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auth"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, "", 0, fmt.Errorf("illegal response of %s, %v", p.Name(), err)
+	}
+	auth := make(map[string]Credential, len(response.Auth))
+	for pattern, credential := range response.Auth {
+		auth[pattern] = Credential{Username: credential.Username, Password: credential.Password}
+	}
+	cacheDuration := response.CacheDuration
+	if cacheDuration == "" {
+		cacheDuration = p.Provider.DefaultCacheDuration
+	}
+	var duration time.Duration
+	if cacheDuration != "" {
+		var err error
+		if duration, err = time.ParseDuration(cacheDuration); err != nil {
+			return nil, "", 0, fmt.Errorf("illegal cache duration of %s, %v", p.Name(), err)
+		}
+	}
+	return auth, response.CacheKeyType, duration, nil
+}
+
+// pickAuth returns the credential of the longest pattern matching the image, the most specific one
+func pickAuth(auth map[string]Credential, image string) (Credential, bool) {
+	patterns := make([]string, 0, len(auth))
+	for pattern := range auth {
+		if MatchImage(pattern, image) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return Credential{}, false
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	return auth[patterns[0]], true
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ctrstate snapshots the facts of the container, the processes, the listening ports, the checksums of the
+// key files and the resource stats, before the experiment is injected and after it is reverted, so the diff shows
+// whether the container returned to the state before the chaos. The facts are read on the host from /proc and the
+// cgroups of the container, the container is not executed.
+package ctrstate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netstate"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/statefile"
+)
+
+// The sections of the snapshot compared line by line
+const (
+	SectionProcesses = "processes"
+	SectionPorts     = "ports"
+	SectionFiles     = "files"
+)
+
+// The resource stats of the snapshot
+const (
+	StatMemory    = "memoryBytes"
+	StatPids      = "pids"
+	StatProcesses = "processes"
+)
+
+// Missing is the checksum of the file missing
+const Missing = "missing"
+
+// procRoot is replaced by the tests
+var procRoot = "/proc"
+
+// Snapshot is the facts of the container at the time
+type Snapshot struct {
+	ContainerId string `json:"containerId"`
+	// Processes are the command lines of the processes, the pids are not compared since they change on restart
+	Processes []string `json:"processes"`
+	// Ports are the listening sockets, such as tcp 0.0.0.0:8080
+	Ports []string `json:"ports"`
+	// Files are the sha256 checksums of the files by the path in the container
+	Files map[string]string `json:"files,omitempty"`
+	// Stats are the resource stats, the ones failed to read are absent
+	Stats map[string]int64 `json:"stats,omitempty"`
+	Time  time.Time        `json:"time"`
+}
+
+// Take snapshots the container, the files are the absolute paths in the container whose checksums are compared
+func Take(ctx context.Context, client container.Container, containerId string, files []string) (Snapshot, error) {
+	pid, err, _ := client.GetPidById(ctx, containerId)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	processes, err, _ := container.ListProcesses(ctx, client, containerId)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot := Snapshot{ContainerId: containerId, Processes: make([]string, 0, len(processes)),
+		Stats: make(map[string]int64), Time: time.Now()}
+	for _, process := range processes {
+		snapshot.Processes = append(snapshot.Processes, strings.TrimSpace(process.Comm+" "+process.Cmdline))
+	}
+	sort.Strings(snapshot.Processes)
+	snapshot.Stats[StatProcesses] = int64(len(processes))
+	if snapshot.Ports, err = ListeningPorts(pid); err != nil {
+		return Snapshot{}, err
+	}
+	if len(files) > 0 {
+		snapshot.Files = make(map[string]string, len(files))
+		for _, file := range files {
+			if snapshot.Files[file], err = checksum(path.Join(procRoot, strconv.Itoa(int(pid)), "root", file)); err != nil {
+				return Snapshot{}, err
+			}
+		}
+	}
+	if cgroupPath, err, _ := client.GetCgroupPath(ctx, containerId); err == nil {
+		if memory, err := container.ReadCgroupCounter(cgroupPath, "memory", "memory.current",
+			"memory.usage_in_bytes"); err == nil {
+			snapshot.Stats[StatMemory] = memory
+		}
+		if pids, err := container.ReadCgroupCounter(cgroupPath, "pids", "pids.current"); err == nil {
+			snapshot.Stats[StatPids] = pids
+		}
+	}
+	return snapshot, nil
+}
+
+// checksum returns the sha256 of the file, Missing if it does not exist
+func checksum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Missing, nil
+		}
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("read %s failed, %v", file, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// socketTables are the socket tables of the network namespace and the states of the listening sockets, the unbound
+// udp sockets are in the close state
+var socketTables = []struct {
+	protocol, file, state string
+}{
+	{"tcp", "tcp", "0A"},
+	{"tcp", "tcp6", "0A"},
+	{"udp", "udp", "07"},
+	{"udp", "udp6", "07"},
+}
+
+// ListeningPorts returns the listening sockets of the network namespace of the process sorted, the tables missing,
+// such as tcp6 with ipv6 disabled, are skipped
+func ListeningPorts(pid int32) ([]string, error) {
+	ports := make([]string, 0)
+	for _, table := range socketTables {
+		content, err := os.ReadFile(path.Join(procRoot, strconv.Itoa(int(pid)), "net", table.file))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		ports = append(ports, ParseSockets(table.protocol, table.state, string(content))...)
+	}
+	sort.Strings(ports)
+	return ports, nil
+}
+
+// ParseSockets returns the local addresses of the sockets of the table in the state, such as tcp 127.0.0.1:8080
+func ParseSockets(protocol, state, content string) []string {
+	sockets := make([]string, 0)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != state {
+			continue
+		}
+		address, port, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		ip, err := parseHexIP(address)
+		if err != nil {
+			continue
+		}
+		number, err := strconv.ParseUint(port, 16, 16)
+		if err != nil {
+			continue
+		}
+		sockets = append(sockets, fmt.Sprintf("%s %s", protocol, net.JoinHostPort(ip.String(), strconv.Itoa(int(number)))))
+	}
+	return sockets
+}
+
+// parseHexIP parses the address of the socket tables, the 32-bit words are in the host byte order, little endian
+func parseHexIP(value string) (net.IP, error) {
+	raw, err := hex.DecodeString(value)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, fmt.Errorf("illegal address %s", value)
+	}
+	ip := make(net.IP, len(raw))
+	for word := 0; word < len(raw); word += 4 {
+		for i := 0; i < 4; i++ {
+			ip[word+i] = raw[word+3-i]
+		}
+	}
+	return ip, nil
+}
+
+// Stat is the change of a resource stat
+type Stat struct {
+	Name   string `json:"name"`
+	Before int64  `json:"before"`
+	After  int64  `json:"after"`
+}
+
+// Diff is the difference of the snapshots after the revert
+type Diff struct {
+	// Restored is true if the processes, the ports and the files match, the stats are not compared since they
+	// fluctuate with the workload
+	Restored bool               `json:"restored"`
+	Residue  []netstate.Residue `json:"residue,omitempty"`
+	Stats    []Stat             `json:"stats,omitempty"`
+	Before   time.Time          `json:"before"`
+	After    time.Time          `json:"after"`
+}
+
+// Compare returns the diff of the snapshot after the revert against the one before the injection
+func Compare(before, after Snapshot) Diff {
+	residue := netstate.Diff(before.sections(), after.sections())
+	diff := Diff{Restored: len(residue) == 0, Residue: residue, Before: before.Time, After: after.Time}
+	names := make([]string, 0, len(before.Stats))
+	for name := range before.Stats {
+		if _, ok := after.Stats[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if before.Stats[name] != after.Stats[name] {
+			diff.Stats = append(diff.Stats, Stat{Name: name, Before: before.Stats[name], After: after.Stats[name]})
+		}
+	}
+	return diff
+}
+
+func (s Snapshot) sections() netstate.Snapshot {
+	files := make([]string, 0, len(s.Files))
+	for file, sum := range s.Files {
+		files = append(files, file+" "+sum)
+	}
+	return netstate.Snapshot{
+		SectionProcesses: s.Processes,
+		SectionPorts:     s.Ports,
+		SectionFiles:     files,
+	}
+}
+
+// Save saves the snapshot of the experiment under the state dir
+func Save(stateDir, uid string, snapshot Snapshot) error {
+	return statefile.Save(stateFile(stateDir, uid), snapshot)
+}
+
+// Load returns the snapshot of the experiment, the error is os.ErrNotExist if it is not saved
+func Load(stateDir, uid string) (Snapshot, error) {
+	var snapshot Snapshot
+	if err := statefile.Load(stateFile(stateDir, uid), &snapshot); err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// Remove removes the snapshot of the experiment, it succeeds if the snapshot is not saved
+func Remove(stateDir, uid string) error {
+	return statefile.Remove(stateFile(stateDir, uid))
+}
+
+func stateFile(stateDir, uid string) string {
+	return statefile.Path(stateDir, "ctrstate", uid)
+}
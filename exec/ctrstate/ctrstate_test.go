@@ -0,0 +1,111 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrstate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+const tcpTable = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1 0 100 0 0 10 0
+   1: 0100007F:1F90 0200007F:D431 01 00000000:00000000 00:00000000 00000000     0        0 2 1 0 20 4 30 10 -1
+`
+
+const tcp6Table = `  sl  local_address                         remote_address                        st
+   0: 00000000000000000000000000000000:0050 00000000000000000000000000000000:0000 0A
+   1: 0000000000000000FFFF00000100007F:0051 00000000000000000000000000000000:0000 0A
+`
+
+func TestParseSockets(t *testing.T) {
+	if sockets := ParseSockets("tcp", "0A", tcpTable); !reflect.DeepEqual(sockets, []string{"tcp 127.0.0.1:8080"}) {
+		t.Errorf("unexpected ipv4 sockets %v", sockets)
+	}
+	expected := []string{"tcp [::]:80", "tcp 127.0.0.1:81"}
+	if sockets := ParseSockets("tcp", "0A", tcp6Table); !reflect.DeepEqual(sockets, expected) {
+		t.Errorf("expected %v, got %v", expected, sockets)
+	}
+}
+
+func TestTakeAndCompare(t *testing.T) {
+	procRoot = t.TempDir()
+	defer func() { procRoot = "/proc" }()
+	for file, content := range map[string]string{"net/tcp": tcpTable, "root/etc/hosts": "127.0.0.1 localhost\n"} {
+		file = path.Join(procRoot, "42", file)
+		if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	client.Pids["c1"] = 42
+	ps := "PID COMMAND COMMAND\n1 nginx nginx: master\n7 nginx nginx: worker\n"
+	client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+		return ps, nil
+	}
+	files := []string{"/etc/hosts", "/etc/resolv.conf"}
+	before, err := Take(context.Background(), client, "c1", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before.Ports, []string{"tcp 127.0.0.1:8080"}) || len(before.Processes) != 2 ||
+		before.Files["/etc/resolv.conf"] != Missing || before.Stats[StatProcesses] != 2 {
+		t.Errorf("unexpected snapshot %+v", before)
+	}
+	stateDir := t.TempDir()
+	if err := Save(stateDir, "uid1", before); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := Load(stateDir, "uid1"); err != nil || !reflect.DeepEqual(loaded.Files, before.Files) {
+		t.Errorf("expected the snapshot loaded, got %+v, %v", loaded, err)
+	}
+	if diff := Compare(before, before); !diff.Restored || len(diff.Stats) != 0 {
+		t.Errorf("expected restored, got %+v", diff)
+	}
+
+	ps += "9 sleep sleep 3600\n"
+	if err := os.WriteFile(path.Join(procRoot, "42", "root/etc/hosts"), []byte("10.0.0.1 db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	after, err := Take(context.Background(), client, "c1", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := Compare(before, after)
+	if diff.Restored || len(diff.Residue) != 2 || diff.Residue[0].Section != SectionFiles ||
+		diff.Residue[1].Section != SectionProcesses || !reflect.DeepEqual(diff.Residue[1].Added, []string{"sleep sleep 3600"}) {
+		t.Errorf("unexpected residue %+v", diff.Residue)
+	}
+	if !reflect.DeepEqual(diff.Stats, []Stat{{Name: StatProcesses, Before: 2, After: 3}}) {
+		t.Errorf("unexpected stats %+v", diff.Stats)
+	}
+	if err := Remove(stateDir, "uid1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(stateDir, "uid1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the snapshot removed, got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+)
+
+// OpenDeployments returns the deployments of the toolkit files copied into the containers, it is replaced in the tests
+var OpenDeployments = func() *journal.Deployments {
+	return journal.OpenDeployments("")
+}
+
+// resolveBladeDir returns the blade directory of the experiment in the container, the flag first, then the directory
+// recorded by the deployment of the container on the destroy, so the experiment is destroyed by the blade creating it,
+// then the BladeDirEnv environment and the DefaultBladeDir
+func resolveBladeDir(ctx context.Context, containerId string, expModel *spec.ExpModel) (string, error) {
+	dir := expModel.ActionFlags[ChaosBladeDirFlag.Name]
+	if _, ok := spec.IsDestroy(ctx); ok && dir == "" {
+		if deployment, ok, err := OpenDeployments().Get(containerId); err != nil {
+			log.Warnf(ctx, "get the deployment of container %s failed, %v", containerId, err)
+		} else if ok {
+			dir = deployment.BladeDir
+		}
+	}
+	if dir == "" {
+		dir = os.Getenv(BladeDirEnv)
+	}
+	if dir == "" {
+		return DefaultBladeDir, nil
+	}
+	if !path.IsAbs(dir) || path.Clean(dir) != dir || path.Dir(dir) == "/" {
+		return "", fmt.Errorf("the directory must be an absolute and clean path under a parent directory, such as /tmp/chaosblade")
+	}
+	return dir, nil
+}
+
+// checkBladeDir checks the parent of the blade directory is writable in the container before the deployment
+func checkBladeDir(ctx context.Context, client container.Container, containerId, bladeDir string) error {
+	parent := nsexec.Quote(path.Dir(bladeDir))
+	probe := nsexec.Quote(path.Join(path.Dir(bladeDir), ".chaosblade-probe"))
+	output, err := client.ExecContainer(ctx, containerId,
+		fmt.Sprintf("mkdir -p %s && touch %s && rm -f %s", parent, probe, probe))
+	if err != nil {
+		return fmt.Errorf("%s is not writable in the container, %v, %s", path.Dir(bladeDir), err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// checkDeployTarget refuses the blade dir which would remove the files not deployed by chaosblade, the deploy removes
+// the blade dir before moving the release extracted into it, and the destroy removes it again. An existing dir is
+// replaced only if it holds the blade or the version marker of an earlier deploy, and the blade dir must not be the
+// dir the release is extracted to, or contain it
+func checkDeployTarget(ctx context.Context, client container.Container, containerId, bladeDir, extractDirName string) error {
+	extractPath := path.Join(path.Dir(bladeDir), extractDirName)
+	if bladeDir == extractPath || strings.HasPrefix(extractPath, bladeDir+"/") {
+		return fmt.Errorf("the blade directory %s must not be the extract path %s of the release", bladeDir, extractPath)
+	}
+	dir := nsexec.Quote(bladeDir)
+	output, err := client.ExecContainer(ctx, containerId, fmt.Sprintf(
+		"if [ ! -e %s ]; then echo absent; elif [ -e %s ] || [ -e %s ]; then echo chaosblade; else echo foreign; fi",
+		dir, nsexec.Quote(path.Join(bladeDir, "blade")), nsexec.Quote(path.Join(bladeDir, ".version"))))
+	if err != nil {
+		return fmt.Errorf("check the blade directory %s failed, %v, %s", bladeDir, err, strings.TrimSpace(output))
+	}
+	if strings.TrimSpace(output) == "foreign" {
+		return fmt.Errorf("the blade directory %s exists without the chaosblade tool, it is refused to be replaced", bladeDir)
+	}
+	return nil
+}
+
+// trackDeployment records the blade deployed, or the toolkit mounted, into the container for the experiment, or
+// attaches the experiment to the blade deployed by another one, the blade in the image is never tracked, the failure
+// is logged only. The archive copied is removed by the client after the extraction, so it is not tracked
+func trackDeployment(ctx context.Context, uid, containerId, bladeDir string, deployed bool, mount string,
+	expModel *spec.ExpModel) {
+	deployments := OpenDeployments()
+	if !deployed {
+		if _, err := deployments.Attach(containerId, uid); err != nil {
+			log.Warnf(ctx, "attach experiment %s to the deployment of container %s failed, %v", uid, containerId, err)
+		}
+		return
+	}
+	deployment := newDeployment(containerId, uid, bladeDir, expModel)
+	deployment.Paths, deployment.Mount = []string{bladeDir}, mount
+	if err := deployments.Add(deployment); err != nil {
+		log.Warnf(ctx, "record the deployment of container %s failed, %v", containerId, err)
+	}
+}
+
+// newDeployment returns the deployment of the container for the experiment without the files
+func newDeployment(containerId, uid, bladeDir string, expModel *spec.ExpModel) journal.Deployment {
+	flags := make(map[string]string, len(expModel.ActionFlags))
+	for k, v := range expModel.ActionFlags {
+		flags[k] = v
+	}
+	retain, _ := strconv.ParseBool(expModel.ActionFlags[ChaosBladeRetainFlag.Name])
+	return journal.Deployment{
+		ContainerId: containerId,
+		BladeDir:    bladeDir,
+		Uids:        []string{uid},
+		Flags:       flags,
+		Retain:      retain,
+	}
+}
+
+// releaseDeployment detaches the experiment from the deployment of the container, the files are removed if no
+// experiment uses them anymore and they are not retained, the failure is logged only
+func (r *RunCmdInContainerExecutorByCP) releaseDeployment(ctx context.Context, uid, containerId string, expModel *spec.ExpModel) {
+	deployment, released, err := OpenDeployments().Release(containerId, uid)
+	if err != nil {
+		log.Warnf(ctx, "release experiment %s from the deployment of container %s failed, %v", uid, containerId, err)
+		return
+	}
+	if !released {
+		return
+	}
+	if retain, _ := strconv.ParseBool(expModel.ActionFlags[ChaosBladeRetainFlag.Name]); retain || deployment.Retain {
+		log.Infof(ctx, "the chaosblade tool %v in container %s is retained", deployment.Paths, containerId)
+		return
+	}
+	if err := removeDeployedFiles(ctx, r.Client, deployment); err != nil {
+		log.Warnf(ctx, "remove the chaosblade tool %v in container %s failed, %v", deployment.Paths, containerId, err)
+	}
+}
+
+// RemoveDeployment removes the files of the deployment, it succeeds if the container is removed with the files
+func RemoveDeployment(ctx context.Context, deployment journal.Deployment) error {
+	client, err := GetClientByRuntime(&spec.ExpModel{ActionFlags: deployment.Flags})
+	if err != nil {
+		return err
+	}
+	if deployment.Origin != nil {
+		// the origin container is restored even if the recreated one is removed
+		return removeDeployedFiles(ctx, client, deployment)
+	}
+	if _, err, _ := client.GetContainerById(ctx, deployment.ContainerId); err != nil {
+		log.Infof(ctx, "the container %s of the deployment is not found, %v", deployment.ContainerId, err)
+		if deployment.Mount != "" {
+			return unmountChaosBlade(ctx, nil, deployment.ContainerId, deployment.Mount)
+		}
+		return nil
+	}
+	return removeDeployedFiles(ctx, client, deployment)
+}
+
+// removeDeployedFiles unmounts the toolkit mounted first, and removes the files in the container, or restores the
+// origin container of the recreated one
+func removeDeployedFiles(ctx context.Context, client container.Container, deployment journal.Deployment) error {
+	if deployment.Origin != nil {
+		return restoreOrigin(ctx, client, deployment)
+	}
+	if deployment.Mount != "" {
+		if err := unmountChaosBlade(ctx, client, deployment.ContainerId, deployment.Mount); err != nil {
+			return err
+		}
+	}
+	paths := make([]string, 0, len(deployment.Paths))
+	for _, p := range deployment.Paths {
+		paths = append(paths, nsexec.Quote(p))
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	output, err := client.ExecContainer(ctx, deployment.ContainerId, fmt.Sprintf("rm -rf %s", strings.Join(paths, " ")))
+	if err != nil {
+		return fmt.Errorf("%v, %s", err, strings.TrimSpace(output))
+	}
+	log.Infof(ctx, "the chaosblade tool %v in container %s is removed", deployment.Paths, deployment.ContainerId)
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/bundle"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+)
+
+// layeredImageRepo is the repository of the images with the chaosblade tool layered in
+const layeredImageRepo = "chaosblade-layered"
+
+// recreatedContainer returns the container recreated in place of the origin container, so the experiments of the
+// origin container id run in the recreated one
+func recreatedContainer(ctx context.Context, originId string) (string, bool) {
+	if originId == "" {
+		return "", false
+	}
+	deployment, ok, err := OpenDeployments().FindByOrigin(originId)
+	if err != nil {
+		log.Warnf(ctx, "find the container recreated from %s failed, %v", originId, err)
+		return "", false
+	}
+	if ok {
+		log.Infof(ctx, "container %s is recreated as %s", originId, deployment.ContainerId)
+	}
+	return deployment.ContainerId, ok
+}
+
+// recreateWithChaosBlade recreates the container from its image with the chaosblade tool layered in, for the
+// containers whose filesystem is not writable, it returns the recreated container. The container recreated before is
+// reused, the experiment is attached to it
+func (r *RunCmdInContainerExecutorByCP) recreateWithChaosBlade(ctx context.Context, uid, containerId string,
+	entry bundle.Entry, bladeDir string, expModel *spec.ExpModel) (string, error) {
+	deployments := OpenDeployments()
+	if deployment, ok, err := deployments.Get(containerId); err == nil && ok && deployment.Origin != nil {
+		if _, err := deployments.Attach(containerId, uid); err != nil {
+			log.Warnf(ctx, "attach experiment %s to the deployment of container %s failed, %v", uid, containerId, err)
+		}
+		return containerId, nil
+	}
+	recreator, ok := container.AsRecreator(r.Client)
+	if !ok {
+		return "", fmt.Errorf("the runtime can't recreate container %s, only docker supports it", containerId)
+	}
+	image := layeredImage(containerId, entry.DirName)
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(bundle.Layer(writer, entry, bladeDir))
+	}()
+	recreatedId, running, err := recreator.Recreate(ctx, containerId, reader, image)
+	// the layer is not read up if the recreation fails
+	reader.Close()
+	if err != nil {
+		return "", err
+	}
+	deployment := newDeployment(recreatedId, uid, bladeDir, expModel)
+	deployment.Origin = &journal.Origin{ContainerId: containerId, Image: image, Stopped: !running}
+	if err := deployments.Add(deployment); err != nil {
+		log.Warnf(ctx, "record the recreation of container %s failed, %v", containerId, err)
+	}
+	return recreatedId, nil
+}
+
+// restoreOrigin removes the recreated container and restores the origin container in the state it was recreated in
+func restoreOrigin(ctx context.Context, client container.Container, deployment journal.Deployment) error {
+	recreator, ok := container.AsRecreator(client)
+	if !ok {
+		return fmt.Errorf("the runtime can't restore container %s", deployment.Origin.ContainerId)
+	}
+	if err := recreator.RestoreRecreated(ctx, deployment.ContainerId, deployment.Origin.ContainerId,
+		deployment.Origin.Image, !deployment.Origin.Stopped); err != nil {
+		return err
+	}
+	log.Infof(ctx, "container %s is restored in place of %s", deployment.Origin.ContainerId, deployment.ContainerId)
+	return nil
+}
+
+// layeredImage returns the image of the container with the chaosblade tool layered in
+func layeredImage(containerId, dirName string) string {
+	if len(containerId) > 12 {
+		containerId = containerId[:12]
+	}
+	return fmt.Sprintf("%s:%s-%s", layeredImageRepo, containerId, dirName)
+}
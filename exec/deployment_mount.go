@@ -0,0 +1,113 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+const (
+	InjectCopy  = "copy"
+	InjectMount = "mount"
+	InjectImage = "image"
+)
+
+// MountRunDir is the host directory of the writable layers of the toolkit mounted into the containers
+const MountRunDir = "/run/chaosblade/mounts"
+
+// hostMountRunDir is the MountRunDir reached from the mount namespace of the agent, the layers on the overlay of the
+// agent container would be refused by the overlay, it is replaced in the tests
+var hostMountRunDir = path.Join("/proc/1/root", MountRunDir)
+
+// mountChaosBlade mounts the toolkit extracted on the node to the blade dir of the container by an overlay, so the
+// toolkit is shared by the containers without copying, and the blade data of the container is kept in its own upper
+// directory on the host. The overlay is attached in the mount namespace of the container only, so it doesn't depend on
+// the mount propagation of the rootfs, and the host mount table is never changed. It returns the mount target, or false
+// if the mount failed, such as on the kernels before 5.2, then the toolkit should be copied instead. The error is
+// returned if the overlay mounted before is still mounted in the container, its layers are kept for it
+func (r *RunCmdInContainerExecutorByCP) mountChaosBlade(ctx context.Context, containerId, toolkitDir, bladeDir string) (string, bool, error) {
+	pid, err, _ := r.Client.GetPidById(ctx, containerId)
+	if err != nil {
+		log.Warnf(ctx, "get the pid of container %s failed, copy the chaosblade tool instead, %v", containerId, err)
+		return "", false, nil
+	}
+	runDir := path.Join(hostMountRunDir, containerId)
+	upper, work := path.Join(runDir, "upper"), path.Join(runDir, "work")
+	// the layers of the mount deployed before are replaced, the overlay on them is unmounted from the container first,
+	// the layers removed under an overlay still mounted would break the blade running in it
+	if err := releaseOverlay(pid, runDir, bladeDir); err != nil {
+		return "", false, fmt.Errorf("the chaosblade tool mounted before in container %s is not released, %v",
+			containerId, err)
+	}
+	err = os.RemoveAll(runDir)
+	if err == nil {
+		err = os.MkdirAll(upper, 0755)
+	}
+	if err == nil {
+		err = os.MkdirAll(work, 0755)
+	}
+	if err == nil {
+		err = attachOverlay(pid, toolkitDir, upper, work, bladeDir)
+	}
+	if err != nil {
+		log.Warnf(ctx, "mount the chaosblade tool to container %s failed, copy it instead, %v", containerId, err)
+		os.RemoveAll(runDir)
+		return "", false, nil
+	}
+	return bladeDir, true, nil
+}
+
+// releaseOverlay unmounts the overlay on the layers of the run dir from the blade dir of the container, it fails if the
+// overlay is still mounted in the container after, such as on another blade dir. Nothing is done if the run dir is
+// missing
+func releaseOverlay(pid int32, runDir, bladeDir string) error {
+	if _, err := os.Stat(runDir); os.IsNotExist(err) {
+		return nil
+	}
+	if err := detachOverlay(pid, bladeDir); err != nil {
+		return err
+	}
+	mounted, err := overlayMounted(pid, path.Join(runDir, "upper"))
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return fmt.Errorf("the overlay on %s is still mounted", runDir)
+	}
+	return nil
+}
+
+// unmountChaosBlade unmounts the toolkit from the blade dir of the container, and removes the upper directory of the
+// container. The mount is gone with the container, so the unmount is skipped if the container is not running
+func unmountChaosBlade(ctx context.Context, client container.Container, containerId, target string) error {
+	if client != nil {
+		pid, err, _ := client.GetPidById(ctx, containerId)
+		if err != nil {
+			log.Infof(ctx, "get the pid of container %s failed, skip the unmount of %s, %v", containerId, target, err)
+		} else if err := detachOverlay(pid, target); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(path.Join(hostMountRunDir, containerId))
+}
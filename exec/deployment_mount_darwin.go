@@ -0,0 +1,32 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import "fmt"
+
+// attachOverlay is supported on linux only, the toolkit is copied instead
+var attachOverlay = func(pid int32, lower, upper, work, target string) error {
+	return fmt.Errorf("the toolkit is mounted on linux only")
+}
+
+var detachOverlay = func(pid int32, target string) error {
+	return fmt.Errorf("the toolkit is mounted on linux only")
+}
+
+var overlayMounted = func(pid int32, upper string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The commands of fsconfig, they are missing in x/sys
+const (
+	fsconfigSetString = 1
+	fsconfigCmdCreate = 6
+)
+
+// attachOverlay creates the overlay of the toolkit detached by the mount api of linux 5.2, so it's never in the mount
+// table of the host or the agent, and moves it to the target in the mount namespace of the process. The target missing
+// is created, and removed if the move failed. The paths of the layers are resolved in the mount namespace of the agent
+var attachOverlay = func(pid int32, lower, upper, work, target string) error {
+	fsfd, err := unix.Fsopen("overlay", unix.FSOPEN_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("fsopen overlay failed, %v", err)
+	}
+	defer unix.Close(fsfd)
+	for _, option := range [][2]string{{"source", "chaosblade"}, {"lowerdir", lower}, {"upperdir", upper},
+		{"workdir", work}} {
+		if err := fsconfig(fsfd, fsconfigSetString, option[0], option[1]); err != nil {
+			return fmt.Errorf("set %s of the overlay to %s failed, %v", option[0], option[1], err)
+		}
+	}
+	if err := fsconfig(fsfd, fsconfigCmdCreate, "", ""); err != nil {
+		return fmt.Errorf("create the overlay failed, %v", err)
+	}
+	mountfd, err := unix.Fsmount(fsfd, unix.FSMOUNT_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("fsmount the overlay failed, %v", err)
+	}
+	defer unix.Close(mountfd)
+	return inMountns(pid, func() error {
+		created := false
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			if err := os.Mkdir(target, 0755); err != nil {
+				return err
+			}
+			created = true
+		}
+		if err := unix.MoveMount(mountfd, "", unix.AT_FDCWD, target, unix.MOVE_MOUNT_F_EMPTY_PATH); err != nil {
+			if created {
+				os.Remove(target)
+			}
+			return fmt.Errorf("move the overlay to %s failed, %v", target, err)
+		}
+		return nil
+	})
+}
+
+// detachOverlay unmounts the target in the mount namespace of the process lazily, the target busy is detached
+var detachOverlay = func(pid int32, target string) error {
+	return inMountns(pid, func() error {
+		if err := unix.Unmount(target, unix.MNT_DETACH); err != nil && err != unix.EINVAL && err != unix.ENOENT {
+			return fmt.Errorf("unmount %s failed, %v", target, err)
+		}
+		return nil
+	})
+}
+
+// overlayMounted returns whether an overlay on the upper directory is in the mount table of the process, the upper
+// directory is shown in the options of the overlay as it is given to attachOverlay
+var overlayMounted = func(pid int32, upper string) (bool, error) {
+	mountinfo, err := os.ReadFile(fmt.Sprintf("/proc/%d/mountinfo", pid))
+	if err != nil {
+		return false, fmt.Errorf("read the mount table of process %d failed, %v", pid, err)
+	}
+	for _, line := range strings.Split(string(mountinfo), "\n") {
+		// the fields after the separator are the filesystem type, the source and the super options
+		_, fields, found := strings.Cut(line, " - ")
+		if !found {
+			continue
+		}
+		parts := strings.Fields(fields)
+		if len(parts) < 3 || parts[0] != "overlay" {
+			continue
+		}
+		for _, option := range strings.Split(parts[2], ",") {
+			if option == "upperdir="+upper {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// inMountns runs the function on a locked thread in the mount namespace of the process, the paths are resolved from
+// its root. The thread unshares its filesystem attributes from the agent to join the namespace, so it's never unlocked
+// and the runtime terminates it after the function returns
+func inMountns(pid int32, fn func() error) error {
+	nsfd, err := unix.Open(fmt.Sprintf("/proc/%d/ns/mnt", pid), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("open the mount namespace of process %d failed, %v", pid, err)
+	}
+	defer unix.Close(nsfd)
+	errs := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := unix.Unshare(unix.CLONE_FS); err != nil {
+			errs <- fmt.Errorf("unshare the filesystem attributes failed, %v", err)
+			return
+		}
+		if err := unix.Setns(nsfd, unix.CLONE_NEWNS); err != nil {
+			errs <- fmt.Errorf("enter the mount namespace of process %d failed, %v", pid, err)
+			return
+		}
+		errs <- fn()
+	}()
+	return <-errs
+}
+
+func fsconfig(fd int, cmd uint, key, value string) error {
+	var keyPtr, valuePtr *byte
+	var err error
+	if key != "" {
+		if keyPtr, err = unix.BytePtrFromString(key); err != nil {
+			return err
+		}
+	}
+	if value != "" {
+		if valuePtr, err = unix.BytePtrFromString(value); err != nil {
+			return err
+		}
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_FSCONFIG, uintptr(fd), uintptr(cmd), uintptr(unsafe.Pointer(keyPtr)),
+		uintptr(unsafe.Pointer(valuePtr)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestAttachOverlay(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("the overlay is mounted by root only")
+	}
+	dir := t.TempDir()
+	lower, upper, work, target := path.Join(dir, "lower"), path.Join(dir, "upper"), path.Join(dir, "work"),
+		path.Join(dir, "target")
+	for _, d := range []string{lower, upper, work} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path.Join(lower, "blade"), []byte("#!/bin/sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the mount namespace of the test process is the one of the container
+	pid := int32(os.Getpid())
+	if err := attachOverlay(pid, lower, upper, work, target); err != nil {
+		t.Skipf("the detached overlay is unsupported, %v", err)
+	}
+	if err := os.WriteFile(path.Join(target, "chaosblade.dat"), []byte("data"), 0644); err != nil {
+		t.Errorf("expected the target writable, got %v", err)
+	}
+	if _, err := os.Stat(path.Join(target, "blade")); err != nil {
+		t.Errorf("expected the toolkit in the target, got %v", err)
+	}
+	if mounted, err := overlayMounted(pid, upper); err != nil || !mounted {
+		t.Errorf("expected the overlay found in the mount table, got %v, %v", mounted, err)
+	}
+	if err := detachOverlay(pid, target); err != nil {
+		t.Fatal(err)
+	}
+	if mounted, err := overlayMounted(pid, upper); err != nil || mounted {
+		t.Errorf("expected the overlay gone from the mount table, got %v, %v", mounted, err)
+	}
+	if _, err := os.Stat(path.Join(upper, "chaosblade.dat")); err != nil {
+		t.Errorf("expected the data kept in the upper directory, got %v", err)
+	}
+	if _, err := os.Stat(path.Join(target, "blade")); !os.IsNotExist(err) {
+		t.Errorf("expected the toolkit unmounted, got %v", err)
+	}
+	// no target is created if the overlay is refused
+	missing := path.Join(dir, "missing")
+	if err := attachOverlay(pid, path.Join(dir, "none"), upper, work, missing); err == nil {
+		t.Fatal("expected the overlay of the missing lower refused")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("expected no target created, got %v", err)
+	}
+}
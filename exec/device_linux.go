@@ -0,0 +1,265 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/dm"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+)
+
+// The flags of the device experiments
+const (
+	DevicePathFlag         = "path"
+	DeviceUpIntervalFlag   = "up-interval"
+	DeviceDownIntervalFlag = "down-interval"
+)
+
+// DeviceAllowlistFile is the allowlist of the device names eligible for the device experiments
+var DeviceAllowlistFile = dm.DefaultAllowlistFile
+
+type DeviceCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewDeviceCommandSpec() spec.ExpModelCommandSpec {
+	return &DeviceCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				newDeviceActionCommand(dm.ModeFlakey, "intermittent i/o errors of the volume device",
+					"The device-mapper device backing the volume fails all i/o for the down interval after every "+
+						"up interval, by swapping its table with the flakey target.",
+					`# The volume of /data in the container ee54f1e61c08 fails all i/o 2 seconds of every 7 seconds for 60 seconds
+blade create cri device flakey --path /data --up-interval 5 --down-interval 2 --timeout 60 --container-id ee54f1e61c08`),
+				newDeviceActionCommand(dm.ModeError, "i/o errors of the volume device",
+					"The device-mapper device backing the volume fails all i/o, by swapping its table with the error target.",
+					`# The volume of /data in the container ee54f1e61c08 fails all i/o for 30 seconds
+blade create cri device error --path /data --timeout 30 --container-id ee54f1e61c08`),
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*DeviceCommandModelSpec) Name() string {
+	return "device"
+}
+
+func (*DeviceCommandModelSpec) ShortDesc() string {
+	return "Volume device experiment"
+}
+
+func (*DeviceCommandModelSpec) LongDesc() string {
+	return "Volume device experiment, the i/o errors are injected beneath the filesystem of the volume. Only the " +
+		"linear device-mapper devices matched by the allowlist " + dm.DefaultAllowlistFile + " are eligible, and " +
+		"the devices containing the kubelet pod directories or mounted by the host are refused."
+}
+
+type DeviceActionCommand struct {
+	spec.BaseExpActionCommandSpec
+	name      string
+	shortDesc string
+}
+
+func newDeviceActionCommand(name, shortDesc, longDesc, example string) spec.ExpActionCommandSpec {
+	flags := []spec.ExpFlagSpec{
+		&spec.ExpFlag{
+			Name:     DevicePathFlag,
+			Desc:     "path in the container on the volume",
+			Required: true,
+		},
+	}
+	if name == dm.ModeFlakey {
+		flags = append(flags,
+			&spec.ExpFlag{
+				Name:    DeviceUpIntervalFlag,
+				Desc:    "duration the device is available, such as 500ms or 5s, the integer is seconds, default is 5s",
+				Default: "5",
+			},
+			&spec.ExpFlag{
+				Name:    DeviceDownIntervalFlag,
+				Desc:    "duration the device fails all i/o, not less than 1s, default is 2s",
+				Default: "2",
+			},
+		)
+	}
+	return &DeviceActionCommand{
+		BaseExpActionCommandSpec: spec.BaseExpActionCommandSpec{
+			ActionMatchers:   []spec.ExpFlagSpec{},
+			ActionFlags:      flags,
+			ActionExecutor:   &deviceActionExecutor{mode: name},
+			ActionLongDesc:   longDesc,
+			ActionExample:    example,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+		name:      name,
+		shortDesc: shortDesc,
+	}
+}
+
+func (d *DeviceActionCommand) Name() string {
+	return d.name
+}
+
+func (*DeviceActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (d *DeviceActionCommand) ShortDesc() string {
+	return d.shortDesc
+}
+
+func (d *DeviceActionCommand) LongDesc() string {
+	return d.ActionLongDesc
+}
+
+type deviceActionExecutor struct {
+	mode string
+}
+
+func (e *deviceActionExecutor) Name() string {
+	return "device"
+}
+
+func (e *deviceActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *deviceActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	stateDir := util.GetProgramPath()
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		if suid != "" {
+			uid = suid
+		}
+		if err := dm.Revert(ctx, stateDir, uid); err != nil {
+			log.Errorf(ctx, "revert the device experiment %s failed, %v", uid, err)
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "dmsetup", err)
+		}
+		return spec.ReturnSuccess(uid)
+	}
+	flags := model.ActionFlags
+	up, down, response := deviceIntervals(e.mode, flags)
+	if response != nil {
+		return response
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	containerInfo, response := GetContainer(ctx, client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name],
+		parseContainerLabelSelector(flags[ContainerLabelSelectorFlag.Name]))
+	if !response.Success {
+		return response
+	}
+	filePath := flags[DevicePathFlag]
+	mount, ok := containerInfo.MountOf(filePath)
+	if !ok {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, DevicePathFlag, filePath, "it is not on a volume")
+	}
+	device, err := mount.BackingDevice()
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, DevicePathFlag, filePath, err)
+	}
+	name, err := dm.DeviceName(device)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, DevicePathFlag, filePath, err)
+	}
+	patterns, err := dm.LoadAllowlist(DeviceAllowlistFile)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, DeviceAllowlistFile)
+	}
+	if !dm.Allowed(name, patterns) {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, DevicePathFlag, filePath,
+			fmt.Sprintf("the device %s is not in the allowlist %s", name, DeviceAllowlistFile))
+	}
+	segments, origin, err := dm.Table(ctx, name)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "dmsetup", err)
+	}
+	faults, err := dm.FaultTable(segments, e.mode, up, down)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, DevicePathFlag, filePath, err)
+	}
+	log.Infof(ctx, "inject %s into the device %s of the volume %s of container %s", e.mode, name, mount.HostPath,
+		containerInfo.ContainerId)
+	injection := dm.Injection{Uid: uid, Name: name, Mode: e.mode, Origin: origin}
+	if err := dm.Inject(ctx, stateDir, injection, faults); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "dmsetup", err)
+	}
+	return spec.ReturnSuccess(injection)
+}
+
+// deviceIntervals returns the up and down seconds of the flakey mode, the table of the flakey target takes the
+// whole seconds so the intervals are rounded up
+func deviceIntervals(mode string, flags map[string]string) (int, int, *spec.Response) {
+	if mode != dm.ModeFlakey {
+		return 0, 0, nil
+	}
+	up, response := durationFlag(flags, DeviceUpIntervalFlag, 5*time.Second, 0)
+	if response != nil {
+		return 0, 0, response
+	}
+	down, response := durationFlag(flags, DeviceDownIntervalFlag, 2*time.Second, time.Second)
+	if response != nil {
+		return 0, 0, response
+	}
+	return durations.Seconds(up), durations.Seconds(down), nil
+}
+
+func intervalFlag(flags map[string]string, name string, defaultValue, minimum int) (int, *spec.Response) {
+	if flags[name] == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(flags[name])
+	if err != nil || value < minimum {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, name, flags[name],
+			fmt.Sprintf("it must be an integer not less than %d", minimum))
+	}
+	return value, nil
+}
+
+// durationFlag returns the duration of the flag, a Go duration or the seconds, not less than the minimum
+func durationFlag(flags map[string]string, name string, defaultValue, minimum time.Duration) (time.Duration, *spec.Response) {
+	if flags[name] == "" {
+		return defaultValue, nil
+	}
+	value, err := durations.Parse(flags[name])
+	if err == nil && (durations.IsForever(flags[name]) || value < minimum) {
+		err = fmt.Errorf("it must be a duration not less than %s", minimum)
+	}
+	if err != nil {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, name, flags[name], err)
+	}
+	return value, nil
+}
+
+// lifetimeFlag returns the duration the experiment lasts, 0 if the flag is empty, 0 or forever, the experiment
+// persists until destroyed then
+func lifetimeFlag(flags map[string]string, name string) (time.Duration, *spec.Response) {
+	if durations.IsForever(flags[name]) {
+		return 0, nil
+	}
+	return durationFlag(flags, name, 0, 0)
+}
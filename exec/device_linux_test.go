@@ -0,0 +1,144 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/dm"
+)
+
+func runDevice(t *testing.T, client *mock.Container, mode string, flags map[string]string) *spec.Response {
+	t.Helper()
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	t.Cleanup(func() { NewClientFunc = nil })
+	flags[ContainerIdFlag.Name] = "c1"
+	model := &spec.ExpModel{Target: "device", ActionName: mode, ActionFlags: flags}
+	return (&deviceActionExecutor{mode: mode}).Exec("uid1", context.Background(), model)
+}
+
+func TestDeviceIllegal(t *testing.T) {
+	run := dm.Run
+	dm.Run = func(ctx context.Context, stdin string, args ...string) (string, error) {
+		t.Fatalf("unexpected dmsetup %v", args)
+		return "", nil
+	}
+	defer func() { dm.Run = run }()
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1",
+		Mounts: []container.Mount{container.NewMount("/data", "/mnt/disks/ssd0", false)}})
+	tests := []struct {
+		mode  string
+		flags map[string]string
+		code  int32
+	}{
+		{dm.ModeFlakey, map[string]string{DevicePathFlag: "/data", DeviceDownIntervalFlag: "0"}, spec.ParameterIllegal.Code},
+		{dm.ModeFlakey, map[string]string{DevicePathFlag: "/data", DeviceUpIntervalFlag: "x"}, spec.ParameterIllegal.Code},
+		{dm.ModeError, map[string]string{DevicePathFlag: "/tmp"}, spec.ParameterInvalid.Code},
+	}
+	for _, tt := range tests {
+		response := runDevice(t, client, tt.mode, tt.flags)
+		if response.Success || response.Code != tt.code {
+			t.Errorf("expected the code %d of %s %v, got %+v", tt.code, tt.mode, tt.flags, response)
+		}
+	}
+}
+
+// TestDeviceRefused refuses the volumes on the device of the kubelet pod directories and the device mounted by the host
+// before dmsetup is called
+func TestDeviceRefused(t *testing.T) {
+	run := dm.Run
+	dm.Run = func(ctx context.Context, stdin string, args ...string) (string, error) {
+		t.Fatalf("unexpected dmsetup %v", args)
+		return "", nil
+	}
+	defer func() { dm.Run = run }()
+	emptyDir := "/var/lib/kubelet/pods/p1/volumes/kubernetes.io~empty-dir/cache"
+	mountinfo := path.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(mountinfo, []byte("1 0 8:1 / / rw - ext4 /dev/sda1 rw\n"+
+		"3 1 253:4 / /mnt/disks/ssd0 rw - ext4 /dev/mapper/vg-ssd0 rw\n"+
+		"4 1 253:4 / /mnt/backup rw - ext4 /dev/mapper/vg-ssd0 rw\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hostMountInfo := container.HostMountInfo
+	container.HostMountInfo = func() string { return mountinfo }
+	defer func() { container.HostMountInfo = hostMountInfo }()
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1", Mounts: []container.Mount{
+		container.NewMount("/cache", emptyDir, false),
+		container.NewMount("/ssd", "/mnt/disks/ssd0", false),
+	}})
+	for devicePath, reason := range map[string]string{
+		"/cache/file": "backs the kubelet pod directories",
+		"/ssd/file":   "shared with the host mount /mnt/backup",
+	} {
+		response := runDevice(t, client, dm.ModeError, map[string]string{DevicePathFlag: devicePath})
+		if response.Success || response.Code != spec.ParameterInvalid.Code || !strings.Contains(response.Err, reason) {
+			t.Errorf("expected %s refused since it %s, got %+v", devicePath, reason, response)
+		}
+	}
+}
+
+func TestDeviceIntervals(t *testing.T) {
+	if up, down, response := deviceIntervals(dm.ModeFlakey, map[string]string{}); response != nil || up != 5 || down != 2 {
+		t.Errorf("expected the default intervals, got %d %d %v", up, down, response)
+	}
+	if up, down, response := deviceIntervals(dm.ModeFlakey, map[string]string{DeviceUpIntervalFlag: "0",
+		DeviceDownIntervalFlag: "10"}); response != nil || up != 0 || down != 10 {
+		t.Errorf("unexpected intervals %d %d %v", up, down, response)
+	}
+	if up, down, response := deviceIntervals(dm.ModeFlakey, map[string]string{DeviceUpIntervalFlag: "500ms",
+		DeviceDownIntervalFlag: "1m"}); response != nil || up != 1 || down != 60 {
+		t.Errorf("expected the durations rounded up to seconds, got %d %d %v", up, down, response)
+	}
+	for _, down := range []string{"500ms", "forever", "-1s"} {
+		if _, _, response := deviceIntervals(dm.ModeFlakey, map[string]string{DeviceDownIntervalFlag: down}); response == nil ||
+			response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the down interval %s refused, got %+v", down, response)
+		}
+	}
+}
+
+func TestLifetimeFlag(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"forever", 0},
+		{"0", 0},
+		{"300", 5 * time.Minute},
+		{"1h", time.Hour},
+	}
+	for _, tt := range tests {
+		if d, response := lifetimeFlag(map[string]string{"duration": tt.value}, "duration"); response != nil || d != tt.expected {
+			t.Errorf("expected %s of %q, got %s %v", tt.expected, tt.value, d, response)
+		}
+	}
+	if _, response := lifetimeFlag(map[string]string{"duration": "soon"}, "duration"); response == nil {
+		t.Error("expected the illegal duration refused")
+	}
+}
@@ -0,0 +1,291 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dm swaps the live table of the device-mapper devices backing the volumes with the flakey or the error
+// targets, so the filesystem above sees the i/o errors. The original table is saved before the swap and restored by
+// the revert, only the devices matched by the allowlist are eligible.
+package dm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/statefile"
+)
+
+// The fault modes
+const (
+	ModeFlakey = "flakey"
+	ModeError  = "error"
+)
+
+// DefaultAllowlistFile lists the patterns of the eligible device names, one per line, see path.Match
+const DefaultAllowlistFile = "/etc/chaosblade/dm-allowlist"
+
+var (
+	// sysBlockDir is the block devices of the sysfs
+	sysBlockDir = "/sys/class/block"
+	// Run executes dmsetup with the stdin, it is replaced in the tests
+	Run = runDmsetup
+)
+
+// Segment is a line of the device table, e.g. 0 2097152 linear 253:0 2048
+type Segment struct {
+	Start  uint64
+	Length uint64
+	Target string
+	Args   []string
+}
+
+func (s Segment) String() string {
+	return strings.Join(append([]string{strconv.FormatUint(s.Start, 10), strconv.FormatUint(s.Length, 10), s.Target},
+		s.Args...), " ")
+}
+
+// ParseTable parses the output of dmsetup table
+func ParseTable(table string) ([]Segment, error) {
+	segments := make([]Segment, 0)
+	scanner := bufio.NewScanner(strings.NewReader(table))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("illegal table line `%s`", scanner.Text())
+		}
+		start, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("illegal start of the table line `%s`", scanner.Text())
+		}
+		length, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("illegal length of the table line `%s`", scanner.Text())
+		}
+		segments = append(segments, Segment{Start: start, Length: length, Target: fields[2], Args: fields[3:]})
+	}
+	if len(segments) == 0 {
+		return nil, errors.New("the table is empty")
+	}
+	return segments, scanner.Err()
+}
+
+// FormatTable returns the table loaded by dmsetup
+func FormatTable(segments []Segment) string {
+	lines := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		lines = append(lines, segment.String())
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// FaultTable returns the table of the mode wrapping every linear segment, the flakey segments are available for
+// the up seconds and fail all i/o for the down seconds. The devices of the other targets such as thin or crypt are
+// not eligible, the faults beneath them are not well defined.
+func FaultTable(segments []Segment, mode string, up, down int) ([]Segment, error) {
+	if mode == ModeFlakey && (up < 0 || down <= 0) {
+		return nil, fmt.Errorf("illegal flakey intervals, up %d, down %d", up, down)
+	}
+	faults := make([]Segment, 0, len(segments))
+	for _, segment := range segments {
+		if segment.Target != "linear" || len(segment.Args) != 2 {
+			return nil, fmt.Errorf("the %s segment at %d is not eligible, only the linear devices are", segment.Target,
+				segment.Start)
+		}
+		fault := Segment{Start: segment.Start, Length: segment.Length, Target: mode}
+		switch mode {
+		case ModeFlakey:
+			fault.Args = []string{segment.Args[0], segment.Args[1], strconv.Itoa(up), strconv.Itoa(down)}
+		case ModeError:
+		default:
+			return nil, fmt.Errorf("illegal mode %s, it must be %s or %s", mode, ModeFlakey, ModeError)
+		}
+		faults = append(faults, fault)
+	}
+	return faults, nil
+}
+
+// LoadAllowlist returns the patterns of the allowlist file, nothing is eligible if the file does not exist
+func LoadAllowlist(file string) ([]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	patterns := make([]string, 0)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("illegal pattern `%s` in %s, %v", line, file, err)
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// Allowed returns true if the device name is matched by any pattern
+func Allowed(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// DeviceName returns the device-mapper name of the block device, e.g. /dev/mapper/vg-data or /dev/dm-3
+func DeviceName(device string) (string, error) {
+	if strings.HasPrefix(device, "/dev/mapper/") {
+		return strings.TrimPrefix(device, "/dev/mapper/"), nil
+	}
+	resolved, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return "", err
+	}
+	name, err := os.ReadFile(path.Join(sysBlockDir, path.Base(resolved), "dm", "name"))
+	if err != nil {
+		return "", fmt.Errorf("%s is not a device-mapper device", device)
+	}
+	return strings.TrimSpace(string(name)), nil
+}
+
+// Table returns the live table of the device
+func Table(ctx context.Context, name string) ([]Segment, string, error) {
+	table, err := Run(ctx, "", "table", name)
+	if err != nil {
+		return nil, "", fmt.Errorf("get the table of %s failed, %v", name, err)
+	}
+	segments, err := ParseTable(table)
+	if err != nil {
+		return nil, "", fmt.Errorf("%v of %s", err, name)
+	}
+	return segments, table, nil
+}
+
+// Injection is the swapped device saved for the revert
+type Injection struct {
+	Uid    string `json:"uid"`
+	Name   string `json:"name"`
+	Mode   string `json:"mode"`
+	Origin string `json:"origin"`
+}
+
+// Inject swaps the table of the device, the injection is saved to the state dir before the swap so the revert
+// restores the device even if the agent exits, a device is injected by one experiment at a time
+func Inject(ctx context.Context, stateDir string, injection Injection, faults []Segment) error {
+	if existing, err := findInjection(stateDir, injection.Name); err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("device %s is already injected by the experiment %s", injection.Name, existing.Uid)
+	}
+	if err := saveInjection(stateDir, injection); err != nil {
+		return err
+	}
+	if err := swap(ctx, injection.Name, FormatTable(faults)); err != nil {
+		statefile.Remove(stateFile(stateDir, injection.Uid))
+		return err
+	}
+	return nil
+}
+
+// Revert restores the original table of the experiment, it succeeds if the experiment injected nothing
+func Revert(ctx context.Context, stateDir, uid string) error {
+	var injection Injection
+	if err := statefile.Load(stateFile(stateDir, uid), &injection); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := swap(ctx, injection.Name, injection.Origin); err != nil {
+		return err
+	}
+	return statefile.Remove(stateFile(stateDir, uid))
+}
+
+// swap loads the table to the inactive slot while the device still serves the i/o, then suspends it and resumes it
+// with the table swapped in. The load may allocate or fail without touching the live table, the inactive table is
+// cleared if the device can't be suspended, and the resume ignores the cancellation of the context, so the device is
+// never left suspended.
+func swap(ctx context.Context, name, table string) error {
+	if _, err := Run(ctx, table, "load", name); err != nil {
+		if _, clearErr := Run(context.Background(), "", "clear", name); clearErr != nil {
+			return fmt.Errorf("load %s failed, %v, and clear failed, %v", name, err, clearErr)
+		}
+		return fmt.Errorf("load %s failed, %v", name, err)
+	}
+	if _, err := Run(ctx, "", "suspend", name); err != nil {
+		// the suspend may be interrupted half way, the live table is resumed without the loaded one
+		if _, clearErr := Run(context.Background(), "", "clear", name); clearErr != nil {
+			return fmt.Errorf("suspend %s failed, %v, and clear failed, %v", name, err, clearErr)
+		}
+		if _, resumeErr := Run(context.Background(), "", "resume", name); resumeErr != nil {
+			return fmt.Errorf("suspend %s failed, %v, and resume failed, %v", name, err, resumeErr)
+		}
+		return fmt.Errorf("suspend %s failed, %v", name, err)
+	}
+	if _, err := Run(context.Background(), "", "resume", name); err != nil {
+		return fmt.Errorf("resume %s failed, %v", name, err)
+	}
+	return nil
+}
+
+func stateFile(stateDir, uid string) string {
+	return statefile.Path(stateDir, "dm", uid)
+}
+
+func saveInjection(stateDir string, injection Injection) error {
+	return statefile.Save(stateFile(stateDir, injection.Uid), injection)
+}
+
+func findInjection(stateDir, name string) (*Injection, error) {
+	files, err := filepath.Glob(path.Join(stateDir, "dm-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		var injection Injection
+		if statefile.Load(file, &injection) == nil && injection.Name == name {
+			return &injection, nil
+		}
+	}
+	return nil, nil
+}
+
+func runDmsetup(ctx context.Context, stdin string, args ...string) (string, error) {
+	command := exec.CommandContext(ctx, "dmsetup", args...)
+	if stdin != "" {
+		command.Stdin = strings.NewReader(stdin)
+	}
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%v, %s", err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testTable = "0 2097152 linear 253:0 2048\n2097152 1048576 linear 8:16 0\n"
+
+func TestFaultTable(t *testing.T) {
+	segments, err := ParseTable(testTable)
+	if err != nil {
+		t.Fatalf("ParseTable failed, %v", err)
+	}
+	if FormatTable(segments) != testTable {
+		t.Errorf("unexpected formatted table %q", FormatTable(segments))
+	}
+	faults, err := FaultTable(segments, ModeFlakey, 5, 2)
+	if err != nil {
+		t.Fatalf("FaultTable failed, %v", err)
+	}
+	expected := "0 2097152 flakey 253:0 2048 5 2\n2097152 1048576 flakey 8:16 0 5 2\n"
+	if table := FormatTable(faults); table != expected {
+		t.Errorf("expected %q, got %q", expected, table)
+	}
+	faults, err = FaultTable(segments, ModeError, 0, 0)
+	if err != nil || FormatTable(faults) != "0 2097152 error\n2097152 1048576 error\n" {
+		t.Errorf("unexpected error table %q, %v", FormatTable(faults), err)
+	}
+
+	thin, _ := ParseTable("0 2097152 thin 253:2 7\n")
+	if _, err := FaultTable(thin, ModeError, 0, 0); err == nil {
+		t.Error("expected the thin device not eligible")
+	}
+	if _, err := FaultTable(segments, ModeFlakey, 5, 0); err == nil {
+		t.Error("expected error of the zero down interval")
+	}
+	for _, table := range []string{"", "0 x linear 8:0 0", "0 1"} {
+		if _, err := ParseTable(table); err == nil {
+			t.Errorf("expected error of the table %q", table)
+		}
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	file := path.Join(t.TempDir(), "allowlist")
+	if patterns, err := LoadAllowlist(file); err != nil || len(patterns) != 0 {
+		t.Errorf("expected nothing eligible without the allowlist, got %v, %v", patterns, err)
+	}
+	if err := os.WriteFile(file, []byte("# local volumes\nvg-pvc--*\n\nchaos-test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	patterns, err := LoadAllowlist(file)
+	if err != nil {
+		t.Fatalf("LoadAllowlist failed, %v", err)
+	}
+	for name, allowed := range map[string]bool{"vg-pvc--1234": true, "chaos-test": true, "vg-root": false} {
+		if Allowed(name, patterns) != allowed {
+			t.Errorf("expected %s allowed %t", name, allowed)
+		}
+	}
+	if err := os.WriteFile(file, []byte("vg-[\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAllowlist(file); err == nil {
+		t.Error("expected error of the illegal pattern")
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	sysBlockDir = t.TempDir()
+	defer func() { sysBlockDir = "/sys/class/block" }()
+	if name, err := DeviceName("/dev/mapper/vg-pvc--1234"); err != nil || name != "vg-pvc--1234" {
+		t.Errorf("unexpected name %s, %v", name, err)
+	}
+	dev := t.TempDir()
+	for _, name := range []string{"dm-3", "sdb"} {
+		if err := os.WriteFile(path.Join(dev, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(path.Join(sysBlockDir, "dm-3", "dm"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(sysBlockDir, "dm-3", "dm", "name"), []byte("vg-data\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := DeviceName(path.Join(dev, "dm-3")); err != nil || name != "vg-data" {
+		t.Errorf("unexpected name %s, %v", name, err)
+	}
+	if _, err := DeviceName(path.Join(dev, "sdb")); err == nil {
+		t.Error("expected error of the plain device")
+	}
+}
+
+func fakeRun(t *testing.T, fail string) *[]string {
+	t.Helper()
+	calls := make([]string, 0)
+	Run = func(ctx context.Context, stdin string, args ...string) (string, error) {
+		call := strings.Join(args, " ")
+		if stdin != "" {
+			call += " < " + strings.TrimSpace(stdin)
+		}
+		calls = append(calls, call)
+		// dmsetup is killed with the context
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if args[0] == fail {
+			return "", errors.New("device busy")
+		}
+		return "", nil
+	}
+	t.Cleanup(func() { Run = runDmsetup })
+	return &calls
+}
+
+func TestInjectAndRevert(t *testing.T) {
+	stateDir := t.TempDir()
+	calls := fakeRun(t, "")
+	faults := []Segment{{Start: 0, Length: 2048, Target: ModeError}}
+	injection := Injection{Uid: "uid1", Name: "vg-data", Mode: ModeError, Origin: "0 2048 linear 8:16 0\n"}
+	if err := Inject(context.Background(), stateDir, injection, faults); err != nil {
+		t.Fatalf("Inject failed, %v", err)
+	}
+	if err := Inject(context.Background(), stateDir, Injection{Uid: "uid2", Name: "vg-data"}, faults); err == nil {
+		t.Error("expected the device injected by one experiment at a time")
+	}
+	if err := Revert(context.Background(), stateDir, "uid1"); err != nil {
+		t.Fatalf("Revert failed, %v", err)
+	}
+	expected := []string{
+		"load vg-data < 0 2048 error", "suspend vg-data", "resume vg-data",
+		"load vg-data < 0 2048 linear 8:16 0", "suspend vg-data", "resume vg-data",
+	}
+	if !reflect.DeepEqual(*calls, expected) {
+		t.Errorf("expected %v, got %v", expected, *calls)
+	}
+	if err := Revert(context.Background(), stateDir, "uid1"); err != nil {
+		t.Errorf("expected the reverted experiment succeeded, %v", err)
+	}
+}
+
+func TestInjectLoadFailed(t *testing.T) {
+	stateDir := t.TempDir()
+	calls := fakeRun(t, "load")
+	injection := Injection{Uid: "uid1", Name: "vg-data", Mode: ModeError, Origin: "0 2048 linear 8:16 0\n"}
+	if err := Inject(context.Background(), stateDir, injection, []Segment{{Length: 2048, Target: ModeError}}); err == nil {
+		t.Fatal("expected the inject failed")
+	}
+	if expected := []string{"load vg-data < 0 2048 error", "clear vg-data"}; !reflect.DeepEqual(*calls, expected) {
+		t.Errorf("expected the device never suspended, got %v", *calls)
+	}
+	if _, err := os.Stat(stateFile(stateDir, "uid1")); !os.IsNotExist(err) {
+		t.Errorf("expected the state removed, %v", err)
+	}
+}
+
+func TestInjectSuspendFailed(t *testing.T) {
+	stateDir := t.TempDir()
+	calls := fakeRun(t, "suspend")
+	injection := Injection{Uid: "uid1", Name: "vg-data", Mode: ModeError, Origin: "0 2048 linear 8:16 0\n"}
+	if err := Inject(context.Background(), stateDir, injection, []Segment{{Length: 2048, Target: ModeError}}); err == nil {
+		t.Fatal("expected the inject failed")
+	}
+	expected := []string{"load vg-data < 0 2048 error", "suspend vg-data", "clear vg-data", "resume vg-data"}
+	if !reflect.DeepEqual(*calls, expected) {
+		t.Errorf("expected the device resumed with the live table, got %v", *calls)
+	}
+}
+
+// TestInjectCanceled cancels the context while the device is suspended, it's resumed all the same
+func TestInjectCanceled(t *testing.T) {
+	stateDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := make([]string, 0)
+	Run = func(runCtx context.Context, stdin string, args ...string) (string, error) {
+		calls = append(calls, args[0])
+		if runCtx.Err() != nil {
+			return "", runCtx.Err()
+		}
+		if args[0] == "suspend" {
+			cancel()
+		}
+		return "", nil
+	}
+	t.Cleanup(func() { Run = runDmsetup })
+	injection := Injection{Uid: "uid1", Name: "vg-data", Mode: ModeError, Origin: "0 2048 linear 8:16 0\n"}
+	if err := Inject(ctx, stateDir, injection, []Segment{{Length: 2048, Target: ModeError}}); err != nil {
+		t.Fatalf("expected the device resumed after the cancellation, %v", err)
+	}
+	if expected := []string{"load", "suspend", "resume"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected %v, got %v", expected, calls)
+	}
+}
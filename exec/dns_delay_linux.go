@@ -0,0 +1,166 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/network"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netif"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/qdisc"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rollback"
+)
+
+// The flags of the dns delay experiment
+const (
+	DNSDelayTimeFlag   = "time"
+	DNSDelayOffsetFlag = "offset"
+	DNSDelayPortFlag   = "dns-port"
+)
+
+// withDNSDelayAction adds the dns delay action to the network model of chaos_os, its executor is set after the
+// executor of the network model
+func withDNSDelayAction(commandSpec spec.ExpModelCommandSpec) spec.ExpModelCommandSpec {
+	if networkSpec, ok := commandSpec.(*network.NetworkCommandSpec); ok {
+		networkSpec.ExpActions = append(networkSpec.ExpActions, NewDNSDelayActionCommand())
+	}
+	return commandSpec
+}
+
+func NewDNSDelayActionCommand() spec.ExpActionCommandSpec {
+	return &DNSDelayActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     DNSDelayTimeFlag,
+					Desc:     "delay of the dns packets in milliseconds",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name: DNSDelayOffsetFlag,
+					Desc: "jitter of the delay in milliseconds, normally distributed, default is 0",
+				},
+				&spec.ExpFlag{
+					Name:    InterfaceFlag,
+					Desc:    "interface of the pod, default is eth0",
+					Default: "eth0",
+				},
+				&spec.ExpFlag{
+					Name:    DNSDelayPortFlag,
+					Desc:    "port of the dns server, default is 53",
+					Default: "53",
+				},
+			},
+			ActionExecutor: &dnsDelayExecutor{},
+			ActionLongDesc: "The dns packets leaving the pod, the udp and the tcp to or from the dns port, are delayed " +
+				"by the netem under a prio qdisc, the other packets are not. The queries of a client pod or the " +
+				"responses of a dns server pod such as coredns are slow but succeed, unlike the dns and the dns_down " +
+				"experiments failing them. The qdisc is deleted by the destroy, the experiment is refused if the " +
+				"interface has a shaper or another tc experiment.",
+			ActionExample: `# Delay the dns queries of the pod by 2 seconds
+blade create cri network dns_delay --time 2000 --container-id ee54f1e61c08
+
+# Delay the responses of coredns by 300ms with a jitter of 100ms
+blade create cri network dns_delay --time 300 --offset 100 --container-id 9c2d0e1f7a3b`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+type DNSDelayActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*DNSDelayActionCommand) Name() string {
+	return "dns_delay"
+}
+
+func (*DNSDelayActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*DNSDelayActionCommand) ShortDesc() string {
+	return "dns latency"
+}
+
+func (c *DNSDelayActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+type dnsDelayExecutor struct {
+}
+
+func (e *dnsDelayExecutor) Name() string {
+	return "network"
+}
+
+func (e *dnsDelayExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *dnsDelayExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return restoreInterface(ctx, uid, model)
+	}
+	flags := model.ActionFlags
+	delay := netif.DNSDelay{Interface: interfaceName(flags)}
+	var response *spec.Response
+	if delay.Time, response = rangeFlag(flags, DNSDelayTimeFlag, 0, 1, 600000); response != nil {
+		return response
+	}
+	if delay.Time == 0 {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, DNSDelayTimeFlag)
+	}
+	if delay.Offset, response = rangeFlag(flags, DNSDelayOffsetFlag, 0, 0, delay.Time); response != nil {
+		return response
+	}
+	if delay.Port, response = rangeFlag(flags, DNSDelayPortFlag, 53, 1, 65535); response != nil {
+		return response
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	output, err := runInNetns(ctx, pid, qdisc.ShowScript(delay.Interface))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, InterfaceFlag, delay.Interface, err)
+	}
+	// the root qdisc is replaced by the prio, so the shapers and the other tc experiments are never overridden
+	if conflicts := qdisc.Inspect(qdisc.Parse(output)); len(conflicts) > 0 {
+		return spec.ReturnFail(spec.OsCmdExecFailed, qdisc.Report(delay.Interface, conflicts))
+	}
+	state := netif.State{Uid: uid, Interface: delay.Interface, Restore: delay.RestoreScript()}
+	steps := rollback.New(ctx, uid)
+	if err := steps.Run("save state", func() error { return netif.SaveState(util.GetProgramPath(), state) },
+		func() error { return netif.RemoveState(util.GetProgramPath(), uid) }); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	// the qdisc added before the failed filter is deleted too
+	steps.Done("dns delay", func() error {
+		_, err := runInNetns(ctx, pid, state.Restore)
+		return err
+	})
+	if _, err := runInNetns(ctx, pid, delay.Script()); err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err))
+	}
+	log.Infof(ctx, "delay the dns packets of the port %d on %s by %dms", delay.Port, delay.Interface, delay.Time)
+	return spec.ReturnSuccess(state)
+}
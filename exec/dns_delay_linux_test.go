@@ -0,0 +1,78 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netif"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/qdisc"
+)
+
+func runDNSDelay(ctx context.Context, flags map[string]string) *spec.Response {
+	flags[ContainerIdFlag.Name] = "c1"
+	model := &spec.ExpModel{Target: "network", ActionName: "dns_delay", ActionFlags: flags}
+	return (&dnsDelayExecutor{}).Exec("uid1", ctx, model)
+}
+
+func TestDNSDelay(t *testing.T) {
+	scripts := fakeNetns(t, "qdisc noqueue 0: root refcnt 2\n")
+	defer netif.RemoveState(util.GetProgramPath(), "uid1")
+	for _, flags := range []map[string]string{
+		{},
+		{DNSDelayTimeFlag: "fast"},
+		{DNSDelayTimeFlag: "100", DNSDelayOffsetFlag: "200"},
+		{DNSDelayTimeFlag: "100", DNSDelayPortFlag: "70000"},
+	} {
+		if response := runDNSDelay(context.Background(), flags); response.Success {
+			t.Errorf("expected the illegal flags %v refused", flags)
+		}
+	}
+	if len(*scripts) != 0 {
+		t.Fatalf("expected nothing run, got %q", *scripts)
+	}
+	response := runDNSDelay(context.Background(), map[string]string{DNSDelayTimeFlag: "300", DNSDelayOffsetFlag: "100"})
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	delay := netif.DNSDelay{Interface: "eth0", Port: 53, Time: 300, Offset: 100}
+	if len(*scripts) != 2 || (*scripts)[0] != qdisc.ShowScript("eth0") || (*scripts)[1] != delay.Script() {
+		t.Errorf("expected the qdiscs and the delay scripts, got %q", *scripts)
+	}
+	if response := runDNSDelay(spec.SetDestroyFlag(context.Background(), "uid1"), map[string]string{}); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if last := (*scripts)[len(*scripts)-1]; last != delay.RestoreScript() {
+		t.Errorf("expected the prio qdisc deleted, got %s", last)
+	}
+}
+
+func TestDNSDelayConflict(t *testing.T) {
+	scripts := fakeNetns(t, "qdisc tbf 1: root refcnt 2 rate 10Mbit burst 256Kb lat 25ms\n")
+	response := runDNSDelay(context.Background(), map[string]string{DNSDelayTimeFlag: "300"})
+	if response.Success || !strings.Contains(response.Err, "bandwidth plugin") {
+		t.Errorf("expected the conflict refused, got %+v", response)
+	}
+	if len(*scripts) != 1 {
+		t.Errorf("expected the qdiscs inspected only, got %q", *scripts)
+	}
+}
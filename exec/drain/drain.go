@@ -0,0 +1,181 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package drain stops the pod sandboxes of the node one by one in a controlled order, simulating a node failure or
+// an unclean drain: the pods are not evicted through the api server, their sandboxes are stopped under the kubelet,
+// which recreates them as after a crash.
+package drain
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// The orders of the sandboxes stopped
+const (
+	OrderOldest = "oldest"
+	OrderNewest = "newest"
+	OrderName   = "name"
+	OrderRandom = "random"
+)
+
+// DefaultExcludedNamespaces are the namespaces never drained unless the namespaces are given explicitly
+var DefaultExcludedNamespaces = []string{"kube-system"}
+
+// Options selects and orders the sandboxes
+type Options struct {
+	// Namespaces are the namespaces drained, all if empty
+	Namespaces []string
+	// ExcludedNamespaces are skipped, they take precedence over the Namespaces
+	ExcludedNamespaces []string
+	// Labels are the pod labels the sandboxes must have
+	Labels map[string]string
+	Order  string
+	// Max is the max count of the sandboxes stopped, 0 means all
+	Max int
+	// Self is the pod of the executor, it's never stopped whatever the namespaces are
+	Self Self
+}
+
+// Validate checks the order and the max count
+func (o Options) Validate() error {
+	switch o.Order {
+	case OrderOldest, OrderNewest, OrderName, OrderRandom:
+	default:
+		return fmt.Errorf("unsupported order %s, it must be %s, %s, %s or %s", o.Order, OrderOldest, OrderNewest,
+			OrderName, OrderRandom)
+	}
+	if o.Max < 0 {
+		return fmt.Errorf("max must not be negative")
+	}
+	return nil
+}
+
+// Plan returns the ready sandboxes selected by the options in the order they are stopped
+func Plan(sandboxes []container.Sandbox, options Options) []container.Sandbox {
+	included, excluded := toSet(options.Namespaces), toSet(options.ExcludedNamespaces)
+	planned := make([]container.Sandbox, 0, len(sandboxes))
+	for _, sandbox := range sandboxes {
+		if !sandbox.Ready || excluded[sandbox.Namespace] || options.Self.Matches(sandbox) {
+			continue
+		}
+		if len(included) > 0 && !included[sandbox.Namespace] {
+			continue
+		}
+		if !matchLabels(sandbox.Labels, options.Labels) {
+			continue
+		}
+		planned = append(planned, sandbox)
+	}
+	switch options.Order {
+	case OrderOldest:
+		sort.SliceStable(planned, func(i, j int) bool { return planned[i].CreatedAt.Before(planned[j].CreatedAt) })
+	case OrderNewest:
+		sort.SliceStable(planned, func(i, j int) bool { return planned[i].CreatedAt.After(planned[j].CreatedAt) })
+	case OrderName:
+		sort.SliceStable(planned, func(i, j int) bool {
+			return planned[i].Namespace+"/"+planned[i].Name < planned[j].Namespace+"/"+planned[j].Name
+		})
+	case OrderRandom:
+		rand.Shuffle(len(planned), func(i, j int) { planned[i], planned[j] = planned[j], planned[i] })
+	}
+	if options.Max > 0 && len(planned) > options.Max {
+		planned = planned[:options.Max]
+	}
+	return planned
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+func matchLabels(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Stopped is the sandbox stopped or failed to stop
+type Stopped struct {
+	Id        string    `json:"id"`
+	Pod       string    `json:"pod"`
+	Success   bool      `json:"success"`
+	Err       string    `json:"error,omitempty"`
+	StoppedAt time.Time `json:"stoppedAt"`
+}
+
+// Result is the result of the drain, the sandboxes are in the order they are stopped
+type Result struct {
+	Planned   int       `json:"planned"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Sandboxes []Stopped `json:"sandboxes"`
+	// Aborted is set if the context is done before all the sandboxes are stopped
+	Aborted bool `json:"aborted,omitempty"`
+	// Skipped is the pod of the executor found on the node and skipped, namespace/name
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// SelfSandbox returns the sandbox of the pod of the executor, false if the executor doesn't run in a pod of the node
+func SelfSandbox(sandboxes []container.Sandbox, self Self) (container.Sandbox, bool) {
+	for _, sandbox := range sandboxes {
+		if self.Matches(sandbox) {
+			return sandbox, true
+		}
+	}
+	return container.Sandbox{}, false
+}
+
+// Run stops the planned sandboxes one by one with the interval between two of them, a failure doesn't stop the
+// others, the sandboxes stopped are not restored, the kubelet recreates them
+func Run(ctx context.Context, stopper container.SandboxStopper, planned []container.Sandbox,
+	interval time.Duration) Result {
+	result := Result{Planned: len(planned), Sandboxes: make([]Stopped, 0, len(planned))}
+	for i, sandbox := range planned {
+		if i > 0 && interval > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				result.Aborted = true
+				return result
+			case <-timer.C:
+			}
+		}
+		stopped := Stopped{Id: sandbox.Id, Pod: sandbox.Namespace + "/" + sandbox.Name, Success: true}
+		if err := stopper.StopSandbox(ctx, sandbox.Id); err != nil {
+			stopped.Success, stopped.Err = false, err.Error()
+			result.Failed++
+		} else {
+			result.Succeeded++
+		}
+		stopped.StoppedAt = time.Now()
+		result.Sandboxes = append(result.Sandboxes, stopped)
+	}
+	return result
+}
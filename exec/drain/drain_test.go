@@ -0,0 +1,151 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package drain
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+func sandboxes() []container.Sandbox {
+	now := time.Now()
+	return []container.Sandbox{
+		{Id: "s1", Name: "web-1", Namespace: "default", Labels: map[string]string{"app": "web"}, Ready: true,
+			CreatedAt: now.Add(-3 * time.Hour)},
+		{Id: "s2", Name: "coredns", Namespace: "kube-system", Ready: true, CreatedAt: now.Add(-5 * time.Hour)},
+		{Id: "s3", Name: "db-0", Namespace: "data", Labels: map[string]string{"app": "db"}, Ready: true,
+			CreatedAt: now.Add(-time.Hour)},
+		{Id: "s4", Name: "web-2", Namespace: "default", Labels: map[string]string{"app": "web"}, Ready: false,
+			CreatedAt: now.Add(-2 * time.Hour)},
+	}
+}
+
+func ids(planned []container.Sandbox) string {
+	result := ""
+	for _, sandbox := range planned {
+		result += sandbox.Id
+	}
+	return result
+}
+
+func TestPlan(t *testing.T) {
+	for _, c := range []struct {
+		options  Options
+		expected string
+	}{
+		{Options{Order: OrderOldest, ExcludedNamespaces: DefaultExcludedNamespaces}, "s1s3"},
+		{Options{Order: OrderNewest}, "s3s1s2"},
+		{Options{Order: OrderName}, "s3s1s2"},
+		{Options{Order: OrderOldest, Namespaces: []string{"default", "kube-system"}, ExcludedNamespaces: []string{"kube-system"}}, "s1"},
+		{Options{Order: OrderOldest, Labels: map[string]string{"app": "db"}}, "s3"},
+		{Options{Order: OrderOldest, Max: 2}, "s2s1"},
+	} {
+		if planned := ids(Plan(sandboxes(), c.options)); planned != c.expected {
+			t.Errorf("expected %s of %+v, got %s", c.expected, c.options, planned)
+		}
+	}
+	if err := (Options{Order: "size"}).Validate(); err == nil {
+		t.Error("expected the order illegal")
+	}
+}
+
+func TestPlanSkipsSelf(t *testing.T) {
+	all := sandboxes()
+	all[0].Uid = "0c1d2e3f-4a5b-6c7d-8e9f-a0b1c2d3e4f5"
+	for _, self := range []Self{
+		{Uid: "0c1d2e3f-4a5b-6c7d-8e9f-a0b1c2d3e4f5"},
+		{Name: "web-1", Namespace: "default"},
+	} {
+		if planned := ids(Plan(all, Options{Order: OrderOldest, Self: self})); planned != "s2s3" {
+			t.Errorf("expected the pod of the executor skipped by %+v, got %s", self, planned)
+		}
+		if sandbox, ok := SelfSandbox(all, self); !ok || sandbox.Id != "s1" {
+			t.Errorf("expected the sandbox of the executor, got %+v", sandbox)
+		}
+	}
+	if _, ok := SelfSandbox(all, Self{}); ok {
+		t.Error("expected no sandbox of the executor out of a pod")
+	}
+}
+
+func TestSelfPod(t *testing.T) {
+	file := path.Join(t.TempDir(), "cgroup")
+	selfCgroupFile = file
+	defer func() { selfCgroupFile = "/proc/self/cgroup" }()
+	for cgroup, uid := range map[string]string{
+		"0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod0c1d2e3f_4a5b_6c7d_8e9f_a0b1c2d3e4f5.slice/" +
+			"cri-containerd-c0ffee.scope\n": "0c1d2e3f-4a5b-6c7d-8e9f-a0b1c2d3e4f5",
+		"4:memory:/kubepods/besteffort/pod0c1d2e3f-4a5b-6c7d-8e9f-a0b1c2d3e4f5/c0ffee\n": "0c1d2e3f-4a5b-6c7d-8e9f-a0b1c2d3e4f5",
+		"0::/system.slice/chaosblade.service\n":                                          "",
+	} {
+		if err := os.WriteFile(file, []byte(cgroup), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if self := SelfPod(); self.Uid != uid {
+			t.Errorf("expected the pod uid %q of %q, got %+v", uid, cgroup, self)
+		}
+	}
+	t.Setenv(PodNameEnv, "chaosblade-agent-x1")
+	t.Setenv(PodNamespaceEnv, "chaosblade")
+	if self := SelfPod(); self.Name != "chaosblade-agent-x1" || self.Namespace != "chaosblade" {
+		t.Errorf("expected the pod of the downward api env, got %+v", self)
+	}
+}
+
+type stopper struct {
+	stopped []string
+	failed  map[string]bool
+}
+
+func (s *stopper) ListSandboxes(ctx context.Context) ([]container.Sandbox, error) {
+	return sandboxes(), nil
+}
+
+func (s *stopper) StopSandbox(ctx context.Context, sandboxId string) error {
+	s.stopped = append(s.stopped, sandboxId)
+	if s.failed[sandboxId] {
+		return errors.New("stop failed")
+	}
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	s := &stopper{failed: map[string]bool{"s1": true}}
+	planned := Plan(sandboxes(), Options{Order: OrderOldest})
+	start := time.Now()
+	result := Run(context.Background(), s, planned, 10*time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected the stops paced, elapsed %s", elapsed)
+	}
+	if result.Planned != 3 || result.Succeeded != 2 || result.Failed != 1 || result.Sandboxes[1].Pod != "default/web-1" ||
+		result.Sandboxes[1].Success {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s = &stopper{}
+	if result := Run(ctx, s, planned, time.Minute); !result.Aborted || len(s.stopped) != 1 {
+		t.Errorf("expected the drain aborted after the first, got %+v %v", result, s.stopped)
+	}
+}
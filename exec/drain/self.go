@@ -0,0 +1,82 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package drain
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// The downward api env of the pod of the executor, such as the daemonset of the agent
+const (
+	PodNameEnv      = "POD_NAME"
+	PodNamespaceEnv = "POD_NAMESPACE"
+	PodUidEnv       = "POD_UID"
+)
+
+var (
+	// selfCgroupFile is the cgroup of the executor, it is replaced by the tests
+	selfCgroupFile = "/proc/self/cgroup"
+	// podUidPattern is the pod uid in the pod cgroup, such as /kubepods/burstable/pod<uid> of the cgroupfs driver or
+	// kubepods-burstable-pod<uid>.slice of the systemd driver, whose uid has the dashes replaced by underscores
+	podUidPattern = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+)
+
+// Self is the pod of the executor, the drain stopping it kills the experiment itself, the fields are empty if the
+// executor doesn't run in a pod
+type Self struct {
+	Name      string
+	Namespace string
+	Uid       string
+}
+
+// SelfPod returns the pod of the executor by the downward api env, or by the pod uid of its cgroup if the env isn't
+// declared
+func SelfPod() Self {
+	self := Self{Name: os.Getenv(PodNameEnv), Namespace: os.Getenv(PodNamespaceEnv), Uid: os.Getenv(PodUidEnv)}
+	if self.Uid == "" {
+		self.Uid = cgroupPodUid()
+	}
+	return self
+}
+
+// Matches returns true if the sandbox is the pod of the executor
+func (s Self) Matches(sandbox container.Sandbox) bool {
+	if s.Uid != "" && sandbox.Uid == s.Uid {
+		return true
+	}
+	return s.Name != "" && s.Namespace != "" && sandbox.Name == s.Name && sandbox.Namespace == s.Namespace
+}
+
+func cgroupPodUid() string {
+	file, err := os.Open(selfCgroupFile)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if match := podUidPattern.FindStringSubmatch(scanner.Text()); match != nil {
+			return strings.ReplaceAll(match[1], "_", "-")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package durations parses the timeouts and durations of the experiments, Go durations such as 500ms, 2m or 1h are
+// accepted besides the integers of seconds the flags took before, and forever declares the experiment open-ended,
+// it persists until destroyed.
+package durations
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Forever is the duration of the open-ended experiments
+const Forever = "forever"
+
+// Parse returns the duration of the value, an integer is the seconds, forever is returned as 0
+func Parse(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == Forever {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("%s is negative", value)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is neither a duration such as 500ms, 2m or 1h, nor the seconds", value)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s is negative", value)
+	}
+	return d, nil
+}
+
+// IsForever returns true if the value declares the experiment open-ended
+func IsForever(value string) bool {
+	return strings.TrimSpace(value) == Forever
+}
+
+// Seconds returns the whole seconds of the duration rounded up, for the scripts counting the seconds
+func Seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Sleep returns the argument of sleep for the duration, the fractional seconds are supported by the sleep of
+// coreutils and busybox
+func Sleep(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// Format describes the duration, 0 is forever
+func Format(d time.Duration) string {
+	if d == 0 {
+		return Forever
+	}
+	return d.String()
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package durations
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		failed   bool
+	}{
+		{"30", 30 * time.Second, false},
+		{"0", 0, false},
+		{"500ms", 500 * time.Millisecond, false},
+		{"2m", 2 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{" forever ", 0, false},
+		{"-1", 0, true},
+		{"-1s", 0, true},
+		{"1.5", 0, true},
+		{"x", 0, true},
+	}
+	for _, tt := range tests {
+		d, err := Parse(tt.value)
+		if (err != nil) != tt.failed || d != tt.expected {
+			t.Errorf("parse %q, expected %s failed %t, got %s %v", tt.value, tt.expected, tt.failed, d, err)
+		}
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	tests := []struct {
+		d       time.Duration
+		seconds int
+		sleep   string
+	}{
+		{0, 0, "0"},
+		{500 * time.Millisecond, 1, "0.5"},
+		{2 * time.Second, 2, "2"},
+		{1500 * time.Millisecond, 2, "1.5"},
+	}
+	for _, tt := range tests {
+		if seconds := Seconds(tt.d); seconds != tt.seconds {
+			t.Errorf("expected %d seconds of %s, got %d", tt.seconds, tt.d, seconds)
+		}
+		if sleep := Sleep(tt.d); sleep != tt.sleep {
+			t.Errorf("expected sleep %s of %s, got %s", tt.sleep, tt.d, sleep)
+		}
+	}
+	if Format(0) != Forever || Format(time.Minute) != "1m0s" {
+		t.Errorf("unexpected formats %s %s", Format(0), Format(time.Minute))
+	}
+}
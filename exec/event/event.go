@@ -0,0 +1,232 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+)
+
+// The experiment lifecycle event types
+const (
+	TypeCreated  = "io.chaosblade.experiment.created"
+	TypeInjected = "io.chaosblade.experiment.injected"
+	TypeReverted = "io.chaosblade.experiment.reverted"
+	TypeFailed   = "io.chaosblade.experiment.failed"
+)
+
+const (
+	SpecVersion = "1.0"
+
+	sendTimeout = 5 * time.Second
+	queueSize   = 1024
+)
+
+// CloudEvent is the structured mode json of the CloudEvents spec
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Id              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            ExperimentEvent `json:"data"`
+}
+
+// ExperimentEvent is the data of the lifecycle event
+type ExperimentEvent struct {
+	Uid    string            `json:"uid"`
+	Target string            `json:"target"`
+	Action string            `json:"action"`
+	Flags  map[string]string `json:"flags,omitempty"`
+	Node   string            `json:"node"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// Sink sends the events
+type Sink interface {
+	Send(ctx context.Context, event CloudEvent) error
+}
+
+// NewSink creates the sink by the address, http(s)://host/path posts the events to the http endpoint,
+// kafka://host:port/topic produces the events to the topic through the kafka rest proxy
+func NewSink(address string) (Sink, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("illegal event sink `%s`, %v", address, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &httpSink{url: address, client: &http.Client{Timeout: sendTimeout}}, nil
+	case "kafka":
+		topic := strings.Trim(u.Path, "/")
+		if topic == "" {
+			return nil, fmt.Errorf("illegal event sink `%s`, the kafka topic is required", address)
+		}
+		return &kafkaRestSink{
+			url:    fmt.Sprintf("http://%s/topics/%s", u.Host, topic),
+			client: &http.Client{Timeout: sendTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("illegal event sink `%s`, the scheme must be http, https or kafka", address)
+	}
+}
+
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpSink) Send(ctx context.Context, event CloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.url, "application/cloudevents+json", body)
+}
+
+// kafkaRestSink produces the events by the kafka rest proxy v2 api
+type kafkaRestSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *kafkaRestSink) Send(ctx context.Context, event CloudEvent) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{{"key": event.Subject, "value": event}},
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.url, "application/vnd.kafka.json.v2+json", body)
+}
+
+func post(ctx context.Context, client *http.Client, url, contentType string, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("post %s failed, status: %s", url, response.Status)
+	}
+	return nil
+}
+
+// Emitter sends the events to the sink asynchronously, so the experiments are not blocked by the sink
+type Emitter struct {
+	sink   Sink
+	source string
+	node   string
+	queue  chan CloudEvent
+	wg     sync.WaitGroup
+	// mu guards the queue against the events emitted after the close, such as by the experiments still running when
+	// the shutdown times out, they are dropped instead of sent on the closed queue
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewEmitter starts the emitter, a nil sink discards all events
+func NewEmitter(sink Sink) *Emitter {
+	node, _ := os.Hostname()
+	e := &Emitter{
+		sink:   sink,
+		source: fmt.Sprintf("chaosblade-exec-cri/%s", node),
+		node:   node,
+		queue:  make(chan CloudEvent, queueSize),
+	}
+	if sink != nil {
+		e.wg.Add(1)
+		go e.run()
+	}
+	return e
+}
+
+// Emit queues the event, the event is dropped if the queue is full
+func (e *Emitter) Emit(eventType string, data ExperimentEvent) {
+	if e == nil || e.sink == nil {
+		return
+	}
+	id, err := util.GenerateUid()
+	if err != nil {
+		id = fmt.Sprintf("%s-%d", data.Uid, time.Now().UnixNano())
+	}
+	data.Node = e.node
+	event := CloudEvent{
+		SpecVersion:     SpecVersion,
+		Id:              id,
+		Source:          e.source,
+		Type:            eventType,
+		Subject:         data.Uid,
+		Time:            time.Now(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		log.Warnf(context.Background(), "the emitter is closed, drop the %s event of experiment %s", eventType, data.Uid)
+		return
+	}
+	select {
+	case e.queue <- event:
+	default:
+		log.Warnf(context.Background(), "event queue is full, drop the %s event of experiment %s", eventType, data.Uid)
+	}
+}
+
+// Close sends the queued events and stops the emitter
+func (e *Emitter) Close() {
+	if e == nil || e.sink == nil {
+		return
+	}
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+}
+
+func (e *Emitter) run() {
+	defer e.wg.Done()
+	for event := range e.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		if err := e.sink.Send(ctx, event); err != nil {
+			log.Warnf(ctx, "send the %s event of experiment %s failed, %v", event.Type, event.Subject, err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordSink records the events sent
+type recordSink struct {
+	mu     sync.Mutex
+	events []CloudEvent
+}
+
+func (s *recordSink) Send(ctx context.Context, event CloudEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+// TestEmitAfterClose emits concurrently with the close, the events after the close are dropped without the panic
+func TestEmitAfterClose(t *testing.T) {
+	sink := &recordSink{}
+	emitter := NewEmitter(sink)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			emitter.Emit(TypeInjected, ExperimentEvent{Uid: "uid1"})
+		}()
+	}
+	emitter.Close()
+	wg.Wait()
+	emitter.Emit(TypeReverted, ExperimentEvent{Uid: "uid1"})
+	emitter.Close()
+	for _, event := range sink.events {
+		if event.Type != TypeInjected {
+			t.Errorf("unexpected event %s sent after the close", event.Type)
+		}
+	}
+}
+
+func TestEmit(t *testing.T) {
+	sink := &recordSink{}
+	emitter := NewEmitter(sink)
+	emitter.Emit(TypeCreated, ExperimentEvent{Uid: "uid1", Target: "cpu", Action: "fullload"})
+	emitter.Emit(TypeFailed, ExperimentEvent{Uid: "uid1", Target: "cpu", Action: "fullload", Error: "failed"})
+	// the queued events are sent by the close
+	emitter.Close()
+	node, _ := os.Hostname()
+	if len(sink.events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", sink.events)
+	}
+	for i, eventType := range []string{TypeCreated, TypeFailed} {
+		event := sink.events[i]
+		if event.SpecVersion != SpecVersion || event.Id == "" || event.Type != eventType || event.Subject != "uid1" ||
+			event.Source != "chaosblade-exec-cri/"+node || event.DataContentType != "application/json" ||
+			event.Time.IsZero() || event.Data.Node != node || event.Data.Target != "cpu" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+	if sink.events[0].Id == sink.events[1].Id {
+		t.Error("expected the unique event ids")
+	}
+	if sink.events[1].Data.Error != "failed" {
+		t.Errorf("expected the error of the failed event, got %+v", sink.events[1].Data)
+	}
+	// the emitter without sink discards the events
+	discard := NewEmitter(nil)
+	discard.Emit(TypeCreated, ExperimentEvent{Uid: "uid1"})
+	discard.Close()
+}
+
+func TestNewSink(t *testing.T) {
+	for _, address := range []string{"ftp://host/events", "kafka://broker:8082", "kafka://broker:8082/", "://"} {
+		if _, err := NewSink(address); err == nil {
+			t.Errorf("expected the sink %s refused", address)
+		}
+	}
+	if sink, err := NewSink("kafka://broker:8082/chaos"); err != nil ||
+		sink.(*kafkaRestSink).url != "http://broker:8082/topics/chaos" {
+		t.Errorf("unexpected kafka sink %+v, %v", sink, err)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	var contentType, uri string
+	var body map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType, uri = r.Header.Get("Content-Type"), r.URL.Path
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	event := CloudEvent{SpecVersion: SpecVersion, Id: "id1", Type: TypeInjected, Subject: "uid1",
+		Data: ExperimentEvent{Uid: "uid1"}}
+
+	httpSink, err := NewSink(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := httpSink.Send(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/cloudevents+json" || uri != "/events" || body["type"] != TypeInjected ||
+		body["specversion"] != SpecVersion {
+		t.Errorf("unexpected structured event %s %s %+v", contentType, uri, body)
+	}
+
+	kafkaSink, err := NewSink("kafka://" + strings.TrimPrefix(server.URL, "http://") + "/chaos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kafkaSink.Send(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	records, _ := body["records"].([]interface{})
+	if contentType != "application/vnd.kafka.json.v2+json" || uri != "/topics/chaos" || len(records) != 1 ||
+		records[0].(map[string]interface{})["key"] != "uid1" {
+		t.Errorf("unexpected kafka records %s %s %+v", contentType, uri, body)
+	}
+
+	status = http.StatusInternalServerError
+	if err := httpSink.Send(context.Background(), event); err == nil {
+		t.Error("expected the failed status returned")
+	}
+}
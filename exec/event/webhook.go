@@ -0,0 +1,162 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/node"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/probe"
+)
+
+// The experiment phases reported to the webhooks
+const (
+	PhaseCreate  = "create"
+	PhaseDestroy = "destroy"
+)
+
+const webhookRetryTimes = 3
+
+// webhookBackoff is the base interval between the retries, the i-th retry waits i intervals
+var webhookBackoff = time.Second
+
+// WebhookResult is the body posted to the webhooks when the experiment phase completes
+type WebhookResult struct {
+	Uid     string        `json:"uid"`
+	Target  string        `json:"target"`
+	Action  string        `json:"action"`
+	Phase   string        `json:"phase"`
+	Success bool          `json:"success"`
+	Code    int32         `json:"code"`
+	Error   string        `json:"error,omitempty"`
+	Result  interface{}   `json:"result,omitempty"`
+	Probes  *probe.Report `json:"probes,omitempty"`
+	Node    string        `json:"node"`
+	// NodeInfo is the node and runtime context of the experiment
+	NodeInfo *node.Info `json:"nodeInfo,omitempty"`
+	Time     time.Time  `json:"time"`
+}
+
+type webhookTask struct {
+	urls   []string
+	result WebhookResult
+}
+
+// Notifier posts the experiment results to the webhooks asynchronously
+type Notifier struct {
+	client *http.Client
+	node   string
+	queue  chan webhookTask
+	wg     sync.WaitGroup
+	// mu guards the queue like the one of the emitter, the results notified after the close are dropped
+	mu     sync.Mutex
+	closed bool
+}
+
+// ValidateWebhooks checks the webhooks are http or https urls
+func ValidateWebhooks(webhooks []string) error {
+	for _, webhook := range webhooks {
+		u, err := url.Parse(webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("illegal webhook `%s`, it must be a http or https url", webhook)
+		}
+	}
+	return nil
+}
+
+func NewNotifier() *Notifier {
+	node, _ := os.Hostname()
+	n := &Notifier{
+		client: &http.Client{Timeout: sendTimeout},
+		node:   node,
+		queue:  make(chan webhookTask, queueSize),
+	}
+	n.wg.Add(1)
+	go n.run()
+	return n
+}
+
+// Notify queues the result for the webhooks
+func (n *Notifier) Notify(webhooks []string, result WebhookResult) {
+	if len(webhooks) == 0 {
+		return
+	}
+	result.Node = n.node
+	result.Time = time.Now()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		log.Warnf(context.Background(), "the notifier is closed, drop the %s result of experiment %s", result.Phase, result.Uid)
+		return
+	}
+	select {
+	case n.queue <- webhookTask{urls: webhooks, result: result}:
+	default:
+		log.Warnf(context.Background(), "webhook queue is full, drop the %s result of experiment %s", result.Phase, result.Uid)
+	}
+}
+
+// Close posts the queued results and stops the notifier
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+	n.wg.Wait()
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+	for task := range n.queue {
+		body, err := json.Marshal(task.result)
+		if err != nil {
+			log.Warnf(context.Background(), "marshal the webhook result of experiment %s failed, %v", task.result.Uid, err)
+			continue
+		}
+		for _, webhook := range task.urls {
+			n.post(webhook, task.result.Uid, body)
+		}
+	}
+}
+
+// post retries with backoff, the webhook receivers are usually ci pipelines which may be briefly unavailable
+func (n *Notifier) post(webhook, uid string, body []byte) {
+	var err error
+	for i := 0; i < webhookRetryTimes; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * webhookBackoff)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = post(ctx, n.client, webhook, "application/json", body)
+		cancel()
+		if err == nil {
+			return
+		}
+	}
+	log.Warnf(context.Background(), "post the result of experiment %s to webhook %s failed, %v", uid, webhook, err)
+}
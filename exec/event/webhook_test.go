@@ -0,0 +1,130 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestNotifyAfterClose notifies concurrently with the close, the results after the close are dropped without the panic
+func TestNotifyAfterClose(t *testing.T) {
+	var mu sync.Mutex
+	phases := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result WebhookResult
+		json.NewDecoder(r.Body).Decode(&result)
+		mu.Lock()
+		phases = append(phases, result.Phase)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	notifier := NewNotifier()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			notifier.Notify([]string{server.URL}, WebhookResult{Uid: "uid1", Phase: PhaseCreate})
+		}()
+	}
+	notifier.Close()
+	wg.Wait()
+	notifier.Notify([]string{server.URL}, WebhookResult{Uid: "uid1", Phase: PhaseDestroy})
+	notifier.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	for _, phase := range phases {
+		if phase != PhaseCreate {
+			t.Errorf("unexpected result of %s posted after the close", phase)
+		}
+	}
+}
+
+func TestValidateWebhooks(t *testing.T) {
+	if err := ValidateWebhooks([]string{"http://ci.example.com/hook", "https://ci.example.com:8443/hook"}); err != nil {
+		t.Errorf("unexpected error, %v", err)
+	}
+	for _, webhook := range []string{"ftp://ci.example.com/hook", "ci.example.com/hook", "http://", "://illegal"} {
+		if err := ValidateWebhooks([]string{webhook}); err == nil {
+			t.Errorf("expect %s refused", webhook)
+		}
+	}
+}
+
+// TestWebhookRetry fails the first posts, the result is retried until the webhook accepts it
+func TestWebhookRetry(t *testing.T) {
+	defer func(backoff time.Duration) { webhookBackoff = backoff }(webhookBackoff)
+	webhookBackoff = time.Millisecond
+	var attempts int32
+	results := make(chan WebhookResult, webhookRetryTimes)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < webhookRetryTimes {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var result WebhookResult
+		json.NewDecoder(r.Body).Decode(&result)
+		results <- result
+	}))
+	defer server.Close()
+	notifier := NewNotifier()
+	notifier.Notify([]string{server.URL}, WebhookResult{Uid: "uid1", Phase: PhaseCreate, Success: true})
+	notifier.Close()
+	if attempts != webhookRetryTimes {
+		t.Errorf("expect %d attempts, but got %d", webhookRetryTimes, attempts)
+	}
+	select {
+	case result := <-results:
+		if result.Uid != "uid1" || result.Phase != PhaseCreate || !result.Success || result.Node == "" || result.Time.IsZero() {
+			t.Errorf("unexpected result %+v", result)
+		}
+	default:
+		t.Errorf("the result is not accepted after the retries")
+	}
+}
+
+// TestWebhookGiveUp posts to a webhook always failing and a healthy one, the failing one is given up after the
+// retries and the healthy one still receives the result
+func TestWebhookGiveUp(t *testing.T) {
+	defer func(backoff time.Duration) { webhookBackoff = backoff }(webhookBackoff)
+	webhookBackoff = time.Millisecond
+	var failed, accepted int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&failed, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&accepted, 1)
+	}))
+	defer healthy.Close()
+	notifier := NewNotifier()
+	notifier.Notify([]string{failing.URL, healthy.URL}, WebhookResult{Uid: "uid1", Phase: PhaseDestroy})
+	notifier.Close()
+	if failed != webhookRetryTimes {
+		t.Errorf("expect %d attempts of the failing webhook, but got %d", webhookRetryTimes, failed)
+	}
+	if accepted != 1 {
+		t.Errorf("expect the healthy webhook posted once, but got %d", accepted)
+	}
+}
@@ -20,16 +20,49 @@ import (
 	"context"
 	"fmt"
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"os"
+	"path"
+	"strconv"
 	"strings"
 
 	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/progress"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 )
 
+// NewClientFunc replaces the runtime client returned by GetClientByRuntime if set,
+// such as a mock.Container in the tests of the chaos flows
+var NewClientFunc func(expModel *spec.ExpModel) (container.Container, error)
+
 // BladeBin is the blade path in the chaosblade-tool image
 const BladeBin = "/opt/chaosblade/blade"
 const DstChaosBladeDir = "/opt"
 
+// DefaultBladeDir is the directory of the blade deployed into the container, BladeDirEnv of the agent or the blade
+// command changes it globally, and ChaosBladeDirFlag per experiment
+const DefaultBladeDir = "/opt/chaosblade"
+const BladeDirEnv = "CHAOSBLADE_CRI_BLADE_DIR"
+
+// ExecPolicyEnv is the json file of the exec policy of the agent or the blade command, the commands executed in the
+// target containers are restricted by it if set, see container.ExecPolicy
+const ExecPolicyEnv = "CHAOSBLADE_CRI_EXEC_POLICY"
+
+// RetryPolicyEnv is the json file of the retry policy of the commands executed in the containers, see
+// container.LoadRetryPolicy, container.DefaultRetryPolicy is used if not set
+const RetryPolicyEnv = "CHAOSBLADE_CRI_RETRY_POLICY"
+
+// ReadOnlyEnv set to true makes the runtime clients read-only, see ReadOnly
+const ReadOnlyEnv = "CHAOSBLADE_CRI_READ_ONLY"
+
+// ReadOnly makes the runtime clients returned by GetClientByRuntime read-only, the mutating methods fail with
+// container.ErrReadOnly, for the monitoring and reporting deployments
+var ReadOnly, _ = strconv.ParseBool(os.Getenv(ReadOnlyEnv))
+
+// BladeVersionFile is the version marker of the blade deployed into the container, it has the directory name of the
+// extracted release, such as chaosblade-1.7.2
+const BladeVersionFile = "/opt/chaosblade/.version"
+
 // BaseClientExecutor
 type BaseClientExecutor struct {
 	Client      container.Container
@@ -51,11 +84,15 @@ var CommonFunc = func(uid string, ctx context.Context, model *spec.ExpModel) str
 	matchers := spec.ConvertExpMatchersToString(model, func() map[string]spec.Empty {
 		return GetAllDockerFlagNames()
 	})
+	bladeBin := BladeBin
+	if dir := model.ActionFlags[ChaosBladeDirFlag.Name]; dir != "" {
+		bladeBin = nsexec.Quote(path.Join(dir, "blade"))
+	}
 	if _, ok := spec.IsDestroy(ctx); ok {
 		// UPDATE: https://github.com/chaosblade-io/chaosblade/issues/334
-		return fmt.Sprintf("%s destroy %s %s %s", BladeBin, model.Target, model.ActionName, matchers)
+		return fmt.Sprintf("%s destroy %s %s %s", bladeBin, model.Target, model.ActionName, matchers)
 	}
-	return fmt.Sprintf("%s create %s %s %s --uid %s", BladeBin, model.Target, model.ActionName, matchers, uid)
+	return fmt.Sprintf("%s create %s %s %s --uid %s", bladeBin, model.Target, model.ActionName, matchers, uid)
 }
 
 func ConvertContainerOutputToResponse(output string, err error, defaultResponse *spec.Response) *spec.Response {
@@ -95,20 +132,175 @@ func GetContainer(ctx context.Context, client container.Container, uid string, c
 		log.Errorf(ctx, err.Error())
 		return container, spec.ResponseFail(code, err.Error(), nil)
 	}
+	progress.FromContext(ctx).OnTargetResolved(ctx, uid, container)
 	return container, spec.ReturnSuccess(container)
 }
 
+// chaosOsFlags returns the action flags passed to chaos_os, the namespace flags, the timeout, the address family and
+// the direction are consumed here
+func chaosOsFlags(expModel *spec.ExpModel) map[string]string {
+	excluded := map[string]bool{"timeout": true, AddressFamilyFlag.Name: true, DirectionFlag.Name: true}
+	for _, f := range GetNSExecFlags() {
+		excluded[f.FlagName()] = true
+	}
+	flags := make(map[string]string, len(expModel.ActionFlags))
+	for k, v := range expModel.ActionFlags {
+		if v == "" || excluded[k] {
+			continue
+		}
+		flags[k] = v
+	}
+	return flags
+}
+
+// warnSharedVolume warns if the path of the file or disk experiment is on a volume shared with the other nodes,
+// e.g. the disk fill of a nfs persistent volume affects every pod mounting it
+func warnSharedVolume(ctx context.Context, info container.ContainerInfo, expModel *spec.ExpModel) {
+	if expModel.Target != "disk" && expModel.Target != "file" {
+		return
+	}
+	filePath := expModel.ActionFlags["path"]
+	if filePath == "" {
+		filePath = expModel.ActionFlags["filepath"]
+	}
+	if filePath == "" {
+		return
+	}
+	if mount, ok := info.MountOf(filePath); ok && mount.IsShared() {
+		log.Warnf(ctx, "the path %s of the %s experiment is on the shared %s volume %s mounted from %s, "+
+			"the pods of the other nodes are affected too", filePath, expModel.Target, mount.VolumeType,
+			mount.VolumeName, mount.HostPath)
+	}
+}
+
 func parseContainerLabelSelector(raw string) map[string]string {
 	labels := make(map[string]string, 0)
 
 	if raw != "" {
 		for _, label := range strings.Split(raw, ",") {
 			keyAndValue := strings.Split(label, "=")
-			if len(keyAndValue) == 2 {
-				labels[keyAndValue[0]] = keyAndValue[1]
+			if len(keyAndValue) != 2 {
+				continue
+			}
+			key := strings.TrimSpace(keyAndValue[0])
+			if key == "" {
+				continue
 			}
+			labels[key] = strings.TrimSpace(keyAndValue[1])
 		}
 	}
 
 	return labels
 }
+
+// rangeFlag parses the integer flag, the default value is returned if the flag is absent
+func rangeFlag(flags map[string]string, name string, defaultValue, minimum, maximum int) (int, *spec.Response) {
+	if flags[name] == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(flags[name])
+	if err != nil || value < minimum || value > maximum {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, name, flags[name],
+			fmt.Sprintf("it must be an integer from %d to %d", minimum, maximum))
+	}
+	return value, nil
+}
+
+// splitFlag splits the comma separated values of the flag, the empty ones are skipped
+func splitFlag(value string) []string {
+	values := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// getEndpoint returns the runtime endpoint, if the ssh-tunnel flag is set, the endpoint is the local socket
+// forwarded to the runtime socket of the remote node
+func getEndpoint(expModel *spec.ExpModel) (string, error) {
+	runtime := expModel.ActionFlags[ContainerRuntime.Name]
+	endpoint := expModel.ActionFlags[EndpointFlag.Name]
+	sshAddress := expModel.ActionFlags[SSHTunnelFlag.Name]
+	if sshAddress == "" {
+		return endpoint, nil
+	}
+	remoteSocket := expModel.ActionFlags[SSHRemoteSocketFlag.Name]
+	if remoteSocket == "" {
+		remoteSocket = container.DefaultRuntimeSockets[runtime]
+		if remoteSocket == "" {
+			remoteSocket = container.DefaultRuntimeSockets[container.DockerRuntime]
+		}
+	}
+	socket, err := container.OpenSSHTunnel(container.SSHTunnelConfig{
+		Address:        sshAddress,
+		KeyFile:        expModel.ActionFlags[SSHKeyFlag.Name],
+		KnownHostsFile: expModel.ActionFlags[SSHKnownHostsFlag.Name],
+		RemoteSocket:   remoteSocket,
+	})
+	if err != nil {
+		return "", err
+	}
+	// containerd dials the socket path, the others need the unix scheme
+	if runtime == container.ContainerdRuntime {
+		return socket, nil
+	}
+	return fmt.Sprintf("unix://%s", socket), nil
+}
+
+// Resident is set by the agent executing the experiments in its process, the ssh tunnels are kept for the next
+// experiments until it shuts down. Otherwise the blade command exits after the experiment, so its tunnels are closed
+var Resident bool
+
+// tunnelExecutor closes the ssh tunnels opened by the experiment of the blade command when it is executed, they
+// would be left with their local sockets as the command exits
+type tunnelExecutor struct {
+	spec.Executor
+}
+
+func (e tunnelExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	response := e.Executor.Exec(uid, ctx, model)
+	if !Resident && model.ActionFlags[SSHTunnelFlag.Name] != "" {
+		if err := container.CloseSSHTunnels(); err != nil {
+			log.Warnf(ctx, "%v", err)
+		}
+	}
+	return response
+}
+
+// newGuardedClient creates the runtime client through the circuit breaker of the endpoint, so that
+// an unhealthy runtime fails fast instead of blocking on dial or on every call
+func newGuardedClient(runtime, endpoint string, newClient func() (container.Container, error)) (container.Container, error) {
+	breaker := container.GetBreaker(runtime, endpoint)
+	if err := breaker.Allow(); err != nil {
+		return nil, err
+	}
+	client, err := newClient()
+	breaker.Done(err)
+	if err != nil {
+		return nil, err
+	}
+	client = container.WithManagedLabels(container.WithBreaker(client, breaker))
+	retryPolicy := container.DefaultRetryPolicy
+	if file := os.Getenv(RetryPolicyEnv); file != "" {
+		if retryPolicy, err = container.LoadRetryPolicy(file); err != nil {
+			return nil, err
+		}
+	}
+	// the retries are inside the policy, the denied commands are not retried
+	client = container.WithRetry(client, retryPolicy)
+	if file := os.Getenv(ExecPolicyEnv); file != "" {
+		// the experiments fail instead of running the commands unrestricted if the policy is broken
+		policy, err := container.LoadExecPolicy(file)
+		if err != nil {
+			return nil, err
+		}
+		client = container.WithExecPolicy(client, policy)
+	}
+	if ReadOnly {
+		client = container.WithReadOnly(client)
+	}
+	// the transcript is the outermost, the commands denied or refused are recorded with the errors
+	return container.WithTranscript(client), nil
+}
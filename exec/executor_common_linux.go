@@ -28,11 +28,15 @@ import (
 	"time"
 
 	osexec "github.com/chaosblade-io/chaosblade-exec-os/exec"
-	"github.com/chaosblade-io/chaosblade-exec-os/exec/model"
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
 	"github.com/containerd/cgroups"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/transcript"
 )
 
 // CommonExecutor is an executor implementation which used copy chaosblade tool to the target container and executed
@@ -64,58 +68,43 @@ func (r *CommonExecutor) Exec(uid string, ctx context.Context, expModel *spec.Ex
 	if !response.Success {
 		return response
 	}
+	warnSharedVolume(ctx, container, expModel)
 	pid, err, code := r.Client.GetPidById(ctx, container.ContainerId)
 	if err != nil {
 		log.Errorf(ctx, err.Error())
 		return spec.ResponseFail(code, err.Error(), nil)
 	}
 
-	var args string
-	var flags string
-
-	nsFlags := GetNSExecFlags()
-	m := make(map[string]string, len(nsFlags))
-	for _, f := range nsFlags {
-		m[f.FlagName()] = f.FlagName()
-	}
-
 	cgroupRoot := os.Getenv("CGROUP_ROOT")
 	if cgroupRoot != "" && expModel.ActionProcessHang {
 		expModel.ActionFlags["cgroup-root"] = cgroupRoot
 	}
 
-	for k, v := range expModel.ActionFlags {
-		if v == "" || m[k] != "" || k == "timeout" {
-			continue
-		}
-		flags = fmt.Sprintf("%s --%s=%s", flags, k, v)
-	}
 	_, isDestroy := spec.IsDestroy(ctx)
-
-	if isDestroy {
-		args = fmt.Sprintf("%s %s %s%s --uid=%s", spec.Destroy, expModel.Target, expModel.ActionName, flags, uid)
-	} else {
-		args = fmt.Sprintf("%s %s %s%s --uid=%s", spec.Create, expModel.Target, expModel.ActionName, flags, uid)
+	args, err := nsexec.ChaosOsArgs{
+		Destroy:    isDestroy,
+		Target:     expModel.Target,
+		Action:     expModel.ActionName,
+		Flags:      chaosOsFlags(expModel),
+		Uid:        uid,
+		Pid:        pid,
+		Namespaces: []nsexec.Namespace{nsexec.Pid, nsexec.Mount},
+	}.Build()
+	if err != nil {
+		return spec.ReturnFail(spec.OsCmdExecFailed, err.Error())
 	}
 
-	args = fmt.Sprintf("%s %s %s %s %s",
-		args,
-		fmt.Sprintf("--%s=%s", model.ChannelFlag.Name, spec.NSExecBin),
-		fmt.Sprintf("--%s=%d", model.NsTargetFlag.Name, pid),
-		fmt.Sprintf("--%s=%s", model.NsPidFlag.Name, spec.True),
-		fmt.Sprintf("--%s=%s", model.NsMntFlag.Name, spec.True),
-	)
-
 	if !isDestroy && expModel.ActionProcessHang {
 		return execForHangAction(uid, ctx, expModel, pid, args)
 	}
 
 	chaosOsBin := path.Join(util.GetProgramPath(), spec.BinPath, spec.ChaosOsBin)
-	argsArray := strings.Split(args, " ")
-
-	command := exec.CommandContext(ctx, chaosOsBin, argsArray...)
+	command := exec.CommandContext(ctx, chaosOsBin, args...)
+	start := time.Now()
 	output, err := command.CombinedOutput()
 	outMsg := string(output)
+	transcript.Record(ctx, container.ContainerId, nsexec.Command{Path: chaosOsBin, Args: args}.String(), start,
+		outMsg, err)
 	log.Debugf(ctx, "Command Result, output: %v, err: %v", outMsg, err)
 	if err != nil {
 		return spec.ReturnFail(spec.OsCmdExecFailed, fmt.Sprintf("command exec failed, %s", err.Error()))
@@ -131,47 +120,89 @@ func (r *CommonExecutor) DeployChaosBlade(ctx context.Context, containerId strin
 	return nil
 }
 
-func execForHangAction(uid string, ctx context.Context, expModel *spec.ExpModel, pid int32, args string) *spec.Response {
+// v1Path returns the paths of the v1 controllers of the cgroup, the controllers not mounted are skipped by the loader
+func v1Path(cgroupPath container.CgroupPath) cgroups.Path {
+	return func(name cgroups.Name) (string, error) {
+		if p, ok := cgroupPath.Controllers[string(name)]; ok {
+			return p, nil
+		}
+		if p, ok := cgroupPath.Controllers["name="+string(name)]; ok {
+			return p, nil
+		}
+		return "", cgroups.ErrControllerNotActive
+	}
+}
+
+func execForHangAction(uid string, ctx context.Context, expModel *spec.ExpModel, pid int32, args []string) *spec.Response {
 
 	chaosOsBin := path.Join(util.GetProgramPath(), spec.BinPath, spec.ChaosOsBin)
 
-	args = fmt.Sprintf("-s -t %d -p -n -- %s %s", pid, chaosOsBin, args)
+	cgroupRoot := getCgroupRoot(expModel)
+	log.Debugf(ctx, "cgroup root path %s", cgroupRoot)
 
-	argsArray := strings.Split(args, " ")
+	childPid, err := startInCgroup(ctx, pid, cgroupRoot, []nsexec.Namespace{nsexec.Pid, nsexec.Net},
+		append([]string{chaosOsBin}, args...))
+	if err != nil {
+		return spec.ReturnFail(spec.OsCmdExecFailed, err.Error())
+	}
+	return spec.ReturnSuccess(childPid)
+}
 
-	bin := path.Join(util.GetProgramPath(), spec.BinPath, spec.NSExecBin)
-	log.Debugf(ctx, "run command, %s %s", bin, args)
+// getCgroupRoot returns the cgroup root of the host, the environment CGROUP_ROOT wins over the cgroup-root flag
+func getCgroupRoot(expModel *spec.ExpModel) string {
+	if cgroupRoot := os.Getenv("CGROUP_ROOT"); cgroupRoot != "" {
+		return cgroupRoot
+	}
+	if cgroupRoot := expModel.ActionFlags["cgroup-root"]; cgroupRoot != "" {
+		return cgroupRoot
+	}
+	return "/sys/fs/cgroup/"
+}
 
-	command := exec.CommandContext(ctx, bin, argsArray...)
-	command.SysProcAttr = &syscall.SysProcAttr{}
+// startInCgroup starts the command in the namespaces of the target process by nsexec, nsexec is suspended until it
+// joined the cgroups of the target so the command never runs outside of them, it returns the pid of nsexec
+func startInCgroup(ctx context.Context, pid int32, cgroupRoot string, namespaces []nsexec.Namespace, argv []string) (
+	childPid int, err error) {
+	start := time.Now()
+	// the command outlives the request, the transcript records whether it is started
+	defer func() {
+		transcript.Record(ctx, transcript.PidTarget(pid), strings.Join(argv, " "), start, "", err)
+	}()
+	bin, err := nsexec.Bin()
+	if err != nil {
+		return 0, err
+	}
 
-	cgroupRoot := os.Getenv("CGROUP_ROOT")
-	if cgroupRoot == "" {
-		cgroupRoot = expModel.ActionFlags["cgroup-root"]
-		if cgroupRoot == "" {
-			cgroupRoot = "/sys/fs/cgroup/"
-		}
+	nsCommand, err := nsexec.New(bin, pid).Suspend().Namespaces(namespaces...).Argv(argv...).Build()
+	if err != nil {
+		return 0, err
 	}
+	log.Debugf(ctx, "run command, %s", nsCommand)
 
-	log.Debugf(ctx, "cgroup root path %s", cgroupRoot)
+	// the command outlives the request, it is stopped by the destroy of the experiment
+	command := exec.Command(nsCommand.Path, nsCommand.Args...)
+	command.SysProcAttr = &syscall.SysProcAttr{}
+	command.Env = trace.Environ(ctx)
 
-	control, err := cgroups.Load(osexec.Hierarchy(cgroupRoot), osexec.PidPath(int(pid)))
+	cgroupPath, err := container.ResolveCgroupPath(pid, "")
+	if err != nil {
+		return 0, fmt.Errorf("cgroups resolve failed, %s", err.Error())
+	}
+	control, err := cgroups.Load(osexec.Hierarchy(cgroupRoot), v1Path(cgroupPath))
 	if err != nil {
-		sprintf := fmt.Sprintf("cgroups load failed, %s", err.Error())
-		return spec.ReturnFail(spec.OsCmdExecFailed, sprintf)
+		return 0, fmt.Errorf("cgroups load failed, %s", err.Error())
 	}
 
 	if err := command.Start(); err != nil {
-		sprintf := fmt.Sprintf("command start failed, %s", err.Error())
-		return spec.ReturnFail(spec.OsCmdExecFailed, sprintf)
+		return 0, fmt.Errorf("command start failed, %s", err.Error())
 	}
 
 	// add target cgroups
 	if err = control.Add(cgroups.Process{Pid: command.Process.Pid}); err != nil {
-		if err := command.Process.Kill(); err != nil {
-			sprintf := fmt.Sprintf("create experiment failed, %v", err)
-			return spec.ReturnFail(spec.OsCmdExecFailed, sprintf)
+		if killErr := command.Process.Kill(); killErr != nil {
+			return 0, fmt.Errorf("create experiment failed, %v", killErr)
 		}
+		return 0, fmt.Errorf("cgroups add failed, %s", err.Error())
 	}
 
 	signal := make(chan bool, 1)
@@ -190,7 +221,6 @@ func execForHangAction(uid string, ctx context.Context, expModel *spec.ExpModel,
 					}
 				}
 
-
 				log.Infof(ctx, "wait nsexec process pasue, current comm: %s, pid: %d", comm, command.Process.Pid)
 				if comm == "pause\n" {
 					signal <- true
@@ -204,8 +234,7 @@ func execForHangAction(uid string, ctx context.Context, expModel *spec.ExpModel,
 	if <-signal {
 		for {
 			if err := command.Process.Signal(syscall.SIGCONT); err != nil {
-				sprintf := fmt.Sprintf("send signal failed, %s", err.Error())
-				return spec.ReturnFail(spec.OsCmdExecFailed, sprintf)
+				return 0, fmt.Errorf("send signal failed, %s", err.Error())
 			}
 			time.Sleep(time.Millisecond)
 
@@ -228,7 +257,7 @@ func execForHangAction(uid string, ctx context.Context, expModel *spec.ExpModel,
 			}
 		}
 	}
-	return spec.ReturnSuccess(command.Process.Pid)
+	return command.Process.Pid, nil
 }
 
 func getProcessComm(pid int) (string, error) {
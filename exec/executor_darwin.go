@@ -17,11 +17,69 @@
 package exec
 
 import (
+	"context"
+
 	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
 	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/docker"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 )
 
 func GetClientByRuntime(expModel *spec.ExpModel) (container.Container, error) {
-	return docker.NewClient(expModel.ActionFlags[EndpointFlag.Name])
+	if NewClientFunc != nil {
+		return NewClientFunc(expModel)
+	}
+	endpoint, err := getEndpoint(expModel)
+	if err != nil {
+		return nil, err
+	}
+	return newGuardedClient(expModel.ActionFlags[ContainerRuntime.Name], endpoint, func() (container.Container, error) {
+		return docker.NewClient(endpoint)
+	})
+}
+
+// CloseClients closes the cached runtime clients
+func CloseClients() error {
+	if err := docker.CloseClient(); err != nil {
+		return err
+	}
+	return container.CloseSSHTunnels()
+}
+
+// execByFamily executes the network action of the ipv4 family by chaos_os, the ipv6 and the dual families are
+// supported on linux only
+func execByFamily(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, family string,
+	isDestroy bool) *spec.Response {
+	if family == FamilyIPv4 {
+		return execChaosOsNetwork(ctx, uid, expModel, pid, chaosOsFlags(expModel), isDestroy)
+	}
+	return spec.ResponseFailWithFlags(spec.ParameterInvalid, AddressFamilyFlag.Name, family,
+		"the ipv6 and the dual families are supported on linux only")
+}
+
+// execTcNetwork executes the tc action by chaos_os, the qdiscs are inspected on linux only
+func execTcNetwork(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, flags map[string]string,
+	isDestroy bool) *spec.Response {
+	return execChaosOsNetwork(ctx, uid, expModel, pid, flags, isDestroy)
+}
+
+// execProtocolNetwork executes the tc action for the packets of the protocol only, it is supported on linux only
+func execProtocolNetwork(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, family string,
+	isDestroy bool) *spec.Response {
+	return spec.ResponseFailWithFlags(spec.ParameterInvalid, ProtocolFlag.Name, expModel.ActionFlags[ProtocolFlag.Name],
+		"the protocol flag is supported on linux only")
+}
+
+// execDirectionNetwork executes the tc action on the ingress of the interface, it is supported on linux only
+func execDirectionNetwork(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, family string,
+	isDestroy bool) *spec.Response {
+	return spec.ResponseFailWithFlags(spec.ParameterInvalid, DirectionFlag.Name, expModel.ActionFlags[DirectionFlag.Name],
+		"the ingress direction is supported on linux only")
+}
+
+// snapshotNetwork records the network state of the container, it is supported on linux only
+func snapshotNetwork(ctx context.Context, uid, containerId string, pid int32) {
+}
+
+// verifyNetwork compares the network state of the container with the snapshot, it is supported on linux only
+func verifyNetwork(ctx context.Context, uid string, pid int32) {
 }
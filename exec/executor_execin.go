@@ -24,13 +24,21 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/bundle"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rollback"
 	"github.com/chaosblade-io/chaosblade-exec-cri/version"
-	"github.com/chaosblade-io/chaosblade-spec-go/channel"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
 )
 
 var defaultBladeTarFilePath = fmt.Sprintf("/opt/chaosblade-%s.tar.gz", version.BladeVersion)
 
+// BundleCache returns the cache of the chaosblade releases on the node, it is replaced in the tests
+var BundleCache = func() *bundle.Fetcher {
+	return bundle.NewFetcher(path.Join(util.GetProgramPath(), "bundles"))
+}
+
 // RunCmdInContainerExecutor is an executor interface which executes command in the target container directly
 type RunCmdInContainerExecutor interface {
 	spec.Executor
@@ -60,58 +68,106 @@ func (r *RunCmdInContainerExecutorByCP) Exec(uid string, ctx context.Context, ex
 		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
 	}
 	containerId := expModel.ActionFlags[ContainerIdFlag.Name]
+	if recreated, ok := recreatedContainer(ctx, containerId); ok {
+		containerId = recreated
+	}
 	containerName := expModel.ActionFlags[ContainerNameFlag.Name]
 	containerLabelSelector := parseContainerLabelSelector(expModel.ActionFlags[ContainerLabelSelectorFlag.Name])
 	container, response := GetContainer(ctx, r.Client, uid, containerId, containerName, containerLabelSelector)
 	if !response.Success {
 		return response
 	}
-	command := r.CommandFunc(uid, ctx, expModel)
+	bladeDir, err := resolveBladeDir(ctx, container.ContainerId, expModel)
+	if err != nil {
+		log.Errorf(ctx, "`%s`: chaosblade-dir parameter is illegal, %v", expModel.ActionFlags[ChaosBladeDirFlag.Name], err)
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, ChaosBladeDirFlag.Name,
+			expModel.ActionFlags[ChaosBladeDirFlag.Name], err)
+	}
+	// the command runs the blade in the resolved directory, the model of the experiment is not changed
+	commandModel := *expModel
+	commandModel.ActionFlags = make(map[string]string, len(expModel.ActionFlags)+1)
+	for k, v := range expModel.ActionFlags {
+		commandModel.ActionFlags[k] = v
+	}
+	commandModel.ActionFlags[ChaosBladeDirFlag.Name] = bladeDir
+	command := r.CommandFunc(uid, ctx, &commandModel)
+	steps := rollback.New(ctx, uid)
 	if _, ok := spec.IsDestroy(ctx); !ok {
 		// Create
 		chaosbladeReleaseFile := expModel.ActionFlags[ChaosBladeReleaseFlag.Name]
 		if chaosbladeReleaseFile == "" {
 			chaosbladeReleaseFile = defaultBladeTarFilePath
 		}
+		inject := expModel.ActionFlags[ChaosBladeInjectFlag.Name]
+		if inject != "" && inject != InjectCopy && inject != InjectMount && inject != InjectImage {
+			log.Errorf(ctx, "`%s`: chaosblade-inject parameter is illegal", inject)
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, ChaosBladeInjectFlag.Name, inject, "only support copy, mount and image")
+		}
 		overrideValue := expModel.ActionFlags[ChaosBladeOverrideFlag.Name]
 		override, err := strconv.ParseBool(overrideValue)
 		if err != nil {
 			override = false
 		}
-		if resp, ok := channel.NewLocalChannel().IsAllCommandsAvailable(ctx, []string{"tar"}); !ok {
-			log.Errorf(ctx, resp.Err)
-			return resp
-		}
-
-		response := channel.NewLocalChannel().Run(context.Background(), "tar",
-			fmt.Sprintf("tf %s| head -1 | cut -f1 -d/", chaosbladeReleaseFile))
-		if !response.Success {
-			log.Errorf(ctx, "`%s`: chaosblade-release parameter is invalid, err: %s", chaosbladeReleaseFile, response.Err)
-			return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeReleaseFlag.Name, chaosbladeReleaseFile, response.Err)
-		}
-		if response.Result == nil {
-			log.Errorf(ctx, "`%s`: chaosblade-release parameter is invalid, extract directory failed", chaosbladeReleaseFile)
-			return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeReleaseFlag.Name, chaosbladeReleaseFile, "the obtained directory name is nil")
-		}
-		extractedDirName := strings.TrimSpace(response.Result.(string))
-		if extractedDirName == "" {
-			log.Errorf(ctx, "`%s`: chaosblade-release parameter is invalid, extract empty directory failed", chaosbladeReleaseFile)
-			return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeReleaseFlag.Name, chaosbladeReleaseFile, "the obtained directory name is empty")
-
-		}
-		err = r.DeployChaosBlade(ctx, container.ContainerId, chaosbladeReleaseFile, extractedDirName, override)
+		// the release is staged once in the cache on the node, the copies to the containers read the staged file
+		digest := expModel.ActionFlags[ChaosBladeReleaseDigestFlag.Name]
+		entry, err := BundleCache().Stage(ctx, chaosbladeReleaseFile, digest)
 		if err != nil {
-			log.Errorf(ctx, "DeployChaosBlade err: %v", err)
-			return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "DeployChaosBlade", err)
+			log.Errorf(ctx, "`%s`: chaosblade-release parameter is invalid, err: %v", chaosbladeReleaseFile, err)
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeReleaseFlag.Name, chaosbladeReleaseFile, err)
+		}
+		log.Infof(ctx, "chaosblade-release %s is staged to %s, hits: %d", chaosbladeReleaseFile, entry.File, entry.Hits)
+		if inject == InjectImage {
+			// the filesystem of the container is never written, the experiment runs in the container recreated
+			recreatedId, err := r.recreateWithChaosBlade(ctx, uid, container.ContainerId, entry, bladeDir, expModel)
+			if err != nil {
+				log.Errorf(ctx, "RecreateContainer err: %v", err)
+				return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "RecreateContainer", err)
+			}
+			container.ContainerId = recreatedId
+		} else {
+			if err := checkBladeDir(ctx, r.Client, container.ContainerId, bladeDir); err != nil {
+				log.Errorf(ctx, "`%s`: chaosblade-dir parameter is invalid, %v", bladeDir, err)
+				return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeDirFlag.Name, bladeDir, err)
+			}
+			chaosbladeReleaseFile, extractedDirName := entry.File, entry.DirName
+			var toolkitDir string
+			if inject == InjectMount {
+				if toolkitDir, err = BundleCache().Extract(entry); err != nil {
+					log.Warnf(ctx, "extract %s failed, copy the chaosblade tool instead, %v", chaosbladeReleaseFile, err)
+				}
+			}
+			deployed, mount, err := r.deployChaosBlade(ctx, container.ContainerId, chaosbladeReleaseFile, extractedDirName,
+				toolkitDir, bladeDir, override)
+			if err != nil {
+				log.Errorf(ctx, "DeployChaosBlade err: %v", err)
+				return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "DeployChaosBlade", err)
+			}
+			trackDeployment(ctx, uid, container.ContainerId, bladeDir, deployed, mount, expModel)
 		}
+		// the blade deployed, or the container recreated, is released if the experiment failed in the container
+		containerId := container.ContainerId
+		steps.Done("deploy chaosblade", func() error {
+			r.releaseDeployment(ctx, uid, containerId, expModel)
+			return nil
+		})
 	}
 	output, err := r.Client.ExecContainer(ctx, container.ContainerId, command)
 	var defaultResponse *spec.Response
 	if err != nil {
 		log.Errorf(ctx, "execContainer err: %v", err)
-		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "execContainer", err)
+		return steps.Fail(spec.ResponseFailWithFlags(spec.ContainerExecFailed, "execContainer", err))
+	}
+	response = ConvertContainerOutputToResponse(output, err, defaultResponse)
+	if !response.Success {
+		return steps.Fail(response)
 	}
-	return ConvertContainerOutputToResponse(output, err, defaultResponse)
+	if suid, ok := spec.IsDestroy(ctx); ok && response.Success {
+		if suid != "" {
+			uid = suid
+		}
+		r.releaseDeployment(ctx, uid, container.ContainerId, expModel)
+	}
+	return response
 	//return spec.Success()
 }
 
@@ -120,27 +176,65 @@ func (r *RunCmdInContainerExecutorByCP) SetChannel(channel spec.Channel) {
 
 func (r *RunCmdInContainerExecutorByCP) DeployChaosBlade(ctx context.Context, containerId string,
 	srcFile, extractDirName string, override bool) error {
-	// check if the blade tool exists
-	// todo for test
-	output, err := r.Client.ExecContainer(ctx, containerId, fmt.Sprintf("[ -e %s ] && echo True || echo False", BladeBin))
-	if err == nil && strings.Contains(output, "True") && !override {
-		return nil
+	_, _, err := r.deployChaosBlade(ctx, containerId, srcFile, extractDirName, "", DefaultBladeDir, override)
+	return err
+}
+
+// deployChaosBlade returns true if the release is deployed into the container, false if the deployed blade is reused.
+// The toolkit dir extracted on the node, if not empty, is mounted into the container instead of copying the release,
+// and the host mount target is returned. The blade is deployed to the blade dir in the container
+func (r *RunCmdInContainerExecutorByCP) deployChaosBlade(ctx context.Context, containerId string,
+	srcFile, extractDirName, toolkitDir, bladeDir string, override bool) (bool, string, error) {
+	bladeBin, versionFile := nsexec.Quote(path.Join(bladeDir, "blade")), nsexec.Quote(path.Join(bladeDir, ".version"))
+	// the deployed blade is reused only if its version marker matches the release, the stale blade left by the
+	// agent of another version, or deployed before the marker, is deployed again
+	output, err := r.Client.ExecContainer(ctx, containerId,
+		fmt.Sprintf("[ -e %s ] && cat %s 2>/dev/null", bladeBin, versionFile))
+	deployed := strings.TrimSpace(output)
+	if err == nil && deployed == extractDirName && !override {
+		return false, "", nil
+	}
+	if err == nil && deployed != extractDirName {
+		log.Infof(ctx, "the blade %s in container %s mismatches the release %s, deploy it again", deployed,
+			containerId, extractDirName)
+	}
+	if err := checkDeployTarget(ctx, r.Client, containerId, bladeDir, extractDirName); err != nil {
+		return false, "", err
+	}
+	markCmd := fmt.Sprintf("echo %s > %s", nsexec.Quote(extractDirName), versionFile)
+	if toolkitDir != "" {
+		mount, ok, err := r.mountChaosBlade(ctx, containerId, toolkitDir, bladeDir)
+		if err != nil {
+			return false, "", err
+		}
+		if ok {
+			_, err = r.Client.ExecContainer(ctx, containerId, markCmd)
+			return true, mount, err
+		}
 	}
 
-	err = r.Client.CopyToContainer(ctx, containerId, srcFile, DstChaosBladeDir, extractDirName, override)
+	extractPath, err := r.Client.CopyToContainer(ctx, containerId, srcFile, path.Dir(bladeDir), extractDirName, override)
 	if err != nil {
-		return err
+		return false, "", err
 	}
 
-	dstBladeDir := path.Join(DstChaosBladeDir, extractDirName)
-	expectBladeDir := path.Join(DstChaosBladeDir, "chaosblade")
+	if extractPath == bladeDir || strings.HasPrefix(extractPath, bladeDir+"/") {
+		return true, "", fmt.Errorf("the blade directory %s must not contain the extract path %s", bladeDir, extractPath)
+	}
+	dstBladeDir := nsexec.Quote(extractPath)
+	expectBladeDir := nsexec.Quote(bladeDir)
 	rmCmd := fmt.Sprintf("rm -rf %s", expectBladeDir)
 	_, err = r.Client.ExecContainer(ctx, containerId, rmCmd)
 	if err != nil {
-		return err
+		return true, "", err
 	}
 
 	renameCmd := fmt.Sprintf("mv %s %s", dstBladeDir, expectBladeDir)
 	_, err = r.Client.ExecContainer(ctx, containerId, renameCmd)
-	return err
+	if err != nil {
+		return true, "", err
+	}
+
+	_, err = r.Client.ExecContainer(ctx, containerId, markCmd)
+	return true, "", err
 }
@@ -0,0 +1,506 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/bundle"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+)
+
+func TestDeployChaosBladeVersion(t *testing.T) {
+	tests := []struct {
+		deployed string
+		err      error
+		override bool
+		copied   bool
+	}{
+		{"chaosblade-1.7.2\n", nil, false, false},
+		{"chaosblade-1.7.2\n", nil, true, true},
+		{"chaosblade-1.6.0\n", nil, false, true},
+		// the blade is absent, or deployed without the marker
+		{"", errors.New("exit status 1"), false, true},
+	}
+	for _, test := range tests {
+		client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+		client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+			if strings.Contains(command, BladeVersionFile) && strings.HasPrefix(command, "[ -e") {
+				return test.deployed, test.err
+			}
+			return "", nil
+		}
+		executor := &RunCmdInContainerExecutorByCP{BaseClientExecutor{Client: client}}
+		if err := executor.DeployChaosBlade(context.Background(), "c1", "/opt/chaosblade-1.7.2.tar.gz",
+			"chaosblade-1.7.2", test.override); err != nil {
+			t.Fatalf("deploy failed, %v", err)
+		}
+		if copied := len(client.CallsOf("CopyToContainer")) > 0; copied != test.copied {
+			t.Errorf("expected copied %v of %q, override %v", test.copied, test.deployed, test.override)
+		}
+		if !test.copied {
+			continue
+		}
+		execs := client.CallsOf("ExecContainer")
+		if last := execs[len(execs)-1].Args[1]; last != "echo chaosblade-1.7.2 > "+BladeVersionFile {
+			t.Errorf("expected the version marker written, got %s", last)
+		}
+	}
+}
+
+func TestDeploymentCleanup(t *testing.T) {
+	deployments := journal.OpenDeployments(path.Join(t.TempDir(), "deployments.json"))
+	origin := OpenDeployments
+	OpenDeployments = func() *journal.Deployments { return deployments }
+	defer func() { OpenDeployments = origin }()
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"}, container.ContainerInfo{ContainerId: "c2"})
+	executor := &RunCmdInContainerExecutorByCP{BaseClientExecutor{Client: client}}
+	ctx := context.Background()
+	model := &spec.ExpModel{ActionFlags: map[string]string{ContainerIdFlag.Name: "c1"}}
+	retained := &spec.ExpModel{ActionFlags: map[string]string{ContainerIdFlag.Name: "c2", ChaosBladeRetainFlag.Name: "true"}}
+
+	trackDeployment(ctx, "uid1", "c1", DefaultBladeDir, true, "", model)
+	trackDeployment(ctx, "uid2", "c1", DefaultBladeDir, false, "", model)
+	trackDeployment(ctx, "uid3", "c2", DefaultBladeDir, true, "", retained)
+	// the blade in the image is not tracked
+	trackDeployment(ctx, "uid4", "c3", DefaultBladeDir, false, "", model)
+	if list, err := deployments.List(); err != nil || len(list) != 2 || len(list[0].Uids) != 2 {
+		t.Fatalf("expected the deployments of c1 and c2, got %+v, %v", list, err)
+	}
+
+	executor.releaseDeployment(ctx, "uid1", "c1", model)
+	if calls := client.CallsOf("ExecContainer"); len(calls) != 0 {
+		t.Fatalf("expected the files used by uid2 kept, got %+v", calls)
+	}
+	executor.releaseDeployment(ctx, "uid2", "c1", model)
+	calls := client.CallsOf("ExecContainer")
+	if len(calls) != 1 || calls[0].Args[1] != "rm -rf /opt/chaosblade" {
+		t.Fatalf("expected the files removed, got %+v", calls)
+	}
+	executor.releaseDeployment(ctx, "uid3", "c2", retained)
+	if calls := client.CallsOf("ExecContainer"); len(calls) != 1 {
+		t.Errorf("expected the retained files kept, got %+v", calls)
+	}
+	if list, err := deployments.List(); err != nil || len(list) != 0 {
+		t.Errorf("expected no deployment left, got %+v, %v", list, err)
+	}
+}
+
+func createRelease(t *testing.T, dir string) string {
+	t.Helper()
+	release := path.Join(dir, "chaosblade-1.7.2-linux-amd64.tar.gz")
+	if output, err := exec.Command("sh", "-c", "mkdir -p "+dir+"/chaosblade-1.7.2 && tar -czf "+release+
+		" -C "+dir+" chaosblade-1.7.2").CombinedOutput(); err != nil {
+		t.Fatalf("create release failed, %s, %v", output, err)
+	}
+	return release
+}
+
+func TestExecStagedRelease(t *testing.T) {
+	dir := t.TempDir()
+	release := createRelease(t, dir)
+	content, _ := os.ReadFile(release)
+	sum := sha256.Sum256(content)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write(content)
+	}))
+	defer server.Close()
+	cache := bundle.NewFetcher(path.Join(dir, "bundles"))
+	originCache, originDeployments := BundleCache, OpenDeployments
+	BundleCache = func() *bundle.Fetcher { return cache }
+	OpenDeployments = func() *journal.Deployments { return journal.OpenDeployments(path.Join(dir, "deployments.json")) }
+	defer func() { BundleCache, OpenDeployments = originCache, originDeployments }()
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"}, container.ContainerInfo{ContainerId: "c2"})
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+
+	executor := NewRunCmdInContainerExecutorByCP()
+	for _, containerId := range []string{"c1", "c2"} {
+		model := &spec.ExpModel{Target: "cpu", ActionName: "fullload", ActionFlags: map[string]string{
+			ContainerIdFlag.Name: containerId, ChaosBladeReleaseFlag.Name: server.URL + "/chaosblade-1.7.2-linux-amd64.tar.gz",
+			ChaosBladeReleaseDigestFlag.Name: digest,
+		}}
+		if response := executor.Exec("uid-"+containerId, context.Background(), model); !response.Success {
+			t.Fatalf("exec failed, %s", response.Err)
+		}
+	}
+	staged := cache.Path(digest, "chaosblade-1.7.2-linux-amd64.tar.gz")
+	copies := client.CallsOf("CopyToContainer")
+	if len(copies) != 2 || copies[1].Args[1] != staged || copies[1].Args[3] != "chaosblade-1.7.2" {
+		t.Errorf("expected the staged release copied, got %+v", copies)
+	}
+	if stats, err := cache.Stats(); err != nil || requests != 1 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected the release downloaded once, requests %d, stats %+v, %v", requests, stats, err)
+	}
+}
+
+func TestDeployChaosBladeMount(t *testing.T) {
+	var attached, detached []string
+	attachErr := error(nil)
+	originAttach, originDetach, originRunDir := attachOverlay, detachOverlay, hostMountRunDir
+	attachOverlay = func(pid int32, lower, upper, work, target string) error {
+		attached = append(attached, fmt.Sprintf("%d %s %s %s %s", pid, lower, upper, work, target))
+		return attachErr
+	}
+	detachOverlay = func(pid int32, target string) error {
+		detached = append(detached, fmt.Sprintf("%d %s", pid, target))
+		return nil
+	}
+	mounted := false
+	originMounted := overlayMounted
+	overlayMounted = func(pid int32, upper string) (bool, error) {
+		return mounted, nil
+	}
+	hostMountRunDir = path.Join(t.TempDir(), "mounts")
+	defer func() {
+		attachOverlay, detachOverlay, hostMountRunDir = originAttach, originDetach, originRunDir
+		overlayMounted = originMounted
+	}()
+	deployments := journal.OpenDeployments(path.Join(t.TempDir(), "deployments.json"))
+	originDeployments := OpenDeployments
+	OpenDeployments = func() *journal.Deployments { return deployments }
+	defer func() { OpenDeployments = originDeployments }()
+	ctx := context.Background()
+	runDir := path.Join(hostMountRunDir, "c1")
+
+	for _, failed := range []bool{false, true} {
+		attached, detached, attachErr = nil, nil, nil
+		if failed {
+			attachErr = errors.New("function not implemented")
+		}
+		client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+		client.GetPidByIdFunc = func(ctx context.Context, containerId string) (int32, error, int32) {
+			return 4242, nil, 0
+		}
+		client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+			if strings.HasPrefix(command, "[ -e") {
+				return "", errors.New("exit status 1")
+			}
+			return "", nil
+		}
+		executor := &RunCmdInContainerExecutorByCP{BaseClientExecutor{Client: client}}
+		deployed, mount, err := executor.deployChaosBlade(ctx, "c1", "/root/chaosblade-1.7.2.tar.gz",
+			"chaosblade-1.7.2", "/root/bundles/sha256-1/chaosblade-1.7.2", DefaultBladeDir, false)
+		if err != nil || !deployed {
+			t.Fatalf("deploy failed, %v", err)
+		}
+		// the overlay is attached in the mount namespace of the container, the layers are on the host
+		expected := fmt.Sprintf("4242 /root/bundles/sha256-1/chaosblade-1.7.2 %s/upper %s/work /opt/chaosblade", runDir, runDir)
+		if len(attached) != 1 || attached[0] != expected {
+			t.Fatalf("expected the overlay %s attached, got %v", expected, attached)
+		}
+		copied := len(client.CallsOf("CopyToContainer")) > 0
+		if failed {
+			// the failed mount leaves no layers, and the toolkit is copied
+			if _, err := os.Stat(runDir); !copied || mount != "" || !os.IsNotExist(err) {
+				t.Errorf("expected the toolkit copied, got %s, copied %v, %v", mount, copied, err)
+			}
+			continue
+		}
+		if _, err := os.Stat(path.Join(runDir, "upper")); copied || mount != "/opt/chaosblade" || err != nil {
+			t.Fatalf("expected the toolkit mounted, got %s, copied %v, %v", mount, copied, err)
+		}
+		trackDeployment(ctx, "uid1", "c1", DefaultBladeDir, deployed, mount,
+			&spec.ExpModel{ActionFlags: map[string]string{}})
+		executor.releaseDeployment(ctx, "uid1", "c1", &spec.ExpModel{ActionFlags: map[string]string{}})
+		execs := client.CallsOf("ExecContainer")
+		if len(detached) != 1 || detached[0] != "4242 /opt/chaosblade" ||
+			execs[len(execs)-1].Args[1] != "rm -rf /opt/chaosblade" {
+			t.Errorf("expected the toolkit unmounted and removed, got %v, %+v", detached, execs)
+		}
+		if _, err := os.Stat(runDir); !os.IsNotExist(err) {
+			t.Errorf("expected the layers removed, got %v", err)
+		}
+	}
+
+	// the overlay left by the deployment before is unmounted before its layers are replaced, and the layers are kept
+	// if it is still mounted
+	stale := path.Join(runDir, "upper", "chaosblade.dat")
+	for _, stillMounted := range []bool{false, true} {
+		attached, detached, attachErr, mounted = nil, nil, nil, stillMounted
+		if err := os.MkdirAll(path.Dir(stale), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(stale, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+		client.GetPidByIdFunc = func(ctx context.Context, containerId string) (int32, error, int32) {
+			return 4242, nil, 0
+		}
+		executor := &RunCmdInContainerExecutorByCP{BaseClientExecutor{Client: client}}
+		mount, ok, err := executor.mountChaosBlade(ctx, "c1", "/root/bundles/sha256-1/chaosblade-1.7.2",
+			DefaultBladeDir)
+		if len(detached) != 1 || detached[0] != "4242 /opt/chaosblade" {
+			t.Errorf("expected the stale overlay unmounted first, got %v", detached)
+		}
+		_, statErr := os.Stat(stale)
+		if stillMounted {
+			if err == nil || ok || len(attached) != 0 || statErr != nil {
+				t.Errorf("expected the mount refused and the layers kept, got %s, %v, %v, %v", mount, ok, err, statErr)
+			}
+			continue
+		}
+		if err != nil || !ok || len(attached) != 1 || !os.IsNotExist(statErr) {
+			t.Errorf("expected the layers replaced, got %s, %v, %v, %v", mount, ok, err, statErr)
+		}
+	}
+}
+
+func TestExecBladeDir(t *testing.T) {
+	dir := t.TempDir()
+	release := createRelease(t, dir)
+	originCache, originDeployments := BundleCache, OpenDeployments
+	BundleCache = func() *bundle.Fetcher { return bundle.NewFetcher(path.Join(dir, "bundles")) }
+	OpenDeployments = func() *journal.Deployments { return journal.OpenDeployments(path.Join(dir, "deployments.json")) }
+	defer func() { BundleCache, OpenDeployments = originCache, originDeployments }()
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"}, container.ContainerInfo{ContainerId: "c2"},
+		container.ContainerInfo{ContainerId: "c3"})
+	client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+		if strings.HasPrefix(command, "[ -e") || (containerId == "c2" && strings.HasPrefix(command, "mkdir -p /data")) {
+			return "read-only file system", errors.New("exit status 1")
+		}
+		if containerId == "c3" && strings.HasPrefix(command, "if [ ! -e") {
+			return "foreign", nil
+		}
+		return `{"code":200,"success":true}`, nil
+	}
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	t.Setenv(BladeDirEnv, "/data/chaosblade")
+	executor := NewRunCmdInContainerExecutorByCP()
+	flags := map[string]string{ContainerIdFlag.Name: "c1", ChaosBladeReleaseFlag.Name: release}
+
+	if response := executor.Exec("uid1", context.Background(), &spec.ExpModel{Target: "cpu", ActionName: "fullload",
+		ActionFlags: flags}); !response.Success {
+		t.Fatalf("exec failed, %s", response.Err)
+	}
+	commands := make([]string, 0)
+	for _, call := range client.CallsOf("ExecContainer") {
+		commands = append(commands, call.Args[1].(string))
+	}
+	expected := []string{
+		"mkdir -p /data && touch /data/.chaosblade-probe && rm -f /data/.chaosblade-probe",
+		"[ -e /data/chaosblade/blade ] && cat /data/chaosblade/.version 2>/dev/null",
+		"if [ ! -e /data/chaosblade ]; then echo absent; elif [ -e /data/chaosblade/blade ] || " +
+			"[ -e /data/chaosblade/.version ]; then echo chaosblade; else echo foreign; fi",
+		"rm -rf /data/chaosblade",
+		"mv /data/chaosblade-1.7.2 /data/chaosblade",
+		"echo chaosblade-1.7.2 > /data/chaosblade/.version",
+	}
+	if len(commands) != 7 || strings.Join(commands[:6], "\n") != strings.Join(expected, "\n") ||
+		!strings.HasPrefix(commands[6], "/data/chaosblade/blade create cpu fullload") {
+		t.Fatalf("unexpected commands %q", commands)
+	}
+	if copies := client.CallsOf("CopyToContainer"); len(copies) != 1 || copies[0].Args[2] != "/data" {
+		t.Errorf("expected the release copied to /data, got %+v", copies)
+	}
+	if _, ok := flags[ChaosBladeDirFlag.Name]; ok {
+		t.Errorf("expected the flags of the experiment unchanged")
+	}
+
+	// the destroy runs the blade recorded by the deployment, even the environment is changed
+	t.Setenv(BladeDirEnv, "")
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := executor.Exec("uid1", ctx, &spec.ExpModel{Target: "cpu", ActionName: "fullload",
+		ActionFlags: map[string]string{ContainerIdFlag.Name: "c1"}}); !response.Success {
+		t.Fatalf("destroy failed, %s", response.Err)
+	}
+	execs := client.CallsOf("ExecContainer")
+	if destroy := execs[len(execs)-2].Args[1].(string); !strings.HasPrefix(destroy, "/data/chaosblade/blade destroy cpu fullload") {
+		t.Errorf("expected the recorded blade destroyed the experiment, got %s", destroy)
+	}
+	if rm := execs[len(execs)-1].Args[1]; rm != "rm -rf /data/chaosblade" {
+		t.Errorf("expected the deployed files removed, got %s", rm)
+	}
+
+	for _, test := range []struct {
+		containerId, dir string
+	}{{"c2", "/data/chaosblade"}, {"c1", "chaosblade"}, {"c1", "/chaosblade"}, {"c1", "/data/../chaosblade"},
+		{"c1", "/data/chaosblade-1.7.2"}, {"c3", "/data/app"}} {
+		response := executor.Exec("uid2", context.Background(), &spec.ExpModel{Target: "cpu", ActionName: "fullload",
+			ActionFlags: map[string]string{ContainerIdFlag.Name: test.containerId, ChaosBladeDirFlag.Name: test.dir,
+				ChaosBladeReleaseFlag.Name: release}})
+		if response.Success {
+			t.Errorf("expected the directory %s of %s refused", test.dir, test.containerId)
+		}
+	}
+	for _, call := range client.CallsOf("ExecContainer") {
+		if command := call.Args[1].(string); command == "rm -rf /data/chaosblade-1.7.2" || command == "rm -rf /data/app" {
+			t.Errorf("expected the refused directory kept, got %s", command)
+		}
+	}
+}
+
+func TestExecRollback(t *testing.T) {
+	dir := t.TempDir()
+	release := createRelease(t, dir)
+	originCache, originDeployments := BundleCache, OpenDeployments
+	BundleCache = func() *bundle.Fetcher { return bundle.NewFetcher(path.Join(dir, "bundles")) }
+	OpenDeployments = func() *journal.Deployments { return journal.OpenDeployments(path.Join(dir, "deployments.json")) }
+	defer func() { BundleCache, OpenDeployments = originCache, originDeployments }()
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+		if strings.HasPrefix(command, "[ -e") {
+			return "", errors.New("exit status 1")
+		}
+		if strings.Contains(command, "blade create") {
+			return `{"code":53000,"success":false,"error":"cpu fullload failed"}`, nil
+		}
+		return "", nil
+	}
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+
+	response := NewRunCmdInContainerExecutorByCP().Exec("uid1", context.Background(), &spec.ExpModel{Target: "cpu",
+		ActionName: "fullload", ActionFlags: map[string]string{ContainerIdFlag.Name: "c1", ChaosBladeReleaseFlag.Name: release}})
+	if response.Success || response.Err != "cpu fullload failed" {
+		t.Fatalf("expected the experiment failed in the container, got %+v", response)
+	}
+	execs := client.CallsOf("ExecContainer")
+	if rm := execs[len(execs)-1].Args[1]; rm != "rm -rf /opt/chaosblade" {
+		t.Errorf("expected the deployed files removed, got %s", rm)
+	}
+	if deployments, err := OpenDeployments().List(); err != nil || len(deployments) != 0 {
+		t.Errorf("expected the deployment released, got %+v %v", deployments, err)
+	}
+}
+
+func TestExecImageInject(t *testing.T) {
+	dir := t.TempDir()
+	release := createRelease(t, dir)
+	originCache, originDeployments := BundleCache, OpenDeployments
+	BundleCache = func() *bundle.Fetcher { return bundle.NewFetcher(path.Join(dir, "bundles")) }
+	OpenDeployments = func() *journal.Deployments { return journal.OpenDeployments(path.Join(dir, "deployments.json")) }
+	defer func() { BundleCache, OpenDeployments = originCache, originDeployments }()
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+		return `{"code":200,"success":true}`, nil
+	}
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	executor := NewRunCmdInContainerExecutorByCP()
+
+	if response := executor.Exec("uid1", context.Background(), &spec.ExpModel{Target: "cpu", ActionName: "fullload",
+		ActionFlags: map[string]string{ContainerIdFlag.Name: "c1", ChaosBladeReleaseFlag.Name: release,
+			ChaosBladeInjectFlag.Name: InjectImage}}); !response.Success {
+		t.Fatalf("exec failed, %s", response.Err)
+	}
+	if recreates := client.CallsOf("Recreate"); len(recreates) != 1 || recreates[0].Args[0] != "c1" ||
+		recreates[0].Args[1] != "chaosblade-layered:c1-chaosblade-1.7.2" {
+		t.Fatalf("expected the container recreated, got %+v", recreates)
+	}
+	if copies := client.CallsOf("CopyToContainer"); len(copies) != 0 {
+		t.Errorf("expected nothing copied into the container, got %+v", copies)
+	}
+	execs := client.CallsOf("ExecContainer")
+	if len(execs) != 1 || execs[0].Args[0] != "c1-recreated" ||
+		!strings.HasPrefix(execs[0].Args[1].(string), "/opt/chaosblade/blade create cpu fullload") {
+		t.Fatalf("expected the experiment run in the recreated container, got %+v", execs)
+	}
+
+	// the destroy with the origin container id runs in the recreated container and restores the origin
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := executor.Exec("uid1", ctx, &spec.ExpModel{Target: "cpu", ActionName: "fullload",
+		ActionFlags: map[string]string{ContainerIdFlag.Name: "c1"}}); !response.Success {
+		t.Fatalf("destroy failed, %s", response.Err)
+	}
+	execs = client.CallsOf("ExecContainer")
+	if destroy := execs[len(execs)-1]; destroy.Args[0] != "c1-recreated" ||
+		!strings.HasPrefix(destroy.Args[1].(string), "/opt/chaosblade/blade destroy cpu fullload") {
+		t.Errorf("expected the experiment destroyed in the recreated container, got %+v", destroy)
+	}
+	restores := client.CallsOf("RestoreRecreated")
+	if len(restores) != 1 || restores[0].Args[0] != "c1-recreated" || restores[0].Args[1] != "c1" ||
+		restores[0].Args[3] != true {
+		t.Errorf("expected the origin container restored and started, got %+v", restores)
+	}
+	if _, ok, _ := OpenDeployments().FindByOrigin("c1"); ok {
+		t.Errorf("expected the recreation removed from the journal")
+	}
+}
+
+func TestExecImageInjectStopped(t *testing.T) {
+	dir := t.TempDir()
+	release := createRelease(t, dir)
+	originCache, originDeployments := BundleCache, OpenDeployments
+	BundleCache = func() *bundle.Fetcher { return bundle.NewFetcher(path.Join(dir, "bundles")) }
+	OpenDeployments = func() *journal.Deployments { return journal.OpenDeployments(path.Join(dir, "deployments.json")) }
+	defer func() { BundleCache, OpenDeployments = originCache, originDeployments }()
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"},
+		container.ContainerInfo{ContainerId: "c1-recreated"})
+	client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+		return `{"code":200,"success":true}`, nil
+	}
+	// the origin container was stopped when recreated
+	client.RecreateFunc = func(ctx context.Context, containerId string, layer io.Reader, image string) (string, bool, error) {
+		_, err := io.Copy(io.Discard, layer)
+		return "c1-recreated", false, err
+	}
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	executor := NewRunCmdInContainerExecutorByCP()
+
+	if response := executor.Exec("uid1", context.Background(), &spec.ExpModel{Target: "cpu", ActionName: "fullload",
+		ActionFlags: map[string]string{ContainerIdFlag.Name: "c1", ChaosBladeReleaseFlag.Name: release,
+			ChaosBladeInjectFlag.Name: InjectImage}}); !response.Success {
+		t.Fatalf("exec failed, %s", response.Err)
+	}
+	if deployment, ok, _ := OpenDeployments().FindByOrigin("c1"); !ok || !deployment.Origin.Stopped {
+		t.Fatalf("expected the stopped origin recorded, got %+v", deployment.Origin)
+	}
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := executor.Exec("uid1", ctx, &spec.ExpModel{Target: "cpu", ActionName: "fullload",
+		ActionFlags: map[string]string{ContainerIdFlag.Name: "c1"}}); !response.Success {
+		t.Fatalf("destroy failed, %s", response.Err)
+	}
+	// the origin container is restored but kept stopped
+	restores := client.CallsOf("RestoreRecreated")
+	if len(restores) != 1 || restores[0].Args[1] != "c1" || restores[0].Args[3] != false {
+		t.Errorf("expected the origin container restored stopped, got %+v", restores)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func FuzzParseContainerLabelSelector(f *testing.F) {
+	for _, seed := range []string{
+		"app=web",
+		"app=web,tier=frontend",
+		" app = web , tier=",
+		"=web",
+		"app==web",
+		",,,",
+		"app",
+		"应用=网站",
+		"",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		labels := parseContainerLabelSelector(raw)
+		pairs := make([]string, 0, len(labels))
+		for key, value := range labels {
+			if key == "" || strings.ContainsAny(key, ",=") || strings.ContainsAny(value, ",=") {
+				t.Fatalf("illegal label %q=%q parsed from %q", key, value, raw)
+			}
+			pairs = append(pairs, key+"="+value)
+		}
+		sort.Strings(pairs)
+		// the parsed labels are stable when formatted and parsed again
+		again := parseContainerLabelSelector(strings.Join(pairs, ","))
+		if len(again) != len(labels) {
+			t.Fatalf("labels %v changed to %v after reparsed", labels, again)
+		}
+		for key, value := range labels {
+			if again[key] != value {
+				t.Fatalf("labels %v changed to %v after reparsed", labels, again)
+			}
+		}
+	})
+}
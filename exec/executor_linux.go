@@ -17,6 +17,13 @@
 package exec
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
 	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
 	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/containerd"
 	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/crio"
@@ -25,14 +32,100 @@ import (
 )
 
 func GetClientByRuntime(expModel *spec.ExpModel) (container.Container, error) {
-	switch expModel.ActionFlags[ContainerRuntime.Name] {
+	if NewClientFunc != nil {
+		return NewClientFunc(expModel)
+	}
+	runtime := expModel.ActionFlags[ContainerRuntime.Name]
+	if runtime == container.RuntimeAuto {
+		return getDiscoveredClient(expModel)
+	}
+	endpoint, err := getEndpoint(expModel)
+	if err != nil {
+		return nil, err
+	}
+	return newGuardedClient(runtime, endpoint, func() (container.Container, error) {
+		return newRuntimeClient(expModel, runtime, endpoint)
+	})
+}
+
+func newRuntimeClient(expModel *spec.ExpModel, runtime, endpoint string) (container.Container, error) {
+	switch runtime {
 	case container.ContainerdRuntime:
-		return containerd.NewClient(expModel.ActionFlags[EndpointFlag.Name], expModel.ActionFlags[ContainerNamespace.Name])
+		namespace := expModel.ActionFlags[ContainerNamespace.Name]
+		if namespace == containerd.NamespaceAuto {
+			target := containerd.Target{
+				Id:     expModel.ActionFlags[ContainerIdFlag.Name],
+				Name:   expModel.ActionFlags[ContainerNameFlag.Name],
+				Labels: parseContainerLabelSelector(expModel.ActionFlags[ContainerLabelSelectorFlag.Name]),
+			}
+			var err error
+			if namespace, err = containerd.SelectNamespace(context.Background(), endpoint, target); err != nil {
+				return nil, err
+			}
+		}
+		return containerd.NewClient(endpoint, namespace)
 	case container.CRIORuntime:
-		return crio.NewClient(expModel.ActionFlags[EndpointFlag.Name], expModel.ActionFlags[ContainerNamespace.Name])
+		return crio.NewClientWithTLS(endpoint, expModel.ActionFlags[ContainerNamespace.Name], criTLSOptions(expModel))
 	default:
-		return docker.NewClient(expModel.ActionFlags[EndpointFlag.Name])
+		return docker.NewClient(endpoint)
 		//default:
 		//	return nil,errors.New(fmt.Sprintf("`%s`, the container runtime not support", expModel.ActionFlags[ContainerRuntime.Name]))
 	}
 }
+
+// getDiscoveredClient returns the client of the live runtime of the node, the sockets are probed by precedence, see
+// container.DiscoverySockets, the remote nodes and the endpoints given have no runtime to discover
+func getDiscoveredClient(expModel *spec.ExpModel) (container.Container, error) {
+	if expModel.ActionFlags[EndpointFlag.Name] != "" || expModel.ActionFlags[SSHTunnelFlag.Name] != "" {
+		return nil, fmt.Errorf("the %s %s discovers the local sockets, the runtime of the %s or the %s must be given",
+			ContainerRuntime.Name, container.RuntimeAuto, EndpointFlag.Name, SSHTunnelFlag.Name)
+	}
+	socket, err := container.DiscoverRuntime(context.Background(), container.DiscoverySockets, probeRuntime)
+	if err != nil {
+		return nil, err
+	}
+	return newGuardedClient(socket.Runtime, socket.Endpoint(), func() (container.Container, error) {
+		client, err := newRuntimeClient(expModel, socket.Runtime, socket.Endpoint())
+		if err != nil {
+			return nil, fmt.Errorf("the runtime discovered is %s, %v", socket, err)
+		}
+		return container.WithDiscoveredRuntime(client, socket), nil
+	})
+}
+
+// probeRuntime calls the cri Version of the cri sockets and connects the docker socket
+func probeRuntime(ctx context.Context, socket container.RuntimeSocket) error {
+	if socket.Runtime != container.DockerRuntime {
+		return crio.Probe(ctx, "unix://"+socket.Socket)
+	}
+	conn, err := net.DialTimeout("unix", socket.Socket, time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// criTLSOptions returns the tls options of the cri endpoint, the client connects insecurely without them
+func criTLSOptions(expModel *spec.ExpModel) crio.TLSOptions {
+	return crio.TLSOptions{
+		CAFile:     expModel.ActionFlags[CRITLSCAFlag.Name],
+		CertFile:   expModel.ActionFlags[CRITLSCertFlag.Name],
+		KeyFile:    expModel.ActionFlags[CRITLSKeyFlag.Name],
+		ServerName: expModel.ActionFlags[CRITLSServerNameFlag.Name],
+	}
+}
+
+// CloseClients closes the cached runtime clients
+func CloseClients() error {
+	var errs []string
+	for _, closeClient := range []func() error{docker.CloseClient, containerd.CloseClient, crio.CloseClient,
+		container.CloseSSHTunnels} {
+		if err := closeClient(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
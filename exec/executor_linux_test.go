@@ -0,0 +1,70 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"net"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+func TestGetDiscoveredClientRefused(t *testing.T) {
+	for _, flags := range []map[string]string{
+		{EndpointFlag.Name: "unix:///var/run/crio/crio.sock"},
+		{SSHTunnelFlag.Name: "root@worker-1"},
+	} {
+		flags[ContainerRuntime.Name] = container.RuntimeAuto
+		if _, err := GetClientByRuntime(&spec.ExpModel{ActionFlags: flags}); err == nil ||
+			!strings.Contains(err.Error(), "discovers the local sockets") {
+			t.Errorf("expected the discovery of the flags %v refused, got %v", flags, err)
+		}
+	}
+}
+
+func TestGetDiscoveredClientNoRuntime(t *testing.T) {
+	saved := container.DiscoverySockets
+	t.Cleanup(func() { container.DiscoverySockets = saved })
+	container.DiscoverySockets = []container.RuntimeSocket{
+		{Runtime: container.CRIORuntime, Name: "cri-o", Socket: path.Join(t.TempDir(), "crio.sock")},
+	}
+	_, err := GetClientByRuntime(&spec.ExpModel{ActionFlags: map[string]string{ContainerRuntime.Name: "auto"}})
+	if err == nil || !strings.Contains(err.Error(), "cri-o at") {
+		t.Errorf("expected the sockets skipped reported, got %v", err)
+	}
+}
+
+func TestProbeDockerRuntime(t *testing.T) {
+	socket := path.Join(t.TempDir(), "docker.sock")
+	docker := container.RuntimeSocket{Runtime: container.DockerRuntime, Name: "docker", Socket: socket}
+	if err := probeRuntime(context.Background(), docker); err == nil {
+		t.Error("expected the absent socket refused")
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if err := probeRuntime(context.Background(), docker); err != nil {
+		t.Errorf("expected the docker socket probed, got %v", err)
+	}
+}
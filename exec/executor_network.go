@@ -19,13 +19,13 @@ package exec
 import (
 	"context"
 	"fmt"
-	"github.com/chaosblade-io/chaosblade-exec-os/exec/model"
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
 	"os/exec"
 	"path"
-	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
 )
 
 // NetworkExecutor is an executor implementation which used copy chaosblade tool to the target container and executed
@@ -63,42 +63,66 @@ func (r *NetworkExecutor) Exec(uid string, ctx context.Context, expModel *spec.E
 		return spec.ResponseFail(code, err.Error(), nil)
 	}
 
-	var args string
-	var flags string
-
-	nsFlags := GetNSExecFlags()
-	m := make(map[string]string, len(nsFlags))
-	for _, f := range nsFlags {
-		m[f.FlagName()] = f.FlagName()
+	_, isDestroy := spec.IsDestroy(ctx)
+	family, err := addressFamily(expModel.ActionFlags)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, AddressFamilyFlag.Name,
+			expModel.ActionFlags[AddressFamilyFlag.Name], err)
 	}
-
-	for k, v := range expModel.ActionFlags {
-		if v == "" || m[k] != "" || k == "timeout" {
-			continue
-		}
-		flags = fmt.Sprintf("%s --%s=%s", flags, k, v)
+	snapshotUid := uid
+	if suid, _ := spec.IsDestroy(ctx); suid != "" {
+		snapshotUid = suid
 	}
-	_, isDestroy := spec.IsDestroy(ctx)
+	if !isDestroy {
+		checkCNI(ctx, expModel.ActionFlags)
+		snapshotNetwork(ctx, snapshotUid, container.ContainerId, pid)
+	}
+	response = execNetwork(ctx, uid, expModel, pid, family, isDestroy)
+	// the state is restored by the destroy succeeded, or never changed by the create failed
+	if response.Success == isDestroy {
+		verifyNetwork(ctx, snapshotUid, pid)
+	}
+	return response
+}
 
-	if isDestroy {
-		args = fmt.Sprintf("%s %s %s%s --uid=%s", spec.Destroy, expModel.Target, expModel.ActionName, flags, uid)
-	} else {
-		args = fmt.Sprintf("%s %s %s%s --uid=%s", spec.Create, expModel.Target, expModel.ActionName, flags, uid)
+// execNetwork executes the network action of the family in the network namespace of the target
+func execNetwork(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, family string,
+	isDestroy bool) *spec.Response {
+	if direction := expModel.ActionFlags[DirectionFlag.Name]; tcActions[expModel.ActionName] && direction != "" &&
+		direction != "egress" {
+		return execDirectionNetwork(ctx, uid, expModel, pid, family, isDestroy)
+	}
+	if tcActions[expModel.ActionName] && expModel.ActionFlags[ProtocolFlag.Name] != "" {
+		return execProtocolNetwork(ctx, uid, expModel, pid, family, isDestroy)
+	}
+	if family != FamilyIPv4 || expModel.ActionName == "drop" {
+		return execByFamily(ctx, uid, expModel, pid, family, isDestroy)
+	}
+	if tcActions[expModel.ActionName] {
+		return execTcNetwork(ctx, uid, expModel, pid, chaosOsFlags(expModel), isDestroy)
 	}
+	return execChaosOsNetwork(ctx, uid, expModel, pid, chaosOsFlags(expModel), isDestroy)
+}
 
-	args = fmt.Sprintf("%s %s %s %s %s",
-		args,
-		fmt.Sprintf("--%s=%s", model.ChannelFlag.Name, spec.NSExecBin),
-		fmt.Sprintf("--%s=%d", model.NsTargetFlag.Name, pid),
-		fmt.Sprintf("--%s=%s", model.NsPidFlag.Name, spec.True),
-		fmt.Sprintf("--%s=%s", model.NsNetFlag.Name, spec.True),
-	)
+// execChaosOsNetwork executes the network action by chaos_os in the network namespace of the target
+func execChaosOsNetwork(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, flags map[string]string,
+	isDestroy bool) *spec.Response {
+	args, err := nsexec.ChaosOsArgs{
+		Destroy:    isDestroy,
+		Target:     expModel.Target,
+		Action:     expModel.ActionName,
+		Flags:      flags,
+		Uid:        uid,
+		Pid:        pid,
+		Namespaces: []nsexec.Namespace{nsexec.Pid, nsexec.Net},
+	}.Build()
+	if err != nil {
+		return spec.ReturnFail(spec.OsCmdExecFailed, err.Error())
+	}
 
 	chaosOsBin := path.Join(util.GetProgramPath(), spec.BinPath, spec.ChaosOsBin)
 
-	argsArray := strings.Split(args, " ")
-
-	command := exec.CommandContext(ctx, chaosOsBin, argsArray...)
+	command := exec.CommandContext(ctx, chaosOsBin, args...)
 	output, err := command.CombinedOutput()
 	outMsg := string(output)
 	log.Debugf(ctx, "Command Result, output: %v, err: %v", outMsg, err)
@@ -20,19 +20,17 @@ import (
 	"context"
 	"fmt"
 	"github.com/chaosblade-io/chaosblade-spec-go/log"
-	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/network"
 
 	execContainer "github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
 )
 
 type RunInSidecarContainerExecutor struct {
 	BaseClientExecutor
-	runConfigFunc func(container string) (container.HostConfig, network.NetworkingConfig)
-	isResident    bool
+	// helperTemplate is the reference of the helper container template, see execContainer.GetHelperTemplate
+	helperTemplate string
+	isResident     bool
 }
 
 func (*RunInSidecarContainerExecutor) Name() string {
@@ -51,24 +49,15 @@ func (r *RunInSidecarContainerExecutor) Exec(uid string, ctx context.Context, ex
 	if !response.Success {
 		return response
 	}
-	hostConfig, networkingConfig := r.runConfigFunc(containerInfo.ContainerId)
 	sidecarName := createSidecarContainerName(containerInfo.ContainerName, expModel.Target, expModel.ActionName)
-	return r.startAndExecInContainer(uid, ctx, expModel, &hostConfig, &networkingConfig, sidecarName, containerInfo)
+	return r.startAndExecInContainer(uid, ctx, expModel, sidecarName, containerInfo)
 }
 
 func NewNetWorkSidecarExecutor() *RunInSidecarContainerExecutor {
-	runConfigFunc := func(containerId string) (container.HostConfig, network.NetworkingConfig) {
-		hostConfig := container.HostConfig{
-			NetworkMode: container.NetworkMode(fmt.Sprintf("container:%s", containerId)),
-			CapAdd:      []string{"NET_ADMIN"},
-		}
-		networkConfig := network.NetworkingConfig{}
-		return hostConfig, networkConfig
-	}
 	return &RunInSidecarContainerExecutor{
 		// set the client when invoking
-		runConfigFunc: runConfigFunc,
-		isResident:    false,
+		helperTemplate: execContainer.NetworkToolsHelper,
+		isResident:     false,
 		BaseClientExecutor: BaseClientExecutor{
 			CommandFunc: CommonFunc,
 		},
@@ -82,28 +71,21 @@ func createSidecarContainerName(containerName, target, injectType string) string
 func (*RunInSidecarContainerExecutor) SetChannel(channel spec.Channel) {
 }
 
-func (r *RunInSidecarContainerExecutor) getContainerConfig(expModel *spec.ExpModel) *container.Config {
-	return &container.Config{
-		// detach
-		AttachStdout: false,
-		AttachStderr: false,
-		Tty:          true,
-		Cmd:          []string{"/bin/sh"},
-		Image: execContainer.GetChaosBladeImageRef(expModel.ActionFlags[ImageRepoFlag.Name],
-			expModel.ActionFlags[ImageVersionFlag.Name]),
-		Labels: map[string]string{
-			"chaosblade": "chaosblade-sidecar",
-		},
+// sidecarImage returns the image of the image flags, empty if not set so the image pinned by the template is used
+func sidecarImage(expModel *spec.ExpModel) string {
+	repo, version := expModel.ActionFlags[ImageRepoFlag.Name], expModel.ActionFlags[ImageVersionFlag.Name]
+	if repo == "" && version == "" {
+		return ""
 	}
+	return execContainer.GetChaosBladeImageRef(repo, version)
 }
 
 func (r *RunInSidecarContainerExecutor) startAndExecInContainer(uid string, ctx context.Context, expModel *spec.ExpModel,
-	hostConfig *container.HostConfig, networkConfig *network.NetworkingConfig, containerName string, containerInfo execContainer.ContainerInfo) *spec.Response {
-	config := r.getContainerConfig(expModel)
+	containerName string, containerInfo execContainer.ContainerInfo) *spec.Response {
 	var defaultResponse *spec.Response
 	command := r.CommandFunc(uid, ctx, expModel)
-	sidecarContainerId, output, err, code := r.Client.ExecuteAndRemove(ctx,
-		config, hostConfig, networkConfig, containerName, true, time.Second, command, containerInfo)
+	sidecarContainerId, output, err, code := execContainer.ExecuteHelper(execContainer.WithExperiment(ctx, uid), r.Client, r.helperTemplate,
+		sidecarImage(expModel), containerName, command, containerInfo)
 
 	if err != nil {
 		log.Errorf(ctx, err.Error())
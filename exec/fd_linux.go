@@ -0,0 +1,303 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/holder"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+)
+
+// The flags of the fd experiment
+const (
+	FdCountFlag    = "count"
+	FdPercentFlag  = "percent"
+	FdInCgroupFlag = "in-cgroup"
+)
+
+// FdHolderBin is the binary holding the file descriptors
+const FdHolderBin = "chaos_fdholder"
+
+// startFdHolder starts the holder in the pid namespace of the process by nsexec, the holder joins the cgroups of the
+// process if inCgroup, it returns the host pid of nsexec
+var startFdHolder = func(ctx context.Context, pid int32, cgroupRoot string, inCgroup bool, argv []string) (int, error) {
+	if inCgroup {
+		return startInCgroup(ctx, pid, cgroupRoot, []nsexec.Namespace{nsexec.Pid}, argv)
+	}
+	bin, err := nsexec.Bin()
+	if err != nil {
+		return 0, err
+	}
+	command, err := nsexec.New(bin, pid).Namespaces(nsexec.Pid).Argv(argv...).Build()
+	if err != nil {
+		return 0, err
+	}
+	log.Debugf(ctx, "run command, %s", command)
+	cmd := exec.Command(command.Path, command.Args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Env = trace.Environ(ctx)
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	go cmd.Wait()
+	return cmd.Process.Pid, nil
+}
+
+type FdCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewFdCommandSpec() spec.ExpModelCommandSpec {
+	return &FdCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&FdExhaustActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name: FdCountFlag,
+								Desc: "file descriptors opened, the count or the percent is required",
+							},
+							&spec.ExpFlag{
+								Name: FdPercentFlag,
+								Desc: "percent of the soft open files limit of the container process opened, 1 to 100",
+							},
+							&spec.ExpFlag{
+								Name:   FdInCgroupFlag,
+								Desc:   "the holder joins the cgroups of the container, so the files are charged to it",
+								NoArgs: true,
+							},
+							&spec.ExpFlag{
+								Name: "cgroup-root",
+								Desc: "cgroup root path, default value /sys/fs/cgroup",
+							},
+						},
+						ActionExecutor: &fdExhaustExecutor{},
+						ActionLongDesc: "A holder in the pid namespace of the container opens the file descriptors as " +
+							"the user of the container process and with its open files limit, and holds them until the " +
+							"experiment is destroyed. The holder stops at the first failure such as EMFILE, the files " +
+							"opened are in the result. The descriptors are released by the kernel when the holder is " +
+							"killed by the destroy.",
+						ActionExample: `# Open 90% of the open files limit of the container process
+blade create cri fd exhaust --percent 90 --container-id ee54f1e61c08
+
+# Open 50000 files charged to the cgroups of the container
+blade create cri fd exhaust --count 50000 --in-cgroup --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*FdCommandModelSpec) Name() string {
+	return "fd"
+}
+
+func (*FdCommandModelSpec) ShortDesc() string {
+	return "File descriptor experiment"
+}
+
+func (*FdCommandModelSpec) LongDesc() string {
+	return "File descriptor experiment, the file descriptors are held in the container as the user of the application."
+}
+
+type FdExhaustActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*FdExhaustActionCommand) Name() string {
+	return "exhaust"
+}
+
+func (*FdExhaustActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*FdExhaustActionCommand) ShortDesc() string {
+	return "file descriptors exhaustion"
+}
+
+func (c *FdExhaustActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+// FdExhaustResult is the result of the fd experiment
+type FdExhaustResult struct {
+	holder.State
+	Requested int    `json:"requested"`
+	Limit     uint64 `json:"limit"`
+	// Error is the failure stopping the holder before the requested files opened, such as EMFILE
+	Error string `json:"error,omitempty"`
+}
+
+type fdExhaustExecutor struct {
+}
+
+func (e *fdExhaustExecutor) Name() string {
+	return "fd"
+}
+
+func (e *fdExhaustExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *fdExhaustExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return releaseFdHolder(ctx, suid)
+	}
+	flags := model.ActionFlags
+	if flags[FdCountFlag] == "" && flags[FdPercentFlag] == "" {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, FdCountFlag)
+	}
+	if flags[FdCountFlag] != "" && flags[FdPercentFlag] != "" {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, FdPercentFlag, flags[FdPercentFlag],
+			"it is exclusive with the count")
+	}
+	count, response := intervalFlag(flags, FdCountFlag, 0, 1)
+	if response != nil {
+		return response
+	}
+	percent, response := rangeFlag(flags, FdPercentFlag, 0, 1, 100)
+	if response != nil {
+		return response
+	}
+	inCgroup, _ := strconv.ParseBool(flags[FdInCgroupFlag])
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	userId, groupId, err := container.Credentials(pid)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, "status", err)
+	}
+	soft, hard, err := container.NofileLimit(pid)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, "limits", err)
+	}
+	if percent > 0 {
+		count = int(soft * uint64(percent) / 100)
+	}
+	ready := path.Join(util.GetProgramPath(), fmt.Sprintf("fdholder-%s.ready", uid))
+	argv := []string{
+		path.Join(util.GetProgramPath(), spec.BinPath, FdHolderBin),
+		"-count", strconv.Itoa(count),
+		"-uid", strconv.Itoa(userId), "-gid", strconv.Itoa(groupId),
+		"-nofile-soft", strconv.FormatUint(soft, 10), "-nofile-hard", strconv.FormatUint(hard, 10),
+		"-ready", ready,
+	}
+	starter, err := startFdHolder(ctx, pid, getCgroupRoot(model), inCgroup, argv)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, FdHolderBin, err)
+	}
+	defer os.Remove(ready)
+	state := holder.State{Uid: uid, Kind: "fd", Starter: starter, Cmdline: strings.Join(argv, " ")}
+	result := FdExhaustResult{Requested: count, Limit: soft}
+	opened, err := waitFdHolder(ready, 30*time.Second)
+	if err == nil {
+		// the command line is read again, it is checked by the destroy before the holder killed
+		state.Cmdline, err = holder.Cmdline(opened.pid)
+	}
+	if err != nil {
+		holder.Release(state)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, FdHolderBin, err)
+	}
+	state.Holder, state.Count, result.Error = opened.pid, opened.count, opened.err
+	result.State = state
+	if err := holder.SaveState(util.GetProgramPath(), state); err != nil {
+		holder.Release(state)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	log.Infof(ctx, "%d of %d files are opened as %d:%d in the pid namespace of process %d, holder %d", state.Count,
+		count, userId, groupId, pid, state.Holder)
+	return spec.ReturnSuccess(result)
+}
+
+// fdHolderReady is the content of the ready file, the host pid of the holder, the count opened and the failure
+type fdHolderReady struct {
+	pid   int
+	count int
+	err   string
+}
+
+// waitFdHolder waits until the holder wrote the ready file
+func waitFdHolder(ready string, timeout time.Duration) (fdHolderReady, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		content, err := os.ReadFile(ready)
+		if err == nil && strings.HasSuffix(string(content), "\n") {
+			return parseFdHolderReady(strings.TrimSpace(string(content)))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fdHolderReady{}, fmt.Errorf("the holder is not ready in %s", timeout)
+}
+
+// parseFdHolderReady parses the ready file, such as 4242 1000 or 4242 1021 too many open files
+func parseFdHolderReady(content string) (fdHolderReady, error) {
+	fields := strings.SplitN(content, " ", 3)
+	if len(fields) < 2 {
+		return fdHolderReady{}, fmt.Errorf("illegal ready file content %q", content)
+	}
+	pid, pidErr := strconv.Atoi(fields[0])
+	count, countErr := strconv.Atoi(fields[1])
+	if pidErr != nil || countErr != nil || pid <= 0 {
+		return fdHolderReady{}, fmt.Errorf("illegal ready file content %q", content)
+	}
+	opened := fdHolderReady{pid: pid, count: count}
+	if len(fields) == 3 {
+		opened.err = fields[2]
+	}
+	return opened, nil
+}
+
+// releaseFdHolder kills the holder, the file descriptors are closed by the kernel
+func releaseFdHolder(ctx context.Context, uid string) *spec.Response {
+	stateDir := util.GetProgramPath()
+	state, err := holder.LoadState(stateDir, uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, stateDir, err)
+	}
+	if err := holder.Release(state); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "kill", err)
+	}
+	if err := holder.RemoveState(stateDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove state", err)
+	}
+	log.Infof(ctx, "the %d files of experiment %s are released", state.Count, uid)
+	return spec.ReturnSuccess(uid)
+}
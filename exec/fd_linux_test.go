@@ -0,0 +1,133 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/holder"
+)
+
+// fakeFdHolder targets the test process and replaces the holder by a sleep written to the ready file
+func fakeFdHolder(t *testing.T, opened string) *[][]string {
+	t.Helper()
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	client.Pids["c1"] = int32(os.Getpid())
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	t.Cleanup(func() { NewClientFunc = nil })
+	argvs := make([][]string, 0)
+	origin := startFdHolder
+	startFdHolder = func(ctx context.Context, pid int32, cgroupRoot string, inCgroup bool, argv []string) (int, error) {
+		argvs = append(argvs, argv)
+		ready := argv[len(argv)-1]
+		cmd := exec.Command("/bin/sh", "-c", `sleep 30 & sleep 0.2; echo "$! `+opened+`" > "$0"; wait`, ready)
+		if err := cmd.Start(); err != nil {
+			return 0, err
+		}
+		go cmd.Wait()
+		t.Cleanup(func() { cmd.Process.Kill() })
+		return cmd.Process.Pid, nil
+	}
+	t.Cleanup(func() { startFdHolder = origin })
+	return &argvs
+}
+
+func TestParseFdHolderReady(t *testing.T) {
+	if opened, err := parseFdHolderReady("4242 1000"); err != nil || opened != (fdHolderReady{pid: 4242, count: 1000}) {
+		t.Errorf("unexpected %+v, %v", opened, err)
+	}
+	opened, err := parseFdHolderReady("4242 1021 too many open files")
+	if err != nil || opened.count != 1021 || opened.err != "too many open files" {
+		t.Errorf("unexpected %+v, %v", opened, err)
+	}
+	for _, content := range []string{"", "4242", "0 10", "4242 many"} {
+		if _, err := parseFdHolderReady(content); err == nil {
+			t.Errorf("expected error of %q", content)
+		}
+	}
+}
+
+func TestFdExhaust(t *testing.T) {
+	argvs := fakeFdHolder(t, "512 too many open files")
+	flags := map[string]string{ContainerIdFlag.Name: "c1", FdPercentFlag: "50"}
+	model := &spec.ExpModel{Target: "fd", ActionName: "exhaust", ActionFlags: flags}
+	response := (&fdExhaustExecutor{}).Exec("uid1", context.Background(), model)
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	defer holder.RemoveState(util.GetProgramPath(), "uid1")
+	soft, _, _ := container.NofileLimit(int32(os.Getpid()))
+	result := response.Result.(FdExhaustResult)
+	if result.Count != 512 || result.Error != "too many open files" || result.Limit != soft ||
+		result.Requested != int(soft/2) || result.Cmdline != "sleep 30" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	argv := strings.Join((*argvs)[0], " ")
+	uid, gid, _ := container.Credentials(int32(os.Getpid()))
+	for _, expected := range []string{FdHolderBin + " -count ", " -uid " + strconv.Itoa(uid) + " -gid " + strconv.Itoa(gid), " -ready "} {
+		if !strings.Contains(argv, expected) {
+			t.Errorf("expected %q in the holder command, got %s", expected, argv)
+		}
+	}
+
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := (&fdExhaustExecutor{}).Exec("uid1", ctx, model); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if cmdline, err := holder.Cmdline(result.Holder); err == nil && cmdline != "" {
+		t.Errorf("expected the holder killed, got %s", cmdline)
+	}
+	if _, err := holder.LoadState(util.GetProgramPath(), "uid1"); !os.IsNotExist(err) {
+		t.Errorf("expected the state removed, got %v", err)
+	}
+}
+
+func TestFdExhaustIllegal(t *testing.T) {
+	argvs := fakeFdHolder(t, "1")
+	tests := []struct {
+		flags map[string]string
+		code  int32
+	}{
+		{map[string]string{}, spec.ParameterLess.Code},
+		{map[string]string{FdCountFlag: "10", FdPercentFlag: "10"}, spec.ParameterIllegal.Code},
+		{map[string]string{FdCountFlag: "0"}, spec.ParameterIllegal.Code},
+		{map[string]string{FdPercentFlag: "101"}, spec.ParameterIllegal.Code},
+	}
+	for _, test := range tests {
+		test.flags[ContainerIdFlag.Name] = "c1"
+		model := &spec.ExpModel{Target: "fd", ActionName: "exhaust", ActionFlags: test.flags}
+		response := (&fdExhaustExecutor{}).Exec("uid1", context.Background(), model)
+		if response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of %v, got %+v", test.code, test.flags, response)
+		}
+	}
+	if len(*argvs) != 0 {
+		t.Errorf("expected nothing started, got %q", *argvs)
+	}
+}
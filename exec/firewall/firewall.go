@@ -0,0 +1,175 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package firewall detects the firewall backend of the network namespace of the pod, and builds the nftables rules of
+// the drop experiments for the nft-only userlands, where the iptables calls fail or go to the legacy backend nobody
+// reads. The scripts are executed by the shell of the host in the network namespace of the pod.
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+)
+
+// The firewall backends
+const (
+	// BackendAuto detects the backend by the DetectScript
+	BackendAuto = "auto"
+	// BackendIptables adds the rules by iptables, either legacy or the nf_tables shim
+	BackendIptables = "iptables"
+	// BackendNft adds the rules by nft in a table of the experiment
+	BackendNft = "nft"
+)
+
+// sectionSeparator separates the iptables and the nft sections in the output of the DetectScript
+const sectionSeparator = "--"
+
+// DetectScript reports the iptables version and the nft tables of the network namespace
+func DetectScript() string {
+	return fmt.Sprintf("iptables -V 2>/dev/null; echo %s; command -v nft >/dev/null && echo nft && nft list tables 2>/dev/null; true",
+		sectionSeparator)
+}
+
+// ParseBackend returns the backend of the output of the DetectScript and why. The iptables of the nf_tables shim
+// writes the nft ruleset, so it's kept. The nft is used if iptables is missing, or iptables is legacy while the
+// ruleset of the namespace is managed by nft
+func ParseBackend(output string) (string, string) {
+	sections := strings.SplitN(output, sectionSeparator+"\n", 2)
+	version := strings.TrimSpace(sections[0])
+	var nftLines []string
+	if len(sections) > 1 {
+		nftLines = strings.Split(strings.TrimSpace(sections[1]), "\n")
+	}
+	hasNft := len(nftLines) > 0 && nftLines[0] == "nft"
+	switch {
+	case strings.Contains(version, "nf_tables"):
+		return BackendIptables, "iptables uses the nf_tables backend"
+	case version == "" && hasNft:
+		return BackendNft, "iptables is missing"
+	case version == "":
+		return BackendIptables, "neither iptables nor nft is found"
+	case hasNft && len(nftLines) > 1:
+		return BackendNft, fmt.Sprintf("iptables is legacy while the ruleset has %d nft tables", len(nftLines)-1)
+	}
+	return BackendIptables, "iptables is legacy"
+}
+
+var nonTableChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Table returns the nft table of the experiment
+func Table(uid string) string {
+	return "chaosblade_" + nonTableChars.ReplaceAllString(uid, "_")
+}
+
+// Rule is a drop rule of the family, the empty fields match all
+type Rule struct {
+	// Family is ip or ip6, the rule matches both families if empty
+	Family string
+	// Traffic is in, out or empty for both directions
+	Traffic         string
+	SourceIP        string
+	DestinationIP   string
+	SourcePort      string
+	DestinationPort string
+}
+
+// DropScript creates the table of the experiment with the input and the output chains before the filter ones, and
+// adds the rules dropping the tcp and udp packets, the rules are commented by the uid
+func DropScript(uid string, rules []Rule) (string, error) {
+	table := Table(uid)
+	commands := []string{
+		fmt.Sprintf("nft add table inet %s", table),
+		fmt.Sprintf("nft add chain inet %s input '{ type filter hook input priority -10; }'", table),
+		fmt.Sprintf("nft add chain inet %s output '{ type filter hook output priority -10; }'", table),
+	}
+	for _, rule := range rules {
+		chains := []string{"input", "output"}
+		switch rule.Traffic {
+		case "in":
+			chains = []string{"input"}
+		case "out":
+			chains = []string{"output"}
+		}
+		if rule.Family == "" && (rule.SourceIP != "" || rule.DestinationIP != "") {
+			return "", fmt.Errorf("the family of the addresses is required")
+		}
+		matches := []string{"meta l4proto '{ tcp, udp }'"}
+		if rule.Family != "" {
+			matches = append(matches, fmt.Sprintf("meta nfproto %s", nfproto(rule.Family)))
+		}
+		for _, match := range []struct {
+			selector, value string
+			parse           func(string) error
+		}{
+			{rule.Family + " saddr", rule.SourceIP, validateAddress},
+			{rule.Family + " daddr", rule.DestinationIP, validateAddress},
+			{"th sport", rule.SourcePort, validatePort},
+			{"th dport", rule.DestinationPort, validatePort},
+		} {
+			if match.value == "" {
+				continue
+			}
+			values := strings.Split(match.value, ",")
+			for i := range values {
+				values[i] = strings.TrimSpace(values[i])
+				if err := match.parse(values[i]); err != nil {
+					return "", err
+				}
+			}
+			matches = append(matches, fmt.Sprintf("%s '{ %s }'", match.selector, strings.Join(values, ", ")))
+		}
+		for _, chain := range chains {
+			commands = append(commands, fmt.Sprintf("nft add rule inet %s %s %s drop comment '\"%s\"'", table, chain,
+				strings.Join(matches, " "), trace.Comment(uid)))
+		}
+	}
+	return strings.Join(commands, " && "), nil
+}
+
+// DeleteScript deletes the table of the experiment, it succeeds if the table is already deleted
+func DeleteScript(uid string) string {
+	return fmt.Sprintf("nft delete table inet %s 2>/dev/null; true", Table(uid))
+}
+
+func nfproto(family string) string {
+	if family == "ip6" {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+func validateAddress(address string) error {
+	if net.ParseIP(address) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(address); err == nil {
+		return nil
+	}
+	return fmt.Errorf("illegal address %s", address)
+}
+
+var portPattern = regexp.MustCompile(`^\d{1,5}(-\d{1,5})?$`)
+
+func validatePort(port string) error {
+	if !portPattern.MatchString(port) {
+		return fmt.Errorf("illegal port %s", port)
+	}
+	return nil
+}
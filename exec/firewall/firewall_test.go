@@ -0,0 +1,60 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall
+
+import (
+	"testing"
+)
+
+func TestParseBackend(t *testing.T) {
+	tests := []struct {
+		output  string
+		backend string
+	}{
+		{"iptables v1.8.7 (nf_tables)\n--\nnft\ntable ip filter\n", BackendIptables},
+		{"--\nnft\n", BackendNft},
+		{"--\n", BackendIptables},
+		{"iptables v1.8.4 (legacy)\n--\nnft\ntable inet firewalld\n", BackendNft},
+		{"iptables v1.8.4 (legacy)\n--\nnft\n", BackendIptables},
+		{"iptables v1.6.1\n--\n", BackendIptables},
+	}
+	for _, tt := range tests {
+		if backend, reason := ParseBackend(tt.output); backend != tt.backend {
+			t.Errorf("parse %q, expected %s, got %s, %s", tt.output, tt.backend, backend, reason)
+		}
+	}
+}
+
+func TestDropScript(t *testing.T) {
+	script, err := DropScript("a-b", []Rule{{Traffic: "in", SourcePort: "8080-8090, 9090"}})
+	expected := "nft add table inet chaosblade_a_b && " +
+		"nft add chain inet chaosblade_a_b input '{ type filter hook input priority -10; }' && " +
+		"nft add chain inet chaosblade_a_b output '{ type filter hook output priority -10; }' && " +
+		"nft add rule inet chaosblade_a_b input meta l4proto '{ tcp, udp }' th sport '{ 8080-8090, 9090 }' drop comment '\"chaosblade:a-b\"'"
+	if err != nil || script != expected {
+		t.Errorf("expected %s, got %s, err %v", expected, script, err)
+	}
+	for _, rule := range []Rule{{Family: "ip", SourceIP: "10.0.0.1;reboot"}, {DestinationPort: "80 || true"},
+		{DestinationIP: "10.0.0.1"}} {
+		if _, err := DropScript("a", []Rule{rule}); err == nil {
+			t.Errorf("expected %+v refused", rule)
+		}
+	}
+	if script := DeleteScript("a"); script != "nft delete table inet chaosblade_a 2>/dev/null; true" {
+		t.Errorf("unexpected delete script %s", script)
+	}
+}
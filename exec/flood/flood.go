@@ -0,0 +1,119 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package flood writes the lines to the stdout or stderr of a process at a rate, the lines are written to the file
+// descriptor of the process opened by the proc filesystem, so they are collected like the logs of the container.
+package flood
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/statefile"
+)
+
+// The streams of the process
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// MinSize is the minimal size of the lines, the marker of the experiment is the head of every line
+const MinSize = 64
+
+// Flood is the lines written to a stream of the process every second
+type Flood struct {
+	Uid    string `json:"uid"`
+	Pid    int32  `json:"pid"`
+	Stream string `json:"stream"`
+	// Rate is the lines written every second
+	Rate int `json:"rate"`
+	// Size is the bytes of every line with the line feed
+	Size int `json:"size"`
+	// Duration is the seconds of the flood, 0 is until the helper is terminated
+	Duration int `json:"duration"`
+}
+
+// Marker returns the head of the lines, it is in the script so the helper is identified by its command line
+func (f Flood) Marker() string {
+	return fmt.Sprintf("chaosblade-flood %s", f.Uid)
+}
+
+// Script returns the script of the helper, the lines of a second are written in one burst, the helper exits if the
+// stream is closed, such as the process exited
+func (f Flood) Script() (string, error) {
+	fd := 1
+	switch f.Stream {
+	case StreamStdout:
+	case StreamStderr:
+		fd = 2
+	default:
+		return "", fmt.Errorf("unsupported stream %s", f.Stream)
+	}
+	marker := f.Marker()
+	// the line is the marker, a space, the padding and the line feed
+	padding := f.Size - len(marker) - 2
+	if f.Size < MinSize || padding < 1 {
+		return "", fmt.Errorf("the size must be greater than %d and not less than %d", len(marker)+2, MinSize)
+	}
+	condition := "true"
+	lines := []string{
+		"trap 'exit 0' TERM INT HUP",
+		// appended, the stream redirected to a file is not truncated
+		fmt.Sprintf("exec 3>>/proc/%d/fd/%d || exit 1", f.Pid, fd),
+		fmt.Sprintf("line=\"%s $(head -c %d /dev/zero | tr '\\0' x)\"", marker, padding),
+	}
+	if f.Duration > 0 {
+		lines = append(lines, fmt.Sprintf("end=$(($(date +%%s) + %d))", f.Duration))
+		condition = `[ "$(date +%s)" -lt "$end" ]`
+	}
+	lines = append(lines,
+		fmt.Sprintf("while %s; do", condition),
+		fmt.Sprintf(`  yes "$line" | head -n %d >&3 || exit 1`, f.Rate),
+		"  sleep 1 & wait $!",
+		"done",
+	)
+	return strings.Join(lines, "\n"), nil
+}
+
+// State is the flood of the experiment with the host pid of its helper
+type State struct {
+	Flood
+	Helper int `json:"helper"`
+}
+
+// SaveState saves the state under the state dir
+func SaveState(stateDir string, state State) error {
+	return statefile.Save(stateFile(stateDir, state.Uid), state)
+}
+
+// LoadState returns the state of the experiment, the error is os.ErrNotExist if it is not saved
+func LoadState(stateDir, uid string) (State, error) {
+	var state State
+	if err := statefile.Load(stateFile(stateDir, uid), &state); err != nil {
+		return State{}, err
+	}
+	return state, nil
+}
+
+// RemoveState removes the state of the experiment, it succeeds if the state is not saved
+func RemoveState(stateDir, uid string) error {
+	return statefile.Remove(stateFile(stateDir, uid))
+}
+
+func stateFile(stateDir, uid string) string {
+	return statefile.Path(stateDir, "flood", uid)
+}
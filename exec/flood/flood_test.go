@@ -0,0 +1,72 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flood
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestFloodScript(t *testing.T) {
+	output, err := os.Create(path.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+	if _, err := output.WriteString("started\n"); err != nil {
+		t.Fatal(err)
+	}
+	target := exec.Command("sleep", "10")
+	target.Stdout = output
+	if err := target.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer target.Process.Kill()
+	flood := Flood{Uid: "uid1", Pid: int32(target.Process.Pid), Stream: StreamStdout, Rate: 3, Size: 100, Duration: 1}
+	script, err := flood.Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("sh", "-c", script).CombinedOutput(); err != nil {
+		t.Fatalf("run the script failed, %v: %s", err, out)
+	}
+	content, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) < 4 || lines[0] != "started" {
+		t.Fatalf("expected the lines appended, got %q", lines)
+	}
+	for _, line := range lines[1:] {
+		if len(line)+1 != 100 || !strings.HasPrefix(line, flood.Marker()+" x") {
+			t.Errorf("unexpected line %q", line)
+		}
+	}
+}
+
+func TestFloodScriptIllegal(t *testing.T) {
+	if _, err := (Flood{Uid: "uid1", Stream: "stdin", Rate: 1, Size: 100}).Script(); err == nil {
+		t.Error("expected error of the unsupported stream")
+	}
+	if _, err := (Flood{Uid: "uid1", Stream: StreamStderr, Rate: 1, Size: 32}).Script(); err == nil {
+		t.Error("expected error of the size too small")
+	}
+}
@@ -0,0 +1,263 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+)
+
+// GPUBin is the helper allocating the gpu memory and running the kernels, it runs in the foreground until it is
+// terminated, e.g. chaos_gpu memory --devices=0,1 --percent=80 --uid=xxx
+const GPUBin = "chaos_gpu"
+
+// The flags of the gpu experiments
+const (
+	GPUPercentFlag = "percent"
+	GPUDeviceFlag  = "device"
+)
+
+type GPUCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewGPUCommandSpec() spec.ExpModelCommandSpec {
+	return &GPUCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				newGPUActionCommand("memory", "gpu memory pressure",
+					"Allocates the percent of the memory of every selected gpu assigned to the container.",
+					`# Allocate 80% of the memory of the gpus assigned to the container ee54f1e61c08
+blade create cri gpu memory --percent 80 --container-id ee54f1e61c08 --container-runtime containerd`),
+				newGPUActionCommand("load", "gpu compute saturation",
+					"Runs the kernels keeping the percent of the compute of every selected gpu assigned to the container busy.",
+					`# Saturate the compute of the gpu 1 assigned to the container ee54f1e61c08
+blade create cri gpu load --percent 100 --device 1 --container-id ee54f1e61c08 --container-runtime containerd`),
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*GPUCommandModelSpec) Name() string {
+	return "gpu"
+}
+
+func (*GPUCommandModelSpec) ShortDesc() string {
+	return "Gpu experiment"
+}
+
+func (*GPUCommandModelSpec) LongDesc() string {
+	return "Gpu experiment of the containers using the nvidia runtime, the chaos_gpu helper runs in the pid " +
+		"namespace and the cgroups of the container, so the pressure is accounted to the container and limited to " +
+		"the gpus assigned to it."
+}
+
+type GPUActionCommand struct {
+	spec.BaseExpActionCommandSpec
+	name      string
+	shortDesc string
+}
+
+func newGPUActionCommand(name, shortDesc, longDesc, example string) spec.ExpActionCommandSpec {
+	return &GPUActionCommand{
+		BaseExpActionCommandSpec: spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     GPUPercentFlag,
+					Desc:     "percent of every selected gpu, 1 to 100",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name: GPUDeviceFlag,
+					Desc: "comma separated minor numbers of the gpus, default is all gpus assigned to the container",
+				},
+			},
+			ActionExecutor:   &gpuActionExecutor{action: name},
+			ActionLongDesc:   longDesc,
+			ActionExample:    example,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+		name:      name,
+		shortDesc: shortDesc,
+	}
+}
+
+func (g *GPUActionCommand) Name() string {
+	return g.name
+}
+
+func (*GPUActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (g *GPUActionCommand) ShortDesc() string {
+	return g.shortDesc
+}
+
+func (g *GPUActionCommand) LongDesc() string {
+	return g.ActionLongDesc
+}
+
+// GPUResult is the result of the gpu experiment
+type GPUResult struct {
+	ContainerId string          `json:"containerId"`
+	Pid         int             `json:"pid"`
+	GPUs        []container.GPU `json:"gpus"`
+}
+
+type gpuActionExecutor struct {
+	action string
+}
+
+func (e *gpuActionExecutor) Name() string {
+	return "gpu"
+}
+
+func (e *gpuActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *gpuActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	flags := model.ActionFlags
+	containerInfo, response := GetContainer(ctx, client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name],
+		parseContainerLabelSelector(flags[ContainerLabelSelectorFlag.Name]))
+	if !response.Success {
+		return response
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return stopGPUHelper(ctx, client, uid, containerInfo.ContainerId)
+	}
+	percent, err := strconv.Atoi(flags[GPUPercentFlag])
+	if err != nil || percent < 1 || percent > 100 {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, GPUPercentFlag, flags[GPUPercentFlag],
+			"it must be an integer from 1 to 100")
+	}
+	pid, err, code := client.GetPidById(ctx, containerInfo.ContainerId)
+	if err != nil {
+		return spec.ResponseFail(code, err.Error(), nil)
+	}
+	detected, err := container.DetectGPUs(pid)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "DetectGPUs", err)
+	}
+	gpus, err := selectGPUs(detected, flags[GPUDeviceFlag])
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, GPUDeviceFlag, flags[GPUDeviceFlag], err)
+	}
+	minors := make([]string, 0, len(gpus))
+	for _, gpu := range gpus {
+		minors = append(minors, strconv.Itoa(gpu.Minor))
+	}
+	argv := []string{
+		path.Join(util.GetProgramPath(), spec.BinPath, GPUBin), e.action,
+		fmt.Sprintf("--devices=%s", strings.Join(minors, ",")),
+		fmt.Sprintf("--percent=%d", percent),
+		fmt.Sprintf("--uid=%s", uid),
+	}
+	cgroupRoot := os.Getenv("CGROUP_ROOT")
+	if cgroupRoot == "" {
+		cgroupRoot = "/sys/fs/cgroup/"
+	}
+	// the mount namespace is not entered, the helper uses the driver libraries of the host
+	helperPid, err := startInCgroup(ctx, pid, cgroupRoot, []nsexec.Namespace{nsexec.Pid}, argv)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, GPUBin, err)
+	}
+	return spec.ReturnSuccess(GPUResult{ContainerId: containerInfo.ContainerId, Pid: helperPid, GPUs: gpus})
+}
+
+// selectGPUs returns the gpus of the device flag, all detected gpus if the flag is empty
+func selectGPUs(detected []container.GPU, devices string) ([]container.GPU, error) {
+	if len(detected) == 0 {
+		return nil, fmt.Errorf("no gpu is assigned to the container")
+	}
+	if devices == "" {
+		return detected, nil
+	}
+	gpus := make([]container.GPU, 0)
+	for _, device := range strings.Split(devices, ",") {
+		minor, err := strconv.Atoi(strings.TrimSpace(device))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a minor number", device)
+		}
+		found := false
+		for _, gpu := range detected {
+			if gpu.Minor == minor {
+				gpus, found = append(gpus, gpu), true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("gpu %d is not assigned to the container", minor)
+		}
+	}
+	return gpus, nil
+}
+
+// stopGPUHelper terminates the helper of the experiment found in the container processes, it succeeds if the
+// helper is already exited
+func stopGPUHelper(ctx context.Context, client container.Container, uid, containerId string) *spec.Response {
+	processes, err, code := client.ListProcesses(ctx, containerId)
+	if err != nil {
+		return spec.ResponseFail(code, err.Error(), nil)
+	}
+	for _, process := range processes {
+		if !isGPUHelper(process, uid) {
+			continue
+		}
+		if process.Namespaced {
+			_, err = client.ExecContainer(ctx, containerId, fmt.Sprintf("kill %d", process.Pid))
+		} else {
+			err = syscall.Kill(int(process.Pid), syscall.SIGTERM)
+		}
+		if err != nil && err != syscall.ESRCH {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "kill "+GPUBin, err)
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func isGPUHelper(process container.Process, uid string) bool {
+	fields := strings.Fields(process.Cmdline)
+	if len(fields) == 0 || path.Base(fields[0]) != GPUBin {
+		return false
+	}
+	for _, field := range fields[1:] {
+		if field == "--uid="+uid {
+			return true
+		}
+	}
+	return false
+}
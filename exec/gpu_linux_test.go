@@ -0,0 +1,90 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+func TestSelectGPUs(t *testing.T) {
+	detected := []container.GPU{{Minor: 0, Device: "/dev/nvidia0"}, {Minor: 3, Device: "/dev/nvidia3"}}
+	if gpus, err := selectGPUs(detected, ""); err != nil || !reflect.DeepEqual(gpus, detected) {
+		t.Errorf("expected all gpus, got %+v, %v", gpus, err)
+	}
+	if gpus, err := selectGPUs(detected, "3"); err != nil || !reflect.DeepEqual(gpus, detected[1:]) {
+		t.Errorf("expected the gpu 3, got %+v, %v", gpus, err)
+	}
+	for _, devices := range []string{"1", "0,x"} {
+		if _, err := selectGPUs(detected, devices); err == nil {
+			t.Errorf("expected error of the devices %s", devices)
+		}
+	}
+	if _, err := selectGPUs(nil, ""); err == nil {
+		t.Error("expected error without gpus")
+	}
+}
+
+func runGPU(t *testing.T, client *mock.Container, ctx context.Context, flags map[string]string) *spec.Response {
+	t.Helper()
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	t.Cleanup(func() { NewClientFunc = nil })
+	flags[ContainerIdFlag.Name] = "c1"
+	model := &spec.ExpModel{Target: "gpu", ActionName: "memory", ActionFlags: flags}
+	return (&gpuActionExecutor{action: "memory"}).Exec("uid1", ctx, model)
+}
+
+func TestGPUCreateIllegal(t *testing.T) {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	client.Pids["c1"] = 1234
+	for _, percent := range []string{"", "0", "101", "x"} {
+		response := runGPU(t, client, context.Background(), map[string]string{GPUPercentFlag: percent})
+		if response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the illegal percent %q, got %+v", percent, response)
+		}
+	}
+	if calls := client.CallsOf("GetPidById"); len(calls) != 0 {
+		t.Errorf("expected no pid lookup of the illegal flags, got %v", calls)
+	}
+}
+
+func TestGPUDestroy(t *testing.T) {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	client.ListProcessesFunc = func(ctx context.Context, containerId string) ([]container.Process, error, int32) {
+		return []container.Process{
+			{Pid: 1, Comm: "python", Cmdline: "python train.py", Namespaced: true},
+			{Pid: 40, Comm: GPUBin, Cmdline: "/opt/chaosblade/bin/chaos_gpu memory --devices=0 --percent=80 --uid=uid0", Namespaced: true},
+			{Pid: 42, Comm: GPUBin, Cmdline: "/opt/chaosblade/bin/chaos_gpu memory --devices=0 --percent=80 --uid=uid1", Namespaced: true},
+		}, nil, spec.OK.Code
+	}
+	response := runGPU(t, client, spec.SetDestroyFlag(context.Background(), "uid1"), map[string]string{})
+	if !response.Success {
+		t.Fatalf("destroy failed, %s", response.Err)
+	}
+	execs := client.CallsOf("ExecContainer")
+	if len(execs) != 1 || execs[0].Args[1] != "kill 42" {
+		t.Errorf("expected the helper of uid1 killed, got %v", execs)
+	}
+}
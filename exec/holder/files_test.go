@@ -0,0 +1,32 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package holder
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestOpenFiles(t *testing.T) {
+	fds, err := OpenFiles(16)
+	if err != nil || len(fds) != 16 {
+		t.Fatalf("expected 16 files, got %d, %v", len(fds), err)
+	}
+	for _, fd := range fds {
+		syscall.Close(fd)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package holder keeps the helper processes holding the resources of the experiments in the container, such as the
+// zombies and the processes, the state of a helper is saved until it is released by the destroy.
+package holder
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/statefile"
+)
+
+// State is the helper holding the resources of the experiment
+type State struct {
+	Uid  string `json:"uid"`
+	Kind string `json:"kind"`
+	// Starter is the host pid of the process starting the holder, such as nsexec
+	Starter int `json:"starter"`
+	// Holder is the host pid of the holder
+	Holder int `json:"holder"`
+	// Cmdline is the command line of the holder, the pid is not released if it is reused by another process
+	Cmdline string `json:"cmdline"`
+	// Count is the resources held
+	Count int `json:"count"`
+}
+
+// Cmdline returns the command line of the process with the arguments joined by spaces, it is empty for the zombies
+func Cmdline(pid int) (string, error) {
+	content, err := os.ReadFile(path.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(content), "\x00", " ")), nil
+}
+
+// Find returns the pid of the process or its first descendant having the command line, the processes are walked
+// breadth first
+func Find(pid int, cmdline string) (int, error) {
+	queue := []int{pid}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if found, err := Cmdline(current); err == nil && found == cmdline {
+			return current, nil
+		}
+		children, err := container.Children(int32(current))
+		if err != nil {
+			continue
+		}
+		for _, child := range children {
+			queue = append(queue, int(child))
+		}
+	}
+	return 0, fmt.Errorf("process %s under %d not found", cmdline, pid)
+}
+
+// Release kills the holder and the starter if they still have the saved command lines, the resources held by the
+// holder are released by the kernel
+func Release(state State) error {
+	if state.Holder > 0 {
+		if cmdline, err := Cmdline(state.Holder); err == nil && cmdline == state.Cmdline {
+			if err := kill(state.Holder); err != nil {
+				return err
+			}
+		}
+	}
+	if state.Starter > 0 && state.Starter != state.Holder {
+		// the starter exits with the holder, it is killed if it is stuck
+		if cmdline, err := Cmdline(state.Starter); err == nil && strings.Contains(cmdline, state.Cmdline) {
+			return kill(state.Starter)
+		}
+	}
+	return nil
+}
+
+// kill kills the process and waits until it exited
+func kill(pid int) error {
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		if err == syscall.ESRCH {
+			return nil
+		}
+		return err
+	}
+	for i := 0; i < 50; i++ {
+		if cmdline, err := Cmdline(pid); err != nil || cmdline == "" {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("process %d is not exited", pid)
+}
+
+// SaveState saves the state under the state dir
+func SaveState(stateDir string, state State) error {
+	return statefile.Save(stateFile(stateDir, state.Uid), state)
+}
+
+// LoadState returns the state of the experiment, the error is os.ErrNotExist if it is not saved
+func LoadState(stateDir, uid string) (State, error) {
+	var state State
+	if err := statefile.Load(stateFile(stateDir, uid), &state); err != nil {
+		return State{}, err
+	}
+	return state, nil
+}
+
+// RemoveState removes the state of the experiment, it succeeds if the state is not saved
+func RemoveState(stateDir, uid string) error {
+	return statefile.Remove(stateFile(stateDir, uid))
+}
+
+func stateFile(stateDir, uid string) string {
+	return statefile.Path(stateDir, "holder", uid)
+}
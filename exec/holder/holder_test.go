@@ -0,0 +1,59 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package holder
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestFindAndRelease(t *testing.T) {
+	starter := exec.Command("sh", "-c", "sleep 30 & wait")
+	if err := starter.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer starter.Process.Kill()
+	go starter.Wait()
+	var holder int
+	var err error
+	for i := 0; i < 50; i++ {
+		if holder, err = Find(starter.Process.Pid, "sleep 30"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil || holder == starter.Process.Pid {
+		t.Fatalf("expected the sleep found, got %d, %v", holder, err)
+	}
+	if _, err := Find(starter.Process.Pid, "sleep 31"); err == nil {
+		t.Error("expected error of the absent process")
+	}
+	state := State{Uid: "uid1", Starter: starter.Process.Pid, Holder: holder, Cmdline: "sleep 30"}
+	if err := Release(state); err != nil {
+		t.Fatal(err)
+	}
+	for _, pid := range []int{holder, starter.Process.Pid} {
+		if cmdline, err := Cmdline(pid); err == nil && cmdline != "" {
+			t.Errorf("expected %d killed, got %s", pid, cmdline)
+		}
+	}
+	// released twice
+	if err := Release(state); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,294 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rootfs"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/tamper"
+)
+
+// The flags of the hosts experiment
+const (
+	HostsIpFlag       = "ip"
+	HostsHostnameFlag = "hostname"
+	HostsModeFlag     = "mode"
+)
+
+// The modes of the hosts experiment
+const (
+	// HostsModeOverride removes the hostnames from the existing entries before the new entry is appended
+	HostsModeOverride = "override"
+	// HostsModeAppend appends the new entry only, the existing entries of the hostnames win
+	HostsModeAppend = "append"
+)
+
+// hostsFile is the path of the hosts file in the container
+const hostsFile = "/etc/hosts"
+
+type HostsCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewHostsCommandSpec() spec.ExpModelCommandSpec {
+	return &HostsCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&HostsRewriteActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:     HostsIpFlag,
+								Desc:     "address the hostnames are resolved to, such as 127.0.0.1 or an unreachable address",
+								Required: true,
+							},
+							&spec.ExpFlag{
+								Name:     HostsHostnameFlag,
+								Desc:     "hostnames redirected, separated by comma, such as api.example.com,db.example.com",
+								Required: true,
+							},
+							&spec.ExpFlag{
+								Name: HostsModeFlag,
+								Desc: fmt.Sprintf("%s removes the hostnames from the existing entries, %s appends the "+
+									"entry only, default is %s", HostsModeOverride, HostsModeAppend, HostsModeOverride),
+								Default: HostsModeOverride,
+							},
+						},
+						ActionExecutor: &hostsRewriteExecutor{},
+						ActionLongDesc: "The /etc/hosts of the container is rewritten in place on the host to resolve " +
+							"the hostnames to the address, the original content is saved before and written back by the " +
+							"destroy if the file is not changed meanwhile. The resolvers read the hosts file before the " +
+							"dns, but the names resolved already may be cached by the application. The hosts file of " +
+							"the kubelet is shared by all the containers of the pod.",
+						ActionExample: `# Resolve the hostname of the dependency to an unreachable address
+blade create cri hosts rewrite --hostname api.example.com --ip 192.0.2.1 --container-id ee54f1e61c08
+
+# Append an entry of the hostnames without overriding the existing ones
+blade create cri hosts rewrite --hostname db.example.com,cache.example.com --ip 127.0.0.1 --mode append --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*HostsCommandModelSpec) Name() string {
+	return "hosts"
+}
+
+func (*HostsCommandModelSpec) ShortDesc() string {
+	return "Hosts experiment"
+}
+
+func (*HostsCommandModelSpec) LongDesc() string {
+	return "Hosts experiment, the hosts file of the container is rewritten to redirect the hostnames and restored."
+}
+
+type HostsRewriteActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*HostsRewriteActionCommand) Name() string {
+	return "rewrite"
+}
+
+func (*HostsRewriteActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*HostsRewriteActionCommand) ShortDesc() string {
+	return "hosts file rewrite"
+}
+
+func (c *HostsRewriteActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+// HostsResult is the result of the hosts experiment
+type HostsResult struct {
+	ContainerId string   `json:"containerId"`
+	Ip          string   `json:"ip"`
+	Hostnames   []string `json:"hostnames"`
+	Mode        string   `json:"mode"`
+	HostFile    string   `json:"hostFile"`
+	// Warning is set if the hosts file is shared by the other containers
+	Warning string `json:"warning,omitempty"`
+}
+
+type hostsRewriteExecutor struct {
+}
+
+func (e *hostsRewriteExecutor) Name() string {
+	return "hosts"
+}
+
+func (e *hostsRewriteExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *hostsRewriteExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		restored, err := tamper.Restore(util.GetProgramPath(), suid)
+		if err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "restore", err)
+		}
+		if !restored {
+			log.Warnf(ctx, "the hosts file of experiment %s is not restored, it is rewritten or already restored", suid)
+		}
+		return spec.ReturnSuccess(uid)
+	}
+	flags := model.ActionFlags
+	ip := flags[HostsIpFlag]
+	if ip == "" {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, HostsIpFlag)
+	}
+	if net.ParseIP(ip) == nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, HostsIpFlag, ip, "it is not an ip address")
+	}
+	if flags[HostsHostnameFlag] == "" {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, HostsHostnameFlag)
+	}
+	hostnames, err := parseHostnames(flags[HostsHostnameFlag])
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, HostsHostnameFlag, flags[HostsHostnameFlag], err)
+	}
+	mode := flags[HostsModeFlag]
+	if mode == "" {
+		mode = HostsModeOverride
+	}
+	if mode != HostsModeOverride && mode != HostsModeAppend {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, HostsModeFlag, mode,
+			fmt.Sprintf("it must be %s or %s", HostsModeOverride, HostsModeAppend))
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	containerInfo, response := GetContainer(ctx, client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name],
+		parseContainerLabelSelector(flags[ContainerLabelSelectorFlag.Name]))
+	if !response.Success {
+		return response
+	}
+	result := HostsResult{ContainerId: containerInfo.ContainerId, Ip: ip, Hostnames: hostnames, Mode: mode}
+	var root, name string
+	var code int32
+	if mount, ok := containerInfo.MountOf(hostsFile); ok {
+		if mount.VolumeType == container.VolumeKubelet {
+			result.Warning = fmt.Sprintf("the hosts file %s is shared by all the containers of the pod", mount.HostPath)
+			log.Warnf(ctx, "%s", result.Warning)
+		}
+		root, name = mount.RootOf(hostsFile)
+	} else {
+		name = hostsFile
+		if root, err, code = client.GetRootfsPath(ctx, containerInfo.ContainerId); err != nil {
+			return spec.ResponseFail(code, err.Error(), nil)
+		}
+	}
+	// the links are resolved in the container, so a link of /etc/hosts never leads to the files of the host
+	if result.HostFile, err = rootfs.Resolve(root, name); err != nil {
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, hostsFile, err)
+	}
+	origin, err := os.ReadFile(result.HostFile)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, hostsFile, err)
+	}
+	content := rewriteHosts(origin, uid, ip, hostnames, mode == HostsModeOverride)
+	if _, err := tamper.Tamper(util.GetProgramPath(), uid, root, name, tamper.ModeReplace, content); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "rewrite", err)
+	}
+	log.Infof(ctx, "the hosts file of container %s resolves %v to %s, host file %s", containerInfo.ContainerId,
+		hostnames, ip, result.HostFile)
+	return spec.ReturnSuccess(result)
+}
+
+// parseHostnames splits the hostnames by comma, the empty ones are ignored
+func parseHostnames(raw string) ([]string, error) {
+	hostnames := make([]string, 0)
+	for _, hostname := range strings.Split(raw, ",") {
+		hostname = strings.TrimSpace(hostname)
+		if hostname == "" {
+			continue
+		}
+		if strings.ContainsAny(hostname, " \t#") {
+			return nil, fmt.Errorf("illegal hostname %q", hostname)
+		}
+		hostnames = append(hostnames, hostname)
+	}
+	if len(hostnames) == 0 {
+		return nil, fmt.Errorf("no hostname")
+	}
+	return hostnames, nil
+}
+
+// rewriteHosts appends the entry of the hostnames marked by the uid, the override removes the hostnames from the
+// existing entries first and drops the entries left without names, the comments and blank lines are kept
+func rewriteHosts(content []byte, uid, ip string, hostnames []string, override bool) []byte {
+	var buffer bytes.Buffer
+	if override {
+		removed := make(map[string]bool, len(hostnames))
+		for _, hostname := range hostnames {
+			removed[strings.ToLower(hostname)] = true
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			line := scanner.Text()
+			entry, comment, _ := strings.Cut(line, "#")
+			fields := strings.Fields(entry)
+			if len(fields) < 2 {
+				buffer.WriteString(line + "\n")
+				continue
+			}
+			names := make([]string, 0, len(fields)-1)
+			for _, name := range fields[1:] {
+				if !removed[strings.ToLower(name)] {
+					names = append(names, name)
+				}
+			}
+			if len(names) == len(fields)-1 {
+				buffer.WriteString(line + "\n")
+			} else if len(names) > 0 {
+				line = fields[0] + "\t" + strings.Join(names, " ")
+				if comment != "" {
+					line += "\t#" + comment
+				}
+				buffer.WriteString(line + "\n")
+			}
+		}
+	} else {
+		buffer.Write(content)
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			buffer.WriteString("\n")
+		}
+	}
+	buffer.WriteString(fmt.Sprintf("%s\t%s\t# chaosblade %s\n", ip, strings.Join(hostnames, " "), uid))
+	return buffer.Bytes()
+}
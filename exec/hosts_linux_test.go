@@ -0,0 +1,151 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+const testHosts = `# Kubernetes-managed hosts file.
+127.0.0.1	localhost
+10.0.0.5	api.example.com api	# service
+10.0.0.6	db.example.com
+`
+
+func runHostsRewrite(ctx context.Context, hostsPath string, flags map[string]string) *spec.Response {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1", Mounts: []container.Mount{
+		{ContainerPath: "/etc/hosts", HostPath: hostsPath, VolumeType: container.VolumeKubelet},
+	}})
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	flags[ContainerIdFlag.Name] = "c1"
+	model := &spec.ExpModel{Target: "hosts", ActionName: "rewrite", ActionFlags: flags}
+	return (&hostsRewriteExecutor{}).Exec("uid1", ctx, model)
+}
+
+func TestRewriteHosts(t *testing.T) {
+	tests := []struct {
+		hostnames []string
+		override  bool
+		expected  string
+	}{
+		{[]string{"API.example.com", "db.example.com"}, true, `# Kubernetes-managed hosts file.
+127.0.0.1	localhost
+10.0.0.5	api	# service
+192.0.2.1	API.example.com db.example.com	# chaosblade uid1
+`},
+		{[]string{"api.example.com"}, false, testHosts + "192.0.2.1\tapi.example.com\t# chaosblade uid1\n"},
+	}
+	for _, test := range tests {
+		if content := rewriteHosts([]byte(testHosts), "uid1", "192.0.2.1", test.hostnames, test.override); string(content) != test.expected {
+			t.Errorf("unexpected hosts of %v, got\n%s", test.hostnames, content)
+		}
+	}
+	if content := rewriteHosts([]byte("127.0.0.1 localhost"), "uid1", "::1", []string{"a"}, false); string(content) != "127.0.0.1 localhost\n::1\ta\t# chaosblade uid1\n" {
+		t.Errorf("expected the newline added, got %q", content)
+	}
+}
+
+func TestHostsRewrite(t *testing.T) {
+	hostsPath := path.Join(t.TempDir(), "etc-hosts")
+	if err := os.WriteFile(hostsPath, []byte(testHosts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	response := runHostsRewrite(context.Background(), hostsPath,
+		map[string]string{HostsIpFlag: "192.0.2.1", HostsHostnameFlag: "db.example.com"})
+	if !response.Success {
+		t.Fatalf("expected success, got %+v", response)
+	}
+	if result := response.Result.(HostsResult); result.Warning == "" || result.HostFile != hostsPath {
+		t.Errorf("expected the warning of the pod hosts file, got %+v", result)
+	}
+	if content, _ := os.ReadFile(hostsPath); string(content) == testHosts {
+		t.Error("expected the hosts file rewritten")
+	}
+	response = runHostsRewrite(spec.SetDestroyFlag(context.Background(), "uid1"), hostsPath, map[string]string{})
+	if !response.Success {
+		t.Fatalf("expected the destroy success, got %+v", response)
+	}
+	if content, _ := os.ReadFile(hostsPath); string(content) != testHosts {
+		t.Errorf("expected the hosts file restored, got %q", content)
+	}
+}
+
+func TestHostsRewriteIllegal(t *testing.T) {
+	hostsPath := path.Join(t.TempDir(), "etc-hosts")
+	tests := []struct {
+		flags map[string]string
+		code  int32
+	}{
+		{map[string]string{HostsHostnameFlag: "api"}, spec.ParameterLess.Code},
+		{map[string]string{HostsIpFlag: "192.0.2.1"}, spec.ParameterLess.Code},
+		{map[string]string{HostsIpFlag: "api.example.com", HostsHostnameFlag: "api"}, spec.ParameterIllegal.Code},
+		{map[string]string{HostsIpFlag: "192.0.2.1", HostsHostnameFlag: "api #"}, spec.ParameterIllegal.Code},
+		{map[string]string{HostsIpFlag: "192.0.2.1", HostsHostnameFlag: ", "}, spec.ParameterIllegal.Code},
+		{map[string]string{HostsIpFlag: "192.0.2.1", HostsHostnameFlag: "api", HostsModeFlag: "prepend"},
+			spec.ParameterIllegal.Code},
+		{map[string]string{HostsIpFlag: "192.0.2.1", HostsHostnameFlag: "api"}, spec.FileCantReadOrOpen.Code},
+	}
+	for _, test := range tests {
+		response := runHostsRewrite(context.Background(), hostsPath, test.flags)
+		if response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of %v, got %+v", test.code, test.flags, response)
+		}
+	}
+}
+
+func TestHostsRewriteRootfs(t *testing.T) {
+	dir := t.TempDir()
+	rootfs, shadow := path.Join(dir, "rootfs"), path.Join(dir, "shadow")
+	if err := os.MkdirAll(path.Join(rootfs, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(shadow, []byte(testHosts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the link is absolute in the container, it must not lead to the file of the host
+	if err := os.Symlink(shadow, path.Join(rootfs, "etc/hosts")); err != nil {
+		t.Fatal(err)
+	}
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	client.GetRootfsPathFunc = func(ctx context.Context, containerId string) (string, error, int32) {
+		return rootfs, nil, 0
+	}
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	response := (&hostsRewriteExecutor{}).Exec("uid1", context.Background(), &spec.ExpModel{Target: "hosts",
+		ActionName: "rewrite", ActionFlags: map[string]string{ContainerIdFlag.Name: "c1", HostsIpFlag: "192.0.2.1",
+			HostsHostnameFlag: "db.example.com"}})
+	if response.Success || response.Code != spec.FileCantReadOrOpen.Code {
+		t.Errorf("expected the link escaping the rootfs refused, got %+v", response)
+	}
+	if content, _ := os.ReadFile(shadow); string(content) != testHosts {
+		t.Errorf("expected the file of the host unchanged, got %q", content)
+	}
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package imagefs
+
+import (
+	"os"
+)
+
+// allocate writes the zeros of the range of the file, there is no fallocate
+var allocate = func(f *os.File, offset, size int64) error {
+	zeros := make([]byte, 1<<20)
+	for written := int64(0); written < size; {
+		n := int64(len(zeros))
+		if size-written < n {
+			n = size - written
+		}
+		if _, err := f.WriteAt(zeros[:n], offset+written); err != nil {
+			return err
+		}
+		written += n
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package imagefs fills the image filesystem of the runtime with an allocated file up to a percent of its bytes, the
+// kubelet sees the pressure of the imagefs, garbage collects the unused images and evicts the pods. The file is
+// removed by the destroy.
+package imagefs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/statefile"
+)
+
+// chunkSize is the bytes allocated at once, the context and the usage are checked between the chunks
+const chunkSize = 256 << 20
+
+// statfs reads the filesystem statistics, it is replaced by the tests
+var statfs = syscall.Statfs
+
+// Usage is the byte usage of the filesystem, Available is the bytes available to the unprivileged users as the
+// kubelet reads them
+type Usage struct {
+	Total     uint64 `json:"total"`
+	Available uint64 `json:"available"`
+}
+
+// Percent returns the percent of the bytes used, the kubelet thresholds are of the same percent
+func (u Usage) Percent() float64 {
+	if u.Total == 0 {
+		return 0
+	}
+	return float64(u.Total-u.Available) * 100 / float64(u.Total)
+}
+
+// Needed returns the bytes to allocate for the usage to reach the percent, 0 if it is reached already
+func (u Usage) Needed(percent float64) uint64 {
+	target := uint64(float64(u.Total) * percent / 100)
+	used := u.Total - u.Available
+	if used >= target {
+		return 0
+	}
+	return target - used
+}
+
+// ReadUsage returns the byte usage of the filesystem of the directory
+func ReadUsage(dir string) (Usage, error) {
+	var stat syscall.Statfs_t
+	if err := statfs(dir, &stat); err != nil {
+		return Usage{}, err
+	}
+	if stat.Blocks == 0 {
+		return Usage{}, fmt.Errorf("the filesystem of %s has no blocks", dir)
+	}
+	return Usage{Total: stat.Blocks * uint64(stat.Bsize), Available: stat.Bavail * uint64(stat.Bsize)}, nil
+}
+
+// Fill allocates the file until the usage of the filesystem of its directory reaches the percent, the usage is read
+// again before every chunk since the images are pulled and removed meanwhile. It returns the bytes allocated, they are
+// kept if it fails, the file is removed by the caller
+func Fill(ctx context.Context, file string, percent float64) (uint64, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	allocated := uint64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return allocated, err
+		}
+		usage, err := ReadUsage(path.Dir(file))
+		if err != nil {
+			return allocated, err
+		}
+		size := usage.Needed(percent)
+		if size == 0 {
+			return allocated, nil
+		}
+		if size > chunkSize {
+			size = chunkSize
+		}
+		if err := allocate(f, int64(allocated), int64(size)); err != nil {
+			return allocated, err
+		}
+		allocated += size
+	}
+}
+
+// State is the file allocated by the experiment
+type State struct {
+	Uid string `json:"uid"`
+	// File is the host path of the file removed by the destroy
+	File      string `json:"file"`
+	Allocated uint64 `json:"allocated"`
+	Before    Usage  `json:"before"`
+	After     Usage  `json:"after"`
+}
+
+// SaveState saves the state under the state dir
+func SaveState(stateDir string, state State) error {
+	return statefile.Save(stateFile(stateDir, state.Uid), state)
+}
+
+// LoadState returns the state of the experiment, the error is os.ErrNotExist if it is not saved
+func LoadState(stateDir, uid string) (State, error) {
+	var state State
+	if err := statefile.Load(stateFile(stateDir, uid), &state); err != nil {
+		return State{}, err
+	}
+	return state, nil
+}
+
+// RemoveState removes the state of the experiment, it succeeds if the state is not saved
+func RemoveState(stateDir, uid string) error {
+	return statefile.Remove(stateFile(stateDir, uid))
+}
+
+func stateFile(stateDir, uid string) string {
+	return statefile.Path(stateDir, "imagefs", uid)
+}
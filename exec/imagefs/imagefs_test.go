@@ -0,0 +1,86 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package imagefs
+
+import (
+	"context"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+// fakeFilesystem reports the bytes of a filesystem of 4k blocks with the used bytes and the size of the file, the
+// file is allocated by the truncate
+func fakeFilesystem(t *testing.T, total, used uint64, file string) {
+	t.Helper()
+	t.Cleanup(func() { statfs = syscall.Statfs })
+	statfs = func(p string, stat *syscall.Statfs_t) error {
+		allocated := uint64(0)
+		if info, err := os.Stat(file); err == nil {
+			allocated = uint64(info.Size())
+		}
+		stat.Bsize, stat.Blocks, stat.Bavail = 4096, total/4096, (total-used-allocated)/4096
+		return nil
+	}
+	saved := allocate
+	t.Cleanup(func() { allocate = saved })
+	allocate = func(f *os.File, offset, size int64) error {
+		return f.Truncate(offset + size)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	usage := Usage{Total: 1000, Available: 400}
+	if usage.Percent() != 60 {
+		t.Errorf("expected 60%% used, got %f", usage.Percent())
+	}
+	if usage.Needed(85) != 250 || usage.Needed(50) != 0 {
+		t.Errorf("unexpected bytes needed %d, %d", usage.Needed(85), usage.Needed(50))
+	}
+	if (Usage{}).Percent() != 0 {
+		t.Error("expected 0% of the empty usage")
+	}
+}
+
+func TestFill(t *testing.T) {
+	file := path.Join(t.TempDir(), "fill")
+	fakeFilesystem(t, 4<<30, 1<<30, file)
+	allocated, err := Fill(context.Background(), file, 90)
+	if err != nil {
+		t.Fatalf("fill failed, %v", err)
+	}
+	if expected := uint64(4<<30*90/100 - 1<<30); allocated != expected {
+		t.Errorf("expected %d bytes allocated, got %d", expected, allocated)
+	}
+	if usage, _ := ReadUsage(path.Dir(file)); usage.Percent() < 90 || usage.Percent() > 90.01 {
+		t.Errorf("expected 90%% used, got %f", usage.Percent())
+	}
+	if _, err := Fill(context.Background(), file, 90); !os.IsExist(err) {
+		t.Errorf("expected the existing file refused, got %v", err)
+	}
+}
+
+func TestFillCanceled(t *testing.T) {
+	file := path.Join(t.TempDir(), "fill")
+	fakeFilesystem(t, 4<<30, 0, file)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if allocated, err := Fill(ctx, file, 90); err != context.Canceled || allocated != 0 {
+		t.Errorf("expected the fill canceled, got %d, %v", allocated, err)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package inode fills the inodes of a filesystem with the empty files under a directory, the bytes are not used so the
+// filesystem fails with ENOSPC while df reports free space. The files are removed with the directory.
+package inode
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/statefile"
+)
+
+// filesPerDir is the files created in one sub directory, the directories are not too large to list and remove
+const filesPerDir = 1000
+
+// statfs reads the filesystem statistics, it is replaced by the tests
+var statfs = syscall.Statfs
+
+// Usage is the inode usage of the filesystem
+type Usage struct {
+	Total uint64 `json:"total"`
+	Free  uint64 `json:"free"`
+}
+
+// Percent returns the percent of the inodes used
+func (u Usage) Percent() float64 {
+	if u.Total == 0 {
+		return 0
+	}
+	return float64(u.Total-u.Free) * 100 / float64(u.Total)
+}
+
+// ReadUsage returns the inode usage of the filesystem of the directory, it fails if the filesystem has no fixed
+// number of the inodes, such as btrfs
+func ReadUsage(dir string) (Usage, error) {
+	var stat syscall.Statfs_t
+	if err := statfs(dir, &stat); err != nil {
+		return Usage{}, err
+	}
+	if stat.Files == 0 {
+		return Usage{}, fmt.Errorf("the filesystem of %s has no fixed number of inodes", dir)
+	}
+	return Usage{Total: stat.Files, Free: stat.Ffree}, nil
+}
+
+// Fill creates the empty files under the directory until the count is created or the inodes used reach the percent
+// of the filesystem, the usage is checked before every sub directory. It returns the files created, they are kept if
+// it fails, the directory is removed by the caller
+func Fill(ctx context.Context, dir string, count int, percent float64) (int, error) {
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return 0, err
+	}
+	created := 0
+	for sub := 0; created < count; sub++ {
+		if err := ctx.Err(); err != nil {
+			return created, err
+		}
+		usage, err := ReadUsage(dir)
+		if err != nil {
+			return created, err
+		}
+		// the sub directory takes an inode too
+		if usage.Percent() >= percent || usage.Free < 2 {
+			break
+		}
+		limit := count - created
+		if limit > filesPerDir {
+			limit = filesPerDir
+		}
+		// the files are created up to the threshold in the last sub directory
+		if left := uint64(float64(usage.Total)*percent/100) - (usage.Total - usage.Free); left < uint64(limit)+1 {
+			limit = int(left) - 1
+		}
+		if limit <= 0 {
+			break
+		}
+		subDir := path.Join(dir, fmt.Sprintf("%d", sub))
+		if err := os.Mkdir(subDir, 0700); err != nil {
+			return created, err
+		}
+		for i := 0; i < limit; i++ {
+			fd, err := syscall.Open(path.Join(subDir, fmt.Sprintf("%d", i)),
+				syscall.O_CREAT|syscall.O_EXCL|syscall.O_WRONLY|syscall.O_CLOEXEC, 0600)
+			if err != nil {
+				return created, err
+			}
+			syscall.Close(fd)
+			created++
+		}
+	}
+	return created, nil
+}
+
+// State is the directory filled by the experiment
+type State struct {
+	Uid string `json:"uid"`
+	// Dir is the host path of the directory removed by the destroy
+	Dir     string `json:"dir"`
+	Created int    `json:"created"`
+	Before  Usage  `json:"before"`
+	After   Usage  `json:"after"`
+}
+
+// SaveState saves the state under the state dir
+func SaveState(stateDir string, state State) error {
+	return statefile.Save(stateFile(stateDir, state.Uid), state)
+}
+
+// LoadState returns the state of the experiment, the error is os.ErrNotExist if it is not saved
+func LoadState(stateDir, uid string) (State, error) {
+	var state State
+	if err := statefile.Load(stateFile(stateDir, uid), &state); err != nil {
+		return State{}, err
+	}
+	return state, nil
+}
+
+// RemoveState removes the state of the experiment, it succeeds if the state is not saved
+func RemoveState(stateDir, uid string) error {
+	return statefile.Remove(stateFile(stateDir, uid))
+}
+
+func stateFile(stateDir, uid string) string {
+	return statefile.Path(stateDir, "inode", uid)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package inode
+
+import (
+	"context"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+// fakeStatfs reports the inodes of a filesystem with the used inodes and the files created under the dir
+func fakeStatfs(t *testing.T, total, used uint64, dir string) {
+	t.Helper()
+	t.Cleanup(func() { statfs = syscall.Statfs })
+	statfs = func(p string, stat *syscall.Statfs_t) error {
+		created := uint64(0)
+		subs, _ := os.ReadDir(dir)
+		for _, sub := range subs {
+			files, _ := os.ReadDir(path.Join(dir, sub.Name()))
+			created += uint64(len(files)) + 1
+		}
+		stat.Files, stat.Ffree = total, total-used-created
+		return nil
+	}
+}
+
+func TestFillCount(t *testing.T) {
+	dir := path.Join(t.TempDir(), "fill")
+	fakeStatfs(t, 1000000, 1000, dir)
+	created, err := Fill(context.Background(), dir, 2500, 90)
+	if err != nil || created != 2500 {
+		t.Fatalf("expected 2500 files, got %d, %v", created, err)
+	}
+	subs, _ := os.ReadDir(dir)
+	if len(subs) != 3 {
+		t.Errorf("expected 3 sub directories, got %d", len(subs))
+	}
+}
+
+func TestFillPercent(t *testing.T) {
+	dir := path.Join(t.TempDir(), "fill")
+	fakeStatfs(t, 10000, 5000, dir)
+	if _, err := Fill(context.Background(), dir, 100000, 80); err != nil {
+		t.Fatal(err)
+	}
+	usage, _ := ReadUsage(dir)
+	if percent := usage.Percent(); percent > 80 || percent < 79.9 {
+		t.Errorf("expected the usage stopped at 80%%, got %f", percent)
+	}
+}
+
+func TestReadUsageDynamic(t *testing.T) {
+	t.Cleanup(func() { statfs = syscall.Statfs })
+	statfs = func(p string, stat *syscall.Statfs_t) error {
+		stat.Files, stat.Ffree = 0, 0
+		return nil
+	}
+	if _, err := ReadUsage("/"); err == nil {
+		t.Error("expected error of the dynamic inodes")
+	}
+}
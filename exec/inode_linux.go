@@ -0,0 +1,239 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/disk"
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/inode"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rootfs"
+)
+
+// The flags of the inode fill experiment
+const (
+	InodePathFlag    = "path"
+	InodeCountFlag   = "count"
+	InodePercentFlag = "percent"
+)
+
+// inodeDirPrefix is the name prefix of the directory of the empty files
+const inodeDirPrefix = ".chaosblade-inode-"
+
+// withInodeFillAction adds the inode fill action to the disk model of chaos_os, its executor is set after the
+// executor of the common models
+func withInodeFillAction(commandSpec spec.ExpModelCommandSpec) spec.ExpModelCommandSpec {
+	if diskSpec, ok := commandSpec.(*disk.DiskCommandSpec); ok {
+		diskSpec.ExpActions = append(diskSpec.ExpActions, NewInodeFillActionCommand())
+	}
+	return commandSpec
+}
+
+func NewInodeFillActionCommand() spec.ExpActionCommandSpec {
+	return &InodeFillActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:    InodePathFlag,
+					Desc:    "directory in the container filled, on the writable layer or a volume, default is /",
+					Default: "/",
+				},
+				&spec.ExpFlag{
+					Name: InodeCountFlag,
+					Desc: "empty files created at most, default is until the percent reached",
+				},
+				&spec.ExpFlag{
+					Name:    InodePercentFlag,
+					Desc:    "percent of the inodes of the filesystem used when the fill stops, 1 to 99, default is 95",
+					Default: "95",
+				},
+			},
+			ActionExecutor: &inodeFillExecutor{},
+			ActionLongDesc: "The inodes of the filesystem of the path are filled with the empty files, the bytes are " +
+				"not used so the writes fail with no space left while df reports free space. The files are created " +
+				"from the host in the writable layer of the container or the volume of the path, until the count is " +
+				"created or the inodes used reach the percent. The destroy removes all the files.",
+			ActionExample: `# Fill the inodes of the writable layer of the container to 95%
+blade create cri disk inode --container-id ee54f1e61c08
+
+# Create 100000 empty files in the volume mounted at /data, stopping at 90% of the inodes
+blade create cri disk inode --path /data --count 100000 --percent 90 --container-id ee54f1e61c08`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+type InodeFillActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*InodeFillActionCommand) Name() string {
+	return "inode"
+}
+
+func (*InodeFillActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*InodeFillActionCommand) ShortDesc() string {
+	return "inode fill"
+}
+
+func (c *InodeFillActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+type inodeFillExecutor struct {
+}
+
+func (e *inodeFillExecutor) Name() string {
+	return "disk"
+}
+
+func (e *inodeFillExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *inodeFillExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return removeInodeFill(ctx, suid)
+	}
+	flags := model.ActionFlags
+	count, response := intervalFlag(flags, InodeCountFlag, math.MaxInt32, 1)
+	if response != nil {
+		return response
+	}
+	percent, response := rangeFlag(flags, InodePercentFlag, 95, 1, 99)
+	if response != nil {
+		return response
+	}
+	containerPath := flags[InodePathFlag]
+	if containerPath == "" {
+		containerPath = "/"
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	containerInfo, response := GetContainer(ctx, client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name],
+		parseContainerLabelSelector(flags[ContainerLabelSelectorFlag.Name]))
+	if !response.Success {
+		return response
+	}
+	warnSharedVolume(ctx, containerInfo, model)
+	var root, name string
+	var code int32
+	if mount, ok := containerInfo.MountOf(containerPath); ok {
+		if mount.ReadOnly {
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, InodePathFlag, containerPath,
+				"it is on a read only volume")
+		}
+		root, name = mount.RootOf(containerPath)
+	} else {
+		name = containerPath
+		if root, err, code = client.GetRootfsPath(ctx, containerInfo.ContainerId); err != nil {
+			return spec.ResponseFail(code, err.Error(), nil)
+		}
+	}
+	// the links are resolved in the container, and the directory must be on the filesystem of the container, not a
+	// directory of the host mounted under the rootfs
+	hostPath, err := rootfs.Resolve(root, name)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, InodePathFlag, containerPath, err)
+	}
+	if info, err := os.Stat(hostPath); err != nil || !info.IsDir() {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, InodePathFlag, containerPath, "it is not a directory")
+	}
+	if err := checkSameDevice(root, hostPath); err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, InodePathFlag, containerPath, err)
+	}
+	before, err := inode.ReadUsage(hostPath)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, InodePathFlag, containerPath, err)
+	}
+	if before.Percent() >= float64(percent) {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, InodePercentFlag, percent,
+			fmt.Sprintf("%.1f%% of the inodes are used already", before.Percent()))
+	}
+	// the state is saved before the fill, so the files created are removed even if the fill is interrupted
+	stateDir := util.GetProgramPath()
+	state := inode.State{Uid: uid, Dir: path.Join(hostPath, inodeDirPrefix+uid), Before: before}
+	if err := inode.SaveState(stateDir, state); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	state.Created, err = inode.Fill(ctx, state.Dir, count, float64(percent))
+	// the inodes may be taken by the application meanwhile
+	if err != nil && !errors.Is(err, syscall.ENOSPC) {
+		os.RemoveAll(state.Dir)
+		inode.RemoveState(stateDir, uid)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "fill", err)
+	}
+	state.After, _ = inode.ReadUsage(hostPath)
+	if err := inode.SaveState(stateDir, state); err != nil {
+		log.Warnf(ctx, "update the state of experiment %s failed, %v", uid, err)
+	}
+	log.Infof(ctx, "%d files are created in %s, the inodes used are %.1f%%", state.Created, state.Dir,
+		state.After.Percent())
+	return spec.ReturnSuccess(state)
+}
+
+// checkSameDevice returns error if the directory resolved is on another device than the root, the rootfs of the
+// container or the source of its volume
+func checkSameDevice(root, dir string) error {
+	var rootStat, dirStat syscall.Stat_t
+	if err := syscall.Stat(root, &rootStat); err != nil {
+		return err
+	}
+	if err := syscall.Stat(dir, &dirStat); err != nil {
+		return err
+	}
+	if rootStat.Dev != dirStat.Dev {
+		return fmt.Errorf("%s is on another filesystem than %s", dir, root)
+	}
+	return nil
+}
+
+// removeInodeFill removes the directory of the files, it succeeds if the directory is removed with the container
+func removeInodeFill(ctx context.Context, uid string) *spec.Response {
+	stateDir := util.GetProgramPath()
+	state, err := inode.LoadState(stateDir, uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, stateDir, err)
+	}
+	if err := os.RemoveAll(state.Dir); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove", err)
+	}
+	if err := inode.RemoveState(stateDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove state", err)
+	}
+	log.Infof(ctx, "the %d files of experiment %s are removed", state.Created, uid)
+	return spec.ReturnSuccess(uid)
+}
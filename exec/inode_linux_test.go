@@ -0,0 +1,113 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/inode"
+)
+
+func runInodeFill(ctx context.Context, rootfs, volume string, flags map[string]string) *spec.Response {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1", Mounts: []container.Mount{
+		{ContainerPath: "/data", HostPath: volume, VolumeType: container.VolumeEmptyDir, VolumeName: "data"},
+		{ContainerPath: "/config", HostPath: volume, ReadOnly: true, VolumeType: container.VolumeConfigMap},
+	}})
+	client.GetRootfsPathFunc = func(ctx context.Context, containerId string) (string, error, int32) {
+		return rootfs, nil, 0
+	}
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	flags[ContainerIdFlag.Name] = "c1"
+	model := &spec.ExpModel{Target: "disk", ActionName: "inode", ActionFlags: flags}
+	return (&inodeFillExecutor{}).Exec("uid1", ctx, model)
+}
+
+func TestInodeFill(t *testing.T) {
+	rootfs, volume := t.TempDir(), t.TempDir()
+	if err := os.Mkdir(path.Join(rootfs, "tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		containerPath string
+		dir           string
+	}{
+		{"/tmp", path.Join(rootfs, "tmp", inodeDirPrefix+"uid1")},
+		{"/data", path.Join(volume, inodeDirPrefix+"uid1")},
+	}
+	for _, test := range tests {
+		response := runInodeFill(context.Background(), rootfs, volume,
+			map[string]string{InodePathFlag: test.containerPath, InodeCountFlag: "1500", InodePercentFlag: "99"})
+		if !response.Success {
+			t.Fatalf("create of %s failed, %+v", test.containerPath, response)
+		}
+		state := response.Result.(inode.State)
+		if state.Dir != test.dir || state.Created != 1500 {
+			t.Errorf("expected 1500 files in %s, got %+v", test.dir, state)
+		}
+		ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+		if response := runInodeFill(ctx, rootfs, volume, map[string]string{}); !response.Success {
+			t.Fatalf("destroy failed, %+v", response)
+		}
+		if _, err := os.Stat(test.dir); !os.IsNotExist(err) {
+			t.Errorf("expected %s removed, got %v", test.dir, err)
+		}
+		if _, err := inode.LoadState(util.GetProgramPath(), "uid1"); !os.IsNotExist(err) {
+			t.Errorf("expected the state removed, got %v", err)
+		}
+	}
+}
+
+func TestInodeFillIllegal(t *testing.T) {
+	rootfs, volume, outside := t.TempDir(), t.TempDir(), t.TempDir()
+	// the link of the container leads to the directory of the host
+	if err := os.Symlink(outside, path.Join(rootfs, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		flags map[string]string
+		code  int32
+	}{
+		{map[string]string{InodeCountFlag: "0"}, spec.ParameterIllegal.Code},
+		{map[string]string{InodePercentFlag: "100"}, spec.ParameterIllegal.Code},
+		{map[string]string{InodePathFlag: "/config"}, spec.ParameterInvalid.Code},
+		{map[string]string{InodePathFlag: "/absent"}, spec.ParameterInvalid.Code},
+		{map[string]string{InodePathFlag: "/escape"}, spec.ParameterInvalid.Code},
+	}
+	for _, test := range tests {
+		response := runInodeFill(context.Background(), rootfs, volume, test.flags)
+		if response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of %v, got %+v", test.code, test.flags, response)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("expected the directory of the host untouched, got %v", entries)
+	}
+	if err := checkSameDevice(rootfs, "/proc"); err == nil {
+		t.Error("expected the directory on another filesystem refused")
+	}
+}
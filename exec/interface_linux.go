@@ -0,0 +1,555 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netif"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nssession"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rollback"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/transcript"
+)
+
+// The flags of the interface experiments
+const (
+	InterfaceFlag             = "interface"
+	InterfaceModeFlag         = "mode"
+	InterfaceUpIntervalFlag   = "up-interval"
+	InterfaceDownIntervalFlag = "down-interval"
+	InterfaceMTUFlag          = "mtu"
+	InterfaceCollectorFlag    = "collector"
+	InterfaceTunnelFlag       = "tunnel"
+	InterfaceVNIFlag          = "vni"
+	InterfacePortFlag         = "port"
+)
+
+// runInNetns runs the script by the shell of the host in the network namespace of the process, in the session of the
+// process if the context carries the pool of the sessions, or by nsexec if the session can not be started
+var runInNetns = func(ctx context.Context, pid int32, script string) (output string, err error) {
+	start := time.Now()
+	defer func() { transcript.Record(ctx, transcript.NetnsTarget(pid), script, start, output, err) }()
+	if pool, ok := nssession.FromContext(ctx); ok {
+		output, err := pool.Run(ctx, pid, script)
+		if !errors.Is(err, nssession.ErrUnavailable) {
+			return output, err
+		}
+		log.Debugf(ctx, "run the script by nsexec, %v", err)
+	}
+	command, err := netnsCommand(pid, script)
+	if err != nil {
+		return "", err
+	}
+	combined, err := exec.CommandContext(ctx, command.Path, command.Args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s failed, %w: %s", command, err, strings.TrimSpace(string(combined)))
+	}
+	return string(combined), nil
+}
+
+// startInNetns starts the script like runInNetns in a new session, so it outlives the request and the agent, and
+// returns the host pid of the shell
+var startInNetns = func(ctx context.Context, pid int32, script string) (int, error) {
+	command, err := netnsCommand(pid, script)
+	if err != nil {
+		return 0, err
+	}
+	log.Debugf(ctx, "run command, %s", command)
+	cmd := exec.Command(command.Path, command.Args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Env = trace.Environ(ctx)
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	go cmd.Wait()
+	return cmd.Process.Pid, nil
+}
+
+func netnsCommand(pid int32, script string) (nsexec.Command, error) {
+	bin, err := nsexec.Bin()
+	if err != nil {
+		return nsexec.Command{}, err
+	}
+	return nsexec.New(bin, pid).Namespaces(nsexec.Net).Shell(script).Build()
+}
+
+type InterfaceCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewInterfaceCommandSpec() spec.ExpModelCommandSpec {
+	return &InterfaceCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&InterfaceFlapActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:    InterfaceModeFlag,
+								Desc:    "link sets the link down, netem drops all packets of the interface, default is link",
+								Default: netif.ModeLink,
+							},
+							&spec.ExpFlag{
+								Name:    InterfaceUpIntervalFlag,
+								Desc:    "duration the interface is up, such as 500ms or 5s, the integer is seconds, default is 5s",
+								Default: "5",
+							},
+							&spec.ExpFlag{
+								Name:    InterfaceDownIntervalFlag,
+								Desc:    "duration the interface is down, such as 500ms or 2s, the integer is seconds, default is 2s",
+								Default: "2",
+							},
+						},
+						ActionExecutor: &interfaceFlapExecutor{},
+						ActionLongDesc: "The interface of the pod is taken down for the down interval after every up interval, " +
+							"like a flaky nic or a hiccup of the cni. The interface and its routes are restored by the destroy, " +
+							"and by the flapping process itself when it is terminated or the timeout is reached, so it is " +
+							"restored even if the agent crashed.",
+						ActionExample: `# Take eth0 of the pod down 2 seconds of every 7 seconds for 60 seconds
+blade create cri interface flap --up-interval 5 --down-interval 2 --timeout 60 --container-id ee54f1e61c08
+
+# Drop all packets of eth0 instead of setting the link down
+blade create cri interface flap --mode netem --timeout 60 --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+				&InterfaceMTUActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:     InterfaceMTUFlag,
+								Desc:     "mtu of the interface, 68 to 65535, ipv6 is disabled on the interface below 1280",
+								Required: true,
+							},
+						},
+						ActionExecutor: &interfaceMTUExecutor{},
+						ActionLongDesc: "The mtu of the interface of the pod is lowered, the packets larger than it are fragmented " +
+							"or dropped, so the path mtu discovery and the fragmentation of the applications and the meshes " +
+							"are exercised. The original mtu is restored by the destroy.",
+						ActionExample: `# Lower the mtu of eth0 of the pod to 1280 for 60 seconds
+blade create cri interface mtu --mtu 1280 --timeout 60 --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+				&InterfaceMirrorActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:     InterfaceCollectorFlag,
+								Desc:     "ip of the collector receiving the mirrored packets",
+								Required: true,
+							},
+							&spec.ExpFlag{
+								Name:    InterfaceTunnelFlag,
+								Desc:    "tunnel carrying the mirrored packets, gretap or vxlan, default is gretap",
+								Default: netif.TunnelGretap,
+							},
+							&spec.ExpFlag{
+								Name:    InterfaceVNIFlag,
+								Desc:    "vxlan id of the vxlan tunnel, default is 1",
+								Default: "1",
+							},
+							&spec.ExpFlag{
+								Name:    InterfacePortFlag,
+								Desc:    "udp port of the collector of the vxlan tunnel, default is 4789",
+								Default: "4789",
+							},
+						},
+						ActionExecutor: &interfaceMirrorExecutor{},
+						ActionLongDesc: "The egress packets of the interface of the pod are duplicated to the collector by " +
+							"the tc mirred action and a tunnel in the network namespace of the pod, so the network " +
+							"observability pipelines are validated under the chaos. The packets to the collector are not " +
+							"mirrored, the filters and the tunnel are deleted by the destroy.",
+						ActionExample: `# Mirror the egress packets of eth0 of the pod to the collector 10.0.0.9 by gretap for 300 seconds
+blade create cri interface mirror --collector 10.0.0.9 --timeout 300 --container-id ee54f1e61c08
+
+# Mirror by vxlan to the port 4789 of the collector
+blade create cri interface mirror --collector 10.0.0.9 --tunnel vxlan --vni 42 --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:    InterfaceFlag,
+					Desc:    "interface of the pod, default is eth0",
+					Default: "eth0",
+				},
+			},
+		},
+	}
+}
+
+func (*InterfaceCommandModelSpec) Name() string {
+	return "interface"
+}
+
+func (*InterfaceCommandModelSpec) ShortDesc() string {
+	return "Pod network interface experiment"
+}
+
+func (*InterfaceCommandModelSpec) LongDesc() string {
+	return "Pod network interface experiment, the interface is changed in the network namespace of the pod by the " +
+		"ip and the tc of the host, the original state is saved and restored by the destroy."
+}
+
+type InterfaceFlapActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*InterfaceFlapActionCommand) Name() string {
+	return "flap"
+}
+
+func (*InterfaceFlapActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*InterfaceFlapActionCommand) ShortDesc() string {
+	return "interface flap"
+}
+
+func (f *InterfaceFlapActionCommand) LongDesc() string {
+	return f.ActionLongDesc
+}
+
+type InterfaceMTUActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*InterfaceMTUActionCommand) Name() string {
+	return "mtu"
+}
+
+func (*InterfaceMTUActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*InterfaceMTUActionCommand) ShortDesc() string {
+	return "interface mtu reduction"
+}
+
+func (m *InterfaceMTUActionCommand) LongDesc() string {
+	return m.ActionLongDesc
+}
+
+type InterfaceMirrorActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*InterfaceMirrorActionCommand) Name() string {
+	return "mirror"
+}
+
+func (*InterfaceMirrorActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*InterfaceMirrorActionCommand) ShortDesc() string {
+	return "interface traffic mirroring"
+}
+
+func (m *InterfaceMirrorActionCommand) LongDesc() string {
+	return m.ActionLongDesc
+}
+
+type interfaceFlapExecutor struct {
+}
+
+func (e *interfaceFlapExecutor) Name() string {
+	return "interface"
+}
+
+func (e *interfaceFlapExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *interfaceFlapExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return restoreInterface(ctx, uid, model)
+	}
+	flags := model.ActionFlags
+	iface := interfaceName(flags)
+	mode := flags[InterfaceModeFlag]
+	if mode == "" {
+		mode = netif.ModeLink
+	}
+	down, err := netif.DownScript(iface, mode)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, InterfaceModeFlag, mode, err)
+	}
+	up, response := durationFlag(flags, InterfaceUpIntervalFlag, 5*time.Second, 0)
+	if response != nil {
+		return response
+	}
+	downInterval, response := durationFlag(flags, InterfaceDownIntervalFlag, 2*time.Second, time.Second)
+	if response != nil {
+		return response
+	}
+	timeout, response := lifetimeFlag(flags, "timeout")
+	if response != nil {
+		return response
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	// the routes are read before the flapping, the kernel removes them when the link is down
+	output, err := runInNetns(ctx, pid, netif.RoutesScript(iface))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, InterfaceFlag, iface, err)
+	}
+	restore, err := netif.RestoreScript(iface, mode, netif.ParseRoutes(output))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, InterfaceModeFlag, mode, err)
+	}
+	flap := netif.Flap{Down: down, Restore: restore, UpInterval: up, DownInterval: downInterval, Timeout: timeout}
+	helperPid, err := startInNetns(ctx, pid, flap.Script())
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err)
+	}
+	state := netif.State{Uid: uid, Interface: iface, Restore: restore, Pid: helperPid}
+	if err := netif.SaveState(util.GetProgramPath(), state); err != nil {
+		// the flapping process restores the interface when it is terminated
+		syscall.Kill(helperPid, syscall.SIGTERM)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	return spec.ReturnSuccess(state)
+}
+
+type interfaceMTUExecutor struct {
+}
+
+func (e *interfaceMTUExecutor) Name() string {
+	return "interface"
+}
+
+func (e *interfaceMTUExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *interfaceMTUExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return restoreInterface(ctx, uid, model)
+	}
+	flags := model.ActionFlags
+	iface := interfaceName(flags)
+	mtu, response := rangeFlag(flags, InterfaceMTUFlag, 0, 68, 65535)
+	if response != nil {
+		return response
+	}
+	if mtu == 0 {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, InterfaceMTUFlag)
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	output, err := runInNetns(ctx, pid, netif.LinkScript(iface))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, InterfaceFlag, iface, err)
+	}
+	origin, err := netif.ParseMTU(output)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "ip link", err)
+	}
+	if mtu >= origin {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, InterfaceMTUFlag, mtu,
+			fmt.Sprintf("it must be lower than the mtu %d of %s", origin, iface))
+	}
+	// the original mtu is saved before the change, so the destroy restores it even if the change is interrupted
+	state := netif.State{Uid: uid, Interface: iface, Restore: netif.MTUScript(iface, origin)}
+	if err := netif.SaveState(util.GetProgramPath(), state); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	if _, err := runInNetns(ctx, pid, netif.MTUScript(iface, mtu)); err != nil {
+		netif.RemoveState(util.GetProgramPath(), uid)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err)
+	}
+	log.Infof(ctx, "lower the mtu of %s from %d to %d", iface, origin, mtu)
+	return spec.ReturnSuccess(state)
+}
+
+type interfaceMirrorExecutor struct {
+}
+
+func (e *interfaceMirrorExecutor) Name() string {
+	return "interface"
+}
+
+func (e *interfaceMirrorExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *interfaceMirrorExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return restoreInterface(ctx, uid, model)
+	}
+	flags := model.ActionFlags
+	mirror := netif.Mirror{
+		Interface: interfaceName(flags),
+		Device:    netif.MirrorDevice(uid),
+		Tunnel:    flags[InterfaceTunnelFlag],
+		Collector: flags[InterfaceCollectorFlag],
+		Handle:    trace.Handle(uid),
+	}
+	if mirror.Collector == "" {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, InterfaceCollectorFlag)
+	}
+	if net.ParseIP(mirror.Collector) == nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, InterfaceCollectorFlag, mirror.Collector, "it is not an ip")
+	}
+	if mirror.Tunnel == "" {
+		mirror.Tunnel = netif.TunnelGretap
+	}
+	if mirror.Tunnel != netif.TunnelGretap && mirror.Tunnel != netif.TunnelVxlan {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, InterfaceTunnelFlag, mirror.Tunnel,
+			fmt.Sprintf("it must be %s or %s", netif.TunnelGretap, netif.TunnelVxlan))
+	}
+	var response *spec.Response
+	if mirror.VNI, response = rangeFlag(flags, InterfaceVNIFlag, 1, 1, 16777215); response != nil {
+		return response
+	}
+	if mirror.Port, response = rangeFlag(flags, InterfacePortFlag, 4789, 1, 65535); response != nil {
+		return response
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	output, err := runInNetns(ctx, pid, netif.QdiscsScript(mirror.Interface))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, InterfaceFlag, mirror.Interface, err)
+	}
+	addClsact := !netif.HasClsact(output)
+	script, err := mirror.Script(addClsact)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, InterfaceTunnelFlag, mirror.Tunnel, err)
+	}
+	state := netif.State{Uid: uid, Interface: mirror.Interface, Restore: mirror.RestoreScript(addClsact)}
+	steps := rollback.New(ctx, uid)
+	if err := steps.Run("save state", func() error { return netif.SaveState(util.GetProgramPath(), state) },
+		func() error { return netif.RemoveState(util.GetProgramPath(), uid) }); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	// the device, the qdisc and the filter added before the failed command are deleted too
+	steps.Done("mirror", func() error {
+		_, err := runInNetns(ctx, pid, state.Restore)
+		return err
+	})
+	if _, err := runInNetns(ctx, pid, script); err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err))
+	}
+	log.Infof(ctx, "mirror the egress packets of %s to %s by %s", mirror.Interface, mirror.Collector, mirror.Device)
+	return spec.ReturnSuccess(state)
+}
+
+func interfaceName(flags map[string]string) string {
+	if flags[InterfaceFlag] == "" {
+		return "eth0"
+	}
+	return flags[InterfaceFlag]
+}
+
+// interfaceTargetPid returns the pid of the container whose network namespace is changed
+func interfaceTargetPid(ctx context.Context, uid string, model *spec.ExpModel) (int32, *spec.Response) {
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
+		return 0, spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	flags := model.ActionFlags
+	containerInfo, response := GetContainer(ctx, client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name],
+		parseContainerLabelSelector(flags[ContainerLabelSelectorFlag.Name]))
+	if !response.Success {
+		return 0, response
+	}
+	pid, err, code := client.GetPidById(ctx, containerInfo.ContainerId)
+	if err != nil {
+		return 0, spec.ResponseFail(code, err.Error(), nil)
+	}
+	return pid, spec.ReturnSuccess(pid)
+}
+
+// restoreInterface terminates the process changing the interface and executes the saved restore script, it
+// succeeds if the state is not saved or the container is removed with its network namespace
+func restoreInterface(ctx context.Context, uid string, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok && suid != "" {
+		uid = suid
+	}
+	stateDir := util.GetProgramPath()
+	state, err := netif.LoadState(stateDir, uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, stateDir, err)
+	}
+	if state.Pid > 0 {
+		if err := terminateProcess(state.Pid, state.Restore); err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "kill", err)
+		}
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if response.Success {
+		if _, err := runInNetns(ctx, pid, state.Restore); err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err)
+		}
+	} else {
+		log.Warnf(ctx, "the interface %s of the experiment %s is not restored, %s", state.Interface, uid, response.Err)
+	}
+	if err := netif.RemoveState(stateDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove state", err)
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+// terminateProcess terminates the process whose cmdline contains the marker and waits for it exiting, the process
+// already exited or reused by others is skipped
+func terminateProcess(pid int, marker string) error {
+	cmdline, err := getProcessCmdline(pid)
+	if err != nil || !strings.Contains(cmdline, marker) {
+		return nil
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		if err == syscall.ESRCH {
+			return nil
+		}
+		return err
+	}
+	for i := 0; i < 50; i++ {
+		if cmdline, err := getProcessCmdline(pid); err != nil || cmdline == "" {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("process %d is not exited", pid)
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netif"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netstate"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+)
+
+// fakeNetns replaces the commands in the network namespace, the scripts run are recorded
+func fakeNetns(t *testing.T, routes string) *[]string {
+	t.Helper()
+	scripts := make([]string, 0)
+	originRun, originStart := runInNetns, startInNetns
+	runInNetns = func(ctx context.Context, pid int32, script string) (string, error) {
+		scripts = append(scripts, script)
+		return routes, nil
+	}
+	startInNetns = func(ctx context.Context, pid int32, script string) (int, error) {
+		scripts = append(scripts, script)
+		return 0, nil
+	}
+	t.Cleanup(func() { runInNetns, startInNetns = originRun, originStart })
+	originSnapshot, originSnapshots := snapshotNetns, OpenSnapshots
+	snapshotNetns = func(ctx context.Context, pid int32) (netstate.Snapshot, error) {
+		return netstate.Snapshot{}, nil
+	}
+	snapshotsFile := path.Join(t.TempDir(), "snapshots.json")
+	OpenSnapshots = func() *journal.Snapshots { return journal.OpenSnapshots(snapshotsFile) }
+	t.Cleanup(func() { snapshotNetns, OpenSnapshots = originSnapshot, originSnapshots })
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	client.Pids["c1"] = 1234
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	t.Cleanup(func() { NewClientFunc = nil })
+	return &scripts
+}
+
+func TestInterfaceFlap(t *testing.T) {
+	scripts := fakeNetns(t, "default via 10.0.0.1\n10.0.0.0/24 proto kernel scope link src 10.0.0.5\n--\n")
+	flags := map[string]string{ContainerIdFlag.Name: "c1", "timeout": "60"}
+	model := &spec.ExpModel{Target: "interface", ActionName: "flap", ActionFlags: flags}
+	response := (&interfaceFlapExecutor{}).Exec("uid1", context.Background(), model)
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	defer netif.RemoveState(util.GetProgramPath(), "uid1")
+	if len(*scripts) != 2 || (*scripts)[0] != netif.RoutesScript("eth0") {
+		t.Fatalf("expected the routes and the flap scripts, got %q", *scripts)
+	}
+	restore := "ip link set dev eth0 up; ip -4 route replace 10.0.0.0/24 proto kernel scope link src 10.0.0.5 dev eth0 " +
+		"2>/dev/null; ip -4 route replace default via 10.0.0.1 dev eth0 2>/dev/null"
+	for _, expected := range []string{restore, "ip link set dev eth0 down", "sleep 2 & wait $!", "sleep 5 & wait $!", "+ 60))"} {
+		if !strings.Contains((*scripts)[1], expected) {
+			t.Errorf("expected %q in the flap script, got %s", expected, (*scripts)[1])
+		}
+	}
+
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := (&interfaceFlapExecutor{}).Exec("uid1", ctx, model); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if len(*scripts) != 3 || (*scripts)[2] != restore {
+		t.Errorf("expected the restore script, got %q", *scripts)
+	}
+	if _, err := netif.LoadState(util.GetProgramPath(), "uid1"); !os.IsNotExist(err) {
+		t.Errorf("expected the state removed, got %v", err)
+	}
+	// destroyed again without the state
+	if response := (&interfaceFlapExecutor{}).Exec("uid1", ctx, model); !response.Success || len(*scripts) != 3 {
+		t.Errorf("expected nothing restored, got %+v, %q", response, *scripts)
+	}
+}
+
+func TestInterfaceFlapIllegal(t *testing.T) {
+	scripts := fakeNetns(t, "")
+	for _, flags := range []map[string]string{
+		{InterfaceModeFlag: "carrier"},
+		{InterfaceDownIntervalFlag: "0"},
+		{InterfaceUpIntervalFlag: "x"},
+	} {
+		flags[ContainerIdFlag.Name] = "c1"
+		model := &spec.ExpModel{Target: "interface", ActionName: "flap", ActionFlags: flags}
+		response := (&interfaceFlapExecutor{}).Exec("uid1", context.Background(), model)
+		if response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the illegal flags %v, got %+v", flags, response)
+		}
+	}
+	if len(*scripts) != 0 {
+		t.Errorf("expected nothing run, got %q", *scripts)
+	}
+}
+
+func TestInterfaceMTU(t *testing.T) {
+	scripts := fakeNetns(t, "2: eth0@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1450 qdisc noqueue state UP\n")
+	defer netif.RemoveState(util.GetProgramPath(), "uid1")
+	run := func(ctx context.Context, mtu string) *spec.Response {
+		flags := map[string]string{ContainerIdFlag.Name: "c1", InterfaceMTUFlag: mtu}
+		model := &spec.ExpModel{Target: "interface", ActionName: "mtu", ActionFlags: flags}
+		return (&interfaceMTUExecutor{}).Exec("uid1", ctx, model)
+	}
+	for mtu, code := range map[string]int32{"": spec.ParameterLess.Code, "67": spec.ParameterIllegal.Code,
+		"1450": spec.ParameterInvalid.Code} {
+		if response := run(context.Background(), mtu); response.Success || response.Code != code {
+			t.Errorf("expected the code %d of the mtu %q, got %+v", code, mtu, response)
+		}
+	}
+	*scripts = (*scripts)[:0]
+	if response := run(context.Background(), "1280"); !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	expected := []string{netif.LinkScript("eth0"), "ip link set dev eth0 mtu 1280"}
+	if strings.Join(*scripts, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, *scripts)
+	}
+	if response := run(spec.SetDestroyFlag(context.Background(), "uid1"), ""); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if last := (*scripts)[len(*scripts)-1]; last != "ip link set dev eth0 mtu 1450" {
+		t.Errorf("expected the mtu 1450 restored, got %s", last)
+	}
+}
+
+func TestInterfaceMirror(t *testing.T) {
+	scripts := fakeNetns(t, "qdisc noqueue 0: root refcnt 2\n")
+	defer netif.RemoveState(util.GetProgramPath(), "uid1")
+	run := func(ctx context.Context, flags map[string]string) *spec.Response {
+		flags[ContainerIdFlag.Name] = "c1"
+		model := &spec.ExpModel{Target: "interface", ActionName: "mirror", ActionFlags: flags}
+		return (&interfaceMirrorExecutor{}).Exec("uid1", ctx, model)
+	}
+	for _, flags := range []map[string]string{
+		{InterfaceCollectorFlag: "collector"},
+		{InterfaceCollectorFlag: "10.0.0.9", InterfaceTunnelFlag: "ipip"},
+		{InterfaceCollectorFlag: "10.0.0.9", InterfaceVNIFlag: "0"},
+	} {
+		if response := run(context.Background(), flags); response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the illegal flags %v, got %+v", flags, response)
+		}
+	}
+	if len(*scripts) != 0 {
+		t.Fatalf("expected nothing run, got %q", *scripts)
+	}
+	if response := run(context.Background(), map[string]string{InterfaceCollectorFlag: "10.0.0.9"}); !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	mirror := netif.Mirror{Interface: "eth0", Device: netif.MirrorDevice("uid1"), Tunnel: netif.TunnelGretap,
+		Collector: "10.0.0.9", VNI: 1, Port: 4789, Handle: trace.Handle("uid1")}
+	script, _ := mirror.Script(true)
+	if len(*scripts) != 2 || (*scripts)[0] != netif.QdiscsScript("eth0") || (*scripts)[1] != script {
+		t.Errorf("expected the qdiscs and the mirror scripts, got %q", *scripts)
+	}
+	if response := run(spec.SetDestroyFlag(context.Background(), "uid1"), map[string]string{}); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if last := (*scripts)[len(*scripts)-1]; last != mirror.RestoreScript(true) {
+		t.Errorf("expected the clsact removed by the restore, got %s", last)
+	}
+}
@@ -0,0 +1,331 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+)
+
+const DefaultDeploymentsFileName = "chaos_cri_deployments.json"
+
+// Deployment is the toolkit files copied into a container, they are removed when the last experiment using them is
+// destroyed, unless they are retained for debugging
+type Deployment struct {
+	ContainerId string   `json:"containerId"`
+	Paths       []string `json:"paths"`
+	// BladeDir is the directory of the blade in the container, the experiments are destroyed by the blade in it
+	BladeDir string `json:"bladeDir,omitempty"`
+	// Mount is the target in the container of the toolkit mounted instead of copied, it's unmounted first
+	Mount string `json:"mount,omitempty"`
+	// Origin is set if the container is recreated from an image with the toolkit layered in, the origin container is
+	// restored instead of removing the files
+	Origin *Origin `json:"origin,omitempty"`
+	// Uids are the experiments using the files
+	Uids []string `json:"uids"`
+	// Flags are the flags of the experiment deploying the files, the runtime client is created from them
+	Flags      map[string]string `json:"flags,omitempty"`
+	Retain     bool              `json:"retain,omitempty"`
+	DeployTime time.Time         `json:"deployTime"`
+}
+
+// Origin is the container kept stopped while the container recreated from the image runs in place of it
+type Origin struct {
+	ContainerId string `json:"containerId"`
+	Image       string `json:"image"`
+	// Stopped is set if the origin container was not running when recreated, it's kept stopped on the restore. The
+	// origins recorded before it are started as before
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+// Deployments are the deployments persisted to a local json file, every change is loaded from and flushed to the
+// file under the exclusive lock of the file, so the deployments are shared by the agent and the blade commands
+// without losing the experiments attached by each other
+type Deployments struct {
+	file string
+}
+
+// GetDefaultDeploymentsFile returns the deployments file path under the program path
+func GetDefaultDeploymentsFile() string {
+	return path.Join(util.GetProgramPath(), DefaultDeploymentsFileName)
+}
+
+// OpenDeployments returns the deployments of the file, the file is created on the first write if not exists
+func OpenDeployments(file string) *Deployments {
+	if file == "" {
+		file = GetDefaultDeploymentsFile()
+	}
+	return &Deployments{file: file}
+}
+
+// Add records the files deployed into the container for the experiment, the files deployed before are replaced
+func (d *Deployments) Add(deployment Deployment) error {
+	unlock, err := d.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	deployments, err := d.load()
+	if err != nil {
+		return err
+	}
+	if deployment.DeployTime.IsZero() {
+		deployment.DeployTime = time.Now()
+	}
+	deployments[deployment.ContainerId] = &deployment
+	return d.flush(deployments)
+}
+
+// Attach adds the experiment to the deployment of the container, it returns false if the container has no deployment,
+// such as the toolkit is in the image
+func (d *Deployments) Attach(containerId, uid string) (bool, error) {
+	unlock, err := d.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	deployments, err := d.load()
+	if err != nil {
+		return false, err
+	}
+	deployment, ok := deployments[containerId]
+	if !ok {
+		return false, nil
+	}
+	for _, attached := range deployment.Uids {
+		if attached == uid {
+			return true, nil
+		}
+	}
+	deployment.Uids = append(deployment.Uids, uid)
+	return true, d.flush(deployments)
+}
+
+// Release removes the experiment from the deployment of the container, the deployment is removed and returned with
+// true if no experiment uses it anymore
+func (d *Deployments) Release(containerId, uid string) (Deployment, bool, error) {
+	unlock, err := d.lock()
+	if err != nil {
+		return Deployment{}, false, err
+	}
+	defer unlock()
+	deployments, err := d.load()
+	if err != nil {
+		return Deployment{}, false, err
+	}
+	deployment, ok := deployments[containerId]
+	if !ok {
+		return Deployment{}, false, nil
+	}
+	uids := make([]string, 0, len(deployment.Uids))
+	for _, attached := range deployment.Uids {
+		if attached != uid {
+			uids = append(uids, attached)
+		}
+	}
+	deployment.Uids = uids
+	if len(uids) > 0 {
+		return Deployment{}, false, d.flush(deployments)
+	}
+	delete(deployments, containerId)
+	return *deployment, true, d.flush(deployments)
+}
+
+// AddPaths adds the files to the deployment of the container, such as the temporary files of the exec, so the destroy
+// removes them if they are left, it returns false if the container has no deployment. The deployments file is not
+// created for the container without deployment
+func (d *Deployments) AddPaths(containerId string, paths ...string) (bool, error) {
+	if _, ok, err := d.Get(containerId); err != nil || !ok {
+		return false, err
+	}
+	unlock, err := d.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	deployments, err := d.load()
+	if err != nil {
+		return false, err
+	}
+	deployment, ok := deployments[containerId]
+	if !ok {
+		return false, nil
+	}
+	deployment.Paths = append(deployment.Paths, paths...)
+	return true, d.flush(deployments)
+}
+
+// RemovePaths removes the files from the deployment of the container, after they are removed from the container, it
+// succeeds if the container has no deployment
+func (d *Deployments) RemovePaths(containerId string, paths ...string) error {
+	unlock, err := d.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	deployments, err := d.load()
+	if err != nil {
+		return err
+	}
+	deployment, ok := deployments[containerId]
+	if !ok {
+		return nil
+	}
+	removed := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		removed[p] = true
+	}
+	kept := make([]string, 0, len(deployment.Paths))
+	for _, p := range deployment.Paths {
+		if !removed[p] {
+			kept = append(kept, p)
+		}
+	}
+	deployment.Paths = kept
+	return d.flush(deployments)
+}
+
+// Get returns the deployment of the container, false if the container has no deployment
+func (d *Deployments) Get(containerId string) (Deployment, bool, error) {
+	deployments, err := d.load()
+	if err != nil {
+		return Deployment{}, false, err
+	}
+	deployment, ok := deployments[containerId]
+	if !ok {
+		return Deployment{}, false, nil
+	}
+	return *deployment, true, nil
+}
+
+// FindByOrigin returns the deployment of the container recreated in place of the origin container, false if the origin
+// container is not recreated
+func (d *Deployments) FindByOrigin(originId string) (Deployment, bool, error) {
+	deployments, err := d.load()
+	if err != nil {
+		return Deployment{}, false, err
+	}
+	for _, deployment := range deployments {
+		if deployment.Origin != nil && deployment.Origin.ContainerId == originId {
+			return *deployment, true, nil
+		}
+	}
+	return Deployment{}, false, nil
+}
+
+// Remove removes the deployment of the container, it succeeds if the container has no deployment
+func (d *Deployments) Remove(containerId string) error {
+	unlock, err := d.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	deployments, err := d.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := deployments[containerId]; !ok {
+		return nil
+	}
+	delete(deployments, containerId)
+	return d.flush(deployments)
+}
+
+// List returns the deployments ordered by the deploy time
+func (d *Deployments) List() ([]Deployment, error) {
+	deployments, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Deployment, 0, len(deployments))
+	for _, deployment := range deployments {
+		list = append(list, *deployment)
+	}
+	sort.Slice(list, func(i, k int) bool {
+		return list[i].DeployTime.Before(list[k].DeployTime)
+	})
+	return list, nil
+}
+
+// lock takes the exclusive flock of the lock file beside the deployments file until the returned function is called,
+// the deployments file itself is replaced by the rename of the flush, so it can't carry the lock
+func (d *Deployments) lock() (func(), error) {
+	if err := os.MkdirAll(path.Dir(d.file), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(d.file+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("lock the deployments file %s failed, %v", d.file, err)
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+func (d *Deployments) load() (map[string]*Deployment, error) {
+	deployments := make(map[string]*Deployment)
+	bytes, err := os.ReadFile(d.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return deployments, nil
+		}
+		return nil, err
+	}
+	if len(bytes) == 0 {
+		return deployments, nil
+	}
+	list := make([]*Deployment, 0)
+	if err := json.Unmarshal(bytes, &list); err != nil {
+		return nil, fmt.Errorf("deployments file %s is corrupted, %v", d.file, err)
+	}
+	for _, deployment := range list {
+		deployments[deployment.ContainerId] = deployment
+	}
+	return deployments, nil
+}
+
+// flush writes the deployments to a temporary file and renames it like the journal
+func (d *Deployments) flush(deployments map[string]*Deployment) error {
+	list := make([]*Deployment, 0, len(deployments))
+	for _, deployment := range deployments {
+		list = append(list, deployment)
+	}
+	bytes, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(d.file), 0755); err != nil {
+		return err
+	}
+	tmpFile := d.file + ".tmp"
+	if err := os.WriteFile(tmpFile, bytes, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, d.file)
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package journal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestDeploymentsAttachRelease(t *testing.T) {
+	d := OpenDeployments(filepath.Join(t.TempDir(), DefaultDeploymentsFileName))
+	if err := d.Add(Deployment{ContainerId: "c1", Paths: []string{"/opt/chaosblade"}, Uids: []string{"u1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := d.Attach("c1", "u2"); err != nil || !ok {
+		t.Fatalf("attach failed, %t, %v", ok, err)
+	}
+	if ok, err := d.Attach("c2", "u2"); err != nil || ok {
+		t.Fatalf("attach to the container without deployment, %t, %v", ok, err)
+	}
+	if _, last, err := d.Release("c1", "u1"); err != nil || last {
+		t.Fatalf("the deployment is used by u2, %t, %v", last, err)
+	}
+	deployment, last, err := d.Release("c1", "u2")
+	if err != nil || !last || deployment.Paths[0] != "/opt/chaosblade" {
+		t.Fatalf("unexpected release %+v, %t, %v", deployment, last, err)
+	}
+	if _, ok, err := d.Get("c1"); err != nil || ok {
+		t.Fatalf("the deployment is not removed, %t, %v", ok, err)
+	}
+}
+
+func TestDeploymentsPaths(t *testing.T) {
+	file := filepath.Join(t.TempDir(), DefaultDeploymentsFileName)
+	d := OpenDeployments(file)
+	if ok, err := d.AddPaths("c1", "/tmp/capture.out"); err != nil || ok {
+		t.Fatalf("expected no deployment of c1, %t, %v", ok, err)
+	}
+	if _, err := os.Stat(file + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("expected no deployments file created, %v", err)
+	}
+	if err := d.Add(Deployment{ContainerId: "c1", Paths: []string{"/opt/chaosblade"}, Uids: []string{"u1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := d.AddPaths("c1", "/tmp/capture.out", "/tmp/capture.err"); err != nil || !ok {
+		t.Fatalf("add paths failed, %t, %v", ok, err)
+	}
+	if deployment, _, _ := d.Get("c1"); len(deployment.Paths) != 3 {
+		t.Errorf("expected the paths added, got %v", deployment.Paths)
+	}
+	if err := d.RemovePaths("c1", "/tmp/capture.out", "/tmp/capture.err"); err != nil {
+		t.Fatal(err)
+	}
+	if deployment, _, _ := d.Get("c1"); len(deployment.Paths) != 1 || deployment.Paths[0] != "/opt/chaosblade" {
+		t.Errorf("expected the deployed files kept only, got %v", deployment.Paths)
+	}
+	if err := d.RemovePaths("c2", "/tmp/capture.out"); err != nil {
+		t.Errorf("expected the container without deployment ignored, %v", err)
+	}
+}
+
+// TestDeploymentsConcurrentAttach attaches by the deployments of their own like the blade commands of the processes,
+// none of the experiments is lost
+func TestDeploymentsConcurrentAttach(t *testing.T) {
+	file := filepath.Join(t.TempDir(), DefaultDeploymentsFileName)
+	if err := OpenDeployments(file).Add(Deployment{ContainerId: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := OpenDeployments(file).Attach("c1", fmt.Sprintf("u%d", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	deployment, _, err := OpenDeployments(file).Get("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deployment.Uids) != 20 {
+		t.Fatalf("expect 20 experiments attached, got %v", deployment.Uids)
+	}
+}
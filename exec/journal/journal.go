@@ -0,0 +1,239 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/node"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/probe"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/scenario"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+// The experiment status, the same as the chaosblade cli
+const (
+	StatusCreated   = "Created"
+	StatusSuccess   = "Success"
+	StatusError     = "Error"
+	StatusDestroyed = "Destroyed"
+	// StatusDestroyFailed means the experiment is still injected and needs to be cleaned up
+	StatusDestroyFailed = "DestroyFailed"
+)
+
+const DefaultJournalFileName = "chaos_cri_journal.json"
+
+// Record is the journal entry of one experiment
+type Record struct {
+	Uid      string            `json:"uid"`
+	Target   string            `json:"target"`
+	Action   string            `json:"action"`
+	Flags    map[string]string `json:"flags,omitempty"`
+	Webhooks []string          `json:"webhooks,omitempty"`
+	// ScheduleId and Run are set if the experiment is a run of a schedule
+	ScheduleId string `json:"scheduleId,omitempty"`
+	Run        int    `json:"run,omitempty"`
+	// Faults are the faults composed by the scenario experiment, its target and action are scenario and run
+	Faults []scenario.Fault `json:"faults,omitempty"`
+	// Flap is the duty cycle of the flapping experiment, the fault is reverted and injected again by the agent
+	Flap *Flap `json:"flap,omitempty"`
+	// Profile is the name of the profile the experiment is created by, ExpireTime is when the agent destroys it by
+	// the ttl of the profile, the agent taking over the journal destroys it at the same time
+	Profile    string     `json:"profile,omitempty"`
+	ExpireTime *time.Time `json:"expireTime,omitempty"`
+	// Probes are the steady state checks, ProbeReport is their results before the injection and after the revert
+	Probes      []probe.Probe `json:"probes,omitempty"`
+	ProbeReport *probe.Report `json:"probeReport,omitempty"`
+	// NodeInfo is the node and runtime context collected when the experiment is created
+	NodeInfo *node.Info `json:"nodeInfo,omitempty"`
+	// Warnings are the non-fatal anomalies of the create and the destroy
+	Warnings   []warning.Warning `json:"warnings,omitempty"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	CreateTime time.Time         `json:"createTime"`
+	UpdateTime time.Time         `json:"updateTime"`
+}
+
+// Flap is the duty cycle of a flapping experiment, such as 5s on and 25s off for 10m, the durations are parsed by
+// time.ParseDuration, the experiment flaps until it's destroyed if the duration is empty
+type Flap struct {
+	On       string `json:"on"`
+	Off      string `json:"off"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// Journal is the experiment journal persisted to a local json file
+type Journal struct {
+	file    string
+	mu      sync.RWMutex
+	records map[string]*Record
+}
+
+// GetDefaultJournalFile returns the journal file path under the program path
+func GetDefaultJournalFile() string {
+	return path.Join(util.GetProgramPath(), DefaultJournalFileName)
+}
+
+// Open loads the journal from the file, the file is created on the first write if not exists
+func Open(file string) (*Journal, error) {
+	if file == "" {
+		file = GetDefaultJournalFile()
+	}
+	j := &Journal{
+		file:    file,
+		records: make(map[string]*Record),
+	}
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, nil
+		}
+		return nil, err
+	}
+	if len(bytes) == 0 {
+		return j, nil
+	}
+	records := make([]*Record, 0)
+	if err := json.Unmarshal(bytes, &records); err != nil {
+		return nil, fmt.Errorf("journal file %s is corrupted, %v", file, err)
+	}
+	for _, record := range records {
+		j.records[record.Uid] = record
+	}
+	return j, nil
+}
+
+// File returns the journal file path
+func (j *Journal) File() string {
+	return j.file
+}
+
+// Put adds or updates the record and flushes the journal
+func (j *Journal) Put(record Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	if old, ok := j.records[record.Uid]; ok {
+		record.CreateTime = old.CreateTime
+	} else if record.CreateTime.IsZero() {
+		record.CreateTime = now
+	}
+	record.UpdateTime = now
+	j.records[record.Uid] = &record
+	return j.flush()
+}
+
+// UpdateStatus changes the status of the record and flushes the journal
+func (j *Journal) UpdateStatus(uid, status, errMsg string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	record, ok := j.records[uid]
+	if !ok {
+		return fmt.Errorf("experiment %s not found in journal", uid)
+	}
+	record.Status = status
+	record.Error = errMsg
+	record.UpdateTime = time.Now()
+	return j.flush()
+}
+
+// SetProbeReport changes the probe report of the record and flushes the journal
+func (j *Journal) SetProbeReport(uid string, report *probe.Report) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	record, ok := j.records[uid]
+	if !ok {
+		return fmt.Errorf("experiment %s not found in journal", uid)
+	}
+	record.ProbeReport = report
+	record.UpdateTime = time.Now()
+	return j.flush()
+}
+
+// AddWarnings appends the warnings to the record and flushes the journal
+func (j *Journal) AddWarnings(uid string, warnings []warning.Warning) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	record, ok := j.records[uid]
+	if !ok {
+		return fmt.Errorf("experiment %s not found in journal", uid)
+	}
+	record.Warnings = append(record.Warnings, warnings...)
+	record.UpdateTime = time.Now()
+	return j.flush()
+}
+
+// Get returns a copy of the record
+func (j *Journal) Get(uid string) (Record, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	record, ok := j.records[uid]
+	if !ok {
+		return Record{}, false
+	}
+	return *record, true
+}
+
+// List returns the records ordered by the create time
+func (j *Journal) List() []Record {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	records := make([]Record, 0, len(j.records))
+	for _, record := range j.records {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, k int) bool {
+		return records[i].CreateTime.Before(records[k].CreateTime)
+	})
+	return records
+}
+
+// Verify reloads the journal file and checks it can be parsed
+func (j *Journal) Verify() error {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	_, err := Open(j.file)
+	return err
+}
+
+// flush writes the journal to a temporary file and renames it, so the journal file is never half written
+func (j *Journal) flush() error {
+	records := make([]*Record, 0, len(j.records))
+	for _, record := range j.records {
+		records = append(records, record)
+	}
+	bytes, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(j.file), 0755); err != nil {
+		return err
+	}
+	tmpFile := j.file + ".tmp"
+	if err := os.WriteFile(tmpFile, bytes, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, j.file)
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package journal
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/probe"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+func TestJournalStatus(t *testing.T) {
+	file := path.Join(t.TempDir(), "journal", "journal.json")
+	j, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(j.List()) != 0 {
+		t.Fatalf("expected the journal empty, got %+v", j.List())
+	}
+	record := Record{Uid: "uid1", Target: "cpu", Action: "fullload", Status: StatusCreated}
+	if err := j.Put(record); err != nil {
+		t.Fatal(err)
+	}
+	created, _ := j.Get("uid1")
+	if created.CreateTime.IsZero() || created.UpdateTime.IsZero() {
+		t.Fatalf("expected the times set, got %+v", created)
+	}
+	// the status transitions of the experiment, the create time is kept
+	for _, status := range []string{StatusSuccess, StatusDestroyFailed, StatusDestroyed} {
+		time.Sleep(time.Millisecond)
+		errMsg := ""
+		if status == StatusDestroyFailed {
+			errMsg = "destroy failed"
+		}
+		if err := j.UpdateStatus("uid1", status, errMsg); err != nil {
+			t.Fatal(err)
+		}
+		updated, _ := j.Get("uid1")
+		if updated.Status != status || updated.Error != errMsg || !updated.CreateTime.Equal(created.CreateTime) ||
+			!updated.UpdateTime.After(created.UpdateTime) {
+			t.Errorf("unexpected record %+v of the status %s", updated, status)
+		}
+	}
+	if err := j.UpdateStatus("unknown", StatusSuccess, ""); err == nil {
+		t.Error("expected the unknown experiment refused")
+	}
+	// the record put again keeps the create time
+	record.Status = StatusSuccess
+	if err := j.Put(record); err != nil {
+		t.Fatal(err)
+	}
+	if updated, _ := j.Get("uid1"); !updated.CreateTime.Equal(created.CreateTime) {
+		t.Errorf("expected the create time kept, got %s", updated.CreateTime)
+	}
+	if err := j.AddWarnings("uid1", []warning.Warning{{Source: "deploy", Message: "stale blade"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.SetProbeReport("uid1", &probe.Report{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.AddWarnings("unknown", nil); err == nil {
+		t.Error("expected the warnings of the unknown experiment refused")
+	}
+
+	// the journal is reloaded by the next agent
+	reopened, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded, ok := reopened.Get("uid1"); !ok || loaded.Status != StatusSuccess || len(loaded.Warnings) != 1 ||
+		loaded.ProbeReport == nil {
+		t.Errorf("unexpected reloaded record %+v", loaded)
+	}
+}
+
+func TestJournalList(t *testing.T) {
+	j, err := Open(path.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now()
+	for i, uid := range []string{"uid3", "uid1", "uid2"} {
+		if err := j.Put(Record{Uid: uid, CreateTime: base.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := j.List()
+	if len(records) != 3 || records[0].Uid != "uid3" || records[1].Uid != "uid1" || records[2].Uid != "uid2" {
+		t.Errorf("expected the records ordered by the create time, got %+v", records)
+	}
+	// the copies don't change the journal
+	records[0].Status = StatusError
+	if record, _ := j.Get("uid3"); record.Status == StatusError {
+		t.Error("expected the journal unchanged by the copy")
+	}
+}
+
+func TestJournalAtomic(t *testing.T) {
+	dir := t.TempDir()
+	file := path.Join(dir, "journal.json")
+	j, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Put(Record{Uid: "uid1", Status: StatusSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	// no temporary file is left by the flush
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("expected the journal file only, got %v, %v", entries, err)
+	}
+	if err := j.Verify(); err != nil {
+		t.Errorf("expected the journal verified, got %v", err)
+	}
+	// the temporary file left by a crash during the flush is replaced by the next flush, the journal is intact
+	if err := os.WriteFile(file+".tmp", []byte(`[{"uid":`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if reopened, err := Open(file); err != nil || len(reopened.List()) != 1 {
+		t.Errorf("expected the journal intact, got %v", err)
+	}
+	if err := j.Put(Record{Uid: "uid2", Status: StatusSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	if reopened, err := Open(file); err != nil || len(reopened.List()) != 2 {
+		t.Errorf("expected the journal flushed, got %v", err)
+	}
+
+	// the empty file is an empty journal, the corrupted one is refused
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if reopened, err := Open(file); err != nil || len(reopened.List()) != 0 {
+		t.Errorf("expected the empty journal, got %v", err)
+	}
+	if err := os.WriteFile(file, []byte(`[{"uid":`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(file); err == nil {
+		t.Error("expected the corrupted journal refused")
+	}
+	if err := j.Verify(); err == nil {
+		t.Error("expected the corrupted journal reported by the verify")
+	}
+}
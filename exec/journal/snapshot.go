@@ -0,0 +1,197 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netstate"
+)
+
+const DefaultSnapshotsFileName = "chaos_cri_snapshots.json"
+
+// NetworkSnapshot is the network state of the container before the experiment changes it. It's removed if the state
+// after the destroy matches it, otherwise the residue is kept for the operators
+type NetworkSnapshot struct {
+	Uid          string             `json:"uid"`
+	ContainerId  string             `json:"containerId"`
+	Before       netstate.Snapshot  `json:"before"`
+	Residue      []netstate.Residue `json:"residue,omitempty"`
+	SnapshotTime time.Time          `json:"snapshotTime"`
+	VerifyTime   time.Time          `json:"verifyTime,omitempty"`
+}
+
+// Snapshots are the network snapshots persisted to a local json file like the deployments
+type Snapshots struct {
+	file string
+}
+
+// GetDefaultSnapshotsFile returns the snapshots file path under the program path
+func GetDefaultSnapshotsFile() string {
+	return path.Join(util.GetProgramPath(), DefaultSnapshotsFileName)
+}
+
+// OpenSnapshots returns the snapshots of the file, the file is created on the first write if not exists
+func OpenSnapshots(file string) *Snapshots {
+	if file == "" {
+		file = GetDefaultSnapshotsFile()
+	}
+	return &Snapshots{file: file}
+}
+
+// Add records the snapshot of the experiment, the snapshot taken before is kept, so the state changed by the
+// experiment itself is never recorded
+func (s *Snapshots) Add(snapshot NetworkSnapshot) (bool, error) {
+	snapshots, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := snapshots[snapshot.Uid]; ok {
+		return false, nil
+	}
+	if snapshot.SnapshotTime.IsZero() {
+		snapshot.SnapshotTime = time.Now()
+	}
+	snapshots[snapshot.Uid] = &snapshot
+	return true, s.flush(snapshots)
+}
+
+// Get returns the snapshot of the experiment, false if not recorded
+func (s *Snapshots) Get(uid string) (NetworkSnapshot, bool, error) {
+	snapshots, err := s.load()
+	if err != nil {
+		return NetworkSnapshot{}, false, err
+	}
+	snapshot, ok := snapshots[uid]
+	if !ok {
+		return NetworkSnapshot{}, false, nil
+	}
+	return *snapshot, true, nil
+}
+
+// Verify records the residue of the experiment found after the destroy, the snapshot is removed without residue
+func (s *Snapshots) Verify(uid string, residue []netstate.Residue) error {
+	snapshots, err := s.load()
+	if err != nil {
+		return err
+	}
+	snapshot, ok := snapshots[uid]
+	if !ok {
+		return nil
+	}
+	if len(residue) == 0 {
+		delete(snapshots, uid)
+	} else {
+		snapshot.Residue = residue
+		snapshot.VerifyTime = time.Now()
+	}
+	return s.flush(snapshots)
+}
+
+// Release detaches the experiment from the network state of the container. If other experiments of the container are
+// still running, the state is not restored yet, the earliest snapshot is handed over to them and false is returned.
+// Otherwise the snapshot is returned with true to be verified
+func (s *Snapshots) Release(uid string) (NetworkSnapshot, bool, error) {
+	snapshots, err := s.load()
+	if err != nil {
+		return NetworkSnapshot{}, false, err
+	}
+	snapshot, ok := snapshots[uid]
+	if !ok {
+		return NetworkSnapshot{}, false, nil
+	}
+	running := false
+	for _, other := range snapshots {
+		if other.Uid == uid || other.ContainerId != snapshot.ContainerId || !other.VerifyTime.IsZero() {
+			continue
+		}
+		running = true
+		if snapshot.SnapshotTime.Before(other.SnapshotTime) {
+			other.Before, other.SnapshotTime = snapshot.Before, snapshot.SnapshotTime
+		}
+	}
+	if !running {
+		return *snapshot, true, nil
+	}
+	delete(snapshots, uid)
+	return NetworkSnapshot{}, false, s.flush(snapshots)
+}
+
+// List returns the snapshots ordered by the snapshot time
+func (s *Snapshots) List() ([]NetworkSnapshot, error) {
+	snapshots, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]NetworkSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		list = append(list, *snapshot)
+	}
+	sort.Slice(list, func(i, k int) bool {
+		return list[i].SnapshotTime.Before(list[k].SnapshotTime)
+	})
+	return list, nil
+}
+
+func (s *Snapshots) load() (map[string]*NetworkSnapshot, error) {
+	snapshots := make(map[string]*NetworkSnapshot)
+	bytes, err := os.ReadFile(s.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return snapshots, nil
+		}
+		return nil, err
+	}
+	if len(bytes) == 0 {
+		return snapshots, nil
+	}
+	list := make([]*NetworkSnapshot, 0)
+	if err := json.Unmarshal(bytes, &list); err != nil {
+		return nil, fmt.Errorf("snapshots file %s is corrupted, %v", s.file, err)
+	}
+	for _, snapshot := range list {
+		snapshots[snapshot.Uid] = snapshot
+	}
+	return snapshots, nil
+}
+
+// flush writes the snapshots to a temporary file and renames it like the journal
+func (s *Snapshots) flush(snapshots map[string]*NetworkSnapshot) error {
+	list := make([]*NetworkSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		list = append(list, snapshot)
+	}
+	bytes, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(s.file), 0755); err != nil {
+		return err
+	}
+	tmpFile := s.file + ".tmp"
+	if err := os.WriteFile(tmpFile, bytes, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, s.file)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package killswitch is the node-local switch stopping the fault injection during an incident. The switch is engaged
+// by the presence of the file, such as `touch /var/run/chaosblade/kill-switch`, or the api of the agent. While it is
+// engaged the experiments are refused, and the agent reverts the running experiments as soon as it is engaged.
+package killswitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// Env overrides the DefaultFile of the switch
+const Env = "CHAOSBLADE_CRI_KILL_SWITCH"
+
+// DefaultFile is the file engaging the switch, the content is the reason
+const DefaultFile = "/var/run/chaosblade/kill-switch"
+
+// File returns the file of the switch
+func File() string {
+	if file := os.Getenv(Env); file != "" {
+		return file
+	}
+	return DefaultFile
+}
+
+// State is the state of the switch
+type State struct {
+	Engaged bool      `json:"engaged"`
+	Reason  string    `json:"reason,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+}
+
+// Check returns the state of the switch, it is engaged if the file exists, even if the file can not be read
+func Check() State {
+	file := File()
+	info, err := os.Stat(file)
+	if err != nil {
+		return State{}
+	}
+	state := State{Engaged: true, Since: info.ModTime()}
+	if content, err := os.ReadFile(file); err == nil {
+		state.Reason = strings.TrimSpace(string(content))
+	}
+	return state
+}
+
+// Engage writes the file of the switch with the reason
+func Engage(reason string) error {
+	file := File()
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, []byte(reason+"\n"), 0644)
+}
+
+// Release removes the file of the switch, it succeeds if the switch is not engaged
+func Release() error {
+	if err := os.Remove(File()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Refusal returns the failed response of the experiments refused by the switch
+func Refusal(state State) *spec.Response {
+	message := fmt.Sprintf("the kill switch %s is engaged since %s", File(), state.Since.Format(time.RFC3339))
+	if state.Reason != "" {
+		message += ", " + state.Reason
+	}
+	return spec.ReturnFail(spec.Forbidden, message)
+}
+
+// Executor refuses to create the experiments while the switch is engaged, the destroy is always passed through
+type Executor struct {
+	spec.Executor
+}
+
+// Wrap returns the executor checking the switch
+func Wrap(executor spec.Executor) spec.Executor {
+	if _, ok := executor.(*Executor); ok {
+		return executor
+	}
+	return &Executor{Executor: executor}
+}
+
+func (e *Executor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, isDestroy := spec.IsDestroy(ctx); isDestroy {
+		return e.Executor.Exec(uid, ctx, model)
+	}
+	if state := Check(); state.Engaged {
+		return Refusal(state)
+	}
+	return e.Executor.Exec(uid, ctx, model)
+}
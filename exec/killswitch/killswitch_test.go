@@ -0,0 +1,68 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package killswitch
+
+import (
+	"context"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+type countExecutor struct {
+	spec.Executor
+	calls int
+}
+
+func (e *countExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	e.calls++
+	return spec.ReturnSuccess(uid)
+}
+
+func TestKillSwitch(t *testing.T) {
+	t.Setenv(Env, path.Join(t.TempDir(), "chaosblade", "kill-switch"))
+	counter := &countExecutor{}
+	executor := Wrap(counter)
+	if state := Check(); state.Engaged {
+		t.Fatalf("expected the switch released, got %+v", state)
+	}
+	if response := executor.Exec("uid1", context.Background(), &spec.ExpModel{}); !response.Success {
+		t.Fatalf("expected the experiment created, got %+v", response)
+	}
+	if err := Engage("incident 42"); err != nil {
+		t.Fatal(err)
+	}
+	if state := Check(); !state.Engaged || state.Reason != "incident 42" {
+		t.Errorf("expected the switch engaged, got %+v", state)
+	}
+	response := executor.Exec("uid2", context.Background(), &spec.ExpModel{})
+	if response.Success || response.Code != spec.Forbidden.Code || !strings.Contains(response.Err, "incident 42") {
+		t.Errorf("expected the experiment refused, got %+v", response)
+	}
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := executor.Exec("uid1", ctx, &spec.ExpModel{}); !response.Success || counter.calls != 2 {
+		t.Errorf("expected the destroy passed through, got %+v", response)
+	}
+	if err := Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Release(); err != nil || Check().Engaged {
+		t.Errorf("expected the switch released, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package loadgen builds the command lines of the load generators from one parameterization of the load, so the
+// stress experiments are the same whatever the runtime and the generator. stress-ng is the generator registered by
+// default, the others are added by Register.
+package loadgen
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+)
+
+// The stressors of the load
+const (
+	StressorCPU    = "cpu"
+	StressorVM     = "vm"
+	StressorIO     = "io"
+	StressorSocket = "sock"
+)
+
+// Stressors are the stressors every generator supports
+var Stressors = []string{StressorCPU, StressorVM, StressorIO, StressorSocket}
+
+// Load is the load generated, the zero fields are the defaults of the generator
+type Load struct {
+	Stressor string `json:"stressor"`
+	// Workers are the processes generating the load, one per cpu if 0
+	Workers int `json:"workers"`
+	// Percent is the load of every cpu worker, 1 to 100
+	Percent int `json:"percent,omitempty"`
+	// Bytes are the memory of every vm worker, or the file of every io worker, such as 256m or 80%
+	Bytes string `json:"bytes,omitempty"`
+	// TempPath is the directory the io workers write the files in
+	TempPath string `json:"tempPath,omitempty"`
+	// Timeout stops the generator, 0 runs until it is killed
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+var bytesPattern = regexp.MustCompile(`^\d+[bkmgBKMG%]?$`)
+
+// Validate returns the error if the load is illegal
+func (l Load) Validate() error {
+	supported := false
+	for _, stressor := range Stressors {
+		supported = supported || stressor == l.Stressor
+	}
+	if !supported {
+		return fmt.Errorf("unsupported stressor %s", l.Stressor)
+	}
+	if l.Workers < 0 {
+		return fmt.Errorf("illegal workers %d", l.Workers)
+	}
+	if l.Percent < 0 || l.Percent > 100 {
+		return fmt.Errorf("illegal percent %d, it must be from 1 to 100", l.Percent)
+	}
+	if l.Bytes != "" && !bytesPattern.MatchString(l.Bytes) {
+		return fmt.Errorf("illegal bytes %s, such as 256m or 80%%", l.Bytes)
+	}
+	return nil
+}
+
+// Generator builds the command line generating the load
+type Generator interface {
+	// Name is the name the generator is selected by
+	Name() string
+	// Argv returns the command line generating the load, the first is the binary
+	Argv(load Load) ([]string, error)
+}
+
+// StressNgBin is the binary of stress-ng
+const StressNgBin = "stress-ng"
+
+// StressNg generates the load by stress-ng, the bin is found by LookPath if empty
+type StressNg struct {
+	Bin string
+}
+
+func (s StressNg) Name() string {
+	return StressNgBin
+}
+
+// stressNgStressors are the stress-ng stressors of the loads, the io load writes files by the hdd stressor
+var stressNgStressors = map[string]string{
+	StressorCPU:    "cpu",
+	StressorVM:     "vm",
+	StressorIO:     "hdd",
+	StressorSocket: "sock",
+}
+
+func (s StressNg) Argv(load Load) ([]string, error) {
+	if err := load.Validate(); err != nil {
+		return nil, err
+	}
+	bin := s.Bin
+	if bin == "" {
+		var err error
+		if bin, err = exec.LookPath(StressNgBin); err != nil {
+			return nil, fmt.Errorf("%s not found, %v", StressNgBin, err)
+		}
+	}
+	stressor := stressNgStressors[load.Stressor]
+	argv := []string{bin, "--" + stressor, strconv.Itoa(load.Workers)}
+	switch load.Stressor {
+	case StressorCPU:
+		if load.Percent > 0 {
+			argv = append(argv, "--cpu-load", strconv.Itoa(load.Percent))
+		}
+	case StressorVM, StressorIO:
+		if load.Bytes != "" {
+			argv = append(argv, "--"+stressor+"-bytes", load.Bytes)
+		}
+	}
+	if load.TempPath != "" {
+		argv = append(argv, "--temp-path", load.TempPath)
+	}
+	if load.Timeout > 0 {
+		argv = append(argv, "--timeout", fmt.Sprintf("%ds", durations.Seconds(load.Timeout)))
+	}
+	return argv, nil
+}
+
+var (
+	mu         sync.RWMutex
+	generators = map[string]Generator{StressNgBin: StressNg{}}
+)
+
+// Register adds the generator, the one of the same name is replaced
+func Register(generator Generator) {
+	mu.Lock()
+	defer mu.Unlock()
+	generators[generator.Name()] = generator
+}
+
+// Get returns the generator of the name
+func Get(name string) (Generator, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	generator, ok := generators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown load generator %s, registered %v", name, names())
+	}
+	return generator, nil
+}
+
+func names() []string {
+	list := make([]string, 0, len(generators))
+	for name := range generators {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
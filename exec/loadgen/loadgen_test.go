@@ -0,0 +1,88 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package loadgen
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStressNgArgv(t *testing.T) {
+	generator := StressNg{Bin: "/opt/stress-ng"}
+	for _, c := range []struct {
+		load     Load
+		expected []string
+	}{
+		{Load{Stressor: StressorCPU, Workers: 2, Percent: 80, Timeout: 90 * time.Second},
+			[]string{"/opt/stress-ng", "--cpu", "2", "--cpu-load", "80", "--timeout", "90s"}},
+		{Load{Stressor: StressorVM, Workers: 1, Bytes: "256m"},
+			[]string{"/opt/stress-ng", "--vm", "1", "--vm-bytes", "256m"}},
+		{Load{Stressor: StressorIO, Bytes: "1g", TempPath: "/proc/1/root/tmp"},
+			[]string{"/opt/stress-ng", "--hdd", "0", "--hdd-bytes", "1g", "--temp-path", "/proc/1/root/tmp"}},
+		{Load{Stressor: StressorSocket, Workers: 4},
+			[]string{"/opt/stress-ng", "--sock", "4"}},
+	} {
+		argv, err := generator.Argv(c.load)
+		if err != nil {
+			t.Fatalf("argv of %+v failed, %v", c.load, err)
+		}
+		if !reflect.DeepEqual(argv, c.expected) {
+			t.Errorf("expected %q of %+v, got %q", c.expected, c.load, argv)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, load := range []Load{
+		{Stressor: "fork"},
+		{Stressor: StressorCPU, Workers: -1},
+		{Stressor: StressorCPU, Percent: 101},
+		{Stressor: StressorVM, Bytes: "1x"},
+	} {
+		if err := load.Validate(); err == nil {
+			t.Errorf("expected %+v illegal", load)
+		}
+	}
+	if err := (Load{Stressor: StressorVM, Bytes: "80%"}).Validate(); err != nil {
+		t.Errorf("expected the percent of the available legal, %v", err)
+	}
+}
+
+type fakeGenerator struct{}
+
+func (fakeGenerator) Name() string {
+	return "fake"
+}
+
+func (fakeGenerator) Argv(load Load) ([]string, error) {
+	return []string{"fake", load.Stressor}, nil
+}
+
+func TestRegister(t *testing.T) {
+	if _, err := Get("fake"); err == nil {
+		t.Fatal("expected the unknown generator failed")
+	}
+	Register(fakeGenerator{})
+	generator, err := Get("fake")
+	if err != nil || generator.Name() != "fake" {
+		t.Fatalf("expected the generator registered, got %v, %v", generator, err)
+	}
+	if _, err := Get(StressNgBin); err != nil {
+		t.Errorf("expected stress-ng registered by default, %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/flood"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+)
+
+// The flags of the log experiment
+const (
+	LogStreamFlag   = "stream"
+	LogRateFlag     = "rate"
+	LogSizeFlag     = "size"
+	LogDurationFlag = "duration"
+)
+
+// startOnHost starts the script by the shell of the host in a new session, so it outlives the request and the agent,
+// and returns the pid of the shell
+var startOnHost = func(ctx context.Context, script string) (int, error) {
+	log.Debugf(ctx, "run script on host, %s", script)
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Env = trace.Environ(ctx)
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	go cmd.Wait()
+	return cmd.Process.Pid, nil
+}
+
+type LogCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewLogCommandSpec() spec.ExpModelCommandSpec {
+	return &LogCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&LogFloodActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:    LogStreamFlag,
+								Desc:    "stream of the container written, stdout or stderr, default is stdout",
+								Default: flood.StreamStdout,
+							},
+							&spec.ExpFlag{
+								Name:    LogRateFlag,
+								Desc:    "lines written every second, 1 to 1000000, default is 1000",
+								Default: "1000",
+							},
+							&spec.ExpFlag{
+								Name:    LogSizeFlag,
+								Desc:    fmt.Sprintf("bytes of every line, %d to 65536, default is 256", flood.MinSize),
+								Default: "256",
+							},
+							&spec.ExpFlag{
+								Name: LogDurationFlag,
+								Desc: "duration of the flood, such as 90s or 1h, the integer is seconds, default or forever is until the experiment is destroyed",
+							},
+						},
+						ActionExecutor: &logFloodExecutor{},
+						ActionLongDesc: "A helper on the host writes the lines to the stdout or stderr of the container " +
+							"at the rate, the lines are collected by the runtime like the output of the application, so " +
+							"the log rotation, the disk of the node and the logging pipeline are stressed. The lines " +
+							"start with chaosblade-flood and the uid of the experiment.",
+						ActionExample: `# Write 5000 lines of 1KB every second to the stdout of the container for 300 seconds
+blade create cri log flood --rate 5000 --size 1024 --duration 300 --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*LogCommandModelSpec) Name() string {
+	return "log"
+}
+
+func (*LogCommandModelSpec) ShortDesc() string {
+	return "Log experiment"
+}
+
+func (*LogCommandModelSpec) LongDesc() string {
+	return "Log experiment, the output of the container is flooded to exercise the logging of the node."
+}
+
+type LogFloodActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*LogFloodActionCommand) Name() string {
+	return "flood"
+}
+
+func (*LogFloodActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*LogFloodActionCommand) ShortDesc() string {
+	return "container output flood"
+}
+
+func (c *LogFloodActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+type logFloodExecutor struct {
+}
+
+func (e *logFloodExecutor) Name() string {
+	return "log"
+}
+
+func (e *logFloodExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *logFloodExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return stopFlood(ctx, suid)
+	}
+	flags := model.ActionFlags
+	stream := flags[LogStreamFlag]
+	if stream == "" {
+		stream = flood.StreamStdout
+	}
+	if stream != flood.StreamStdout && stream != flood.StreamStderr {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, LogStreamFlag, stream,
+			fmt.Sprintf("it must be %s or %s", flood.StreamStdout, flood.StreamStderr))
+	}
+	rate, response := rangeFlag(flags, LogRateFlag, 1000, 1, 1000000)
+	if response != nil {
+		return response
+	}
+	size, response := rangeFlag(flags, LogSizeFlag, 256, flood.MinSize, 65536)
+	if response != nil {
+		return response
+	}
+	duration, response := lifetimeFlag(flags, LogDurationFlag)
+	if response != nil {
+		return response
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	f := flood.Flood{Uid: uid, Pid: pid, Stream: stream, Rate: rate, Size: size, Duration: durations.Seconds(duration)}
+	script, err := f.Script()
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, LogSizeFlag, size, err)
+	}
+	helper, err := startOnHost(ctx, script)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "sh", err)
+	}
+	state := flood.State{Flood: f, Helper: helper}
+	if err := flood.SaveState(util.GetProgramPath(), state); err != nil {
+		syscall.Kill(helper, syscall.SIGTERM)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	log.Infof(ctx, "the %s of process %d is flooded by %d lines of %d bytes every second, helper %d", stream, pid,
+		rate, size, helper)
+	return spec.ReturnSuccess(state)
+}
+
+// stopFlood terminates the helper of the experiment, it succeeds if the helper exited by the duration
+func stopFlood(ctx context.Context, uid string) *spec.Response {
+	stateDir := util.GetProgramPath()
+	state, err := flood.LoadState(stateDir, uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, stateDir, err)
+	}
+	// the helper exits if the stream is closed, the pid may be reused, so it is checked by the marker
+	if err := terminateProcess(state.Helper, state.Marker()); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "kill", err)
+	}
+	if err := flood.RemoveState(stateDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove state", err)
+	}
+	return spec.ReturnSuccess(uid)
+}
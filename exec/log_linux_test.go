@@ -0,0 +1,103 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/flood"
+)
+
+// fakeHost replaces the helper started on the host by a sleeping shell with the script in its command line
+func fakeHost(t *testing.T) *[]string {
+	t.Helper()
+	scripts := make([]string, 0)
+	origin := startOnHost
+	startOnHost = func(ctx context.Context, script string) (int, error) {
+		scripts = append(scripts, script)
+		cmd := exec.Command("sh", "-c", "sleep 10\n"+script)
+		if err := cmd.Start(); err != nil {
+			return 0, err
+		}
+		go cmd.Wait()
+		t.Cleanup(func() { cmd.Process.Kill() })
+		return cmd.Process.Pid, nil
+	}
+	t.Cleanup(func() { startOnHost = origin })
+	return &scripts
+}
+
+func TestLogFlood(t *testing.T) {
+	fakeNetns(t, "")
+	scripts := fakeHost(t)
+	flags := map[string]string{ContainerIdFlag.Name: "c1", LogStreamFlag: "stderr", LogRateFlag: "500", LogDurationFlag: "60"}
+	model := &spec.ExpModel{Target: "log", ActionName: "flood", ActionFlags: flags}
+	response := (&logFloodExecutor{}).Exec("uid1", context.Background(), model)
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	defer flood.RemoveState(util.GetProgramPath(), "uid1")
+	state := response.Result.(flood.State)
+	if state.Pid != 1234 || state.Size != 256 || state.Rate != 500 || state.Helper == 0 {
+		t.Errorf("unexpected state %+v", state)
+	}
+	for _, expected := range []string{"/proc/1234/fd/2", "head -n 500", "+ 60))"} {
+		if !strings.Contains((*scripts)[0], expected) {
+			t.Errorf("expected %q in the flood script, got %s", expected, (*scripts)[0])
+		}
+	}
+
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := (&logFloodExecutor{}).Exec("uid1", ctx, model); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if cmdline, err := getProcessCmdline(state.Helper); err == nil && cmdline != "" {
+		t.Errorf("expected the helper terminated, got %s", cmdline)
+	}
+	if _, err := flood.LoadState(util.GetProgramPath(), "uid1"); !os.IsNotExist(err) {
+		t.Errorf("expected the state removed, got %v", err)
+	}
+}
+
+func TestLogFloodIllegal(t *testing.T) {
+	fakeNetns(t, "")
+	scripts := fakeHost(t)
+	tests := []map[string]string{
+		{LogStreamFlag: "stdin"},
+		{LogRateFlag: "0"},
+		{LogSizeFlag: "16"},
+		{LogDurationFlag: "-1"},
+	}
+	for _, flags := range tests {
+		flags[ContainerIdFlag.Name] = "c1"
+		model := &spec.ExpModel{Target: "log", ActionName: "flood", ActionFlags: flags}
+		response := (&logFloodExecutor{}).Exec("uid1", context.Background(), model)
+		if response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the illegal parameter of %v, got %+v", flags, response)
+		}
+	}
+	if len(*scripts) != 0 {
+		t.Errorf("expected nothing started, got %q", *scripts)
+	}
+}
@@ -28,6 +28,14 @@ import (
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/process"
 	"github.com/chaosblade-io/chaosblade-exec-os/exec/script"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/batch"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/killswitch"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nssession"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/progress"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/schema"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/transcript"
 )
 
 type ResourceExpModelSpec interface {
@@ -417,6 +425,12 @@ func (b *DockerExpModelSpec) GetExpActionModelSpec(target, actionName string) sp
 
 func (b *DockerExpModelSpec) addExpModels(expModel ...spec.ExpModelCommandSpec) {
 	for _, model := range expModel {
+		// the flags of the actions declaring the parameters are validated before the executors
+		for _, action := range model.Actions() {
+			if _, ok := schema.Get(model.Name(), action.Name()); ok && action.Executor() != nil {
+				action.SetExecutor(schema.Wrap(action.Executor()))
+			}
+		}
 		b.ExpModelSpecs[model.Name()] = model
 	}
 }
@@ -436,14 +450,18 @@ func GetAllExecutors() map[string]spec.Executor {
 func extractExecutorFromExpModel(expModel spec.ExpModelCommandSpec) map[string]spec.Executor {
 	executors := make(map[string]spec.Executor)
 	for _, actionModel := range expModel.Actions() {
-		executors[GetExecutorKey(expModel.Name(), actionModel.Name())] = actionModel.Executor()
+		executor := batch.Wrap(wrapStateVerify(actionModel.Executor()), BatchModeFlag.Name, ContainerIdFlag.Name,
+			ContainerNameFlag.Name)
+		executor.Canary = canaryCheck
+		executors[GetExecutorKey(expModel.Name(), actionModel.Name())] = tunnelExecutor{progress.Wrap(killswitch.Wrap(
+			trace.Wrap(transcript.Wrap(nssession.Wrap(executor), TranscriptFlag.Name))))}
 	}
 	return executors
 }
 
 var ContainerIdFlag = &spec.ExpFlag{
 	Name:                  "container-id",
-	Desc:                  "Container id, when used with container-name, container-id is preferred",
+	Desc:                  "Container id, when used with container-name, container-id is preferred, the ids separated by commas inject the containers in a batch",
 	NoArgs:                false,
 	Required:              false,
 	RequiredWhenDestroyed: false,
@@ -451,7 +469,7 @@ var ContainerIdFlag = &spec.ExpFlag{
 
 var ContainerNameFlag = &spec.ExpFlag{
 	Name:                  "container-name",
-	Desc:                  "Container name, when used with container-id, container-id is preferred",
+	Desc:                  "Container name, when used with container-id, container-id is preferred, the names separated by commas inject the containers in a batch",
 	NoArgs:                false,
 	Required:              false,
 	RequiredWhenDestroyed: false,
@@ -465,6 +483,46 @@ var ContainerLabelSelectorFlag = &spec.ExpFlag{
 	RequiredWhenDestroyed: false,
 }
 
+var BatchModeFlag = &spec.ExpFlag{
+	Name:     "batch-mode",
+	Desc:     "The mode of the batch of the containers, strict rolls back the containers injected if any container failed, best-effort keeps them and succeeds if any container is injected, canary injects the first container and checks it by the canary probes before the others, default value is strict",
+	NoArgs:   false,
+	Required: false,
+}
+
+var CanaryProbesFlag = &spec.ExpFlag{
+	Name:     "canary-probes",
+	Desc:     `The probes checking the canary container of the canary batch mode, the json array of the probes of the agent, such as [{"type":"command","command":"curl -sf localhost/healthz"}]`,
+	NoArgs:   false,
+	Required: false,
+}
+
+var CanaryWaitFlag = &spec.ExpFlag{
+	Name:     "canary-wait",
+	Desc:     "How long to wait after the canary container injected before the canary probes, such as 30s, default value is 10s",
+	NoArgs:   false,
+	Required: false,
+}
+
+var VerifyStateFlag = &spec.ExpFlag{
+	Name:   "verify-state",
+	Desc:   "Snapshot the processes, the listening ports, the files of verify-files and the resource stats of the container before the injection and after the revert, the result of the destroy carries the diff, default value is false",
+	NoArgs: true,
+}
+
+var VerifyFilesFlag = &spec.ExpFlag{
+	Name:     "verify-files",
+	Desc:     "The files in the container whose checksums are compared by verify-state, separated by commas, such as /etc/hosts,/etc/resolv.conf",
+	NoArgs:   false,
+	Required: false,
+}
+
+var TranscriptFlag = &spec.ExpFlag{
+	Name:   "transcript",
+	Desc:   "Record the commands executed in the container and its network namespace by the create and the destroy, with the times, the exit codes and the outputs, to the transcript of the experiment, default value is false",
+	NoArgs: true,
+}
+
 var ImageRepoFlag = &spec.ExpFlag{
 	Name:     "image-repo",
 	Desc:     "Image repository of the chaosblade-tool",
@@ -474,7 +532,7 @@ var ImageRepoFlag = &spec.ExpFlag{
 
 var ImageVersionFlag = &spec.ExpFlag{
 	Name:     "image-version",
-	Desc:     "Image version of the chaosblade-tool",
+	Desc:     "Image version of the chaosblade-tool, the image pinned by the helper template is used if neither the repository nor the version is set",
 	NoArgs:   false,
 	Required: false,
 }
@@ -488,7 +546,22 @@ var EndpointFlag = &spec.ExpFlag{
 
 var ChaosBladeReleaseFlag = &spec.ExpFlag{
 	Name: "chaosblade-release",
-	Desc: "The pull path of the chaosblade tar package, for example, --chaosblade-release /opt/chaosblade-0.4.0.tar.gz",
+	Desc: "The pull path of the chaosblade tar package, or the http(s) url, or the oci artifact reference of it, for example, --chaosblade-release /opt/chaosblade-0.4.0.tar.gz, oci://registry.example.com/chaosblade/bundle:0.4.0",
+}
+
+var ChaosBladeReleaseDigestFlag = &spec.ExpFlag{
+	Name: "chaosblade-release-digest",
+	Desc: "The sha256:<hex> digest of the remote chaosblade tar package, the downloaded package is verified and cached on the node by it",
+}
+
+var ChaosBladeInjectFlag = &spec.ExpFlag{
+	Name: "chaosblade-inject",
+	Desc: "The way to deploy the chaosblade tool into the target container, support copy and mount, mount shares the tool extracted on the node by an overlay mount instead of copying, and falls back to copy if the mount is invisible in the container, image recreates the container from its image with the tool layered in, for the containers with a read-only filesystem, docker only, default value is copy",
+}
+
+var ChaosBladeDirFlag = &spec.ExpFlag{
+	Name: "chaosblade-dir",
+	Desc: "The directory of the chaosblade tool deployed in the target container, for the images with specific writable paths, its parent must be writable, an existing directory is refused unless it holds the chaosblade tool deployed before, and it must not be the directory the release is extracted to, default value is the CHAOSBLADE_CRI_BLADE_DIR environment, or /opt/chaosblade",
 }
 
 var ChaosBladeOverrideFlag = &spec.ExpFlag{
@@ -497,16 +570,113 @@ var ChaosBladeOverrideFlag = &spec.ExpFlag{
 	NoArgs: true,
 }
 
+var ChaosBladeRetainFlag = &spec.ExpFlag{
+	Name:   "chaosblade-retain",
+	Desc:   "Retain the chaosblade tool deployed in the target container after the destroy for debugging, default value is false",
+	NoArgs: true,
+}
+
 var ContainerRuntime = &spec.ExpFlag{
 	Name:     "container-runtime",
-	Desc:     "container runtime, support cri and containerd, default value is docker",
+	Desc:     "container runtime, support cri and containerd, default value is docker, auto discovers the live runtime of the well-known sockets, containerd, cri-o, cri-dockerd and docker",
+	NoArgs:   false,
+	Required: false,
+}
+
+var CRITLSCAFlag = &spec.ExpFlag{
+	Name:     "cri-tls-ca",
+	Desc:     "The ca file verifying the certificate of the cri-endpoint served over tls, such as tcp://10.0.0.1:10010, default value is the system cas if any tls flag is given",
+	NoArgs:   false,
+	Required: false,
+}
+
+var CRITLSCertFlag = &spec.ExpFlag{
+	Name:     "cri-tls-cert",
+	Desc:     "The client certificate file of the mutual tls of the cri-endpoint, it requires cri-tls-key",
+	NoArgs:   false,
+	Required: false,
+}
+
+var CRITLSKeyFlag = &spec.ExpFlag{
+	Name:     "cri-tls-key",
+	Desc:     "The client key file of the mutual tls of the cri-endpoint, it requires cri-tls-cert",
+	NoArgs:   false,
+	Required: false,
+}
+
+var CRITLSServerNameFlag = &spec.ExpFlag{
+	Name:     "cri-tls-server-name",
+	Desc:     "The server name verified in the certificate of the cri-endpoint, default value is the host of the cri-endpoint",
 	NoArgs:   false,
 	Required: false,
 }
 
 var ContainerNamespace = &spec.ExpFlag{
 	Name:     "container-namespace",
-	Desc:     "container namespace, If container-runtime is containerd it will be used, default value is k8s.io",
+	Desc:     "container namespace, If container-runtime is containerd it will be used, default value is k8s.io, auto selects the one containing the container",
+	NoArgs:   false,
+	Required: false,
+}
+
+var SSHTunnelFlag = &spec.ExpFlag{
+	Name:     "ssh-tunnel",
+	Desc:     "Reach the container runtime of a remote node by ssh local forwarding, the format is user@host[:port]",
+	NoArgs:   false,
+	Required: false,
+}
+
+var SSHKeyFlag = &spec.ExpFlag{
+	Name:     "ssh-key",
+	Desc:     "The ssh private key file used by ssh-tunnel, default value is ~/.ssh/id_rsa",
+	NoArgs:   false,
+	Required: false,
+}
+
+var SSHKnownHostsFlag = &spec.ExpFlag{
+	Name:     "ssh-known-hosts",
+	Desc:     "The known hosts file used by ssh-tunnel to verify the remote host, default value is ~/.ssh/known_hosts",
+	NoArgs:   false,
+	Required: false,
+}
+
+var SSHRemoteSocketFlag = &spec.ExpFlag{
+	Name:     "ssh-remote-socket",
+	Desc:     "The container runtime socket on the remote node, default value is the default socket of the container-runtime",
+	NoArgs:   false,
+	Required: false,
+}
+
+var AddressFamilyFlag = &spec.ExpFlag{
+	Name:     "address-family",
+	Desc:     "The address family of the network rules, support ipv4, ipv6 and dual, default value is the family of the ip flags, or ipv4 without them",
+	NoArgs:   false,
+	Required: false,
+}
+
+var QdiscConflictFlag = &spec.ExpFlag{
+	Name:     "qdisc-conflict",
+	Desc:     "How to handle the qdiscs of the target interface installed by the CNI shapers, such as the tbf of the bandwidth plugin or the fq of the Cilium bandwidth manager, support refuse and chain, refuse fails the experiment with the conflicts, chain adds the netem under the tbf root qdisc for the whole interface, default value is refuse",
+	NoArgs:   false,
+	Required: false,
+}
+
+var ProtocolFlag = &spec.ExpFlag{
+	Name:     "protocol",
+	Desc:     "The transport protocol of the tc actions, support udp and tcp, only the packets of the protocol are impacted, the local-port and the remote-port flags match its ports and accept the ranges such as 5000-5100, default value is all the protocols",
+	NoArgs:   false,
+	Required: false,
+}
+
+var DirectionFlag = &spec.ExpFlag{
+	Name:     "direction",
+	Desc:     "The direction of the tc actions, support egress, ingress and both, the ingress packets are redirected to an ifb device and impacted by its netem, the filters of the ingress and both directions require the protocol flag, default value is egress",
+	NoArgs:   false,
+	Required: false,
+}
+
+var FirewallBackendFlag = &spec.ExpFlag{
+	Name:     "firewall-backend",
+	Desc:     "The firewall backend of the drop rules in the network namespace of the container, support auto, iptables and nft, auto uses nft if iptables is missing, or iptables is legacy while the ruleset is managed by nft, default value is auto",
 	NoArgs:   false,
 	Required: false,
 }
@@ -515,9 +685,23 @@ func GetContainerSelfFlags() []spec.ExpFlagSpec {
 	return []spec.ExpFlagSpec{
 		ContainerIdFlag,
 		ContainerNameFlag,
+		BatchModeFlag,
+		CanaryProbesFlag,
+		CanaryWaitFlag,
+		VerifyStateFlag,
+		VerifyFilesFlag,
+		TranscriptFlag,
 		EndpointFlag,
+		CRITLSCAFlag,
+		CRITLSCertFlag,
+		CRITLSKeyFlag,
+		CRITLSServerNameFlag,
 		ContainerRuntime,
 		ContainerNamespace,
+		SSHTunnelFlag,
+		SSHKeyFlag,
+		SSHKnownHostsFlag,
+		SSHRemoteSocketFlag,
 	}
 }
 
@@ -525,9 +709,19 @@ func GetExecSidecarFlags() []spec.ExpFlagSpec {
 	return []spec.ExpFlagSpec{
 		ContainerIdFlag,
 		ContainerNameFlag,
+		BatchModeFlag,
+		CanaryProbesFlag,
+		CanaryWaitFlag,
+		VerifyStateFlag,
+		VerifyFilesFlag,
+		TranscriptFlag,
 		ImageRepoFlag,
 		ImageVersionFlag,
 		EndpointFlag,
+		CRITLSCAFlag,
+		CRITLSCertFlag,
+		CRITLSKeyFlag,
+		CRITLSServerNameFlag,
 		ContainerRuntime,
 		ContainerNamespace,
 	}
@@ -537,11 +731,25 @@ func GetExecInContainerFlags() []spec.ExpFlagSpec {
 	return []spec.ExpFlagSpec{
 		ContainerIdFlag,
 		ContainerNameFlag,
+		BatchModeFlag,
+		CanaryProbesFlag,
+		CanaryWaitFlag,
+		VerifyStateFlag,
+		VerifyFilesFlag,
+		TranscriptFlag,
 		ImageRepoFlag,
 		ImageVersionFlag,
 		EndpointFlag,
+		CRITLSCAFlag,
+		CRITLSCertFlag,
+		CRITLSKeyFlag,
+		CRITLSServerNameFlag,
 		ChaosBladeReleaseFlag,
+		ChaosBladeReleaseDigestFlag,
+		ChaosBladeInjectFlag,
+		ChaosBladeDirFlag,
 		ChaosBladeOverrideFlag,
+		ChaosBladeRetainFlag,
 		ContainerRuntime,
 		ContainerNamespace,
 	}
@@ -551,13 +759,53 @@ func GetNSExecFlags() []spec.ExpFlagSpec {
 	return []spec.ExpFlagSpec{
 		ContainerIdFlag,
 		ContainerNameFlag,
+		BatchModeFlag,
+		CanaryProbesFlag,
+		CanaryWaitFlag,
+		VerifyStateFlag,
+		VerifyFilesFlag,
+		TranscriptFlag,
 		EndpointFlag,
+		CRITLSCAFlag,
+		CRITLSCertFlag,
+		CRITLSKeyFlag,
+		CRITLSServerNameFlag,
 		ContainerRuntime,
 		ContainerNamespace,
 		ContainerLabelSelectorFlag,
 	}
 }
 
+func GetNetworkFamilyFlags() []spec.ExpFlagSpec {
+	return []spec.ExpFlagSpec{
+		AddressFamilyFlag,
+	}
+}
+
+func GetNetworkQdiscFlags() []spec.ExpFlagSpec {
+	return []spec.ExpFlagSpec{
+		QdiscConflictFlag,
+	}
+}
+
+func GetNetworkProtocolFlags() []spec.ExpFlagSpec {
+	return []spec.ExpFlagSpec{
+		ProtocolFlag,
+	}
+}
+
+func GetNetworkDirectionFlags() []spec.ExpFlagSpec {
+	return []spec.ExpFlagSpec{
+		DirectionFlag,
+	}
+}
+
+func GetNetworkFirewallFlags() []spec.ExpFlagSpec {
+	return []spec.ExpFlagSpec{
+		FirewallBackendFlag,
+	}
+}
+
 func getAllDockerFlags() []spec.ExpFlagSpec {
 	allFlags := make([]spec.ExpFlagSpec, 0)
 	allFlags = append(allFlags, GetContainerSelfFlags()...)
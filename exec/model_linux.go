@@ -26,10 +26,12 @@ func NewCriExpModelSpec() *DockerExpModelSpec {
 		ExpModelSpecs: make(map[string]spec.ExpModelCommandSpec, 0),
 	}
 
-	// common
+	// common, the cpu model has the wave action and the disk model has the inode fill action
+	cpuModelSpec := withCpuWaveAction(newCpuCommandModelSpecForDocker())
+	diskModelSpec := withInodeFillAction(newDiskFillCommandSpecForDocker())
 	commonModelSpec := []spec.ExpModelCommandSpec{
-		newCpuCommandModelSpecForDocker(),
-		newDiskFillCommandSpecForDocker(),
+		cpuModelSpec,
+		diskModelSpec,
 		newMemCommandModelSpecForDocker(),
 		newFileCommandSpecForDocker(),
 		newScriptCommandSpecForDocker(),
@@ -37,17 +39,35 @@ func NewCriExpModelSpec() *DockerExpModelSpec {
 		newHTTPCommandSpecForDocker(),
 	}
 	spec.AddExecutorToModelSpec(NewCommonExecutor(), commonModelSpec...)
+	for _, action := range cpuModelSpec.Actions() {
+		if action.Name() == "wave" {
+			action.SetExecutor(&cpuWaveExecutor{})
+		}
+	}
+	for _, action := range diskModelSpec.Actions() {
+		if action.Name() == "inode" {
+			action.SetExecutor(&inodeFillExecutor{})
+		}
+	}
 	spec.AddFlagsToModelSpec(GetNSExecFlags, commonModelSpec...)
 
-	// network
-	networkModeSpec := newNetworkCommandModelSpecForDocker()
+	// network, the dns_delay action is added to the network model
+	networkModeSpec := withDNSDelayAction(newNetworkCommandModelSpecForDocker())
 	spec.AddExecutorToModelSpec(NewNetworkExecutor(), networkModeSpec)
 	spec.AddFlagsToModelSpec(GetNSExecFlags, networkModeSpec)
+	spec.AddFlagsToModelSpec(GetNetworkFamilyFlags, networkModeSpec)
+	spec.AddFlagsToModelSpec(GetNetworkQdiscFlags, networkModeSpec)
+	spec.AddFlagsToModelSpec(GetNetworkProtocolFlags, networkModeSpec)
+	spec.AddFlagsToModelSpec(GetNetworkDirectionFlags, networkModeSpec)
+	spec.AddFlagsToModelSpec(GetNetworkFirewallFlags, networkModeSpec)
 
 	for _, action := range networkModeSpec.Actions() {
 		if action.Name() == "dns" || action.Name() == "occupy" {
 			action.SetExecutor(NewCommonExecutor())
 		}
+		if action.Name() == "dns_delay" {
+			action.SetExecutor(&dnsDelayExecutor{})
+		}
 	}
 
 	// copy
@@ -59,9 +79,68 @@ func NewCriExpModelSpec() *DockerExpModelSpec {
 	containerSelfModelSpec := NewContainerCommandSpec()
 	spec.AddFlagsToModelSpec(GetContainerSelfFlags, containerSelfModelSpec)
 
+	// gpu
+	gpuModelSpec := NewGPUCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, gpuModelSpec)
+
+	// device
+	deviceModelSpec := NewDeviceCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, deviceModelSpec)
+
+	// steal
+	stealModelSpec := NewStealCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, stealModelSpec)
+
+	// interface
+	interfaceModelSpec := NewInterfaceCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, interfaceModelSpec)
+
+	// conntrack
+	conntrackModelSpec := NewConntrackCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, conntrackModelSpec)
+
+	// secret
+	secretModelSpec := NewSecretCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, secretModelSpec)
+
+	// log
+	logModelSpec := NewLogCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, logModelSpec)
+
+	// pid
+	pidModelSpec := NewPidCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, pidModelSpec)
+
+	// fd
+	fdModelSpec := NewFdCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, fdModelSpec)
+
+	// socket
+	socketModelSpec := NewSocketCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, socketModelSpec)
+
+	// hosts
+	hostsModelSpec := NewHostsCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, hostsModelSpec)
+
+	// sysctl
+	sysctlModelSpec := NewSysctlCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, sysctlModelSpec)
+
+	// stress
+	stressModelSpec := NewStressCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, stressModelSpec)
+
+	// node
+	nodeModelSpec := withNRIAction(withStartDelayAction(withImageFsAction(NewNodeCommandSpec())))
+	spec.AddFlagsToModelSpec(GetNodeFlags, nodeModelSpec)
+
 	expModelCommandSpecs := append(commonModelSpec, networkModeSpec)
 	expModelCommandSpecs = append(expModelCommandSpecs, execInContainerModelSpecs...)
-	expModelCommandSpecs = append(expModelCommandSpecs, containerSelfModelSpec)
+	expModelCommandSpecs = append(expModelCommandSpecs, containerSelfModelSpec, gpuModelSpec, deviceModelSpec,
+		stealModelSpec, interfaceModelSpec, conntrackModelSpec, secretModelSpec,
+		logModelSpec, pidModelSpec, fdModelSpec, socketModelSpec, hostsModelSpec,
+		sysctlModelSpec, stressModelSpec, nodeModelSpec)
 	modelSpec.addExpModels(expModelCommandSpecs...)
 	return modelSpec
 }
@@ -72,10 +151,12 @@ func NewDockerExpModelSpec() *DockerExpModelSpec {
 		ExpModelSpecs: make(map[string]spec.ExpModelCommandSpec, 0),
 	}
 
-	// common
+	// common, the cpu model has the wave action and the disk model has the inode fill action
+	cpuModelSpec := withCpuWaveAction(newCpuCommandModelSpecForDocker())
+	diskModelSpec := withInodeFillAction(newDiskFillCommandSpecForDocker())
 	commonModelSpec := []spec.ExpModelCommandSpec{
-		newCpuCommandModelSpecForDocker(),
-		newDiskFillCommandSpecForDocker(),
+		cpuModelSpec,
+		diskModelSpec,
 		newMemCommandModelSpecForDocker(),
 		newFileCommandSpecForDocker(),
 		newScriptCommandSpecForDocker(),
@@ -84,17 +165,35 @@ func NewDockerExpModelSpec() *DockerExpModelSpec {
 	}
 
 	spec.AddExecutorToModelSpec(NewCommonExecutor(), commonModelSpec...)
+	for _, action := range cpuModelSpec.Actions() {
+		if action.Name() == "wave" {
+			action.SetExecutor(&cpuWaveExecutor{})
+		}
+	}
+	for _, action := range diskModelSpec.Actions() {
+		if action.Name() == "inode" {
+			action.SetExecutor(&inodeFillExecutor{})
+		}
+	}
 	spec.AddFlagsToModelSpec(GetNSExecFlags, commonModelSpec...)
 
-	// network
-	networkModeSpec := newNetworkCommandModelSpecForDocker()
+	// network, the dns_delay action is added to the network model
+	networkModeSpec := withDNSDelayAction(newNetworkCommandModelSpecForDocker())
 	spec.AddExecutorToModelSpec(NewNetworkExecutor(), networkModeSpec)
 	spec.AddFlagsToModelSpec(GetNSExecFlags, networkModeSpec)
+	spec.AddFlagsToModelSpec(GetNetworkFamilyFlags, networkModeSpec)
+	spec.AddFlagsToModelSpec(GetNetworkQdiscFlags, networkModeSpec)
+	spec.AddFlagsToModelSpec(GetNetworkProtocolFlags, networkModeSpec)
+	spec.AddFlagsToModelSpec(GetNetworkDirectionFlags, networkModeSpec)
+	spec.AddFlagsToModelSpec(GetNetworkFirewallFlags, networkModeSpec)
 
 	for _, action := range networkModeSpec.Actions() {
 		if action.Name() == "dns" || action.Name() == "occupy" {
 			action.SetExecutor(NewCommonExecutor())
 		}
+		if action.Name() == "dns_delay" {
+			action.SetExecutor(&dnsDelayExecutor{})
+		}
 	}
 
 	// copy
@@ -106,9 +205,64 @@ func NewDockerExpModelSpec() *DockerExpModelSpec {
 	containerSelfModelSpec := NewContainerCommandSpec()
 	spec.AddFlagsToModelSpec(GetContainerSelfFlags, containerSelfModelSpec)
 
+	// gpu
+	gpuModelSpec := NewGPUCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, gpuModelSpec)
+
+	// device
+	deviceModelSpec := NewDeviceCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, deviceModelSpec)
+
+	// steal
+	stealModelSpec := NewStealCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, stealModelSpec)
+
+	// interface
+	interfaceModelSpec := NewInterfaceCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, interfaceModelSpec)
+
+	// conntrack
+	conntrackModelSpec := NewConntrackCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, conntrackModelSpec)
+
+	// secret
+	secretModelSpec := NewSecretCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, secretModelSpec)
+
+	// log
+	logModelSpec := NewLogCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, logModelSpec)
+
+	// pid
+	pidModelSpec := NewPidCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, pidModelSpec)
+
+	// fd
+	fdModelSpec := NewFdCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, fdModelSpec)
+
+	// socket
+	socketModelSpec := NewSocketCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, socketModelSpec)
+
+	// hosts
+	hostsModelSpec := NewHostsCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, hostsModelSpec)
+
+	// sysctl
+	sysctlModelSpec := NewSysctlCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, sysctlModelSpec)
+
+	// stress
+	stressModelSpec := NewStressCommandSpec()
+	spec.AddFlagsToModelSpec(GetNSExecFlags, stressModelSpec)
+
 	expModelCommandSpecs := append(commonModelSpec, networkModeSpec)
 	expModelCommandSpecs = append(expModelCommandSpecs, execInContainerModelSpecs...)
-	expModelCommandSpecs = append(expModelCommandSpecs, containerSelfModelSpec)
+	expModelCommandSpecs = append(expModelCommandSpecs, containerSelfModelSpec, gpuModelSpec, deviceModelSpec,
+		stealModelSpec, interfaceModelSpec, conntrackModelSpec, secretModelSpec,
+		logModelSpec, pidModelSpec, fdModelSpec, socketModelSpec, hostsModelSpec,
+		sysctlModelSpec, stressModelSpec)
 	modelSpec.addExpModels(expModelCommandSpecs...)
 	return modelSpec
 }
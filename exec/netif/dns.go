@@ -0,0 +1,70 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netif
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+)
+
+// dnsDelayHandle is the handle of the root prio qdisc of the dns delay, the qdisc of other handles is never deleted
+const dnsDelayHandle = "1bae:"
+
+// dnsDelayBand is the band of the prio qdisc the dns packets are classified into, the default priomap of the three
+// bands never selects it, so the other packets are not delayed
+const dnsDelayBand = 4
+
+// DNSDelay delays the dns packets leaving the interface by the netem under a prio qdisc, the packets to the port are
+// the queries of the clients and the packets from the port are the responses of the dns servers, such as coredns, so
+// it slows down the resolution whichever side the pod is
+type DNSDelay struct {
+	Interface string
+	Port      int
+	// Time and Offset are the delay and its jitter in milliseconds
+	Time   int
+	Offset int
+}
+
+// Script adds the prio qdisc, the netem of the dns band and the u32 filters of both the udp and the tcp of both
+// families, the ports of the u32 match the udp and the tcp headers alike
+func (d DNSDelay) Script() string {
+	iface := nsexec.Quote(d.Interface)
+	netem := fmt.Sprintf("delay %dms", d.Time)
+	if d.Offset > 0 {
+		netem += fmt.Sprintf(" %dms distribution normal", d.Offset)
+	}
+	flowid := fmt.Sprintf("%s%d", dnsDelayHandle, dnsDelayBand)
+	commands := []string{
+		fmt.Sprintf("tc qdisc add dev %s root handle %s prio bands %d priomap 1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1", iface,
+			dnsDelayHandle, dnsDelayBand),
+		fmt.Sprintf("tc qdisc add dev %s parent %s netem %s", iface, flowid, netem),
+	}
+	for _, family := range []struct{ protocol, match string }{{"ip", "ip"}, {"ipv6", "ip6"}} {
+		for _, port := range []string{"dport", "sport"} {
+			commands = append(commands, fmt.Sprintf("tc filter add dev %s parent %s protocol %s prio 1 u32 "+
+				"match %s %s %d 0xffff flowid %s", iface, dnsDelayHandle, family.protocol, family.match, port, d.Port, flowid))
+		}
+	}
+	return strings.Join(commands, " && ")
+}
+
+// RestoreScript deletes the prio qdisc, its netem and filters are deleted with it
+func (d DNSDelay) RestoreScript() string {
+	return fmt.Sprintf("tc qdisc del dev %s root handle %s 2>/dev/null; true", nsexec.Quote(d.Interface), dnsDelayHandle)
+}
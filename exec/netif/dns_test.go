@@ -0,0 +1,46 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netif
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDNSDelay(t *testing.T) {
+	delay := DNSDelay{Interface: "eth0", Port: 53, Time: 300}
+	commands := strings.Split(delay.Script(), " && ")
+	expected := []string{
+		"tc qdisc add dev eth0 root handle 1bae: prio bands 4 priomap 1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1",
+		"tc qdisc add dev eth0 parent 1bae:4 netem delay 300ms",
+		"tc filter add dev eth0 parent 1bae: protocol ip prio 1 u32 match ip dport 53 0xffff flowid 1bae:4",
+		"tc filter add dev eth0 parent 1bae: protocol ip prio 1 u32 match ip sport 53 0xffff flowid 1bae:4",
+		"tc filter add dev eth0 parent 1bae: protocol ipv6 prio 1 u32 match ip6 dport 53 0xffff flowid 1bae:4",
+		"tc filter add dev eth0 parent 1bae: protocol ipv6 prio 1 u32 match ip6 sport 53 0xffff flowid 1bae:4",
+	}
+	if strings.Join(commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, commands)
+	}
+	delay = DNSDelay{Interface: "eth1", Port: 5353, Time: 300, Offset: 100}
+	if script := delay.Script(); !strings.Contains(script, "netem delay 300ms 100ms distribution normal") ||
+		!strings.Contains(script, "dport 5353 0xffff") {
+		t.Errorf("unexpected script %s", script)
+	}
+	if script := delay.RestoreScript(); script != "tc qdisc del dev eth1 root handle 1bae: 2>/dev/null; true" {
+		t.Errorf("unexpected restore script %s", script)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netif
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+)
+
+// The directions of the tc experiments
+const (
+	DirectionEgress  = "egress"
+	DirectionIngress = "ingress"
+	DirectionBoth    = "both"
+)
+
+// ingressPref is the preference of the filter redirecting the ingress packets to the ifb device, it is after the
+// filters of the eBPF datapaths
+const ingressPref = 49150
+
+// IngressDevice returns the name of the ifb device of the experiment, it is limited to 15 characters
+func IngressDevice(uid string) string {
+	if len(uid) > 12 {
+		uid = uid[:12]
+	}
+	return "cbi" + uid
+}
+
+// Ingress impacts the packets received by the interface, the netem only shapes the egress of a device, so the
+// packets are redirected to an ifb device by the ingress hook of the clsact qdisc and impacted on its egress
+type Ingress struct {
+	Interface string
+	Device    string
+}
+
+// Script creates the ifb device, runs the script adding the netem to it, the NetemScript or the ProtocolNetem of the
+// device, then redirects the ingress packets to it. The clsact qdisc is added if it is absent
+func (i Ingress) Script(addClsact bool, netemScript string) string {
+	iface, device := nsexec.Quote(i.Interface), nsexec.Quote(i.Device)
+	commands := []string{
+		fmt.Sprintf("ip link add dev %s type ifb", device),
+		fmt.Sprintf("ip link set dev %s up", device),
+		netemScript,
+	}
+	if addClsact {
+		commands = append(commands, fmt.Sprintf("tc qdisc add dev %s clsact", iface))
+	}
+	commands = append(commands, fmt.Sprintf("tc filter add dev %s ingress pref %d matchall action mirred egress "+
+		"redirect dev %s", iface, ingressPref, device))
+	return strings.Join(commands, " && ")
+}
+
+// directionHandle is the handle of the root netem of the whole traffic, the root qdisc of other handles is never deleted
+const directionHandle = "1bb0:"
+
+// NetemScript adds the netem of the whole traffic to the root of the interface or the ifb device
+func NetemScript(iface, netemArgs string) string {
+	return fmt.Sprintf("tc qdisc add dev %s root handle %s netem %s", nsexec.Quote(iface), directionHandle, netemArgs)
+}
+
+// NetemRestoreScript deletes the root netem added by the NetemScript
+func NetemRestoreScript(iface string) string {
+	return fmt.Sprintf("tc qdisc del dev %s root handle %s 2>/dev/null; true", nsexec.Quote(iface), directionHandle)
+}
+
+// RestoreScript deletes the redirecting filter and the ifb device with its netem, and the clsact qdisc if it was
+// added by the Script
+func (i Ingress) RestoreScript(removeClsact bool) string {
+	iface := nsexec.Quote(i.Interface)
+	commands := []string{fmt.Sprintf("tc filter del dev %s ingress pref %d 2>/dev/null", iface, ingressPref)}
+	if removeClsact {
+		commands = append(commands, fmt.Sprintf("tc qdisc del dev %s clsact 2>/dev/null", iface))
+	}
+	commands = append(commands, fmt.Sprintf("ip link del dev %s 2>/dev/null", nsexec.Quote(i.Device)), "true")
+	return strings.Join(commands, "; ")
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netif
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIngressDevice(t *testing.T) {
+	if device := IngressDevice("0123456789abcdef"); device != "cbi0123456789ab" || len(device) > 15 {
+		t.Errorf("unexpected device %s", device)
+	}
+}
+
+func TestIngressScript(t *testing.T) {
+	ingress := Ingress{Interface: "eth0", Device: "cbi0123"}
+	script := ingress.Script(true, NetemScript("cbi0123", "delay 100ms"))
+	expected := "ip link add dev cbi0123 type ifb && ip link set dev cbi0123 up && " +
+		"tc qdisc add dev cbi0123 root handle 1bb0: netem delay 100ms && tc qdisc add dev eth0 clsact && " +
+		"tc filter add dev eth0 ingress pref 49150 matchall action mirred egress redirect dev cbi0123"
+	if script != expected {
+		t.Errorf("expected %s, got %s", expected, script)
+	}
+	if script := ingress.Script(false, ""); strings.Contains(script, "clsact") {
+		t.Errorf("expected the clsact kept, got %s", script)
+	}
+	restore := ingress.RestoreScript(true)
+	if !strings.Contains(restore, "tc qdisc del dev eth0 clsact") || !strings.Contains(restore, "ip link del dev cbi0123") {
+		t.Errorf("unexpected restore %s", restore)
+	}
+	if restore := ingress.RestoreScript(false); strings.Contains(restore, "clsact") {
+		t.Errorf("expected the clsact kept, got %s", restore)
+	}
+}
+
+func TestProtocolNetemIngress(t *testing.T) {
+	scoped := ProtocolNetem{Interface: "cbi0123", Protocol: ProtocolTCP, Netem: "loss 10%", Families: []string{"ip"},
+		LocalPorts: []string{"8080"}, RemotePorts: []string{"443"}, DestinationIPs: []string{"10.0.0.1"}, Ingress: true}
+	script, err := scoped.Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(script, "flower ip_proto tcp dst_port 8080 src_port 443 src_ip 10.0.0.1 classid 1baf:4") {
+		t.Errorf("expected the ingress matches, got %s", script)
+	}
+}
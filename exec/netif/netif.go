@@ -0,0 +1,316 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package netif builds the scripts changing the network interface of the pod, they are executed by the shell of the
+// host in the network namespace of the pod, so the ip and the tc of the host are used. The script restoring the
+// interface is saved before the change and executed by the revert, even if the process flapping it was killed.
+package netif
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/statefile"
+)
+
+// The modes taking the interface down
+const (
+	// ModeLink sets the link down, the routes of the interface removed by the kernel are added back by the restore
+	ModeLink = "link"
+	// ModeNetem drops all packets by the netem qdisc, the link and the routes are kept
+	ModeNetem = "netem"
+)
+
+// netemHandle is the handle of the root qdisc added by the netem mode, the qdisc of other handles is never deleted
+const netemHandle = "1bad:"
+
+// routesSeparator separates the ipv4 and the ipv6 routes in the output of the RoutesScript
+const routesSeparator = "--"
+
+// Route is a route of the interface
+type Route struct {
+	// Family is 4 or 6
+	Family int    `json:"family"`
+	Route  string `json:"route"`
+}
+
+// RoutesScript lists the routes of the interface of both families
+func RoutesScript(iface string) string {
+	return fmt.Sprintf("ip -4 route show dev %[1]s && echo %[2]s && ip -6 route show dev %[1]s",
+		nsexec.Quote(iface), routesSeparator)
+}
+
+// ParseRoutes parses the output of the RoutesScript, the routes without gateways are sorted before the routes with
+// gateways which depend on them, and the flags reported by the kernel only are removed
+func ParseRoutes(output string) []Route {
+	routes := make([]Route, 0)
+	family := 4
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == routesSeparator {
+			family = 6
+			continue
+		}
+		// the nexthops of the multipath routes are not supported
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		fields := make([]string, 0)
+		words := strings.Fields(line)
+		for i := 0; i < len(words); i++ {
+			switch words[i] {
+			case "linkdown", "dead":
+				continue
+			case "expires":
+				i++
+				continue
+			}
+			fields = append(fields, words[i])
+		}
+		routes = append(routes, Route{Family: family, Route: strings.Join(fields, " ")})
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return !hasGateway(routes[i]) && hasGateway(routes[j])
+	})
+	return routes
+}
+
+func hasGateway(route Route) bool {
+	for _, field := range strings.Fields(route.Route) {
+		if field == "via" {
+			return true
+		}
+	}
+	return false
+}
+
+// DownScript takes the interface down by the mode
+func DownScript(iface, mode string) (string, error) {
+	switch mode {
+	case ModeLink:
+		return fmt.Sprintf("ip link set dev %s down", nsexec.Quote(iface)), nil
+	case ModeNetem:
+		return fmt.Sprintf("tc qdisc add dev %s root handle %s netem loss 100%%", nsexec.Quote(iface), netemHandle), nil
+	}
+	return "", fmt.Errorf("unsupported mode %s", mode)
+}
+
+// RestoreScript brings the interface up by the mode, it succeeds if the interface is already up
+func RestoreScript(iface, mode string, routes []Route) (string, error) {
+	switch mode {
+	case ModeLink:
+		commands := []string{fmt.Sprintf("ip link set dev %s up", nsexec.Quote(iface))}
+		for _, route := range routes {
+			commands = append(commands, fmt.Sprintf("ip -%d route replace %s dev %s 2>/dev/null", route.Family,
+				route.Route, nsexec.Quote(iface)))
+		}
+		return strings.Join(commands, "; "), nil
+	case ModeNetem:
+		return fmt.Sprintf("tc qdisc del dev %s root handle %s 2>/dev/null; true", nsexec.Quote(iface),
+			netemHandle), nil
+	}
+	return "", fmt.Errorf("unsupported mode %s", mode)
+}
+
+// LinkScript shows the link of the interface
+func LinkScript(iface string) string {
+	return fmt.Sprintf("ip -o link show dev %s", nsexec.Quote(iface))
+}
+
+// ParseMTU returns the mtu in the output of the LinkScript
+func ParseMTU(output string) (int, error) {
+	fields := strings.Fields(output)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "mtu" {
+			return strconv.Atoi(fields[i+1])
+		}
+	}
+	return 0, fmt.Errorf("mtu not found in %s", strings.TrimSpace(output))
+}
+
+// MTUScript sets the mtu of the interface
+func MTUScript(iface string, mtu int) string {
+	return fmt.Sprintf("ip link set dev %s mtu %d", nsexec.Quote(iface), mtu)
+}
+
+// The tunnels carrying the mirrored packets to the collector
+const (
+	TunnelGretap = "gretap"
+	TunnelVxlan  = "vxlan"
+)
+
+// mirrorPref is the preference of the mirred filter, the filter passing the packets to the collector is before it so
+// the tunnel packets are never mirrored again
+const mirrorPref = 49152
+
+// QdiscsScript shows the qdiscs of the interface
+func QdiscsScript(iface string) string {
+	return fmt.Sprintf("tc qdisc show dev %s", nsexec.Quote(iface))
+}
+
+// HasClsact returns true if the clsact qdisc is in the output of the QdiscsScript
+func HasClsact(output string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "qdisc clsact") {
+			return true
+		}
+	}
+	return false
+}
+
+// MirrorDevice returns the name of the tunnel device of the experiment, it is limited to 15 characters
+func MirrorDevice(uid string) string {
+	if len(uid) > 12 {
+		uid = uid[:12]
+	}
+	return "cbm" + uid
+}
+
+// Mirror mirrors the egress packets of the interface to the collector by the tunnel device
+type Mirror struct {
+	Interface string
+	Device    string
+	Tunnel    string
+	Collector string
+	// VNI and Port are the vxlan id and the udp port of the vxlan tunnel
+	VNI  int
+	Port int
+	// Handle is the handle of the filters, such as the trace.Handle of the experiment, the kernel allocates it if 0
+	Handle uint32
+}
+
+// Script creates the tunnel and the filters mirroring the packets, the clsact qdisc is added if it is absent
+func (m Mirror) Script(addClsact bool) (string, error) {
+	collector := net.ParseIP(m.Collector)
+	if collector == nil {
+		return "", fmt.Errorf("illegal collector ip %s", m.Collector)
+	}
+	protocol, gretap := "ip", "gretap"
+	if collector.To4() == nil {
+		protocol, gretap = "ipv6", "ip6gretap"
+	}
+	iface, device := nsexec.Quote(m.Interface), nsexec.Quote(m.Device)
+	var tunnel string
+	switch m.Tunnel {
+	case TunnelGretap:
+		tunnel = fmt.Sprintf("ip link add dev %s type %s remote %s", device, gretap, collector)
+	case TunnelVxlan:
+		tunnel = fmt.Sprintf("ip link add dev %s type vxlan id %d remote %s dstport %d", device, m.VNI, collector, m.Port)
+	default:
+		return "", fmt.Errorf("unsupported tunnel %s", m.Tunnel)
+	}
+	commands := []string{tunnel, fmt.Sprintf("ip link set dev %s up", device)}
+	if addClsact {
+		commands = append(commands, fmt.Sprintf("tc qdisc add dev %s clsact", iface))
+	}
+	var handle string
+	if m.Handle != 0 {
+		handle = fmt.Sprintf(" handle 0x%x", m.Handle)
+	}
+	commands = append(commands,
+		fmt.Sprintf("tc filter add dev %s egress pref %d%s protocol %s flower dst_ip %s action pass", iface,
+			mirrorPref-1, handle, protocol, collector),
+		fmt.Sprintf("tc filter add dev %s egress pref %d%s matchall action mirred egress mirror dev %s", iface,
+			mirrorPref, handle, device),
+	)
+	return strings.Join(commands, " && "), nil
+}
+
+// RestoreScript deletes the filters and the tunnel, and the clsact qdisc if it was added by the Script
+func (m Mirror) RestoreScript(removeClsact bool) string {
+	iface := nsexec.Quote(m.Interface)
+	commands := []string{
+		fmt.Sprintf("tc filter del dev %s egress pref %d 2>/dev/null", iface, mirrorPref),
+		fmt.Sprintf("tc filter del dev %s egress pref %d 2>/dev/null", iface, mirrorPref-1),
+	}
+	if removeClsact {
+		commands = append(commands, fmt.Sprintf("tc qdisc del dev %s clsact 2>/dev/null", iface))
+	}
+	commands = append(commands, fmt.Sprintf("ip link del dev %s 2>/dev/null", nsexec.Quote(m.Device)), "true")
+	return strings.Join(commands, "; ")
+}
+
+// Flap is the interface taken down for the down interval after every up interval
+type Flap struct {
+	Down    string
+	Restore string
+	// Up and DownInterval are the durations of the phases
+	UpInterval   time.Duration
+	DownInterval time.Duration
+	// Timeout is the duration the flapping stops after, rounded up to seconds, 0 flaps until the script is terminated
+	Timeout time.Duration
+}
+
+// Script returns the script flapping the interface, the interface is restored when the script is terminated or
+// timed out, the sleeps are waited in the background so the signals are trapped without delay
+func (f Flap) Script() string {
+	condition := "true"
+	lines := []string{
+		fmt.Sprintf("restore() { %s; }", f.Restore),
+		"trap 'restore; exit 0' TERM INT HUP",
+	}
+	if f.Timeout > 0 {
+		lines = append(lines, fmt.Sprintf("end=$(($(date +%%s) + %d))", durations.Seconds(f.Timeout)))
+		condition = `[ "$(date +%s)" -lt "$end" ]`
+	}
+	lines = append(lines,
+		fmt.Sprintf("while %s; do", condition),
+		fmt.Sprintf("  %s || { restore; exit 1; }", f.Down),
+		fmt.Sprintf("  sleep %s & wait $!", durations.Sleep(f.DownInterval)),
+		"  restore",
+		fmt.Sprintf("  sleep %s & wait $!", durations.Sleep(f.UpInterval)),
+		"done",
+		"restore",
+	)
+	return strings.Join(lines, "\n")
+}
+
+// State is the state of the experiment changing the interface, it is kept until the interface is restored
+type State struct {
+	Uid       string `json:"uid"`
+	Interface string `json:"interface"`
+	Restore   string `json:"restore"`
+	// Pid is the host pid of the process changing the interface in the background
+	Pid int `json:"pid,omitempty"`
+}
+
+// SaveState saves the state under the state dir
+func SaveState(stateDir string, state State) error {
+	return statefile.Save(stateFile(stateDir, state.Uid), state)
+}
+
+// LoadState returns the state of the experiment, the error is os.ErrNotExist if it is not saved
+func LoadState(stateDir, uid string) (State, error) {
+	var state State
+	if err := statefile.Load(stateFile(stateDir, uid), &state); err != nil {
+		return State{}, err
+	}
+	return state, nil
+}
+
+// RemoveState removes the state of the experiment, it succeeds if the state is not saved
+func RemoveState(stateDir, uid string) error {
+	return statefile.Remove(stateFile(stateDir, uid))
+}
+
+func stateFile(stateDir, uid string) string {
+	return statefile.Path(stateDir, "netif", uid)
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netif
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseRoutes(t *testing.T) {
+	output := `default via 169.254.1.1 
+169.254.1.1 scope link linkdown
+--
+fe80::/64 proto kernel metric 256 expires 86398sec pref medium
+default via fe80::1 metric 1024 pref medium
+default proto ra metric 1024
+	nexthop via fe80::2 weight 1
+`
+	expected := []Route{
+		{Family: 4, Route: "169.254.1.1 scope link"},
+		{Family: 6, Route: "fe80::/64 proto kernel metric 256 pref medium"},
+		{Family: 6, Route: "default proto ra metric 1024"},
+		{Family: 4, Route: "default via 169.254.1.1"},
+		{Family: 6, Route: "default via fe80::1 metric 1024 pref medium"},
+	}
+	if routes := ParseRoutes(output); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, routes)
+	}
+}
+
+func TestScripts(t *testing.T) {
+	routes := []Route{{Family: 4, Route: "169.254.1.1 scope link"}, {Family: 4, Route: "default via 169.254.1.1"}}
+	tests := []struct {
+		mode    string
+		down    string
+		restore string
+	}{
+		{ModeLink, "ip link set dev eth0 down",
+			"ip link set dev eth0 up; ip -4 route replace 169.254.1.1 scope link dev eth0 2>/dev/null; " +
+				"ip -4 route replace default via 169.254.1.1 dev eth0 2>/dev/null"},
+		{ModeNetem, "tc qdisc add dev eth0 root handle 1bad: netem loss 100%",
+			"tc qdisc del dev eth0 root handle 1bad: 2>/dev/null; true"},
+	}
+	for _, test := range tests {
+		if down, err := DownScript("eth0", test.mode); err != nil || down != test.down {
+			t.Errorf("expected the down script %q of %s, got %q, %v", test.down, test.mode, down, err)
+		}
+		if restore, err := RestoreScript("eth0", test.mode, routes); err != nil || restore != test.restore {
+			t.Errorf("expected the restore script %q of %s, got %q, %v", test.restore, test.mode, restore, err)
+		}
+	}
+	if _, err := DownScript("eth0", "carrier"); err == nil {
+		t.Error("expected error of the unsupported mode")
+	}
+	if script := RoutesScript("eth0; reboot"); !strings.Contains(script, "'eth0; reboot'") {
+		t.Errorf("expected the quoted interface, got %s", script)
+	}
+}
+
+func TestParseMTU(t *testing.T) {
+	output := "2: eth0@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1450 qdisc noqueue state UP mode DEFAULT group default " +
+		"\\    link/ether 42:01:0a:f4:00:05 brd ff:ff:ff:ff:ff:ff link-netnsid 0\n"
+	if mtu, err := ParseMTU(output); err != nil || mtu != 1450 {
+		t.Errorf("expected 1450, got %d, %v", mtu, err)
+	}
+	if _, err := ParseMTU("Device \"eth1\" does not exist."); err == nil {
+		t.Error("expected error without the mtu")
+	}
+	if script := MTUScript("eth0", 1280); script != "ip link set dev eth0 mtu 1280" {
+		t.Errorf("unexpected mtu script %s", script)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	if device := MirrorDevice("0123456789abcdef"); device != "cbm0123456789ab" {
+		t.Errorf("unexpected device %s", device)
+	}
+	if !HasClsact("qdisc noqueue 0: root refcnt 2\nqdisc clsact ffff: parent ffff:fff1\n") || HasClsact("qdisc noqueue 0: root") {
+		t.Error("unexpected clsact detection")
+	}
+	mirror := Mirror{Interface: "eth0", Device: "cbm1", Tunnel: TunnelGretap, Collector: "10.0.0.9"}
+	expected := "ip link add dev cbm1 type gretap remote 10.0.0.9 && ip link set dev cbm1 up && " +
+		"tc qdisc add dev eth0 clsact && " +
+		"tc filter add dev eth0 egress pref 49151 protocol ip flower dst_ip 10.0.0.9 action pass && " +
+		"tc filter add dev eth0 egress pref 49152 matchall action mirred egress mirror dev cbm1"
+	if script, err := mirror.Script(true); err != nil || script != expected {
+		t.Errorf("expected %s, got %s, %v", expected, script, err)
+	}
+	expected = "tc filter del dev eth0 egress pref 49152 2>/dev/null; tc filter del dev eth0 egress pref 49151 2>/dev/null; " +
+		"ip link del dev cbm1 2>/dev/null; true"
+	if script := mirror.RestoreScript(false); script != expected {
+		t.Errorf("expected %s, got %s", expected, script)
+	}
+
+	mirror = Mirror{Interface: "eth0", Device: "cbm1", Tunnel: TunnelVxlan, Collector: "fd00::9", VNI: 7, Port: 4789,
+		Handle: 0x2a}
+	script, err := mirror.Script(false)
+	if err != nil || !strings.HasPrefix(script, "ip link add dev cbm1 type vxlan id 7 remote fd00::9 dstport 4789 && ") ||
+		!strings.Contains(script, "pref 49151 handle 0x2a protocol ipv6 flower dst_ip fd00::9") ||
+		!strings.Contains(script, "pref 49152 handle 0x2a matchall") || strings.Contains(script, "clsact") {
+		t.Errorf("unexpected vxlan script %s, %v", script, err)
+	}
+	for _, illegal := range []Mirror{{Tunnel: TunnelGretap, Collector: "collector"}, {Tunnel: "ipip", Collector: "10.0.0.9"}} {
+		if _, err := illegal.Script(false); err == nil {
+			t.Errorf("expected error of %+v", illegal)
+		}
+	}
+}
+
+func runFlap(t *testing.T, flap Flap, events string) *exec.Cmd {
+	t.Helper()
+	flap.Down = "echo down >> " + events
+	flap.Restore = "echo up >> " + events
+	command := exec.Command("/bin/sh", "-c", flap.Script())
+	if err := command.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return command
+}
+
+func readEvents(t *testing.T, events string) string {
+	t.Helper()
+	content, err := os.ReadFile(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(strings.Fields(string(content)), ",")
+}
+
+func TestFlapTimeout(t *testing.T) {
+	events := path.Join(t.TempDir(), "events")
+	command := runFlap(t, Flap{UpInterval: 0, DownInterval: time.Second, Timeout: time.Second}, events)
+	if err := command.Wait(); err != nil {
+		t.Fatalf("flap failed, %v", err)
+	}
+	if actual := readEvents(t, events); actual != "down,up,up" {
+		t.Errorf("expected down,up,up, got %s", actual)
+	}
+}
+
+func TestFlapTerminated(t *testing.T) {
+	events := path.Join(t.TempDir(), "events")
+	command := runFlap(t, Flap{UpInterval: time.Minute, DownInterval: time.Minute}, events)
+	time.Sleep(300 * time.Millisecond)
+	if err := command.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- command.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("flap failed, %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		command.Process.Kill()
+		t.Fatal("flap is not terminated")
+	}
+	if actual := readEvents(t, events); actual != "down,up" {
+		t.Errorf("expected down,up, got %s", actual)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netif
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+)
+
+// The transport protocols of the protocol scoped netem
+const (
+	ProtocolUDP = "udp"
+	ProtocolTCP = "tcp"
+)
+
+// protocolHandle is the handle of the root prio qdisc of the protocol scoped netem
+const protocolHandle = "1baf:"
+
+// portPattern is a port or a range of ports of the flower filters
+var portPattern = regexp.MustCompile(`^[0-9]{1,5}(-[0-9]{1,5})?$`)
+
+// ProtocolNetem impacts the packets of the transport protocol leaving the interface by the netem under a prio qdisc,
+// they are classified by the flower filters, so the other protocols, such as the tcp control traffic of a pod
+// serving quic, are not perturbed
+type ProtocolNetem struct {
+	Interface string
+	Protocol  string
+	// Netem are the arguments of the netem, such as delay 100ms
+	Netem string
+	// Families are ip or ipv6, the families the filters match
+	Families []string
+	// LocalPorts and RemotePorts are the source and the destination ports or ranges, such as 5000-5100, any if empty
+	LocalPorts  []string
+	RemotePorts []string
+	// DestinationIPs are the destination addresses or cidrs of both families, any if empty
+	DestinationIPs []string
+	// Ingress matches the packets received by the interface, the ifb device of the Ingress, so the local ports are
+	// the destination ports, and the remote ports and the addresses are the sources
+	Ingress bool
+}
+
+// filterMatches returns the flower matches of the family, the cross product of the ports and the addresses
+func (p ProtocolNetem) filterMatches(family string) []string {
+	matches := []string{"ip_proto " + p.Protocol}
+	product := func(keyword string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		extended := make([]string, 0, len(matches)*len(values))
+		for _, match := range matches {
+			for _, value := range values {
+				extended = append(extended, fmt.Sprintf("%s %s %s", match, keyword, value))
+			}
+		}
+		matches = extended
+	}
+	local, remote, address := "src_port", "dst_port", "dst_ip"
+	if p.Ingress {
+		local, remote, address = "dst_port", "src_port", "src_ip"
+	}
+	product(local, p.LocalPorts)
+	product(remote, p.RemotePorts)
+	if len(p.DestinationIPs) > 0 {
+		addresses := make([]string, 0)
+		for _, address := range p.DestinationIPs {
+			if isIPv6(address) == (family == "ipv6") {
+				addresses = append(addresses, address)
+			}
+		}
+		if len(addresses) == 0 {
+			return nil
+		}
+		product(address, addresses)
+	}
+	return matches
+}
+
+func isIPv6(address string) bool {
+	if ip, _, err := net.ParseCIDR(address); err == nil {
+		return ip.To4() == nil
+	}
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() == nil
+}
+
+// Validate checks the protocol, the ports and the addresses, the values are written into the script
+func (p ProtocolNetem) Validate() error {
+	if p.Protocol != ProtocolUDP && p.Protocol != ProtocolTCP {
+		return fmt.Errorf("unsupported protocol %s, it must be %s or %s", p.Protocol, ProtocolUDP, ProtocolTCP)
+	}
+	for _, port := range append(append([]string{}, p.LocalPorts...), p.RemotePorts...) {
+		if !portPattern.MatchString(port) {
+			return fmt.Errorf("illegal port %s, it must be a port or a range such as 5000-5100", port)
+		}
+	}
+	for _, address := range p.DestinationIPs {
+		if _, _, err := net.ParseCIDR(address); err != nil && net.ParseIP(address) == nil {
+			return fmt.Errorf("illegal address %s", address)
+		}
+	}
+	return nil
+}
+
+// Script adds the prio qdisc, the netem of its fourth band and the filters of the families classifying the packets
+// into it, the default priomap of the three bands never selects it
+func (p ProtocolNetem) Script() (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	iface := nsexec.Quote(p.Interface)
+	flowid := protocolHandle + "4"
+	commands := []string{
+		fmt.Sprintf("tc qdisc add dev %s root handle %s prio bands 4 priomap 1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1", iface,
+			protocolHandle),
+		fmt.Sprintf("tc qdisc add dev %s parent %s netem %s", iface, flowid, p.Netem),
+	}
+	filters := 0
+	for _, family := range p.Families {
+		for _, match := range p.filterMatches(family) {
+			commands = append(commands, fmt.Sprintf("tc filter add dev %s parent %s protocol %s prio 1 flower %s "+
+				"classid %s", iface, protocolHandle, family, match, flowid))
+			filters++
+		}
+	}
+	if filters == 0 {
+		return "", fmt.Errorf("no destination address of the families %s", strings.Join(p.Families, ", "))
+	}
+	return strings.Join(commands, " && "), nil
+}
+
+// RestoreScript deletes the prio qdisc, its netem and filters are deleted with it
+func (p ProtocolNetem) RestoreScript() string {
+	return fmt.Sprintf("tc qdisc del dev %s root handle %s 2>/dev/null; true", nsexec.Quote(p.Interface), protocolHandle)
+}
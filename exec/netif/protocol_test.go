@@ -0,0 +1,74 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netif
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProtocolNetem(t *testing.T) {
+	scoped := ProtocolNetem{Interface: "eth0", Protocol: ProtocolUDP, Netem: "loss 30%", Families: []string{"ip", "ipv6"},
+		LocalPorts: []string{"4433"}, RemotePorts: []string{"53", "5000-5100"}}
+	script, err := scoped.Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := strings.Split(script, " && ")
+	expected := []string{
+		"tc qdisc add dev eth0 root handle 1baf: prio bands 4 priomap 1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1",
+		"tc qdisc add dev eth0 parent 1baf:4 netem loss 30%",
+		"tc filter add dev eth0 parent 1baf: protocol ip prio 1 flower ip_proto udp src_port 4433 dst_port 53 classid 1baf:4",
+		"tc filter add dev eth0 parent 1baf: protocol ip prio 1 flower ip_proto udp src_port 4433 dst_port 5000-5100 classid 1baf:4",
+		"tc filter add dev eth0 parent 1baf: protocol ipv6 prio 1 flower ip_proto udp src_port 4433 dst_port 53 classid 1baf:4",
+		"tc filter add dev eth0 parent 1baf: protocol ipv6 prio 1 flower ip_proto udp src_port 4433 dst_port 5000-5100 classid 1baf:4",
+	}
+	if strings.Join(commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, commands)
+	}
+	if restore := scoped.RestoreScript(); restore != "tc qdisc del dev eth0 root handle 1baf: 2>/dev/null; true" {
+		t.Errorf("unexpected restore script %s", restore)
+	}
+}
+
+func TestProtocolNetemAddresses(t *testing.T) {
+	scoped := ProtocolNetem{Interface: "eth0", Protocol: ProtocolTCP, Netem: "delay 10ms", Families: []string{"ip", "ipv6"},
+		DestinationIPs: []string{"10.0.0.0/8", "fd00::1"}}
+	script, err := scoped.Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(script, "protocol ip prio 1 flower ip_proto tcp dst_ip 10.0.0.0/8 classid") ||
+		!strings.Contains(script, "protocol ipv6 prio 1 flower ip_proto tcp dst_ip fd00::1 classid") ||
+		strings.Count(script, "tc filter") != 2 {
+		t.Errorf("unexpected script %s", script)
+	}
+	scoped.Families = []string{"ip"}
+	scoped.DestinationIPs = []string{"fd00::1"}
+	if _, err := scoped.Script(); err == nil {
+		t.Error("expected no filter of the ipv4 family refused")
+	}
+	for _, illegal := range []ProtocolNetem{
+		{Protocol: "icmp", Families: []string{"ip"}},
+		{Protocol: ProtocolUDP, Families: []string{"ip"}, LocalPorts: []string{"80;true"}},
+		{Protocol: ProtocolUDP, Families: []string{"ip"}, DestinationIPs: []string{"host"}},
+	} {
+		if err := illegal.Validate(); err == nil {
+			t.Errorf("expected %+v invalid", illegal)
+		}
+	}
+}
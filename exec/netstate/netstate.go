@@ -0,0 +1,163 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package netstate snapshots the network state of the network namespace of the pod, the qdiscs, the tc filters and
+// the firewall rulesets, before the network experiments change it, and finds the residue left after the destroy by
+// comparing the snapshots. The counters and the statistics are removed, so only the configuration is compared.
+package netstate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The sections of the snapshot
+const (
+	SectionQdiscs    = "qdiscs"
+	SectionFilters   = "filters"
+	SectionIptables  = "iptables"
+	SectionIp6tables = "ip6tables"
+	SectionNft       = "nft"
+)
+
+// sectionMarker starts a section in the output of the Script
+const sectionMarker = "### "
+
+// Snapshot is the normalized lines of the sections
+type Snapshot map[string][]string
+
+// Script dumps the sections of the network namespace, the missing tools dump empty sections
+func Script() string {
+	return strings.Join([]string{
+		"echo '" + sectionMarker + SectionQdiscs + "'",
+		"tc -s qdisc show 2>/dev/null",
+		"echo '" + sectionMarker + SectionFilters + "'",
+		"for dev in $(ip -o link show | cut -d: -f2 | cut -d@ -f1); do " +
+			"for parent in root ingress egress; do tc filter show dev $dev $parent 2>/dev/null | sed \"s/^/$dev $parent: /\"; done; done",
+		"echo '" + sectionMarker + SectionIptables + "'",
+		"iptables-save 2>/dev/null",
+		"echo '" + sectionMarker + SectionIp6tables + "'",
+		"ip6tables-save 2>/dev/null",
+		"echo '" + sectionMarker + SectionNft + "'",
+		"nft list ruleset 2>/dev/null",
+		"true",
+	}, "; ")
+}
+
+var (
+	// iptablesCounters are the packet and byte counters of the chains and the rules of iptables-save
+	iptablesCounters = regexp.MustCompile(`\[\d+:\d+\]`)
+	// nftCounters are the counters of the nft rules
+	nftCounters = regexp.MustCompile(`counter packets \d+ bytes \d+`)
+)
+
+// Parse parses the output of the Script, the statistics of the qdiscs, the counters and the comments are removed
+func Parse(output string) Snapshot {
+	snapshot := make(Snapshot)
+	section := ""
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, sectionMarker) {
+			section = strings.TrimPrefix(line, sectionMarker)
+			snapshot[section] = make([]string, 0)
+			continue
+		}
+		if section == "" || strings.TrimSpace(line) == "" {
+			continue
+		}
+		switch section {
+		case SectionQdiscs:
+			// the statistics are indented below the qdisc
+			if line[0] == ' ' || line[0] == '\t' {
+				continue
+			}
+		case SectionIptables, SectionIp6tables:
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			line = iptablesCounters.ReplaceAllString(line, "")
+		case SectionNft:
+			line = nftCounters.ReplaceAllString(line, "counter")
+		}
+		snapshot[section] = append(snapshot[section], strings.TrimSpace(line))
+	}
+	return snapshot
+}
+
+// Residue is the difference of a section after the destroy
+type Residue struct {
+	Section string `json:"section"`
+	// Added are the lines not in the snapshot before, such as a rule not deleted
+	Added []string `json:"added,omitempty"`
+	// Removed are the lines of the snapshot before missing, such as a qdisc of the CNI replaced
+	Removed []string `json:"removed,omitempty"`
+}
+
+func (r Residue) String() string {
+	return fmt.Sprintf("%s: %d added, %d removed", r.Section, len(r.Added), len(r.Removed))
+}
+
+// Diff returns the residue of the sections of the snapshot before, the sections missing in either snapshot are not
+// compared since the tool is not available
+func Diff(before, after Snapshot) []Residue {
+	sections := make([]string, 0, len(before))
+	for section := range before {
+		if _, ok := after[section]; ok {
+			sections = append(sections, section)
+		}
+	}
+	sort.Strings(sections)
+	residue := make([]Residue, 0)
+	for _, section := range sections {
+		added, removed := diffLines(before[section], after[section])
+		if len(added) > 0 || len(removed) > 0 {
+			residue = append(residue, Residue{Section: section, Added: added, Removed: removed})
+		}
+	}
+	return residue
+}
+
+// diffLines compares the lines as multisets, the order of the lines is not compared
+func diffLines(before, after []string) ([]string, []string) {
+	counts := make(map[string]int, len(before))
+	for _, line := range before {
+		counts[line]++
+	}
+	added := make([]string, 0)
+	for _, line := range after {
+		if counts[line] > 0 {
+			counts[line]--
+			continue
+		}
+		added = append(added, line)
+	}
+	removed := make([]string, 0)
+	for _, line := range before {
+		if counts[line] > 0 {
+			counts[line]--
+			removed = append(removed, line)
+		}
+	}
+	return nilIfEmpty(added), nilIfEmpty(removed)
+}
+
+func nilIfEmpty(lines []string) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+	return lines
+}
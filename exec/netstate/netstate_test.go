@@ -0,0 +1,66 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package netstate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAndDiff(t *testing.T) {
+	before := Parse(`### qdiscs
+qdisc noqueue 0: dev eth0 root refcnt 2
+ Sent 1024 bytes 10 pkt (dropped 0, overlimits 0 requeues 0)
+ backlog 0b 0p requeues 0
+### filters
+### iptables
+# Generated by iptables-save v1.8.7 on Mon Jan  1 00:00:00 2024
+*filter
+:INPUT ACCEPT [10:1024]
+COMMIT
+### nft
+table inet filter {
+	chain input {
+		tcp dport 22 counter packets 3 bytes 180 accept
+	}
+}
+`)
+	after := Parse(`### qdiscs
+qdisc netem 8001: dev eth0 root refcnt 2 limit 1000 delay 100ms
+ Sent 2048 bytes 20 pkt (dropped 0, overlimits 0 requeues 0)
+### filters
+### iptables
+# Generated by iptables-save v1.8.7 on Mon Jan  1 00:01:00 2024
+*filter
+:INPUT ACCEPT [20:2048]
+COMMIT
+### nft
+table inet filter {
+	chain input {
+		tcp dport 22 counter packets 9 bytes 540 accept
+	}
+}
+`)
+	expected := []Residue{{Section: SectionQdiscs, Added: []string{"qdisc netem 8001: dev eth0 root refcnt 2 limit 1000 delay 100ms"},
+		Removed: []string{"qdisc noqueue 0: dev eth0 root refcnt 2"}}}
+	if residue := Diff(before, after); !reflect.DeepEqual(residue, expected) {
+		t.Errorf("expected %+v, got %+v", expected, residue)
+	}
+	if residue := Diff(before, Parse("### qdiscs\nqdisc noqueue 0: dev eth0 root refcnt 2\n")); len(residue) != 0 {
+		t.Errorf("expected the sections missing after not compared, got %+v", residue)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/node"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+// serviceIpFlags are the address flags of the network experiments matching the destination of the packets, the
+// service ips of them never match under the providers translating the services at the connect
+var serviceIpFlags = []string{"destination-ip", "exclude-ip"}
+
+// detectCNI returns the cni provider of the node, it's replaced by the tests
+var detectCNI = func() *node.CNIProvider {
+	return node.Collect(context.Background(), nil).CNIProvider
+}
+
+// checkCNI warns the address flags of the network experiment the cni provider of the node handles differently, the
+// experiment is still executed since the addresses may be the pod ips
+func checkCNI(ctx context.Context, flags map[string]string) {
+	addresses := make([]string, 0)
+	for _, flag := range serviceIpFlags {
+		if flags[flag] != "" {
+			addresses = append(addresses, fmt.Sprintf("--%s %s", flag, flags[flag]))
+		}
+	}
+	if len(addresses) == 0 {
+		return
+	}
+	if provider := detectCNI(); provider.TranslatesServices() {
+		warning.Add(ctx, "CNI", "%s translates the service ips at the connect, the packets leave the pod with the "+
+			"pod ips of the endpoints, so the service ips of %v never match, use the pod ips instead", provider, addresses)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/node"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+func TestCheckCNI(t *testing.T) {
+	provider, original := &node.CNIProvider{Name: node.CNICilium}, detectCNI
+	detectCNI = func() *node.CNIProvider { return provider }
+	t.Cleanup(func() { detectCNI = original })
+	ctx, collector := warning.WithCollector(context.Background())
+	checkCNI(ctx, map[string]string{"destination-ip": "10.96.0.10", "remote-port": "53"})
+	warnings := collector.List()
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "--destination-ip 10.96.0.10") ||
+		!strings.Contains(warnings[0].Message, "cilium without kube-proxy") {
+		t.Fatalf("expected the service ips warned, got %+v", warnings)
+	}
+	// kube-proxy translates the service ips after the packets leave the pod
+	provider.KubeProxy = true
+	checkCNI(ctx, map[string]string{"destination-ip": "10.96.0.10"})
+	provider.KubeProxy = false
+	checkCNI(ctx, map[string]string{"remote-port": "53"})
+	if warnings := collector.List(); len(warnings) != 1 {
+		t.Errorf("expected no more warning, got %+v", warnings)
+	}
+}
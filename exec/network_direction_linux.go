@@ -0,0 +1,123 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netif"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/qdisc"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rollback"
+)
+
+// execDirectionNetwork executes the tc action on the ingress of the interface, or on both the ingress and the egress,
+// instead of chaos_os. The ingress packets are redirected to an ifb device and impacted by its netem, the egress ones
+// by the netem of the interface, scoped by the protocol flag if given. The restore script is saved like the interface
+// experiments and executed by the destroy
+func execDirectionNetwork(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, family string,
+	isDestroy bool) *spec.Response {
+	direction := expModel.ActionFlags[DirectionFlag.Name]
+	if direction != netif.DirectionIngress && direction != netif.DirectionBoth {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, DirectionFlag.Name, direction,
+			fmt.Sprintf("it must be %s, %s or %s", netif.DirectionEgress, netif.DirectionIngress, netif.DirectionBoth))
+	}
+	if isDestroy {
+		return restoreInterface(ctx, uid, expModel)
+	}
+	flags := chaosOsFlags(expModel)
+	ingress := netif.Ingress{Interface: flags["interface"], Device: netif.IngressDevice(uid)}
+	var ingressNetem, egressNetem, egressRestore string
+	if flags[ProtocolFlag.Name] != "" {
+		scoped, response := protocolNetem(expModel, flags, family)
+		if response != nil {
+			return response
+		}
+		egress := scoped
+		scoped.Interface, scoped.Ingress = ingress.Device, true
+		script, err := scoped.Script()
+		if err != nil {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, ProtocolFlag.Name, flags[ProtocolFlag.Name], err)
+		}
+		ingressNetem = script
+		if direction == netif.DirectionBoth {
+			egressNetem, _ = egress.Script()
+			egressRestore = egress.RestoreScript()
+		}
+	} else {
+		// the filters are added by chaos_os on the egress only, the protocol flag scopes both directions
+		for _, name := range tcFilterFlags {
+			if flags[name] != "" {
+				return spec.ResponseFailWithFlags(spec.ParameterInvalid, name, flags[name],
+					fmt.Sprintf("the filters of the %s direction require the protocol flag", direction))
+			}
+		}
+		if ingress.Interface == "" {
+			return spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
+		}
+		netem, err := qdisc.NetemArgs(expModel.ActionName, flags)
+		if err != nil {
+			return spec.ReturnFail(spec.ParameterLess, err.Error())
+		}
+		ingressNetem = netif.NetemScript(ingress.Device, netem)
+		if direction == netif.DirectionBoth {
+			egressNetem = netif.NetemScript(ingress.Interface, netem)
+			egressRestore = netif.NetemRestoreScript(ingress.Interface)
+		}
+	}
+	output, err := runInNetns(ctx, pid, qdisc.ShowScript(ingress.Interface))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "interface", ingress.Interface, err)
+	}
+	// the root qdisc is replaced by the egress netem only, the ingress is shaped by the ifb device
+	if egressNetem != "" {
+		if conflicts := qdisc.Inspect(qdisc.Parse(output)); len(conflicts) > 0 {
+			return spec.ReturnFail(spec.OsCmdExecFailed, qdisc.Report(ingress.Interface, conflicts))
+		}
+	}
+	// the clsact of the eBPF datapaths, such as Cilium, is kept by the restore
+	addClsact := !netif.HasClsact(output)
+	state := netif.State{Uid: uid, Interface: ingress.Interface, Restore: ingress.RestoreScript(addClsact)}
+	if egressRestore != "" {
+		state.Restore = egressRestore + "; " + state.Restore
+	}
+	steps := rollback.New(ctx, uid)
+	if err := steps.Run("save state", func() error { return netif.SaveState(util.GetProgramPath(), state) },
+		func() error { return netif.RemoveState(util.GetProgramPath(), uid) }); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	// the ifb device and the netem added before the failed command are deleted too
+	steps.Done("direction netem", func() error {
+		_, err := runInNetns(ctx, pid, state.Restore)
+		return err
+	})
+	if _, err := runInNetns(ctx, pid, ingress.Script(addClsact, ingressNetem)); err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err))
+	}
+	if egressNetem != "" {
+		if _, err := runInNetns(ctx, pid, egressNetem); err != nil {
+			return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err))
+		}
+	}
+	log.Infof(ctx, "the %s of the %s direction on %s is executed by the ifb device %s", expModel.ActionName,
+		direction, ingress.Interface, ingress.Device)
+	return spec.ReturnSuccess(uid)
+}
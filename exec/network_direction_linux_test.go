@@ -0,0 +1,87 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netif"
+)
+
+func TestNetworkDirection(t *testing.T) {
+	scripts := fakeNetns(t, "qdisc noqueue 0: root refcnt 2\n")
+	defer netif.RemoveState(util.GetProgramPath(), "uid1")
+	for _, flags := range []map[string]string{
+		{DirectionFlag.Name: "inbound", "interface": "eth0", "percent": "50"},
+		{DirectionFlag.Name: "ingress", "interface": "eth0", "percent": "50", "remote-port": "443"},
+		{DirectionFlag.Name: "ingress", "percent": "50"},
+	} {
+		if response := runNetwork(context.Background(), "loss", flags); response.Success {
+			t.Errorf("expected the flags %v refused", flags)
+		}
+	}
+	for _, script := range *scripts {
+		if strings.Contains(script, "ifb") {
+			t.Fatalf("expected nothing added, got %q", *scripts)
+		}
+	}
+
+	*scripts = (*scripts)[:0]
+	flags := map[string]string{DirectionFlag.Name: "both", "interface": "eth0", "time": "100"}
+	if response := runNetwork(context.Background(), "delay", flags); !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	ingress := netif.Ingress{Interface: "eth0", Device: netif.IngressDevice("uid1")}
+	expected := []string{
+		ingress.Script(true, netif.NetemScript(ingress.Device, "delay 100ms")),
+		netif.NetemScript("eth0", "delay 100ms"),
+	}
+	if got := (*scripts)[len(*scripts)-2:]; got[0] != expected[0] || got[1] != expected[1] {
+		t.Errorf("expected %q, got %q", expected, *scripts)
+	}
+	if response := runNetwork(spec.SetDestroyFlag(context.Background(), "uid1"), "delay", flags); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	restore := netif.NetemRestoreScript("eth0") + "; " + ingress.RestoreScript(true)
+	if got := (*scripts)[len(*scripts)-1]; got != restore {
+		t.Errorf("expected %s, got %s", restore, got)
+	}
+}
+
+func TestNetworkDirectionProtocol(t *testing.T) {
+	scripts := fakeNetns(t, "qdisc noqueue 0: root refcnt 2\nqdisc clsact ffff: parent ffff:fff1\n")
+	defer netif.RemoveState(util.GetProgramPath(), "uid1")
+	flags := map[string]string{DirectionFlag.Name: "ingress", ProtocolFlag.Name: "tcp", "interface": "eth0",
+		"percent": "10", "local-port": "8080"}
+	if response := runNetwork(context.Background(), "loss", flags); !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	found := false
+	for _, script := range *scripts {
+		if strings.Contains(script, "type ifb") {
+			found = strings.Contains(script, "dst_port 8080") && !strings.Contains(script, "add dev eth0 clsact")
+		}
+	}
+	if !found {
+		t.Errorf("expected the ingress matches with the clsact kept, got %q", *scripts)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The address families of the network experiments
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+	FamilyDual = "dual"
+)
+
+// tcActions are the network actions of chaos_os by tc, the filters of the ports and the addresses match ipv4 only
+var tcActions = map[string]bool{"delay": true, "loss": true, "duplicate": true, "corrupt": true, "reorder": true}
+
+// networkIpFlags are the flags of the network actions taking the comma separated addresses or cidrs
+var networkIpFlags = []string{"source-ip", "destination-ip", "exclude-ip"}
+
+// addressFamily returns the address family of the network experiment, it is inferred from the addresses of the ip
+// flags if the address-family flag is empty
+func addressFamily(flags map[string]string) (string, error) {
+	hasIPv4, hasIPv6 := false, false
+	for _, name := range networkIpFlags {
+		ipv4, ipv6 := splitByFamily(flags[name])
+		hasIPv4 = hasIPv4 || ipv4 != ""
+		hasIPv6 = hasIPv6 || ipv6 != ""
+	}
+	family := flags[AddressFamilyFlag.Name]
+	switch family {
+	case "":
+		if hasIPv4 && hasIPv6 {
+			return FamilyDual, nil
+		}
+		if hasIPv6 {
+			return FamilyIPv6, nil
+		}
+		return FamilyIPv4, nil
+	case FamilyIPv4:
+		if hasIPv6 {
+			return "", fmt.Errorf("the ipv6 addresses are not allowed by the ipv4 family")
+		}
+	case FamilyIPv6:
+		if hasIPv4 {
+			return "", fmt.Errorf("the ipv4 addresses are not allowed by the ipv6 family")
+		}
+	case FamilyDual:
+	default:
+		return "", fmt.Errorf("unsupported address family, it must be %s, %s or %s", FamilyIPv4, FamilyIPv6, FamilyDual)
+	}
+	return family, nil
+}
+
+// splitByFamily splits the comma separated addresses or cidrs by the family, the illegal addresses are kept in the
+// ipv4 ones so they are reported by chaos_os
+func splitByFamily(addresses string) (string, string) {
+	ipv4, ipv6 := make([]string, 0), make([]string, 0)
+	for _, address := range strings.Split(addresses, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		host := address
+		if i := strings.Index(address, "/"); i >= 0 {
+			host = address[:i]
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			ipv6 = append(ipv6, address)
+		} else {
+			ipv4 = append(ipv4, address)
+		}
+	}
+	return strings.Join(ipv4, ","), strings.Join(ipv6, ",")
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/firewall"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+)
+
+// tcFilterFlags are the flags of the tc actions adding the filters
+var tcFilterFlags = []string{"local-port", "remote-port", "exclude-port", "destination-ip", "exclude-ip"}
+
+// dropIpFlags are the ip flags of the drop action
+var dropIpFlags = []string{"source-ip", "destination-ip"}
+
+// execByFamily executes the network action of the ipv6 or the dual family, and the drop action of every family. The
+// drop action adds the ip6tables rules of the ipv6 addresses besides the iptables rules of chaos_os, the tc actions
+// are executed for the whole interface of the dual family only
+func execByFamily(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, family string,
+	isDestroy bool) *spec.Response {
+	flags := chaosOsFlags(expModel)
+	if expModel.ActionName == "drop" {
+		return execDropByFamily(ctx, uid, expModel, pid, family, flags, isDestroy)
+	}
+	if !tcActions[expModel.ActionName] {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, AddressFamilyFlag.Name, family,
+			fmt.Sprintf("the %s action supports the ipv4 family only", expModel.ActionName))
+	}
+	for _, name := range tcFilterFlags {
+		if flags[name] != "" {
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, name, flags[name],
+				"the tc filters match ipv4 only, the ipv6 traffic is impacted by the whole interface of the dual family only")
+		}
+	}
+	if family != FamilyDual {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, AddressFamilyFlag.Name, family,
+			"the qdisc of the whole interface impacts both families, use the dual family")
+	}
+	return execTcNetwork(ctx, uid, expModel, pid, flags, isDestroy)
+}
+
+// execDropByFamily drops the packets of each family whose addresses are given by every ip flag set, the ipv4 ones by
+// chaos_os and the ipv6 ones by ip6tables, or both by nft if the namespace uses the nft backend
+func execDropByFamily(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, family string,
+	flags map[string]string, isDestroy bool) *spec.Response {
+	ipv4Flags, ipv6Flags := copyFlags(flags), copyFlags(flags)
+	withIPv4, withIPv6 := family != FamilyIPv6, family != FamilyIPv4
+	for _, name := range dropIpFlags {
+		if flags[name] == "" {
+			continue
+		}
+		ipv4Flags[name], ipv6Flags[name] = splitByFamily(flags[name])
+		withIPv4 = withIPv4 && ipv4Flags[name] != ""
+		withIPv6 = withIPv6 && ipv6Flags[name] != ""
+	}
+	if !withIPv4 && !withIPv6 {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "source-ip", flags["source-ip"],
+			"the source and the destination addresses are of different families")
+	}
+	if !isDestroy && withIPv6 && !hasDropMatch(ipv6Flags) {
+		return spec.ReturnFail(spec.OsCmdExecFailed, "must specify ip or port or string flag")
+	}
+	backend, err := firewallBackend(ctx, pid, expModel)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, FirewallBackendFlag.Name,
+			expModel.ActionFlags[FirewallBackendFlag.Name], err)
+	}
+	if backend == firewall.BackendNft {
+		return execNftDrop(ctx, uid, pid, withIPv4, withIPv6, ipv4Flags, ipv6Flags, isDestroy)
+	}
+	if isDestroy {
+		var response *spec.Response
+		if withIPv6 {
+			if _, err := runInNetns(ctx, pid, ip6tablesDropScript("-D", uid, ipv6Flags)); err != nil {
+				log.Warnf(ctx, "delete the ip6tables rules of %s failed, %v", uid, err)
+			}
+		}
+		if withIPv4 {
+			response = execChaosOsNetwork(ctx, uid, expModel, pid, ipv4Flags, isDestroy)
+		}
+		if response != nil && !response.Success {
+			return response
+		}
+		return spec.ReturnSuccess(uid)
+	}
+	if withIPv6 {
+		if _, err := runInNetns(ctx, pid, ip6tablesDropScript("-A", uid, ipv6Flags)); err != nil {
+			runInNetns(ctx, pid, ip6tablesDropScript("-D", uid, ipv6Flags))
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "ip6tables", err)
+		}
+	}
+	if withIPv4 {
+		if response := execChaosOsNetwork(ctx, uid, expModel, pid, ipv4Flags, isDestroy); !response.Success {
+			if withIPv6 {
+				runInNetns(ctx, pid, ip6tablesDropScript("-D", uid, ipv6Flags))
+			}
+			return response
+		}
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+// hasDropMatch returns true if the drop flags match some packets rather than all
+func hasDropMatch(flags map[string]string) bool {
+	return flags["source-ip"] != "" || flags["destination-ip"] != "" || flags["source-port"] != "" ||
+		flags["destination-port"] != "" || flags["string-pattern"] != ""
+}
+
+// firewallBackend returns the backend of the firewall-backend flag, or detects the backend of the network namespace
+func firewallBackend(ctx context.Context, pid int32, expModel *spec.ExpModel) (string, error) {
+	backend := expModel.ActionFlags[FirewallBackendFlag.Name]
+	switch backend {
+	case firewall.BackendIptables, firewall.BackendNft:
+		return backend, nil
+	case "", firewall.BackendAuto:
+	default:
+		return "", fmt.Errorf("only support %s, %s and %s", firewall.BackendAuto, firewall.BackendIptables,
+			firewall.BackendNft)
+	}
+	output, err := runInNetns(ctx, pid, firewall.DetectScript())
+	if err != nil {
+		log.Warnf(ctx, "detect the firewall backend failed, use iptables, %v", err)
+		return firewall.BackendIptables, nil
+	}
+	backend, reason := firewall.ParseBackend(output)
+	log.Infof(ctx, "the firewall backend of the network namespace of %d is %s, %s", pid, backend, reason)
+	return backend, nil
+}
+
+// execNftDrop drops the packets of the families by the nft table of the experiment, the destroy deletes the table
+func execNftDrop(ctx context.Context, uid string, pid int32, withIPv4, withIPv6 bool,
+	ipv4Flags, ipv6Flags map[string]string, isDestroy bool) *spec.Response {
+	if isDestroy {
+		if _, err := runInNetns(ctx, pid, firewall.DeleteScript(uid)); err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "nft", err)
+		}
+		return spec.ReturnSuccess(uid)
+	}
+	if pattern := ipv4Flags["string-pattern"]; pattern != "" {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "string-pattern", pattern,
+			"the string match is not supported by the nft backend")
+	}
+	if withIPv4 && !hasDropMatch(ipv4Flags) {
+		return spec.ReturnFail(spec.OsCmdExecFailed, "must specify ip or port or string flag")
+	}
+	rules := make([]firewall.Rule, 0)
+	if withIPv4 {
+		rules = append(rules, dropRule("ip", ipv4Flags))
+	}
+	if withIPv6 {
+		rules = append(rules, dropRule("ip6", ipv6Flags))
+	}
+	script, err := firewall.DropScript(uid, rules)
+	if err != nil {
+		return spec.ReturnFail(spec.ParameterIllegal, err.Error())
+	}
+	if _, err := runInNetns(ctx, pid, script); err != nil {
+		runInNetns(ctx, pid, firewall.DeleteScript(uid))
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "nft", err)
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+func dropRule(family string, flags map[string]string) firewall.Rule {
+	return firewall.Rule{
+		Family:          family,
+		Traffic:         flags["network-traffic"],
+		SourceIP:        flags["source-ip"],
+		DestinationIP:   flags["destination-ip"],
+		SourcePort:      flags["source-port"],
+		DestinationPort: flags["destination-port"],
+	}
+}
+
+// ip6tablesDropScript builds the ip6tables rules of the drop action like chaos_os builds the iptables rules, the
+// rules are appended by -A until one fails, and all rules are deleted by -D ignoring the missing ones. The rules are
+// commented by the uid, so they are traced back to the experiment
+func ip6tablesDropScript(operation, uid string, flags map[string]string) string {
+	chains := []string{"INPUT", "OUTPUT"}
+	switch flags["network-traffic"] {
+	case "in":
+		chains = []string{"INPUT"}
+	case "out":
+		chains = []string{"OUTPUT"}
+	}
+	rules := make([]string, 0)
+	for _, chain := range chains {
+		for _, protocol := range []string{"tcp", "udp"} {
+			args := []string{"ip6tables", operation, chain, "-p", protocol}
+			if ip := flags["source-ip"]; ip != "" {
+				args = append(args, "-s", nsexec.Quote(ip))
+			}
+			if ip := flags["destination-ip"]; ip != "" {
+				args = append(args, "-d", nsexec.Quote(ip))
+			}
+			args = append(args, portArgs("--sport", flags["source-port"])...)
+			args = append(args, portArgs("--dport", flags["destination-port"])...)
+			if pattern := flags["string-pattern"]; pattern != "" {
+				args = append(args, "-m", "string", "--string", nsexec.Quote(pattern), "--algo", "bm")
+			}
+			args = append(args, "-m", "comment", "--comment", trace.Comment(uid), "-j", "DROP")
+			rules = append(rules, strings.Join(args, " "))
+		}
+	}
+	if operation == "-D" {
+		return strings.Join(rules, " 2>/dev/null; ") + " 2>/dev/null; true"
+	}
+	return strings.Join(rules, " && ")
+}
+
+// portArgs returns the match of the port, the comma separated ports are matched by the multiport module
+func portArgs(option, ports string) []string {
+	if ports == "" {
+		return nil
+	}
+	if strings.Contains(ports, ",") {
+		return []string{"-m", "multiport", option + "s", nsexec.Quote(ports)}
+	}
+	return []string{option, nsexec.Quote(ports)}
+}
+
+func copyFlags(flags map[string]string) map[string]string {
+	copied := make(map[string]string, len(flags))
+	for k, v := range flags {
+		copied[k] = v
+	}
+	return copied
+}
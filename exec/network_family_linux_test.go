@@ -0,0 +1,139 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/firewall"
+)
+
+func TestIp6tablesDropScript(t *testing.T) {
+	flags := map[string]string{"destination-ip": "fd00::1", "destination-port": "80,443", "network-traffic": "out"}
+	expected := "ip6tables -A OUTPUT -p tcp -d fd00::1 -m multiport --dports 80,443 -m comment --comment chaosblade:uid1 -j DROP && " +
+		"ip6tables -A OUTPUT -p udp -d fd00::1 -m multiport --dports 80,443 -m comment --comment chaosblade:uid1 -j DROP"
+	if script := ip6tablesDropScript("-A", "uid1", flags); script != expected {
+		t.Errorf("expected %s, got %s", expected, script)
+	}
+	flags = map[string]string{"source-port": "53", "string-pattern": "x y"}
+	expected = "ip6tables -D INPUT -p tcp --sport 53 -m string --string 'x y' --algo bm -m comment --comment chaosblade:uid1 -j DROP 2>/dev/null; " +
+		"ip6tables -D INPUT -p udp --sport 53 -m string --string 'x y' --algo bm -m comment --comment chaosblade:uid1 -j DROP 2>/dev/null; " +
+		"ip6tables -D OUTPUT -p tcp --sport 53 -m string --string 'x y' --algo bm -m comment --comment chaosblade:uid1 -j DROP 2>/dev/null; " +
+		"ip6tables -D OUTPUT -p udp --sport 53 -m string --string 'x y' --algo bm -m comment --comment chaosblade:uid1 -j DROP 2>/dev/null; true"
+	if script := ip6tablesDropScript("-D", "uid1", flags); script != expected {
+		t.Errorf("expected %s, got %s", expected, script)
+	}
+}
+
+func runNetwork(ctx context.Context, action string, flags map[string]string) *spec.Response {
+	flags[ContainerIdFlag.Name] = "c1"
+	model := &spec.ExpModel{Target: "network", ActionName: action, ActionFlags: flags}
+	return NewNetworkExecutor().Exec("uid1", ctx, model)
+}
+
+func TestNetworkDropIPv6(t *testing.T) {
+	scripts := fakeNetns(t, "")
+	response := runNetwork(context.Background(), "drop", map[string]string{"source-ip": "fd00::1", "network-traffic": "in"})
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	response = runNetwork(spec.SetDestroyFlag(context.Background(), "uid1"), "drop",
+		map[string]string{"source-ip": "fd00::1", "network-traffic": "in"})
+	if !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	flags := map[string]string{"source-ip": "fd00::1", "network-traffic": "in"}
+	expected := []string{firewall.DetectScript(), ip6tablesDropScript("-A", "uid1", flags), firewall.DetectScript(),
+		ip6tablesDropScript("-D", "uid1", flags)}
+	if !reflect.DeepEqual(*scripts, expected) {
+		t.Errorf("expected %q, got %q", expected, *scripts)
+	}
+}
+
+func TestNetworkDropNft(t *testing.T) {
+	scripts := fakeNetns(t, "--\nnft\ntable inet firewalld\n")
+	flags := map[string]string{"destination-ip": "10.0.0.1,fd00::1", "destination-port": "80", "network-traffic": "out"}
+	if response := runNetwork(context.Background(), "drop", flags); !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	expected := "nft add table inet chaosblade_uid1 && " +
+		"nft add chain inet chaosblade_uid1 input '{ type filter hook input priority -10; }' && " +
+		"nft add chain inet chaosblade_uid1 output '{ type filter hook output priority -10; }' && " +
+		"nft add rule inet chaosblade_uid1 output meta l4proto '{ tcp, udp }' meta nfproto ipv4 ip daddr '{ 10.0.0.1 }' th dport '{ 80 }' drop comment '\"chaosblade:uid1\"' && " +
+		"nft add rule inet chaosblade_uid1 output meta l4proto '{ tcp, udp }' meta nfproto ipv6 ip6 daddr '{ fd00::1 }' th dport '{ 80 }' drop comment '\"chaosblade:uid1\"'"
+	if len(*scripts) != 2 || (*scripts)[1] != expected {
+		t.Fatalf("expected the nft rules, got %q", *scripts)
+	}
+	if response := runNetwork(spec.SetDestroyFlag(context.Background(), "uid1"), "drop", flags); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if destroy := (*scripts)[len(*scripts)-1]; destroy != firewall.DeleteScript("uid1") {
+		t.Errorf("expected the table deleted, got %s", destroy)
+	}
+
+	flags = map[string]string{"source-port": "53", "string-pattern": "x", FirewallBackendFlag.Name: firewall.BackendNft}
+	if response := runNetwork(context.Background(), "drop", flags); response.Success || response.Code != spec.ParameterInvalid.Code {
+		t.Errorf("expected the string pattern refused by nft, got %+v", response)
+	}
+	if len(*scripts) != 4 {
+		t.Errorf("expected the backend of the flag used without the detection, got %q", *scripts)
+	}
+}
+
+func TestNetworkFamilyInvalid(t *testing.T) {
+	scripts := fakeNetns(t, "")
+	tests := []struct {
+		action string
+		flags  map[string]string
+		code   int32
+	}{
+		{"drop", map[string]string{"source-ip": "10.0.0.1", "destination-ip": "fd00::1"}, spec.ParameterInvalid.Code},
+		{"drop", map[string]string{AddressFamilyFlag.Name: FamilyIPv6}, spec.OsCmdExecFailed.Code},
+		{"delay", map[string]string{"destination-ip": "fd00::1", "time": "100"}, spec.ParameterInvalid.Code},
+		{"delay", map[string]string{AddressFamilyFlag.Name: FamilyIPv6, "time": "100"}, spec.ParameterInvalid.Code},
+		{"flood", map[string]string{AddressFamilyFlag.Name: FamilyDual}, spec.ParameterInvalid.Code},
+		{"loss", map[string]string{AddressFamilyFlag.Name: "inet"}, spec.ParameterIllegal.Code},
+	}
+	for _, test := range tests {
+		if response := runNetwork(context.Background(), test.action, test.flags); response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of %s %v, got %+v", test.code, test.action, test.flags, response)
+		}
+	}
+	if len(*scripts) != 0 {
+		t.Errorf("expected nothing run, got %q", *scripts)
+	}
+}
+
+func TestNetworkSchema(t *testing.T) {
+	scripts := fakeNetns(t, "")
+	executor := GetAllExecutors()[GetExecutorKey("network", "delay")]
+	model := &spec.ExpModel{Target: "network", ActionName: "delay", ActionFlags: map[string]string{
+		ContainerIdFlag.Name: "c1", "interface": "eth0", "time": "120000"}}
+	response := executor.Exec("uid1", context.Background(), model)
+	if response.Success || response.Code != spec.ParameterIllegal.Code ||
+		!strings.Contains(response.Err, "time must be from 1ms to 60s") {
+		t.Errorf("expected the delay refused by the schema, got %+v", response)
+	}
+	if len(*scripts) != 0 {
+		t.Errorf("expected nothing run, got %q", *scripts)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"testing"
+)
+
+func TestSplitByFamily(t *testing.T) {
+	ipv4, ipv6 := splitByFamily("10.0.0.1, fd00::1,10.1.0.0/16,2001:db8::/32,::ffff:10.0.0.2,example")
+	if ipv4 != "10.0.0.1,10.1.0.0/16,::ffff:10.0.0.2,example" || ipv6 != "fd00::1,2001:db8::/32" {
+		t.Errorf("unexpected split %q and %q", ipv4, ipv6)
+	}
+}
+
+func TestAddressFamily(t *testing.T) {
+	tests := []struct {
+		flags  map[string]string
+		family string
+		err    bool
+	}{
+		{map[string]string{}, FamilyIPv4, false},
+		{map[string]string{"destination-ip": "10.0.0.1"}, FamilyIPv4, false},
+		{map[string]string{"destination-ip": "fd00::1"}, FamilyIPv6, false},
+		{map[string]string{"source-ip": "10.0.0.1", "destination-ip": "fd00::1"}, FamilyDual, false},
+		{map[string]string{AddressFamilyFlag.Name: FamilyDual}, FamilyDual, false},
+		{map[string]string{AddressFamilyFlag.Name: FamilyIPv6}, FamilyIPv6, false},
+		{map[string]string{AddressFamilyFlag.Name: FamilyIPv4, "exclude-ip": "fd00::1"}, "", true},
+		{map[string]string{AddressFamilyFlag.Name: FamilyIPv6, "destination-ip": "10.0.0.1"}, "", true},
+		{map[string]string{AddressFamilyFlag.Name: "inet"}, "", true},
+	}
+	for _, test := range tests {
+		family, err := addressFamily(test.flags)
+		if family != test.family || (err != nil) != test.err {
+			t.Errorf("expected %q and error %v of %v, got %q, %v", test.family, test.err, test.flags, family, err)
+		}
+	}
+}
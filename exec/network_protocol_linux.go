@@ -0,0 +1,113 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netif"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/qdisc"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rollback"
+)
+
+// protocolUnsupportedFlags are the flags of the tc actions the protocol scoped netem doesn't support
+var protocolUnsupportedFlags = []string{"exclude-port", "exclude-ip"}
+
+// execProtocolNetwork executes the tc action for the packets of the protocol only by the netem under a prio qdisc,
+// instead of chaos_os. The restore script is saved like the interface experiments and executed by the destroy
+func execProtocolNetwork(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, family string,
+	isDestroy bool) *spec.Response {
+	if isDestroy {
+		return restoreInterface(ctx, uid, expModel)
+	}
+	flags := chaosOsFlags(expModel)
+	scoped, response := protocolNetem(expModel, flags, family)
+	if response != nil {
+		return response
+	}
+	script, err := scoped.Script()
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, ProtocolFlag.Name, flags[ProtocolFlag.Name], err)
+	}
+	output, err := runInNetns(ctx, pid, qdisc.ShowScript(scoped.Interface))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "interface", scoped.Interface, err)
+	}
+	// the root qdisc is replaced by the prio, the netem can't be chained under the shapers
+	if conflicts := qdisc.Inspect(qdisc.Parse(output)); len(conflicts) > 0 {
+		return spec.ReturnFail(spec.OsCmdExecFailed, qdisc.Report(scoped.Interface, conflicts))
+	}
+	state := netif.State{Uid: uid, Interface: scoped.Interface, Restore: scoped.RestoreScript()}
+	steps := rollback.New(ctx, uid)
+	if err := steps.Run("save state", func() error { return netif.SaveState(util.GetProgramPath(), state) },
+		func() error { return netif.RemoveState(util.GetProgramPath(), uid) }); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	steps.Done("protocol netem", func() error {
+		_, err := runInNetns(ctx, pid, state.Restore)
+		return err
+	})
+	if _, err := runInNetns(ctx, pid, script); err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err))
+	}
+	log.Infof(ctx, "the %s of the %s packets on %s is executed by the prio qdisc", expModel.ActionName,
+		scoped.Protocol, scoped.Interface)
+	return spec.ReturnSuccess(uid)
+}
+
+// protocolNetem builds the protocol scoped netem of the interface from the flags of the tc action
+func protocolNetem(expModel *spec.ExpModel, flags map[string]string, family string) (netif.ProtocolNetem,
+	*spec.Response) {
+	for _, name := range protocolUnsupportedFlags {
+		if flags[name] != "" {
+			return netif.ProtocolNetem{}, spec.ResponseFailWithFlags(spec.ParameterInvalid, name, flags[name],
+				"the exclusions are not supported by the protocol flag")
+		}
+	}
+	if flags["interface"] == "" {
+		return netif.ProtocolNetem{}, spec.ResponseFailWithFlags(spec.ParameterLess, "interface")
+	}
+	netem, err := qdisc.NetemArgs(expModel.ActionName, flags)
+	if err != nil {
+		return netif.ProtocolNetem{}, spec.ReturnFail(spec.ParameterLess, err.Error())
+	}
+	scoped := netif.ProtocolNetem{
+		Interface:      flags["interface"],
+		Protocol:       strings.ToLower(flags[ProtocolFlag.Name]),
+		Netem:          netem,
+		Families:       []string{"ip", "ipv6"},
+		LocalPorts:     splitFlag(flags["local-port"]),
+		RemotePorts:    splitFlag(flags["remote-port"]),
+		DestinationIPs: splitFlag(flags["destination-ip"]),
+	}
+	// both families are matched unless the address-family flag is given, the filters skip the addresses of the
+	// other family
+	if expModel.ActionFlags[AddressFamilyFlag.Name] != "" {
+		switch family {
+		case FamilyIPv4:
+			scoped.Families = []string{"ip"}
+		case FamilyIPv6:
+			scoped.Families = []string{"ipv6"}
+		}
+	}
+	return scoped, nil
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netif"
+)
+
+func TestNetworkProtocol(t *testing.T) {
+	scripts := fakeNetns(t, "qdisc noqueue 0: root refcnt 2\n")
+	defer netif.RemoveState(util.GetProgramPath(), "uid1")
+	for _, flags := range []map[string]string{
+		{ProtocolFlag.Name: "sctp", "interface": "eth0", "percent": "50"},
+		{ProtocolFlag.Name: "udp", "interface": "eth0", "percent": "50", "remote-port": "53;reboot"},
+		{ProtocolFlag.Name: "udp", "interface": "eth0", "percent": "50", "exclude-port": "22"},
+		{ProtocolFlag.Name: "udp", "percent": "50"},
+	} {
+		if response := runNetwork(context.Background(), "loss", flags); response.Success {
+			t.Errorf("expected the flags %v refused", flags)
+		}
+	}
+	for _, script := range *scripts {
+		if strings.Contains(script, "prio") {
+			t.Fatalf("expected nothing added, got %q", *scripts)
+		}
+	}
+
+	*scripts = (*scripts)[:0]
+	flags := map[string]string{ProtocolFlag.Name: "UDP", "interface": "eth0", "time": "100", "remote-port": "443,5000-5100"}
+	if response := runNetwork(context.Background(), "delay", flags); !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	scoped := netif.ProtocolNetem{Interface: "eth0", Protocol: "udp", Netem: "delay 100ms", Families: []string{"ip", "ipv6"},
+		LocalPorts: []string{}, RemotePorts: []string{"443", "5000-5100"}, DestinationIPs: []string{}}
+	expected, _ := scoped.Script()
+	found := false
+	for _, script := range *scripts {
+		found = found || script == expected
+	}
+	if !found {
+		t.Errorf("expected %s, got %q", expected, *scripts)
+	}
+	if response := runNetwork(spec.SetDestroyFlag(context.Background(), "uid1"), "delay", flags); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	found = false
+	for _, script := range *scripts {
+		found = found || script == scoped.RestoreScript()
+	}
+	if !found {
+		t.Errorf("expected the prio qdisc deleted, got %q", *scripts)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/qdisc"
+)
+
+// execTcNetwork executes the tc action by chaos_os after inspecting the qdiscs of the interface, the qdiscs of the
+// CNI shapers are never replaced, the experiment is refused with the conflicts or the netem is chained under the tbf
+func execTcNetwork(ctx context.Context, uid string, expModel *spec.ExpModel, pid int32, flags map[string]string,
+	isDestroy bool) *spec.Response {
+	iface := flags["interface"]
+	if iface == "" {
+		return execChaosOsNetwork(ctx, uid, expModel, pid, flags, isDestroy)
+	}
+	output, err := runInNetns(ctx, pid, qdisc.ShowScript(iface))
+	if err != nil {
+		log.Warnf(ctx, "inspect the qdiscs of %s failed, %v", iface, err)
+		return execChaosOsNetwork(ctx, uid, expModel, pid, flags, isDestroy)
+	}
+	qdiscs := qdisc.Parse(output)
+	if isDestroy {
+		if netem, ok := qdisc.Chained(qdiscs); ok {
+			if _, err := runInNetns(ctx, pid, qdisc.UnchainScript(iface, netem)); err != nil {
+				return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "tc", err)
+			}
+			return spec.ReturnSuccess(uid)
+		}
+		return execChaosOsNetwork(ctx, uid, expModel, pid, flags, isDestroy)
+	}
+	conflicts := qdisc.Inspect(qdiscs)
+	if len(conflicts) == 0 {
+		return execChaosOsNetwork(ctx, uid, expModel, pid, flags, isDestroy)
+	}
+	report := qdisc.Report(iface, conflicts)
+	log.Warnf(ctx, "experiment %s, %s", uid, report)
+	mode := expModel.ActionFlags[QdiscConflictFlag.Name]
+	if mode == "" {
+		mode = qdisc.ModeRefuse
+	}
+	switch mode {
+	case qdisc.ModeRefuse:
+		return spec.ReturnFail(spec.OsCmdExecFailed, report)
+	case qdisc.ModeChain:
+	default:
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, QdiscConflictFlag.Name, mode,
+			fmt.Sprintf("only support %s and %s", qdisc.ModeRefuse, qdisc.ModeChain))
+	}
+	tbf, ok := qdisc.Chainable(conflicts)
+	if !ok {
+		return spec.ReturnFail(spec.OsCmdExecFailed, report+", only the tbf root qdisc can be chained")
+	}
+	for _, name := range tcFilterFlags {
+		if flags[name] != "" {
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, name, flags[name],
+				"the netem chained under the tbf impacts the whole interface, the tc filters are not supported")
+		}
+	}
+	args, err := qdisc.NetemArgs(expModel.ActionName, flags)
+	if err != nil {
+		return spec.ReturnFail(spec.ParameterLess, err.Error())
+	}
+	if _, err := runInNetns(ctx, pid, qdisc.ChainScript(iface, tbf, args)); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "tc", err)
+	}
+	log.Infof(ctx, "experiment %s chains the netem under %s of %s", uid, tbf, iface)
+	return spec.ReturnSuccess(uid)
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestExecTcNetworkConflict(t *testing.T) {
+	tbf := "qdisc tbf 1: root refcnt 2 rate 10Mbit burst 5Kb lat 50.0ms\n"
+	scripts := fakeNetns(t, tbf)
+	flags := map[string]string{ContainerIdFlag.Name: "c1", "interface": "eth0", "percent": "50"}
+	model := &spec.ExpModel{Target: "network", ActionName: "loss", ActionFlags: flags}
+	response := NewNetworkExecutor().Exec("uid1", context.Background(), model)
+	if response.Success || !strings.Contains(response.Err, "tbf 1: root installed by the bandwidth plugin of the CNI") {
+		t.Fatalf("expected the conflict refused, got %+v", response)
+	}
+	if len(*scripts) != 1 || (*scripts)[0] != "tc qdisc show dev eth0" {
+		t.Fatalf("expected the qdiscs inspected only, got %q", *scripts)
+	}
+
+	flags[QdiscConflictFlag.Name] = "chain"
+	flags["local-port"] = "80"
+	if response := NewNetworkExecutor().Exec("uid1", context.Background(), model); response.Success {
+		t.Errorf("expected the filters refused by the chain")
+	}
+	delete(flags, "local-port")
+	if response := NewNetworkExecutor().Exec("uid1", context.Background(), model); !response.Success {
+		t.Fatalf("chain failed, %+v", response)
+	}
+	if chain := (*scripts)[len(*scripts)-1]; chain != "tc qdisc add dev eth0 parent 1:1 handle 1bac: netem loss 50%" {
+		t.Errorf("expected the netem chained, got %s", chain)
+	}
+
+	scripts = fakeNetns(t, tbf+"qdisc netem 1bac: parent 1:1 limit 1000 loss 50%\n")
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := NewNetworkExecutor().Exec("uid1", ctx, model); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if len(*scripts) != 2 || (*scripts)[1] != "tc qdisc del dev eth0 parent 1:1 handle 1bac: 2>/dev/null; true" {
+		t.Errorf("expected the netem unchained, got %q", *scripts)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netstate"
+)
+
+// snapshotNetns dumps the network state of the network namespace of the process
+var snapshotNetns = func(ctx context.Context, pid int32) (netstate.Snapshot, error) {
+	output, err := runInNetns(ctx, pid, netstate.Script())
+	if err != nil {
+		return nil, err
+	}
+	return netstate.Parse(output), nil
+}
+
+// snapshotNetwork records the network state of the container before the experiment changes it, the experiment is
+// never failed by the snapshot
+func snapshotNetwork(ctx context.Context, uid, containerId string, pid int32) {
+	snapshot, err := snapshotNetns(ctx, pid)
+	if err != nil {
+		log.Warnf(ctx, "snapshot the network state of container %s failed, %v", containerId, err)
+		return
+	}
+	if _, err := OpenSnapshots().Add(journal.NetworkSnapshot{Uid: uid, ContainerId: containerId,
+		Before: snapshot}); err != nil {
+		log.Warnf(ctx, "record the network snapshot of experiment %s failed, %v", uid, err)
+	}
+}
+
+// verifyNetwork compares the network state of the container with the snapshot after the experiment is destroyed or
+// failed to create, the residue is recorded with the snapshot. It's verified by the last experiment of the container
+func verifyNetwork(ctx context.Context, uid string, pid int32) {
+	snapshots := OpenSnapshots()
+	snapshot, last, err := snapshots.Release(uid)
+	if err != nil {
+		log.Warnf(ctx, "release the network snapshot of experiment %s failed, %v", uid, err)
+		return
+	}
+	if !last {
+		return
+	}
+	after, err := snapshotNetns(ctx, pid)
+	if err != nil {
+		log.Warnf(ctx, "snapshot the network state of container %s failed, %v", snapshot.ContainerId, err)
+		return
+	}
+	residue := netstate.Diff(snapshot.Before, after)
+	if len(residue) > 0 {
+		log.Warnf(ctx, "the network state of container %s is not restored after experiment %s, residue %v",
+			snapshot.ContainerId, uid, residue)
+	}
+	if err := snapshots.Verify(uid, residue); err != nil {
+		log.Warnf(ctx, "record the network residue of experiment %s failed, %v", uid, err)
+	}
+}
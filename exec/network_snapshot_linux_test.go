@@ -0,0 +1,70 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netstate"
+)
+
+func TestNetworkSnapshotResidue(t *testing.T) {
+	fakeNetns(t, "")
+	residue := "ip6tables -A INPUT -s fd00::1/128 -p tcp -j DROP"
+	states := []netstate.Snapshot{
+		{netstate.SectionIp6tables: {"*filter", "COMMIT"}},
+		{netstate.SectionIp6tables: {"*filter", "COMMIT"}},
+		{netstate.SectionIp6tables: {"*filter", residue, "COMMIT"}},
+	}
+	snapshotNetns = func(ctx context.Context, pid int32) (netstate.Snapshot, error) {
+		state := states[0]
+		states = states[1:]
+		return state, nil
+	}
+	flags := func() map[string]string { return map[string]string{"source-ip": "fd00::1", "network-traffic": "in"} }
+	model := func(flags map[string]string) *spec.ExpModel {
+		flags[ContainerIdFlag.Name] = "c1"
+		return &spec.ExpModel{Target: "network", ActionName: "drop", ActionFlags: flags}
+	}
+	// the state is verified once both experiments of the container are destroyed, against the earliest snapshot
+	for _, uid := range []string{"uid1", "uid2"} {
+		if response := NewNetworkExecutor().Exec(uid, context.Background(), model(flags())); !response.Success {
+			t.Fatalf("create %s failed, %+v", uid, response)
+		}
+	}
+	for _, uid := range []string{"uid1", "uid2"} {
+		ctx := spec.SetDestroyFlag(context.Background(), uid)
+		if response := NewNetworkExecutor().Exec(uid, ctx, model(flags())); !response.Success {
+			t.Fatalf("destroy %s failed, %+v", uid, response)
+		}
+	}
+	if len(states) != 0 {
+		t.Errorf("expected the state snapshot before and after, %d left", len(states))
+	}
+	snapshots, err := OpenSnapshots().List()
+	if err != nil || len(snapshots) != 1 || snapshots[0].Uid != "uid2" {
+		t.Fatalf("expected the residue of the last experiment recorded, got %+v, err %v", snapshots, err)
+	}
+	expected := []netstate.Residue{{Section: netstate.SectionIp6tables, Added: []string{residue}}}
+	if !reflect.DeepEqual(snapshots[0].Residue, expected) {
+		t.Errorf("expected residue %+v, got %+v", expected, snapshots[0].Residue)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package node
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// The cni providers detected
+const (
+	CNICilium        = "cilium"
+	CNICalico        = "calico"
+	CNIFlannel       = "flannel"
+	CNIOVNKubernetes = "ovn-kubernetes"
+	CNIKubeOVN       = "kube-ovn"
+	CNIWeave         = "weave"
+	CNIAntrea        = "antrea"
+)
+
+// procDir is the proc of the host pid namespace, the agents of the providers are found by their commands
+var procDir = "/proc"
+
+// pluginProviders are the providers of the main plugin types of the cni config
+var pluginProviders = map[string]string{
+	"cilium-cni":          CNICilium,
+	"calico":              CNICalico,
+	"flannel":             CNIFlannel,
+	"ovn-k8s-cni-overlay": CNIOVNKubernetes,
+	"kube-ovn":            CNIKubeOVN,
+	"weave-net":           CNIWeave,
+	"antrea":              CNIAntrea,
+}
+
+// processProviders are the providers of the agent commands in order, the first one running wins, e.g. calico-node of
+// canal running flanneld too. The commands are truncated to 15 characters by the kernel
+var processProviders = []struct{ command, name string }{
+	{"cilium-agent", CNICilium},
+	{"calico-node", CNICalico},
+	{"ovnkube", CNIOVNKubernetes},
+	{"kube-ovn-daemon", CNIKubeOVN},
+	{"antrea-agent", CNIAntrea},
+	{"weaver", CNIWeave},
+	{"flanneld", CNIFlannel},
+}
+
+// kubeProxyCommand is the command of kube-proxy
+const kubeProxyCommand = "kube-proxy"
+
+// CNIProvider is the cni provider of the node, several network experiments behave differently under the providers,
+// and the support tickets lack it mostly
+type CNIProvider struct {
+	Name string `json:"name"`
+	// Source is what the provider is detected by, the plugin type of the cni config or the agent command
+	Source string `json:"source"`
+	// KubeProxy is true if kube-proxy runs on the node, the providers replacing it balance the services themselves
+	KubeProxy bool `json:"kubeProxy"`
+}
+
+// TranslatesServices returns true if the service ips are translated at the connect by the socket load balancer, as
+// the kube-proxy replacement of cilium and the ebpf dataplane of calico do, the packets leaving the pods carry the
+// ips of the endpoints instead of the service ips
+func (p *CNIProvider) TranslatesServices() bool {
+	return p != nil && !p.KubeProxy && (p.Name == CNICilium || p.Name == CNICalico)
+}
+
+// String returns the provider and the service proxy, e.g. cilium without kube-proxy
+func (p *CNIProvider) String() string {
+	if p == nil {
+		return "unknown"
+	}
+	if p.KubeProxy {
+		return p.Name
+	}
+	return p.Name + " without kube-proxy"
+}
+
+// DetectCNI returns the provider of the plugin types of the cni config, or the provider of the agent running on the
+// node if the config is not readable, such as the config dir not mounted into the agent, nil if neither is found
+func DetectCNI(plugins []string) *CNIProvider {
+	commands := processCommands()
+	provider := &CNIProvider{KubeProxy: commands[kubeProxyCommand]}
+	for _, plugin := range plugins {
+		if name, ok := pluginProviders[plugin]; ok {
+			provider.Name, provider.Source = name, "cni config "+plugin
+			return provider
+		}
+	}
+	for _, process := range processProviders {
+		if commands[process.command] {
+			provider.Name, provider.Source = process.name, "process "+process.command
+			return provider
+		}
+	}
+	return nil
+}
+
+// processCommands returns the commands of the processes, the processes of the other pid namespaces are not visible
+func processCommands() map[string]bool {
+	commands := make(map[string]bool)
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return commands
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+		if comm, err := os.ReadFile(path.Join(procDir, entry.Name(), "comm")); err == nil {
+			commands[strings.TrimSpace(string(comm))] = true
+		}
+	}
+	return commands
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package node
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func setupProcesses(t *testing.T, commands ...string) {
+	t.Helper()
+	for i, command := range commands {
+		dir := path.Join(procDir, string(rune('1'+i)))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path.Join(dir, "comm"), []byte(command+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// not a process
+	os.MkdirAll(path.Join(procDir, "sys"), 0755)
+}
+
+func TestDetectCNI(t *testing.T) {
+	tests := []struct {
+		plugins   []string
+		processes []string
+		expected  *CNIProvider
+	}{
+		{[]string{"cilium-cni"}, nil, &CNIProvider{Name: CNICilium, Source: "cni config cilium-cni"}},
+		{[]string{"calico", "bandwidth"}, []string{"kube-proxy", "calico-node"},
+			&CNIProvider{Name: CNICalico, Source: "cni config calico", KubeProxy: true}},
+		{[]string{"ovn-k8s-cni-overlay"}, nil, &CNIProvider{Name: CNIOVNKubernetes, Source: "cni config ovn-k8s-cni-overlay"}},
+		// the config dir is not mounted, the agents are found
+		{nil, []string{"kube-proxy", "flanneld", "calico-node"},
+			&CNIProvider{Name: CNICalico, Source: "process calico-node", KubeProxy: true}},
+		{[]string{"bridge", "portmap"}, []string{"kube-ovn-daemon"}, &CNIProvider{Name: CNIKubeOVN, Source: "process kube-ovn-daemon"}},
+		{[]string{"bridge"}, []string{"kube-proxy"}, nil},
+	}
+	for _, test := range tests {
+		setupNode(t, nil)
+		setupProcesses(t, test.processes...)
+		provider := DetectCNI(test.plugins)
+		if (provider == nil) != (test.expected == nil) || provider != nil && *provider != *test.expected {
+			t.Errorf("expected %+v of %v %v, got %+v", test.expected, test.plugins, test.processes, provider)
+		}
+	}
+}
+
+func TestTranslatesServices(t *testing.T) {
+	tests := []struct {
+		provider *CNIProvider
+		expected bool
+		output   string
+	}{
+		{&CNIProvider{Name: CNICilium}, true, "cilium without kube-proxy"},
+		{&CNIProvider{Name: CNICilium, KubeProxy: true}, false, "cilium"},
+		{&CNIProvider{Name: CNIFlannel}, false, "flannel without kube-proxy"},
+		{nil, false, "unknown"},
+	}
+	for _, test := range tests {
+		if translates := test.provider.TranslatesServices(); translates != test.expected {
+			t.Errorf("expected %t of %+v, got %t", test.expected, test.provider, translates)
+		}
+		if output := test.provider.String(); output != test.output {
+			t.Errorf("expected %s, got %s", test.output, output)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package node
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// The features of the node the experiments depend on
+const (
+	FeatureCgroupV2   = "cgroup-v2"
+	FeaturePrivileged = "privileged"
+	FeatureIptables   = "iptables"
+	FeatureNft        = "nft"
+	FeatureTc         = "tc"
+	FeatureConntrack  = "conntrack"
+	FeatureIPv6       = "ipv6"
+	FeatureNvidiaGPU  = "nvidia-gpu"
+	FeatureKata       = "kata"
+	FeatureGVisor     = "gvisor"
+	// FeatureDmsetup is required by the device experiments swapping the tables of the device-mapper devices
+	FeatureDmsetup = "dmsetup"
+	// FeatureCgroupWritable is required by the experiments starting their processes in the cgroups of the containers
+	// or creating the sibling cgroups, the cgroupfs is read-only in the unprivileged executor containers
+	FeatureCgroupWritable = "cgroup-writable"
+	// FeatureIfb is required by the ingress direction of the tc actions, the ingress packets are redirected to an ifb
+	FeatureIfb = "ifb"
+	// FeatureU32 is required by the dns_delay action filtering the dns packets by the u32 classifier
+	FeatureU32 = "u32"
+)
+
+// privilegedCapabilities are the capabilities of the executor entering the namespaces of the containers and
+// changing their network and processes: CAP_NET_ADMIN, CAP_SYS_PTRACE and CAP_SYS_ADMIN
+var privilegedCapabilities = []uint{12, 19, 21}
+
+var (
+	procSelfStatus = "/proc/self/status"
+	ipv6File       = "/proc/net/if_inet6"
+	nvidiaDevice   = "/dev/nvidia0"
+	cgroupRoot     = "/sys/fs/cgroup"
+	sysModuleDir   = "/sys/module"
+	modulesDir     = "/lib/modules"
+	osReleaseFile  = "/proc/sys/kernel/osrelease"
+	// binDirs are the install dirs out of the PATH of the executor, such as the dir of the kata packages
+	binDirs  = []string{"/opt/kata/bin", "/usr/local/bin"}
+	lookPath = exec.LookPath
+)
+
+// Feature is a feature of the node, the detail tells why it is unavailable or which variant is available
+type Feature struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// Features detects the features of the node. The tools are looked up in the PATH of the executor, which runs them in
+// the namespaces of the containers, and the kata and gvisor runtimes are the sandboxes the executor cannot enter
+func Features() []Feature {
+	return []Feature{
+		cgroupV2Feature(),
+		privilegedFeature(),
+		binaryFeature(FeatureIptables, "iptables"),
+		binaryFeature(FeatureNft, "nft"),
+		binaryFeature(FeatureTc, "tc"),
+		binaryFeature(FeatureConntrack, "conntrack"),
+		fileFeature(FeatureIPv6, ipv6File),
+		nvidiaFeature(),
+		binaryFeature(FeatureKata, "containerd-shim-kata-v2", "kata-runtime"),
+		binaryFeature(FeatureGVisor, "runsc"),
+		binaryFeature(FeatureDmsetup, "dmsetup"),
+		cgroupWritableFeature(),
+		moduleFeature(FeatureIfb, "ifb"),
+		moduleFeature(FeatureU32, "cls_u32"),
+	}
+}
+
+func cgroupV2Feature() Feature {
+	if container.CgroupVersion() == container.CgroupV2 {
+		return Feature{Name: FeatureCgroupV2, Available: true}
+	}
+	return Feature{Name: FeatureCgroupV2, Detail: "cgroup v1"}
+}
+
+// cgroupWritableFeature checks the unified hierarchy, or the cpu controller of the v1 hierarchies whose tmpfs root is
+// mounted read-only by systemd, is writable by the executor
+func cgroupWritableFeature() Feature {
+	dir := cgroupRoot
+	if container.CgroupVersion() != container.CgroupV2 {
+		dir = path.Join(cgroupRoot, "cpu")
+	}
+	if err := unix.Access(dir, unix.W_OK); err != nil {
+		return Feature{Name: FeatureCgroupWritable, Detail: fmt.Sprintf("%s is not writable, %v", dir, err)}
+	}
+	return Feature{Name: FeatureCgroupWritable, Available: true, Detail: dir}
+}
+
+func privilegedFeature() Feature {
+	feature := Feature{Name: FeaturePrivileged}
+	effective, err := effectiveCapabilities()
+	if err != nil {
+		feature.Detail = err.Error()
+		return feature
+	}
+	missing := make([]string, 0)
+	for _, capability := range privilegedCapabilities {
+		if effective&(1<<capability) == 0 {
+			missing = append(missing, strconv.Itoa(int(capability)))
+		}
+	}
+	if len(missing) > 0 {
+		feature.Detail = fmt.Sprintf("missing the capabilities %s", strings.Join(missing, ","))
+		return feature
+	}
+	feature.Available = true
+	return feature
+}
+
+// effectiveCapabilities returns the CapEff of the executor
+func effectiveCapabilities() (uint64, error) {
+	file, err := os.Open(procSelfStatus)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), "CapEff:"); value != scanner.Text() {
+			return strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		}
+	}
+	return 0, fmt.Errorf("CapEff not found in %s", procSelfStatus)
+}
+
+// binaryFeature is available if any of the binaries is found in the PATH or the binDirs
+func binaryFeature(name string, binaries ...string) Feature {
+	for _, binary := range binaries {
+		if found, err := lookPath(binary); err == nil {
+			return Feature{Name: name, Available: true, Detail: found}
+		}
+		for _, dir := range binDirs {
+			if info, err := os.Stat(path.Join(dir, binary)); err == nil && !info.IsDir() {
+				return Feature{Name: name, Available: true, Detail: path.Join(dir, binary)}
+			}
+		}
+	}
+	return Feature{Name: name, Detail: fmt.Sprintf("%s not found", strings.Join(binaries, ", "))}
+}
+
+func fileFeature(name, file string) Feature {
+	if _, err := os.Stat(file); err != nil {
+		return Feature{Name: name, Detail: fmt.Sprintf("%s not found", file)}
+	}
+	return Feature{Name: name, Available: true}
+}
+
+func nvidiaFeature() Feature {
+	if feature := fileFeature(FeatureNvidiaGPU, nvidiaDevice); feature.Available {
+		return feature
+	}
+	return binaryFeature(FeatureNvidiaGPU, "nvidia-smi")
+}
+
+// moduleFeature is available if the kernel module is loaded, built in or installed for the running kernel, the
+// installed module is loaded on demand when the experiment adds the device or the filter
+func moduleFeature(name, module string) Feature {
+	if _, err := os.Stat(path.Join(sysModuleDir, module)); err == nil {
+		return Feature{Name: name, Available: true, Detail: "loaded"}
+	}
+	release, err := os.ReadFile(osReleaseFile)
+	if err != nil {
+		return Feature{Name: name, Detail: err.Error()}
+	}
+	dir := path.Join(modulesDir, strings.TrimSpace(string(release)))
+	for _, index := range []string{"modules.builtin", "modules.dep"} {
+		content, err := os.ReadFile(path.Join(dir, index))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			file := path.Base(strings.SplitN(line, ":", 2)[0])
+			if file == module+".ko" || strings.HasPrefix(file, module+".ko.") {
+				return Feature{Name: name, Available: true, Detail: index}
+			}
+		}
+	}
+	return Feature{Name: name, Detail: fmt.Sprintf("module %s not found in %s", module, dir)}
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package node
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+)
+
+func TestPrivilegedFeature(t *testing.T) {
+	dir := t.TempDir()
+	procSelfStatus = path.Join(dir, "status")
+	t.Cleanup(func() { procSelfStatus = "/proc/self/status" })
+	tests := []struct {
+		capEff    string
+		available bool
+		detail    string
+	}{
+		{"000001ffffffffff", true, ""},
+		{"000001fffff7ffff", false, "missing the capabilities 19"},
+		{"0000000000000000", false, "missing the capabilities 12,19,21"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(procSelfStatus, []byte("Name:\tblade\nCapEff:\t"+tt.capEff+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if feature := privilegedFeature(); feature.Available != tt.available || feature.Detail != tt.detail {
+			t.Errorf("expected %v %q of %s, got %+v", tt.available, tt.detail, tt.capEff, feature)
+		}
+	}
+}
+
+func TestBinaryFeature(t *testing.T) {
+	dir := t.TempDir()
+	binDirs, lookPath = []string{dir}, func(file string) (string, error) {
+		if file == "nft" {
+			return "/usr/sbin/nft", nil
+		}
+		return "", errors.New("not found")
+	}
+	t.Cleanup(func() {
+		binDirs = []string{"/opt/kata/bin", "/usr/local/bin"}
+		lookPath = exec.LookPath
+	})
+	if err := os.WriteFile(path.Join(dir, "kata-runtime"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []Feature{
+		binaryFeature(FeatureNft, "nft"),
+		binaryFeature(FeatureKata, "containerd-shim-kata-v2", "kata-runtime"),
+		binaryFeature(FeatureGVisor, "runsc"),
+	}
+	expected := []Feature{
+		{Name: FeatureNft, Available: true, Detail: "/usr/sbin/nft"},
+		{Name: FeatureKata, Available: true, Detail: path.Join(dir, "kata-runtime")},
+		{Name: FeatureGVisor, Detail: "runsc not found"},
+	}
+	for i := range tests {
+		if tests[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], tests[i])
+		}
+	}
+}
+
+func TestModuleFeature(t *testing.T) {
+	dir := t.TempDir()
+	sysModuleDir, modulesDir, osReleaseFile = path.Join(dir, "sys"), path.Join(dir, "modules"), path.Join(dir, "osrelease")
+	t.Cleanup(func() {
+		sysModuleDir, modulesDir, osReleaseFile = "/sys/module", "/lib/modules", "/proc/sys/kernel/osrelease"
+	})
+	kernelDir := path.Join(modulesDir, "5.15.0-91-generic")
+	for _, dir := range []string{path.Join(sysModuleDir, "sch_netem"), kernelDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		osReleaseFile:                           "5.15.0-91-generic\n",
+		path.Join(kernelDir, "modules.builtin"): "kernel/net/sched/cls_matchall.ko\n",
+		path.Join(kernelDir, "modules.dep"): "kernel/drivers/net/ifb.ko.zst:\n" +
+			"kernel/net/sched/act_mirred.ko: kernel/net/sched/cls_u32_extra.ko\n",
+	}
+	for file, content := range files {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []Feature{
+		moduleFeature("netem", "sch_netem"),
+		moduleFeature("matchall", "cls_matchall"),
+		moduleFeature(FeatureIfb, "ifb"),
+		moduleFeature(FeatureU32, "cls_u32"),
+	}
+	expected := []Feature{
+		{Name: "netem", Available: true, Detail: "loaded"},
+		{Name: "matchall", Available: true, Detail: "modules.builtin"},
+		{Name: FeatureIfb, Available: true, Detail: "modules.dep"},
+		{Name: FeatureU32, Detail: "module cls_u32 not found in " + kernelDir},
+	}
+	for i := range tests {
+		if tests[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], tests[i])
+		}
+	}
+}
+
+func TestCgroupWritableFeature(t *testing.T) {
+	cgroupRoot = t.TempDir()
+	t.Cleanup(func() { cgroupRoot = "/sys/fs/cgroup" })
+	if err := os.Mkdir(path.Join(cgroupRoot, "cpu"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if feature := cgroupWritableFeature(); !feature.Available {
+		t.Errorf("expected the cgroup writable, got %+v", feature)
+	}
+	cgroupRoot = path.Join(cgroupRoot, "not-exist")
+	if feature := cgroupWritableFeature(); feature.Available || feature.Detail == "" {
+		t.Errorf("expected the cgroup not writable, got %+v", feature)
+	}
+}
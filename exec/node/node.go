@@ -0,0 +1,109 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package node collects the node and runtime context the experiments run on, so the outcomes collected
+// centrally can be correlated with the node heterogeneity
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+var (
+	kernelReleaseFile = "/proc/sys/kernel/osrelease"
+	cniConfDir        = "/etc/cni/net.d"
+)
+
+// Info is the node and runtime context, the fields failed to collect are empty
+type Info struct {
+	Hostname       string `json:"hostname"`
+	KernelVersion  string `json:"kernelVersion,omitempty"`
+	CgroupVersion  int    `json:"cgroupVersion,omitempty"`
+	Runtime        string `json:"runtime,omitempty"`
+	RuntimeVersion string `json:"runtimeVersion,omitempty"`
+	// CNI are the plugin types of the active cni config, e.g. calico, bandwidth, portmap
+	CNI []string `json:"cni,omitempty"`
+	// CNIProvider is the cni provider detected by the config or the agent running, nil if unknown
+	CNIProvider *CNIProvider `json:"cniProvider,omitempty"`
+}
+
+// Collect returns the context of the node, the runtime is queried by the client if not nil
+func Collect(ctx context.Context, client container.Container) *Info {
+	info := &Info{CNI: cniPlugins()}
+	info.CNIProvider = DetectCNI(info.CNI)
+	info.Hostname, _ = os.Hostname()
+	if release, err := os.ReadFile(kernelReleaseFile); err == nil {
+		info.KernelVersion = strings.TrimSpace(string(release))
+		info.CgroupVersion = container.CgroupVersion()
+	}
+	if client != nil {
+		info.Runtime, info.RuntimeVersion, _ = client.RuntimeVersion(ctx)
+	}
+	return info
+}
+
+// cniPlugins returns the plugin types of the lexicographically first config, which is the one used by the runtime
+func cniPlugins() []string {
+	entries, err := os.ReadDir(cniConfDir)
+	if err != nil {
+		return nil
+	}
+	files := make([]string, 0)
+	for _, entry := range entries {
+		switch path.Ext(entry.Name()) {
+		case ".conf", ".conflist", ".json":
+			if !entry.IsDir() {
+				files = append(files, entry.Name())
+			}
+		}
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		bytes, err := os.ReadFile(path.Join(cniConfDir, file))
+		if err != nil {
+			continue
+		}
+		var conf struct {
+			Type    string `json:"type"`
+			Plugins []struct {
+				Type string `json:"type"`
+			} `json:"plugins"`
+		}
+		if err := json.Unmarshal(bytes, &conf); err != nil {
+			continue
+		}
+		plugins := make([]string, 0)
+		if conf.Type != "" {
+			plugins = append(plugins, conf.Type)
+		}
+		for _, plugin := range conf.Plugins {
+			if plugin.Type != "" {
+				plugins = append(plugins, plugin.Type)
+			}
+		}
+		if len(plugins) > 0 {
+			return plugins
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+func setupNode(t *testing.T, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	kernelReleaseFile, cniConfDir, procDir = path.Join(dir, "osrelease"), path.Join(dir, "net.d"), path.Join(dir, "proc")
+	t.Cleanup(func() {
+		kernelReleaseFile, cniConfDir, procDir = "/proc/sys/kernel/osrelease", "/etc/cni/net.d", "/proc"
+	})
+	if err := os.MkdirAll(cniConfDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kernelReleaseFile, []byte("5.15.0-91-generic\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path.Join(cniConfDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	setupNode(t, map[string]string{
+		"10-calico.conflist": `{"name":"k8s-pod-network","plugins":[{"type":"calico"},{"type":"bandwidth"},{"type":"portmap"}]}`,
+		"99-loopback.conf":   `{"name":"lo","type":"loopback"}`,
+		"00-broken.conf":     `{`,
+	})
+	client := mock.NewContainer()
+	client.RuntimeVersionFunc = func(ctx context.Context) (string, string, error) {
+		return "containerd", "1.7.11", nil
+	}
+	info := Collect(context.Background(), client)
+	if info.Hostname == "" || info.KernelVersion != "5.15.0-91-generic" || info.CgroupVersion == 0 {
+		t.Errorf("unexpected host info %+v", info)
+	}
+	if info.Runtime != "containerd" || info.RuntimeVersion != "1.7.11" {
+		t.Errorf("unexpected runtime %s %s", info.Runtime, info.RuntimeVersion)
+	}
+	if cni := fmt.Sprint(info.CNI); cni != "[calico bandwidth portmap]" {
+		t.Errorf("unexpected cni %s", cni)
+	}
+	if info.CNIProvider == nil || info.CNIProvider.Name != CNICalico {
+		t.Errorf("unexpected cni provider %+v", info.CNIProvider)
+	}
+}
+
+func TestCollectWithoutRuntime(t *testing.T) {
+	setupNode(t, nil)
+	client := mock.NewContainer()
+	client.RuntimeVersionFunc = func(ctx context.Context) (string, string, error) {
+		return "", "", fmt.Errorf("connection refused")
+	}
+	info := Collect(context.Background(), client)
+	if info.Runtime != "" || info.CNI != nil || info.KernelVersion == "" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if info := Collect(context.Background(), nil); info.Runtime != "" {
+		t.Errorf("unexpected runtime without the client %s", info.Runtime)
+	}
+}
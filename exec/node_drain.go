@@ -0,0 +1,260 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/drain"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+)
+
+// The flags of the node drain experiment
+const (
+	DrainConfirmFlag           = "confirm"
+	DrainNamespacesFlag        = "namespaces"
+	DrainExcludeNamespacesFlag = "exclude-namespaces"
+	DrainLabelsFlag            = "labels"
+	DrainOrderFlag             = "order"
+	DrainIntervalFlag          = "interval"
+	DrainMaxFlag               = "max"
+)
+
+// DefaultDrainInterval is the pacing between two sandboxes stopped
+const DefaultDrainInterval = "5s"
+
+// hostname returns the name of the node, it's a var for the tests
+var hostname = os.Hostname
+
+type NodeCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewNodeCommandSpec() spec.ExpModelCommandSpec {
+	return &NodeCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&NodeDrainActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:     DrainConfirmFlag,
+								Desc:     "the name of the node drained, the experiment is refused unless it is the hostname of the node, or the host of the ssh-tunnel",
+								Required: true,
+							},
+							&spec.ExpFlag{
+								Name: DrainNamespacesFlag,
+								Desc: "namespaces of the pods stopped separated by commas, default is all namespaces",
+							},
+							&spec.ExpFlag{
+								// no default, the blade fills it in, so the kube-system is excluded in Exec only
+								Name: DrainExcludeNamespacesFlag,
+								Desc: "namespaces of the pods never stopped separated by commas, default is kube-system unless the namespaces flag is given",
+							},
+							&spec.ExpFlag{
+								Name: DrainLabelsFlag,
+								Desc: "labels of the pods stopped, such as app=web,tier=frontend",
+							},
+							&spec.ExpFlag{
+								Name:    DrainOrderFlag,
+								Desc:    "order of the pods stopped, oldest, newest, name or random, default is oldest",
+								Default: drain.OrderOldest,
+							},
+							&spec.ExpFlag{
+								Name:    DrainIntervalFlag,
+								Desc:    "pacing between two pods stopped, such as 500ms or 10s, default is 5s",
+								Default: DefaultDrainInterval,
+							},
+							&spec.ExpFlag{
+								Name: DrainMaxFlag,
+								Desc: "max count of the pods stopped, default is all the pods selected",
+							},
+						},
+						ActionExecutor: &nodeDrainExecutor{},
+						ActionLongDesc: "The pod sandboxes of the node are stopped one by one with their containers by the " +
+							"cri runtime, in the order and the pacing given, like a node failure or an unclean drain, the " +
+							"pods are not evicted through the api server. The kubelet recreates the sandboxes stopped, so " +
+							"the destroy does nothing. The experiment is refused unless the confirm flag is the name of " +
+							"the node, and the kube-system pods are never stopped by default. The pod of the executor, " +
+							"found by the POD_NAME and POD_NAMESPACE env or its cgroup, is never stopped.",
+						ActionExample: `# Stop all the pods but the kube-system ones of the node worker-1, the oldest first, one every 5 seconds
+blade create cri node drain --confirm worker-1 --container-runtime crio --cri-endpoint unix:///run/containerd/containerd.sock
+
+# Stop 3 random pods of the shop namespace, one every 30 seconds
+blade create cri node drain --confirm worker-1 --namespaces shop --order random --max 3 --interval 30s --container-runtime crio`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*NodeCommandModelSpec) Name() string {
+	return "node"
+}
+
+func (*NodeCommandModelSpec) ShortDesc() string {
+	return "Node experiment"
+}
+
+func (*NodeCommandModelSpec) LongDesc() string {
+	return "Node experiment, the pod sandboxes of the node are stopped, the image filesystem of the cri runtime is " +
+		"filled, the containers are slow to start, or they are changed before the start by the nri plugin."
+}
+
+type NodeDrainActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*NodeDrainActionCommand) Name() string {
+	return "drain"
+}
+
+func (*NodeDrainActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*NodeDrainActionCommand) ShortDesc() string {
+	return "stop the pod sandboxes of the node"
+}
+
+func (c *NodeDrainActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+// GetNodeFlags are the flags reaching the runtime of the node, the node experiments have no target container
+func GetNodeFlags() []spec.ExpFlagSpec {
+	return []spec.ExpFlagSpec{
+		EndpointFlag,
+		CRITLSCAFlag,
+		CRITLSCertFlag,
+		CRITLSKeyFlag,
+		CRITLSServerNameFlag,
+		ContainerRuntime,
+		SSHTunnelFlag,
+		SSHKeyFlag,
+		SSHKnownHostsFlag,
+		SSHRemoteSocketFlag,
+	}
+}
+
+type nodeDrainExecutor struct {
+}
+
+func (e *nodeDrainExecutor) Name() string {
+	return "node"
+}
+
+func (e *nodeDrainExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *nodeDrainExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	// the sandboxes stopped are recreated by the kubelet
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return spec.ReturnSuccess(uid)
+	}
+	flags := model.ActionFlags
+	node, err := drainNodeName(flags)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, DrainConfirmFlag, flags[DrainConfirmFlag], err)
+	}
+	if flags[DrainConfirmFlag] != node {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, DrainConfirmFlag, flags[DrainConfirmFlag],
+			fmt.Sprintf("it must be the node name %s to confirm the drain", node))
+	}
+	options := drain.Options{
+		Namespaces: splitFlag(flags[DrainNamespacesFlag]),
+		Labels:     parseContainerLabelSelector(flags[DrainLabelsFlag]),
+		Order:      flags[DrainOrderFlag],
+		Self:       drain.SelfPod(),
+	}
+	if options.Order == "" {
+		options.Order = drain.OrderOldest
+	}
+	// the default exclusions are dropped if the namespaces are given, so the kube-system is drained explicitly only
+	if excluded, ok := flags[DrainExcludeNamespacesFlag]; ok {
+		options.ExcludedNamespaces = splitFlag(excluded)
+	} else if len(options.Namespaces) == 0 {
+		options.ExcludedNamespaces = drain.DefaultExcludedNamespaces
+	}
+	var response *spec.Response
+	if options.Max, response = rangeFlag(flags, DrainMaxFlag, 0, 0, 100000); response != nil {
+		return response
+	}
+	if err := options.Validate(); err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, DrainOrderFlag, options.Order, err)
+	}
+	interval := flags[DrainIntervalFlag]
+	if interval == "" {
+		interval = DefaultDrainInterval
+	}
+	pacing, err := durations.Parse(interval)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, DrainIntervalFlag, interval, err)
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	stopper, ok := container.AsSandboxStopper(client)
+	if !ok {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, ContainerRuntime.Name,
+			flags[ContainerRuntime.Name], "the pod sandboxes are managed by the cri runtimes only")
+	}
+	sandboxes, err := stopper.ListSandboxes(ctx)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "ListSandboxes", err)
+	}
+	planned := drain.Plan(sandboxes, options)
+	if len(planned) == 0 {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, DrainNamespacesFlag, flags[DrainNamespacesFlag],
+			"no ready pod sandbox is selected")
+	}
+	log.Infof(ctx, "experiment %s drains %d pod sandboxes of %s, %s first, every %s", uid, len(planned), node,
+		options.Order, pacing)
+	result := drain.Run(ctx, stopper, planned, pacing)
+	if self, ok := drain.SelfSandbox(sandboxes, options.Self); ok {
+		result.Skipped = self.Namespace + "/" + self.Name
+	}
+	if result.Failed > 0 || result.Aborted {
+		return spec.ResponseFail(spec.ContainerExecFailed.Code, fmt.Sprintf("%d of %d pod sandboxes stopped, "+
+			"the stopped ones are recreated by the kubelet", result.Succeeded, result.Planned), result)
+	}
+	return spec.ReturnSuccess(result)
+}
+
+// drainNodeName returns the name confirming the drain, the host of the ssh tunnel or the hostname of the node
+func drainNodeName(flags map[string]string) (string, error) {
+	if tunnel := flags[SSHTunnelFlag.Name]; tunnel != "" {
+		host := tunnel[strings.LastIndex(tunnel, "@")+1:]
+		if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+			host = host[:i]
+		}
+		return strings.Trim(host, "[]"), nil
+	}
+	return hostname()
+}
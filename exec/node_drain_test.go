@@ -0,0 +1,140 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/drain"
+)
+
+// sandboxContainer is the mock client of a cri runtime, the sandboxes stopped are recorded
+type sandboxContainer struct {
+	*mock.Container
+	sandboxes []container.Sandbox
+	stopped   []string
+}
+
+func (c *sandboxContainer) ListSandboxes(ctx context.Context) ([]container.Sandbox, error) {
+	return c.sandboxes, nil
+}
+
+func (c *sandboxContainer) StopSandbox(ctx context.Context, sandboxId string) error {
+	c.stopped = append(c.stopped, sandboxId)
+	return nil
+}
+
+func runNodeDrain(t *testing.T, client container.Container, flags map[string]string) *spec.Response {
+	t.Helper()
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	saved := hostname
+	hostname = func() (string, error) { return "node-1", nil }
+	t.Cleanup(func() {
+		NewClientFunc = nil
+		hostname = saved
+	})
+	model := &spec.ExpModel{Target: "node", ActionName: "drain", ActionFlags: flags}
+	return (&nodeDrainExecutor{}).Exec("uid1", context.Background(), model)
+}
+
+func TestNodeDrainConfirm(t *testing.T) {
+	client := &sandboxContainer{Container: mock.NewContainer(),
+		sandboxes: []container.Sandbox{{Id: "s1", Name: "web", Namespace: "default", Ready: true}}}
+	for _, confirm := range []string{"", "node-2"} {
+		response := runNodeDrain(t, client, map[string]string{DrainConfirmFlag: confirm})
+		if response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the confirmation %q refused, got %+v", confirm, response)
+		}
+	}
+	if len(client.stopped) != 0 {
+		t.Errorf("expected no sandbox stopped, got %v", client.stopped)
+	}
+}
+
+func TestNodeDrain(t *testing.T) {
+	now := time.Now()
+	client := &sandboxContainer{Container: mock.NewContainer(), sandboxes: []container.Sandbox{
+		{Id: "s1", Name: "web", Namespace: "default", Ready: true, CreatedAt: now},
+		{Id: "s2", Name: "coredns", Namespace: "kube-system", Ready: true, CreatedAt: now.Add(-time.Hour)},
+		{Id: "s3", Name: "api", Namespace: "default", Ready: true, CreatedAt: now.Add(-time.Minute)},
+		{Id: "s4", Name: "job", Namespace: "default", CreatedAt: now.Add(-2 * time.Hour)},
+	}}
+	response := runNodeDrain(t, client, map[string]string{DrainConfirmFlag: "node-1", DrainIntervalFlag: "0s"})
+	if !response.Success {
+		t.Fatalf("expected success, got %+v", response)
+	}
+	if expected := []string{"s3", "s1"}; !reflect.DeepEqual(client.stopped, expected) {
+		t.Errorf("expected the sandboxes %v stopped, got %v", expected, client.stopped)
+	}
+	if result, ok := response.Result.(drain.Result); !ok || result.Succeeded != 2 {
+		t.Errorf("expected the result of 2 sandboxes, got %+v", response.Result)
+	}
+}
+
+func TestNodeDrainNamespaces(t *testing.T) {
+	for _, action := range NewNodeCommandSpec().Actions() {
+		for _, flag := range action.Flags() {
+			if flag.FlagName() == DrainExcludeNamespacesFlag && flag.FlagDefault() != "" {
+				t.Errorf("expected no default of the excluded namespaces filled in by the blade, got %s",
+					flag.FlagDefault())
+			}
+		}
+	}
+	// the kube-system is drained if the namespaces are given without the exclusions
+	client := &sandboxContainer{Container: mock.NewContainer(), sandboxes: []container.Sandbox{
+		{Id: "s1", Name: "web", Namespace: "default", Ready: true},
+		{Id: "s2", Name: "coredns", Namespace: "kube-system", Ready: true},
+	}}
+	response := runNodeDrain(t, client, map[string]string{DrainConfirmFlag: "node-1", DrainIntervalFlag: "0s",
+		DrainNamespacesFlag: "kube-system"})
+	if !response.Success || !reflect.DeepEqual(client.stopped, []string{"s2"}) {
+		t.Errorf("expected the kube-system drained, got %v, %+v", client.stopped, response)
+	}
+}
+
+func TestNodeDrainSkipsSelf(t *testing.T) {
+	t.Setenv(drain.PodNameEnv, "chaosblade-agent-x1")
+	t.Setenv(drain.PodNamespaceEnv, "chaosblade")
+	client := &sandboxContainer{Container: mock.NewContainer(), sandboxes: []container.Sandbox{
+		{Id: "s1", Name: "web", Namespace: "default", Ready: true},
+		{Id: "s2", Name: "chaosblade-agent-x1", Namespace: "chaosblade", Ready: true},
+	}}
+	response := runNodeDrain(t, client, map[string]string{DrainConfirmFlag: "node-1", DrainIntervalFlag: "0s",
+		DrainExcludeNamespacesFlag: ""})
+	if !response.Success || !reflect.DeepEqual(client.stopped, []string{"s1"}) {
+		t.Fatalf("expected the pod of the executor never stopped, got %v, %+v", client.stopped, response)
+	}
+	if result := response.Result.(drain.Result); result.Skipped != "chaosblade/chaosblade-agent-x1" {
+		t.Errorf("expected the pod of the executor reported, got %+v", result)
+	}
+}
+
+func TestNodeDrainNoSandboxes(t *testing.T) {
+	response := runNodeDrain(t, mock.NewContainer(), map[string]string{DrainConfirmFlag: "node-1"})
+	if response.Success || response.Code != spec.ParameterInvalid.Code {
+		t.Errorf("expected the runtime without sandboxes refused, got %+v", response)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/imagefs"
+)
+
+// The flags of the imagefs fill experiment
+const (
+	ImageFsPercentFlag = "percent"
+	ImageFsPathFlag    = "path"
+)
+
+// imageFsFilePrefix is the name prefix of the file allocated in the image filesystem
+const imageFsFilePrefix = ".chaosblade-imagefs-"
+
+// withImageFsAction adds the imagefs fill action to the node model, it fills the filesystem of the node so it is
+// available on linux only
+func withImageFsAction(commandSpec spec.ExpModelCommandSpec) spec.ExpModelCommandSpec {
+	if nodeSpec, ok := commandSpec.(*NodeCommandModelSpec); ok {
+		nodeSpec.ExpActions = append(nodeSpec.ExpActions, NewImageFsActionCommand())
+	}
+	return commandSpec
+}
+
+func NewImageFsActionCommand() spec.ExpActionCommandSpec {
+	return &ImageFsActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:    ImageFsPercentFlag,
+					Desc:    "percent of the bytes of the image filesystem used when the fill stops, 1 to 99, default is 90",
+					Default: "90",
+				},
+				&spec.ExpFlag{
+					Name: ImageFsPathFlag,
+					Desc: "directory of the image filesystem on the node, default is the mountpoint reported by the ImageFsInfo of the runtime",
+				},
+			},
+			ActionExecutor: &imageFsExecutor{},
+			ActionLongDesc: "The image filesystem of the runtime, the imagefs of the kubelet discovered by the cri " +
+				"ImageFsInfo, is filled with an allocated file until the bytes used reach the percent. The kubelet " +
+				"garbage collects the unused images above its image-gc-high-threshold, 85% by default, and reports " +
+				"the DiskPressure and evicts the pods below its imagefs.available threshold, 15% by default. The file " +
+				"is allocated on the node, so the experiment is refused with the ssh tunnel. The destroy removes it.",
+			ActionExample: `# Fill the imagefs of CRI-O to 90%, beyond the image gc threshold and the eviction threshold of the kubelet
+blade create cri node imagefs --container-runtime crio
+
+# Fill the imagefs of containerd to 87%, the images are garbage collected but the pods are not evicted
+blade create cri node imagefs --percent 87 --container-runtime crio --cri-endpoint unix:///run/containerd/containerd.sock`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+type ImageFsActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*ImageFsActionCommand) Name() string {
+	return "imagefs"
+}
+
+func (*ImageFsActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*ImageFsActionCommand) ShortDesc() string {
+	return "image filesystem pressure"
+}
+
+func (c *ImageFsActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+type imageFsExecutor struct {
+}
+
+func (e *imageFsExecutor) Name() string {
+	return "node"
+}
+
+func (e *imageFsExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *imageFsExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return removeImageFsFill(ctx, suid)
+	}
+	flags := model.ActionFlags
+	if tunnel := flags[SSHTunnelFlag.Name]; tunnel != "" {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, SSHTunnelFlag.Name, tunnel,
+			"the image filesystem is filled on the node, the ssh tunnel reaches the runtime api only")
+	}
+	percent, response := rangeFlag(flags, ImageFsPercentFlag, 90, 1, 99)
+	if response != nil {
+		return response
+	}
+	dir := flags[ImageFsPathFlag]
+	if dir == "" {
+		if dir, response = imageFsMountpoint(ctx, model); response != nil {
+			return response
+		}
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, ImageFsPathFlag, dir, "it is not a directory")
+	}
+	before, err := imagefs.ReadUsage(dir)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, ImageFsPathFlag, dir, err)
+	}
+	if before.Percent() >= float64(percent) {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, ImageFsPercentFlag, percent,
+			fmt.Sprintf("%.1f%% of the image filesystem is used already", before.Percent()))
+	}
+	// the state is saved before the fill, so the file allocated is removed even if the fill is interrupted
+	stateDir := util.GetProgramPath()
+	state := imagefs.State{Uid: uid, File: path.Join(dir, imageFsFilePrefix+uid), Before: before}
+	if err := imagefs.SaveState(stateDir, state); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	state.Allocated, err = imagefs.Fill(ctx, state.File, float64(percent))
+	// the bytes may be taken by the images pulled meanwhile
+	if err != nil && !errors.Is(err, syscall.ENOSPC) {
+		os.Remove(state.File)
+		imagefs.RemoveState(stateDir, uid)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "fill", err)
+	}
+	state.After, _ = imagefs.ReadUsage(dir)
+	if err := imagefs.SaveState(stateDir, state); err != nil {
+		log.Warnf(ctx, "update the state of experiment %s failed, %v", uid, err)
+	}
+	log.Infof(ctx, "%d bytes are allocated in %s, the image filesystem used is %.1f%%", state.Allocated, state.File,
+		state.After.Percent())
+	return spec.ReturnSuccess(state)
+}
+
+// imageFsMountpoint returns the mountpoint of the first image filesystem reported by the runtime
+func imageFsMountpoint(ctx context.Context, model *spec.ExpModel) (string, *spec.Response) {
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		return "", spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	reader, ok := container.AsImageFsReader(client)
+	if !ok {
+		return "", spec.ResponseFailWithFlags(spec.ParameterInvalid, ContainerRuntime.Name,
+			model.ActionFlags[ContainerRuntime.Name], "the image filesystems are reported by the cri runtimes only")
+	}
+	filesystems, err := reader.ImageFilesystems(ctx)
+	if err != nil {
+		return "", spec.ResponseFailWithFlags(spec.ContainerExecFailed, "ImageFsInfo", err)
+	}
+	for _, filesystem := range filesystems {
+		if filesystem.Mountpoint != "" {
+			return filesystem.Mountpoint, nil
+		}
+	}
+	return "", spec.ResponseFailWithFlags(spec.ParameterLess, ImageFsPathFlag)
+}
+
+// removeImageFsFill removes the file allocated, it succeeds if the state is removed already
+func removeImageFsFill(ctx context.Context, uid string) *spec.Response {
+	stateDir := util.GetProgramPath()
+	state, err := imagefs.LoadState(stateDir, uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, stateDir, err)
+	}
+	if err := os.Remove(state.File); err != nil && !os.IsNotExist(err) {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove", err)
+	}
+	if err := imagefs.RemoveState(stateDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove state", err)
+	}
+	log.Infof(ctx, "the %d bytes of experiment %s are released", state.Allocated, uid)
+	return spec.ReturnSuccess(uid)
+}
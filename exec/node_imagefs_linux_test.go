@@ -0,0 +1,99 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/imagefs"
+)
+
+// imageFsContainer is the mock client of a cri runtime reporting the image filesystems
+type imageFsContainer struct {
+	*mock.Container
+	filesystems []container.ImageFilesystem
+}
+
+func (c *imageFsContainer) ImageFilesystems(ctx context.Context) ([]container.ImageFilesystem, error) {
+	return c.filesystems, nil
+}
+
+func runImageFs(t *testing.T, client container.Container, ctx context.Context, flags map[string]string) *spec.Response {
+	t.Helper()
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	t.Cleanup(func() { NewClientFunc = nil })
+	model := &spec.ExpModel{Target: "node", ActionName: "imagefs", ActionFlags: flags}
+	return (&imageFsExecutor{}).Exec("uid1", ctx, model)
+}
+
+func TestImageFsRefused(t *testing.T) {
+	missing := path.Join(t.TempDir(), "missing")
+	tests := []struct {
+		client container.Container
+		flags  map[string]string
+		code   int32
+	}{
+		{mock.NewContainer(), map[string]string{SSHTunnelFlag.Name: "root@worker-1"}, spec.ParameterInvalid.Code},
+		{mock.NewContainer(), map[string]string{ImageFsPercentFlag: "100"}, spec.ParameterIllegal.Code},
+		{mock.NewContainer(), map[string]string{}, spec.ParameterInvalid.Code},
+		{&imageFsContainer{Container: mock.NewContainer()}, map[string]string{}, spec.ParameterLess.Code},
+		{&imageFsContainer{Container: mock.NewContainer(),
+			filesystems: []container.ImageFilesystem{{Mountpoint: missing}}}, map[string]string{}, spec.ParameterInvalid.Code},
+	}
+	for _, test := range tests {
+		response := runImageFs(t, test.client, context.Background(), test.flags)
+		if response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of the flags %v, got %+v", test.code, test.flags, response)
+		}
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("expected nothing created, got %v", err)
+	}
+}
+
+func TestImageFsDestroy(t *testing.T) {
+	file := path.Join(t.TempDir(), imageFsFilePrefix+"uid1")
+	if err := os.WriteFile(file, make([]byte, 4096), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := imagefs.SaveState(util.GetProgramPath(), imagefs.State{Uid: "uid1", File: file, Allocated: 4096}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := runImageFs(t, mock.NewContainer(), ctx, map[string]string{}); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected %s removed, got %v", file, err)
+	}
+	if _, err := imagefs.LoadState(util.GetProgramPath(), "uid1"); !os.IsNotExist(err) {
+		t.Errorf("expected the state removed, got %v", err)
+	}
+	if response := runImageFs(t, mock.NewContainer(), ctx, map[string]string{}); !response.Success {
+		t.Errorf("expected the destroy repeated, got %+v", response)
+	}
+}
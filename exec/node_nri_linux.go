@@ -0,0 +1,246 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nri"
+)
+
+// NRIBin is the NRI plugin in the bin dir, it is copied into the NRI bin dir of containerd as the chaosblade plugin
+const NRIBin = "chaos_nri"
+
+// The flags of the nri experiment, the delay and the selectors are the flags of the start delay experiment
+const (
+	NRICPUPercentFlag = "cpu-percent"
+	NRIMemLimitFlag   = "mem-limit"
+	NRIConfigFlag     = "nri-config"
+	NRIBinDirFlag     = "nri-bin-dir"
+)
+
+var (
+	// nriRulesDir is the rules dir the plugin is registered with
+	nriRulesDir = nri.DefaultRulesDir
+	// nriBinary returns the plugin binary copied into the NRI bin dir
+	nriBinary = func() string {
+		return path.Join(util.GetProgramPath(), spec.BinPath, NRIBin)
+	}
+)
+
+// withNRIAction adds the nri action to the node model, it writes the NRI config of the node so it is available on
+// linux only
+func withNRIAction(commandSpec spec.ExpModelCommandSpec) spec.ExpModelCommandSpec {
+	if nodeSpec, ok := commandSpec.(*NodeCommandModelSpec); ok {
+		nodeSpec.ExpActions = append(nodeSpec.ExpActions, NewNRIActionCommand())
+	}
+	return commandSpec
+}
+
+func NewNRIActionCommand() spec.ExpActionCommandSpec {
+	return &NRIActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: StartDelayFlag,
+					Desc: "delay of the start of the containers, such as 30s or 2m, the integer is seconds",
+				},
+				&spec.ExpFlag{
+					Name: NRICPUPercentFlag,
+					Desc: "cpu limit of the containers in percent of a cpu, such as 50 or 200",
+				},
+				&spec.ExpFlag{
+					Name: NRIMemLimitFlag,
+					Desc: "memory limit of the containers in megabytes",
+				},
+				&spec.ExpFlag{
+					Name: StartDelayPodNamespaceFlag,
+					Desc: "regular expression of the namespaces of the pods, such as ^shop$, default is all namespaces",
+				},
+				&spec.ExpFlag{
+					Name: StartDelayPodNameFlag,
+					Desc: "regular expression of the names of the pods, such as ^web-, default is all pods",
+				},
+				&spec.ExpFlag{
+					Name: StartDelayContainerNameFlag,
+					Desc: "regular expression of the names of the containers, such as ^app$, default is all containers",
+				},
+				&spec.ExpFlag{
+					Name:    NRIConfigFlag,
+					Desc:    "NRI config of containerd the plugin is registered in, default is /etc/nri/conf.json",
+					Default: nri.DefaultConfigFile,
+				},
+				&spec.ExpFlag{
+					Name:    NRIBinDirFlag,
+					Desc:    "NRI bin dir of containerd the plugin is copied into, default is /opt/nri/bin",
+					Default: nri.DefaultBinDir,
+				},
+			},
+			ActionExecutor: &nriExecutor{},
+			ActionLongDesc: "The containers created on the node and matching the selectors are changed before they " +
+				"start by the NRI plugin of containerd, their start is delayed and their cpu and memory limits are " +
+				"replaced, which the experiments injected after the start cannot do, e.g. the memory limit of the init " +
+				"of a jvm. The plugin is registered in the NRI config of containerd, which reads it on every start of " +
+				"the containers, and the last destroy unregisters it. The protocol is NRI v0.1 of containerd 1.5 and " +
+				"1.6, the experiment is refused for containerd 1.7, which replaced it by the ttrpc plugins of the NRI " +
+				"api, and for the other runtimes. NRI v0.1 is invoked after the process of the container is created, " +
+				"so the environment of the containers cannot be changed. The delay beyond the runtime request timeout of the kubelet, 2m by default, fails the start.",
+			ActionExample: `# Delay the start of the containers of the shop namespace by 30 seconds
+blade create cri node nri --delay 30s --pod-namespace ^shop$ --container-runtime containerd
+
+# Start the app container of the web pods with half a cpu and 256MB of memory
+blade create cri node nri --cpu-percent 50 --mem-limit 256 --pod-name ^web- --container-name ^app$ --container-runtime containerd`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+type NRIActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*NRIActionCommand) Name() string {
+	return "nri"
+}
+
+func (*NRIActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*NRIActionCommand) ShortDesc() string {
+	return "container faults before the start by the nri plugin"
+}
+
+func (c *NRIActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+type nriExecutor struct {
+}
+
+func (e *nriExecutor) Name() string {
+	return "node"
+}
+
+func (e *nriExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *nriExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flags := model.ActionFlags
+	configFile, binDir := flags[NRIConfigFlag], flags[NRIBinDirFlag]
+	if configFile == "" {
+		configFile = nri.DefaultConfigFile
+	}
+	if binDir == "" {
+		binDir = nri.DefaultBinDir
+	}
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return e.destroy(ctx, suid, configFile, binDir)
+	}
+	if tunnel := flags[SSHTunnelFlag.Name]; tunnel != "" {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, SSHTunnelFlag.Name, tunnel,
+			"the plugin is registered on the node, the ssh tunnel reaches the runtime api only")
+	}
+	rule := nri.Rule{Uid: uid, Selectors: make(map[string]string)}
+	if flags[StartDelayFlag] != "" {
+		delay, err := durations.Parse(flags[StartDelayFlag])
+		if err != nil || delay == 0 {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, StartDelayFlag, flags[StartDelayFlag],
+				"it must be a positive duration")
+		}
+		rule.Delay = delay
+	}
+	var response *spec.Response
+	if rule.CPUPercent, response = rangeFlag(flags, NRICPUPercentFlag, 0, 1, 100000); response != nil {
+		return response
+	}
+	memLimit, response := rangeFlag(flags, NRIMemLimitFlag, 0, 4, 1<<30)
+	if response != nil {
+		return response
+	}
+	rule.MemoryLimit = int64(memLimit) << 20
+	if rule.Delay == 0 && rule.CPUPercent == 0 && rule.MemoryLimit == 0 {
+		return spec.ResponseFailWithFlags(spec.ParameterLess,
+			fmt.Sprintf("%s|%s|%s", StartDelayFlag, NRICPUPercentFlag, NRIMemLimitFlag))
+	}
+	for flag, annotation := range map[string]string{
+		StartDelayPodNamespaceFlag:  nri.PodNamespaceAnnotation,
+		StartDelayPodNameFlag:       nri.PodNameAnnotation,
+		StartDelayContainerNameFlag: nri.ContainerNameAnnotation,
+	} {
+		if flags[flag] == "" {
+			continue
+		}
+		if _, err := regexp.Compile(flags[flag]); err != nil {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, flag, flags[flag], err)
+		}
+		rule.Selectors[annotation] = flags[flag]
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	name, version, err := client.RuntimeVersion(ctx)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "RuntimeVersion", err)
+	}
+	if name != container.ContainerdRuntime {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, ContainerRuntime.Name, name,
+			fmt.Sprintf("the NRI v0.1 plugins are invoked by %s only", container.ContainerdRuntime))
+	}
+	if !nri.SupportedRuntime(version) {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, ContainerRuntime.Name, name+" "+version,
+			"the NRI v0.1 plugins are invoked by containerd 1.5 and 1.6 only")
+	}
+	file, err := nri.SaveRule(nriRulesDir, rule)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save rule", err)
+	}
+	// the plugin without the rule does nothing, so the rule is saved first and removed if the plugin fails
+	if err := nri.Register(configFile, binDir, nriBinary(), nriRulesDir); err != nil {
+		nri.RemoveRule(nriRulesDir, uid)
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, NRIConfigFlag, configFile, err)
+	}
+	log.Infof(ctx, "the containers of %s %s matching %v are changed before the start by the NRI plugin, the rule is %s",
+		name, version, rule.Selectors, file)
+	return spec.ReturnSuccess(file)
+}
+
+// destroy removes the rule of the experiment, the plugin is unregistered after the last rule is removed
+func (e *nriExecutor) destroy(ctx context.Context, uid, configFile, binDir string) *spec.Response {
+	if err := nri.RemoveRule(nriRulesDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove rule", err)
+	}
+	if rules, errs := nri.LoadRules(nriRulesDir); len(rules) == 0 && len(errs) == 0 {
+		if err := nri.Unregister(configFile, binDir); err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "unregister plugin", err)
+		}
+		log.Infof(ctx, "the NRI plugin is unregistered from %s", configFile)
+	}
+	log.Infof(ctx, "the NRI rule of experiment %s is removed", uid)
+	return spec.ReturnSuccess(uid)
+}
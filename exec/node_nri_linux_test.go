@@ -0,0 +1,145 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nri"
+)
+
+// setupNRI returns the NRI config and bin dir of the test, the rules dir and the plugin binary are replaced
+func setupNRI(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	binary := path.Join(dir, NRIBin)
+	if err := os.WriteFile(binary, []byte("#!/bin/sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	originalRulesDir, originalBinary := nriRulesDir, nriBinary
+	nriRulesDir, nriBinary = path.Join(dir, "rules"), func() string { return binary }
+	t.Cleanup(func() { nriRulesDir, nriBinary = originalRulesDir, originalBinary })
+	return path.Join(dir, "nri", "conf.json"), path.Join(dir, "bin")
+}
+
+func runNRI(t *testing.T, runtime, uid string, ctx context.Context, flags map[string]string) *spec.Response {
+	t.Helper()
+	return runNRIVersion(t, runtime, "1.6.20", uid, ctx, flags)
+}
+
+func runNRIVersion(t *testing.T, runtime, version, uid string, ctx context.Context,
+	flags map[string]string) *spec.Response {
+	t.Helper()
+	client := mock.NewContainer()
+	client.RuntimeVersionFunc = func(ctx context.Context) (string, string, error) {
+		return runtime, version, nil
+	}
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	t.Cleanup(func() { NewClientFunc = nil })
+	model := &spec.ExpModel{Target: "node", ActionName: "nri", ActionFlags: flags}
+	return (&nriExecutor{}).Exec(uid, ctx, model)
+}
+
+func TestNRI(t *testing.T) {
+	configFile, binDir := setupNRI(t)
+	flags := func(extra map[string]string) map[string]string {
+		extra[NRIConfigFlag], extra[NRIBinDirFlag] = configFile, binDir
+		return extra
+	}
+	response := runNRI(t, container.ContainerdRuntime, "uid1", context.Background(), flags(map[string]string{
+		StartDelayFlag: "30s", StartDelayPodNamespaceFlag: "^shop$"}))
+	if !response.Success || response.Result != nri.RuleFile(nriRulesDir, "uid1") {
+		t.Fatalf("expected the rule saved, got %+v", response)
+	}
+	response = runNRI(t, container.ContainerdRuntime, "uid2", context.Background(), flags(map[string]string{
+		NRICPUPercentFlag: "50", NRIMemLimitFlag: "256", StartDelayContainerNameFlag: "^app$"}))
+	if !response.Success {
+		t.Fatalf("expected the second rule saved, got %+v", response)
+	}
+	rules, errs := nri.LoadRules(nriRulesDir)
+	if len(rules) != 2 || len(errs) != 0 || rules[0].Delay != 30*time.Second ||
+		rules[0].Selectors[nri.PodNamespaceAnnotation] != "^shop$" || rules[1].CPUPercent != 50 ||
+		rules[1].MemoryLimit != 256<<20 || rules[1].Selectors[nri.ContainerNameAnnotation] != "^app$" {
+		t.Errorf("unexpected rules %+v, %v", rules, errs)
+	}
+	if _, err := os.Stat(path.Join(binDir, nri.PluginType)); err != nil {
+		t.Errorf("expected the plugin installed, got %v", err)
+	}
+	// the plugin is unregistered by the destroy of the last experiment
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := runNRI(t, container.ContainerdRuntime, "uid1", ctx, flags(map[string]string{})); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if _, err := os.Stat(configFile); err != nil {
+		t.Errorf("expected the plugin kept for the other experiment, got %v", err)
+	}
+	ctx = spec.SetDestroyFlag(context.Background(), "uid2")
+	if response := runNRI(t, container.ContainerdRuntime, "uid2", ctx, flags(map[string]string{})); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if _, err := os.Stat(configFile); !os.IsNotExist(err) {
+		t.Errorf("expected the plugin unregistered, got %v", err)
+	}
+}
+
+func TestNRIRefused(t *testing.T) {
+	configFile, binDir := setupNRI(t)
+	tests := []struct {
+		runtime string
+		flags   map[string]string
+		code    int32
+	}{
+		{container.ContainerdRuntime, map[string]string{}, spec.ParameterLess.Code},
+		{container.ContainerdRuntime, map[string]string{StartDelayFlag: "0"}, spec.ParameterIllegal.Code},
+		{container.ContainerdRuntime, map[string]string{NRICPUPercentFlag: "0"}, spec.ParameterIllegal.Code},
+		{container.ContainerdRuntime, map[string]string{NRIMemLimitFlag: "1"}, spec.ParameterIllegal.Code},
+		{container.ContainerdRuntime, map[string]string{StartDelayFlag: "30s", StartDelayPodNameFlag: "web-("}, spec.ParameterIllegal.Code},
+		{container.ContainerdRuntime, map[string]string{StartDelayFlag: "30s", SSHTunnelFlag.Name: "root@worker-1"}, spec.ParameterInvalid.Code},
+		{crioRuntimeName, map[string]string{StartDelayFlag: "30s"}, spec.ParameterInvalid.Code},
+	}
+	for _, test := range tests {
+		test.flags[NRIConfigFlag], test.flags[NRIBinDirFlag] = configFile, binDir
+		response := runNRI(t, test.runtime, "uid1", context.Background(), test.flags)
+		if response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of %s %v, got %+v", test.code, test.runtime, test.flags, response)
+		}
+	}
+	// containerd 1.7 invokes the ttrpc plugins of the NRI api only
+	for _, version := range []string{"v1.7.2", "v1.4.13"} {
+		flags := map[string]string{StartDelayFlag: "30s", NRIConfigFlag: configFile, NRIBinDirFlag: binDir}
+		response := runNRIVersion(t, container.ContainerdRuntime, version, "uid1", context.Background(), flags)
+		if response.Success || response.Code != spec.ParameterInvalid.Code {
+			t.Errorf("expected containerd %s refused, got %+v", version, response)
+		}
+	}
+	if rules, _ := nri.LoadRules(nriRulesDir); len(rules) != 0 {
+		t.Errorf("expected no rule saved, got %+v", rules)
+	}
+	if _, err := os.Stat(configFile); !os.IsNotExist(err) {
+		t.Errorf("expected no plugin registered, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/ocihook"
+)
+
+// The flags of the start delay experiment
+const (
+	StartDelayFlag              = "delay"
+	StartDelayPodNamespaceFlag  = "pod-namespace"
+	StartDelayPodNameFlag       = "pod-name"
+	StartDelayContainerNameFlag = "container-name"
+	StartDelayHooksDirFlag      = "hooks-dir"
+)
+
+// crioRuntimeName is the runtime name of the cri Version of CRI-O, the only runtime reading the hooks dir
+const crioRuntimeName = "cri-o"
+
+// withStartDelayAction adds the start delay action to the node model, it writes the hooks dir of the node so it is
+// available on linux only
+func withStartDelayAction(commandSpec spec.ExpModelCommandSpec) spec.ExpModelCommandSpec {
+	if nodeSpec, ok := commandSpec.(*NodeCommandModelSpec); ok {
+		nodeSpec.ExpActions = append(nodeSpec.ExpActions, NewStartDelayActionCommand())
+	}
+	return commandSpec
+}
+
+func NewStartDelayActionCommand() spec.ExpActionCommandSpec {
+	return &StartDelayActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name:     StartDelayFlag,
+					Desc:     "delay of the start of the containers, such as 30s or 2m, the integer is seconds",
+					Required: true,
+				},
+				&spec.ExpFlag{
+					Name: StartDelayPodNamespaceFlag,
+					Desc: "regular expression of the namespaces of the pods delayed, such as ^shop$, default is all namespaces",
+				},
+				&spec.ExpFlag{
+					Name: StartDelayPodNameFlag,
+					Desc: "regular expression of the names of the pods delayed, such as ^web-, default is all pods",
+				},
+				&spec.ExpFlag{
+					Name: StartDelayContainerNameFlag,
+					Desc: "regular expression of the names of the containers delayed, such as ^app$, default is all containers",
+				},
+				&spec.ExpFlag{
+					Name:    StartDelayHooksDirFlag,
+					Desc:    "hooks dir of CRI-O the hook is written to, default is /etc/containers/oci/hooks.d",
+					Default: ocihook.DefaultDir,
+				},
+			},
+			ActionExecutor: &startDelayExecutor{},
+			ActionLongDesc: "The containers created on the node and matching the selectors are slow to start, an oci " +
+				"hook of the prestart stage sleeps the delay before the runtime starts their process, so the rollouts, " +
+				"the startup probes and the readiness of the pods are tested under slow starts. The hook is written to " +
+				"the hooks dir watched by CRI-O, the selectors match the annotations of the containers set by the " +
+				"kubelet, and the containers created after the destroy, which removes the hook, start as before. " +
+				"Containerd reads no hooks dir, the experiment is refused for the other runtimes.",
+			ActionExample: `# Delay the start of the containers of the shop namespace by 30 seconds
+blade create cri node start-delay --delay 30s --pod-namespace ^shop$ --container-runtime crio
+
+# Delay the app container of the web pods by 2 minutes, beyond the failure threshold of their startup probe
+blade create cri node start-delay --delay 2m --pod-name ^web- --container-name ^app$ --container-runtime crio`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+type StartDelayActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*StartDelayActionCommand) Name() string {
+	return "start-delay"
+}
+
+func (*StartDelayActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*StartDelayActionCommand) ShortDesc() string {
+	return "container start latency"
+}
+
+func (c *StartDelayActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+type startDelayExecutor struct {
+}
+
+func (e *startDelayExecutor) Name() string {
+	return "node"
+}
+
+func (e *startDelayExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *startDelayExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flags := model.ActionFlags
+	dir := flags[StartDelayHooksDirFlag]
+	if dir == "" {
+		dir = ocihook.DefaultDir
+	}
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		if err := ocihook.Remove(dir, suid); err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove hook", err)
+		}
+		log.Infof(ctx, "the start delay hook of experiment %s is removed", suid)
+		return spec.ReturnSuccess(suid)
+	}
+	if tunnel := flags[SSHTunnelFlag.Name]; tunnel != "" {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, SSHTunnelFlag.Name, tunnel,
+			"the hook is written on the node, the ssh tunnel reaches the runtime api only")
+	}
+	if flags[StartDelayFlag] == "" {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, StartDelayFlag)
+	}
+	delay, err := durations.Parse(flags[StartDelayFlag])
+	if err != nil || delay == 0 {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, StartDelayFlag, flags[StartDelayFlag],
+			"it must be a positive duration")
+	}
+	startDelay := ocihook.StartDelay{Delay: delay, Selectors: make(map[string]string)}
+	for flag, annotation := range map[string]string{
+		StartDelayPodNamespaceFlag:  ocihook.PodNamespaceAnnotation,
+		StartDelayPodNameFlag:       ocihook.PodNameAnnotation,
+		StartDelayContainerNameFlag: ocihook.ContainerNameAnnotation,
+	} {
+		if flags[flag] == "" {
+			continue
+		}
+		if _, err := regexp.Compile(flags[flag]); err != nil {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, flag, flags[flag], err)
+		}
+		startDelay.Selectors[annotation] = flags[flag]
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	name, version, err := client.RuntimeVersion(ctx)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "RuntimeVersion", err)
+	}
+	if name != crioRuntimeName {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, ContainerRuntime.Name, name,
+			fmt.Sprintf("the hooks dir is read by %s only", crioRuntimeName))
+	}
+	file, err := ocihook.Install(dir, uid, startDelay)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, StartDelayHooksDirFlag, dir, err)
+	}
+	log.Infof(ctx, "the containers of %s %s matching %v are delayed by %s, the hook is %s", name, version,
+		startDelay.Selectors, delay, file)
+	return spec.ReturnSuccess(file)
+}
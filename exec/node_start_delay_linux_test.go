@@ -0,0 +1,96 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/ocihook"
+)
+
+func runStartDelay(t *testing.T, runtime string, ctx context.Context, flags map[string]string) *spec.Response {
+	t.Helper()
+	client := mock.NewContainer()
+	client.RuntimeVersionFunc = func(ctx context.Context) (string, string, error) {
+		return runtime, "1.28.0", nil
+	}
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	t.Cleanup(func() { NewClientFunc = nil })
+	model := &spec.ExpModel{Target: "node", ActionName: "start-delay", ActionFlags: flags}
+	return (&startDelayExecutor{}).Exec("uid1", ctx, model)
+}
+
+func TestStartDelay(t *testing.T) {
+	dir := t.TempDir()
+	response := runStartDelay(t, crioRuntimeName, context.Background(), map[string]string{StartDelayFlag: "30s",
+		StartDelayPodNamespaceFlag: "^shop$", StartDelayHooksDirFlag: dir})
+	if !response.Success || response.Result != ocihook.File(dir, "uid1") {
+		t.Fatalf("expected the hook installed, got %+v", response)
+	}
+	content, err := os.ReadFile(ocihook.File(dir, "uid1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := ocihook.StartDelay{Delay: 30 * time.Second, Selectors: map[string]string{ocihook.PodNamespaceAnnotation: "^shop$"}}.Config()
+	if string(content) != string(expected) {
+		t.Errorf("expected the hook %s, got %s", expected, content)
+	}
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := runStartDelay(t, crioRuntimeName, ctx, map[string]string{StartDelayHooksDirFlag: dir}); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if _, err := os.Stat(ocihook.File(dir, "uid1")); !os.IsNotExist(err) {
+		t.Errorf("expected the hook removed, got %v", err)
+	}
+}
+
+func TestStartDelayRefused(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		runtime string
+		flags   map[string]string
+		code    int32
+	}{
+		{crioRuntimeName, map[string]string{}, spec.ParameterLess.Code},
+		{crioRuntimeName, map[string]string{StartDelayFlag: "0"}, spec.ParameterIllegal.Code},
+		{crioRuntimeName, map[string]string{StartDelayFlag: "30s", StartDelayPodNameFlag: "web-("}, spec.ParameterIllegal.Code},
+		{crioRuntimeName, map[string]string{StartDelayFlag: "30s", SSHTunnelFlag.Name: "root@worker-1"}, spec.ParameterInvalid.Code},
+		{"containerd", map[string]string{StartDelayFlag: "30s"}, spec.ParameterInvalid.Code},
+		{crioRuntimeName, map[string]string{StartDelayFlag: "30s", StartDelayHooksDirFlag: dir + "/missing"}, spec.ParameterInvalid.Code},
+	}
+	for _, test := range tests {
+		if test.flags[StartDelayHooksDirFlag] == "" {
+			test.flags[StartDelayHooksDirFlag] = dir
+		}
+		response := runStartDelay(t, test.runtime, context.Background(), test.flags)
+		if response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of %s %v, got %+v", test.code, test.runtime, test.flags, response)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no hook written, got %v", entries)
+	}
+}
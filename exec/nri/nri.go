@@ -0,0 +1,225 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package nri is the plugin of the NRI v0.1 protocol of containerd 1.5 and 1.6 applying the faults to the containers
+// before they start. Containerd invokes the plugins of its NRI config with the container lifecycle events, the create
+// event is sent after the task is created and before it is started, so the plugin delays the start of the container
+// and changes its resources in time, which the experiments injected after the start cannot. The process of the task
+// holds its environment already, so the plugin cannot change it. Containerd 1.7 and CRI-O invoke the ttrpc plugins of
+// the NRI api instead, which this package doesn't implement. The faults are the rules of the experiments in the rules
+// dir of the plugin, the plugin without rules does nothing.
+package nri
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// The paths of NRI v0.1 read by containerd, the plugin of the type is the binary of the same name in the bin dir
+const (
+	DefaultConfigFile = "/etc/nri/conf.json"
+	DefaultBinDir     = "/opt/nri/bin"
+)
+
+// PluginType is the type of the plugin in the NRI config and its binary name in the bin dir
+const PluginType = "chaosblade"
+
+// DefaultRulesDir is the rules dir of the plugin, it is cleared by the reboot of the node as the experiments are
+const DefaultRulesDir = "/var/run/chaosblade/nri"
+
+// Version is the NRI version of the config and the results
+const Version = "0.1"
+
+// The minor versions of containerd 1.x invoking the NRI v0.1 plugins of the NRI config
+const (
+	minContainerdMinor = 5
+	maxContainerdMinor = 6
+)
+
+// SupportedRuntime returns true if the containerd version invokes the NRI v0.1 plugins, such as v1.6.20 or
+// 1.5.6-k3s1, the versions not parsed aren't supported
+func SupportedRuntime(version string) bool {
+	fields := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(fields) < 2 || fields[0] != "1" {
+		return false
+	}
+	minor, err := strconv.Atoi(fields[1])
+	return err == nil && minor >= minContainerdMinor && minor <= maxContainerdMinor
+}
+
+// State is the container lifecycle event of the request
+type State int
+
+// The events of NRI v0.1
+const (
+	Create State = iota + 1
+	Delete
+	Update
+	Pause
+	Resume
+)
+
+// Spec is the part of the oci spec of the container sent to the plugins
+type Spec struct {
+	Resources   json.RawMessage   `json:"resources"`
+	Namespaces  map[string]string `json:"namespaces"`
+	CgroupsPath string            `json:"cgroupsPath"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// Request is the event read by the plugin from the stdin, the labels are the labels of the pod sandbox, and the conf
+// is the conf of the plugin in the NRI config
+type Request struct {
+	Version   string            `json:"version"`
+	ID        string            `json:"id"`
+	SandboxID string            `json:"sandboxID,omitempty"`
+	Pid       int               `json:"pid"`
+	State     State             `json:"state"`
+	Spec      *Spec             `json:"spec"`
+	Labels    map[string]string `json:"labels"`
+	Conf      json.RawMessage   `json:"conf,omitempty"`
+	Results   []*Result         `json:"results,omitempty"`
+}
+
+// Result is written by the plugin to the stdout, containerd fails the start of the container if the plugin fails
+type Result struct {
+	Version  string            `json:"version"`
+	Plugin   string            `json:"plugin"`
+	Labels   map[string]string `json:"labels"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// Conf is the conf of the plugin in the NRI config
+type Conf struct {
+	RulesDir string `json:"rulesDir"`
+}
+
+// Config is the NRI config of containerd, containerd reads it on every start of the containers, NRI is disabled if it
+// doesn't exist
+type Config struct {
+	Version string    `json:"version"`
+	Plugins []*Plugin `json:"plugins"`
+}
+
+// Plugin is a plugin of the NRI config, the plugins are invoked in order
+type Plugin struct {
+	Type string          `json:"type"`
+	Conf json.RawMessage `json:"conf,omitempty"`
+}
+
+func readConfig(file string) (*Config, error) {
+	content, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return &Config{Version: Version}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("illegal NRI config %s, %v", file, err)
+	}
+	return config, nil
+}
+
+// writeFile writes the file by a temporary file renamed, so containerd never reads a partial one
+func writeFile(file string, content []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return err
+	}
+	temp := file + ".tmp"
+	if err := os.WriteFile(temp, content, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, file); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
+
+// Register copies the plugin binary into the bin dir and appends the plugin to the NRI config, the config is created
+// if NRI is disabled. It's idempotent, the binary is replaced and the conf of the registered plugin is updated
+func Register(configFile, binDir, binary, rulesDir string) error {
+	config, err := readConfig(configFile)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(binary)
+	if err != nil {
+		return fmt.Errorf("the plugin binary %s is not found, %v", binary, err)
+	}
+	if err := writeFile(path.Join(binDir, PluginType), content, 0755); err != nil {
+		return err
+	}
+	conf, err := json.Marshal(Conf{RulesDir: rulesDir})
+	if err != nil {
+		return err
+	}
+	registered := false
+	for _, plugin := range config.Plugins {
+		if plugin.Type == PluginType {
+			plugin.Conf, registered = conf, true
+		}
+	}
+	if !registered {
+		config.Plugins = append(config.Plugins, &Plugin{Type: PluginType, Conf: conf})
+	}
+	content, err = json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(configFile, content, 0644)
+}
+
+// Unregister removes the plugin from the NRI config before its binary, so containerd never invokes a missing plugin
+// and fails the containers. The config left without plugins is removed, which disables NRI again
+func Unregister(configFile, binDir string) error {
+	config, err := readConfig(configFile)
+	if err != nil {
+		return err
+	}
+	plugins := make([]*Plugin, 0, len(config.Plugins))
+	for _, plugin := range config.Plugins {
+		if plugin.Type != PluginType {
+			plugins = append(plugins, plugin)
+		}
+	}
+	if len(plugins) == len(config.Plugins) {
+		// not registered, the config is not changed
+	} else if len(plugins) == 0 {
+		if err := os.Remove(configFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		config.Plugins = plugins
+		content, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFile(configFile, content, 0644); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(path.Join(binDir, PluginType)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nri
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+)
+
+func readTestConfig(t *testing.T, file string) Config {
+	t.Helper()
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config Config
+	if err := json.Unmarshal(content, &config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestRegister(t *testing.T) {
+	dir := t.TempDir()
+	configFile, binDir, binary := path.Join(dir, "nri", "conf.json"), path.Join(dir, "bin"), path.Join(dir, "chaos_nri")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(configFile, binDir, binary, "/rules"); err != nil {
+		t.Fatalf("register failed, %v", err)
+	}
+	// registered twice, the plugin is not duplicated
+	if err := Register(configFile, binDir, binary, "/run/rules"); err != nil {
+		t.Fatalf("register again failed, %v", err)
+	}
+	config := readTestConfig(t, configFile)
+	if config.Version != Version || len(config.Plugins) != 1 || config.Plugins[0].Type != PluginType {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	var conf Conf
+	if err := json.Unmarshal(config.Plugins[0].Conf, &conf); err != nil || conf.RulesDir != "/run/rules" {
+		t.Errorf("expected the conf of the plugin updated, got %s, %v", config.Plugins[0].Conf, err)
+	}
+	if info, err := os.Stat(path.Join(binDir, PluginType)); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected the plugin binary copied, got %v, %v", info, err)
+	}
+	if err := Unregister(configFile, binDir); err != nil {
+		t.Fatalf("unregister failed, %v", err)
+	}
+	if _, err := os.Stat(configFile); !os.IsNotExist(err) {
+		t.Errorf("expected the config without plugins removed, got %v", err)
+	}
+	if _, err := os.Stat(path.Join(binDir, PluginType)); !os.IsNotExist(err) {
+		t.Errorf("expected the plugin binary removed, got %v", err)
+	}
+}
+
+func TestRegisterKeepsPlugins(t *testing.T) {
+	dir := t.TempDir()
+	configFile, binary := path.Join(dir, "conf.json"), path.Join(dir, "chaos_nri")
+	os.WriteFile(binary, []byte("#!/bin/sh"), 0755)
+	os.WriteFile(configFile, []byte(`{"version":"0.1","plugins":[{"type":"clearcfs"}]}`), 0644)
+	if err := Register(configFile, dir, binary, DefaultRulesDir); err != nil {
+		t.Fatalf("register failed, %v", err)
+	}
+	if config := readTestConfig(t, configFile); len(config.Plugins) != 2 || config.Plugins[0].Type != "clearcfs" {
+		t.Errorf("expected the plugin appended, got %+v", config)
+	}
+	if err := Unregister(configFile, dir); err != nil {
+		t.Fatalf("unregister failed, %v", err)
+	}
+	if config := readTestConfig(t, configFile); len(config.Plugins) != 1 || config.Plugins[0].Type != "clearcfs" {
+		t.Errorf("expected the other plugins kept, got %+v", config)
+	}
+	if err := Register(configFile, dir, path.Join(dir, "missing"), DefaultRulesDir); err == nil {
+		t.Error("expected the missing binary refused")
+	}
+}
+
+func TestSupportedRuntime(t *testing.T) {
+	tests := map[string]bool{
+		"v1.5.6":      true,
+		"1.6.20":      true,
+		"v1.6.8-k3s1": true,
+		"v1.4.13":     false,
+		"v1.7.2":      false,
+		"2.0.0":       false,
+		"1":           false,
+		"dev":         false,
+		"v1.x.0":      false,
+	}
+	for version, expected := range tests {
+		if actual := SupportedRuntime(version); actual != expected {
+			t.Errorf("expected %v of %s, got %v", expected, version, actual)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nri
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// The metadata of the result, the rules applied to the container and the errors of the faults
+const (
+	RulesMetadata  = "chaosblade.io/rules"
+	ErrorsMetadata = "chaosblade.io/errors"
+)
+
+// cpuPeriod is the cfs period of the cpu limit in microseconds, the default of the runtimes
+const cpuPeriod = 100000
+
+// sleep waits the delay unless the invocation is cancelled, containerd kills the plugin when the request of the
+// kubelet times out
+var sleep = func(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Handle applies the rules matching the container of the create event, the strictest limits and the longest delay of
+// the rules win. The failures of the faults are reported in the result instead of failing the start of the container,
+// containerd would fail all the containers of the node otherwise
+func Handle(ctx context.Context, request *Request, rulesDir string) *Result {
+	result := &Result{Version: request.Version, Plugin: PluginType, Labels: request.Labels,
+		Metadata: make(map[string]string)}
+	if request.State != Create || request.Spec == nil {
+		return result
+	}
+	rules, errs := LoadRules(rulesDir)
+	applied, fault := make([]string, 0), Rule{}
+	for _, rule := range rules {
+		if !rule.Matches(request.Spec.Annotations) {
+			continue
+		}
+		applied = append(applied, rule.Uid)
+		if rule.Delay > fault.Delay {
+			fault.Delay = rule.Delay
+		}
+		if rule.CPUPercent > 0 && (fault.CPUPercent == 0 || rule.CPUPercent < fault.CPUPercent) {
+			fault.CPUPercent = rule.CPUPercent
+		}
+		if rule.MemoryLimit > 0 && (fault.MemoryLimit == 0 || rule.MemoryLimit < fault.MemoryLimit) {
+			fault.MemoryLimit = rule.MemoryLimit
+		}
+	}
+	if len(applied) > 0 {
+		result.Metadata[RulesMetadata] = strings.Join(applied, ",")
+		if fault.CPUPercent > 0 || fault.MemoryLimit > 0 {
+			if err := limitResources(request, fault); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if fault.Delay > 0 {
+			if err := sleep(ctx, fault.Delay); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		messages := make([]string, 0, len(errs))
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		result.Metadata[ErrorsMetadata] = strings.Join(messages, "; ")
+	}
+	return result
+}
+
+// limitResources writes the limits to the cgroup of the created container, its init process is in the cgroup already
+func limitResources(request *Request, fault Rule) error {
+	cgroupPath, err := container.ResolveCgroupPath(int32(request.Pid), request.Spec.CgroupsPath)
+	if err != nil {
+		return err
+	}
+	cpuDir, err := cgroupPath.Absolute("cpu")
+	if err != nil {
+		return err
+	}
+	memoryDir, err := cgroupPath.Absolute("memory")
+	if err != nil {
+		return err
+	}
+	return writeLimits(cgroupPath.Version, cpuDir, memoryDir, fault)
+}
+
+// writeLimits writes the cfs quota and the memory limit of the cgroup version
+func writeLimits(version int, cpuDir, memoryDir string, fault Rule) error {
+	if fault.CPUPercent > 0 {
+		var err error
+		quota := strconv.Itoa(fault.CPUPercent * cpuPeriod / 100)
+		if version == container.CgroupV2 {
+			err = writeCgroupFile(cpuDir, "cpu.max", fmt.Sprintf("%s %d", quota, cpuPeriod))
+		} else if err = writeCgroupFile(cpuDir, "cpu.cfs_period_us", strconv.Itoa(cpuPeriod)); err == nil {
+			err = writeCgroupFile(cpuDir, "cpu.cfs_quota_us", quota)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if fault.MemoryLimit > 0 {
+		file := "memory.limit_in_bytes"
+		if version == container.CgroupV2 {
+			file = "memory.max"
+		}
+		return writeCgroupFile(memoryDir, file, strconv.FormatInt(fault.MemoryLimit, 10))
+	}
+	return nil
+}
+
+func writeCgroupFile(dir, file, value string) error {
+	if err := os.WriteFile(path.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("write %s to %s failed, %v", value, path.Join(dir, file), err)
+	}
+	return nil
+}
+
+// Invoke reads the request from the stdin, handles it and writes the result to the stdout, the rules dir is the one
+// of the conf of the plugin
+func Invoke(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	request := &Request{}
+	if err := json.NewDecoder(stdin).Decode(request); err != nil {
+		return fmt.Errorf("illegal NRI request, %v", err)
+	}
+	conf := Conf{RulesDir: DefaultRulesDir}
+	if len(request.Conf) > 0 {
+		if err := json.Unmarshal(request.Conf, &conf); err != nil {
+			return fmt.Errorf("illegal conf of the plugin, %v", err)
+		}
+	}
+	return json.NewEncoder(stdout).Encode(Handle(ctx, request, conf.RulesDir))
+}
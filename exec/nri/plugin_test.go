@@ -0,0 +1,125 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nri
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+func stubSleep(t *testing.T) *time.Duration {
+	t.Helper()
+	slept := new(time.Duration)
+	original := sleep
+	sleep = func(ctx context.Context, delay time.Duration) error {
+		*slept += delay
+		return nil
+	}
+	t.Cleanup(func() { sleep = original })
+	return slept
+}
+
+func TestHandle(t *testing.T) {
+	slept := stubSleep(t)
+	dir := t.TempDir()
+	SaveRule(dir, Rule{Uid: "uid1", Delay: 30 * time.Second, Selectors: map[string]string{PodNamespaceAnnotation: "^shop$"}})
+	SaveRule(dir, Rule{Uid: "uid2", Delay: time.Minute, Selectors: map[string]string{PodNameAnnotation: "^web-"}})
+	SaveRule(dir, Rule{Uid: "uid3", Delay: time.Hour, Selectors: map[string]string{PodNamespaceAnnotation: "^kube-system$"}})
+	request := &Request{Version: Version, ID: "c1", State: Create, Labels: map[string]string{"app": "web"},
+		Spec: &Spec{Annotations: map[string]string{PodNamespaceAnnotation: "shop", PodNameAnnotation: "web-1",
+			ContainerTypeAnnotation: "container"}}}
+	result := Handle(context.Background(), request, dir)
+	if *slept != time.Minute {
+		t.Errorf("expected the longest delay of the matching rules, got %s", *slept)
+	}
+	if result.Plugin != PluginType || result.Labels["app"] != "web" || result.Metadata[RulesMetadata] != "uid1,uid2" ||
+		result.Metadata[ErrorsMetadata] != "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	// the other events and the pause containers are not delayed
+	*slept = 0
+	Handle(context.Background(), &Request{State: Delete, Spec: request.Spec}, dir)
+	request.Spec.Annotations[ContainerTypeAnnotation] = containerTypeSandbox
+	if result := Handle(context.Background(), request, dir); *slept != 0 || result.Metadata[RulesMetadata] != "" {
+		t.Errorf("expected nothing applied, got %s, %+v", *slept, result)
+	}
+}
+
+func TestHandleLimitFailed(t *testing.T) {
+	stubSleep(t)
+	dir := t.TempDir()
+	SaveRule(dir, Rule{Uid: "uid1", CPUPercent: 50})
+	result := Handle(context.Background(), &Request{State: Create, Spec: &Spec{}}, dir)
+	if result.Metadata[RulesMetadata] != "uid1" || result.Metadata[ErrorsMetadata] == "" {
+		t.Errorf("expected the failed limit reported instead of failing the container, got %+v", result)
+	}
+}
+
+func TestWriteLimits(t *testing.T) {
+	dir := t.TempDir()
+	fault := Rule{CPUPercent: 250, MemoryLimit: 256 << 20}
+	if err := writeLimits(container.CgroupV2, dir, dir, fault); err != nil {
+		t.Fatal(err)
+	}
+	for file, expected := range map[string]string{"cpu.max": "250000 100000", "memory.max": "268435456"} {
+		if content, _ := os.ReadFile(path.Join(dir, file)); string(content) != expected {
+			t.Errorf("expected %s of %s, got %s", expected, file, content)
+		}
+	}
+	cpuDir, memoryDir := path.Join(dir, "cpu"), path.Join(dir, "memory")
+	os.Mkdir(cpuDir, 0755)
+	os.Mkdir(memoryDir, 0755)
+	if err := writeLimits(container.CgroupV1, cpuDir, memoryDir, fault); err != nil {
+		t.Fatal(err)
+	}
+	for file, expected := range map[string]string{"cpu/cpu.cfs_period_us": "100000", "cpu/cpu.cfs_quota_us": "250000",
+		"memory/memory.limit_in_bytes": "268435456"} {
+		if content, _ := os.ReadFile(path.Join(dir, file)); string(content) != expected {
+			t.Errorf("expected %s of %s, got %s", expected, file, content)
+		}
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	slept := stubSleep(t)
+	dir := t.TempDir()
+	SaveRule(dir, Rule{Uid: "uid1", Delay: 5 * time.Second})
+	stdin := strings.NewReader(`{"version":"0.1","id":"c1","pid":1234,"state":1,"spec":{"cgroupsPath":"",` +
+		`"annotations":{"io.kubernetes.cri.container-type":"container"}},"conf":{"rulesDir":"` + dir + `"}}`)
+	var stdout bytes.Buffer
+	if err := Invoke(context.Background(), stdin, &stdout); err != nil {
+		t.Fatalf("invoke failed, %v", err)
+	}
+	var result Result
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil || result.Metadata[RulesMetadata] != "uid1" {
+		t.Errorf("unexpected result %s, %v", stdout.String(), err)
+	}
+	if *slept != 5*time.Second {
+		t.Errorf("expected the start delayed, got %s", *slept)
+	}
+	if err := Invoke(context.Background(), strings.NewReader("{"), &stdout); err == nil {
+		t.Error("expected the illegal request refused")
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nri
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The annotations of the containers created by the cri plugin of containerd, the selectors of the rules match them
+const (
+	ContainerTypeAnnotation = "io.kubernetes.cri.container-type"
+	ContainerNameAnnotation = "io.kubernetes.cri.container-name"
+	PodNamespaceAnnotation  = "io.kubernetes.cri.sandbox-namespace"
+	PodNameAnnotation       = "io.kubernetes.cri.sandbox-name"
+)
+
+// containerTypeSandbox is the container type of the pause containers, the rules never apply to them
+const containerTypeSandbox = "sandbox"
+
+// ruleSuffix is the suffix of the rule files, the rule of an experiment is named by its uid
+const ruleSuffix = ".json"
+
+// Rule is the fault of an experiment applied to the containers whose annotations match all the selectors, the
+// selectors are the regular expressions of the annotations, such as ^shop$ of the pod namespace
+type Rule struct {
+	Uid       string            `json:"uid"`
+	Selectors map[string]string `json:"selectors,omitempty"`
+	// Delay is the delay of the start of the containers
+	Delay time.Duration `json:"delay,omitempty"`
+	// CPUPercent is the cpu limit of the containers in percent of a cpu, such as 50 or 200
+	CPUPercent int `json:"cpuPercent,omitempty"`
+	// MemoryLimit is the memory limit of the containers in bytes
+	MemoryLimit int64 `json:"memoryLimit,omitempty"`
+}
+
+// Validate checks the rule has a fault and the selectors compile
+func (r Rule) Validate() error {
+	if r.Delay < 0 || r.CPUPercent < 0 || r.MemoryLimit < 0 {
+		return fmt.Errorf("the delay and the limits must not be negative")
+	}
+	if r.Delay == 0 && r.CPUPercent == 0 && r.MemoryLimit == 0 {
+		return fmt.Errorf("the rule has no fault, the delay or a limit is required")
+	}
+	for annotation, selector := range r.Selectors {
+		if _, err := regexp.Compile(selector); err != nil {
+			return fmt.Errorf("illegal selector %s of %s, %v", selector, annotation, err)
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the container of the annotations is selected, the pause containers never are
+func (r Rule) Matches(annotations map[string]string) bool {
+	if annotations[ContainerTypeAnnotation] == containerTypeSandbox {
+		return false
+	}
+	for annotation, selector := range r.Selectors {
+		pattern, err := regexp.Compile(selector)
+		if err != nil || !pattern.MatchString(annotations[annotation]) {
+			return false
+		}
+	}
+	return true
+}
+
+// RuleFile returns the rule file of the experiment in the rules dir
+func RuleFile(dir, uid string) string {
+	return path.Join(dir, uid+ruleSuffix)
+}
+
+// SaveRule writes the rule file of the experiment, the rules dir is created if not exists
+func SaveRule(dir string, rule Rule) (string, error) {
+	if err := rule.Validate(); err != nil {
+		return "", err
+	}
+	content, err := json.Marshal(rule)
+	if err != nil {
+		return "", err
+	}
+	file := RuleFile(dir, rule.Uid)
+	return file, writeFile(file, content, 0644)
+}
+
+// RemoveRule removes the rule file of the experiment, it succeeds if the file is removed already
+func RemoveRule(dir, uid string) error {
+	if err := os.Remove(RuleFile(dir, uid)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// LoadRules reads the rules of the rules dir ordered by the uid, the illegal files are returned as the errors instead
+// of failing the others, no rule is returned if the dir doesn't exist
+func LoadRules(dir string) ([]Rule, []error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, []error{err}
+	}
+	rules, errs := make([]Rule, 0, len(entries)), make([]error, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ruleSuffix) {
+			continue
+		}
+		content, err := os.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var rule Rule
+		if err := json.Unmarshal(content, &rule); err != nil {
+			errs = append(errs, fmt.Errorf("illegal rule %s, %v", entry.Name(), err))
+			continue
+		}
+		if err := rule.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("illegal rule %s, %v", entry.Name(), err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Uid < rules[j].Uid
+	})
+	return rules, errs
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nri
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRuleMatches(t *testing.T) {
+	rule := Rule{Uid: "uid1", Delay: time.Second, Selectors: map[string]string{PodNamespaceAnnotation: "^shop$",
+		ContainerNameAnnotation: "^app$"}}
+	tests := []struct {
+		annotations map[string]string
+		expected    bool
+	}{
+		{map[string]string{PodNamespaceAnnotation: "shop", ContainerNameAnnotation: "app"}, true},
+		{map[string]string{PodNamespaceAnnotation: "shop", ContainerNameAnnotation: "sidecar"}, false},
+		{map[string]string{PodNamespaceAnnotation: "shop"}, false},
+		{map[string]string{PodNamespaceAnnotation: "shop", ContainerNameAnnotation: "app",
+			ContainerTypeAnnotation: containerTypeSandbox}, false},
+	}
+	for _, test := range tests {
+		if matched := rule.Matches(test.annotations); matched != test.expected {
+			t.Errorf("expected %t of %v, got %t", test.expected, test.annotations, matched)
+		}
+	}
+	if !(Rule{Delay: time.Second}).Matches(map[string]string{ContainerTypeAnnotation: "container"}) {
+		t.Error("expected the rule without selectors matching all the containers")
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	for _, rule := range []Rule{
+		{Uid: "uid1"},
+		{Uid: "uid1", Delay: -time.Second},
+		{Uid: "uid1", CPUPercent: 50, Selectors: map[string]string{PodNameAnnotation: "web-("}},
+	} {
+		if err := rule.Validate(); err == nil {
+			t.Errorf("expected the rule %+v refused", rule)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	if rules, errs := LoadRules(dir + "/missing"); len(rules) != 0 || len(errs) != 0 {
+		t.Errorf("expected no rule of the missing dir, got %+v, %v", rules, errs)
+	}
+	for _, rule := range []Rule{{Uid: "uid2", MemoryLimit: 1 << 28}, {Uid: "uid1", Delay: time.Minute}} {
+		if _, err := SaveRule(dir, rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(RuleFile(dir, "broken"), []byte("{"), 0644)
+	os.WriteFile(RuleFile(dir, "uid3")+".tmp", []byte("{"), 0644)
+	rules, errs := LoadRules(dir)
+	if len(rules) != 2 || rules[0].Uid != "uid1" || rules[0].Delay != time.Minute || rules[1].MemoryLimit != 1<<28 {
+		t.Errorf("expected the rules ordered by the uid, got %+v", rules)
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected the broken rule reported, got %v", errs)
+	}
+	if err := RemoveRule(dir, "uid1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveRule(dir, "uid1"); err != nil {
+		t.Errorf("expected the removed rule removed again, got %v", err)
+	}
+}
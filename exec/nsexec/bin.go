@@ -0,0 +1,99 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nsexec
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"sync"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+)
+
+// machines are the architectures of the uname machines
+var machines = map[string]string{
+	"x86_64":  "amd64",
+	"amd64":   "amd64",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+}
+
+// elfArchs are the architectures of the elf machines
+var elfArchs = map[elf.Machine]string{
+	elf.EM_X86_64:  "amd64",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_PPC64:   "ppc64le",
+	elf.EM_S390:    "s390x",
+}
+
+var (
+	nodeArch     string
+	nodeArchOnce sync.Once
+)
+
+// NodeArch returns the architecture of the node, such as amd64 or arm64, it's detected once by the uname machine, and
+// falls back to the architecture of the program
+func NodeArch() string {
+	nodeArchOnce.Do(func() {
+		nodeArch = runtime.GOARCH
+		if arch, ok := machines[machine()]; ok {
+			nodeArch = arch
+		}
+	})
+	return nodeArch
+}
+
+// Bin returns the nsexec of the node architecture under the bin directory of the program, see ResolveBin
+func Bin() (string, error) {
+	return ResolveBin(path.Join(util.GetProgramPath(), spec.BinPath), NodeArch())
+}
+
+// ResolveBin returns the nsexec of the architecture in the bin directory, bin/<arch>/nsexec of the per-arch layout
+// first, then bin/nsexec if it's built for the architecture. It fails with the missing architecture rather than the
+// opaque exec format error of running the nsexec of another one
+func ResolveBin(binDir, arch string) (string, error) {
+	archBin := path.Join(binDir, arch, spec.NSExecBin)
+	if info, err := os.Stat(archBin); err == nil && !info.IsDir() {
+		return archBin, nil
+	}
+	bin := path.Join(binDir, spec.NSExecBin)
+	if _, err := os.Stat(bin); err != nil {
+		return "", fmt.Errorf("missing %s for %s, neither %s nor %s exists", spec.NSExecBin, arch, archBin, bin)
+	}
+	file, err := elf.Open(bin)
+	if err != nil {
+		// not an elf, such as a wrapper script, it's run as it is
+		return bin, nil
+	}
+	defer file.Close()
+	binArch, ok := elfArchs[file.Machine]
+	if file.Machine == elf.EM_PPC64 && file.ByteOrder == binary.BigEndian {
+		binArch = "ppc64"
+	}
+	if ok && binArch != arch {
+		return "", fmt.Errorf("missing %s for %s, %s is built for %s, put the %s one to %s", spec.NSExecBin, arch,
+			bin, binArch, arch, archBin)
+	}
+	return bin, nil
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nsexec
+
+import (
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolveBin(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if _, err := ResolveBin(dir, "arm64"); err == nil || !strings.Contains(err.Error(), "missing nsexec for arm64") {
+		t.Errorf("expected the missing nsexec, got %v", err)
+	}
+	// the flat nsexec is used only for its architecture
+	if err := os.Symlink(self, path.Join(dir, "nsexec")); err != nil {
+		t.Fatal(err)
+	}
+	if bin, err := ResolveBin(dir, runtime.GOARCH); err != nil || bin != path.Join(dir, "nsexec") {
+		t.Errorf("expected the flat nsexec, got %s, %v", bin, err)
+	}
+	other := "s390x"
+	if runtime.GOARCH == other {
+		other = "amd64"
+	}
+	if _, err := ResolveBin(dir, other); err == nil || !strings.Contains(err.Error(), "missing nsexec for "+other) {
+		t.Errorf("expected the nsexec of %s refused, got %v", runtime.GOARCH, err)
+	}
+	if err := os.MkdirAll(path.Join(dir, other), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(dir, other, "nsexec"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if bin, err := ResolveBin(dir, other); err != nil || bin != path.Join(dir, other, "nsexec") {
+		t.Errorf("expected the nsexec of the per-arch layout, got %s, %v", bin, err)
+	}
+	if arch := NodeArch(); arch == "" {
+		t.Errorf("expected the node architecture detected")
+	}
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package nsexec builds the nsexec and chaos_os command lines. The builders are pure, every argument is kept as one
+// argv element so the values containing spaces, quotes or unicode are passed through unchanged.
+package nsexec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-os/exec/model"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// Namespace is a namespace entered by nsexec
+type Namespace string
+
+const (
+	Pid   Namespace = "pid"
+	Mount Namespace = "mnt"
+	Net   Namespace = "net"
+	IPC   Namespace = "ipc"
+	UTS   Namespace = "uts"
+	// User is entered first, so the other namespaces owned by it are entered with its capabilities
+	User Namespace = "user"
+)
+
+// namespaceOrder is the order of the namespace options in the built commands
+var namespaceOrder = []Namespace{User, Pid, Mount, Net, IPC, UTS}
+
+var nsexecOptions = map[Namespace]string{
+	Pid:   "-p",
+	Mount: "-m",
+	Net:   "-n",
+	IPC:   "-i",
+	UTS:   "-u",
+	User:  "-U",
+}
+
+// chaos_os flags enabling the namespaces, only the namespaces supported by chaos_os are listed
+var chaosOsFlags = map[Namespace]string{
+	Pid:   model.NsPidFlag.Name,
+	Mount: model.NsMntFlag.Name,
+	Net:   model.NsNetFlag.Name,
+}
+
+// Command is a built command line
+type Command struct {
+	Path string
+	Args []string
+}
+
+// String returns the shell quoted command line, it is used by the logs
+func (c Command) String() string {
+	words := make([]string, 0, len(c.Args)+1)
+	words = append(words, Quote(c.Path))
+	for _, arg := range c.Args {
+		words = append(words, Quote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// Builder builds the nsexec command entering the namespaces of the target process
+type Builder struct {
+	bin        string
+	pid        int32
+	namespaces map[Namespace]bool
+	suspend    bool
+	argv       []string
+}
+
+// New returns the builder of the nsexec binary targeting the pid
+func New(bin string, pid int32) *Builder {
+	return &Builder{bin: bin, pid: pid, namespaces: make(map[Namespace]bool)}
+}
+
+// Namespaces adds the namespaces to enter, the duplicates are ignored
+func (b *Builder) Namespaces(namespaces ...Namespace) *Builder {
+	for _, namespace := range namespaces {
+		b.namespaces[namespace] = true
+	}
+	return b
+}
+
+// Suspend makes nsexec stop itself before executing the command, it is resumed by SIGCONT after joined the cgroups
+func (b *Builder) Suspend() *Builder {
+	b.suspend = true
+	return b
+}
+
+// Argv sets the command executed in the namespaces
+func (b *Builder) Argv(argv ...string) *Builder {
+	b.argv = append([]string{}, argv...)
+	return b
+}
+
+// Shell sets the script executed by /bin/sh in the namespaces
+func (b *Builder) Shell(script string) *Builder {
+	return b.Argv("/bin/sh", "-c", script)
+}
+
+// Build returns the command or the error if the pid, namespaces or argv is illegal
+func (b *Builder) Build() (Command, error) {
+	if b.bin == "" {
+		return Command{}, fmt.Errorf("nsexec binary is empty")
+	}
+	if b.pid <= 0 {
+		return Command{}, fmt.Errorf("illegal target pid %d", b.pid)
+	}
+	if len(b.argv) == 0 || b.argv[0] == "" {
+		return Command{}, fmt.Errorf("command is empty")
+	}
+	for namespace := range b.namespaces {
+		if _, ok := nsexecOptions[namespace]; !ok {
+			return Command{}, fmt.Errorf("unsupported namespace %s", namespace)
+		}
+	}
+	args := make([]string, 0, len(b.argv)+8)
+	if b.suspend {
+		args = append(args, "-s")
+	}
+	args = append(args, "-t", fmt.Sprintf("%d", b.pid))
+	for _, namespace := range namespaceOrder {
+		if b.namespaces[namespace] {
+			args = append(args, nsexecOptions[namespace])
+		}
+	}
+	args = append(args, "--")
+	args = append(args, b.argv...)
+	return Command{Path: b.bin, Args: args}, nil
+}
+
+// ChaosOsArgs are the chaos_os arguments of an experiment executed in the namespaces of the target process
+type ChaosOsArgs struct {
+	Destroy    bool
+	Target     string
+	Action     string
+	Flags      map[string]string
+	Uid        string
+	Pid        int32
+	Namespaces []Namespace
+}
+
+// Build returns the chaos_os argv, the flags are sorted by name and the empty values are skipped
+func (a ChaosOsArgs) Build() ([]string, error) {
+	if a.Target == "" || a.Action == "" {
+		return nil, fmt.Errorf("target or action is empty")
+	}
+	if a.Pid <= 0 {
+		return nil, fmt.Errorf("illegal target pid %d", a.Pid)
+	}
+	command := spec.Create
+	if a.Destroy {
+		command = spec.Destroy
+	}
+	args := []string{command, a.Target, a.Action}
+	names := make([]string, 0, len(a.Flags))
+	for name, value := range a.Flags {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("--%s=%s", name, a.Flags[name]))
+	}
+	args = append(args,
+		fmt.Sprintf("--uid=%s", a.Uid),
+		fmt.Sprintf("--%s=%s", model.ChannelFlag.Name, spec.NSExecBin),
+		fmt.Sprintf("--%s=%d", model.NsTargetFlag.Name, a.Pid),
+	)
+	enabled := make(map[Namespace]bool, len(a.Namespaces))
+	for _, namespace := range a.Namespaces {
+		if _, ok := chaosOsFlags[namespace]; !ok {
+			return nil, fmt.Errorf("chaos_os does not support namespace %s", namespace)
+		}
+		enabled[namespace] = true
+	}
+	for _, namespace := range namespaceOrder {
+		if enabled[namespace] {
+			args = append(args, fmt.Sprintf("--%s=%s", chaosOsFlags[namespace], spec.True))
+		}
+	}
+	return args, nil
+}
+
+// Quote returns the word quoted for the posix shell if it contains any special characters
+func Quote(word string) string {
+	if word == "" {
+		return "''"
+	}
+	for _, r := range word {
+		if !isSafe(r) {
+			return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+		}
+	}
+	return word
+}
+
+func isSafe(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-+=./:,@%", r)
+}
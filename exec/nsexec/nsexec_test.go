@@ -0,0 +1,157 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nsexec
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+const nsbin = "/opt/chaosblade/bin/nsexec"
+
+// golden renders the argv one per line and the quoted command line, and compares it with testdata/<name>.golden
+func golden(t *testing.T, name string, command Command) {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "path: %q\n", command.Path)
+	for i, arg := range command.Args {
+		fmt.Fprintf(&b, "arg[%d]: %q\n", i, arg)
+	}
+	fmt.Fprintf(&b, "shell: %s\n", command)
+	file := path.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(file, []byte(b.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read golden file failed, %v, run with -update to create it", err)
+	}
+	if b.String() != string(expected) {
+		t.Errorf("%s mismatch\n--- got\n%s--- expected\n%s", file, b.String(), expected)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+	}{
+		{"exec", New(nsbin, 1234).Namespaces(Net, Mount, Pid).Shell("cat /etc/hostname")},
+		{"copy-quotes", New(nsbin, 1234).Namespaces(Pid, Mount).
+			Shell("cat > " + Quote("/tmp/it's \"quoted\" $HOME/chaos.tar.gz"))},
+		{"copy-unicode", New(nsbin, 1234).Namespaces(Pid, Mount).
+			Argv("tar", "-zxf", "/数据/混沌 工程/chaosblade.tar.gz", "-C", "/数据/混沌 工程")},
+		{"hang", New(nsbin, 42).Suspend().Namespaces(Pid, Net, Pid).
+			Argv("/opt/chaosblade/bin/chaos_os", "create", "cpu", "fullload", "--cpu-percent=80")},
+		{"userns", New(nsbin, 4321).Namespaces(Mount, Pid, User).Shell("id -u")},
+		{"long", New(nsbin, 99999).Namespaces(Pid, Mount, Net, IPC, UTS).
+			Shell(strings.Repeat("echo chaosblade && ", 200) + "true")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, err := tt.builder.Build()
+			if err != nil {
+				t.Fatalf("build failed, %v", err)
+			}
+			golden(t, tt.name, command)
+		})
+	}
+}
+
+func TestBuilderIllegal(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+	}{
+		{"no bin", New("", 1).Argv("true")},
+		{"no pid", New(nsbin, 0).Argv("true")},
+		{"no argv", New(nsbin, 1)},
+		{"empty command", New(nsbin, 1).Argv("")},
+		{"unknown namespace", New(nsbin, 1).Namespaces("cgroup").Argv("true")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.builder.Build(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestChaosOsArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args ChaosOsArgs
+	}{
+		{"chaos-os-create", ChaosOsArgs{
+			Target: "network", Action: "delay", Uid: "3e5d1c0f", Pid: 1234,
+			Flags:      map[string]string{"time": "3000", "interface": "eth0", "offset": "", "exclude-port": "22,8080"},
+			Namespaces: []Namespace{Net, Pid},
+		}},
+		{"chaos-os-destroy", ChaosOsArgs{
+			Destroy: true, Target: "file", Action: "append", Uid: "3e5d1c0f", Pid: 1234,
+			Flags: map[string]string{
+				"filepath": "/var/log/混沌 app.log",
+				"content":  `it's a "chaos" $(reboot)`,
+			},
+			Namespaces: []Namespace{Mount, Pid},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.args.Build()
+			if err != nil {
+				t.Fatalf("build failed, %v", err)
+			}
+			golden(t, tt.name, Command{Path: "/opt/chaosblade/bin/chaos_os", Args: args})
+		})
+	}
+	if _, err := (ChaosOsArgs{Target: "cpu", Action: "load", Pid: 1, Namespaces: []Namespace{IPC}}).Build(); err == nil {
+		t.Error("expected error for the namespace unsupported by chaos_os")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := map[string]string{
+		"":             "''",
+		"/tmp/a.log":   "/tmp/a.log",
+		"--time=3000":  "--time=3000",
+		"a b":          "'a b'",
+		"it's":         `'it'\''s'`,
+		"$HOME":        "'$HOME'",
+		"混沌":           "'混沌'",
+		"a\nb":         "'a\nb'",
+		"x;rm -rf /":   "'x;rm -rf /'",
+		"`id`":         "'`id`'",
+		"\"double\"":   `'"double"'`,
+		"user@host:22": "user@host:22",
+	}
+	for word, expected := range tests {
+		if quoted := Quote(word); quoted != expected {
+			t.Errorf("Quote(%q) = %s, expected %s", word, quoted, expected)
+		}
+	}
+}
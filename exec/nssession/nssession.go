@@ -0,0 +1,322 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package nssession keeps one shell entered into the network namespace of the target process for the experiment, the
+// tc, iptables and ip scripts are written to its stdin and their outputs read back over the pipe, instead of spawning
+// nsexec for every script. The experiments injecting many rules pay for entering the namespace once:
+//
+//	ctx = nssession.WithPool(ctx, nssession.NewPool(nssession.StartNetns))
+//	output, err := pool.Run(ctx, pid, "tc qdisc show dev eth0")
+package nssession
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+)
+
+// ErrUnavailable is returned if the session can not be started, the caller runs the script by nsexec then
+var ErrUnavailable = errors.New("namespace session unavailable")
+
+// ScriptError is returned if the script exits with the non-zero status
+type ScriptError struct {
+	Script string
+	Status int
+	Output string
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("%s failed, exit status %d: %s", e.Script, e.Status, e.Output)
+}
+
+// ExitCode returns the exit status of the script
+func (e *ScriptError) ExitCode() int {
+	return e.Status
+}
+
+// Session is the shell started in the namespaces, the scripts are run one by one
+type Session struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	// marker ends the output of every script, it is random so no output is mistaken for it
+	marker string
+	broken error
+	// waitOnce reaps the shell once, it is waited by the close or after it is killed
+	waitOnce sync.Once
+	waitErr  error
+	// Runs are the scripts run by the session
+	Runs int
+}
+
+// Start starts the shell of the command, such as the nsexec command running /bin/sh in the namespaces
+func Start(path string, args []string, env []string) (*Session, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Env = env
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &Session{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout),
+		marker: "__chaosblade_session_" + hex.EncodeToString(buf) + "__"}, nil
+}
+
+// StartNetns starts the shell in the network namespace of the process, stamped by the experiment of the context
+func StartNetns(ctx context.Context, pid int32) (*Session, error) {
+	bin, err := nsexec.Bin()
+	if err != nil {
+		return nil, err
+	}
+	command, err := nsexec.New(bin, pid).Namespaces(nsexec.Net).Argv("/bin/sh").Build()
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf(ctx, "start session, %s", command)
+	return Start(command.Path, command.Args, trace.Environ(ctx))
+}
+
+// Run runs the script in the session and returns the combined output. The script is evaluated in a subshell with
+// the stdin of /dev/null, so its exit, its syntax errors and its reads do not affect the session. The session is
+// killed if the context is done before the script returns, it is broken then
+func (s *Session) Run(ctx context.Context, script string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Runs++
+	return s.run(ctx, script)
+}
+
+// probe checks the shell is running in the namespaces, it is not counted in the runs
+func (s *Session) probe(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.run(ctx, "true")
+	return err
+}
+
+func (s *Session) run(ctx context.Context, script string) (string, error) {
+	if s.broken != nil {
+		return "", s.broken
+	}
+	input := fmt.Sprintf("( eval %s ) </dev/null 2>&1; printf '\\n%s %%d\\n' \"$?\"\n", nsexec.Quote(script), s.marker)
+	type result struct {
+		output string
+		status int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		if _, err := io.WriteString(s.stdin, input); err != nil {
+			done <- result{err: err}
+			return
+		}
+		output, status, err := s.read()
+		done <- result{output, status, err}
+	}()
+	select {
+	case <-ctx.Done():
+		s.broken = fmt.Errorf("session killed, %v", ctx.Err())
+		s.kill()
+		return "", ctx.Err()
+	case r := <-done:
+		if r.err != nil {
+			s.broken = fmt.Errorf("session exited, %v", r.err)
+			s.kill()
+			return "", s.broken
+		}
+		if r.status != 0 {
+			return "", &ScriptError{Script: script, Status: r.status, Output: strings.TrimSpace(r.output)}
+		}
+		return r.output, nil
+	}
+}
+
+// read reads the output of the script up to the marker, the newline printed before the marker is dropped
+func (s *Session) read() (string, int, error) {
+	var output strings.Builder
+	for {
+		line, err := s.stdout.ReadString('\n')
+		if strings.HasPrefix(line, s.marker+" ") {
+			status, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, s.marker+" ")))
+			if err != nil {
+				return "", 0, fmt.Errorf("illegal status %q", line)
+			}
+			return strings.TrimSuffix(output.String(), "\n"), status, nil
+		}
+		output.WriteString(line)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+}
+
+// Broken returns true if the session can not run the scripts any more
+func (s *Session) Broken() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.broken != nil
+}
+
+// Close ends the shell by closing its stdin, it is killed if it is running a script, the shell is reaped either way,
+// so neither a zombie nor the reader of its pipe is left
+func (s *Session) Close() error {
+	if !s.mu.TryLock() {
+		s.kill()
+		s.wait()
+		return nil
+	}
+	defer s.mu.Unlock()
+	if s.broken == nil {
+		s.broken = errors.New("session closed")
+	}
+	s.stdin.Close()
+	return s.wait()
+}
+
+// kill kills the shell and reaps it in the background, the pipe is closed after it exits so the reader returns
+func (s *Session) kill() {
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+		go s.wait()
+	}
+}
+
+func (s *Session) wait() error {
+	s.waitOnce.Do(func() {
+		s.waitErr = s.cmd.Wait()
+	})
+	return s.waitErr
+}
+
+// StartFunc starts the session of the process
+type StartFunc func(ctx context.Context, pid int32) (*Session, error)
+
+// Pool is the sessions of the processes, started on the first script of the process
+type Pool struct {
+	mu       sync.Mutex
+	start    StartFunc
+	sessions map[int32]*Session
+}
+
+// NewPool returns the pool starting the sessions by the function
+func NewPool(start StartFunc) *Pool {
+	return &Pool{start: start, sessions: make(map[int32]*Session)}
+}
+
+// Run runs the script in the session of the process, the error wraps ErrUnavailable if the session can not be
+// started, and the broken session is replaced by the next script
+func (p *Pool) Run(ctx context.Context, pid int32, script string) (string, error) {
+	session, err := p.session(ctx, pid)
+	if err != nil {
+		return "", err
+	}
+	return session.Run(ctx, script)
+}
+
+func (p *Pool) session(ctx context.Context, pid int32) (*Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if session, ok := p.sessions[pid]; ok {
+		if !session.Broken() {
+			return session, nil
+		}
+		session.Close()
+		delete(p.sessions, pid)
+	}
+	session, err := p.start(ctx, pid)
+	if err == nil {
+		// the shell exits if the namespace can not be entered, it is probed before any script is written
+		if err = session.probe(ctx); err != nil {
+			session.Close()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w, start the session of process %d failed, %v", ErrUnavailable, pid, err)
+	}
+	p.sessions[pid] = session
+	return session, nil
+}
+
+// Close closes the sessions of the pool
+func (p *Pool) Close(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for pid, session := range p.sessions {
+		log.Debugf(ctx, "close the session of process %d, %d scripts run", pid, session.Runs)
+		session.Close()
+		delete(p.sessions, pid)
+	}
+}
+
+type poolKey struct{}
+
+// WithPool returns the context carrying the pool
+func WithPool(ctx context.Context, pool *Pool) context.Context {
+	return context.WithValue(ctx, poolKey{}, pool)
+}
+
+// FromContext returns the pool of the context, false if not set
+func FromContext(ctx context.Context) (*Pool, bool) {
+	pool, ok := ctx.Value(poolKey{}).(*Pool)
+	return pool, ok && pool != nil
+}
+
+// Executor runs the experiment with the pool of the network namespace sessions, they are closed after it
+type Executor struct {
+	spec.Executor
+}
+
+// Wrap returns the executor with the pool of the sessions
+func Wrap(executor spec.Executor) spec.Executor {
+	if _, ok := executor.(*Executor); ok {
+		return executor
+	}
+	return &Executor{Executor: executor}
+}
+
+func (e *Executor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := FromContext(ctx); ok {
+		return e.Executor.Exec(uid, ctx, model)
+	}
+	pool := NewPool(StartNetns)
+	defer pool.Close(ctx)
+	return e.Executor.Exec(uid, WithPool(ctx, pool), model)
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nssession
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// startShell starts the sessions on the shell of the host instead of the namespaces
+func startShell(starts *int) StartFunc {
+	return func(ctx context.Context, pid int32) (*Session, error) {
+		*starts++
+		return Start("/bin/sh", nil, nil)
+	}
+}
+
+func TestSessionRun(t *testing.T) {
+	session, err := Start("/bin/sh", nil, nil)
+	if err != nil {
+		t.Fatalf("start failed, %v", err)
+	}
+	defer session.Close()
+	ctx := context.Background()
+	for _, c := range []struct {
+		script, expected string
+	}{
+		{"echo a; echo b >&2", "a\nb\n"},
+		{"printf abc", "abc"},
+		{"echo 'it''s'; exit 0", "its\n"},
+		{"cat; echo read", "read\n"},
+		{"", ""},
+	} {
+		output, err := session.Run(ctx, c.script)
+		if err != nil || output != c.expected {
+			t.Errorf("expected %q of %q, got %q, %v", c.expected, c.script, output, err)
+		}
+	}
+	if _, err := session.Run(ctx, "echo failed; exit 3"); err == nil || !strings.Contains(err.Error(), "exit status 3: failed") {
+		t.Errorf("expected the exit status, got %v", err)
+	}
+	if _, err := session.Run(ctx, "echo 'unterminated"); err == nil {
+		t.Error("expected the syntax error")
+	}
+	if output, err := session.Run(ctx, "echo alive"); err != nil || output != "alive\n" || session.Runs != 8 {
+		t.Errorf("expected the session alive after 8 runs, got %q, %v, %d", output, err, session.Runs)
+	}
+}
+
+func TestSessionCancel(t *testing.T) {
+	session, err := Start("/bin/sh", nil, nil)
+	if err != nil {
+		t.Fatalf("start failed, %v", err)
+	}
+	defer session.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := session.Run(ctx, "sleep 10"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline exceeded, got %v", err)
+	}
+	if !session.Broken() {
+		t.Error("expected the session broken")
+	}
+	if _, err := session.Run(context.Background(), "true"); err == nil {
+		t.Error("expected the broken session failed")
+	}
+}
+
+func TestSessionCloseRunning(t *testing.T) {
+	session, err := Start("/bin/sh", nil, nil)
+	if err != nil {
+		t.Fatalf("start failed, %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := session.Run(context.Background(), "sleep 10")
+		done <- err
+	}()
+	// the script is running when the session is closed
+	for session.mu.TryLock() {
+		session.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	session.Close()
+	if session.cmd.ProcessState == nil {
+		t.Error("expected the killed shell reaped by the close")
+	}
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected the script of the closed session failed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the script returned after the close")
+	}
+	if err := session.Close(); err == nil {
+		t.Error("expected the error of the killed shell")
+	}
+}
+
+func TestPool(t *testing.T) {
+	starts := 0
+	pool := NewPool(startShell(&starts))
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		for _, pid := range []int32{1, 2} {
+			if output, err := pool.Run(ctx, pid, fmt.Sprintf("echo %d", pid)); err != nil || output != fmt.Sprintf("%d\n", pid) {
+				t.Fatalf("run in %d failed, %q, %v", pid, output, err)
+			}
+		}
+	}
+	if starts != 2 || pool.sessions[1].Runs != 3 {
+		t.Errorf("expected a session of 3 runs per process, got %d starts, %d runs", starts, pool.sessions[1].Runs)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	pool.Run(timeout, 1, "sleep 10")
+	if output, err := pool.Run(ctx, 1, "echo again"); err != nil || output != "again\n" || starts != 3 {
+		t.Errorf("expected the broken session replaced, got %q, %v, %d starts", output, err, starts)
+	}
+	pool.Close(ctx)
+	if len(pool.sessions) != 0 {
+		t.Errorf("expected the sessions closed, got %d", len(pool.sessions))
+	}
+}
+
+func TestPoolUnavailable(t *testing.T) {
+	for name, start := range map[string]StartFunc{
+		"start": func(ctx context.Context, pid int32) (*Session, error) {
+			return nil, errors.New("no nsexec")
+		},
+		"probe": func(ctx context.Context, pid int32) (*Session, error) {
+			// the shell exits at once like nsexec failed to enter the namespace
+			return Start("/bin/sh", []string{"-c", "exit 1"}, nil)
+		},
+	} {
+		if _, err := NewPool(start).Run(context.Background(), 1, "true"); !errors.Is(err, ErrUnavailable) {
+			t.Errorf("expected the %s failure unavailable, got %v", name, err)
+		}
+	}
+}
+
+type poolExecutor struct {
+	pool *Pool
+}
+
+func (e *poolExecutor) Name() string {
+	return "pool"
+}
+
+func (e *poolExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *poolExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	e.pool, _ = FromContext(ctx)
+	return spec.ReturnSuccess(uid)
+}
+
+func TestWrap(t *testing.T) {
+	inner := &poolExecutor{}
+	executor := Wrap(Wrap(inner))
+	if _, ok := executor.(*Executor).Executor.(*poolExecutor); !ok {
+		t.Fatal("expected the executor wrapped once")
+	}
+	executor.Exec("uid1", context.Background(), &spec.ExpModel{})
+	if inner.pool == nil {
+		t.Fatal("expected the pool in the context")
+	}
+	pool := NewPool(nil)
+	executor.Exec("uid1", WithPool(context.Background(), pool), &spec.ExpModel{})
+	if inner.pool != pool {
+		t.Error("expected the pool of the context kept")
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ocihook writes the oci hooks of the hooks dir of CRI-O delaying the start of the containers, the hook of
+// the prestart stage sleeps before the runtime starts the process of the container, so the container and its pod are
+// slow to start. CRI-O watches the hooks dir, the hook applies to the containers created after it is written.
+package ocihook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+)
+
+// DefaultDir is the hooks dir of CRI-O read before the one of the packages, /usr/share/containers/oci/hooks.d
+const DefaultDir = "/etc/containers/oci/hooks.d"
+
+// The annotations of the containers created by the kubelet, the selectors of the hook match them
+const (
+	PodNamespaceAnnotation  = "io.kubernetes.pod.namespace"
+	PodNameAnnotation       = "io.kubernetes.pod.name"
+	ContainerNameAnnotation = "io.kubernetes.container.name"
+)
+
+// hookTimeout is the margin of the hook timeout over the delay, the runtime kills the hook after the timeout
+const hookTimeout = 10
+
+// filePrefix is the name prefix of the hook files, the hook of an experiment is named by its uid
+const filePrefix = "chaosblade-start-delay-"
+
+// StartDelay delays the start of the containers whose annotations match all the selectors, the selectors are the
+// regular expressions of the annotations, such as ^shop$ of the pod namespace
+type StartDelay struct {
+	Delay     time.Duration
+	Selectors map[string]string
+}
+
+// hookConfig is the version 1.0.0 hook config of the containers/common hooks, the schema of the hooks dir of CRI-O
+type hookConfig struct {
+	Version string    `json:"version"`
+	Hook    hook      `json:"hook"`
+	When    condition `json:"when"`
+	Stages  []string  `json:"stages"`
+}
+
+type hook struct {
+	Path    string   `json:"path"`
+	Args    []string `json:"args"`
+	Timeout int      `json:"timeout"`
+}
+
+type condition struct {
+	Always      bool              `json:"always,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Validate checks the delay and the selectors, the selectors are compiled by CRI-O, an illegal one fails all the hooks
+func (d StartDelay) Validate() error {
+	if d.Delay <= 0 {
+		return fmt.Errorf("the delay must be positive")
+	}
+	for annotation, selector := range d.Selectors {
+		if _, err := regexp.Compile(selector); err != nil {
+			return fmt.Errorf("illegal selector %s of %s, %v", selector, annotation, err)
+		}
+	}
+	return nil
+}
+
+// Config returns the hook config, the hook sleeps in the runtime namespaces of the host, and always applies if there
+// is no selector
+func (d StartDelay) Config() ([]byte, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	config := hookConfig{
+		Version: "1.0.0",
+		Hook: hook{
+			Path:    "/bin/sh",
+			Args:    []string{"sh", "-c", "sleep " + durations.Sleep(d.Delay)},
+			Timeout: durations.Seconds(d.Delay) + hookTimeout,
+		},
+		Stages: []string{"prestart"},
+	}
+	if len(d.Selectors) == 0 {
+		config.When.Always = true
+	} else {
+		config.When.Annotations = d.Selectors
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
+
+// File returns the hook file of the experiment in the hooks dir
+func File(dir, uid string) string {
+	return path.Join(dir, filePrefix+uid+".json")
+}
+
+// Install writes the hook file of the experiment, it is written to a temporary file and renamed, so CRI-O never reads
+// a partial one
+func Install(dir, uid string, delay StartDelay) (string, error) {
+	content, err := delay.Config()
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("the hooks dir %s is not found", dir)
+	}
+	file := File(dir, uid)
+	// the temporary file has no json suffix, the hooks dir loads the json files only
+	temp := file + ".tmp"
+	if err := os.WriteFile(temp, content, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(temp, file); err != nil {
+		os.Remove(temp)
+		return "", err
+	}
+	return file, nil
+}
+
+// Remove removes the hook file of the experiment, it succeeds if the file is removed already
+func Remove(dir, uid string) error {
+	if err := os.Remove(File(dir, uid)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
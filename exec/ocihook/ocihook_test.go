@@ -0,0 +1,85 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ocihook
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	delay := StartDelay{Delay: 2500 * time.Millisecond, Selectors: map[string]string{PodNamespaceAnnotation: "^shop$"}}
+	content, err := delay.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config hookConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		t.Fatal(err)
+	}
+	expected := hookConfig{
+		Version: "1.0.0",
+		Hook:    hook{Path: "/bin/sh", Args: []string{"sh", "-c", "sleep 2.5"}, Timeout: 13},
+		When:    condition{Annotations: map[string]string{PodNamespaceAnnotation: "^shop$"}},
+		Stages:  []string{"prestart"},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected the config %+v, got %+v", expected, config)
+	}
+	content, _ = StartDelay{Delay: time.Second}.Config()
+	if json.Unmarshal(content, &config); !config.When.Always {
+		t.Errorf("expected the hook without selectors always applied, got %s", content)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, delay := range []StartDelay{
+		{},
+		{Delay: -time.Second},
+		{Delay: time.Second, Selectors: map[string]string{PodNameAnnotation: "web-("}},
+	} {
+		if err := delay.Validate(); err == nil {
+			t.Errorf("expected error of %+v", delay)
+		}
+	}
+}
+
+func TestInstall(t *testing.T) {
+	dir := t.TempDir()
+	file, err := Install(dir, "uid1", StartDelay{Delay: time.Second})
+	if err != nil || file != File(dir, "uid1") {
+		t.Fatalf("install failed, %s, %v", file, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected the hook file only, got %v", entries)
+	}
+	if err := Remove(dir, "uid1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected the hook removed, got %v", err)
+	}
+	if err := Remove(dir, "uid1"); err != nil {
+		t.Errorf("expected the absent hook removed, got %v", err)
+	}
+	if _, err := Install(dir+"/missing", "uid1", StartDelay{Delay: time.Second}); err == nil {
+		t.Error("expected the missing hooks dir refused")
+	}
+}
@@ -0,0 +1,423 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/holder"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+)
+
+// The flags of the pid experiments
+const (
+	PidCountFlag    = "count"
+	PidPercentFlag  = "percent"
+	PidRateFlag     = "rate"
+	PidDurationFlag = "duration"
+)
+
+// holderCmdline is the command line of the holders, it is exec'd by the shell so the children are kept as zombies
+const holderCmdline = "sleep 2147483647"
+
+// startInPidns starts the script by the shell of the host in the pid namespace and the cgroups of the process, it
+// returns the host pid of nsexec
+var startInPidns = func(ctx context.Context, pid int32, cgroupRoot, script string) (int, error) {
+	return startInCgroup(ctx, pid, cgroupRoot, []nsexec.Namespace{nsexec.Pid}, []string{"/bin/sh", "-c", script})
+}
+
+type PidCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewPidCommandSpec() spec.ExpModelCommandSpec {
+	return &PidCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&PidZombieActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:    PidCountFlag,
+								Desc:    "zombies spawned, 1 to 10000, default is 10",
+								Default: "10",
+							},
+							&spec.ExpFlag{
+								Name: "cgroup-root",
+								Desc: "cgroup root path, default value /sys/fs/cgroup",
+							},
+						},
+						ActionExecutor: &pidZombieExecutor{},
+						ActionLongDesc: "A holder in the pid namespace and the cgroups of the container spawns the " +
+							"children and never reaps them, so the zombies take the pids of the container like a " +
+							"leaking parent. The destroy kills the holder, the zombies are reaped by the init " +
+							"process of the container then, they are kept until the container exits if it never reaps.",
+						ActionExample: `# Spawn 100 zombies in the container
+blade create cri pid zombie --count 100 --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+				&PidExhaustActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:    PidPercentFlag,
+								Desc:    "percent of the pids limit used after the processes spawned, 1 to 99, default is 90",
+								Default: "90",
+							},
+							&spec.ExpFlag{
+								Name:    PidRateFlag,
+								Desc:    "processes spawned every second, 1 to 10000, default is 100",
+								Default: "100",
+							},
+							&spec.ExpFlag{
+								Name: PidDurationFlag,
+								Desc: "duration the processes are held, such as 90s or 1h, the integer is seconds, default or forever is until the experiment is destroyed",
+							},
+							&spec.ExpFlag{
+								Name: "cgroup-root",
+								Desc: "cgroup root path, default value /sys/fs/cgroup",
+							},
+						},
+						ActionExecutor: &pidExhaustExecutor{},
+						ActionLongDesc: "A holder in the pid namespace and the cgroups of the container spawns the " +
+							"sleeping processes at the rate until the pids used reach the percent of the pids limit, and " +
+							"holds them for the duration. The limit is the one with the least pids left among the cgroup " +
+							"of the container and its ancestors, such as the pod pids limit, the experiment is refused " +
+							"if none of them is limited. The holder reads the pids used before every spawn, so it stops " +
+							"early if the application takes the pids meanwhile.",
+						ActionExample: `# Use 95% of the pids limit of the container for 120 seconds
+blade create cri pid exhaust --percent 95 --duration 120 --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*PidCommandModelSpec) Name() string {
+	return "pid"
+}
+
+func (*PidCommandModelSpec) ShortDesc() string {
+	return "Pid experiment"
+}
+
+func (*PidCommandModelSpec) LongDesc() string {
+	return "Pid experiment, the pids of the container are taken by the processes of a holder."
+}
+
+type PidZombieActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*PidZombieActionCommand) Name() string {
+	return "zombie"
+}
+
+func (*PidZombieActionCommand) Aliases() []string {
+	return []string{"defunct"}
+}
+
+func (*PidZombieActionCommand) ShortDesc() string {
+	return "zombie processes"
+}
+
+func (c *PidZombieActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+type PidExhaustActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*PidExhaustActionCommand) Name() string {
+	return "exhaust"
+}
+
+func (*PidExhaustActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*PidExhaustActionCommand) ShortDesc() string {
+	return "pids exhaustion"
+}
+
+func (c *PidExhaustActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+type pidZombieExecutor struct {
+}
+
+func (e *pidZombieExecutor) Name() string {
+	return "pid"
+}
+
+func (e *pidZombieExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *pidZombieExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return releaseZombies(ctx, suid)
+	}
+	count, response := rangeFlag(model.ActionFlags, PidCountFlag, 10, 1, 10000)
+	if response != nil {
+		return response
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	// the children sleep until the holder exec'd, otherwise the shell may reap them
+	delay := 2 + count/200
+	starter, err := startInPidns(ctx, pid, getCgroupRoot(model), zombieScript(count, delay))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err)
+	}
+	state := holder.State{Uid: uid, Kind: "zombie", Starter: starter, Cmdline: holderCmdline, Count: count}
+	deadline := time.Now().Add(time.Duration(delay+10) * time.Second)
+	for {
+		if state.Holder == 0 {
+			state.Holder, _ = holder.Find(starter, holderCmdline)
+		}
+		if state.Holder > 0 && len(zombiesOf(state.Holder)) >= count {
+			break
+		}
+		if time.Now().After(deadline) {
+			holder.Release(state)
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "zombie",
+				fmt.Sprintf("%d zombies are not spawned in %d seconds", count, delay+10))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := holder.SaveState(util.GetProgramPath(), state); err != nil {
+		holder.Release(state)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	log.Infof(ctx, "%d zombies are spawned in the pid namespace of process %d, holder %d", count, pid, state.Holder)
+	return spec.ReturnSuccess(state)
+}
+
+// zombieScript spawns the children sleeping for the delay and execs the holder never reaping them
+func zombieScript(count, delay int) string {
+	return strings.Join([]string{
+		"i=0",
+		fmt.Sprintf("while [ $i -lt %d ]; do sleep %d & i=$((i+1)); done", count, delay),
+		"exec " + holderCmdline,
+	}, "\n")
+}
+
+// zombiesOf returns the zombie children of the process
+func zombiesOf(pid int) []int32 {
+	zombies := make([]int32, 0)
+	children, err := container.Children(int32(pid))
+	if err != nil {
+		return zombies
+	}
+	for _, child := range children {
+		if zombie, err := container.IsZombie(child); err == nil && zombie {
+			zombies = append(zombies, child)
+		}
+	}
+	return zombies
+}
+
+// releaseZombies kills the holder, the zombies not reaped by the init process of the container are reported
+func releaseZombies(ctx context.Context, uid string) *spec.Response {
+	stateDir := util.GetProgramPath()
+	state, err := holder.LoadState(stateDir, uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, stateDir, err)
+	}
+	zombies := zombiesOf(state.Holder)
+	if err := holder.Release(state); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "kill", err)
+	}
+	left := 0
+	for i := 0; i < 20; i++ {
+		left = 0
+		for _, zombie := range zombies {
+			if exited, err := container.IsZombie(zombie); err == nil && exited {
+				left++
+			}
+		}
+		if left == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if left > 0 {
+		log.Warnf(ctx, "%d zombies of experiment %s are not reaped by the init process of the container", left, uid)
+	}
+	if err := holder.RemoveState(stateDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove state", err)
+	}
+	return spec.ReturnSuccess(uid)
+}
+
+// PidExhaustResult is the result of the pid exhaust experiment
+type PidExhaustResult struct {
+	holder.State
+	Limit  container.PidsLimit `json:"limit"`
+	Target int                 `json:"target"`
+}
+
+type pidExhaustExecutor struct {
+}
+
+func (e *pidExhaustExecutor) Name() string {
+	return "pid"
+}
+
+func (e *pidExhaustExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *pidExhaustExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return releaseExhaust(ctx, suid)
+	}
+	flags := model.ActionFlags
+	percent, response := rangeFlag(flags, PidPercentFlag, 90, 1, 99)
+	if response != nil {
+		return response
+	}
+	rate, response := rangeFlag(flags, PidRateFlag, 100, 1, 10000)
+	if response != nil {
+		return response
+	}
+	duration, response := lifetimeFlag(flags, PidDurationFlag)
+	if response != nil {
+		return response
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	limit, err := readPidsLimit(pid)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, PidPercentFlag, percent, err)
+	}
+	target := limit.Max * percent / 100
+	if target <= limit.Current {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, PidPercentFlag, percent,
+			fmt.Sprintf("%d of %d pids are used already", limit.Current, limit.Max))
+	}
+	count := target - limit.Current
+	script := exhaustScript(uid, path.Join(limit.Dir, "pids.current"), count, target, rate, duration)
+	starter, err := startInPidns(ctx, pid, getCgroupRoot(model), script)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err)
+	}
+	state := holder.State{Uid: uid, Kind: "exhaust", Starter: starter, Cmdline: "/bin/sh -c " + script, Count: count}
+	for i := 0; i < 50; i++ {
+		if state.Holder, err = holder.Find(starter, state.Cmdline); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		holder.Release(state)
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err)
+	}
+	if err := holder.SaveState(util.GetProgramPath(), state); err != nil {
+		terminateProcess(state.Holder, exhaustMarker(uid))
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	log.Infof(ctx, "%d processes are spawned by %d every second in the cgroup %s of %d/%d pids, holder %d", count,
+		rate, limit.Dir, limit.Current, limit.Max, state.Holder)
+	return spec.ReturnSuccess(PidExhaustResult{State: state, Limit: limit, Target: target})
+}
+
+// readPidsLimit reads the pids limit of the cgroup of the process
+var readPidsLimit = func(pid int32) (container.PidsLimit, error) {
+	cgroupPath, err := container.ResolveCgroupPath(pid, "")
+	if err != nil {
+		return container.PidsLimit{}, err
+	}
+	return container.ReadPidsLimit(cgroupPath)
+}
+
+// exhaustMarker identifies the holder of the experiment by its command line
+func exhaustMarker(uid string) string {
+	return fmt.Sprintf(": chaosblade-pid-exhaust %s", uid)
+}
+
+// exhaustScript spawns the sleeping processes at the rate until the count spawned or the pids used of the cgroup
+// reach the target, the processes are killed and reaped by the holder when it is terminated or the duration ends
+func exhaustScript(uid, pidsCurrent string, count, target, rate int, duration time.Duration) string {
+	hold := "wait"
+	if duration > 0 {
+		hold = fmt.Sprintf("sleep %s & wait $!\nrelease", durations.Sleep(duration))
+	}
+	return strings.Join([]string{
+		exhaustMarker(uid),
+		`pids=""`,
+		`release() { [ -z "$pids" ] || kill $pids 2>/dev/null; wait; }`,
+		"trap 'release; exit 0' TERM INT HUP",
+		"i=0",
+		fmt.Sprintf("while [ $i -lt %d ]; do", count),
+		fmt.Sprintf("  read current < %s || break", nsexec.Quote(pidsCurrent)),
+		fmt.Sprintf(`  [ "$current" -lt %d ] || break`, target),
+		"  " + holderCmdline + " &",
+		`  pids="$pids $!"`,
+		"  i=$((i+1))",
+		fmt.Sprintf("  [ $((i %% %d)) -ne 0 ] || { sleep 1 & wait $!; }", rate),
+		"done",
+		hold,
+	}, "\n")
+}
+
+// releaseExhaust terminates the holder, it kills and reaps the processes spawned
+func releaseExhaust(ctx context.Context, uid string) *spec.Response {
+	stateDir := util.GetProgramPath()
+	state, err := holder.LoadState(stateDir, uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, stateDir, err)
+	}
+	if err := terminateProcess(state.Holder, exhaustMarker(uid)); err != nil {
+		log.Warnf(ctx, "terminate the holder %d of experiment %s failed, %v", state.Holder, uid, err)
+	}
+	if err := holder.Release(state); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "kill", err)
+	}
+	if err := holder.RemoveState(stateDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove state", err)
+	}
+	return spec.ReturnSuccess(uid)
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/holder"
+)
+
+// fakePidns replaces the pid namespace by the host, the scripts run are recorded
+func fakePidns(t *testing.T) *[]string {
+	t.Helper()
+	scripts := make([]string, 0)
+	origin := startInPidns
+	startInPidns = func(ctx context.Context, pid int32, cgroupRoot, script string) (int, error) {
+		scripts = append(scripts, script)
+		cmd := exec.Command("/bin/sh", "-c", script)
+		if err := cmd.Start(); err != nil {
+			return 0, err
+		}
+		go cmd.Wait()
+		t.Cleanup(func() { cmd.Process.Kill() })
+		return cmd.Process.Pid, nil
+	}
+	t.Cleanup(func() { startInPidns = origin })
+	return &scripts
+}
+
+func TestPidZombie(t *testing.T) {
+	fakeNetns(t, "")
+	scripts := fakePidns(t)
+	flags := map[string]string{ContainerIdFlag.Name: "c1", PidCountFlag: "5"}
+	model := &spec.ExpModel{Target: "pid", ActionName: "zombie", ActionFlags: flags}
+	response := (&pidZombieExecutor{}).Exec("uid1", context.Background(), model)
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	defer holder.RemoveState(util.GetProgramPath(), "uid1")
+	if len(*scripts) != 1 || (*scripts)[0] != zombieScript(5, 2) {
+		t.Errorf("unexpected scripts %q", *scripts)
+	}
+	state := response.Result.(holder.State)
+	if zombies := zombiesOf(state.Holder); len(zombies) != 5 {
+		t.Errorf("expected 5 zombies, got %v", zombies)
+	}
+
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := (&pidZombieExecutor{}).Exec("uid1", ctx, model); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if cmdline, err := holder.Cmdline(state.Holder); err == nil && cmdline != "" {
+		t.Errorf("expected the holder killed, got %s", cmdline)
+	}
+	if _, err := holder.LoadState(util.GetProgramPath(), "uid1"); !os.IsNotExist(err) {
+		t.Errorf("expected the state removed, got %v", err)
+	}
+}
+
+func TestPidZombieIllegal(t *testing.T) {
+	fakeNetns(t, "")
+	scripts := fakePidns(t)
+	for _, count := range []string{"0", "10001", "many"} {
+		flags := map[string]string{ContainerIdFlag.Name: "c1", PidCountFlag: count}
+		model := &spec.ExpModel{Target: "pid", ActionName: "zombie", ActionFlags: flags}
+		response := (&pidZombieExecutor{}).Exec("uid1", context.Background(), model)
+		if response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the illegal count %s, got %+v", count, response)
+		}
+	}
+	if len(*scripts) != 0 {
+		t.Errorf("expected nothing started, got %q", *scripts)
+	}
+}
+
+// fakePidsLimit replaces the pids limit of the container by the files in a temp dir
+func fakePidsLimit(t *testing.T, max, current int, currentFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(path.Join(dir, "pids.current"), []byte(currentFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	origin := readPidsLimit
+	readPidsLimit = func(pid int32) (container.PidsLimit, error) {
+		return container.PidsLimit{Dir: dir, Max: max, Current: current}, nil
+	}
+	t.Cleanup(func() { readPidsLimit = origin })
+}
+
+// spawned returns the sleeping processes of the holder
+func spawned(pid int) []int32 {
+	processes := make([]int32, 0)
+	children, _ := container.Children(int32(pid))
+	for _, child := range children {
+		if cmdline, err := holder.Cmdline(int(child)); err == nil && cmdline == holderCmdline {
+			processes = append(processes, child)
+		}
+	}
+	return processes
+}
+
+func TestPidExhaust(t *testing.T) {
+	fakeNetns(t, "")
+	fakePidns(t)
+	fakePidsLimit(t, 100, 90, "90\n")
+	flags := map[string]string{ContainerIdFlag.Name: "c1", PidPercentFlag: "95"}
+	model := &spec.ExpModel{Target: "pid", ActionName: "exhaust", ActionFlags: flags}
+	response := (&pidExhaustExecutor{}).Exec("uid1", context.Background(), model)
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	defer holder.RemoveState(util.GetProgramPath(), "uid1")
+	result := response.Result.(PidExhaustResult)
+	if result.Target != 95 || result.Count != 5 {
+		t.Errorf("expected 5 processes to 95 pids, got %+v", result)
+	}
+	var processes []int32
+	for i := 0; i < 50 && len(processes) < 5; i++ {
+		processes = spawned(result.Holder)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(processes) != 5 {
+		t.Fatalf("expected 5 processes spawned, got %v", processes)
+	}
+
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := (&pidExhaustExecutor{}).Exec("uid1", ctx, model); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	for _, process := range append(processes, int32(result.Holder)) {
+		if cmdline, err := holder.Cmdline(int(process)); err == nil && cmdline != "" {
+			t.Errorf("expected %d killed, got %s", process, cmdline)
+		}
+	}
+}
+
+func TestExhaustScriptGovernor(t *testing.T) {
+	dir := t.TempDir()
+	pidsCurrent := path.Join(dir, "pids.current")
+	if err := os.WriteFile(pidsCurrent, []byte("95\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the application took the pids, nothing is spawned
+	script := exhaustScript("uid1", pidsCurrent, 5, 95, 100, 0)
+	if output, err := exec.Command("/bin/sh", "-c", script+"\necho $i").CombinedOutput(); err != nil ||
+		strings.TrimSpace(string(output)) != "0" {
+		t.Errorf("expected nothing spawned, got %s, %v", output, err)
+	}
+}
+
+func TestPidExhaustIllegal(t *testing.T) {
+	fakeNetns(t, "")
+	scripts := fakePidns(t)
+	fakePidsLimit(t, 100, 90, "90\n")
+	tests := []struct {
+		flags map[string]string
+		code  int32
+	}{
+		{map[string]string{PidPercentFlag: "100"}, spec.ParameterIllegal.Code},
+		{map[string]string{PidRateFlag: "0"}, spec.ParameterIllegal.Code},
+		{map[string]string{PidDurationFlag: "-1"}, spec.ParameterIllegal.Code},
+		{map[string]string{PidPercentFlag: "90"}, spec.ParameterInvalid.Code},
+	}
+	for _, test := range tests {
+		test.flags[ContainerIdFlag.Name] = "c1"
+		model := &spec.ExpModel{Target: "pid", ActionName: "exhaust", ActionFlags: test.flags}
+		response := (&pidExhaustExecutor{}).Exec("uid1", context.Background(), model)
+		if response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of %v, got %+v", test.code, test.flags, response)
+		}
+	}
+	if len(*scripts) != 0 {
+		t.Errorf("expected nothing started, got %q", *scripts)
+	}
+}
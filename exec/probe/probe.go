@@ -0,0 +1,288 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// The probe types
+const (
+	TypeHTTP    = "http"
+	TypeCommand = "command"
+	TypeStats   = "stats"
+)
+
+const (
+	DefaultTimeout         = 5 * time.Second
+	DefaultRecoveryTimeout = time.Minute
+)
+
+// recoveryInterval is the interval between the evaluations waiting for the recovery
+var recoveryInterval = 2 * time.Second
+
+// Probe is a steady state check of the experiment target
+type Probe struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+	// URL and ExpectStatus are for the http probe, the status is expected to be 2xx if ExpectStatus is 0. The URL
+	// can be a path such as /healthz, it is requested on the pod ip of the experiment container and the Port, which
+	// is the first declared tcp port if not set
+	URL          string `json:"url,omitempty"`
+	Port         int32  `json:"port,omitempty"`
+	ExpectStatus int    `json:"expectStatus,omitempty"`
+	// Command runs in the experiment container, it is healthy if the command succeeds and the output contains ExpectOutput
+	Command      string `json:"command,omitempty"`
+	ExpectOutput string `json:"expectOutput,omitempty"`
+	// Metric of the experiment container is healthy if it is not above the Threshold, see the metric constants for the unit
+	Metric    string  `json:"metric,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	// Timeout of one evaluation, default is 5s
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Result of one probe evaluation
+type Result struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Healthy bool   `json:"healthy"`
+	Value   string `json:"value,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report is the probe results before the injection and after the revert
+type Report struct {
+	Before []Result `json:"before,omitempty"`
+	After  []Result `json:"after,omitempty"`
+	// Recovered is whether all probes are healthy again after the revert, RecoveryTime is how long it took
+	Recovered    bool   `json:"recovered"`
+	RecoveryTime string `json:"recoveryTime,omitempty"`
+}
+
+// Target is the experiment container, it is required by the command, the stats and the path http probes only
+type Target struct {
+	Client      container.Container
+	ContainerId string
+	// IP and Ports are the pod ip and the declared ports of the container for the path http probes
+	IP    string
+	Ports []container.Port
+}
+
+func (p Probe) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Type
+}
+
+// relative returns true if the url is a path on the experiment container
+func (p Probe) relative() bool {
+	return strings.HasPrefix(p.URL, "/")
+}
+
+func (p Probe) timeout() time.Duration {
+	if timeout, err := time.ParseDuration(p.Timeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return DefaultTimeout
+}
+
+// NeedContainer returns true if any probe runs against the experiment container
+func NeedContainer(probes []Probe) bool {
+	for _, p := range probes {
+		if p.Type == TypeCommand || p.Type == TypeStats || (p.Type == TypeHTTP && p.relative()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the probes
+func Validate(probes []Probe) error {
+	for _, p := range probes {
+		if p.Timeout != "" {
+			if _, err := time.ParseDuration(p.Timeout); err != nil {
+				return fmt.Errorf("illegal timeout `%s` of probe %s, %v", p.Timeout, p.name(), err)
+			}
+		}
+		switch p.Type {
+		case TypeHTTP:
+			if !p.relative() && !strings.HasPrefix(p.URL, "http://") && !strings.HasPrefix(p.URL, "https://") {
+				return fmt.Errorf("illegal url `%s` of probe %s", p.URL, p.name())
+			}
+			if p.Port < 0 || p.Port > 65535 {
+				return fmt.Errorf("illegal port %d of probe %s", p.Port, p.name())
+			}
+		case TypeCommand:
+			if p.Command == "" {
+				return fmt.Errorf("command of probe %s is required", p.name())
+			}
+		case TypeStats:
+			if _, ok := metricReaders[p.Metric]; !ok {
+				return fmt.Errorf("illegal metric `%s` of probe %s, it must be one of %s, %s or %s",
+					p.Metric, p.name(), MetricMemory, MetricCPU, MetricPids)
+			}
+		default:
+			return fmt.Errorf("illegal probe type `%s`, it must be one of %s, %s or %s", p.Type, TypeHTTP, TypeCommand, TypeStats)
+		}
+	}
+	return nil
+}
+
+// Evaluate runs all probes once, it returns true if all of them are healthy
+func Evaluate(ctx context.Context, target Target, probes []Probe) ([]Result, bool) {
+	results := make([]Result, 0, len(probes))
+	healthy := true
+	for _, p := range probes {
+		result := evaluate(ctx, target, p)
+		healthy = healthy && result.Healthy
+		results = append(results, result)
+	}
+	return results, healthy
+}
+
+// WaitRecovery evaluates the probes until all of them are healthy or the timeout, it returns the last results
+// and how long the recovery took
+func WaitRecovery(ctx context.Context, target Target, probes []Probe, timeout time.Duration) ([]Result, time.Duration, bool) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		results, healthy := Evaluate(ctx, target, probes)
+		if healthy {
+			return results, time.Since(start), true
+		}
+		select {
+		case <-ctx.Done():
+			return results, time.Since(start), false
+		case <-time.After(recoveryInterval):
+		}
+	}
+}
+
+func evaluate(ctx context.Context, target Target, p Probe) Result {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+	result := Result{Name: p.name(), Type: p.Type}
+	var err error
+	switch p.Type {
+	case TypeHTTP:
+		result.Value, err = httpProbe(ctx, target, p)
+	case TypeCommand:
+		result.Value, err = commandProbe(ctx, target, p)
+	case TypeStats:
+		result.Value, err = statsProbe(ctx, target, p)
+	default:
+		err = fmt.Errorf("unknown probe type `%s`", p.Type)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Healthy = true
+	return result
+}
+
+// probeURL returns the url of the http probe, the path is resolved against the pod ip and the port of the target
+func probeURL(target Target, p Probe) (string, error) {
+	if !p.relative() {
+		return p.URL, nil
+	}
+	if target.IP == "" {
+		return "", errors.New("the pod ip of the experiment container is not found")
+	}
+	port := p.Port
+	if port == 0 {
+		for _, declared := range target.Ports {
+			if declared.Protocol == "" || strings.EqualFold(declared.Protocol, "TCP") {
+				port = declared.ContainerPort
+				break
+			}
+		}
+	}
+	if port == 0 {
+		return "", errors.New("the port is required, the experiment container does not declare a tcp port")
+	}
+	return "http://" + net.JoinHostPort(target.IP, strconv.Itoa(int(port))) + p.URL, nil
+}
+
+func httpProbe(ctx context.Context, target Target, p Probe) (string, error) {
+	url, err := probeURL(target, p)
+	if err != nil {
+		return "", err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	response.Body.Close()
+	value := fmt.Sprintf("%d", response.StatusCode)
+	if p.ExpectStatus != 0 && response.StatusCode != p.ExpectStatus {
+		return value, fmt.Errorf("status %d, expected %d", response.StatusCode, p.ExpectStatus)
+	}
+	if p.ExpectStatus == 0 && (response.StatusCode < 200 || response.StatusCode >= 300) {
+		return value, fmt.Errorf("status %d, expected 2xx", response.StatusCode)
+	}
+	return value, nil
+}
+
+func commandProbe(ctx context.Context, target Target, p Probe) (string, error) {
+	if target.Client == nil || target.ContainerId == "" {
+		return "", errors.New("the experiment container is not found")
+	}
+	output, err := target.Client.ExecContainer(ctx, target.ContainerId, p.Command)
+	output = strings.TrimSpace(output)
+	if err != nil {
+		return output, err
+	}
+	if p.ExpectOutput != "" && !strings.Contains(output, p.ExpectOutput) {
+		return output, fmt.Errorf("output does not contain `%s`", p.ExpectOutput)
+	}
+	return output, nil
+}
+
+func statsProbe(ctx context.Context, target Target, p Probe) (string, error) {
+	if target.Client == nil || target.ContainerId == "" {
+		return "", errors.New("the experiment container is not found")
+	}
+	pid, err, _ := target.Client.GetPidById(ctx, target.ContainerId)
+	if err != nil {
+		return "", err
+	}
+	value, err := metricReaders[p.Metric](ctx, pid)
+	if err != nil {
+		return "", err
+	}
+	formatted := fmt.Sprintf("%.2f", value)
+	if value > p.Threshold {
+		return formatted, fmt.Errorf("%s %s is above the threshold %.2f", p.Metric, formatted, p.Threshold)
+	}
+	return formatted, nil
+}
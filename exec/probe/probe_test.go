@@ -0,0 +1,254 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		probes []Probe
+		legal  bool
+	}{
+		{"http", []Probe{{Type: TypeHTTP, URL: "https://example.com/healthz"}}, true},
+		{"path", []Probe{{Type: TypeHTTP, URL: "/healthz", Port: 8080, Timeout: "1s"}}, true},
+		{"command", []Probe{{Type: TypeCommand, Command: "cat /tmp/ready"}}, true},
+		{"stats", []Probe{{Type: TypeStats, Metric: MetricCPU, Threshold: 80}}, true},
+		{"scheme", []Probe{{Type: TypeHTTP, URL: "ftp://example.com"}}, false},
+		{"port", []Probe{{Type: TypeHTTP, URL: "/healthz", Port: 65536}}, false},
+		{"no command", []Probe{{Type: TypeCommand}}, false},
+		{"metric", []Probe{{Type: TypeStats, Metric: "disk"}}, false},
+		{"timeout", []Probe{{Type: TypeCommand, Command: "true", Timeout: "1 minute"}}, false},
+		{"type", []Probe{{Type: "tcp"}}, false},
+		{"second illegal", []Probe{{Type: TypeCommand, Command: "true"}, {Type: "tcp"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.probes); (err == nil) != tt.legal {
+				t.Errorf("expect legal %t, but got %v", tt.legal, err)
+			}
+		})
+	}
+}
+
+func TestNeedContainer(t *testing.T) {
+	if NeedContainer([]Probe{{Type: TypeHTTP, URL: "http://example.com"}}) {
+		t.Errorf("the absolute http probe does not need the container")
+	}
+	for _, p := range []Probe{{Type: TypeHTTP, URL: "/healthz"}, {Type: TypeCommand}, {Type: TypeStats}} {
+		if !NeedContainer([]Probe{{Type: TypeHTTP, URL: "http://example.com"}, p}) {
+			t.Errorf("the %s probe %s needs the container", p.Type, p.URL)
+		}
+	}
+}
+
+func TestProbeURL(t *testing.T) {
+	ports := []container.Port{{ContainerPort: 53, Protocol: "UDP"}, {ContainerPort: 8080, Protocol: "TCP"}}
+	tests := []struct {
+		name   string
+		target Target
+		probe  Probe
+		url    string
+	}{
+		{"absolute", Target{}, Probe{URL: "http://example.com/healthz"}, "http://example.com/healthz"},
+		{"declared tcp port", Target{IP: "10.0.0.1", Ports: ports}, Probe{URL: "/healthz"}, "http://10.0.0.1:8080/healthz"},
+		{"port", Target{IP: "10.0.0.1", Ports: ports}, Probe{URL: "/healthz", Port: 9090}, "http://10.0.0.1:9090/healthz"},
+		{"ipv6", Target{IP: "fd00::1"}, Probe{URL: "/healthz", Port: 80}, "http://[fd00::1]:80/healthz"},
+		{"no ip", Target{Ports: ports}, Probe{URL: "/healthz"}, ""},
+		{"no tcp port", Target{IP: "10.0.0.1", Ports: ports[:1]}, Probe{URL: "/healthz"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, err := probeURL(tt.target, tt.probe)
+			if tt.url == "" {
+				if err == nil {
+					t.Errorf("expect error, but got %s", url)
+				}
+				return
+			}
+			if err != nil || url != tt.url {
+				t.Errorf("expect %s, but got %s, %v", tt.url, url, err)
+			}
+		})
+	}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	var containerPort int32
+	fmt.Sscanf(port, "%d", &containerPort)
+	target := Target{IP: host, Ports: []container.Port{{ContainerPort: containerPort}}}
+	tests := []struct {
+		name    string
+		probe   Probe
+		healthy bool
+		value   string
+	}{
+		{"2xx", Probe{Type: TypeHTTP, URL: server.URL + "/healthz"}, true, "200"},
+		{"path", Probe{Type: TypeHTTP, URL: "/healthz"}, true, "200"},
+		{"not 2xx", Probe{Type: TypeHTTP, URL: server.URL + "/down"}, false, "503"},
+		{"expect status", Probe{Type: TypeHTTP, URL: "/down", ExpectStatus: http.StatusServiceUnavailable}, true, "503"},
+		{"unexpected status", Probe{Type: TypeHTTP, URL: "/healthz", ExpectStatus: http.StatusNoContent}, false, "200"},
+		{"timeout", Probe{Type: TypeHTTP, URL: "/slow", Timeout: "50ms"}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := evaluate(context.Background(), target, tt.probe)
+			if result.Healthy != tt.healthy || result.Value != tt.value || result.Type != TypeHTTP || result.Name != TypeHTTP {
+				t.Errorf("unexpected result %+v", result)
+			}
+			if !tt.healthy && result.Error == "" {
+				t.Errorf("the error of the unhealthy result is required")
+			}
+		})
+	}
+}
+
+func TestCommandProbe(t *testing.T) {
+	client := mock.NewContainer()
+	client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+		if command == "false" {
+			return "failed\n", errors.New("exit status 1")
+		}
+		return "ready\n", nil
+	}
+	target := Target{Client: client, ContainerId: "c1"}
+	tests := []struct {
+		name    string
+		target  Target
+		probe   Probe
+		healthy bool
+		value   string
+	}{
+		{"succeed", target, Probe{Name: "ready", Type: TypeCommand, Command: "cat /tmp/ready"}, true, "ready"},
+		{"expect output", target, Probe{Type: TypeCommand, Command: "cat /tmp/ready", ExpectOutput: "ready"}, true, "ready"},
+		{"unexpected output", target, Probe{Type: TypeCommand, Command: "cat /tmp/ready", ExpectOutput: "done"}, false, "ready"},
+		{"fail", target, Probe{Type: TypeCommand, Command: "false"}, false, "failed"},
+		{"no container", Target{Client: client}, Probe{Type: TypeCommand, Command: "true"}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := evaluate(context.Background(), tt.target, tt.probe)
+			if result.Healthy != tt.healthy || result.Value != tt.value {
+				t.Errorf("unexpected result %+v", result)
+			}
+		})
+	}
+	if calls := client.CallsOf("ExecContainer"); len(calls) != 4 || calls[0].Args[0] != "c1" {
+		t.Errorf("unexpected exec calls %v", calls)
+	}
+}
+
+func TestStatsProbe(t *testing.T) {
+	defer func(reader func(ctx context.Context, pid int32) (float64, error)) {
+		metricReaders[MetricMemory] = reader
+	}(metricReaders[MetricMemory])
+	metricReaders[MetricMemory] = func(ctx context.Context, pid int32) (float64, error) {
+		if pid != 100 {
+			return 0, fmt.Errorf("unexpected pid %d", pid)
+		}
+		return 1024, nil
+	}
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	client.Pids["c1"] = 100
+	target := Target{Client: client, ContainerId: "c1"}
+	result := evaluate(context.Background(), target, Probe{Type: TypeStats, Metric: MetricMemory, Threshold: 2048})
+	if !result.Healthy || result.Value != "1024.00" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	result = evaluate(context.Background(), target, Probe{Type: TypeStats, Metric: MetricMemory, Threshold: 512})
+	if result.Healthy || result.Value != "1024.00" || !strings.Contains(result.Error, "above the threshold") {
+		t.Errorf("unexpected result %+v", result)
+	}
+	client.GetPidByIdFunc = func(ctx context.Context, containerId string) (int32, error, int32) {
+		return 0, errors.New("container not found"), 0
+	}
+	if result = evaluate(context.Background(), target, Probe{Type: TypeStats, Metric: MetricMemory}); result.Healthy {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+// TestWaitRecovery fails the probe twice, the recovery is reported after the third evaluation, and the timeout
+// is reported if the probe never recovers
+func TestWaitRecovery(t *testing.T) {
+	defer func(interval time.Duration) { recoveryInterval = interval }(recoveryInterval)
+	recoveryInterval = 10 * time.Millisecond
+	var evaluations int32
+	client := mock.NewContainer()
+	client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+		if command == "false" || atomic.AddInt32(&evaluations, 1) < 3 {
+			return "", errors.New("exit status 1")
+		}
+		return "", nil
+	}
+	target := Target{Client: client, ContainerId: "c1"}
+	results, _, recovered := WaitRecovery(context.Background(), target, []Probe{{Type: TypeCommand, Command: "true"}}, time.Second)
+	if !recovered || evaluations != 3 || len(results) != 1 || !results[0].Healthy {
+		t.Errorf("expect recovered after 3 evaluations, but got %t after %d, %+v", recovered, evaluations, results)
+	}
+	results, elapsed, recovered := WaitRecovery(context.Background(), target, []Probe{{Type: TypeCommand, Command: "false"}}, 50*time.Millisecond)
+	if recovered || len(results) != 1 || results[0].Healthy || elapsed < 50*time.Millisecond {
+		t.Errorf("expect not recovered after the timeout, but got %t after %s, %+v", recovered, elapsed, results)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	client := mock.NewContainer()
+	client.ExecContainerFunc = func(ctx context.Context, containerId, command string) (string, error) {
+		if command == "false" {
+			return "", errors.New("exit status 1")
+		}
+		return "", nil
+	}
+	target := Target{Client: client, ContainerId: "c1"}
+	results, healthy := Evaluate(context.Background(), target, []Probe{
+		{Name: "first", Type: TypeCommand, Command: "true"},
+		{Name: "second", Type: TypeCommand, Command: "false"},
+	})
+	if healthy || len(results) != 2 || !results[0].Healthy || results[1].Healthy || results[1].Name != "second" {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if _, healthy = Evaluate(context.Background(), target, nil); !healthy {
+		t.Errorf("no probe is healthy")
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package probe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+// The container metrics of the stats probe
+const (
+	// MetricMemory is the memory usage in bytes
+	MetricMemory = "memory"
+	// MetricCPU is the cpu usage in percent of one core
+	MetricCPU = "cpu"
+	// MetricPids is the number of the processes
+	MetricPids = "pids"
+)
+
+const cpuSampleDelay = time.Second
+
+var metricReaders = map[string]func(ctx context.Context, pid int32) (float64, error){
+	MetricMemory: readMemory,
+	MetricCPU:    readCPU,
+	MetricPids:   readPids,
+}
+
+// readCgroupValue reads the v1 file of the controller if the controller is mounted, otherwise the v2 file
+func readCgroupValue(pid int32, controller, v1File, v2File string) (float64, error) {
+	cgroupPath, err := container.ResolveCgroupPath(pid, "")
+	if err != nil {
+		return 0, err
+	}
+	dir, err := cgroupPath.Absolute(controller)
+	if err != nil {
+		return 0, fmt.Errorf("cgroup of process %d not found", pid)
+	}
+	file := path.Join(dir, v2File)
+	if _, ok := cgroupPath.Controllers[controller]; ok {
+		file = path.Join(dir, v1File)
+	}
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(bytes)), 64)
+}
+
+func readMemory(ctx context.Context, pid int32) (float64, error) {
+	return readCgroupValue(pid, "memory", "memory.usage_in_bytes", "memory.current")
+}
+
+func readPids(ctx context.Context, pid int32) (float64, error) {
+	return readCgroupValue(pid, "pids", "pids.current", "pids.current")
+}
+
+// readCPU samples the cpu usage twice, the result is in percent of one core
+func readCPU(ctx context.Context, pid int32) (float64, error) {
+	first, err := readCPUUsage(pid)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-time.After(cpuSampleDelay):
+	}
+	second, err := readCPUUsage(pid)
+	if err != nil {
+		return 0, err
+	}
+	return float64(second-first) / float64(time.Since(start)) * 100, nil
+}
+
+// readCPUUsage returns the cpu usage of the cgroup in nanoseconds
+func readCPUUsage(pid int32) (time.Duration, error) {
+	cgroupPath, err := container.ResolveCgroupPath(pid, "")
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := cgroupPath.Controllers["cpuacct"]; ok {
+		dir, err := cgroupPath.Absolute("cpuacct")
+		if err != nil {
+			return 0, err
+		}
+		bytes, err := os.ReadFile(path.Join(dir, "cpuacct.usage"))
+		if err != nil {
+			return 0, err
+		}
+		usage, err := strconv.ParseInt(strings.TrimSpace(string(bytes)), 10, 64)
+		return time.Duration(usage), err
+	}
+	dir, err := cgroupPath.Absolute("")
+	if err != nil {
+		return 0, fmt.Errorf("cgroup of process %d not found", pid)
+	}
+	bytes, err := os.ReadFile(path.Join(dir, "cpu.stat"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(bytes), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usage, err := strconv.ParseInt(fields[1], 10, 64)
+			return time.Duration(usage) * time.Microsecond, err
+		}
+	}
+	return 0, fmt.Errorf("usage_usec not found in cpu.stat of process %d", pid)
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package profile loads the named experiment profiles shared by the teams using the agent, such as mild-latency or
+// aggressive-cpu. A profile pre-sets the flags of the experiment, pins the target flags the experiment is restricted
+// to and bounds how long it's kept injected, so the create request refers to the profile by name instead of copying
+// the parameters.
+package profile
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+)
+
+// Profile is a named bundle of the experiment defaults
+type Profile struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	// Target and Action are the experiment of the profile, the profile applies to any experiment if they are empty
+	Target string `yaml:"target" json:"target,omitempty"`
+	Action string `yaml:"action" json:"action,omitempty"`
+	// Flags are the defaults of the experiment flags, the flags of the request override them
+	Flags map[string]string `yaml:"flags" json:"flags,omitempty"`
+	// Policy pins the target flags, such as the container-namespace or the container-label-selector, the request
+	// can't override them, so the experiments of the profile never leave the targets of the team
+	Policy map[string]string `yaml:"policy" json:"policy,omitempty"`
+	// TTL is how long the experiment is kept injected before the agent destroys it, such as 10m, it's kept until
+	// destroyed if empty or forever
+	TTL string `yaml:"ttl" json:"ttl,omitempty"`
+}
+
+// file is the yaml of the profiles, the json is accepted as well
+type file struct {
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// Set is the profiles by name
+type Set map[string]Profile
+
+// Load reads the profiles from the file, such as
+//
+//	profiles:
+//	- name: mild-latency
+//	  target: network
+//	  action: delay
+//	  flags: {time: "100", offset: "20", interface: eth0}
+//	  policy: {container-namespace: k8s.io}
+//	  ttl: 10m
+func Load(path string) (Set, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var profiles file
+	if err := yaml.UnmarshalStrict(content, &profiles); err != nil {
+		return nil, fmt.Errorf("parse the profiles %s failed, %v", path, err)
+	}
+	set := make(Set, len(profiles.Profiles))
+	for _, profile := range profiles.Profiles {
+		if err := profile.Validate(); err != nil {
+			return nil, fmt.Errorf("illegal profile in %s, %v", path, err)
+		}
+		if _, ok := set[profile.Name]; ok {
+			return nil, fmt.Errorf("duplicate profile %s in %s", profile.Name, path)
+		}
+		set[profile.Name] = profile
+	}
+	return set, nil
+}
+
+// List returns the profiles sorted by name
+func (s Set) List() []Profile {
+	profiles := make([]Profile, 0, len(s))
+	for _, profile := range s {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+// Validate checks the name, the experiment and the ttl of the profile
+func (p Profile) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("the name of the profile is required")
+	}
+	if p.Action != "" && p.Target == "" {
+		return fmt.Errorf("the profile %s has the action %s but no target", p.Name, p.Action)
+	}
+	for name, value := range p.Policy {
+		if flag, ok := p.Flags[name]; ok && flag != value {
+			return fmt.Errorf("the flag %s of the profile %s is %s but pinned to %s by its policy", name, p.Name,
+				flag, value)
+		}
+	}
+	if _, err := p.Lifetime(); err != nil {
+		return fmt.Errorf("illegal ttl of the profile %s, %v", p.Name, err)
+	}
+	return nil
+}
+
+// Lifetime returns the ttl of the profile, 0 if the experiment is kept until destroyed
+func (p Profile) Lifetime() (time.Duration, error) {
+	if p.TTL == "" {
+		return 0, nil
+	}
+	return durations.Parse(p.TTL)
+}
+
+// Apply returns the target, the action and the flags of the experiment created by the profile. The target and the
+// action of the request must be those of the profile if both are given, the flags of the request override the
+// defaults of the profile but not the flags pinned by its policy
+func (p Profile) Apply(target, action string, flags map[string]string) (string, string, map[string]string, error) {
+	if p.Target != "" {
+		if target != "" && target != p.Target {
+			return "", "", nil, fmt.Errorf("the profile %s is for the %s experiments, not %s", p.Name, p.Target, target)
+		}
+		target = p.Target
+	}
+	if p.Action != "" {
+		if action != "" && action != p.Action {
+			return "", "", nil, fmt.Errorf("the profile %s is for the %s %s experiments, not %s", p.Name, p.Target,
+				p.Action, action)
+		}
+		action = p.Action
+	}
+	merged := make(map[string]string, len(p.Flags)+len(flags)+len(p.Policy))
+	for name, value := range p.Flags {
+		merged[name] = value
+	}
+	for name, value := range flags {
+		if pinned, ok := p.Policy[name]; ok && value != pinned {
+			return "", "", nil, fmt.Errorf("the flag %s is pinned to %s by the profile %s", name, pinned, p.Name)
+		}
+		merged[name] = value
+	}
+	for name, value := range p.Policy {
+		merged[name] = value
+	}
+	return target, action, merged, nil
+}
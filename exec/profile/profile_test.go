@@ -0,0 +1,120 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeProfiles(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	set, err := Load(writeProfiles(t, `
+profiles:
+- name: mild-latency
+  target: network
+  action: delay
+  flags: {time: "100", interface: eth0}
+  policy: {container-namespace: k8s.io}
+  ttl: 10m
+- name: team-a
+  policy: {container-label-selector: team=a}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles := set.List()
+	if len(profiles) != 2 || profiles[0].Name != "mild-latency" || profiles[1].Name != "team-a" {
+		t.Fatalf("unexpected profiles %+v", profiles)
+	}
+	if ttl, err := profiles[0].Lifetime(); err != nil || ttl != 10*time.Minute {
+		t.Errorf("unexpected ttl %v, %v", ttl, err)
+	}
+	if ttl, err := profiles[1].Lifetime(); err != nil || ttl != 0 {
+		t.Errorf("unexpected ttl %v, %v", ttl, err)
+	}
+	// the json is yaml too
+	if set, err := Load(writeProfiles(t, `{"profiles":[{"name":"aggressive-cpu","target":"cpu","action":"fullload",`+
+		`"flags":{"cpu-percent":"90"},"ttl":"5m"}]}`)); err != nil || set["aggressive-cpu"].Flags["cpu-percent"] != "90" {
+		t.Errorf("unexpected profiles %+v, %v", set, err)
+	}
+}
+
+func TestLoadIllegal(t *testing.T) {
+	tests := map[string]string{
+		"name of the profile is required": `profiles: [{target: cpu}]`,
+		"duplicate profile":               `profiles: [{name: a}, {name: a}]`,
+		"but no target":                   `profiles: [{name: a, action: delay}]`,
+		"pinned to k8s.io":                `profiles: [{name: a, flags: {container-namespace: default}, policy: {container-namespace: k8s.io}}]`,
+		"illegal ttl":                     `profiles: [{name: a, ttl: soon}]`,
+		"field timeout not found":         `profiles: [{name: a, timeout: 10m}]`,
+	}
+	for expected, content := range tests {
+		if _, err := Load(writeProfiles(t, content)); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expect the error containing %q, got %v", content, expected, err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	profile := Profile{
+		Name:   "mild-latency",
+		Target: "network",
+		Action: "delay",
+		Flags:  map[string]string{"time": "100", "interface": "eth0"},
+		Policy: map[string]string{"container-namespace": "k8s.io"},
+	}
+	target, action, flags, err := profile.Apply("", "", map[string]string{"time": "200", "container-id": "c1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"time": "200", "interface": "eth0", "container-id": "c1", "container-namespace": "k8s.io"}
+	if target != "network" || action != "delay" || !reflect.DeepEqual(flags, expected) {
+		t.Errorf("unexpected experiment %s %s %v", target, action, flags)
+	}
+	// the pinned value may be repeated
+	if _, _, _, err := profile.Apply("network", "delay", map[string]string{"container-namespace": "k8s.io"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, _, _, err := profile.Apply("", "", map[string]string{"container-namespace": "default"}); err == nil ||
+		!strings.Contains(err.Error(), "pinned to k8s.io") {
+		t.Errorf("expect the pinned error, got %v", err)
+	}
+	if _, _, _, err := profile.Apply("cpu", "", nil); err == nil || !strings.Contains(err.Error(), "not cpu") {
+		t.Errorf("expect the target error, got %v", err)
+	}
+	if _, _, _, err := profile.Apply("network", "loss", nil); err == nil || !strings.Contains(err.Error(), "not loss") {
+		t.Errorf("expect the action error, got %v", err)
+	}
+	// the profile of no experiment keeps the experiment of the request
+	policy := Profile{Name: "team-a", Policy: map[string]string{"container-label-selector": "team=a"}}
+	if target, action, flags, err := policy.Apply("cpu", "fullload", nil); err != nil || target != "cpu" ||
+		action != "fullload" || flags["container-label-selector"] != "team=a" {
+		t.Errorf("unexpected experiment %s %s %v, %v", target, action, flags, err)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package progress reports the progress of the experiments to the applications embedding the executors.
+// The writer is carried by the context passed to the executor:
+//
+//	ctx = progress.WithWriter(ctx, writer)
+//	response := executor.Exec(uid, ctx, model)
+package progress
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+// The phases of the experiment
+const (
+	PhaseCreate  = "create"
+	PhaseDestroy = "destroy"
+)
+
+// Writer receives the progress of the experiments, the callbacks are invoked synchronously in the executor
+// goroutine so they should return quickly
+type Writer interface {
+	// OnTargetResolved is invoked when the container of the experiment is found
+	OnTargetResolved(ctx context.Context, uid string, target container.ContainerInfo)
+	// OnInjected is invoked when the experiment is created, the result is the result of the executor response
+	OnInjected(ctx context.Context, uid string, result interface{})
+	// OnReverted is invoked when the experiment is destroyed
+	OnReverted(ctx context.Context, uid string, result interface{})
+	// OnError is invoked when the experiment failed, the err is an *Error
+	OnError(ctx context.Context, uid string, err error)
+}
+
+// WarningWriter is implemented by the writers receiving the warnings of the degraded operations, such as the stderr
+// of a command returned as its output, they are reported after the phase result
+type WarningWriter interface {
+	OnWarnings(ctx context.Context, uid string, warnings []warning.Warning)
+}
+
+// Error is the failure of an experiment phase
+type Error struct {
+	Phase   string
+	Code    int32
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s failed, code: %d, %s", e.Phase, e.Code, e.Message)
+}
+
+type writerKey struct{}
+
+// WithWriter returns the context carrying the writer
+func WithWriter(ctx context.Context, writer Writer) context.Context {
+	return context.WithValue(ctx, writerKey{}, writer)
+}
+
+// FromContext returns the writer of the context, a no-op writer if not set
+func FromContext(ctx context.Context) Writer {
+	if writer, ok := ctx.Value(writerKey{}).(Writer); ok && writer != nil {
+		return writer
+	}
+	return NopWriter{}
+}
+
+// NopWriter ignores the progress, it can be embedded to implement a part of the callbacks
+type NopWriter struct{}
+
+func (NopWriter) OnTargetResolved(ctx context.Context, uid string, target container.ContainerInfo) {}
+func (NopWriter) OnInjected(ctx context.Context, uid string, result interface{})                   {}
+func (NopWriter) OnReverted(ctx context.Context, uid string, result interface{})                   {}
+func (NopWriter) OnError(ctx context.Context, uid string, err error)                               {}
+
+// Executor reports the phase result of the wrapped executor to the writer of the context
+type Executor struct {
+	spec.Executor
+}
+
+// Wrap returns the executor reporting the progress
+func Wrap(executor spec.Executor) spec.Executor {
+	if _, ok := executor.(*Executor); ok {
+		return executor
+	}
+	return &Executor{Executor: executor}
+}
+
+func (e *Executor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	// the collector of the caller is reused, only the warnings of this experiment are reported
+	ctx, collector := warning.WithCollector(ctx)
+	collected := len(collector.List())
+	response := e.Executor.Exec(uid, ctx, model)
+	writer := FromContext(ctx)
+	if warningWriter, ok := writer.(WarningWriter); ok {
+		defer func() {
+			if warnings := collector.List()[collected:]; len(warnings) > 0 {
+				warningWriter.OnWarnings(ctx, uid, warnings)
+			}
+		}()
+	}
+	_, isDestroy := spec.IsDestroy(ctx)
+	phase := PhaseCreate
+	if isDestroy {
+		phase = PhaseDestroy
+	}
+	switch {
+	case !response.Success:
+		writer.OnError(ctx, uid, &Error{Phase: phase, Code: response.Code, Message: response.Err})
+	case isDestroy:
+		writer.OnReverted(ctx, uid, response.Result)
+	default:
+		writer.OnInjected(ctx, uid, response.Result)
+	}
+	return response
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/progress"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/warning"
+)
+
+type recordingWriter struct {
+	progress.NopWriter
+	events []string
+}
+
+func (w *recordingWriter) OnTargetResolved(ctx context.Context, uid string, target container.ContainerInfo) {
+	w.events = append(w.events, fmt.Sprintf("resolved %s %s", uid, target.ContainerId))
+}
+
+func (w *recordingWriter) OnInjected(ctx context.Context, uid string, result interface{}) {
+	w.events = append(w.events, "injected "+uid)
+}
+
+func (w *recordingWriter) OnReverted(ctx context.Context, uid string, result interface{}) {
+	w.events = append(w.events, "reverted "+uid)
+}
+
+func (w *recordingWriter) OnError(ctx context.Context, uid string, err error) {
+	var progressErr *progress.Error
+	if errors.As(err, &progressErr) {
+		w.events = append(w.events, fmt.Sprintf("error %s %s", uid, progressErr.Phase))
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	executor := GetAllExecutors()[GetExecutorKey("container", "remove")]
+	writer := &recordingWriter{}
+	ctx := progress.WithWriter(context.Background(), writer)
+	model := func() *spec.ExpModel {
+		return &spec.ExpModel{Target: "container", ActionName: "remove",
+			ActionFlags: map[string]string{ContainerIdFlag.Name: "c1"}}
+	}
+
+	executor.Exec("u1", ctx, model())
+	executor.Exec("u1", spec.SetDestroyFlag(ctx, "u1"), model())
+	executor.Exec("u2", ctx, model())
+
+	expected := []string{"resolved u1 c1", "injected u1", "reverted u1", "error u2 create"}
+	if !reflect.DeepEqual(writer.events, expected) {
+		t.Errorf("expected events %v, got %v", expected, writer.events)
+	}
+	if executor.Name() != "remove" {
+		t.Errorf("the wrapped executor name is %s", executor.Name())
+	}
+}
+
+type warningWriter struct {
+	progress.NopWriter
+	warnings []string
+}
+
+func (w *warningWriter) OnWarnings(ctx context.Context, uid string, warnings []warning.Warning) {
+	for _, warning := range warnings {
+		w.warnings = append(w.warnings, fmt.Sprintf("%s %s: %s", uid, warning.Source, warning.Message))
+	}
+}
+
+type warningExecutor struct {
+	spec.Executor
+}
+
+func (e *warningExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	warning.Add(ctx, "ExecContainer", "the stderr is returned")
+	return spec.ReturnSuccess(uid)
+}
+
+func TestProgressWarnings(t *testing.T) {
+	writer := &warningWriter{}
+	ctx, collector := warning.WithCollector(progress.WithWriter(context.Background(), writer))
+	executor := progress.Wrap(&warningExecutor{})
+	executor.Exec("u1", ctx, &spec.ExpModel{})
+	executor.Exec("u2", ctx, &spec.ExpModel{})
+
+	expected := []string{"u1 ExecContainer: the stderr is returned", "u2 ExecContainer: the stderr is returned"}
+	if !reflect.DeepEqual(writer.warnings, expected) {
+		t.Errorf("expected warnings %v, got %v", expected, writer.warnings)
+	}
+	if len(collector.List()) != 2 {
+		t.Errorf("expected the warnings collected by the caller, got %+v", collector.List())
+	}
+}
@@ -0,0 +1,203 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package qdisc inspects the qdiscs of the interface in the network namespace of the pod before the tc experiments,
+// the qdiscs installed by the shapers of the CNI are reported as conflicts instead of being replaced, and the netem
+// is chained under the tbf of the bandwidth plugin if requested.
+package qdisc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+)
+
+// The ways to handle the conflicts
+const (
+	// ModeRefuse refuses the experiment with the conflicts
+	ModeRefuse = "refuse"
+	// ModeChain adds the netem as the child of the tbf root qdisc
+	ModeChain = "chain"
+)
+
+// chainHandle is the handle of the netem chained under the tbf, the qdisc of other handles is never deleted
+const chainHandle = "1bac:"
+
+// defaultKinds are the root qdiscs attached by the kernel, they are replaced by the tc experiments
+var defaultKinds = map[string]bool{"noqueue": true, "pfifo_fast": true, "fq_codel": true, "mq": true, "pfifo": true}
+
+// Qdisc is a qdisc in the output of the ShowScript
+type Qdisc struct {
+	Kind   string
+	Handle string
+	// Parent is empty for the root qdisc
+	Parent string
+}
+
+func (q Qdisc) String() string {
+	if q.Parent == "" {
+		return fmt.Sprintf("%s %s root", q.Kind, q.Handle)
+	}
+	return fmt.Sprintf("%s %s parent %s", q.Kind, q.Handle, q.Parent)
+}
+
+// ShowScript shows the qdiscs of the interface
+func ShowScript(iface string) string {
+	return fmt.Sprintf("tc qdisc show dev %s", nsexec.Quote(iface))
+}
+
+// Parse parses the output of the ShowScript
+func Parse(output string) []Qdisc {
+	qdiscs := make([]Qdisc, 0)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "qdisc" {
+			continue
+		}
+		qdisc := Qdisc{Kind: fields[1], Handle: fields[2]}
+		if fields[3] == "parent" && len(fields) > 4 {
+			qdisc.Parent = fields[4]
+		}
+		qdiscs = append(qdiscs, qdisc)
+	}
+	return qdiscs
+}
+
+// Conflict is a qdisc which the tc experiments would replace
+type Conflict struct {
+	Qdisc Qdisc
+	// Owner is who installs the qdisc probably
+	Owner string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s installed by %s", c.Qdisc, c.Owner)
+}
+
+// Inspect returns the conflicts of the qdiscs, the clsact and the ingress qdiscs of the eBPF datapaths and the
+// ingress policing are kept by the tc experiments, so they never conflict
+func Inspect(qdiscs []Qdisc) []Conflict {
+	conflicts := make([]Conflict, 0)
+	for _, qdisc := range qdiscs {
+		var owner string
+		switch {
+		case qdisc.Handle == chainHandle:
+			owner = "another chaos experiment chained under the tbf"
+		case qdisc.Kind == "fq":
+			owner = "the Cilium bandwidth manager"
+		case qdisc.Parent != "":
+			continue
+		case qdisc.Handle == "0:" && defaultKinds[qdisc.Kind]:
+			continue
+		case qdisc.Kind == "tbf":
+			owner = "the bandwidth plugin of the CNI"
+		case qdisc.Kind == "htb" || qdisc.Kind == "hfsc" || qdisc.Kind == "cbq":
+			owner = "a traffic shaper such as kube-router"
+		case qdisc.Kind == "netem" || qdisc.Kind == "prio":
+			owner = "another chaos experiment"
+		default:
+			owner = "an unknown shaper"
+		}
+		conflicts = append(conflicts, Conflict{Qdisc: qdisc, Owner: owner})
+	}
+	return conflicts
+}
+
+// Report describes the conflicts
+func Report(iface string, conflicts []Conflict) string {
+	descriptions := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		descriptions = append(descriptions, conflict.String())
+	}
+	return fmt.Sprintf("the qdiscs of %s would be replaced: %s", iface, strings.Join(descriptions, "; "))
+}
+
+// Chainable returns the tbf root qdisc if it is the only conflict, the netem is chained under it
+func Chainable(conflicts []Conflict) (Qdisc, bool) {
+	if len(conflicts) != 1 || conflicts[0].Qdisc.Kind != "tbf" || conflicts[0].Qdisc.Parent != "" {
+		return Qdisc{}, false
+	}
+	return conflicts[0].Qdisc, true
+}
+
+// Chained returns the netem chained by the ChainScript
+func Chained(qdiscs []Qdisc) (Qdisc, bool) {
+	for _, qdisc := range qdiscs {
+		if qdisc.Handle == chainHandle && qdisc.Kind == "netem" {
+			return qdisc, true
+		}
+	}
+	return Qdisc{}, false
+}
+
+// NetemArgs returns the netem arguments of the tc action of chaos_os
+func NetemArgs(action string, flags map[string]string) (string, error) {
+	required := func(name string) (string, error) {
+		if flags[name] == "" {
+			return "", fmt.Errorf("less %s flag", name)
+		}
+		return nsexec.Quote(flags[name]), nil
+	}
+	switch action {
+	case "delay":
+		time, err := required("time")
+		if err != nil {
+			return "", err
+		}
+		args := fmt.Sprintf("delay %sms", time)
+		if offset := flags["offset"]; offset != "" {
+			args += fmt.Sprintf(" %sms", nsexec.Quote(offset))
+		}
+		return args, nil
+	case "loss", "duplicate", "corrupt":
+		percent, err := required("percent")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s%%", action, percent), nil
+	case "reorder":
+		percent, err := required("percent")
+		if err != nil {
+			return "", err
+		}
+		time := "10"
+		if flags["time"] != "" {
+			time = nsexec.Quote(flags["time"])
+		}
+		args := fmt.Sprintf("delay %sms reorder %s%%", time, percent)
+		if correlation := flags["correlation"]; correlation != "" {
+			args += fmt.Sprintf(" %s%%", nsexec.Quote(correlation))
+		}
+		if gap := flags["gap"]; gap != "" {
+			args += fmt.Sprintf(" gap %s", nsexec.Quote(gap))
+		}
+		return args, nil
+	}
+	return "", fmt.Errorf("the %s action can't be chained", action)
+}
+
+// ChainScript adds the netem as the child of the tbf
+func ChainScript(iface string, tbf Qdisc, netemArgs string) string {
+	return fmt.Sprintf("tc qdisc add dev %s parent %s1 handle %s netem %s", nsexec.Quote(iface), tbf.Handle,
+		chainHandle, netemArgs)
+}
+
+// UnchainScript deletes the chained netem, the default child of the tbf is restored by the kernel
+func UnchainScript(iface string, netem Qdisc) string {
+	return fmt.Sprintf("tc qdisc del dev %s parent %s handle %s 2>/dev/null; true", nsexec.Quote(iface), netem.Parent,
+		chainHandle)
+}
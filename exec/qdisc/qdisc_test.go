@@ -0,0 +1,82 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package qdisc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		output    string
+		conflicts []Conflict
+		chainable bool
+	}{
+		{"qdisc noqueue 0: root refcnt 2\nqdisc clsact ffff: parent ffff:fff1\n", []Conflict{}, false},
+		{"qdisc mq 0: root\nqdisc pfifo_fast 0: parent :1 bands 3\n", []Conflict{}, false},
+		{"qdisc tbf 1: root refcnt 2 rate 10Mbit burst 5Kb lat 50.0ms\nqdisc ingress ffff: parent ffff:fff1 ----------------\n",
+			[]Conflict{{Qdisc{Kind: "tbf", Handle: "1:"}, "the bandwidth plugin of the CNI"}}, true},
+		{"qdisc mq 8002: root\nqdisc fq 8003: parent 8002:1 limit 10000p\n", []Conflict{
+			{Qdisc{Kind: "mq", Handle: "8002:"}, "an unknown shaper"},
+			{Qdisc{Kind: "fq", Handle: "8003:", Parent: "8002:1"}, "the Cilium bandwidth manager"}}, false},
+		{"qdisc htb 1: root refcnt 2 r2q 10 default 0x30\n",
+			[]Conflict{{Qdisc{Kind: "htb", Handle: "1:"}, "a traffic shaper such as kube-router"}}, false},
+		{"qdisc tbf 1: root refcnt 2 rate 10Mbit\nqdisc netem 1bac: parent 1:1 limit 1000 delay 100ms\n", []Conflict{
+			{Qdisc{Kind: "tbf", Handle: "1:"}, "the bandwidth plugin of the CNI"},
+			{Qdisc{Kind: "netem", Handle: "1bac:", Parent: "1:1"}, "another chaos experiment chained under the tbf"}}, false},
+	}
+	for _, tt := range tests {
+		conflicts := Inspect(Parse(tt.output))
+		if !reflect.DeepEqual(conflicts, tt.conflicts) {
+			t.Errorf("inspect %q, expected %+v, got %+v", tt.output, tt.conflicts, conflicts)
+		}
+		if _, ok := Chainable(conflicts); ok != tt.chainable {
+			t.Errorf("inspect %q, expected chainable %v", tt.output, tt.chainable)
+		}
+	}
+}
+
+func TestNetemArgs(t *testing.T) {
+	tests := []struct {
+		action string
+		flags  map[string]string
+		args   string
+	}{
+		{"delay", map[string]string{"time": "100", "offset": "10"}, "delay 100ms 10ms"},
+		{"loss", map[string]string{"percent": "50"}, "loss 50%"},
+		{"corrupt", map[string]string{"percent": "5"}, "corrupt 5%"},
+		{"reorder", map[string]string{"percent": "20", "correlation": "50", "gap": "5"},
+			"delay 10ms reorder 20% 50% gap 5"},
+		{"delay", map[string]string{}, ""},
+		{"drop", map[string]string{}, ""},
+	}
+	for _, tt := range tests {
+		args, err := NetemArgs(tt.action, tt.flags)
+		if args != tt.args || (err != nil) != (tt.args == "") {
+			t.Errorf("%s %v, expected %q, got %q, err %v", tt.action, tt.flags, tt.args, args, err)
+		}
+	}
+	netem := Qdisc{Kind: "netem", Handle: chainHandle, Parent: "1:1"}
+	if script := ChainScript("eth0", Qdisc{Kind: "tbf", Handle: "1:"}, "loss 50%"); script !=
+		"tc qdisc add dev eth0 parent 1:1 handle 1bac: netem loss 50%" {
+		t.Errorf("unexpected chain script %s", script)
+	}
+	if script := UnchainScript("eth0", netem); script != "tc qdisc del dev eth0 parent 1:1 handle 1bac: 2>/dev/null; true" {
+		t.Errorf("unexpected unchain script %s", script)
+	}
+}
@@ -0,0 +1,318 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package report exports the experiment results as json or junit xml, so the chaos runs in the ci pipelines
+// can fail the builds and be rendered natively
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/node"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/probe"
+)
+
+// The report formats
+const (
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
+// defaultSuite is the junit suite of the experiments not created by a schedule
+const defaultSuite = "experiments"
+
+// Experiment is the outcome of one experiment on its target
+type Experiment struct {
+	Uid    string `json:"uid"`
+	Target string `json:"target"`
+	Action string `json:"action"`
+	// Container is the container flag the experiment is targeted by
+	Container  string        `json:"container,omitempty"`
+	ScheduleId string        `json:"scheduleId,omitempty"`
+	Run        int           `json:"run,omitempty"`
+	Status     string        `json:"status"`
+	Failed     bool          `json:"failed"`
+	Error      string        `json:"error,omitempty"`
+	CreateTime time.Time     `json:"createTime"`
+	Duration   string        `json:"duration"`
+	Probes     *probe.Report `json:"probes,omitempty"`
+	NodeInfo   *node.Info    `json:"nodeInfo,omitempty"`
+
+	duration time.Duration
+}
+
+// Report is the outcomes of the experiments
+type Report struct {
+	GeneratedAt time.Time    `json:"generatedAt"`
+	Total       int          `json:"total"`
+	Failed      int          `json:"failed"`
+	Experiments []Experiment `json:"experiments"`
+}
+
+// ValidateFormat returns the error if the format is not supported
+func ValidateFormat(format string) error {
+	if format != FormatJSON && format != FormatJUnit {
+		return fmt.Errorf("unsupported report format %s, only %s and %s are supported", format, FormatJSON, FormatJUnit)
+	}
+	return nil
+}
+
+// FormatOf returns the format by the file extension, .xml is junit and the others are json
+func FormatOf(file string) string {
+	if strings.EqualFold(path.Ext(file), ".xml") {
+		return FormatJUnit
+	}
+	return FormatJSON
+}
+
+// New creates the report of the journal records ordered by the create time
+func New(records []journal.Record) *Report {
+	report := &Report{GeneratedAt: time.Now(), Experiments: make([]Experiment, 0, len(records))}
+	for _, record := range records {
+		experiment := Experiment{
+			Uid:        record.Uid,
+			Target:     record.Target,
+			Action:     record.Action,
+			Container:  containerOf(record.Flags),
+			ScheduleId: record.ScheduleId,
+			Run:        record.Run,
+			Status:     record.Status,
+			CreateTime: record.CreateTime,
+			Probes:     record.ProbeReport,
+			NodeInfo:   record.NodeInfo,
+			duration:   record.UpdateTime.Sub(record.CreateTime),
+		}
+		experiment.Duration = experiment.duration.String()
+		experiment.Error, experiment.Failed = failure(record)
+		if experiment.Failed {
+			report.Failed++
+		}
+		report.Experiments = append(report.Experiments, experiment)
+	}
+	sort.SliceStable(report.Experiments, func(i, j int) bool {
+		return report.Experiments[i].CreateTime.Before(report.Experiments[j].CreateTime)
+	})
+	report.Total = len(report.Experiments)
+	return report
+}
+
+func containerOf(flags map[string]string) string {
+	for _, name := range []string{"container-id", "container-name", "container-label-selector"} {
+		if value := flags[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// failure returns whether the experiment failed and why, it fails if the execution failed, the steady state was
+// not healthy before the injection or not recovered after the revert
+func failure(record journal.Record) (string, bool) {
+	if record.Status == journal.StatusError || record.Status == journal.StatusDestroyFailed {
+		return record.Error, true
+	}
+	if record.ProbeReport == nil {
+		return "", false
+	}
+	for _, result := range record.ProbeReport.Before {
+		if !result.Healthy {
+			return fmt.Sprintf("probe %s is not healthy before the injection, %s", result.Name, result.Error), true
+		}
+	}
+	if len(record.ProbeReport.After) > 0 && !record.ProbeReport.Recovered {
+		return "the steady state is not recovered after the revert", true
+	}
+	return "", false
+}
+
+// Write writes the report in the format
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case FormatJUnit:
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		encoder := xml.NewEncoder(w)
+		encoder.Indent("", "  ")
+		if err := encoder.Encode(r.junit()); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	default:
+		return ValidateFormat(format)
+	}
+}
+
+// WriteFile writes the report to the file, the file is replaced atomically
+func (r *Report) WriteFile(file, format string) error {
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := r.Write(f, format); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junit groups the experiments by the schedule, the experiments not created by a schedule are in one suite
+func (r *Report) junit() junitTestSuites {
+	suites := junitTestSuites{Name: "chaosblade-cri", Tests: r.Total, Failures: r.Failed}
+	index := make(map[string]int)
+	var total time.Duration
+	durations := make([]time.Duration, 0)
+	for _, experiment := range r.Experiments {
+		name := defaultSuite
+		if experiment.ScheduleId != "" {
+			name = "schedule-" + experiment.ScheduleId
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(suites.Suites)
+			index[name] = i
+			suites.Suites = append(suites.Suites, junitTestSuite{
+				Name:      name,
+				Timestamp: experiment.CreateTime.Format("2006-01-02T15:04:05"),
+			})
+			durations = append(durations, 0)
+		}
+		suite := &suites.Suites[i]
+		suite.Tests++
+		testCase := junitTestCase{
+			Name:      caseName(experiment),
+			Classname: "chaosblade.cri." + experiment.Target,
+			Time:      seconds(experiment.duration),
+			SystemOut: nodeOutput(experiment.NodeInfo) + probeOutput(experiment.Probes),
+		}
+		if experiment.Failed {
+			suite.Failures++
+			testCase.Failure = &junitFailure{Message: experiment.Error, Type: experiment.Status, Text: experiment.Error}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+		durations[i] += experiment.duration
+		total += experiment.duration
+	}
+	for i := range suites.Suites {
+		suites.Suites[i].Time = seconds(durations[i])
+	}
+	suites.Time = seconds(total)
+	return suites
+}
+
+func caseName(experiment Experiment) string {
+	name := fmt.Sprintf("%s %s", experiment.Target, experiment.Action)
+	if experiment.Container != "" {
+		name = fmt.Sprintf("%s on %s", name, experiment.Container)
+	}
+	if experiment.Run > 0 {
+		name = fmt.Sprintf("%s run %d", name, experiment.Run)
+	}
+	return fmt.Sprintf("%s (%s)", name, experiment.Uid)
+}
+
+func nodeOutput(info *node.Info) string {
+	if info == nil {
+		return ""
+	}
+	cni := strings.Join(info.CNI, ",")
+	if info.CNIProvider != nil {
+		cni = fmt.Sprintf("%s (%s)", cni, info.CNIProvider)
+	}
+	return fmt.Sprintf("node %s: kernel=%s cgroup=v%d runtime=%s %s cni=%s\n", info.Hostname, info.KernelVersion,
+		info.CgroupVersion, info.Runtime, info.RuntimeVersion, cni)
+}
+
+func probeOutput(report *probe.Report) string {
+	if report == nil {
+		return ""
+	}
+	var b strings.Builder
+	write := func(phase string, results []probe.Result) {
+		for _, result := range results {
+			fmt.Fprintf(&b, "%s probe %s (%s): healthy=%t", phase, result.Name, result.Type, result.Healthy)
+			if result.Value != "" {
+				fmt.Fprintf(&b, " value=%s", result.Value)
+			}
+			if result.Error != "" {
+				fmt.Fprintf(&b, " error=%s", result.Error)
+			}
+			b.WriteString("\n")
+		}
+	}
+	write("before", report.Before)
+	write("after", report.After)
+	if len(report.After) > 0 {
+		fmt.Fprintf(&b, "recovered=%t recoveryTime=%s\n", report.Recovered, report.RecoveryTime)
+	}
+	return b.String()
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
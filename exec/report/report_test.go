@@ -0,0 +1,121 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/journal"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/node"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/probe"
+)
+
+func testRecords() []journal.Record {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []journal.Record{
+		{
+			Uid: "u2", Target: "network", Action: "delay", Flags: map[string]string{"container-id": "c1"},
+			Status: journal.StatusDestroyed, CreateTime: start.Add(time.Minute), UpdateTime: start.Add(2 * time.Minute),
+			ProbeReport: &probe.Report{
+				Before: []probe.Result{{Name: "web", Type: probe.TypeHTTP, Healthy: true, Value: "200"}},
+				After:  []probe.Result{{Name: "web", Type: probe.TypeHTTP, Healthy: false, Error: "timeout"}},
+			},
+		},
+		{
+			Uid: "u1", Target: "container", Action: "remove", Flags: map[string]string{"container-name": "nginx"},
+			Status: journal.StatusDestroyed, CreateTime: start, UpdateTime: start.Add(time.Second),
+			NodeInfo: &node.Info{Hostname: "node1", KernelVersion: "5.15.0", CgroupVersion: 2, Runtime: "containerd",
+				RuntimeVersion: "1.7.11", CNI: []string{"calico", "portmap"}},
+		},
+		{
+			Uid: "u3", Target: "cpu", Action: "fullload", ScheduleId: "s1", Run: 1,
+			Status: journal.StatusError, Error: "exec failed", CreateTime: start.Add(3 * time.Minute),
+			UpdateTime: start.Add(3 * time.Minute),
+		},
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := New(testRecords()).Write(&b, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(b.Bytes(), &report); err != nil {
+		t.Fatalf("decode report failed, %v", err)
+	}
+	if report.Total != 3 || report.Failed != 2 {
+		t.Errorf("expected 3 experiments and 2 failed, got %d and %d", report.Total, report.Failed)
+	}
+	if report.Experiments[0].Uid != "u1" || report.Experiments[0].Container != "nginx" || report.Experiments[0].Failed {
+		t.Errorf("unexpected first experiment %+v", report.Experiments[0])
+	}
+	if info := report.Experiments[0].NodeInfo; info == nil || info.Hostname != "node1" || info.RuntimeVersion != "1.7.11" {
+		t.Errorf("expected the node info of the first experiment, got %+v", info)
+	}
+	if report.Experiments[1].Probes == nil || !report.Experiments[1].Failed {
+		t.Errorf("expected the not recovered experiment failed with probes, got %+v", report.Experiments[1])
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	file := path.Join(t.TempDir(), "report.xml")
+	if err := New(testRecords()).WriteFile(file, FormatOf(file)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatalf("decode junit failed, %v\n%s", err, data)
+	}
+	if suites.Tests != 3 || suites.Failures != 2 || len(suites.Suites) != 2 {
+		t.Fatalf("unexpected suites %+v", suites)
+	}
+	experiments := suites.Suites[0]
+	if experiments.Name != defaultSuite || experiments.Tests != 2 || experiments.Failures != 1 || experiments.Time != "61.000" {
+		t.Errorf("unexpected suite %+v", experiments)
+	}
+	if remove := experiments.Cases[0]; remove.SystemOut != "node node1: kernel=5.15.0 cgroup=v2 runtime=containerd 1.7.11 cni=calico,portmap\n" {
+		t.Errorf("unexpected node output %q", remove.SystemOut)
+	}
+	delay := experiments.Cases[1]
+	if delay.Name != "network delay on c1 (u2)" || delay.Failure == nil || delay.SystemOut == "" {
+		t.Errorf("unexpected case %+v", delay)
+	}
+	schedule := suites.Suites[1]
+	if schedule.Name != "schedule-s1" || schedule.Cases[0].Failure.Message != "exec failed" {
+		t.Errorf("unexpected suite %+v", schedule)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if FormatOf("/tmp/report.XML") != FormatJUnit || FormatOf("report.json") != FormatJSON {
+		t.Error("unexpected format of the file")
+	}
+	if err := New(nil).Write(&bytes.Buffer{}, "yaml"); err == nil {
+		t.Error("expected error for the unsupported format")
+	}
+}
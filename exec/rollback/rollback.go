@@ -0,0 +1,96 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rollback unwinds the multi-step injections, every step done registers its compensating action and the
+// actions are run in the reverse order if a later step failed, so a failed injection leaves no partial state:
+//
+//	steps := rollback.New(ctx, uid)
+//	if err := cgroup.create(); err != nil {
+//		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "create cgroup", err))
+//	}
+//	steps.Done("cgroup", cgroup.remove)
+package rollback
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// Step is the compensating action of a step done
+type Step struct {
+	Name string
+	Undo func() error
+}
+
+// Steps is the steps done of the injection
+type Steps struct {
+	ctx   context.Context
+	uid   string
+	steps []Step
+}
+
+// New returns the steps of the injection of the experiment
+func New(ctx context.Context, uid string) *Steps {
+	return &Steps{ctx: ctx, uid: uid}
+}
+
+// Done registers the compensating action of the step done, the step whose mutation is partial on failure, such as
+// a script of several commands, registers it before running
+func (s *Steps) Done(name string, undo func() error) {
+	s.steps = append(s.steps, Step{Name: name, Undo: undo})
+}
+
+// Run runs the step and registers its compensating action if it succeeded
+func (s *Steps) Run(name string, do, undo func() error) error {
+	if err := do(); err != nil {
+		return err
+	}
+	s.Done(name, undo)
+	return nil
+}
+
+// Unwind runs the compensating actions in the reverse order, all of them are run even if some failed, the steps
+// are cleared so the unwinding is run once
+func (s *Steps) Unwind() error {
+	failed := make([]string, 0)
+	for i := len(s.steps) - 1; i >= 0; i-- {
+		step := s.steps[i]
+		if err := step.Undo(); err != nil {
+			log.Warnf(s.ctx, "roll back the step %s of experiment %s failed, %v", step.Name, s.uid, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", step.Name, err))
+			continue
+		}
+		log.Infof(s.ctx, "the step %s of experiment %s is rolled back", step.Name, s.uid)
+	}
+	s.steps = nil
+	if len(failed) > 0 {
+		return fmt.Errorf("roll back failed, %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Fail unwinds the steps done and returns the response of the failed step, the failure of the unwinding is appended
+// to its error since the partial state is left
+func (s *Steps) Fail(response *spec.Response) *spec.Response {
+	if err := s.Unwind(); err != nil {
+		response.Err = fmt.Sprintf("%s, %v", response.Err, err)
+	}
+	return response
+}
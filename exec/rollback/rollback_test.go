@@ -0,0 +1,54 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rollback
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestFail(t *testing.T) {
+	undone := make([]string, 0)
+	undo := func(name string, err error) func() error {
+		return func() error {
+			undone = append(undone, name)
+			return err
+		}
+	}
+	steps := New(context.Background(), "uid1")
+	steps.Done("copy", undo("copy", nil))
+	if err := steps.Run("cgroup", func() error { return nil }, undo("cgroup", errors.New("busy"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := steps.Run("tc", func() error { return errors.New("no qdisc") }, undo("tc", nil)); err == nil {
+		t.Fatal("expected the step failed")
+	}
+	response := steps.Fail(spec.ReturnFail(spec.OsCmdExecFailed, "tc failed"))
+	if strings.Join(undone, ",") != "cgroup,copy" {
+		t.Errorf("expected the steps done undone in the reverse order, got %v", undone)
+	}
+	if response.Code != spec.OsCmdExecFailed.Code || response.Err != "tc failed, roll back failed, cgroup: busy" {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if err := steps.Unwind(); err != nil || len(undone) != 2 {
+		t.Errorf("expected the steps unwound once, got %v %v", undone, err)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rootfs opens the paths of a container on the host inside its root filesystem, or the source of its volume,
+// the symbolic links are resolved as if the root were the / of the container, so a link such as /etc/hosts ->
+// /etc/shadow, or a linked directory, never leads to the files of the host. openat2 with RESOLVE_IN_ROOT resolves the
+// paths on linux 5.6 and later, the components are resolved one by one without following the links otherwise.
+package rootfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxLinks is the limit of the symbolic links resolved in a path, the MAXSYMLINKS of linux
+const maxLinks = 40
+
+// openat2Retries is the times openat2 is retried if the root is changed by a rename meanwhile
+const openat2Retries = 8
+
+// Open opens the name in the root with the flags of os.OpenFile, the name is the path in the root, the .. is refused
+func Open(root, name string, flag int, perm os.FileMode) (*os.File, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+	rootFd, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: root, Err: err}
+	}
+	defer unix.Close(rootFd)
+	fd, err := openat2(rootFd, name, flag, perm)
+	if errors.Is(err, unix.ENOSYS) {
+		fd, err = walk(rootFd, name, flag, perm)
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path.Join(root, name), Err: err}
+	}
+	return os.NewFile(uintptr(fd), path.Join(root, name)), nil
+}
+
+// OpenParent opens the directory of the name in the root, and returns it with the last component of the name, which
+// is not resolved, for the changes of the name itself by the *at syscalls, such as renameat
+func OpenParent(root, name string) (*os.File, string, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if name == "." {
+		return nil, "", fmt.Errorf("the root %s has no parent", root)
+	}
+	dir, err := Open(root, path.Dir(name), unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	return dir, path.Base(name), nil
+}
+
+// Resolve returns the path on the host of the name resolved in the root, the name must exist. The path is refused if
+// it is outside the root, e.g. by a mount on the host meanwhile
+func Resolve(root, name string) (string, error) {
+	f, err := Open(root, name, unix.O_PATH, 0)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return fdPath(root, f)
+}
+
+// Rel returns the name in the root of the path on the host returned by Resolve, the name has no link, so it is opened
+// again with O_NOFOLLOW
+func Rel(root, resolved string) (string, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	if !Within(realRoot, resolved) {
+		return "", fmt.Errorf("%s is outside %s", resolved, root)
+	}
+	return "/" + strings.TrimPrefix(strings.TrimPrefix(resolved, realRoot), "/"), nil
+}
+
+// Within returns whether the file is the root or under it, both are cleaned
+func Within(root, file string) bool {
+	root, file = path.Clean(root), path.Clean(file)
+	return file == root || strings.HasPrefix(file, strings.TrimSuffix(root, "/")+"/")
+}
+
+// fdPath returns the path on the host of the file opened in the root, it must be inside the root
+func fdPath(root string, f *os.File) (string, error) {
+	resolved, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", f.Fd()))
+	if err != nil {
+		return "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	if !Within(realRoot, resolved) {
+		return "", fmt.Errorf("%s resolves to %s outside %s", f.Name(), resolved, root)
+	}
+	return resolved, nil
+}
+
+// clean refuses the .. of the name, and returns it relative to the root, or . for the root itself
+func clean(name string) (string, error) {
+	for _, component := range strings.Split(name, "/") {
+		if component == ".." {
+			return "", fmt.Errorf("the path %s must not contain ..", name)
+		}
+	}
+	if name = strings.TrimPrefix(path.Clean("/"+name), "/"); name == "" {
+		return ".", nil
+	}
+	return name, nil
+}
+
+func openat2(rootFd int, name string, flag int, perm os.FileMode) (int, error) {
+	how := &unix.OpenHow{
+		Flags:   uint64(flag | unix.O_CLOEXEC),
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	}
+	if flag&unix.O_CREAT != 0 {
+		how.Mode = uint64(perm.Perm())
+	}
+	for i := 0; ; i++ {
+		fd, err := unix.Openat2(rootFd, name, how)
+		if !errors.Is(err, unix.EAGAIN) || i == openat2Retries {
+			return fd, err
+		}
+	}
+}
+
+// walk resolves the name component by component, every component is opened without following the links, and the
+// links are resolved relative to the root, the .. of a link never leaves the root
+func walk(rootFd int, name string, flag int, perm os.FileMode) (int, error) {
+	// dirs are the directories opened from the root, the .. pops them but never the root
+	dirs := make([]int, 0)
+	defer func() {
+		for _, fd := range dirs {
+			unix.Close(fd)
+		}
+	}()
+	current := func() int {
+		if len(dirs) == 0 {
+			return rootFd
+		}
+		return dirs[len(dirs)-1]
+	}
+	components := strings.Split(name, "/")
+	links := 0
+	for len(components) > 0 {
+		component := components[0]
+		components = components[1:]
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			if len(dirs) > 0 {
+				unix.Close(dirs[len(dirs)-1])
+				dirs = dirs[:len(dirs)-1]
+			}
+			continue
+		}
+		fd, err := unix.Openat(current(), component, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err != nil {
+			if errors.Is(err, unix.ENOENT) && len(components) == 0 && flag&unix.O_CREAT != 0 {
+				return unix.Openat(current(), component, flag|unix.O_NOFOLLOW|unix.O_CLOEXEC, uint32(perm.Perm()))
+			}
+			return -1, err
+		}
+		var stat unix.Stat_t
+		if err := unix.Fstat(fd, &stat); err != nil {
+			unix.Close(fd)
+			return -1, err
+		}
+		switch {
+		case stat.Mode&unix.S_IFMT == unix.S_IFLNK && (len(components) > 0 || flag&unix.O_NOFOLLOW == 0):
+			target, err := readlink(fd)
+			unix.Close(fd)
+			if err != nil {
+				return -1, err
+			}
+			if links++; links > maxLinks {
+				return -1, unix.ELOOP
+			}
+			if strings.HasPrefix(target, "/") {
+				for _, dir := range dirs {
+					unix.Close(dir)
+				}
+				dirs = dirs[:0]
+			}
+			components = append(strings.Split(target, "/"), components...)
+		case len(components) > 0:
+			if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+				unix.Close(fd)
+				return -1, unix.ENOTDIR
+			}
+			dirs = append(dirs, fd)
+		default:
+			unix.Close(fd)
+			// the last component is checked above, it fails with ELOOP if it is replaced by a link meanwhile
+			return unix.Openat(current(), component, flag|unix.O_NOFOLLOW|unix.O_CLOEXEC, uint32(perm.Perm()))
+		}
+	}
+	// the name resolves to a directory opened, or the root
+	return unix.Openat(current(), ".", flag|unix.O_CLOEXEC, uint32(perm.Perm()))
+}
+
+// readlink reads the link opened by O_PATH|O_NOFOLLOW, the empty path reads the fd itself
+func readlink(fd int) (string, error) {
+	buf := make([]byte, unix.PathMax)
+	n, err := unix.Readlinkat(fd, "", buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rootfs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// createRootfs creates a rootfs with the links escaping it, and a file outside it
+func createRootfs(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	root, outside := path.Join(dir, "rootfs"), path.Join(dir, "shadow")
+	for _, d := range []string{"etc", "data/app"} {
+		if err := os.MkdirAll(path.Join(root, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{outside: "host", path.Join(root, "etc/shadow"): "container",
+		path.Join(root, "etc/hostname"): "c1"}
+	for file, content := range files {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{"etc/hosts": "/etc/shadow", "etc/relative": "../../../shadow", "etc/host-dir": dir,
+		"var": "/data", "loop": "loop", "etc/name": "hostname"}
+	for link, target := range links {
+		if err := os.Symlink(target, path.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, outside
+}
+
+func TestOpen(t *testing.T) {
+	root, _ := createRootfs(t)
+	resolvers := map[string]func(rootFd int, name string, flag int, perm os.FileMode) (int, error){
+		"openat2": openat2, "walk": walk}
+	for resolver, open := range resolvers {
+		rootFd, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, expected := range map[string]string{"etc/hosts": "container", "etc/relative": "",
+			"etc/host-dir/shadow": "", "etc/name": "c1", "var/app/../../etc/shadow": "container"} {
+			fd, err := open(rootFd, name, unix.O_RDONLY, 0)
+			if expected == "" {
+				if err == nil {
+					unix.Close(fd)
+					t.Errorf("%s: expected %s escaping the root refused", resolver, name)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: open %s failed, %v", resolver, name, err)
+				continue
+			}
+			content, _ := os.ReadFile(fmt.Sprintf("/proc/self/fd/%d", fd))
+			unix.Close(fd)
+			if string(content) != expected {
+				t.Errorf("%s: expected %s resolved in the root, got %q", resolver, name, content)
+			}
+		}
+		if fd, err := open(rootFd, "loop", unix.O_RDONLY, 0); err == nil {
+			unix.Close(fd)
+			t.Errorf("%s: expected the loop refused", resolver)
+		}
+		if fd, err := open(rootFd, "etc/name", unix.O_RDONLY|unix.O_NOFOLLOW, 0); err == nil {
+			unix.Close(fd)
+			t.Errorf("%s: expected the last link not followed", resolver)
+		}
+		fd, err := open(rootFd, "var/app/created", unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL, 0600)
+		if err != nil {
+			t.Errorf("%s: create failed, %v", resolver, err)
+		} else {
+			unix.Close(fd)
+			os.Remove(path.Join(root, "data/app/created"))
+		}
+		unix.Close(rootFd)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	root, _ := createRootfs(t)
+	if resolved, err := Resolve(root, "/etc/hosts"); err != nil || resolved != path.Join(root, "etc/shadow") {
+		t.Errorf("expected /etc/hosts resolved in the root, got %s, %v", resolved, err)
+	}
+	if resolved, err := Resolve(root, "/"); err != nil || resolved != root {
+		t.Errorf("expected the root resolved, got %s, %v", resolved, err)
+	}
+	for _, name := range []string{"/etc/relative", "/../shadow", "etc/../etc/hosts", "/etc/missing"} {
+		if resolved, err := Resolve(root, name); err == nil {
+			t.Errorf("expected %s refused, got %s", name, resolved)
+		}
+	}
+	if name, err := Rel(root, path.Join(root, "etc/shadow")); err != nil || name != "/etc/shadow" {
+		t.Errorf("expected the name in the root, got %s, %v", name, err)
+	}
+	if name, err := Rel(root, path.Dir(root)); err == nil {
+		t.Errorf("expected the path outside the root refused, got %s", name)
+	}
+	dir, base, err := OpenParent(root, "/var/app/socket")
+	if err != nil || base != "socket" {
+		t.Fatalf("expected the parent opened, got %s, %v", base, err)
+	}
+	defer dir.Close()
+	if resolved, err := fdPath(root, dir); err != nil || resolved != path.Join(root, "data/app") {
+		t.Errorf("expected the parent resolved in the root, got %s, %v", resolved, err)
+	}
+	if _, _, err := OpenParent(root, "/"); err == nil {
+		t.Errorf("expected the parent of the root refused")
+	}
+}
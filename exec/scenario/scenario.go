@@ -0,0 +1,182 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package scenario composes several faults, such as a network delay and a cpu load of the same container, or the
+// faults of two containers, into one experiment of a single lifecycle. The faults are injected in order as the
+// experiments of their own uids, if any of them failed the faults injected are destroyed in the reverse order, and
+// the destroy of the scenario destroys all of them in the reverse order.
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rollback"
+)
+
+// The target and the action recording the scenario in the journal
+const (
+	Target = "scenario"
+	Action = "run"
+)
+
+// Fault is a fault of the scenario, the flags carry the target container as the experiment of the fault alone
+type Fault struct {
+	Target string            `json:"target"`
+	Action string            `json:"action"`
+	Flags  map[string]string `json:"flags,omitempty"`
+}
+
+// FaultResult is the result of a fault
+type FaultResult struct {
+	Target  string      `json:"target"`
+	Action  string      `json:"action"`
+	Uid     string      `json:"uid"`
+	Success bool        `json:"success"`
+	Code    int32       `json:"code,omitempty"`
+	Err     string      `json:"error,omitempty"`
+	Result  interface{} `json:"result,omitempty"`
+	// RolledBack is set if the fault injected is destroyed since a later fault failed
+	RolledBack bool `json:"rolledBack,omitempty"`
+}
+
+// Result is the result of the scenario, the faults are in the order of the scenario
+type Result struct {
+	Faults []FaultResult `json:"faults"`
+}
+
+// Uid returns the uid of the experiment of the fault at the index, the destroy derives the same uids
+func Uid(uid string, index int) string {
+	return uid + "-" + strconv.Itoa(index)
+}
+
+// Executor executes the faults of the scenario by the executors of their targets and actions
+type Executor struct {
+	Faults    []Fault
+	Executors map[string]spec.Executor
+}
+
+// Validate checks the scenario has two faults at least and their executors exist
+func Validate(faults []Fault, executors map[string]spec.Executor) error {
+	if len(faults) < 2 {
+		return errors.New("a scenario has two faults at least")
+	}
+	for i, fault := range faults {
+		if fault.Target == Target {
+			return fmt.Errorf("the fault %d is a scenario, the scenarios can't be nested", i)
+		}
+		if _, ok := executors[key(fault)]; !ok {
+			return fmt.Errorf("the fault %d, %s %s, is not supported", i, fault.Target, fault.Action)
+		}
+	}
+	return nil
+}
+
+func key(fault Fault) string {
+	return fault.Target + "-" + fault.Action
+}
+
+func (e *Executor) Name() string {
+	return "scenario"
+}
+
+func (e *Executor) SetChannel(channel spec.Channel) {
+}
+
+// Exec injects or destroys the faults, the model of the scenario is ignored, every fault is executed by its own model
+func (e *Executor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if err := Validate(e.Faults, e.Executors); err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, "faults", e.Faults, err)
+	}
+	if suid, isDestroy := spec.IsDestroy(ctx); isDestroy {
+		if suid != "" {
+			uid = suid
+		}
+		return e.destroy(ctx, uid)
+	}
+	result := Result{Faults: make([]FaultResult, 0, len(e.Faults))}
+	steps := rollback.New(ctx, uid)
+	for i := range e.Faults {
+		fault, faultUid := e.Faults[i], Uid(uid, i)
+		response := e.Executors[key(fault)].Exec(faultUid, ctx, faultModel(fault))
+		result.add(fault, faultUid, response)
+		if !response.Success {
+			failed := steps.Fail(spec.ResponseFail(response.Code, fmt.Sprintf("the fault %d, %s %s, failed, %s", i,
+				fault.Target, fault.Action, response.Err), nil))
+			// the result is taken after the unwinding marked the faults rolled back
+			failed.Result = result
+			return failed
+		}
+		// the faults are never reallocated, the capacity is the count of the faults
+		injected := &result.Faults[len(result.Faults)-1]
+		steps.Done(fmt.Sprintf("%s %s", fault.Target, fault.Action), func() error {
+			response := e.Executors[key(fault)].Exec(faultUid, spec.SetDestroyFlag(ctx, faultUid), faultModel(fault))
+			if !response.Success {
+				return errors.New(response.Err)
+			}
+			injected.RolledBack = true
+			return nil
+		})
+	}
+	return spec.ReturnSuccess(result)
+}
+
+// destroy destroys the faults in the reverse order, the failures do not stop the others
+func (e *Executor) destroy(ctx context.Context, uid string) *spec.Response {
+	result := Result{Faults: make([]FaultResult, len(e.Faults))}
+	failed := 0
+	var first *FaultResult
+	for i := len(e.Faults) - 1; i >= 0; i-- {
+		fault, faultUid := e.Faults[i], Uid(uid, i)
+		response := e.Executors[key(fault)].Exec(faultUid, spec.SetDestroyFlag(ctx, faultUid), faultModel(fault))
+		result.Faults[i] = newFaultResult(fault, faultUid, response)
+		if !response.Success {
+			failed++
+			first = &result.Faults[i]
+		}
+	}
+	if first == nil {
+		return spec.ReturnSuccess(result)
+	}
+	return spec.ResponseFail(first.Code, fmt.Sprintf("%d of %d faults failed to destroy, the first is %s %s, %s",
+		failed, len(e.Faults), first.Target, first.Action, first.Err), result)
+}
+
+func (r *Result) add(fault Fault, uid string, response *spec.Response) {
+	r.Faults = append(r.Faults, newFaultResult(fault, uid, response))
+}
+
+func newFaultResult(fault Fault, uid string, response *spec.Response) FaultResult {
+	result := FaultResult{Target: fault.Target, Action: fault.Action, Uid: uid, Success: response.Success,
+		Result: response.Result}
+	if !response.Success {
+		result.Code, result.Err = response.Code, response.Err
+	}
+	return result
+}
+
+// faultModel returns the model of the fault, the flags are copied since the executors may change them
+func faultModel(fault Fault) *spec.ExpModel {
+	flags := make(map[string]string, len(fault.Flags))
+	for k, v := range fault.Flags {
+		flags[k] = v
+	}
+	return &spec.ExpModel{Target: fault.Target, Scope: "cri", ActionName: fault.Action, ActionFlags: flags}
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scenario
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// recordExecutor fails the containers listed and records the calls as create|destroy:uid:action
+type recordExecutor struct {
+	spec.Executor
+	failed map[string]bool
+	calls  *[]string
+}
+
+func (e *recordExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	phase := "create"
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		phase, uid = "destroy", suid
+	}
+	*e.calls = append(*e.calls, phase+":"+uid+":"+model.ActionName)
+	if e.failed[phase+":"+model.ActionFlags["container-id"]] {
+		return spec.ReturnFail(spec.ContainerExecFailed, model.ActionFlags["container-id"]+" failed")
+	}
+	return spec.ReturnSuccess(model.ActionName)
+}
+
+func run(ctx context.Context, failed ...string) (*spec.Response, []string) {
+	calls := make([]string, 0)
+	record := &recordExecutor{failed: map[string]bool{}, calls: &calls}
+	for _, f := range failed {
+		record.failed[f] = true
+	}
+	executor := &Executor{
+		Faults: []Fault{
+			{Target: "network", Action: "delay", Flags: map[string]string{"container-id": "c1", "time": "100"}},
+			{Target: "cpu", Action: "fullload", Flags: map[string]string{"container-id": "c1", "cpu-percent": "30"}},
+			{Target: "network", Action: "loss", Flags: map[string]string{"container-id": "c2", "percent": "10"}},
+		},
+		Executors: map[string]spec.Executor{"network-delay": record, "cpu-fullload": record, "network-loss": record},
+	}
+	return executor.Exec("uid1", ctx, &spec.ExpModel{}), calls
+}
+
+func TestScenario(t *testing.T) {
+	response, calls := run(context.Background())
+	if !response.Success || strings.Join(calls, ",") != "create:uid1-0:delay,create:uid1-1:fullload,create:uid1-2:loss" {
+		t.Fatalf("expected the faults injected in order, got %+v %v", response, calls)
+	}
+	response, calls = run(spec.SetDestroyFlag(context.Background(), "uid1"))
+	if !response.Success || strings.Join(calls, ",") != "destroy:uid1-2:loss,destroy:uid1-1:fullload,destroy:uid1-0:delay" {
+		t.Fatalf("expected the faults destroyed in the reverse order, got %+v %v", response, calls)
+	}
+}
+
+func TestScenarioRollback(t *testing.T) {
+	response, calls := run(context.Background(), "create:c2")
+	expected := "create:uid1-0:delay,create:uid1-1:fullload,create:uid1-2:loss,destroy:uid1-1:fullload,destroy:uid1-0:delay"
+	if response.Success || strings.Join(calls, ",") != expected {
+		t.Fatalf("expected %s, got %+v %v", expected, response, calls)
+	}
+	result := response.Result.(Result)
+	if !result.Faults[0].RolledBack || !result.Faults[1].RolledBack || result.Faults[2].Success {
+		t.Errorf("expected the injected faults rolled back, got %+v", result)
+	}
+	if !strings.Contains(response.Err, "the fault 2, network loss, failed") {
+		t.Errorf("unexpected error %s", response.Err)
+	}
+
+	response, _ = run(spec.SetDestroyFlag(context.Background(), "uid1"), "destroy:c1")
+	if response.Success || !strings.Contains(response.Err, "2 of 3 faults failed to destroy, the first is network delay") {
+		t.Errorf("expected the destroy failed, got %+v", response)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	executors := map[string]spec.Executor{"network-delay": &recordExecutor{}}
+	for _, faults := range [][]Fault{
+		{{Target: "network", Action: "delay"}},
+		{{Target: "network", Action: "delay"}, {Target: "cpu", Action: "fullload"}},
+		{{Target: "network", Action: "delay"}, {Target: Target, Action: Action}},
+	} {
+		if err := Validate(faults, executors); err == nil {
+			t.Errorf("expected %+v illegal", faults)
+		}
+	}
+}
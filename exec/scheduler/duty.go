@@ -0,0 +1,85 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DutyCycle flaps a fault, it is kept injected for On and reverted for Off alternately until Duration elapses
+type DutyCycle struct {
+	On  time.Duration
+	Off time.Duration
+	// Duration is the total time of the cycles, 0 means until the context is done
+	Duration time.Duration
+}
+
+// Validate checks the duty cycle
+func (d DutyCycle) Validate() error {
+	if d.On <= 0 || d.Off <= 0 {
+		return errors.New("on and off must be positive durations")
+	}
+	if d.Duration < 0 {
+		return errors.New("duration must not be negative")
+	}
+	if d.Duration > 0 && d.Duration < d.On {
+		return errors.New("duration must not be shorter than on")
+	}
+	return nil
+}
+
+// Toggle flaps the fault injected before it's called, off is called after every on period and on after every off
+// period, until the duration elapses, the context is done or a call fails. It returns whether the fault is injected
+// at the end, the fault is kept injected if off fails, so the caller reverts it in any case
+func Toggle(ctx context.Context, d DutyCycle, on, off func(ctx context.Context) error) (bool, error) {
+	var end time.Time
+	if d.Duration > 0 {
+		end = time.Now().Add(d.Duration)
+	}
+	injected := true
+	for {
+		period := d.On
+		if !injected {
+			period = d.Off
+		}
+		next := time.Now().Add(period)
+		last := !end.IsZero() && !next.Before(end)
+		if last {
+			next = end
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return injected, ctx.Err()
+		case <-timer.C:
+		}
+		if last {
+			return injected, nil
+		}
+		if injected {
+			if err := off(ctx); err != nil {
+				return true, err
+			}
+		} else if err := on(ctx); err != nil {
+			return false, err
+		}
+		injected = !injected
+	}
+}
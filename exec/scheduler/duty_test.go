@@ -0,0 +1,67 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDutyCycleValidate(t *testing.T) {
+	for _, d := range []DutyCycle{
+		{On: 0, Off: time.Second},
+		{On: time.Second, Off: -time.Second},
+		{On: time.Second, Off: time.Second, Duration: -time.Second},
+		{On: time.Minute, Off: time.Second, Duration: time.Second},
+	} {
+		if err := d.Validate(); err == nil {
+			t.Errorf("expected %+v illegal", d)
+		}
+	}
+	if err := (DutyCycle{On: 5 * time.Second, Off: 25 * time.Second, Duration: 10 * time.Minute}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestToggle(t *testing.T) {
+	calls := make([]string, 0)
+	on := func(ctx context.Context) error { calls = append(calls, "on"); return nil }
+	off := func(ctx context.Context) error { calls = append(calls, "off"); return nil }
+	d := DutyCycle{On: 20 * time.Millisecond, Off: 20 * time.Millisecond, Duration: 70 * time.Millisecond}
+	injected, err := Toggle(context.Background(), d, on, off)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// off at 20ms, on at 40ms, off at 60ms, then the end at 70ms
+	if injected || len(calls) != 3 || calls[0] != "off" || calls[1] != "on" || calls[2] != "off" {
+		t.Errorf("unexpected calls %v, injected %t", calls, injected)
+	}
+
+	failed := errors.New("inject failed")
+	injected, err = Toggle(context.Background(), d, func(ctx context.Context) error { return failed }, off)
+	if err != failed || injected {
+		t.Errorf("expected the failed injection reported, got %v, injected %t", err, injected)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if injected, err := Toggle(ctx, DutyCycle{On: time.Minute, Off: time.Minute}, on, off); err == nil || !injected {
+		t.Errorf("expected the cancel reported with the fault injected, got %v, injected %t", err, injected)
+	}
+}
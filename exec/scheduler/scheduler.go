@@ -0,0 +1,226 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trigger returns the next fire time after the given time, zero if it never fires again
+type Trigger interface {
+	Next(after time.Time) time.Time
+}
+
+// Schedule decides when an experiment is re-run
+type Schedule struct {
+	// Cron is the standard 5 fields cron expression: minute hour day-of-month month day-of-week
+	Cron string
+	// Interval is the fixed interval between two runs, used if Cron is empty
+	Interval time.Duration
+	// Jitter is the max random delay added to every fire time
+	Jitter time.Duration
+	// Times is the max run times, 0 means unlimited
+	Times int
+	// End is the time after which no run is started, zero means no end
+	End time.Time
+}
+
+// Validate checks the schedule
+func (s Schedule) Validate() error {
+	if s.Cron == "" && s.Interval <= 0 {
+		return errors.New("cron or a positive interval is required")
+	}
+	if s.Jitter < 0 {
+		return errors.New("jitter must not be negative")
+	}
+	if s.Times < 0 {
+		return errors.New("times must not be negative")
+	}
+	if s.Times == 0 && s.End.IsZero() {
+		return errors.New("times or end time is required")
+	}
+	if _, err := s.trigger(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s Schedule) trigger() (Trigger, error) {
+	if s.Cron != "" {
+		return ParseCron(s.Cron)
+	}
+	return intervalTrigger(s.Interval), nil
+}
+
+// Run calls fn on every fire time until the times or the end time is reached or the context is done.
+// The next fire time is computed after fn returns, so the runs never overlap.
+func Run(ctx context.Context, s Schedule, fn func(ctx context.Context, run int)) error {
+	trigger, err := s.trigger()
+	if err != nil {
+		return err
+	}
+	for run := 1; s.Times == 0 || run <= s.Times; run++ {
+		next := trigger.Next(time.Now())
+		if next.IsZero() {
+			return nil
+		}
+		if s.Jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(s.Jitter))))
+		}
+		if !s.End.IsZero() && next.After(s.End) {
+			return nil
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		fn(ctx, run)
+	}
+	return nil
+}
+
+type intervalTrigger time.Duration
+
+func (i intervalTrigger) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+// CronExpr is a parsed cron expression
+type CronExpr struct {
+	minute, hour, dom, month, dow map[int]bool
+	domStar, dowStar              bool
+}
+
+// ParseCron parses the standard 5 fields cron expression, each field supports *, n, a-b, */s, a-b/s and lists
+func ParseCron(expr string) (*CronExpr, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("illegal cron expression `%s`, 5 fields are required", expr)
+	}
+	var (
+		c   CronExpr
+		err error
+	)
+	bounds := []struct {
+		field    *map[int]bool
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		if *b.field, err = parseCronField(fields[i], b.min, b.max); err != nil {
+			return nil, fmt.Errorf("illegal cron expression `%s`, %v", expr, err)
+		}
+	}
+	// 7 is sunday too
+	if c.dow[7] {
+		c.dow[0] = true
+	}
+	// the day field starting with *, such as */2, is unrestricted like the cron of vixie, so the other day field
+	// restricts the days alone
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+	return &c, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			rangePart = part[:idx]
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("illegal step in `%s`", part)
+			}
+		}
+		start, end := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("illegal value in `%s`", part)
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("illegal value in `%s`", part)
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("`%s` is out of range %d-%d", part, min, max)
+		}
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Next returns the first matched minute after the given time, it searches at most 5 years
+func (c *CronExpr) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows the cron convention, if both day fields are restricted, either of them matches, otherwise both
+// of them must match
+func (c *CronExpr) dayMatches(t time.Time) bool {
+	domMatch := c.dom[t.Day()]
+	dowMatch := c.dow[int(t.Weekday())]
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseCronIllegal(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected `%s` refused", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2024-01-01 is a monday
+	after := time.Date(2024, 1, 1, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 1, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * 3 *", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 7 is sunday too
+		{"0 0 * * 7", time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{"30 8 * * 1-5", time.Date(2024, 1, 2, 8, 30, 0, 0, time.UTC)},
+		// both day fields restricted, either of them matches: the 15th or the next friday
+		{"0 0 15 * 5", time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 3,15 * 5", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		// a day field starting with * is unrestricted, so both of them must match: the odd days and mondays
+		{"0 0 */2 * 1", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * */2", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1", time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+		// never fires
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		c, err := ParseCron(test.expr)
+		if err != nil {
+			t.Fatalf("parse `%s` failed, %v", test.expr, err)
+		}
+		if actual := c.Next(after); !actual.Equal(test.expected) {
+			t.Errorf("expected the next of `%s` %s, got %s", test.expr, test.expected, actual)
+		}
+	}
+}
+
+func TestCronNextLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	c, err := ParseCron("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := c.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if expected := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, next)
+	}
+	next = c.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, loc))
+	if expected := time.Date(2024, 1, 1, 2, 0, 0, 0, loc); !next.Equal(expected) {
+		t.Errorf("expected the fire time in the location of the time, %s, got %s", expected, next)
+	}
+}
+
+func TestRunTimes(t *testing.T) {
+	runs := make([]int, 0)
+	err := Run(context.Background(), Schedule{Interval: time.Millisecond, Times: 3}, func(ctx context.Context, run int) {
+		runs = append(runs, run)
+	})
+	if err != nil || len(runs) != 3 || runs[0] != 1 || runs[2] != 3 {
+		t.Errorf("expected 3 runs, got %v, %v", runs, err)
+	}
+}
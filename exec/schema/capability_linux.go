@@ -0,0 +1,66 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// capabilityBits are the bits of the capabilities declared by the schemas
+var capabilityBits = map[string]uint{
+	"NET_ADMIN":  12,
+	"NET_RAW":    13,
+	"SYS_PTRACE": 19,
+	"SYS_ADMIN":  21,
+}
+
+// effectiveCapabilities returns the effective capabilities of the agent, it's replaced by the tests
+var effectiveCapabilities = func() (uint64, error) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "CapEff:"); ok {
+			return strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// missingCapabilities returns the capabilities the agent lacks, none is reported if they can't be read
+func missingCapabilities(capabilities []string) []string {
+	if len(capabilities) == 0 {
+		return nil
+	}
+	effective, err := effectiveCapabilities()
+	if err != nil {
+		return nil
+	}
+	missing := make([]string, 0)
+	for _, capability := range capabilities {
+		if bit, ok := capabilityBits[capability]; ok && effective&(1<<bit) == 0 {
+			missing = append(missing, capability)
+		}
+	}
+	return missing
+}
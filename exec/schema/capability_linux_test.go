@@ -0,0 +1,40 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMissingCapabilities(t *testing.T) {
+	origin := effectiveCapabilities
+	defer func() { effectiveCapabilities = origin }()
+	// NET_ADMIN only
+	effectiveCapabilities = func() (uint64, error) { return 1 << 12, nil }
+	if missing := missingCapabilities([]string{"NET_ADMIN", "SYS_ADMIN"}); !reflect.DeepEqual(missing, []string{"SYS_ADMIN"}) {
+		t.Errorf("expected SYS_ADMIN missing, got %v", missing)
+	}
+	schema := Schema{Target: "network", Action: "loss", Capabilities: []string{"NET_ADMIN", "SYS_ADMIN"}}
+	if response := schema.Validate(map[string]string{}); response == nil ||
+		response.Err != "the network loss experiment requires the capabilities SYS_ADMIN" {
+		t.Errorf("expected the experiment forbidden, got %+v", response)
+	}
+	if _, err := origin(); err != nil {
+		t.Errorf("read the capabilities of the process failed, %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package schema declares the typed parameters of the experiments, their ranges, units and the privileges required,
+// so the flags are validated before any runtime call, with errors like `time must be from 1ms to 60s` instead of
+// failing deep inside tc.
+package schema
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+)
+
+// The kinds of the parameters
+const (
+	// KindInt is an integer of the unit, such as a percent
+	KindInt = "int"
+	// KindMillis is an integer of milliseconds, the range is described by durations
+	KindMillis = "millis"
+	// KindSeconds is a duration such as 500ms or 2m, or an integer of seconds, the range is in seconds
+	KindSeconds = "seconds"
+	// KindPorts is a comma separated list of ports or port ranges
+	KindPorts = "ports"
+	// KindEnum is one of the values
+	KindEnum = "enum"
+)
+
+// Param is a typed parameter of the experiment
+type Param struct {
+	Name     string
+	Kind     string
+	Required bool
+	// Min and Max are the range of the int, millis and seconds kinds
+	Min int
+	Max int
+	// Forever accepts forever of the seconds kind, the experiment persists until destroyed
+	Forever bool
+	// Unit is appended to the range of the int kind, such as %
+	Unit   string
+	Values []string
+}
+
+// Schema is the parameters of the action of the target, and the capabilities of the agent it requires
+type Schema struct {
+	Target       string
+	Action       string
+	Params       []Param
+	Capabilities []string
+}
+
+// Validate checks the flags of the experiment by the parameters, the empty flags are not checked unless required
+func (s Schema) Validate(flags map[string]string) *spec.Response {
+	for _, param := range s.Params {
+		value := strings.TrimSpace(flags[param.Name])
+		if value == "" {
+			if param.Required {
+				return spec.ResponseFailWithFlags(spec.ParameterLess, param.Name)
+			}
+			continue
+		}
+		if err := param.validate(value); err != nil {
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, param.Name, value, err)
+		}
+	}
+	if missing := missingCapabilities(s.Capabilities); len(missing) > 0 {
+		return spec.ReturnFail(spec.Forbidden, fmt.Sprintf("the %s %s experiment requires the capabilities %s",
+			s.Target, s.Action, strings.Join(missing, ",")))
+	}
+	return nil
+}
+
+func (p Param) validate(value string) error {
+	switch p.Kind {
+	case KindSeconds:
+		if p.Forever && durations.IsForever(value) {
+			return nil
+		}
+		d, err := durations.Parse(value)
+		if err != nil || durations.IsForever(value) ||
+			d < time.Duration(p.Min)*time.Second || d > time.Duration(p.Max)*time.Second {
+			return fmt.Errorf("%s must be from %s to %s%s", p.Name, p.format(p.Min), p.format(p.Max), p.forever())
+		}
+	case KindInt, KindMillis:
+		number, err := strconv.Atoi(value)
+		if err != nil || number < p.Min || number > p.Max {
+			return fmt.Errorf("%s must be from %s to %s", p.Name, p.format(p.Min), p.format(p.Max))
+		}
+	case KindPorts:
+		for _, port := range strings.Split(value, ",") {
+			if !validPorts(strings.TrimSpace(port)) {
+				return fmt.Errorf("%s must be the ports from 1 to 65535 or the port ranges separated by commas", p.Name)
+			}
+		}
+	case KindEnum:
+		for _, allowed := range p.Values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %s", p.Name, strings.Join(p.Values, ", "))
+	}
+	return nil
+}
+
+// format describes the value of the parameter with the unit
+func (p Param) format(value int) string {
+	switch p.Kind {
+	case KindMillis:
+		if value != 0 && value%1000 == 0 {
+			return fmt.Sprintf("%ds", value/1000)
+		}
+		return fmt.Sprintf("%dms", value)
+	case KindSeconds:
+		if value != 0 && value%3600 == 0 {
+			return fmt.Sprintf("%dh", value/3600)
+		}
+		return fmt.Sprintf("%ds", value)
+	}
+	return fmt.Sprintf("%d%s", value, p.Unit)
+}
+
+func (p Param) forever() string {
+	if p.Forever {
+		return " or " + durations.Forever
+	}
+	return ""
+}
+
+func validPorts(ports string) bool {
+	bounds := strings.SplitN(ports, "-", 2)
+	previous := 0
+	for _, bound := range bounds {
+		port, err := strconv.Atoi(bound)
+		if err != nil || port < 1 || port > 65535 || port < previous {
+			return false
+		}
+		previous = port
+	}
+	return true
+}
+
+var (
+	schemasLock sync.RWMutex
+	schemas     = map[string]Schema{}
+)
+
+// Register adds the schema of the action, the schema registered replaces the same one
+func Register(schema Schema) {
+	schemasLock.Lock()
+	defer schemasLock.Unlock()
+	schemas[key(schema.Target, schema.Action)] = schema
+}
+
+// Get returns the schema of the action, false if the action declares no schema
+func Get(target, action string) (Schema, bool) {
+	schemasLock.RLock()
+	defer schemasLock.RUnlock()
+	schema, ok := schemas[key(target, action)]
+	return schema, ok
+}
+
+func key(target, action string) string {
+	return target + "/" + action
+}
+
+// Executor validates the flags of the experiment by its schema before the wrapped executor, the destroy is never
+// validated since its flags are the ones of the experiment created
+type Executor struct {
+	spec.Executor
+}
+
+// Wrap returns the executor validating the flags
+func Wrap(executor spec.Executor) spec.Executor {
+	if _, ok := executor.(*Executor); ok {
+		return executor
+	}
+	return &Executor{Executor: executor}
+}
+
+func (e *Executor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, isDestroy := spec.IsDestroy(ctx); !isDestroy {
+		if schema, ok := Get(model.Target, model.ActionName); ok {
+			if response := schema.Validate(model.ActionFlags); response != nil {
+				return response
+			}
+		}
+	}
+	return e.Executor.Exec(uid, ctx, model)
+}
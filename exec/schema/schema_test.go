@@ -0,0 +1,101 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+func TestValidate(t *testing.T) {
+	schema := Schema{Target: "network", Action: "delay", Params: []Param{
+		{Name: "interface", Required: true},
+		{Name: "time", Kind: KindMillis, Required: true, Min: 1, Max: 60000},
+		{Name: "percent", Kind: KindInt, Min: 0, Max: 100, Unit: "%"},
+		{Name: "timeout", Kind: KindSeconds, Min: 1, Max: 86400},
+		{Name: "duration", Kind: KindSeconds, Min: 0, Max: 86400, Forever: true},
+		{Name: "local-port", Kind: KindPorts},
+		{Name: "mode", Kind: KindEnum, Values: []string{"link", "netem"}},
+	}}
+	tests := []struct {
+		flags   map[string]string
+		code    int32
+		message string
+	}{
+		{map[string]string{"interface": "eth0", "time": "100", "local-port": "80,8080-8090", "mode": "netem"}, 0, ""},
+		{map[string]string{"time": "100"}, spec.ParameterLess.Code, "less parameter: `interface`"},
+		{map[string]string{"interface": "eth0", "time": "120000"}, spec.ParameterIllegal.Code, "time must be from 1ms to 60s"},
+		{map[string]string{"interface": "eth0", "time": "1s"}, spec.ParameterIllegal.Code, "time must be from 1ms to 60s"},
+		{map[string]string{"interface": "eth0", "time": "10", "percent": "101"}, spec.ParameterIllegal.Code,
+			"percent must be from 0% to 100%"},
+		{map[string]string{"interface": "eth0", "time": "10", "timeout": "0"}, spec.ParameterIllegal.Code,
+			"timeout must be from 1s to 24h"},
+		{map[string]string{"interface": "eth0", "time": "10", "timeout": "500ms"}, spec.ParameterIllegal.Code,
+			"timeout must be from 1s to 24h"},
+		{map[string]string{"interface": "eth0", "time": "10", "timeout": "forever"}, spec.ParameterIllegal.Code,
+			"timeout must be from 1s to 24h"},
+		{map[string]string{"interface": "eth0", "time": "10", "timeout": "1h30m", "duration": "forever"}, 0, ""},
+		{map[string]string{"interface": "eth0", "time": "10", "duration": "25h"}, spec.ParameterIllegal.Code,
+			"duration must be from 0s to 24h or forever"},
+		{map[string]string{"interface": "eth0", "time": "10", "local-port": "90-80"}, spec.ParameterIllegal.Code,
+			"local-port must be the ports"},
+		{map[string]string{"interface": "eth0", "time": "10", "mode": "down"}, spec.ParameterIllegal.Code,
+			"mode must be one of link, netem"},
+	}
+	for _, tt := range tests {
+		response := schema.Validate(tt.flags)
+		if tt.code == 0 {
+			if response != nil {
+				t.Errorf("expected %v valid, got %+v", tt.flags, response)
+			}
+			continue
+		}
+		if response == nil || response.Code != tt.code || !strings.Contains(response.Err, tt.message) {
+			t.Errorf("expected %v refused by %q, got %+v", tt.flags, tt.message, response)
+		}
+	}
+}
+
+type recordExecutor struct {
+	spec.Executor
+	called bool
+}
+
+func (e *recordExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	e.called = true
+	return spec.ReturnSuccess(uid)
+}
+
+func TestWrap(t *testing.T) {
+	Register(Schema{Target: "test", Action: "wrap", Params: []Param{{Name: "count", Kind: KindInt, Min: 1, Max: 10}}})
+	executor := &recordExecutor{}
+	wrapped := Wrap(executor)
+	if Wrap(wrapped) != wrapped {
+		t.Errorf("expected the wrapped executor not wrapped again")
+	}
+	model := &spec.ExpModel{Target: "test", ActionName: "wrap", ActionFlags: map[string]string{"count": "20"}}
+	if response := wrapped.Exec("uid1", context.Background(), model); response.Success || executor.called {
+		t.Fatalf("expected the flags refused before the executor, got %+v", response)
+	}
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := wrapped.Exec("uid1", ctx, model); !response.Success || !executor.called {
+		t.Errorf("expected the destroy not validated, got %+v", response)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/schema"
+)
+
+// netnsCapabilities are required by the experiments changing the network namespace of the container from the host
+var netnsCapabilities = []string{"NET_ADMIN", "SYS_ADMIN"}
+
+func init() {
+	interfaceParam := schema.Param{Name: "interface", Required: true}
+	percent := func(name string, required bool) schema.Param {
+		return schema.Param{Name: name, Kind: schema.KindInt, Required: required, Min: 0, Max: 100, Unit: "%"}
+	}
+	ports := func(name string) schema.Param {
+		return schema.Param{Name: name, Kind: schema.KindPorts}
+	}
+	filters := []schema.Param{ports("local-port"), ports("remote-port"), ports("exclude-port")}
+	tcSchemas := map[string][]schema.Param{
+		"delay": {
+			{Name: "time", Kind: schema.KindMillis, Required: true, Min: 1, Max: 60000},
+			{Name: "offset", Kind: schema.KindMillis, Min: 0, Max: 60000},
+		},
+		"loss":      {percent("percent", true)},
+		"duplicate": {percent("percent", true)},
+		"corrupt":   {percent("percent", true)},
+		"reorder": {
+			percent("percent", true),
+			percent("correlation", false),
+			{Name: "time", Kind: schema.KindMillis, Min: 1, Max: 60000},
+		},
+	}
+	for action, params := range tcSchemas {
+		params = append(append([]schema.Param{interfaceParam}, params...), filters...)
+		schema.Register(schema.Schema{Target: "network", Action: action, Params: params,
+			Capabilities: netnsCapabilities})
+	}
+	schema.Register(schema.Schema{Target: "network", Action: "drop", Params: []schema.Param{
+		ports("source-port"),
+		ports("destination-port"),
+		{Name: "network-traffic", Kind: schema.KindEnum, Values: []string{"in", "out"}},
+	}, Capabilities: netnsCapabilities})
+
+}
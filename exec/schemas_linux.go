@@ -0,0 +1,38 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/netif"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/schema"
+)
+
+func init() {
+	schema.Register(schema.Schema{Target: "interface", Action: "flap", Params: []schema.Param{
+		{Name: InterfaceModeFlag, Kind: schema.KindEnum, Values: []string{netif.ModeLink, netif.ModeNetem}},
+		{Name: InterfaceUpIntervalFlag, Kind: schema.KindSeconds, Min: 0, Max: 86400},
+		{Name: InterfaceDownIntervalFlag, Kind: schema.KindSeconds, Min: 1, Max: 86400},
+	}, Capabilities: netnsCapabilities})
+	schema.Register(schema.Schema{Target: "interface", Action: "mtu", Params: []schema.Param{
+		{Name: InterfaceMTUFlag, Kind: schema.KindInt, Required: true, Min: 68, Max: 65535},
+	}, Capabilities: netnsCapabilities})
+	schema.Register(schema.Schema{Target: "interface", Action: "mirror", Params: []schema.Param{
+		{Name: InterfaceTunnelFlag, Kind: schema.KindEnum, Values: []string{netif.TunnelGretap, netif.TunnelVxlan}},
+		{Name: InterfaceVNIFlag, Kind: schema.KindInt, Min: 1, Max: 16777215},
+		{Name: InterfacePortFlag, Kind: schema.KindInt, Min: 1, Max: 65535},
+	}, Capabilities: netnsCapabilities})
+}
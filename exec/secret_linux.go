@@ -0,0 +1,195 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/tamper"
+)
+
+// The flags of the secret experiment
+const (
+	SecretPathFlag    = "path"
+	SecretModeFlag    = "mode"
+	SecretContentFlag = "content"
+)
+
+type SecretCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewSecretCommandSpec() spec.ExpModelCommandSpec {
+	return &SecretCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&SecretTamperActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:     SecretPathFlag,
+								Desc:     "path of the file in the container, it must be on a volume, such as /etc/tls/tls.crt",
+								Required: true,
+							},
+							&spec.ExpFlag{
+								Name: SecretModeFlag,
+								Desc: fmt.Sprintf("%s empties the file, %s fills it with random bytes of the same size, "+
+									"%s writes the content flag, default is %s",
+									tamper.ModeTruncate, tamper.ModeGarbage, tamper.ModeReplace, tamper.ModeTruncate),
+								Default: tamper.ModeTruncate,
+							},
+							&spec.ExpFlag{
+								Name: SecretContentFlag,
+								Desc: "content written by the replace mode, such as an expired certificate",
+							},
+						},
+						ActionExecutor: &secretTamperExecutor{},
+						ActionLongDesc: "The mounted certificate or secret file of the container is corrupted on the host " +
+							"in place, the original content is saved before and written back by the destroy if the file " +
+							"still has the tampered content. The files of the secret, configMap and projected volumes are " +
+							"rewritten by the kubelet when the objects change, which ends the experiment earlier.",
+						ActionExample: `# Truncate the tls certificate of the container
+blade create cri secret tamper --path /etc/tls/tls.crt --container-id ee54f1e61c08
+
+# Replace the token with an invalid one
+blade create cri secret tamper --path /var/run/secrets/app/token --mode replace --content invalid --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*SecretCommandModelSpec) Name() string {
+	return "secret"
+}
+
+func (*SecretCommandModelSpec) ShortDesc() string {
+	return "Secret experiment"
+}
+
+func (*SecretCommandModelSpec) LongDesc() string {
+	return "Secret experiment, the certificate and secret files mounted into the container are tampered and restored."
+}
+
+type SecretTamperActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*SecretTamperActionCommand) Name() string {
+	return "tamper"
+}
+
+func (*SecretTamperActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*SecretTamperActionCommand) ShortDesc() string {
+	return "secret file tamper"
+}
+
+func (c *SecretTamperActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+// SecretResult is the result of the secret experiment
+type SecretResult struct {
+	ContainerId string `json:"containerId"`
+	File        string `json:"file"`
+	Mode        string `json:"mode"`
+	// Warning is set if the kubelet may rewrite the file before the destroy
+	Warning string `json:"warning,omitempty"`
+}
+
+type secretTamperExecutor struct {
+}
+
+func (e *secretTamperExecutor) Name() string {
+	return "secret"
+}
+
+func (e *secretTamperExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *secretTamperExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		restored, err := tamper.Restore(util.GetProgramPath(), suid)
+		if err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "restore", err)
+		}
+		if !restored {
+			log.Warnf(ctx, "the file of experiment %s is not restored, it is rewritten or already restored", suid)
+		}
+		return spec.ReturnSuccess(uid)
+	}
+	flags := model.ActionFlags
+	file := flags[SecretPathFlag]
+	if file == "" {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, SecretPathFlag)
+	}
+	mode := flags[SecretModeFlag]
+	if mode == "" {
+		mode = tamper.ModeTruncate
+	}
+	switch mode {
+	case tamper.ModeTruncate, tamper.ModeGarbage:
+	case tamper.ModeReplace:
+		if flags[SecretContentFlag] == "" {
+			return spec.ResponseFailWithFlags(spec.ParameterLess, SecretContentFlag)
+		}
+	default:
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, SecretModeFlag, mode,
+			fmt.Sprintf("it must be %s, %s or %s", tamper.ModeTruncate, tamper.ModeGarbage, tamper.ModeReplace))
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	containerInfo, response := GetContainer(ctx, client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name],
+		parseContainerLabelSelector(flags[ContainerLabelSelectorFlag.Name]))
+	if !response.Success {
+		return response
+	}
+	mount, ok := containerInfo.MountOf(file)
+	if !ok {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, SecretPathFlag, file, "it is not on a volume")
+	}
+	result := SecretResult{ContainerId: containerInfo.ContainerId, File: file, Mode: mode}
+	if mount.IsSynced() {
+		result.Warning = fmt.Sprintf("the %s volume %s is synced by the kubelet, the file may be rewritten before the destroy",
+			mount.VolumeType, mount.VolumeName)
+		log.Warnf(ctx, "%s", result.Warning)
+	}
+	// the file is resolved in the source of the volume, its links never lead to the files of the host
+	root, name := mount.RootOf(file)
+	tampering, err := tamper.Tamper(util.GetProgramPath(), uid, root, name, mode, []byte(flags[SecretContentFlag]))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, SecretPathFlag, file, err)
+	}
+	log.Infof(ctx, "the file %s of container %s is tampered by %s, host file %s", file, containerInfo.ContainerId,
+		mode, tampering.File)
+	return spec.ReturnSuccess(result)
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+func runSecret(ctx context.Context, hostPath, volumeType string, flags map[string]string) *spec.Response {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1", Mounts: []container.Mount{
+		{ContainerPath: "/etc/tls", HostPath: hostPath, ReadOnly: true, VolumeType: volumeType, VolumeName: "tls"},
+	}})
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	flags[ContainerIdFlag.Name] = "c1"
+	model := &spec.ExpModel{Target: "secret", ActionName: "tamper", ActionFlags: flags}
+	return (&secretTamperExecutor{}).Exec("uid1", ctx, model)
+}
+
+func TestSecretTamper(t *testing.T) {
+	hostPath := t.TempDir()
+	file := path.Join(hostPath, "tls.crt")
+	if err := os.WriteFile(file, []byte("certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	flags := map[string]string{SecretPathFlag: "/etc/tls/tls.crt", SecretModeFlag: "replace", SecretContentFlag: "expired"}
+	response := runSecret(context.Background(), hostPath, container.VolumeSecret, flags)
+	if !response.Success {
+		t.Fatalf("expected success, got %+v", response)
+	}
+	if result := response.Result.(SecretResult); result.Warning == "" {
+		t.Errorf("expected the warning of the secret volume, got %+v", result)
+	}
+	if content, _ := os.ReadFile(file); string(content) != "expired" {
+		t.Errorf("expected the file replaced, got %q", content)
+	}
+	response = runSecret(spec.SetDestroyFlag(context.Background(), "uid1"), hostPath, container.VolumeSecret,
+		map[string]string{})
+	if !response.Success {
+		t.Fatalf("expected the destroy success, got %+v", response)
+	}
+	if content, _ := os.ReadFile(file); string(content) != "certificate" {
+		t.Errorf("expected the file restored, got %q", content)
+	}
+}
+
+func TestSecretTamperIllegal(t *testing.T) {
+	hostPath, outside := t.TempDir(), path.Join(t.TempDir(), "shadow")
+	if err := os.WriteFile(outside, []byte("root:x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the link of the volume must not lead to the file of the host
+	if err := os.Symlink(outside, path.Join(hostPath, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		flags map[string]string
+		code  int32
+	}{
+		{map[string]string{}, spec.ParameterLess.Code},
+		{map[string]string{SecretPathFlag: "/etc/tls/tls.crt", SecretModeFlag: "replace"}, spec.ParameterLess.Code},
+		{map[string]string{SecretPathFlag: "/etc/tls/tls.crt", SecretModeFlag: "rot13"}, spec.ParameterIllegal.Code},
+		{map[string]string{SecretPathFlag: "/etc/passwd"}, spec.ParameterInvalid.Code},
+		{map[string]string{SecretPathFlag: "/etc/tls/absent"}, spec.ParameterInvalid.Code},
+		{map[string]string{SecretPathFlag: "/etc/tls/escape"}, spec.ParameterInvalid.Code},
+	}
+	for _, test := range tests {
+		response := runSecret(context.Background(), hostPath, container.VolumeHostPath, test.flags)
+		if response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of %v, got %+v", test.code, test.flags, response)
+		}
+	}
+	if content, _ := os.ReadFile(outside); string(content) != "root:x" {
+		t.Errorf("expected the file of the host unchanged, got %q", content)
+	}
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/unixsock"
+)
+
+// The flags of the socket experiment
+const (
+	SocketPathFlag = "path"
+	SocketModeFlag = "mode"
+)
+
+type SocketCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewSocketCommandSpec() spec.ExpModelCommandSpec {
+	return &SocketCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&SocketBlockActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:     SocketPathFlag,
+								Desc:     "path of the unix socket in the container, such as /var/run/app/app.sock",
+								Required: true,
+							},
+							&spec.ExpFlag{
+								Name: SocketModeFlag,
+								Desc: fmt.Sprintf("%s renames the socket so the clients get no such file, %s removes its "+
+									"permissions so the non root clients get permission denied, default is %s",
+									unixsock.ModeRename, unixsock.ModeChmod, unixsock.ModeRename),
+								Default: unixsock.ModeRename,
+							},
+						},
+						ActionExecutor: &socketBlockExecutor{},
+						ActionLongDesc: "The named unix socket of the container is blocked on the host, the server keeps " +
+							"listening while the new connections of the clients fail, the established connections are not " +
+							"impacted. The socket is on the writable layer of the container or a volume shared with the " +
+							"sidecars. The destroy renames the socket back or restores its permissions, unless the socket " +
+							"is recreated meanwhile by a restart of the server.",
+						ActionExample: `# Rename the socket of the sidecar shared by an emptyDir volume
+blade create cri socket block --path /var/run/envoy/admin.sock --container-id ee54f1e61c08
+
+# Remove the permissions of the socket on the writable layer
+blade create cri socket block --path /tmp/app.sock --mode chmod --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*SocketCommandModelSpec) Name() string {
+	return "socket"
+}
+
+func (*SocketCommandModelSpec) ShortDesc() string {
+	return "Unix socket experiment"
+}
+
+func (*SocketCommandModelSpec) LongDesc() string {
+	return "Unix socket experiment, the named unix sockets of the container are blocked and restored."
+}
+
+type SocketBlockActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*SocketBlockActionCommand) Name() string {
+	return "block"
+}
+
+func (*SocketBlockActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*SocketBlockActionCommand) ShortDesc() string {
+	return "unix socket block"
+}
+
+func (c *SocketBlockActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+// SocketResult is the result of the socket experiment
+type SocketResult struct {
+	ContainerId string `json:"containerId"`
+	File        string `json:"file"`
+	Mode        string `json:"mode"`
+	HostFile    string `json:"hostFile"`
+	// Warning is set if the socket is shared with the host
+	Warning string `json:"warning,omitempty"`
+}
+
+type socketBlockExecutor struct {
+}
+
+func (e *socketBlockExecutor) Name() string {
+	return "socket"
+}
+
+func (e *socketBlockExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *socketBlockExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		restored, err := unixsock.Restore(util.GetProgramPath(), suid)
+		if err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "restore", err)
+		}
+		if !restored {
+			log.Warnf(ctx, "the socket of experiment %s is not restored, it is recreated or already restored", suid)
+		}
+		return spec.ReturnSuccess(uid)
+	}
+	flags := model.ActionFlags
+	file := flags[SocketPathFlag]
+	if file == "" {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, SocketPathFlag)
+	}
+	mode := flags[SocketModeFlag]
+	if mode == "" {
+		mode = unixsock.ModeRename
+	}
+	if mode != unixsock.ModeRename && mode != unixsock.ModeChmod {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, SocketModeFlag, mode,
+			fmt.Sprintf("it must be %s or %s", unixsock.ModeRename, unixsock.ModeChmod))
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	containerInfo, response := GetContainer(ctx, client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name],
+		parseContainerLabelSelector(flags[ContainerLabelSelectorFlag.Name]))
+	if !response.Success {
+		return response
+	}
+	result := SocketResult{ContainerId: containerInfo.ContainerId, File: file, Mode: mode}
+	var root, name string
+	var code int32
+	if mount, ok := containerInfo.MountOf(file); ok {
+		// the socket itself is bind mounted from the host, such as the docker.sock
+		if path.Clean(mount.ContainerPath) == path.Clean(file) {
+			if mode == unixsock.ModeRename {
+				return spec.ResponseFailWithFlags(spec.ParameterInvalid, SocketPathFlag, file,
+					fmt.Sprintf("the socket is bind mounted, the rename is not seen in the container, use the %s mode",
+						unixsock.ModeChmod))
+			}
+			result.Warning = fmt.Sprintf("the socket is bind mounted from %s, its permissions are changed for all "+
+				"the clients on the node", mount.HostPath)
+			log.Warnf(ctx, "%s", result.Warning)
+		}
+		root, name = mount.RootOf(file)
+	} else {
+		name = file
+		if root, err, code = client.GetRootfsPath(ctx, containerInfo.ContainerId); err != nil {
+			return spec.ResponseFail(code, err.Error(), nil)
+		}
+	}
+	// the directories are resolved in the container, and the socket is changed relative to the directory resolved
+	blocking, err := unixsock.Block(util.GetProgramPath(), uid, root, name, mode)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, SocketPathFlag, file, err)
+	}
+	result.HostFile = blocking.File
+	log.Infof(ctx, "the socket %s of container %s is blocked by %s, host file %s", file, containerInfo.ContainerId,
+		mode, result.HostFile)
+	return spec.ReturnSuccess(result)
+}
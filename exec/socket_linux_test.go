@@ -0,0 +1,125 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"net"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+func runSocketBlock(ctx context.Context, rootfs, volume string, flags map[string]string) *spec.Response {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1", Mounts: []container.Mount{
+		{ContainerPath: "/var/run/envoy", HostPath: volume, VolumeType: container.VolumeEmptyDir, VolumeName: "envoy"},
+		{ContainerPath: "/var/run/docker.sock", HostPath: "/var/run/docker.sock", VolumeType: container.VolumeHostPath},
+	}})
+	client.GetRootfsPathFunc = func(ctx context.Context, containerId string) (string, error, int32) {
+		return rootfs, nil, 0
+	}
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	flags[ContainerIdFlag.Name] = "c1"
+	model := &spec.ExpModel{Target: "socket", ActionName: "block", ActionFlags: flags}
+	return (&socketBlockExecutor{}).Exec("uid1", ctx, model)
+}
+
+func listenUnix(t *testing.T, file string) {
+	t.Helper()
+	listener, err := net.Listen("unix", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+}
+
+func TestSocketBlock(t *testing.T) {
+	rootfs, volume := t.TempDir(), t.TempDir()
+	if err := os.Mkdir(path.Join(rootfs, "tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	listenUnix(t, path.Join(rootfs, "tmp", "app.sock"))
+	listenUnix(t, path.Join(volume, "admin.sock"))
+	tests := []struct {
+		containerPath string
+		hostFile      string
+	}{
+		{"/tmp/app.sock", path.Join(rootfs, "tmp", "app.sock")},
+		{"/var/run/envoy/admin.sock", path.Join(volume, "admin.sock")},
+	}
+	for _, test := range tests {
+		response := runSocketBlock(context.Background(), rootfs, volume, map[string]string{SocketPathFlag: test.containerPath})
+		if !response.Success {
+			t.Fatalf("expected success of %s, got %+v", test.containerPath, response)
+		}
+		if result := response.Result.(SocketResult); result.HostFile != test.hostFile {
+			t.Errorf("expected the host file %s, got %+v", test.hostFile, result)
+		}
+		if _, err := net.Dial("unix", test.hostFile); err == nil {
+			t.Errorf("expected the socket %s blocked", test.containerPath)
+		}
+		response = runSocketBlock(spec.SetDestroyFlag(context.Background(), "uid1"), rootfs, volume, map[string]string{})
+		if !response.Success {
+			t.Fatalf("expected the destroy success, got %+v", response)
+		}
+		if conn, err := net.Dial("unix", test.hostFile); err != nil {
+			t.Errorf("expected the socket %s restored, %v", test.containerPath, err)
+		} else {
+			conn.Close()
+		}
+	}
+}
+
+func TestSocketBlockIllegal(t *testing.T) {
+	rootfs, volume, outside := t.TempDir(), t.TempDir(), t.TempDir()
+	if err := os.WriteFile(path.Join(volume, "admin.conf"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the linked directory of the container leads to the socket of the host
+	listenUnix(t, path.Join(outside, "host.sock"))
+	if err := os.Symlink(outside, path.Join(rootfs, "run")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		flags map[string]string
+		code  int32
+	}{
+		{map[string]string{}, spec.ParameterLess.Code},
+		{map[string]string{SocketPathFlag: "/tmp/app.sock", SocketModeFlag: "unlink"}, spec.ParameterIllegal.Code},
+		{map[string]string{SocketPathFlag: "/tmp/app.sock"}, spec.ParameterInvalid.Code},
+		{map[string]string{SocketPathFlag: "/var/run/envoy/admin.conf"}, spec.ParameterInvalid.Code},
+		{map[string]string{SocketPathFlag: "/var/run/docker.sock"}, spec.ParameterInvalid.Code},
+		{map[string]string{SocketPathFlag: "/run/host.sock"}, spec.ParameterInvalid.Code},
+	}
+	for _, test := range tests {
+		response := runSocketBlock(context.Background(), rootfs, volume, test.flags)
+		if response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of %v, got %+v", test.code, test.flags, response)
+		}
+	}
+	if _, err := os.Lstat(path.Join(outside, "host.sock")); err != nil {
+		t.Errorf("expected the socket of the host untouched, %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package statefile keeps the states of the experiments in the state dir, such as the helper pids and the values to
+// restore, which the destroy reads, maybe by another blade process after the agent restarted. The states are written
+// atomically, so a crash during the write leaves the previous state or none, never a partial one.
+package statefile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+)
+
+// Path returns the state file of the experiment of the kind under the state dir, such as netif-<uid>.json
+func Path(stateDir, kind, uid string) string {
+	return path.Join(stateDir, fmt.Sprintf("%s-%s.json", kind, uid))
+}
+
+// Save writes the state as json to the file atomically, see WriteFile
+func Save(file string, state interface{}) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return WriteFile(file, content)
+}
+
+// WriteFile writes the content to the file by a temporary file in the same dir readable by the owner only, it's
+// synced and renamed to the file, then the dir is synced so the rename is durable too. It keeps the backups as well,
+// such as the original content of a file changed by the experiment
+func WriteFile(file string, content []byte) error {
+	temp, err := os.CreateTemp(path.Dir(file), path.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err = temp.Write(content); err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	if dir, err := os.Open(path.Dir(file)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// Load reads the state of the file into the value, the error is os.ErrNotExist if it is not saved
+func Load(file string, state interface{}) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, state); err != nil {
+		return fmt.Errorf("illegal state %s, %v", file, err)
+	}
+	return nil
+}
+
+// Remove removes the state file, it succeeds if the state is not saved
+func Remove(file string) error {
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
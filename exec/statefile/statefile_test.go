@@ -0,0 +1,90 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package statefile
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+type state struct {
+	Uid     string   `json:"uid"`
+	Pid     int      `json:"pid,omitempty"`
+	Restore []string `json:"restore,omitempty"`
+}
+
+func TestState(t *testing.T) {
+	dir := t.TempDir()
+	file := Path(dir, "netif", "uid1")
+	if file != path.Join(dir, "netif-uid1.json") {
+		t.Errorf("unexpected state file %s", file)
+	}
+	var loaded state
+	if err := Load(file, &loaded); !os.IsNotExist(err) {
+		t.Errorf("expected not exist, got %v", err)
+	}
+	saved := state{Uid: "uid1", Pid: 42, Restore: []string{"ip link set dev eth0 up"}}
+	if err := Save(file, saved); err != nil {
+		t.Fatal(err)
+	}
+	// the state saved again replaces the previous one
+	saved.Pid = 43
+	if err := Save(file, saved); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(file, &loaded); err != nil || loaded.Pid != 43 || len(loaded.Restore) != 1 {
+		t.Errorf("expected %+v, got %+v, %v", saved, loaded, err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the state readable by the owner only, got %v, %v", info, err)
+	}
+	// no temporary file is left
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("expected the state file only, got %v, %v", entries, err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := Remove(file); err != nil {
+			t.Errorf("remove failed, %v", err)
+		}
+	}
+}
+
+func TestSaveFailed(t *testing.T) {
+	dir := t.TempDir()
+	file := Path(dir, "netif", "uid1")
+	if err := Save(file, state{Uid: "uid1", Pid: 42}); err != nil {
+		t.Fatal(err)
+	}
+	// the state unmarshalable keeps the previous one
+	if err := Save(file, make(chan int)); err == nil {
+		t.Fatal("expected the save failed")
+	}
+	var loaded state
+	if err := Load(file, &loaded); err != nil || loaded.Pid != 42 {
+		t.Errorf("expected the previous state kept, got %+v, %v", loaded, err)
+	}
+	if err := os.WriteFile(file, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(file, &loaded); err == nil || os.IsNotExist(err) {
+		t.Errorf("expected the illegal state refused, got %v", err)
+	}
+	if err := Save(path.Join(dir, "missing", "netif-uid1.json"), state{}); err == nil {
+		t.Error("expected the save in the missing dir failed")
+	}
+}
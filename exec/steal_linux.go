@@ -0,0 +1,358 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rollback"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/trace"
+)
+
+// The flags of the steal experiment
+const (
+	StealPercentFlag = "cpu-percent"
+	StealWeightFlag  = "weight"
+)
+
+// stealCgroupPrefix is the name prefix of the sibling cgroups running the cpu burners
+const stealCgroupPrefix = "chaosblade-steal-"
+
+type StealCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewStealCommandSpec() spec.ExpModelCommandSpec {
+	return &StealCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&StealCpuActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name:    StealPercentFlag,
+								Desc:    "percent of every cpu burned by the neighbor, 1 to 100, default is 100",
+								Default: "100",
+							},
+							&spec.ExpFlag{
+								Name:    StealWeightFlag,
+								Desc:    "cpu weight of the neighbor cgroup, 1 to 10000, default is 10000",
+								Default: "10000",
+							},
+						},
+						ActionExecutor: &stealActionExecutor{},
+						ActionLongDesc: "A noisy neighbor cgroup pinned to the cpus of the container burns them with a high cpu weight, so the container waits for the cpus like its neighbor steals them, the container itself is not throttled.",
+						ActionExample: `# Steal the cpus of the container ee54f1e61c08 by a neighbor burning 80% of them for 60 seconds
+blade create cri steal cpu --cpu-percent 80 --timeout 60 --container-id ee54f1e61c08 --container-runtime containerd`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*StealCommandModelSpec) Name() string {
+	return "steal"
+}
+
+func (*StealCommandModelSpec) ShortDesc() string {
+	return "Noisy neighbor experiment"
+}
+
+func (*StealCommandModelSpec) LongDesc() string {
+	return "Noisy neighbor experiment, the resources of the container are contended by a sibling cgroup of its pod " +
+		"instead of limiting the container, it validates the latency objectives under the contention."
+}
+
+type StealCpuActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*StealCpuActionCommand) Name() string {
+	return "cpu"
+}
+
+func (*StealCpuActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*StealCpuActionCommand) ShortDesc() string {
+	return "cpu steal by a noisy neighbor"
+}
+
+func (s *StealCpuActionCommand) LongDesc() string {
+	return s.ActionLongDesc
+}
+
+// StealResult is the result of the steal experiment
+type StealResult struct {
+	ContainerId string `json:"containerId"`
+	Pid         int    `json:"pid"`
+	Cgroup      string `json:"cgroup"`
+	Cpus        string `json:"cpus"`
+}
+
+type stealActionExecutor struct {
+}
+
+func (e *stealActionExecutor) Name() string {
+	return "steal"
+}
+
+func (e *stealActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *stealActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flags := model.ActionFlags
+	_, isDestroy := spec.IsDestroy(ctx)
+	var percent, weight int
+	if !isDestroy {
+		var response *spec.Response
+		if percent, response = rangeFlag(flags, StealPercentFlag, 100, 1, 100); response != nil {
+			return response
+		}
+		if weight, response = rangeFlag(flags, StealWeightFlag, 10000, 1, 10000); response != nil {
+			return response
+		}
+	}
+	client, err := GetClientByRuntime(model)
+	if err != nil {
+		log.Errorf(ctx, spec.ContainerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "GetClient", err)
+	}
+	containerInfo, response := GetContainer(ctx, client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name],
+		parseContainerLabelSelector(flags[ContainerLabelSelectorFlag.Name]))
+	if !response.Success {
+		return response
+	}
+	pid, err, code := client.GetPidById(ctx, containerInfo.ContainerId)
+	if err != nil {
+		return spec.ResponseFail(code, err.Error(), nil)
+	}
+	cgroupPath, err := container.ResolveCgroupPath(pid, "")
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "ResolveCgroupPath", err)
+	}
+	cgroup, err := newStealCgroup(cgroupPath, uid)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "ResolveCgroupPath", err)
+	}
+	if isDestroy {
+		if err := cgroup.remove(); err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove cgroup", err)
+		}
+		return spec.ReturnSuccess(uid)
+	}
+	cpus, err := container.AllowedCpus(pid)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ContainerExecFailed, "AllowedCpus", err)
+	}
+	steps := rollback.New(ctx, uid)
+	// the cgroup created partially is removed too
+	steps.Done("create cgroup", cgroup.remove)
+	if err := cgroup.create(cpus, weight); err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "create cgroup", err))
+	}
+	// the burner is not in the namespaces of the container, it is a process of the neighbor on the host
+	command, err := cgroup.start(ctx, trace.Environ(ctx), path.Join(util.GetProgramPath(), spec.BinPath, spec.ChaosOsBin),
+		spec.Create, "cpu", "fullload",
+		fmt.Sprintf("--cpu-list=%s", cpus),
+		fmt.Sprintf("--cpu-percent=%d", percent),
+		fmt.Sprintf("--uid=%s", uid))
+	if err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.ChaosOsBin, err))
+	}
+	go command.Wait()
+	steps.Done("start burner", command.Process.Kill)
+	log.Infof(ctx, "steal the cpus %s of container %s by the cgroup %s", cpus, containerInfo.ContainerId, cgroup.Cpu)
+	return spec.ReturnSuccess(StealResult{
+		ContainerId: containerInfo.ContainerId,
+		Pid:         command.Process.Pid,
+		Cgroup:      cgroup.Cpu,
+		Cpus:        cpus,
+	})
+}
+
+// stealCgroup is the neighbor cgroup of the experiment, the cpu and the cpuset dirs are the same on cgroup v2
+type stealCgroup struct {
+	Version int
+	Cpu     string
+	Cpuset  string
+}
+
+func newStealCgroup(cgroupPath container.CgroupPath, uid string) (stealCgroup, error) {
+	cpuDir, err := cgroupPath.Absolute("cpu")
+	if err != nil {
+		return stealCgroup{}, err
+	}
+	cpusetDir, err := cgroupPath.Absolute("cpuset")
+	if err != nil {
+		return stealCgroup{}, err
+	}
+	return stealCgroup{
+		Version: cgroupPath.Version,
+		Cpu:     path.Join(stealParent(cpuDir), stealCgroupPrefix+uid),
+		Cpuset:  path.Join(stealParent(cpusetDir), stealCgroupPrefix+uid),
+	}, nil
+}
+
+// stealParent returns the parent of the neighbor cgroup, it is the parent of the pod cgroup so the neighbor is not
+// limited by the quota of the pod, or the parent of the container cgroup if it is not in a pod
+func stealParent(dir string) string {
+	parent := path.Dir(dir)
+	name := path.Base(parent)
+	// /kubepods/burstable/pod<uid> or /kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod<uid>.slice
+	if strings.HasPrefix(name, "pod") || strings.Contains(name, "-pod") {
+		return path.Dir(parent)
+	}
+	return parent
+}
+
+func (s stealCgroup) dirs() []string {
+	if s.Cpuset == s.Cpu {
+		return []string{s.Cpu}
+	}
+	return []string{s.Cpu, s.Cpuset}
+}
+
+// create creates the cgroup pinned to the cpus, the cpuset.mems of cgroup v1 must be set before any process joins
+func (s stealCgroup) create(cpus string, weight int) error {
+	for _, dir := range s.dirs() {
+		if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	if s.Version == container.CgroupV1 {
+		mems, err := os.ReadFile(path.Join(path.Dir(s.Cpuset), "cpuset.mems"))
+		if err != nil {
+			return err
+		}
+		if err := writeCgroupFile(s.Cpuset, "cpuset.mems", strings.TrimSpace(string(mems))); err != nil {
+			return err
+		}
+	}
+	if err := writeCgroupFile(s.Cpuset, "cpuset.cpus", cpus); err != nil {
+		return err
+	}
+	if s.Version == container.CgroupV2 {
+		return writeCgroupFile(s.Cpu, "cpu.weight", strconv.Itoa(weight))
+	}
+	return writeCgroupFile(s.Cpu, "cpu.shares", strconv.Itoa(weightToShares(weight)))
+}
+
+// start starts the command in the cgroup, so the burner never runs outside it, not even before it is moved. The
+// process is cloned into the cgroup by CLONE_INTO_CGROUP on cgroup v2, or it is started by the shell which joins the
+// cgroup before it execs the command, on cgroup v1 or the kernels before 5.7
+func (s stealCgroup) start(ctx context.Context, env []string, name string, args ...string) (*exec.Cmd, error) {
+	if s.Version == container.CgroupV2 {
+		command := exec.Command(name, args...)
+		command.Env = env
+		if dir, err := os.Open(s.Cpu); err == nil {
+			command.SysProcAttr = &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: int(dir.Fd())}
+			err = command.Start()
+			dir.Close()
+			if err == nil {
+				return command, nil
+			}
+			log.Warnf(ctx, "start %s in the cgroup %s by CLONE_INTO_CGROUP failed, join it by the shell, %v", name,
+				s.Cpu, err)
+		}
+	}
+	// $0 and $1 are the cgroup.procs files, the same file of cgroup v2 is written twice, the shell reports it joined
+	// the cgroup by the fd 3, and closes it before the exec
+	dirs := s.dirs()
+	stub := append([]string{"-c", `echo $$ >"$0" && echo $$ >"$1" && echo joined >&3 && exec 3>&- && shift && exec "$@"`,
+		path.Join(dirs[0], "cgroup.procs"), path.Join(dirs[len(dirs)-1], "cgroup.procs"), name}, args...)
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	command := exec.Command("/bin/sh", stub...)
+	command.Env = env
+	command.ExtraFiles = []*os.File{writer}
+	err = command.Start()
+	writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	if joined, _ := io.ReadAll(reader); strings.TrimSpace(string(joined)) != "joined" {
+		command.Wait()
+		return nil, fmt.Errorf("join the cgroup %s failed", strings.Join(dirs, ","))
+	}
+	return command, nil
+}
+
+// remove kills the processes in the cgroup and removes it, it succeeds if the cgroup is already removed
+func (s stealCgroup) remove() error {
+	for _, dir := range s.dirs() {
+		procs, err := os.ReadFile(path.Join(dir, "cgroup.procs"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		for _, field := range strings.Fields(string(procs)) {
+			if pid, err := strconv.Atoi(field); err == nil {
+				syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+		// the cgroup is busy until the killed processes exited
+		for i := 0; ; i++ {
+			err = os.Remove(dir)
+			if err == nil || os.IsNotExist(err) {
+				break
+			}
+			if i == 50 {
+				return err
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+func writeCgroupFile(dir, file, value string) error {
+	if err := os.WriteFile(path.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("write %s to %s failed, %v", value, path.Join(dir, file), err)
+	}
+	return nil
+}
+
+// weightToShares converts the cgroup v2 cpu weight to the cgroup v1 cpu shares, it is the inverse of the conversion
+// of the container runtimes from the shares to the weight
+func weightToShares(weight int) int {
+	return 2 + (weight-1)*262142/9999
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container/mock"
+)
+
+func TestStealParent(t *testing.T) {
+	tests := []struct {
+		dir    string
+		parent string
+	}{
+		{"/sys/fs/cgroup/cpu/kubepods/burstable/pod1234/c0ffee", "/sys/fs/cgroup/cpu/kubepods/burstable"},
+		{"/sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-c0ffee.scope",
+			"/sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice"},
+		{"/sys/fs/cgroup/kubepods.slice/kubepods-pod1234.slice/crio-c0ffee.scope", "/sys/fs/cgroup/kubepods.slice"},
+		{"/sys/fs/cgroup/system.slice/docker-c0ffee.scope", "/sys/fs/cgroup/system.slice"},
+	}
+	for _, test := range tests {
+		if parent := stealParent(test.dir); parent != test.parent {
+			t.Errorf("expected the parent %s of %s, got %s", test.parent, test.dir, parent)
+		}
+	}
+}
+
+func TestWeightToShares(t *testing.T) {
+	for weight, shares := range map[int]int{1: 2, 100: 2597, 10000: 262144} {
+		if actual := weightToShares(weight); actual != shares {
+			t.Errorf("expected the shares %d of the weight %d, got %d", shares, weight, actual)
+		}
+	}
+}
+
+func readCgroupFile(t *testing.T, dir, file string) string {
+	t.Helper()
+	content, err := os.ReadFile(path.Join(dir, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestStealCgroupCreate(t *testing.T) {
+	root := t.TempDir()
+	cpuParent, cpusetParent := path.Join(root, "cpu,cpuacct"), path.Join(root, "cpuset")
+	for _, dir := range []string{cpuParent, cpusetParent} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path.Join(cpusetParent, "cpuset.mems"), []byte("0-1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v1 := stealCgroup{
+		Version: container.CgroupV1,
+		Cpu:     path.Join(cpuParent, stealCgroupPrefix+"uid1"),
+		Cpuset:  path.Join(cpusetParent, stealCgroupPrefix+"uid1"),
+	}
+	if err := v1.create("0-3", 10000); err != nil {
+		t.Fatalf("create failed, %v", err)
+	}
+	command, err := v1.start(context.Background(), nil, "/bin/sleep", "10")
+	if err != nil {
+		t.Fatalf("start failed, %v", err)
+	}
+	defer command.Wait()
+	defer command.Process.Kill()
+	// the shell writes the pid with the newline
+	pid := strconv.Itoa(command.Process.Pid) + "\n"
+	expected := map[string]string{
+		path.Join(v1.Cpuset, "cpuset.mems"):  "0-1",
+		path.Join(v1.Cpuset, "cpuset.cpus"):  "0-3",
+		path.Join(v1.Cpu, "cpu.shares"):      "262144",
+		path.Join(v1.Cpu, "cgroup.procs"):    pid,
+		path.Join(v1.Cpuset, "cgroup.procs"): pid,
+	}
+	for file, value := range expected {
+		if actual := readCgroupFile(t, path.Dir(file), path.Base(file)); actual != value {
+			t.Errorf("expected %s of %s, got %s", value, file, actual)
+		}
+	}
+
+	v2 := stealCgroup{Version: container.CgroupV2, Cpu: path.Join(root, "v2")}
+	v2.Cpuset = v2.Cpu
+	if err := v2.create("2", 500); err != nil {
+		t.Fatalf("create failed, %v", err)
+	}
+	if weight := readCgroupFile(t, v2.Cpu, "cpu.weight"); weight != "500" {
+		t.Errorf("expected the weight 500, got %s", weight)
+	}
+	if _, err := os.Stat(path.Join(v2.Cpu, "cpuset.mems")); !os.IsNotExist(err) {
+		t.Errorf("expected no cpuset.mems of cgroup v2, got %v", err)
+	}
+	// the directory is not a cgroup, the shell joins it instead of CLONE_INTO_CGROUP
+	command, err = v2.start(context.Background(), nil, "/bin/true")
+	if err != nil {
+		t.Fatalf("start failed, %v", err)
+	}
+	command.Wait()
+	if procs := readCgroupFile(t, v2.Cpu, "cgroup.procs"); procs != strconv.Itoa(command.Process.Pid)+"\n" {
+		t.Errorf("expected the burner joined the cgroup before it runs, got %s", procs)
+	}
+	// the burner never runs if the cgroup can't be joined
+	missing := stealCgroup{Version: container.CgroupV1, Cpu: path.Join(root, "missing"), Cpuset: path.Join(root, "missing")}
+	if command, err := missing.start(context.Background(), nil, "/bin/sleep", "10"); err == nil {
+		command.Process.Kill()
+		t.Error("expected the burner refused without the cgroup")
+	}
+}
+
+func TestStealIllegalFlags(t *testing.T) {
+	client := mock.NewContainer(container.ContainerInfo{ContainerId: "c1"})
+	NewClientFunc = func(expModel *spec.ExpModel) (container.Container, error) {
+		return client, nil
+	}
+	defer func() { NewClientFunc = nil }()
+	for _, flags := range []map[string]string{
+		{StealPercentFlag: "0"},
+		{StealPercentFlag: "101"},
+		{StealWeightFlag: "x"},
+		{StealWeightFlag: "10001"},
+	} {
+		flags[ContainerIdFlag.Name] = "c1"
+		model := &spec.ExpModel{Target: "steal", ActionName: "cpu", ActionFlags: flags}
+		response := (&stealActionExecutor{}).Exec("uid1", context.Background(), model)
+		if response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the illegal flags %v, got %+v", flags, response)
+		}
+	}
+	if calls := client.CallsOf("GetContainerById"); len(calls) != 0 {
+		t.Errorf("expected no container lookup of the illegal flags, got %v", calls)
+	}
+}
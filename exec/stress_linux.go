@@ -0,0 +1,284 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/holder"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/loadgen"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rollback"
+)
+
+// The flags of the stress experiment
+const (
+	StressGeneratorFlag = "generator"
+	StressWorkersFlag   = "workers"
+	StressPercentFlag   = "percent"
+	StressBytesFlag     = "bytes"
+	StressDurationFlag  = "duration"
+)
+
+// startStress starts the generator in the pid namespace and the cgroups of the process, so the load is charged to
+// the container and limited by its limits, it returns the host pid of nsexec
+var startStress = func(ctx context.Context, pid int32, cgroupRoot string, argv []string) (int, error) {
+	return startInCgroup(ctx, pid, cgroupRoot, []nsexec.Namespace{nsexec.Pid}, argv)
+}
+
+// stressGenerator returns the generator of the flag, stress-ng is the one copied under the bin of the program path
+// if present, otherwise the one of the PATH of the host
+func stressGenerator(name string) (loadgen.Generator, error) {
+	if name == "" || name == loadgen.StressNgBin {
+		bin := path.Join(util.GetProgramPath(), spec.BinPath, loadgen.StressNgBin)
+		if _, err := os.Stat(bin); err == nil {
+			return loadgen.StressNg{Bin: bin}, nil
+		}
+		name = loadgen.StressNgBin
+	}
+	return loadgen.Get(name)
+}
+
+type StressCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewStressCommandSpec() spec.ExpModelCommandSpec {
+	actions := make([]spec.ExpActionCommandSpec, 0, len(loadgen.Stressors))
+	for _, stressor := range loadgen.Stressors {
+		actions = append(actions, newStressActionCommand(stressor))
+	}
+	return &StressCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: actions,
+			ExpFlags:   []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*StressCommandModelSpec) Name() string {
+	return "stress"
+}
+
+func (*StressCommandModelSpec) ShortDesc() string {
+	return "Load generator experiment"
+}
+
+func (*StressCommandModelSpec) LongDesc() string {
+	return "Load generator experiment, the load of the cpu, vm, io and sock stressors is generated by the " +
+		"generator, stress-ng by default, in the pid namespace and the cgroups of the container"
+}
+
+// stressDescs are the short descriptions and the examples of the stressors
+var stressDescs = map[string][2]string{
+	loadgen.StressorCPU: {"cpu load by the load generator", `# Load 2 cpus of the container at 80% for 5 minutes
+blade create cri stress cpu --workers 2 --percent 80 --duration 5m --container-id ee54f1e61c08`},
+	loadgen.StressorVM: {"memory pressure by the load generator", `# 2 workers allocate and touch 256m each
+blade create cri stress vm --workers 2 --bytes 256m --container-id ee54f1e61c08`},
+	loadgen.StressorIO: {"disk io by the load generator", `# 1 worker writes a file of 1g in /tmp of the container
+blade create cri stress io --workers 1 --bytes 1g --container-id ee54f1e61c08`},
+	loadgen.StressorSocket: {"socket churn by the load generator", `# 4 workers exchange the data over the loopback sockets
+blade create cri stress sock --workers 4 --container-id ee54f1e61c08`},
+}
+
+func newStressActionCommand(stressor string) spec.ExpActionCommandSpec {
+	flags := []spec.ExpFlagSpec{
+		&spec.ExpFlag{
+			Name:    StressGeneratorFlag,
+			Desc:    "the load generator, default is stress-ng",
+			Default: loadgen.StressNgBin,
+		},
+		&spec.ExpFlag{
+			Name: StressWorkersFlag,
+			Desc: "workers generating the load, default or 0 is one per cpu",
+		},
+	}
+	switch stressor {
+	case loadgen.StressorCPU:
+		flags = append(flags, &spec.ExpFlag{
+			Name: StressPercentFlag,
+			Desc: "percent of the cpu loaded by every worker, 1 to 100, default is 100",
+		})
+	case loadgen.StressorVM, loadgen.StressorIO:
+		flags = append(flags, &spec.ExpFlag{
+			Name: StressBytesFlag,
+			Desc: "bytes of every worker, such as 256m, 1g or 80% of the available, default is the one of the generator",
+		})
+	}
+	flags = append(flags,
+		&spec.ExpFlag{
+			Name: StressDurationFlag,
+			Desc: "duration of the load, such as 90s or 1h, the integer is seconds, default or forever is until the experiment is destroyed",
+		},
+		&spec.ExpFlag{
+			Name: "cgroup-root",
+			Desc: "cgroup root path, default value /sys/fs/cgroup",
+		},
+	)
+	return &StressActionCommand{
+		BaseExpActionCommandSpec: spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags:    flags,
+			ActionExecutor: &stressExecutor{stressor: stressor},
+			ActionLongDesc: "The load is generated by the generator of the host in the pid namespace and the cgroups " +
+				"of the container, so the load is charged to the container and limited by its limits. The io " +
+				"workers write the files in /tmp of the container. The destroy kills the generator.",
+			ActionExample:    stressDescs[stressor][1],
+			ActionCategories: []string{CategorySystemContainer},
+		},
+		stressor: stressor,
+	}
+}
+
+type StressActionCommand struct {
+	spec.BaseExpActionCommandSpec
+	stressor string
+}
+
+func (c *StressActionCommand) Name() string {
+	return c.stressor
+}
+
+func (*StressActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (c *StressActionCommand) ShortDesc() string {
+	return stressDescs[c.stressor][0]
+}
+
+func (c *StressActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+// StressResult is the result of the stress experiment
+type StressResult struct {
+	holder.State
+	Generator string       `json:"generator"`
+	Load      loadgen.Load `json:"load"`
+}
+
+type stressExecutor struct {
+	stressor string
+}
+
+func (e *stressExecutor) Name() string {
+	return "stress"
+}
+
+func (e *stressExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *stressExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		if suid == "" {
+			suid = uid
+		}
+		return stopStress(ctx, suid)
+	}
+	flags := model.ActionFlags
+	load := loadgen.Load{Stressor: e.stressor, Bytes: flags[StressBytesFlag]}
+	var response *spec.Response
+	if load.Workers, response = intervalFlag(flags, StressWorkersFlag, 0, 0); response != nil {
+		return response
+	}
+	if load.Percent, response = rangeFlag(flags, StressPercentFlag, 0, 1, 100); response != nil {
+		return response
+	}
+	if load.Timeout, response = lifetimeFlag(flags, StressDurationFlag); response != nil {
+		return response
+	}
+	generator, err := stressGenerator(flags[StressGeneratorFlag])
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, StressGeneratorFlag, flags[StressGeneratorFlag], err)
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	if load.Stressor == loadgen.StressorIO {
+		// the generator is in the mount namespace of the host, the files are written in the rootfs of the container
+		load.TempPath = fmt.Sprintf("/proc/%d/root/tmp", pid)
+	}
+	argv, err := generator.Argv(load)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, StressBytesFlag, load.Bytes, err)
+	}
+	starter, err := startStress(ctx, pid, getCgroupRoot(model), argv)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, generator.Name(), err)
+	}
+	state := holder.State{Uid: uid, Kind: "stress", Starter: starter, Cmdline: strings.Join(argv, " "),
+		Count: load.Workers}
+	steps := rollback.New(ctx, uid)
+	// the state is read when released, so the generator found later is released too
+	steps.Done("start generator", func() error { return holder.Release(state) })
+	for i := 0; i < 50; i++ {
+		if state.Holder, err = holder.Find(starter, state.Cmdline); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, generator.Name(), err))
+	}
+	if err := holder.SaveState(util.GetProgramPath(), state); err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err))
+	}
+	log.Infof(ctx, "the %s load of process %d is generated by %s, %s workers, generator %d", load.Stressor, pid,
+		generator.Name(), stressWorkers(load.Workers), state.Holder)
+	return spec.ReturnSuccess(StressResult{State: state, Generator: generator.Name(), Load: load})
+}
+
+// stopStress kills the generator of the experiment, its workers exit with it, it succeeds if the generator exited by
+// the duration
+func stopStress(ctx context.Context, uid string) *spec.Response {
+	stateDir := util.GetProgramPath()
+	state, err := holder.LoadState(stateDir, uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, stateDir, err)
+	}
+	if err := holder.Release(state); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "kill", err)
+	}
+	if err := holder.RemoveState(stateDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove state", err)
+	}
+	log.Infof(ctx, "the generator %d of experiment %s is stopped", state.Holder, uid)
+	return spec.ReturnSuccess(uid)
+}
+
+// stressWorkers formats the workers of the load, 0 is one per cpu
+func stressWorkers(workers int) string {
+	if workers == 0 {
+		return "one per cpu"
+	}
+	return strconv.Itoa(workers)
+}
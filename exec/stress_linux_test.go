@@ -0,0 +1,129 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/holder"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/loadgen"
+)
+
+// sleepGenerator generates no load, the shell sleeps with the load in the command line
+type sleepGenerator struct {
+	bin string
+}
+
+func (sleepGenerator) Name() string {
+	return "sleep"
+}
+
+func (g sleepGenerator) Argv(load loadgen.Load) ([]string, error) {
+	if err := load.Validate(); err != nil {
+		return nil, err
+	}
+	return []string{g.bin, "-c", "sleep 30; :", load.Stressor, stressWorkers(load.Workers), load.Bytes, load.TempPath}, nil
+}
+
+// fakeStress registers the sleep generator and starts it on the host, the argv started are recorded
+func fakeStress(t *testing.T) *[][]string {
+	t.Helper()
+	bin, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skipf("no sh, %v", err)
+	}
+	loadgen.Register(sleepGenerator{bin: bin})
+	argvs := make([][]string, 0)
+	origin := startStress
+	startStress = func(ctx context.Context, pid int32, cgroupRoot string, argv []string) (int, error) {
+		argvs = append(argvs, argv)
+		cmd := &exec.Cmd{Path: argv[0], Args: argv}
+		if err := cmd.Start(); err != nil {
+			return 0, err
+		}
+		go cmd.Wait()
+		t.Cleanup(func() { cmd.Process.Kill() })
+		return cmd.Process.Pid, nil
+	}
+	t.Cleanup(func() { startStress = origin })
+	return &argvs
+}
+
+func TestStress(t *testing.T) {
+	fakeNetns(t, "")
+	argvs := fakeStress(t)
+	flags := map[string]string{ContainerIdFlag.Name: "c1", StressGeneratorFlag: "sleep", StressWorkersFlag: "2",
+		StressBytesFlag: "1g"}
+	model := &spec.ExpModel{Target: "stress", ActionName: loadgen.StressorIO, ActionFlags: flags}
+	executor := &stressExecutor{stressor: loadgen.StressorIO}
+	response := executor.Exec("uid1", context.Background(), model)
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	defer holder.RemoveState(util.GetProgramPath(), "uid1")
+	result := response.Result.(StressResult)
+	if result.Generator != "sleep" || result.Load.Workers != 2 || result.Load.Bytes != "1g" ||
+		!strings.HasSuffix(result.Load.TempPath, "/root/tmp") || result.Holder != result.Starter {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(*argvs) != 1 || (*argvs)[0][3] != loadgen.StressorIO {
+		t.Fatalf("expected the generator started, got %q", *argvs)
+	}
+	if cmdline, err := holder.Cmdline(result.Holder); err != nil || cmdline != result.Cmdline {
+		t.Fatalf("expected the generator running, got %q, %v", cmdline, err)
+	}
+
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := executor.Exec("uid1", ctx, model); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if cmdline, _ := holder.Cmdline(result.Holder); cmdline == result.Cmdline {
+		t.Error("expected the generator killed")
+	}
+	if _, err := holder.LoadState(util.GetProgramPath(), "uid1"); !os.IsNotExist(err) {
+		t.Errorf("expected the state removed, got %v", err)
+	}
+}
+
+func TestStressIllegal(t *testing.T) {
+	fakeNetns(t, "")
+	fakeStress(t)
+	for _, flags := range []map[string]string{
+		{StressGeneratorFlag: "unknown"},
+		{StressWorkersFlag: "-1"},
+		{StressPercentFlag: "101"},
+		{StressBytesFlag: "1x"},
+		{StressDurationFlag: "x"},
+	} {
+		flags[ContainerIdFlag.Name] = "c1"
+		if flags[StressGeneratorFlag] == "" {
+			flags[StressGeneratorFlag] = "sleep"
+		}
+		model := &spec.ExpModel{Target: "stress", ActionName: loadgen.StressorCPU, ActionFlags: flags}
+		response := (&stressExecutor{stressor: loadgen.StressorCPU}).Exec("uid1", context.Background(), model)
+		if response.Success || response.Code != spec.ParameterIllegal.Code {
+			t.Errorf("expected the illegal flags %v, got %+v", flags, response)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sysctl builds the scripts changing the network sysctls of the pod, they are executed by the shell of the host
+// in the network namespace of the pod, where the proc filesystem shows the sysctls of that namespace only. The original
+// values are saved before the change and written back by the restore.
+package sysctl
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/durations"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/nsexec"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/statefile"
+)
+
+// keyPattern matches the keys of the network sysctls, the other sysctls are not namespaced by the network namespace
+var keyPattern = regexp.MustCompile(`^net\.[A-Za-z0-9_\-]+(\.[A-Za-z0-9_\-]+)+$`)
+
+// Param is a sysctl and its value
+type Param struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// File returns the file of the sysctl under the proc filesystem
+func (p Param) File() string {
+	return path.Join("/proc/sys", strings.ReplaceAll(p.Key, ".", "/"))
+}
+
+// ParseParams parses the sysctls separated by comma, such as net.ipv4.tcp_retries2=3,net.core.somaxconn=16
+func ParseParams(raw string) ([]Param, error) {
+	params := make([]Param, 0)
+	keys := make(map[string]bool)
+	for _, item := range strings.Split(raw, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		key, value = strings.TrimSpace(key), strings.Join(strings.Fields(value), " ")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%q is not key=value", item)
+		}
+		if !keyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%s is not a network sysctl", key)
+		}
+		if keys[key] {
+			return nil, fmt.Errorf("%s is duplicated", key)
+		}
+		keys[key] = true
+		params = append(params, Param{Key: key, Value: value})
+	}
+	if len(params) == 0 {
+		return nil, fmt.Errorf("no sysctl")
+	}
+	return params, nil
+}
+
+// ReadScript prints the values of the sysctls, one line for each
+func ReadScript(params []Param) string {
+	commands := make([]string, 0, len(params))
+	for _, param := range params {
+		commands = append(commands, "cat "+nsexec.Quote(param.File()))
+	}
+	return strings.Join(commands, " && ")
+}
+
+// ParseValues returns the sysctls of the values printed by the ReadScript, the whitespaces of the values, such as
+// the tab of ip_local_port_range, are replaced by a space
+func ParseValues(params []Param, output string) ([]Param, error) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) != len(params) {
+		return nil, fmt.Errorf("unexpected values %q of %d sysctls", output, len(params))
+	}
+	values := make([]Param, 0, len(params))
+	for i, param := range params {
+		values = append(values, Param{Key: param.Key, Value: strings.Join(strings.Fields(lines[i]), " ")})
+	}
+	return values, nil
+}
+
+// WriteScript writes the values of the sysctls, it stops at the first failure
+func WriteScript(params []Param) string {
+	commands := make([]string, 0, len(params))
+	for _, param := range params {
+		commands = append(commands, fmt.Sprintf("echo %s > %s", nsexec.Quote(param.Value), nsexec.Quote(param.File())))
+	}
+	return strings.Join(commands, " && ")
+}
+
+// RestoreScript writes the original values of the sysctls, all of them are written even if some fail
+func RestoreScript(origin []Param) string {
+	commands := make([]string, 0, len(origin)+1)
+	for _, param := range origin {
+		commands = append(commands, fmt.Sprintf("echo %s > %s", nsexec.Quote(param.Value), nsexec.Quote(param.File())))
+	}
+	return strings.Join(append(commands, "true"), "; ")
+}
+
+// Marker returns the marker of the timer of the experiment, it is in the script so the timer is identified by its
+// command line
+func Marker(uid string) string {
+	return fmt.Sprintf(": chaosblade-sysctl %s", uid)
+}
+
+// TimerScript returns the script restoring the sysctls after the seconds, or when it is terminated, the sleep is
+// waited in the background so the signals are trapped without delay
+func TimerScript(uid string, origin []Param, duration time.Duration) string {
+	return strings.Join([]string{
+		Marker(uid),
+		fmt.Sprintf("restore() { %s; }", RestoreScript(origin)),
+		"trap 'restore; exit 0' TERM INT HUP",
+		fmt.Sprintf("sleep %s & wait $!", durations.Sleep(duration)),
+		"restore",
+	}, "\n")
+}
+
+// State is the state of the experiment changing the sysctls, it is kept until the sysctls are restored
+type State struct {
+	Uid    string  `json:"uid"`
+	Params []Param `json:"params"`
+	Origin []Param `json:"origin"`
+	// Timer is the host pid of the process restoring the sysctls after the duration
+	Timer int `json:"timer,omitempty"`
+}
+
+// SaveState saves the state under the state dir
+func SaveState(stateDir string, state State) error {
+	return statefile.Save(stateFile(stateDir, state.Uid), state)
+}
+
+// LoadState returns the state of the experiment, the error is os.ErrNotExist if it is not saved
+func LoadState(stateDir, uid string) (State, error) {
+	var state State
+	if err := statefile.Load(stateFile(stateDir, uid), &state); err != nil {
+		return State{}, err
+	}
+	return state, nil
+}
+
+// RemoveState removes the state of the experiment, it succeeds if the state is not saved
+func RemoveState(stateDir, uid string) error {
+	return statefile.Remove(stateFile(stateDir, uid))
+}
+
+func stateFile(stateDir, uid string) string {
+	return statefile.Path(stateDir, "sysctl", uid)
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sysctl
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseParams(t *testing.T) {
+	params, err := ParseParams("net.ipv4.tcp_retries2=3, net.ipv4.ip_local_port_range=1024  1100,")
+	expected := []Param{{"net.ipv4.tcp_retries2", "3"}, {"net.ipv4.ip_local_port_range", "1024 1100"}}
+	if err != nil || !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %+v, got %+v, %v", expected, params, err)
+	}
+	if file := params[1].File(); file != "/proc/sys/net/ipv4/ip_local_port_range" {
+		t.Errorf("unexpected file %s", file)
+	}
+	for _, illegal := range []string{"", ",", "net.core.somaxconn", "net.core.somaxconn=", "kernel.pid_max=100",
+		"net.core/../../kernel.pid_max=1", "net=1", "net.core.somaxconn=1,net.core.somaxconn=2"} {
+		if _, err := ParseParams(illegal); err == nil {
+			t.Errorf("expected error of %q", illegal)
+		}
+	}
+}
+
+func TestReadValues(t *testing.T) {
+	params := []Param{{Key: "net.core.somaxconn"}, {Key: "net.ipv4.ip_local_port_range"}}
+	if _, err := os.Stat(params[1].File()); err != nil {
+		t.Skipf("no network sysctls, %v", err)
+	}
+	output, err := exec.Command("/bin/sh", "-c", ReadScript(params)).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := ParseValues(params, string(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Value == "" || len(strings.Fields(values[1].Value)) != 2 || strings.Contains(values[1].Value, "\t") {
+		t.Errorf("unexpected values %+v", values)
+	}
+	if _, err := ParseValues(params, "4096\n"); err == nil {
+		t.Error("expected error of the missing value")
+	}
+}
+
+func TestScripts(t *testing.T) {
+	params := []Param{{"net.core.somaxconn", "16"}, {"net.ipv4.ip_local_port_range", "1024 1100"}}
+	if script := WriteScript(params); script != "echo 16 > /proc/sys/net/core/somaxconn && "+
+		"echo '1024 1100' > /proc/sys/net/ipv4/ip_local_port_range" {
+		t.Errorf("unexpected write script %s", script)
+	}
+	if script := RestoreScript(params); script != "echo 16 > /proc/sys/net/core/somaxconn; "+
+		"echo '1024 1100' > /proc/sys/net/ipv4/ip_local_port_range; true" {
+		t.Errorf("unexpected restore script %s", script)
+	}
+	script := TimerScript("uid1", params, time.Minute)
+	if !strings.HasPrefix(script, Marker("uid1")) {
+		t.Errorf("expected the marker in the timer script, got %s", script)
+	}
+	if output, err := exec.Command("/bin/sh", "-n", "-c", script).CombinedOutput(); err != nil {
+		t.Errorf("illegal timer script, %v: %s", err, output)
+	}
+}
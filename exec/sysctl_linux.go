@@ -0,0 +1,209 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rollback"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/sysctl"
+)
+
+// The flags of the sysctl experiment
+const (
+	SysctlParamsFlag   = "params"
+	SysctlDurationFlag = "duration"
+)
+
+type SysctlCommandModelSpec struct {
+	spec.BaseExpModelCommandSpec
+}
+
+func NewSysctlCommandSpec() spec.ExpModelCommandSpec {
+	return &SysctlCommandModelSpec{
+		spec.BaseExpModelCommandSpec{
+			ExpActions: []spec.ExpActionCommandSpec{
+				&SysctlSetActionCommand{
+					spec.BaseExpActionCommandSpec{
+						ActionMatchers: []spec.ExpFlagSpec{},
+						ActionFlags: []spec.ExpFlagSpec{
+							&spec.ExpFlag{
+								Name: SysctlParamsFlag,
+								Desc: "network sysctls and their values separated by comma, " +
+									"such as net.ipv4.tcp_retries2=3,net.core.somaxconn=16",
+								Required: true,
+							},
+							&spec.ExpFlag{
+								Name: SysctlDurationFlag,
+								Desc: "duration the sysctls are restored after, such as 90s or 1h, the integer is seconds, default or forever is until the experiment is destroyed",
+							},
+						},
+						ActionExecutor: &sysctlSetExecutor{},
+						ActionLongDesc: "The network sysctls of the container are changed in its network namespace, the " +
+							"sysctls of the node and the other pods are not impacted. The original values are saved " +
+							"before the change and written back after the duration or by the destroy. The sysctls not " +
+							"namespaced by the network namespace are refused.",
+						ActionExample: `# Give up the unacknowledged tcp connections earlier for 5 minutes
+blade create cri sysctl set --params net.ipv4.tcp_retries2=3 --duration 300 --container-id ee54f1e61c08
+
+# Shrink the accept queue and the local port range
+blade create cri sysctl set --params "net.core.somaxconn=16,net.ipv4.ip_local_port_range=40000 40100" --container-id ee54f1e61c08`,
+						ActionCategories: []string{CategorySystemContainer},
+					},
+				},
+			},
+			ExpFlags: []spec.ExpFlagSpec{},
+		},
+	}
+}
+
+func (*SysctlCommandModelSpec) Name() string {
+	return "sysctl"
+}
+
+func (*SysctlCommandModelSpec) ShortDesc() string {
+	return "Sysctl experiment"
+}
+
+func (*SysctlCommandModelSpec) LongDesc() string {
+	return "Sysctl experiment, the namespaced kernel parameters of the container are changed and restored."
+}
+
+type SysctlSetActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func (*SysctlSetActionCommand) Name() string {
+	return "set"
+}
+
+func (*SysctlSetActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*SysctlSetActionCommand) ShortDesc() string {
+	return "network sysctl set"
+}
+
+func (c *SysctlSetActionCommand) LongDesc() string {
+	return c.ActionLongDesc
+}
+
+type sysctlSetExecutor struct {
+}
+
+func (e *sysctlSetExecutor) Name() string {
+	return "sysctl"
+}
+
+func (e *sysctlSetExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *sysctlSetExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return restoreSysctl(ctx, uid, model)
+	}
+	flags := model.ActionFlags
+	if flags[SysctlParamsFlag] == "" {
+		return spec.ResponseFailWithFlags(spec.ParameterLess, SysctlParamsFlag)
+	}
+	params, err := sysctl.ParseParams(flags[SysctlParamsFlag])
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, SysctlParamsFlag, flags[SysctlParamsFlag], err)
+	}
+	duration, response := lifetimeFlag(flags, SysctlDurationFlag)
+	if response != nil {
+		return response
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if !response.Success {
+		return response
+	}
+	output, err := runInNetns(ctx, pid, sysctl.ReadScript(params))
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, SysctlParamsFlag, flags[SysctlParamsFlag], err)
+	}
+	origin, err := sysctl.ParseValues(params, output)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "cat", err)
+	}
+	// the original values are saved before the change, so the destroy restores them even if the change is interrupted
+	stateDir := util.GetProgramPath()
+	state := sysctl.State{Uid: uid, Params: params, Origin: origin}
+	steps := rollback.New(ctx, uid)
+	if err := steps.Run("save state", func() error { return sysctl.SaveState(stateDir, state) },
+		func() error { return sysctl.RemoveState(stateDir, uid) }); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "save state", err)
+	}
+	// the sysctls written before the failed one are restored too
+	steps.Done("write sysctls", func() error {
+		_, err := runInNetns(ctx, pid, sysctl.RestoreScript(origin))
+		return err
+	})
+	if _, err := runInNetns(ctx, pid, sysctl.WriteScript(params)); err != nil {
+		return steps.Fail(spec.ResponseFailWithFlags(spec.ParameterInvalid, SysctlParamsFlag, flags[SysctlParamsFlag], err))
+	}
+	if duration > 0 {
+		state.Timer, err = startInNetns(ctx, pid, sysctl.TimerScript(uid, origin, duration))
+		if err != nil {
+			log.Warnf(ctx, "start the timer of experiment %s failed, the sysctls are restored by the destroy, %v",
+				uid, err)
+		} else if err := sysctl.SaveState(stateDir, state); err != nil {
+			log.Warnf(ctx, "update the state of experiment %s failed, %v", uid, err)
+		}
+	}
+	log.Infof(ctx, "the sysctls %+v of process %d are changed from %+v", params, pid, origin)
+	return spec.ReturnSuccess(state)
+}
+
+// restoreSysctl terminates the timer and writes back the original values, it succeeds if the state is not saved or
+// the container is removed with its network namespace
+func restoreSysctl(ctx context.Context, uid string, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok && suid != "" {
+		uid = suid
+	}
+	stateDir := util.GetProgramPath()
+	state, err := sysctl.LoadState(stateDir, uid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec.ReturnSuccess(uid)
+		}
+		return spec.ResponseFailWithFlags(spec.FileCantReadOrOpen, stateDir, err)
+	}
+	if state.Timer > 0 {
+		if err := terminateProcess(state.Timer, sysctl.Marker(uid)); err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "kill", err)
+		}
+	}
+	pid, response := interfaceTargetPid(ctx, uid, model)
+	if response.Success {
+		if _, err := runInNetns(ctx, pid, sysctl.RestoreScript(state.Origin)); err != nil {
+			return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.NSExecBin, err)
+		}
+	} else {
+		log.Warnf(ctx, "the sysctls of the experiment %s are not restored, %s", uid, response.Err)
+	}
+	if err := sysctl.RemoveState(stateDir, uid); err != nil {
+		return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, "remove state", err)
+	}
+	return spec.ReturnSuccess(uid)
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/sysctl"
+)
+
+func TestSysctlSet(t *testing.T) {
+	scripts := fakeNetns(t, "15\n32768\t60999\n")
+	flags := map[string]string{ContainerIdFlag.Name: "c1", SysctlDurationFlag: "300",
+		SysctlParamsFlag: "net.ipv4.tcp_retries2=3,net.ipv4.ip_local_port_range=40000 40100"}
+	model := &spec.ExpModel{Target: "sysctl", ActionName: "set", ActionFlags: flags}
+	response := (&sysctlSetExecutor{}).Exec("uid1", context.Background(), model)
+	if !response.Success {
+		t.Fatalf("create failed, %+v", response)
+	}
+	defer sysctl.RemoveState(util.GetProgramPath(), "uid1")
+	origin := []sysctl.Param{{Key: "net.ipv4.tcp_retries2", Value: "15"},
+		{Key: "net.ipv4.ip_local_port_range", Value: "32768 60999"}}
+	restore := sysctl.RestoreScript(origin)
+	if len(*scripts) != 3 || !strings.HasPrefix((*scripts)[0], "cat ") ||
+		(*scripts)[1] != "echo 3 > /proc/sys/net/ipv4/tcp_retries2 && "+
+			"echo '40000 40100' > /proc/sys/net/ipv4/ip_local_port_range" ||
+		!strings.Contains((*scripts)[2], restore) || !strings.Contains((*scripts)[2], "sleep 300") {
+		t.Fatalf("expected the read, the write and the timer scripts, got %q", *scripts)
+	}
+
+	ctx := spec.SetDestroyFlag(context.Background(), "uid1")
+	if response := (&sysctlSetExecutor{}).Exec("uid1", ctx, model); !response.Success {
+		t.Fatalf("destroy failed, %+v", response)
+	}
+	if len(*scripts) != 4 || (*scripts)[3] != restore {
+		t.Errorf("expected the restore script, got %q", *scripts)
+	}
+	if _, err := sysctl.LoadState(util.GetProgramPath(), "uid1"); !os.IsNotExist(err) {
+		t.Errorf("expected the state removed, got %v", err)
+	}
+}
+
+func TestSysctlSetIllegal(t *testing.T) {
+	scripts := fakeNetns(t, "")
+	tests := []struct {
+		flags map[string]string
+		code  int32
+	}{
+		{map[string]string{}, spec.ParameterLess.Code},
+		{map[string]string{SysctlParamsFlag: "kernel.pid_max=100"}, spec.ParameterIllegal.Code},
+		{map[string]string{SysctlParamsFlag: "net.core.somaxconn"}, spec.ParameterIllegal.Code},
+		{map[string]string{SysctlParamsFlag: "net.core.somaxconn=16", SysctlDurationFlag: "x"},
+			spec.ParameterIllegal.Code},
+	}
+	for _, test := range tests {
+		test.flags[ContainerIdFlag.Name] = "c1"
+		model := &spec.ExpModel{Target: "sysctl", ActionName: "set", ActionFlags: test.flags}
+		response := (&sysctlSetExecutor{}).Exec("uid1", context.Background(), model)
+		if response.Success || response.Code != test.code {
+			t.Errorf("expected the code %d of %v, got %+v", test.code, test.flags, response)
+		}
+	}
+	if len(*scripts) != 0 {
+		t.Errorf("expected nothing run, got %q", *scripts)
+	}
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tamper replaces the content of a file in place and restores it, the inode is kept so the file bind mounted
+// into the container sees the changes. The original content is saved before the change, and the file is restored only
+// if it still has the tampered content, so the content rewritten by others meanwhile is never reverted.
+package tamper
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rootfs"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/statefile"
+)
+
+// The modes of the tampering
+const (
+	// ModeTruncate truncates the file to empty
+	ModeTruncate = "truncate"
+	// ModeGarbage replaces the content with the random bytes of the same size
+	ModeGarbage = "garbage"
+	// ModeReplace replaces the content with the given content
+	ModeReplace = "replace"
+)
+
+// Tampering is the tampered file saved for the restore
+type Tampering struct {
+	Uid string `json:"uid"`
+	// File is the path on the host of the file tampered
+	File string `json:"file"`
+	// Root is the rootfs of the container, or the source of its volume, the file is opened in it by the Name resolved
+	Root string      `json:"root,omitempty"`
+	Name string      `json:"name,omitempty"`
+	Mode string      `json:"mode"`
+	Perm os.FileMode `json:"perm"`
+	// Digest is the sha256 of the tampered content
+	Digest string `json:"digest"`
+}
+
+// Tamper changes the content of the file in the root by the mode, the symbolic links are resolved in the root so the
+// file of the atomic writer of the kubelet is changed, but never a file outside the root. The file resolved is opened
+// with O_NOFOLLOW and changed by the file opened, a file is tampered by one experiment at a time
+func Tamper(stateDir, uid, root, file, mode string, content []byte) (Tampering, error) {
+	resolved, err := rootfs.Resolve(root, file)
+	if err != nil {
+		return Tampering{}, err
+	}
+	name, err := rootfs.Rel(root, resolved)
+	if err != nil {
+		return Tampering{}, err
+	}
+	f, err := rootfs.Open(root, name, os.O_RDWR|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return Tampering{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Tampering{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return Tampering{}, fmt.Errorf("%s is not a regular file", resolved)
+	}
+	if existing, err := findTampering(stateDir, resolved); err != nil {
+		return Tampering{}, err
+	} else if existing != nil {
+		return Tampering{}, fmt.Errorf("%s is already tampered by the experiment %s", resolved, existing.Uid)
+	}
+	origin, err := io.ReadAll(f)
+	if err != nil {
+		return Tampering{}, err
+	}
+	switch mode {
+	case ModeTruncate:
+		content = []byte{}
+	case ModeGarbage:
+		content = make([]byte, len(origin))
+		if _, err := rand.Read(content); err != nil {
+			return Tampering{}, err
+		}
+	case ModeReplace:
+	default:
+		return Tampering{}, fmt.Errorf("unsupported mode %s", mode)
+	}
+	tampering := Tampering{Uid: uid, File: resolved, Root: root, Name: name, Mode: mode, Perm: info.Mode().Perm(),
+		Digest: digest(content)}
+	// the backup is saved atomically before the change, so the restore works even if the agent exits, or the node
+	// crashes, meanwhile
+	if err := statefile.WriteFile(backupFile(stateDir, uid), origin); err != nil {
+		return Tampering{}, err
+	}
+	if err := saveTampering(stateDir, tampering); err != nil {
+		os.Remove(backupFile(stateDir, uid))
+		return Tampering{}, err
+	}
+	if err := writeInPlace(f, content); err != nil {
+		writeInPlace(f, origin)
+		remove(stateDir, uid)
+		return Tampering{}, err
+	}
+	return tampering, nil
+}
+
+// Restore writes back the original content of the experiment, it returns false without error if the experiment
+// tampered nothing, or the file is removed or rewritten after the tampering, e.g. by the sync of the kubelet
+func Restore(stateDir, uid string) (bool, error) {
+	var tampering Tampering
+	if err := statefile.Load(stateFile(stateDir, uid), &tampering); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	// the tampering saved by the earlier versions has the resolved file only
+	root, name := tampering.Root, tampering.Name
+	if root == "" {
+		root, name = "/", tampering.File
+	}
+	f, err := rootfs.Open(root, name, os.O_RDWR|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return false, remove(stateDir, uid)
+	}
+	defer f.Close()
+	current, err := io.ReadAll(f)
+	if err != nil || digest(current) != tampering.Digest {
+		return false, remove(stateDir, uid)
+	}
+	origin, err := os.ReadFile(backupFile(stateDir, uid))
+	if err != nil {
+		return false, err
+	}
+	if err := writeInPlace(f, origin); err != nil {
+		return false, err
+	}
+	if err := f.Chmod(tampering.Perm); err != nil {
+		return false, err
+	}
+	return true, remove(stateDir, uid)
+}
+
+// writeInPlace truncates and writes the file opened, it is not renamed so the inode is kept
+func writeInPlace(f *os.File, content []byte) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt(content, 0)
+	return err
+}
+
+func digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func stateFile(stateDir, uid string) string {
+	return statefile.Path(stateDir, "tamper", uid)
+}
+
+func backupFile(stateDir, uid string) string {
+	return path.Join(stateDir, fmt.Sprintf("tamper-%s.bak", uid))
+}
+
+func saveTampering(stateDir string, tampering Tampering) error {
+	return statefile.Save(stateFile(stateDir, tampering.Uid), tampering)
+}
+
+func remove(stateDir, uid string) error {
+	for _, file := range []string{stateFile(stateDir, uid), backupFile(stateDir, uid)} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func findTampering(stateDir, file string) (*Tampering, error) {
+	files, err := filepath.Glob(path.Join(stateDir, "tamper-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, stateFile := range files {
+		var tampering Tampering
+		if statefile.Load(stateFile, &tampering) == nil && tampering.File == file {
+			return &tampering, nil
+		}
+	}
+	return nil, nil
+}
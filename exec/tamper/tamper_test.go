@@ -0,0 +1,167 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tamper
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+// setupAtomicWriter lays out the files like the atomic writer of the kubelet, tls.crt -> ..data/tls.crt
+func setupAtomicWriter(t *testing.T, content string) (string, string) {
+	t.Helper()
+	volume := t.TempDir()
+	data := path.Join(volume, "..2024_01_01_00_00_00.1")
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(data, "tls.crt"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(path.Base(data), path.Join(volume, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..data/tls.crt", path.Join(volume, "tls.crt")); err != nil {
+		t.Fatal(err)
+	}
+	return path.Join(volume, "tls.crt"), path.Join(data, "tls.crt")
+}
+
+func inode(t *testing.T, file string) uint64 {
+	t.Helper()
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Sys().(*syscall.Stat_t).Ino
+}
+
+func TestTamperAndRestore(t *testing.T) {
+	stateDir := t.TempDir()
+	link, file := setupAtomicWriter(t, "-----BEGIN CERTIFICATE-----")
+	ino := inode(t, file)
+	tests := []struct {
+		mode    string
+		content string
+		check   func([]byte) bool
+	}{
+		{ModeTruncate, "", func(b []byte) bool { return len(b) == 0 }},
+		{ModeReplace, "expired", func(b []byte) bool { return string(b) == "expired" }},
+		{ModeGarbage, "", func(b []byte) bool { return len(b) == 27 && string(b) != "-----BEGIN CERTIFICATE-----" }},
+	}
+	for _, test := range tests {
+		tampering, err := Tamper(stateDir, "uid1", path.Dir(link), path.Base(link), test.mode, []byte(test.content))
+		if err != nil || tampering.File != file {
+			t.Fatalf("tamper %s failed, %+v, %v", test.mode, tampering, err)
+		}
+		if content, _ := os.ReadFile(link); !test.check(content) {
+			t.Errorf("unexpected content %q of %s", content, test.mode)
+		}
+		if _, err := Tamper(stateDir, "uid2", path.Dir(link), path.Base(link), ModeTruncate, nil); err == nil {
+			t.Error("expected error of the file tampered twice")
+		}
+		if restored, err := Restore(stateDir, "uid1"); err != nil || !restored {
+			t.Fatalf("restore %s failed, %t, %v", test.mode, restored, err)
+		}
+		if content, _ := os.ReadFile(link); string(content) != "-----BEGIN CERTIFICATE-----" {
+			t.Errorf("unexpected restored content %q of %s", content, test.mode)
+		}
+		if inode(t, file) != ino {
+			t.Errorf("expected the inode kept by %s", test.mode)
+		}
+	}
+	if restored, err := Restore(stateDir, "uid1"); err != nil || restored {
+		t.Errorf("expected nothing restored, got %t, %v", restored, err)
+	}
+	if _, err := Tamper(stateDir, "uid1", path.Dir(link), path.Base(link), "rot13", nil); err == nil {
+		t.Error("expected error of the unsupported mode")
+	}
+}
+
+func TestRestoreResynced(t *testing.T) {
+	stateDir := t.TempDir()
+	link, file := setupAtomicWriter(t, "v1")
+	if _, err := Tamper(stateDir, "uid1", path.Dir(link), path.Base(link), ModeTruncate, nil); err != nil {
+		t.Fatal(err)
+	}
+	// the kubelet writes the updated secret
+	if err := os.WriteFile(file, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if restored, err := Restore(stateDir, "uid1"); err != nil || restored {
+		t.Errorf("expected the resynced file skipped, got %t, %v", restored, err)
+	}
+	if content, _ := os.ReadFile(link); !bytes.Equal(content, []byte("v2")) {
+		t.Errorf("expected the resynced content kept, got %q", content)
+	}
+	if files, _ := os.ReadDir(stateDir); len(files) != 0 {
+		t.Errorf("expected the state removed, got %v", files)
+	}
+}
+
+func TestTamperBackup(t *testing.T) {
+	stateDir := t.TempDir()
+	link, file := setupAtomicWriter(t, "-----BEGIN CERTIFICATE-----")
+	if _, err := Tamper(stateDir, "uid1", path.Dir(link), path.Base(link), ModeTruncate, nil); err != nil {
+		t.Fatal(err)
+	}
+	// the backup is complete before the file is changed, and no temporary file is left
+	if backup, err := os.ReadFile(backupFile(stateDir, "uid1")); err != nil || string(backup) != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("unexpected backup %q, %v", backup, err)
+	}
+	if entries, err := os.ReadDir(stateDir); err != nil || len(entries) != 2 {
+		t.Errorf("expected the state and the backup only, got %v, %v", entries, err)
+	}
+	if _, err := Restore(stateDir, "uid1"); err != nil {
+		t.Fatal(err)
+	}
+	// the backup failed leaves the file unchanged
+	if _, err := Tamper(path.Join(stateDir, "missing"), "uid2", path.Dir(link), path.Base(link), ModeTruncate, nil); err == nil {
+		t.Fatal("expected the tampering without backup refused")
+	}
+	if content, _ := os.ReadFile(file); string(content) != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("expected the file unchanged, got %q", content)
+	}
+}
+
+func TestTamperOutsideRoot(t *testing.T) {
+	stateDir, dir := t.TempDir(), t.TempDir()
+	root, shadow := path.Join(dir, "rootfs"), path.Join(dir, "shadow")
+	if err := os.MkdirAll(path.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(shadow, []byte("root:x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{"etc/hosts": shadow, "etc/relative": "../../shadow"} {
+		if err := os.Symlink(target, path.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Tamper(stateDir, "uid1", root, "/"+link, ModeTruncate, nil); err == nil {
+			t.Errorf("expected the link %s escaping the root refused", link)
+		}
+	}
+	if _, err := Tamper(stateDir, "uid1", root, "/etc/../../shadow", ModeTruncate, nil); err == nil {
+		t.Error("expected the .. refused")
+	}
+	if content, _ := os.ReadFile(shadow); string(content) != "root:x" {
+		t.Errorf("expected the file outside the root unchanged, got %q", content)
+	}
+}
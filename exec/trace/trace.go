@@ -0,0 +1,126 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package trace stamps the experiment into the artifacts injected on the node, the tc filters, the firewall rules,
+// the helper processes and the copied files, so any residue found later is traced back to the experiment and the
+// time it was injected. The stamp is carried by the context passed to the executor:
+//
+//	ctx = trace.WithStamp(ctx, uid)
+//	response := executor.Exec(uid, ctx, model)
+package trace
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// The environment variables of the helper processes
+const (
+	UidEnv  = "CHAOSBLADE_EXP_UID"
+	TimeEnv = "CHAOSBLADE_EXP_TIME"
+)
+
+// commentPrefix prefixes the comments of the firewall rules
+const commentPrefix = "chaosblade:"
+
+// Stamp is the experiment and the time it was injected
+type Stamp struct {
+	Uid  string
+	Time time.Time
+}
+
+type stampKey struct{}
+
+// WithStamp returns the context carrying the stamp of the experiment at now, the stamp of the context is kept if
+// it is of the same experiment, so the containers of a batch share the time
+func WithStamp(ctx context.Context, uid string) context.Context {
+	if stamp, ok := FromContext(ctx); ok && stamp.Uid == uid {
+		return ctx
+	}
+	return context.WithValue(ctx, stampKey{}, Stamp{Uid: uid, Time: time.Now()})
+}
+
+// FromContext returns the stamp of the context, false if not set
+func FromContext(ctx context.Context) (Stamp, bool) {
+	stamp, ok := ctx.Value(stampKey{}).(Stamp)
+	return stamp, ok && stamp.Uid != ""
+}
+
+// Env returns the environment variables of the stamp
+func (s Stamp) Env() []string {
+	return []string{UidEnv + "=" + s.Uid, TimeEnv + "=" + s.Time.UTC().Format(time.RFC3339)}
+}
+
+// Suffix returns the suffix of the names of the files copied, it is the uid and the unix time of the stamp
+func (s Stamp) Suffix() string {
+	return fmt.Sprintf("%s-%d", nonNameChars.ReplaceAllString(s.Uid, "_"), s.Time.Unix())
+}
+
+var nonNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// Environ returns the environment of the helper processes started with the context, the environment of the current
+// process with the stamp appended, nil to inherit it if the context has no stamp
+func Environ(ctx context.Context) []string {
+	stamp, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return append(os.Environ(), stamp.Env()...)
+}
+
+// Comment returns the comment of the firewall rules of the experiment, it depends on the uid only, so the rules are
+// deleted by the same comment when the experiment is destroyed. It is safe in the shell scripts without quoting
+func Comment(uid string) string {
+	return commentPrefix + nonNameChars.ReplaceAllString(uid, "_")
+}
+
+// Handle returns the tc filter handle of the experiment, it is derived from the uid so the filter shown by
+// `tc filter show` is traced back by computing the handle of the uid, it is never zero
+func Handle(uid string) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(uid))
+	if handle := hash.Sum32() & 0x7fffffff; handle != 0 {
+		return handle
+	}
+	return 1
+}
+
+// Executor stamps the context of the experiments
+type Executor struct {
+	spec.Executor
+}
+
+// Wrap returns the executor stamping the context
+func Wrap(executor spec.Executor) spec.Executor {
+	if _, ok := executor.(*Executor); ok {
+		return executor
+	}
+	return &Executor{Executor: executor}
+}
+
+func (e *Executor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	stampUid := uid
+	if suid, isDestroy := spec.IsDestroy(ctx); isDestroy && suid != "" {
+		stampUid = suid
+	}
+	return e.Executor.Exec(uid, WithStamp(ctx, stampUid), model)
+}
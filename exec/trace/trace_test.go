@@ -0,0 +1,70 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+type stampExecutor struct {
+	spec.Executor
+	stamps []Stamp
+}
+
+func (e *stampExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	stamp, _ := FromContext(ctx)
+	e.stamps = append(e.stamps, stamp)
+	return spec.ReturnSuccess(uid)
+}
+
+func TestWrap(t *testing.T) {
+	recorder := &stampExecutor{}
+	executor := Wrap(Wrap(recorder))
+	executor.Exec("uid1", context.Background(), &spec.ExpModel{})
+	executor.Exec("uid2", spec.SetDestroyFlag(context.Background(), "uid1"), &spec.ExpModel{})
+	if len(recorder.stamps) != 2 || recorder.stamps[0].Uid != "uid1" || recorder.stamps[1].Uid != "uid1" {
+		t.Fatalf("expected the uid of the experiment stamped, got %+v", recorder.stamps)
+	}
+	ctx := WithStamp(context.Background(), "uid1")
+	if first, _ := FromContext(ctx); WithStamp(ctx, "uid1") != ctx || first.Time.IsZero() {
+		t.Errorf("expected the stamp of the same experiment kept, got %+v", first)
+	}
+	if Environ(context.Background()) != nil {
+		t.Error("expected the environment inherited without the stamp")
+	}
+	environ := strings.Join(Environ(ctx), "\n")
+	if !strings.Contains(environ, UidEnv+"=uid1\n"+TimeEnv+"=") {
+		t.Errorf("expected the stamp in the environment, got %s", environ)
+	}
+}
+
+func TestStamp(t *testing.T) {
+	if comment := Comment("a b;'c"); comment != "chaosblade:a_b__c" {
+		t.Errorf("unexpected comment %s", comment)
+	}
+	if Handle("uid1") != Handle("uid1") || Handle("uid1") == Handle("uid2") || Handle("uid1") > 0x7fffffff {
+		t.Errorf("unexpected handles %x and %x", Handle("uid1"), Handle("uid2"))
+	}
+	stamp, _ := FromContext(WithStamp(context.Background(), "a/b"))
+	if suffix := stamp.Suffix(); !strings.HasPrefix(suffix, "a_b-") {
+		t.Errorf("unexpected suffix %s", suffix)
+	}
+}
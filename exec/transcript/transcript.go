@@ -0,0 +1,207 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package transcript records the commands executed in the targets of the experiment, the commands in the containers
+// and the scripts in their network namespaces, with the times, the exit codes and the outputs, so the authors of the
+// experiments see what ran instead of guessing. The transcript is enabled by the context passed to the executor and
+// appended to the file of the experiment by the create and the destroy:
+//
+//	ctx = transcript.WithTranscript(ctx, stateDir, uid, transcript.PhaseCreate)
+//	transcript.Record(ctx, containerId, command, start, output, err)
+package transcript
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+)
+
+// The phases of the experiment the commands are executed in
+const (
+	PhaseCreate  = "create"
+	PhaseDestroy = "destroy"
+)
+
+// OutputLimit is the bytes of the output kept in the entry, the rest is dropped
+const OutputLimit = 4096
+
+// Entry is a command executed in the target
+type Entry struct {
+	Time  time.Time `json:"time"`
+	Phase string    `json:"phase"`
+	// Target is the container id, netns:<pid> for the scripts in the network namespace of the process, or pid:<pid>
+	// for the helpers started in the namespaces of the process
+	Target  string `json:"target"`
+	Command string `json:"command"`
+	// ExitCode is the exit code of the command, -1 if it failed without one, such as denied by the exec policy
+	ExitCode  int    `json:"exitCode"`
+	Duration  string `json:"duration"`
+	Output    string `json:"output,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Transcript is the file the entries of the experiment are appended to
+type Transcript struct {
+	mu    sync.Mutex
+	file  string
+	phase string
+}
+
+type transcriptKey struct{}
+
+// WithTranscript returns the context recording the commands to the transcript of the experiment under the state dir
+func WithTranscript(ctx context.Context, stateDir, uid, phase string) context.Context {
+	return context.WithValue(ctx, transcriptKey{}, &Transcript{file: transcriptFile(stateDir, uid), phase: phase})
+}
+
+// FromContext returns the transcript of the context, false if the commands are not recorded
+func FromContext(ctx context.Context) (*Transcript, bool) {
+	t, ok := ctx.Value(transcriptKey{}).(*Transcript)
+	return t, ok && t != nil
+}
+
+// Record appends the command to the transcript of the context, it does nothing if the context has no transcript.
+// The transcript is best effort, the failure to write it is logged only
+func Record(ctx context.Context, target, command string, start time.Time, output string, err error) {
+	t, ok := FromContext(ctx)
+	if !ok {
+		return
+	}
+	entry := Entry{Time: start, Phase: t.phase, Target: target, Command: command, ExitCode: ExitCode(err),
+		Duration: time.Since(start).String(), Output: output}
+	if len(entry.Output) > OutputLimit {
+		entry.Output, entry.Truncated = entry.Output[:OutputLimit], true
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := t.append(entry); err != nil {
+		log.Warnf(ctx, "record the command to the transcript %s failed, %v", t.file, err)
+	}
+}
+
+// NetnsTarget returns the target of the scripts run in the network namespace of the process
+func NetnsTarget(pid int32) string {
+	return "netns:" + strconv.Itoa(int(pid))
+}
+
+// PidTarget returns the target of the helpers started in the namespaces of the process, such as the burners
+func PidTarget(pid int32) string {
+	return "pid:" + strconv.Itoa(int(pid))
+}
+
+// ExitCode returns the exit code of the error of the command, 0 if it succeeded and -1 if it has no exit code
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exit interface{ ExitCode() int }
+	if errors.As(err, &exit) {
+		return exit.ExitCode()
+	}
+	return -1
+}
+
+func (t *Transcript) append(entry Entry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, err := os.OpenFile(t.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(content, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load returns the entries of the experiment in the order executed, the error is os.ErrNotExist if none is recorded
+func Load(stateDir, uid string) ([]Entry, error) {
+	f, err := os.Open(transcriptFile(stateDir, uid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// the line torn by a crash is skipped
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Remove removes the transcript of the experiment, it succeeds if none is recorded
+func Remove(stateDir, uid string) error {
+	if err := os.Remove(transcriptFile(stateDir, uid)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func transcriptFile(stateDir, uid string) string {
+	return path.Join(stateDir, fmt.Sprintf("transcript-%s.jsonl", uid))
+}
+
+// Executor records the commands of the experiment to its transcript if the flag is set
+type Executor struct {
+	spec.Executor
+	flag string
+}
+
+// Wrap returns the executor recording the transcript if the flag of the model is true
+func Wrap(executor spec.Executor, flag string) spec.Executor {
+	if _, ok := executor.(*Executor); ok {
+		return executor
+	}
+	return &Executor{Executor: executor, flag: flag}
+}
+
+func (e *Executor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if enabled, _ := strconv.ParseBool(model.ActionFlags[e.flag]); !enabled {
+		return e.Executor.Exec(uid, ctx, model)
+	}
+	transcriptUid, phase := uid, PhaseCreate
+	if suid, isDestroy := spec.IsDestroy(ctx); isDestroy {
+		phase = PhaseDestroy
+		if suid != "" {
+			transcriptUid = suid
+		}
+	}
+	return e.Executor.Exec(uid, WithTranscript(ctx, util.GetProgramPath(), transcriptUid, phase), model)
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transcript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+)
+
+func TestRecord(t *testing.T) {
+	stateDir := t.TempDir()
+	Record(context.Background(), "c1", "true", time.Now(), "", nil)
+	if _, err := Load(stateDir, "uid1"); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing recorded without the transcript, got %v", err)
+	}
+	ctx := WithTranscript(context.Background(), stateDir, "uid1", PhaseCreate)
+	Record(ctx, "c1", "echo ok", time.Now(), "ok\n", nil)
+	Record(ctx, NetnsTarget(1234), "tc qdisc show", time.Now(), strings.Repeat("x", OutputLimit+1),
+		fmt.Errorf("tc failed, %w", exec.Command("false").Run()))
+	Record(WithTranscript(context.Background(), stateDir, "uid1", PhaseDestroy), "c1", "rm -f /tmp/x", time.Now(),
+		"", errors.New("denied"))
+	entries, err := Load(stateDir, "uid1")
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v, %v", entries, err)
+	}
+	if e := entries[0]; e.Phase != PhaseCreate || e.Target != "c1" || e.Command != "echo ok" || e.ExitCode != 0 ||
+		e.Output != "ok\n" || e.Error != "" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e := entries[1]; e.Target != "netns:1234" || e.ExitCode != 1 || !e.Truncated || len(e.Output) != OutputLimit {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e := entries[2]; e.Phase != PhaseDestroy || e.ExitCode != -1 || e.Error != "denied" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if err := Remove(stateDir, "uid1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(stateDir, "uid1"); err != nil {
+		t.Errorf("expected the removed transcript removed again, %v", err)
+	}
+}
+
+func TestLoadTorn(t *testing.T) {
+	stateDir := t.TempDir()
+	content := `{"command":"a","exitCode":0}` + "\n" + `{"command":"b","exi`
+	if err := os.WriteFile(transcriptFile(stateDir, "uid1"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := Load(stateDir, "uid1")
+	if err != nil || len(entries) != 1 || entries[0].Command != "a" {
+		t.Errorf("expected the torn line skipped, got %+v, %v", entries, err)
+	}
+}
+
+type phaseExecutor struct {
+	transcript *Transcript
+}
+
+func (e *phaseExecutor) Name() string {
+	return "phase"
+}
+
+func (e *phaseExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *phaseExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	e.transcript, _ = FromContext(ctx)
+	return spec.ReturnSuccess(uid)
+}
+
+func TestWrap(t *testing.T) {
+	inner := &phaseExecutor{}
+	executor := Wrap(Wrap(inner, "transcript"), "transcript")
+	executor.Exec("uid1", context.Background(), &spec.ExpModel{ActionFlags: map[string]string{}})
+	if inner.transcript != nil {
+		t.Fatal("expected no transcript without the flag")
+	}
+	model := &spec.ExpModel{ActionFlags: map[string]string{"transcript": "true"}}
+	executor.Exec("uid1", context.Background(), model)
+	if inner.transcript == nil || inner.transcript.phase != PhaseCreate ||
+		inner.transcript.file != transcriptFile(util.GetProgramPath(), "uid1") {
+		t.Fatalf("unexpected transcript %+v", inner.transcript)
+	}
+	executor.Exec("uid2", spec.SetDestroyFlag(context.Background(), "uid1"), model)
+	if inner.transcript.phase != PhaseDestroy || inner.transcript.file != transcriptFile(util.GetProgramPath(), "uid1") {
+		t.Errorf("expected the destroy recorded to the transcript of the experiment, got %+v", inner.transcript)
+	}
+}
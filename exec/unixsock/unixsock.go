@@ -0,0 +1,218 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package unixsock blocks a unix socket by renaming it or removing its permissions, so the clients fail to connect
+// while the server keeps listening, and restores it. The socket is restored only if it is not recreated meanwhile, so
+// the socket of a restarted server is never replaced or changed.
+package unixsock
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/rootfs"
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/statefile"
+)
+
+// The modes of the block
+const (
+	// ModeRename renames the socket, the clients fail with no such file
+	ModeRename = "rename"
+	// ModeChmod removes the permissions of the socket, the clients without CAP_DAC_OVERRIDE fail with permission denied
+	ModeChmod = "chmod"
+)
+
+// Blocking is the blocked socket saved for the restore
+type Blocking struct {
+	Uid string `json:"uid"`
+	// File is the path on the host of the socket blocked
+	File string `json:"file"`
+	// Root is the rootfs of the container, or the source of its volume, the socket is changed in the directory of the
+	// Name resolved in it
+	Root string      `json:"root,omitempty"`
+	Name string      `json:"name,omitempty"`
+	Mode string      `json:"mode"`
+	Perm os.FileMode `json:"perm"`
+	Ino  uint64      `json:"ino"`
+	// Renamed is the path of the socket renamed by the rename mode
+	Renamed string `json:"renamed,omitempty"`
+}
+
+// Block blocks the socket of the name in the root by the mode, the directories are resolved in the root, and the
+// socket is changed relative to the directory opened, so neither a linked directory nor a link of the socket leads to
+// the files of the host, a socket is blocked by one experiment at a time
+func Block(stateDir, uid, root, name, mode string) (Blocking, error) {
+	dir, base, err := rootfs.OpenParent(root, name)
+	if err != nil {
+		return Blocking{}, err
+	}
+	defer dir.Close()
+	dirPath, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", dir.Fd()))
+	if err != nil {
+		return Blocking{}, err
+	}
+	file := path.Join(dirPath, base)
+	socket, stat, err := openSocket(dir, base)
+	if err != nil {
+		return Blocking{}, err
+	}
+	defer socket.Close()
+	if existing, err := findBlock(stateDir, file); err != nil {
+		return Blocking{}, err
+	} else if existing != nil {
+		return Blocking{}, fmt.Errorf("%s is already blocked by the experiment %s", file, existing.Uid)
+	}
+	block := Blocking{Uid: uid, File: file, Root: root, Name: name, Mode: mode,
+		Perm: os.FileMode(stat.Mode).Perm(), Ino: stat.Ino}
+	switch mode {
+	case ModeRename:
+		block.Renamed = renamedOf(file, uid)
+	case ModeChmod:
+	default:
+		return Blocking{}, fmt.Errorf("unsupported mode %s", mode)
+	}
+	// the state is saved before the change, so the restore works even if the agent exits
+	if err := saveBlock(stateDir, block); err != nil {
+		return Blocking{}, err
+	}
+	if mode == ModeRename {
+		err = unix.Renameat(int(dir.Fd()), base, int(dir.Fd()), path.Base(block.Renamed))
+	} else {
+		err = chmod(socket, 0)
+	}
+	if err != nil {
+		removeBlock(stateDir, uid)
+		return Blocking{}, err
+	}
+	return block, nil
+}
+
+// Restore unblocks the socket of the experiment, it returns false without error if the experiment blocked nothing,
+// or the socket is recreated after the block, e.g. by the restart of the server, then the renamed socket is removed
+func Restore(stateDir, uid string) (bool, error) {
+	var block Blocking
+	if err := statefile.Load(stateFile(stateDir, uid), &block); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	restored, err := restore(block)
+	if err != nil {
+		return false, err
+	}
+	return restored, removeBlock(stateDir, uid)
+}
+
+func restore(block Blocking) (bool, error) {
+	// the block saved by the earlier versions has the path on the host only
+	root, name := block.Root, block.Name
+	if root == "" {
+		root, name = "/", block.File
+	}
+	dir, base, err := rootfs.OpenParent(root, name)
+	if err != nil {
+		return false, nil
+	}
+	defer dir.Close()
+	dirFd := int(dir.Fd())
+	switch block.Mode {
+	case ModeRename:
+		renamed := path.Base(block.Renamed)
+		var stat unix.Stat_t
+		if err := unix.Fstatat(dirFd, renamed, &stat, unix.AT_SYMLINK_NOFOLLOW); err != nil || stat.Ino != block.Ino {
+			return false, nil
+		}
+		if err := unix.Fstatat(dirFd, base, &stat, unix.AT_SYMLINK_NOFOLLOW); err == nil {
+			return false, unix.Unlinkat(dirFd, renamed, 0)
+		}
+		return true, unix.Renameat(dirFd, renamed, dirFd, base)
+	case ModeChmod:
+		socket, stat, err := openSocket(dir, base)
+		if err != nil || stat.Ino != block.Ino {
+			return false, nil
+		}
+		defer socket.Close()
+		return true, chmod(socket, block.Perm)
+	}
+	return false, fmt.Errorf("unsupported mode %s", block.Mode)
+}
+
+// openSocket opens the socket in the directory by O_PATH without following the links
+func openSocket(dir *os.File, base string) (*os.File, unix.Stat_t, error) {
+	var stat unix.Stat_t
+	fd, err := unix.Openat(int(dir.Fd()), base, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, stat, &os.PathError{Op: "open", Path: path.Join(dir.Name(), base), Err: err}
+	}
+	socket := os.NewFile(uintptr(fd), path.Join(dir.Name(), base))
+	if err := unix.Fstat(fd, &stat); err != nil {
+		socket.Close()
+		return nil, stat, err
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFSOCK {
+		socket.Close()
+		return nil, stat, fmt.Errorf("%s is not a unix socket", socket.Name())
+	}
+	return socket, stat, nil
+}
+
+// chmod changes the socket opened by O_PATH through its magic link, fchmod refuses the O_PATH file
+func chmod(socket *os.File, perm os.FileMode) error {
+	return os.Chmod(fmt.Sprintf("/proc/self/fd/%d", socket.Fd()), perm)
+}
+
+func renamedOf(file, uid string) string {
+	return fmt.Sprintf("%s.chaosblade-%s", file, uid)
+}
+
+func inode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
+
+func stateFile(stateDir, uid string) string {
+	return statefile.Path(stateDir, "unixsock", uid)
+}
+
+func saveBlock(stateDir string, block Blocking) error {
+	return statefile.Save(stateFile(stateDir, block.Uid), block)
+}
+
+func removeBlock(stateDir, uid string) error {
+	return statefile.Remove(stateFile(stateDir, uid))
+}
+
+func findBlock(stateDir, file string) (*Blocking, error) {
+	files, err := filepath.Glob(path.Join(stateDir, "unixsock-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, stateFile := range files {
+		var block Blocking
+		if statefile.Load(stateFile, &block) == nil && block.File == file {
+			return &block, nil
+		}
+	}
+	return nil, nil
+}
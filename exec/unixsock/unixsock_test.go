@@ -0,0 +1,145 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package unixsock
+
+import (
+	"net"
+	"os"
+	"path"
+	"testing"
+)
+
+func listen(t *testing.T, file string) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("unix", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	t.Cleanup(func() { listener.Close() })
+	return listener
+}
+
+func TestBlockAndRestore(t *testing.T) {
+	for _, mode := range []string{ModeRename, ModeChmod} {
+		stateDir, dir := t.TempDir(), t.TempDir()
+		file := path.Join(dir, "app.sock")
+		listen(t, file)
+		blocking, err := Block(stateDir, "uid1", dir, path.Base(file), mode)
+		if err != nil {
+			t.Fatalf("block %s failed, %v", mode, err)
+		}
+		info, err := os.Lstat(file)
+		switch mode {
+		case ModeRename:
+			if err == nil {
+				t.Errorf("expected the socket renamed")
+			}
+			if _, err := os.Lstat(blocking.Renamed); err != nil {
+				t.Errorf("expected the renamed socket, %v", err)
+			}
+		case ModeChmod:
+			if err != nil || info.Mode().Perm() != 0 {
+				t.Errorf("expected the permissions removed, got %v, %v", info, err)
+			}
+		}
+		if _, err := Block(stateDir, "uid2", dir, path.Base(file), mode); err == nil {
+			t.Error("expected error of the socket blocked twice")
+		}
+		if restored, err := Restore(stateDir, "uid1"); err != nil || !restored {
+			t.Fatalf("restore %s failed, %v, %v", mode, restored, err)
+		}
+		if info, err := os.Lstat(file); err != nil || info.Mode().Perm() != blocking.Perm {
+			t.Errorf("expected the socket restored, got %v, %v", info, err)
+		}
+		if conn, err := net.Dial("unix", file); err != nil {
+			t.Errorf("expected the socket connected after the restore, %v", err)
+		} else {
+			conn.Close()
+		}
+		if restored, err := Restore(stateDir, "uid1"); err != nil || restored {
+			t.Errorf("expected nothing restored twice, got %v, %v", restored, err)
+		}
+	}
+}
+
+func TestRestoreRecreated(t *testing.T) {
+	stateDir, dir := t.TempDir(), t.TempDir()
+	file := path.Join(dir, "app.sock")
+	listen(t, file)
+	blocking, err := Block(stateDir, "uid1", dir, path.Base(file), ModeRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the server is restarted and listens again
+	listen(t, file)
+	recreated, _ := os.Lstat(file)
+	if restored, err := Restore(stateDir, "uid1"); err != nil || restored {
+		t.Fatalf("expected the recreated socket kept, got %v, %v", restored, err)
+	}
+	if _, err := os.Lstat(blocking.Renamed); !os.IsNotExist(err) {
+		t.Errorf("expected the renamed socket removed, %v", err)
+	}
+	if info, err := os.Lstat(file); err != nil || inode(info) != inode(recreated) {
+		t.Errorf("expected the recreated socket untouched, %v", err)
+	}
+}
+
+func TestBlockIllegal(t *testing.T) {
+	stateDir, dir := t.TempDir(), t.TempDir()
+	file := path.Join(dir, "app.conf")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Block(stateDir, "uid1", dir, path.Base(file), ModeRename); err == nil {
+		t.Error("expected error of the regular file")
+	}
+	if _, err := Block(stateDir, "uid1", dir, "absent.sock", ModeRename); err == nil {
+		t.Error("expected error of the absent socket")
+	}
+	socket := path.Join(dir, "app.sock")
+	listen(t, socket)
+	if _, err := Block(stateDir, "uid1", dir, path.Base(socket), "unlink"); err == nil {
+		t.Error("expected error of the unsupported mode")
+	}
+	if _, err := os.Stat(stateFile(stateDir, "uid1")); !os.IsNotExist(err) {
+		t.Errorf("expected no state left, %v", err)
+	}
+}
+
+func TestBlockOutsideRoot(t *testing.T) {
+	stateDir, root, outside := t.TempDir(), t.TempDir(), t.TempDir()
+	socket := path.Join(outside, "docker.sock")
+	listen(t, socket)
+	// the linked directory and the linked socket lead to the socket of the host
+	if err := os.Symlink(outside, path.Join(root, "run")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(socket, path.Join(root, "app.sock")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/run/docker.sock", "/app.sock", "/../" + path.Base(outside) + "/docker.sock"} {
+		for _, mode := range []string{ModeRename, ModeChmod} {
+			if _, err := Block(stateDir, "uid1", root, name, mode); err == nil {
+				t.Errorf("expected the socket %s outside the root refused by %s", name, mode)
+			}
+		}
+	}
+	if info, err := os.Lstat(socket); err != nil || info.Mode().Perm() == 0 {
+		t.Errorf("expected the socket of the host untouched, got %v, %v", info, err)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package warning collects the non-fatal anomalies of the operations which degrade silently otherwise, such as the
+// image status check failed before the pull, or the stderr of a command returned as its output. The collector is
+// carried by the context passed to the executor:
+//
+//	ctx, collector := warning.WithCollector(ctx)
+//	response := executor.Exec(uid, ctx, model)
+//	warnings := collector.List()
+package warning
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/log"
+)
+
+// Warning is a non-fatal anomaly of an operation, the source is the operation, such as ExecContainer
+type Warning struct {
+	Source  string    `json:"source"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Collector collects the warnings of the operations run with its context
+type Collector struct {
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+// Add appends the warning
+func (c *Collector) Add(warning Warning) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.warnings = append(c.warnings, warning)
+}
+
+// List returns the warnings in the order added
+func (c *Collector) List() []Warning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Warning(nil), c.warnings...)
+}
+
+type collectorKey struct{}
+
+// WithCollector returns the context carrying the collector, the collector of the context is reused if set, so the
+// warnings reach the outermost caller
+func WithCollector(ctx context.Context) (context.Context, *Collector) {
+	if collector := FromContext(ctx); collector != nil {
+		return ctx, collector
+	}
+	collector := &Collector{}
+	return context.WithValue(ctx, collectorKey{}, collector), collector
+}
+
+// FromContext returns the collector of the context, nil if not set
+func FromContext(ctx context.Context) *Collector {
+	collector, _ := ctx.Value(collectorKey{}).(*Collector)
+	return collector
+}
+
+// Add logs the warning of the source and adds it to the collector of the context if set
+func Add(ctx context.Context, source, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Warnf(ctx, "%s: %s", source, message)
+	if collector := FromContext(ctx); collector != nil {
+		collector.Add(Warning{Source: source, Message: message, Time: time.Now()})
+	}
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package warning
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	Add(context.Background(), "ExecContainer", "dropped without a collector")
+	ctx, collector := WithCollector(context.Background())
+	Add(ctx, "ExecContainer", "the stderr of %s is returned", "ls")
+	inner, same := WithCollector(ctx)
+	Add(inner, "CreateContainer", "image status failed")
+	if same != collector {
+		t.Error("expected the collector of the context reused")
+	}
+	warnings := collector.List()
+	if len(warnings) != 2 || warnings[0].Message != "the stderr of ls is returned" ||
+		warnings[1].Source != "CreateContainer" || warnings[1].Time.IsZero() {
+		t.Errorf("unexpected warnings %+v", warnings)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package wave burns the cpu by a waveform instead of a constant load, the load of every period is shaped by a burst
+// at its start, the cpu is burnt and slept in short slices so the load of a second follows the percent of the wave.
+package wave
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// The shapes of the bursts
+const (
+	// ShapeSquare keeps the high percent during the burst
+	ShapeSquare = "square"
+	// ShapeSpike jumps to the high percent and decays to the low percent during the burst
+	ShapeSpike = "spike"
+	// ShapeRamp rises from the low percent to the high percent during the burst
+	ShapeRamp = "ramp"
+)
+
+// slice is the time the cpu is burnt and slept in, the load of a slice is the percent of the wave
+const slice = 100 * time.Millisecond
+
+// Wave is a burst of the load at the start of every period, the load is the low percent out of the bursts
+type Wave struct {
+	Shape string `json:"shape"`
+	// High and Low are the percents of every cpu burnt
+	High int `json:"high"`
+	Low  int `json:"low"`
+	// Period and Burst are the seconds of the period and of the burst in it
+	Period int `json:"period"`
+	Burst  int `json:"burst"`
+}
+
+// Validate returns the error if the wave is illegal
+func (w Wave) Validate() error {
+	switch w.Shape {
+	case ShapeSquare, ShapeSpike, ShapeRamp:
+	default:
+		return fmt.Errorf("unsupported shape %s", w.Shape)
+	}
+	if w.Low < 0 || w.High > 100 || w.Low >= w.High {
+		return fmt.Errorf("the low percent %d must be lower than the high percent %d, in 0 to 100", w.Low, w.High)
+	}
+	if w.Burst < 1 || w.Burst > w.Period {
+		return fmt.Errorf("the burst %d seconds must be in 1 to the period %d seconds", w.Burst, w.Period)
+	}
+	return nil
+}
+
+// Percent returns the percent of the load after the elapsed time
+func (w Wave) Percent(elapsed time.Duration) float64 {
+	phase := elapsed % (time.Duration(w.Period) * time.Second)
+	burst := time.Duration(w.Burst) * time.Second
+	if phase >= burst {
+		return float64(w.Low)
+	}
+	progress := float64(phase) / float64(burst)
+	switch w.Shape {
+	case ShapeSpike:
+		return float64(w.High) - float64(w.High-w.Low)*progress
+	case ShapeRamp:
+		return float64(w.Low) + float64(w.High-w.Low)*progress
+	}
+	return float64(w.High)
+}
+
+// Burn burns the cpus by the wave until the context is done, every cpu is burnt by a locked thread
+func Burn(ctx context.Context, w Wave, cpus int) {
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < cpus; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runtime.LockOSThread()
+			for ctx.Err() == nil {
+				begin := time.Now()
+				busy := time.Duration(float64(slice) * w.Percent(begin.Sub(start)) / 100)
+				for time.Since(begin) < busy {
+				}
+				select {
+				case <-ctx.Done():
+				case <-time.After(slice - time.Since(begin)):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package wave
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	if err := (Wave{Shape: ShapeSquare, High: 90, Period: 60, Burst: 10}).Validate(); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+	for _, illegal := range []Wave{
+		{Shape: "sine", High: 90, Period: 60, Burst: 10},
+		{Shape: ShapeSquare, High: 101, Period: 60, Burst: 10},
+		{Shape: ShapeSquare, High: 50, Low: 50, Period: 60, Burst: 10},
+		{Shape: ShapeSquare, High: 50, Low: -1, Period: 60, Burst: 10},
+		{Shape: ShapeSquare, High: 90, Period: 60, Burst: 0},
+		{Shape: ShapeSquare, High: 90, Period: 10, Burst: 60},
+	} {
+		if err := illegal.Validate(); err == nil {
+			t.Errorf("expected error of %+v", illegal)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		shape    string
+		elapsed  time.Duration
+		expected float64
+	}{
+		{ShapeSquare, 0, 90},
+		{ShapeSquare, 9 * time.Second, 90},
+		{ShapeSquare, 10 * time.Second, 10},
+		{ShapeSquare, 61 * time.Second, 90},
+		{ShapeSpike, 0, 90},
+		{ShapeSpike, 5 * time.Second, 50},
+		{ShapeSpike, 30 * time.Second, 10},
+		{ShapeRamp, 0, 10},
+		{ShapeRamp, 125 * time.Second, 50},
+		{ShapeRamp, 59 * time.Second, 10},
+	}
+	for _, test := range tests {
+		w := Wave{Shape: test.shape, High: 90, Low: 10, Period: 60, Burst: 10}
+		if actual := w.Percent(test.elapsed); actual != test.expected {
+			t.Errorf("expected %v of %s after %s, got %v", test.expected, test.shape, test.elapsed, actual)
+		}
+	}
+}
+
+func userTime(t *testing.T) time.Duration {
+	t.Helper()
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		t.Fatal(err)
+	}
+	return time.Duration(usage.Utime.Nano())
+}
+
+func TestBurn(t *testing.T) {
+	before := userTime(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		Burn(ctx, Wave{Shape: ShapeSquare, High: 100, Low: 0, Period: 1, Burst: 1}, 1)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("burn is not stopped")
+	}
+	if burnt := userTime(t) - before; burnt < 100*time.Millisecond {
+		t.Errorf("expected the cpu burnt, got %s", burnt)
+	}
+}
@@ -0,0 +1,177 @@
+//go:build e2e
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+const (
+	agentToken   = "e2e-token"
+	agentAddress = "127.0.0.1:9526"
+	agentDir     = "/opt/chaosblade"
+	releaseFile  = "/opt/chaosblade.tar.gz"
+)
+
+// cluster is a single node kind cluster whose node runs the runtime under test
+type cluster struct {
+	t       *testing.T
+	name    string
+	node    string
+	runtime string
+}
+
+func run(name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("%s %s failed, %v, %s", name, strings.Join(args, " "), err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// createCluster creates the kind cluster from the node image, the node image decides the runtime
+func createCluster(t *testing.T, name, image, runtime string) *cluster {
+	t.Helper()
+	if _, err := run("kind", "create", "cluster", "--name", name, "--image", image, "--wait", "5m"); err != nil {
+		t.Fatalf("create cluster %s failed, %v", name, err)
+	}
+	c := &cluster{t: t, name: name, node: name + "-control-plane", runtime: runtime}
+	t.Cleanup(c.delete)
+	return c
+}
+
+func (c *cluster) delete() {
+	if _, err := run("kind", "delete", "cluster", "--name", c.name); err != nil {
+		c.t.Logf("delete cluster %s failed, %v", c.name, err)
+	}
+}
+
+func (c *cluster) kubectl(args ...string) (string, error) {
+	return run("kubectl", append([]string{"--context", "kind-" + c.name}, args...)...)
+}
+
+// nodeExec runs the command in the node container
+func (c *cluster) nodeExec(args ...string) (string, error) {
+	return run("docker", append([]string{"exec", c.node}, args...)...)
+}
+
+func (c *cluster) copyToNode(src, dst string) error {
+	_, err := run("docker", "cp", src, c.node+":"+dst)
+	return err
+}
+
+// deployPod creates the pod and waits until it is ready, it returns the container id
+func (c *cluster) deployPod(name, image string) string {
+	c.t.Helper()
+	if _, err := c.kubectl("run", name, "--image", image, "--restart", "Always"); err != nil {
+		c.t.Fatalf("run pod %s failed, %v", name, err)
+	}
+	if _, err := c.kubectl("wait", "--for", "condition=Ready", "pod/"+name, "--timeout", "5m"); err != nil {
+		c.t.Fatalf("wait pod %s failed, %v", name, err)
+	}
+	return c.containerId(name)
+}
+
+func (c *cluster) containerId(name string) string {
+	c.t.Helper()
+	output, err := c.nodeExec("crictl", "ps", "--name", name, "--state", "running", "-q")
+	if err != nil {
+		c.t.Fatalf("get container of pod %s failed, %v", name, err)
+	}
+	ids := strings.Fields(output)
+	if len(ids) == 0 {
+		c.t.Fatalf("no running container of pod %s", name)
+	}
+	return ids[0]
+}
+
+func (c *cluster) restartCount(name string) int {
+	c.t.Helper()
+	output, err := c.kubectl("get", "pod", name, "-o", "jsonpath={.status.containerStatuses[0].restartCount}")
+	if err != nil {
+		c.t.Fatalf("get restart count of pod %s failed, %v", name, err)
+	}
+	var count int
+	fmt.Sscanf(output, "%d", &count)
+	return count
+}
+
+// startAgent copies the agent and the chaosblade release if given to the node and starts the agent
+func (c *cluster) startAgent(agentBinary, release string) {
+	c.t.Helper()
+	if _, err := c.nodeExec("mkdir", "-p", agentDir); err != nil {
+		c.t.Fatal(err)
+	}
+	if release != "" {
+		if err := c.copyToNode(release, releaseFile); err != nil {
+			c.t.Fatal(err)
+		}
+		if _, err := c.nodeExec("tar", "-xzf", releaseFile, "-C", agentDir, "--strip-components", "1"); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	if err := c.copyToNode(agentBinary, agentDir+"/chaos_criagent"); err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := run("docker", "exec", "-d", c.node, agentDir+"/chaos_criagent",
+		"--address", agentAddress, "--token", agentToken, "--container-runtime", c.runtime); err != nil {
+		c.t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		if _, err := c.nodeExec("curl", "-sf", "http://"+agentAddress+"/readyz"); err == nil {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+	output, _ := c.nodeExec("curl", "-s", "http://"+agentAddress+"/readyz")
+	c.t.Fatalf("agent is not ready, %s", output)
+}
+
+// agent calls the agent api in the node and decodes the response
+func (c *cluster) agent(method, path string, body interface{}) *spec.Response {
+	c.t.Helper()
+	args := []string{"curl", "-s", "-X", method, "-H", "Authorization: Bearer " + agentToken}
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		args = append(args, "-H", "Content-Type: application/json", "-d", string(data))
+	}
+	output, err := c.nodeExec(append(args, "http://"+agentAddress+path)...)
+	if err != nil {
+		c.t.Fatalf("call agent %s %s failed, %v", method, path, err)
+	}
+	var response spec.Response
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		c.t.Fatalf("decode agent response %s failed, %v", output, err)
+	}
+	return &response
+}
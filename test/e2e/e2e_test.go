@@ -0,0 +1,187 @@
+//go:build e2e
+
+/*
+ * Copyright 1999-2020 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package e2e exercises the experiments against real runtimes in kind clusters, run it by
+//
+//	go test -tags e2e -v -timeout 60m ./test/e2e/...
+//
+// E2E_RUNTIMES selects the runtimes, default is containerd. The crio runtime requires E2E_CRIO_NODE_IMAGE, a kind
+// node image running cri-o. E2E_CHAOSBLADE_RELEASE is the chaosblade linux release tarball, the experiments executed
+// by the chaosblade tools are skipped without it.
+package e2e
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-exec-cri/exec/container"
+)
+
+const (
+	defaultContainerdNodeImage = "kindest/node:v1.27.3"
+	samplePodImage             = "nginx:1.25"
+)
+
+var agentBinary string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "chaosblade-e2e-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	agentBinary = path.Join(dir, "chaos_criagent")
+	build := exec.Command("go", "build", "-o", agentBinary, "../../cmd/agent")
+	build.Env = append(os.Environ(), "GOOS=linux", "CGO_ENABLED=0")
+	if output, err := build.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "build agent failed, %v, %s\n", err, output)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+type runtimeCase struct {
+	runtime   string
+	nodeImage string
+}
+
+func runtimeCases(t *testing.T) []runtimeCase {
+	runtimes := os.Getenv("E2E_RUNTIMES")
+	if runtimes == "" {
+		runtimes = container.ContainerdRuntime
+	}
+	cases := make([]runtimeCase, 0)
+	for _, runtime := range strings.Split(runtimes, ",") {
+		switch runtime {
+		case container.ContainerdRuntime:
+			image := os.Getenv("E2E_CONTAINERD_NODE_IMAGE")
+			if image == "" {
+				image = defaultContainerdNodeImage
+			}
+			cases = append(cases, runtimeCase{runtime: runtime, nodeImage: image})
+		case container.CRIORuntime:
+			image := os.Getenv("E2E_CRIO_NODE_IMAGE")
+			if image == "" {
+				t.Logf("E2E_CRIO_NODE_IMAGE is not set, skip %s", runtime)
+				continue
+			}
+			cases = append(cases, runtimeCase{runtime: runtime, nodeImage: image})
+		default:
+			t.Fatalf("unsupported runtime %s", runtime)
+		}
+	}
+	return cases
+}
+
+func TestExperiments(t *testing.T) {
+	release := os.Getenv("E2E_CHAOSBLADE_RELEASE")
+	for _, rc := range runtimeCases(t) {
+		rc := rc
+		t.Run(rc.runtime, func(t *testing.T) {
+			c := createCluster(t, fmt.Sprintf("chaosblade-e2e-%s", rc.runtime), rc.nodeImage, rc.runtime)
+			c.startAgent(agentBinary, release)
+
+			t.Run("targets", func(t *testing.T) {
+				containerId := c.deployPod("e2e-targets", samplePodImage)
+				response := c.agent("GET", "/v1/targets?container-runtime="+rc.runtime, nil)
+				if !response.Success {
+					t.Fatalf("list targets failed, %s", response.Err)
+				}
+				if !strings.Contains(fmt.Sprintf("%v", response.Result), containerId) {
+					t.Errorf("container %s is not in the targets", containerId)
+				}
+			})
+
+			t.Run("kill", func(t *testing.T) {
+				containerId := c.deployPod("e2e-kill", samplePodImage)
+				before := c.restartCount("e2e-kill")
+				c.create(t, "container", "remove", map[string]string{
+					"container-id": containerId,
+					"force":        "true",
+				})
+				deadline := time.Now().Add(3 * time.Minute)
+				for c.restartCount("e2e-kill") <= before {
+					if time.Now().After(deadline) {
+						t.Fatal("the container is not restarted after removed")
+					}
+					time.Sleep(2 * time.Second)
+				}
+			})
+
+			t.Run("copy-exec", func(t *testing.T) {
+				if release == "" {
+					t.Skip("E2E_CHAOSBLADE_RELEASE is not set")
+				}
+				containerId := c.deployPod("e2e-copy", samplePodImage)
+				uid := c.create(t, "file", "append", map[string]string{
+					"container-id":       containerId,
+					"chaosblade-release": releaseFile,
+					"filepath":           "/tmp/e2e.log",
+					"content":            "chaosblade-e2e",
+				})
+				output, err := c.nodeExec("crictl", "exec", containerId, "cat", "/tmp/e2e.log")
+				if err != nil || !strings.Contains(output, "chaosblade-e2e") {
+					t.Errorf("the content is not appended, %s, %v", output, err)
+				}
+				c.destroy(t, uid)
+			})
+
+			t.Run("network", func(t *testing.T) {
+				if release == "" {
+					t.Skip("E2E_CHAOSBLADE_RELEASE is not set")
+				}
+				containerId := c.deployPod("e2e-network", samplePodImage)
+				uid := c.create(t, "network", "delay", map[string]string{
+					"container-id": containerId,
+					"interface":    "eth0",
+					"time":         "100",
+				})
+				c.destroy(t, uid)
+			})
+		})
+	}
+}
+
+// create creates the experiment by the agent and returns the uid
+func (c *cluster) create(t *testing.T, target, action string, flags map[string]string) string {
+	t.Helper()
+	flags["container-runtime"] = c.runtime
+	response := c.agent("POST", "/v1/experiments", map[string]interface{}{
+		"target": target,
+		"action": action,
+		"flags":  flags,
+	})
+	if !response.Success {
+		t.Fatalf("create %s %s failed, %s", target, action, response.Err)
+	}
+	return fmt.Sprintf("%v", response.Result)
+}
+
+func (c *cluster) destroy(t *testing.T, uid string) {
+	t.Helper()
+	if response := c.agent("DELETE", "/v1/experiments/"+uid, nil); !response.Success {
+		t.Errorf("destroy %s failed, %s", uid, response.Err)
+	}
+}